@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
 	"github.com/tomochain/tomochain/core"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/params"
@@ -154,11 +155,15 @@ func (w *wizard) makeGenesis() {
 		validatorCap := new(big.Int)
 		validatorCap.SetString("50000000000000000000000", 10)
 		var validatorCaps []*big.Int
-		genesis.ExtraData = make([]byte, 32+len(signers)*common.AddressLength+65)
-		for i, signer := range signers {
+		for range signers {
 			validatorCaps = append(validatorCaps, validatorCap)
-			copy(genesis.ExtraData[32+i*common.AddressLength:], signer[:])
 		}
+		genesisExtra := &extra.Extra{Version: extra.VersionAt(common.Big0), Masternodes: signers}
+		extraData, err := genesisExtra.Encode()
+		if err != nil {
+			log.Crit("Failed to encode genesis extra-data", "err", err)
+		}
+		genesis.ExtraData = extraData
 
 		fmt.Println()
 		fmt.Println("How many blocks per epoch? (default = 900)")
@@ -35,6 +35,8 @@ import (
 	"github.com/tomochain/tomochain/eth/downloader"
 	"github.com/tomochain/tomochain/event"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -66,7 +68,11 @@ It expects the genesis file as argument.`,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 			utils.GCModeFlag,
+			utils.GCRetainFlag,
+			utils.StateVerifyFlag,
+			utils.HistoryExpiryFlag,
 			utils.CacheDatabaseFlag,
+			utils.CacheTrieFlag,
 			utils.CacheGCFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -86,13 +92,20 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
+			utils.ExportFormatFlag,
+			utils.ExportWorkersFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
-if already existing.`,
+if already existing.
+
+With --format csv, the first argument names a directory that
+receives one file per table (blocks, transactions, receipts
+and trades), ready to be loaded into a data warehouse. Block
+ranges are converted by --workers goroutines in parallel.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -120,7 +133,112 @@ if already existing.`,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
-The export-preimages command export hash preimages to an RLP encoded stream`,
+The export-preimages command export hash preimages to an RLP encoded stream.
+Preimages are only available if the node ran without --nopreimages.`,
+	}
+	exportStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportState),
+		Name:      "export-state",
+		Usage:     "Export the state of a block into a file",
+		ArgsUsage: "<filename> [<blockNum>|<blockHash>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TomoXDataDirFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-state command writes the full state of a block, the current head
+unless a second argument is given, into a file: the accounts with their
+storage and code, and the TomoX order and lending books. The blocks back to
+the previous epoch checkpoint are written along. If the file name ends in
+.gz, the output is gzipped.
+
+The file can be imported into a fresh node with the import-state command.`,
+	}
+	importStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(importState),
+		Name:      "import-state",
+		Usage:     "Bootstrap a fresh node from a state export file",
+		ArgsUsage: "<filename>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TomoXDataDirFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The import-state command imports a file written by export-state into a node
+holding no block but the genesis one. The exported block becomes the head of
+the chain, from which the node syncs once started. The history below the
+exported blocks is not available on the node.`,
+	}
+	exportTomoXCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportTomoX),
+		Name:      "export-tomox",
+		Usage:     "Export the TomoX order and lending books of an epoch into a JSON file",
+		ArgsUsage: "<filename> [<epoch>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TomoXDataDirFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-tomox command writes the TomoX state at the checkpoint block of an
+epoch, the last one of the chain unless a second argument is given, into a JSON
+file: the resting and stop orders of every order book, and the resting orders
+and open loans of every lending book. Relayers can rebuild their off-chain
+database from it. If the file name ends in .gz, the output is gzipped.`,
+	}
+	repairTomoXCommand = cli.Command{
+		Action:    utils.MigrateFlags(repairTomoX),
+		Name:      "repair-tomox",
+		Usage:     "Roll the TomoX databases back to the last consistent block",
+		ArgsUsage: "[<blockNum>|<blockHash>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TomoXDataDirFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The repair-tomox command recovers a node whose TomoX database got corrupted
+without a full resync. Starting from the given block, the current head unless
+an argument is given, it walks the chain down until a block whose state and
+order and lending books are complete, verifying every node of their tries. The
+chain is rewound to that block and the candles of the blocks above it are
+rolled back, the blocks being processed again once the node is started.`,
+	}
+	migrateTomoXDryRunFlag = cli.BoolFlag{
+		Name:  "dryrun",
+		Usage: "Only report the order books the migration changes, without writing the migrated state",
+	}
+	migrateTomoXCommand = cli.Command{
+		Action:    utils.MigrateFlags(migrateTomoX),
+		Name:      "migrate-tomox",
+		Usage:     "Migrate the TomoX trading state of an epoch to a new layout",
+		ArgsUsage: "<epoch> rekey <baseToken> <quoteToken> <newBaseToken> <newQuoteToken> | <epoch> drop <baseToken> <quoteToken>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.TomoXDataDirFlag,
+			migrateTomoXDryRunFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The migrate-tomox command applies a migration to the trading state at the
+checkpoint block of an epoch, the way a hard fork changing the layout of the
+order books applies it, and prints the order books it changes along with the
+migrated root as JSON:
+
+  rekey moves the order book of a pair to another pair, rewriting the tokens
+        of its orders, e.g. after a token contract was redeployed
+  drop  removes the order book of a delisted pair, which must hold no order
+
+With --dryrun, the migrated state is not written into the TomoX database. The
+chain is never changed: the migrated root only becomes the state of the chain
+through the hard fork applying the same migration.`,
 	}
 	copydbCommand = cli.Command{
 		Action:    utils.MigrateFlags(copyDb),
@@ -151,6 +269,22 @@ The first argument must be the directory containing the blockchain to download f
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 Remove blockchain and state databases`,
+	}
+	migrateReceiptsCommand = cli.Command{
+		Action:    utils.MigrateFlags(migrateReceipts),
+		Name:      "migrate-receipts",
+		Usage:     "Rewrite stored receipts into the compact storage format",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The migrate-receipts command rewrites the receipts of every canonical block
+still stored in the legacy format into the compact one, which omits the fields
+derivable from the block and the receipt blooms. These are regenerated when the
+receipts are read. Run it on a stopped node.`,
 	}
 	dumpCommand = cli.Command{
 		Action:    utils.MigrateFlags(dump),
@@ -304,6 +438,26 @@ func exportChain(ctx *cli.Context) error {
 
 	var err error
 	fp := ctx.Args().First()
+	switch format := ctx.String(utils.ExportFormatFlag.Name); format {
+	case "rlp":
+	case "csv":
+		first, last := uint64(0), chain.CurrentBlock().NumberU64()
+		if len(ctx.Args()) >= 3 {
+			f, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+			l, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+			if ferr != nil || lerr != nil {
+				utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+			}
+			first, last = f, l
+		}
+		if err := utils.ExportChainCSV(chain, fp, first, last, ctx.Int(utils.ExportWorkersFlag.Name)); err != nil {
+			utils.Fatalf("Export error: %v\n", err)
+		}
+		fmt.Printf("Export done in %v\n", time.Since(start))
+		return nil
+	default:
+		utils.Fatalf("Export error: unsupported format %q", format)
+	}
 	if len(ctx.Args()) < 3 {
 		err = utils.ExportChain(chain, fp)
 	} else {
@@ -336,13 +490,13 @@ func importPreimages(ctx *cli.Context) error {
 
 	start := time.Now()
 	if err := utils.ImportPreimages(diskdb, ctx.Args().First()); err != nil {
-		utils.Fatalf("Export error: %v\n", err)
+		utils.Fatalf("Import error: %v\n", err)
 	}
-	fmt.Printf("Export done in %v\n", time.Since(start))
+	fmt.Printf("Import done in %v\n", time.Since(start))
 	return nil
 }
 
-// exportPreimages dumps the preimage data to specified json file in streaming way.
+// exportPreimages dumps the preimage data to specified RLP file in streaming way.
 func exportPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
 		utils.Fatalf("This command requires an argument.")
@@ -358,6 +512,164 @@ func exportPreimages(ctx *cli.Context) error {
 	return nil
 }
 
+// exportState writes the state of a block into the specified file.
+func exportState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, cfg := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	block := chain.CurrentBlock()
+	if len(ctx.Args()) > 1 {
+		if arg := ctx.Args().Get(1); hashish(arg) {
+			block = chain.GetBlockByHash(common.HexToHash(arg))
+		} else {
+			num, _ := strconv.ParseUint(arg, 10, 64)
+			block = chain.GetBlockByNumber(num)
+		}
+		if block == nil {
+			utils.Fatalf("Export error: block not found")
+		}
+	}
+	tomoxDb := tomox.NewLDBEngine(&cfg.TomoX)
+	defer tomoxDb.Close()
+
+	start := time.Now()
+	err := utils.ExportState(chain, tomoxDb, ctx.Args().First(), block)
+	chain.Stop()
+	if err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+// importState bootstraps a fresh node from the specified state export file.
+func importState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, cfg := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	if _, _, err := core.SetupGenesisBlock(chainDb, utils.MakeGenesis(ctx)); err != nil {
+		utils.Fatalf("Failed to write genesis block: %v", err)
+	}
+	tomoxDb := tomox.NewLDBEngine(&cfg.TomoX)
+	defer tomoxDb.Close()
+
+	start := time.Now()
+	if err := utils.ImportState(chainDb, tomoxDb, ctx.Args().First()); err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+// exportTomoX writes the TomoX snapshot of an epoch into the specified file.
+func exportTomoX(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, cfg := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	posv := chain.Config().Posv
+	if posv == nil || posv.Epoch == 0 {
+		utils.Fatalf("Export error: chain has no epoch")
+	}
+	epoch := chain.CurrentBlock().NumberU64() / posv.Epoch
+	if len(ctx.Args()) > 1 {
+		var err error
+		if epoch, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			utils.Fatalf("Export error: invalid epoch: %v", err)
+		}
+	}
+	tomoxDb := tomox.NewLDBEngine(&cfg.TomoX)
+	defer tomoxDb.Close()
+
+	start := time.Now()
+	err := utils.ExportTomoXSnapshot(chain, tomoxDb, ctx.Args().First(), epoch)
+	chain.Stop()
+	if err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+// repairTomoX rolls the chain and the TomoX databases back to the last block
+// with a consistent state.
+func repairTomoX(ctx *cli.Context) error {
+	stack, cfg := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	block := chain.CurrentBlock()
+	if len(ctx.Args()) > 0 {
+		if arg := ctx.Args().First(); hashish(arg) {
+			block = chain.GetBlockByHash(common.HexToHash(arg))
+		} else {
+			num, _ := strconv.ParseUint(arg, 10, 64)
+			block = chain.GetBlockByNumber(num)
+		}
+		if block == nil {
+			utils.Fatalf("Repair error: block not found")
+		}
+	}
+	tomoxDb := tomox.NewLDBEngine(&cfg.TomoX)
+	defer tomoxDb.Close()
+
+	start := time.Now()
+	err := utils.RepairTomoX(chain, tomoxDb, block)
+	chain.Stop()
+	if err != nil {
+		utils.Fatalf("Repair error: %v\n", err)
+	}
+	fmt.Printf("Repair done in %v\n", time.Since(start))
+	return nil
+}
+
+// migrateTomoX migrates the trading state of an epoch checkpoint.
+func migrateTomoX(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) < 2 {
+		utils.Fatalf("This command requires an epoch and a migration.")
+	}
+	epoch, err := strconv.ParseUint(args.First(), 10, 64)
+	if err != nil {
+		utils.Fatalf("Migration error: invalid epoch: %v", err)
+	}
+	var migration tradingstate.Migration
+	switch tokens := args[2:]; {
+	case args.Get(1) == "rekey" && len(tokens) == 4:
+		migration = tradingstate.RekeyOrderBook(common.HexToAddress(tokens[0]), common.HexToAddress(tokens[1]), common.HexToAddress(tokens[2]), common.HexToAddress(tokens[3]))
+	case args.Get(1) == "drop" && len(tokens) == 2:
+		migration = tradingstate.DropOrderBook(common.HexToAddress(tokens[0]), common.HexToAddress(tokens[1]))
+	default:
+		utils.Fatalf("Migration error: unknown migration %q with %d tokens", args.Get(1), len(tokens))
+	}
+	stack, cfg := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	tomoxDb := tomox.NewLDBEngine(&cfg.TomoX)
+	defer tomoxDb.Close()
+
+	start := time.Now()
+	err = utils.MigrateTomoX(chain, tomoxDb, os.Stdout, epoch, ctx.Bool(migrateTomoXDryRunFlag.Name), migration)
+	chain.Stop()
+	if err != nil {
+		utils.Fatalf("Migration error: %v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Migration done in %v\n", time.Since(start))
+	return nil
+}
+
 func copyDb(ctx *cli.Context) error {
 	// Ensure we have a source chain directory to copy
 	if len(ctx.Args()) != 1 {
@@ -435,6 +747,52 @@ func removeDB(ctx *cli.Context) error {
 	return nil
 }
 
+// migrateReceipts rewrites the receipts of the canonical chain into the compact
+// storage format.
+func migrateReceipts(ctx *cli.Context) error {
+	stack, _ := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	head := core.GetHeadBlockHash(chainDb)
+	if head == (common.Hash{}) {
+		utils.Fatalf("No head block found in the database")
+	}
+	var (
+		last     = core.GetBlockNumber(chainDb, head)
+		migrated uint64
+		start    = time.Now()
+		logged   = time.Now()
+	)
+	for number := uint64(0); number <= last; number++ {
+		hash := core.GetCanonicalHash(chainDb, number)
+		if hash == (common.Hash{}) {
+			utils.Fatalf("Canonical hash missing for block #%d", number)
+		}
+		changed, err := core.MigrateBlockReceipts(chainDb, hash, number)
+		if err != nil {
+			utils.Fatalf("Failed to migrate receipts of block #%d: %v", number, err)
+		}
+		if changed {
+			migrated++
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Migrating receipts", "number", number, "last", last, "migrated", migrated, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	fmt.Printf("Migrated receipts of %d blocks in %v\n", migrated, time.Since(start))
+
+	// Compact the entire database to reclaim the space of the legacy receipts
+	start = time.Now()
+	fmt.Println("Compacting entire database...")
+	if err := chainDb.Compact(nil, nil); err != nil {
+		utils.Fatalf("Compaction failed: %v", err)
+	}
+	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
+	return nil
+}
+
 func dump(ctx *cli.Context) error {
 	stack, _ := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
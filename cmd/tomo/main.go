@@ -70,7 +70,16 @@ var (
 		utils.TomoXDBEngineFlag,
 		utils.TomoXDBConnectionUrlFlag,
 		utils.TomoXDBReplicaSetNameFlag,
+		utils.TomoXSinkPostgresFlag,
+		utils.TomoXSinkKafkaFlag,
+		utils.TomoXSinkKafkaTopicFlag,
 		utils.TomoXDBNameFlag,
+		utils.TomoXBookSamplingFlag,
+		utils.TomoXGossipRateFlag,
+		utils.TomoXGossipPoolFlag,
+		utils.TomoXCandlesFlag,
+		utils.TomoXAddressIndexFlag,
+		utils.TomoXVerifyFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
@@ -81,27 +90,44 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolGapPolicyFlag,
+		utils.TxPoolSpecialGapPolicyFlag,
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.GCRetainFlag,
+		utils.StateVerifyFlag,
+		utils.HistoryExpiryFlag,
+		utils.CrossValidateFlag,
+		utils.NoPreimagesFlag,
 		//utils.LightServFlag,
 		//utils.LightPeersFlag,
 		//utils.LightKDFFlag,
-		//utils.CacheFlag,
-		//utils.CacheDatabaseFlag,
-		//utils.CacheGCFlag,
+		utils.CacheFlag,
+		utils.CacheDatabaseFlag,
+		utils.CacheTrieFlag,
+		utils.CacheGCFlag,
 		//utils.TrieCacheGenFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.MaxPeersPerIPFlag,
+		utils.MaxPeersPerSubnetFlag,
 		utils.EtherbaseFlag,
 		utils.GasPriceFlag,
 		utils.StakerThreadsFlag,
 		utils.StakingEnabledFlag,
+		utils.HealthIntervalFlag,
+		utils.HealthMinPeersFlag,
+		utils.HealthMaxDriftFlag,
+		utils.HealthMaxDBLatencyFlag,
+		utils.HealthStopSealingFlag,
 		utils.TargetGasLimitFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
+		utils.DiscoveryDNSFlag,
+		utils.NetFilterFlag,
 		//utils.DiscoveryV5Flag,
 		//utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
@@ -128,6 +154,10 @@ var (
 		utils.StoreRewardFlag,
 		utils.RollbackFlag,
 		utils.TomoSlaveModeFlag,
+		utils.OverrideTomoXFlag,
+		utils.OverrideTomoXLendingFlag,
+		utils.OverrideBerlinFlag,
+		utils.OverrideLondonFlag,
 	}
 
 	rpcFlags = []cli.Flag{
@@ -135,6 +165,8 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.RPCStateVerifyFlag,
+		utils.RPCCallCacheFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -142,6 +174,7 @@ var (
 		utils.WSAllowedOriginsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.RPCDeprecationMetricsFlag,
 	}
 
 	whisperFlags = []cli.Flag{
@@ -163,6 +196,14 @@ func init() {
 		exportCommand,
 		removedbCommand,
 		dumpCommand,
+		migrateReceiptsCommand,
+		importPreimagesCommand,
+		exportPreimagesCommand,
+		exportStateCommand,
+		importStateCommand,
+		exportTomoXCommand,
+		repairTomoXCommand,
+		migrateTomoXCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
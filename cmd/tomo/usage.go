@@ -74,6 +74,11 @@ var AppHelpFlagGroups = []flagGroup{
 			//utils.RinkebyFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.GCRetainFlag,
+			utils.StateVerifyFlag,
+			utils.HistoryExpiryFlag,
+			utils.CrossValidateFlag,
+			utils.NoPreimagesFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			//utils.LightServFlag,
@@ -123,15 +128,15 @@ var AppHelpFlagGroups = []flagGroup{
 	//		utils.TxPoolLifetimeFlag,
 	//	},
 	//},
-	//{
-	//	Name: "PERFORMANCE TUNING",
-	//	Flags: []cli.Flag{
-	//		utils.CacheFlag,
-	//		utils.CacheDatabaseFlag,
-	//		utils.CacheGCFlag,
-	//		utils.TrieCacheGenFlag,
-	//	},
-	//},
+	{
+		Name: "PERFORMANCE TUNING",
+		Flags: []cli.Flag{
+			utils.CacheFlag,
+			utils.CacheDatabaseFlag,
+			utils.CacheTrieFlag,
+			utils.CacheGCFlag,
+		},
+	},
 	{
 		Name: "ACCOUNT",
 		Flags: []cli.Flag{
@@ -146,6 +151,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.RPCStateVerifyFlag,
+			utils.RPCCallCacheFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -155,6 +162,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
+			utils.RPCDeprecationMetricsFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -169,8 +177,12 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
+			utils.MaxPeersPerIPFlag,
+			utils.MaxPeersPerSubnetFlag,
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
+			utils.DiscoveryDNSFlag,
+			utils.NetFilterFlag,
 			//utils.DiscoveryV5Flag,
 			//utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
@@ -186,6 +198,11 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.HealthIntervalFlag,
+			utils.HealthMinPeersFlag,
+			utils.HealthMaxDriftFlag,
+			utils.HealthMaxDBLatencyFlag,
+			utils.HealthStopSealingFlag,
 		},
 	},
 	//{
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -36,6 +37,9 @@ import (
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/node"
 	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxDAO"
 )
 
 const (
@@ -302,12 +306,136 @@ func ExportPreimages(db ethdb.Database, fn string) error {
 		defer writer.(*gzip.Writer).Close()
 	}
 	// Iterate over the preimages and export them
-	it := db.NewIterator([]byte("secure-key-"), nil)
+	it := core.PreimageTable(db).NewIterator(nil, nil)
+	defer it.Release()
+
 	for it.Next() {
 		if err := rlp.Encode(writer, it.Value()); err != nil {
 			return err
 		}
 	}
+	if err := it.Error(); err != nil {
+		return err
+	}
 	log.Info("Exported preimages", "file", fn)
 	return nil
 }
+
+// ExportState exports the state of a block, along with the TomoX state held in
+// tomoxdb if set, into the specified file.
+func ExportState(blockchain *core.BlockChain, tomoxdb ethdb.Database, fn string, block *types.Block) error {
+	log.Info("Exporting state", "file", fn, "number", block.Number(), "hash", block.Hash())
+
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	if err := blockchain.ExportState(writer, block, tomoxdb); err != nil {
+		return err
+	}
+	log.Info("Exported state", "file", fn)
+	return nil
+}
+
+// ExportTomoXSnapshot writes the TomoX snapshot of an epoch, read from tomoxdb,
+// into the specified file.
+func ExportTomoXSnapshot(blockchain *core.BlockChain, tomoxdb ethdb.Database, fn string, epoch uint64) error {
+	log.Info("Exporting TomoX snapshot", "file", fn, "epoch", epoch)
+
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	snapshot, err := blockchain.ExportTomoXSnapshot(writer, epoch, tomoxdb)
+	if err != nil {
+		return err
+	}
+	log.Info("Exported TomoX snapshot", "file", fn, "number", snapshot.Number, "orderbooks", len(snapshot.OrderBooks), "lendingbooks", len(snapshot.LendingBooks))
+	return nil
+}
+
+// ImportState imports a state export file into a database holding no block but
+// the genesis one, the TomoX state going into tomoxdb.
+func ImportState(db ethdb.Database, tomoxdb ethdb.Database, fn string) error {
+	log.Info("Importing state", "file", fn)
+
+	// Open the file handle and potentially unwrap the gzip stream
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	block, err := core.ImportState(reader, db, tomoxdb)
+	if err != nil {
+		return err
+	}
+	log.Info("Imported state", "file", fn, "number", block.Number(), "hash", block.Hash())
+	return nil
+}
+
+// RepairTomoX rolls the chain and the TomoX databases back from the given block
+// to the last block whose state is consistent, restoring the candles of the
+// blocks rolled back.
+func RepairTomoX(blockchain *core.BlockChain, tomoxdb *tomoxDAO.BatchDatabase, from *types.Block) error {
+	log.Info("Verifying TomoX state", "number", from.Number(), "hash", from.Hash())
+
+	block, err := blockchain.LastConsistentTomoXBlock(tomoxdb, from)
+	if err != nil {
+		return err
+	}
+	head := blockchain.CurrentBlock().NumberU64()
+	for number := head; number > block.NumberU64(); number-- {
+		if dropped := blockchain.GetBlockByNumber(number); dropped != nil {
+			if err := tomox.RollbackBlockCandles(tomoxdb, dropped); err != nil {
+				return err
+			}
+		}
+	}
+	if err := blockchain.RewindTomoX(tomoxdb, block); err != nil {
+		return err
+	}
+	log.Info("Repaired TomoX state", "number", block.Number(), "hash", block.Hash(), "dropped", head-block.NumberU64())
+	return nil
+}
+
+// MigrateTomoX applies the migrations to the trading state of the checkpoint
+// block of an epoch and writes the order books they change into w, as indented
+// JSON. Unless dryRun is set, the migrated state is also written into tomoxdb.
+func MigrateTomoX(blockchain *core.BlockChain, tomoxdb ethdb.Database, w io.Writer, epoch uint64, dryRun bool, migrations ...tradingstate.Migration) error {
+	log.Info("Migrating TomoX state", "epoch", epoch, "migrations", len(migrations), "dryrun", dryRun)
+
+	result, err := blockchain.MigrateTomoX(epoch, tomoxdb, !dryRun, migrations...)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return err
+	}
+	log.Info("Migrated TomoX state", "number", result.Number, "root", result.TradingRoot, "migrated", result.MigratedRoot, "orderbooks", len(result.OrderBooks))
+	return nil
+}
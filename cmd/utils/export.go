@@ -0,0 +1,245 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// exportBatchSize is the number of blocks a single export worker converts
+// before handing its rows over to the writer.
+const exportBatchSize = 1000
+
+// exportTables lists the tables of a columnar export, each written into its
+// own file named after the table.
+var exportTables = []struct {
+	name    string
+	columns []string
+}{
+	{"blocks", []string{"number", "hash", "parent_hash", "timestamp", "miner", "gas_limit", "gas_used", "base_fee_per_gas", "transaction_count", "size"}},
+	{"transactions", []string{"block_number", "block_hash", "transaction_index", "hash", "type", "from", "to", "nonce", "value", "gas", "gas_price", "max_fee_per_gas", "max_priority_fee_per_gas", "input"}},
+	{"receipts", []string{"block_number", "transaction_hash", "transaction_index", "status", "cumulative_gas_used", "gas_used", "contract_address", "log_count"}},
+	{"trades", []string{"block_number", "transaction_hash", "order_hash", "order_id", "exchange", "user", "base_token", "quote_token", "side", "type", "status", "price", "quantity"}},
+}
+
+// exportRows holds the rows of every table for a consecutive range of blocks.
+type exportRows [][][]string
+
+// ExportChainCSV exports the blocks first to last (inclusive) of the canonical
+// chain as CSV tables into the given directory. Block ranges are converted by
+// the given number of workers in parallel, rows are written in block order.
+//
+// The trades table lists the orders matched by the TomoX engine in each block,
+// as recorded in the trading transactions.
+func ExportChainCSV(blockchain *core.BlockChain, dir string, first, last uint64, workers int) error {
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	log.Info("Exporting blockchain", "dir", dir, "format", "csv", "first", first, "last", last, "workers", workers)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	writers := make([]*csv.Writer, len(exportTables))
+	for i, table := range exportTables {
+		fh, err := os.OpenFile(filepath.Join(dir, table.name+".csv"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+
+		writers[i] = csv.NewWriter(fh)
+		if err := writers[i].Write(table.columns); err != nil {
+			return err
+		}
+	}
+	// Convert the requested range in rounds of one batch per worker, flushing
+	// each round in order so the output is deterministic.
+	for start, done := first, false; !done; {
+		var ranges [][2]uint64
+		for len(ranges) < workers && !done {
+			end := last
+			if last-start >= exportBatchSize {
+				end = start + exportBatchSize - 1
+			}
+			ranges = append(ranges, [2]uint64{start, end})
+			start, done = end+1, end == last
+		}
+		var (
+			batches = make([]exportRows, len(ranges))
+			errs    = make([]error, len(ranges))
+			wg      sync.WaitGroup
+		)
+		for i, r := range ranges {
+			wg.Add(1)
+			go func(i int, from, to uint64) {
+				defer wg.Done()
+				batches[i], errs[i] = exportCSVRows(blockchain, from, to)
+			}(i, r[0], r[1])
+		}
+		wg.Wait()
+
+		for i, rows := range batches {
+			if errs[i] != nil {
+				return errs[i]
+			}
+			for table, records := range rows {
+				if err := writers[table].WriteAll(records); err != nil {
+					return err
+				}
+			}
+		}
+		log.Info("Exported blocks", "number", ranges[len(ranges)-1][1])
+	}
+	log.Info("Exported blockchain", "dir", dir)
+	return nil
+}
+
+// exportCSVRows converts the blocks first to last into rows of each table.
+func exportCSVRows(blockchain *core.BlockChain, first, last uint64) (exportRows, error) {
+	rows := make(exportRows, len(exportTables))
+	for nr := first; nr <= last; nr++ {
+		block := blockchain.GetBlockByNumber(nr)
+		if block == nil {
+			return nil, fmt.Errorf("export failed on #%d: not found", nr)
+		}
+		number := strconv.FormatUint(nr, 10)
+		rows[0] = append(rows[0], []string{
+			number,
+			block.Hash().Hex(),
+			block.ParentHash().Hex(),
+			block.Time().String(),
+			block.Coinbase().Hex(),
+			strconv.FormatUint(block.GasLimit(), 10),
+			strconv.FormatUint(block.GasUsed(), 10),
+			exportBig(block.BaseFee()),
+			strconv.Itoa(len(block.Transactions())),
+			strconv.FormatFloat(float64(block.Size()), 'f', 0, 64),
+		})
+		signer := types.MakeSigner(blockchain.Config(), block.Number())
+		for i, tx := range block.Transactions() {
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				return nil, fmt.Errorf("export failed on #%d: tx %x: %v", nr, tx.Hash(), err)
+			}
+			var to string
+			if tx.To() != nil {
+				to = tx.To().Hex()
+			}
+			var feeCap, tipCap string
+			if tx.Type() == types.DynamicFeeTxType {
+				feeCap, tipCap = exportBig(tx.GasFeeCap()), exportBig(tx.GasTipCap())
+			}
+			rows[1] = append(rows[1], []string{
+				number,
+				block.Hash().Hex(),
+				strconv.Itoa(i),
+				tx.Hash().Hex(),
+				strconv.Itoa(int(tx.Type())),
+				from.Hex(),
+				to,
+				strconv.FormatUint(tx.Nonce(), 10),
+				exportBig(tx.Value()),
+				strconv.FormatUint(tx.Gas(), 10),
+				exportBig(tx.GasPrice()),
+				feeCap,
+				tipCap,
+				hexutil.Encode(tx.Data()),
+			})
+			if tx.To() != nil && tx.To().Hex() == common.TradingStateAddr {
+				trades, err := exportTrades(number, tx)
+				if err != nil {
+					return nil, fmt.Errorf("export failed on #%d: tx %x: %v", nr, tx.Hash(), err)
+				}
+				rows[3] = append(rows[3], trades...)
+			}
+		}
+		for i, receipt := range blockchain.GetReceiptsByHash(block.Hash()) {
+			var contract string
+			if receipt.ContractAddress != (common.Address{}) {
+				contract = receipt.ContractAddress.Hex()
+			}
+			rows[2] = append(rows[2], []string{
+				number,
+				receipt.TxHash.Hex(),
+				strconv.Itoa(i),
+				strconv.FormatUint(uint64(receipt.Status), 10),
+				strconv.FormatUint(receipt.CumulativeGasUsed, 10),
+				strconv.FormatUint(receipt.GasUsed, 10),
+				contract,
+				strconv.Itoa(len(receipt.Logs)),
+			})
+		}
+	}
+	return rows, nil
+}
+
+// exportTrades converts the orders matched by a TomoX trading transaction into
+// rows of the trades table.
+func exportTrades(number string, tx *types.Transaction) ([][]string, error) {
+	batch, err := tradingstate.DecodeTxMatchesBatch(tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]string, 0, len(batch.Data))
+	for _, match := range batch.Data {
+		order, err := match.DecodeOrder()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, []string{
+			number,
+			tx.Hash().Hex(),
+			order.Hash.Hex(),
+			strconv.FormatUint(order.OrderID, 10),
+			order.ExchangeAddress.Hex(),
+			order.UserAddress.Hex(),
+			order.BaseToken.Hex(),
+			order.QuoteToken.Hex(),
+			order.Side,
+			order.Type,
+			order.Status,
+			exportBig(order.Price),
+			exportBig(order.Quantity),
+		})
+	}
+	return rows, nil
+}
+
+// exportBig formats an optional big integer as a decimal string.
+func exportBig(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that a CSV export produces the same rows regardless of the number of
+// workers converting the chain.
+func TestExportChainCSV(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2500, func(i int, block *core.BlockGen) {
+		if i%100 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0xaa}, big.NewInt(1), params.TxGas, nil, nil), signer, key)
+			block.AddTx(tx)
+		}
+	})
+	chain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "tomo-export-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	want := map[string]int{"blocks": 2501, "transactions": 25, "receipts": 25, "trades": 0}
+	var serial map[string][][]string
+	for _, workers := range []int{1, 4} {
+		out := filepath.Join(dir, "workers", string('0'+rune(workers)))
+		if err := ExportChainCSV(chain, out, 0, chain.CurrentBlock().NumberU64(), workers); err != nil {
+			t.Fatalf("workers %d: export failed: %v", workers, err)
+		}
+		tables := make(map[string][][]string)
+		for name, rows := range want {
+			fh, err := os.Open(filepath.Join(out, name+".csv"))
+			if err != nil {
+				t.Fatalf("workers %d: missing table %s: %v", workers, name, err)
+			}
+			records, err := csv.NewReader(fh).ReadAll()
+			fh.Close()
+			if err != nil {
+				t.Fatalf("workers %d: table %s unreadable: %v", workers, name, err)
+			}
+			// Skip the header line
+			if len(records)-1 != rows {
+				t.Errorf("workers %d: table %s row count mismatch: have %d, want %d", workers, name, len(records)-1, rows)
+			}
+			tables[name] = records
+		}
+		if serial == nil {
+			serial = tables
+			continue
+		}
+		for name, records := range tables {
+			for i := range records {
+				if i >= len(serial[name]) || len(records[i]) != len(serial[name][i]) || records[i][0] != serial[name][i][0] || records[i][1] != serial[name][i][1] {
+					t.Fatalf("workers %d: table %s row %d differs from serial export", workers, name, i)
+				}
+			}
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/VictoriaMetrics/fastcache"
 	"github.com/tomochain/tomochain/accounts"
 	"github.com/tomochain/tomochain/accounts/keystore"
 	"github.com/tomochain/tomochain/common"
@@ -48,6 +49,7 @@ import (
 	"github.com/tomochain/tomochain/p2p"
 	"github.com/tomochain/tomochain/p2p/discover"
 	"github.com/tomochain/tomochain/p2p/discv5"
+	"github.com/tomochain/tomochain/p2p/dnsdisc"
 	"github.com/tomochain/tomochain/p2p/nat"
 	"github.com/tomochain/tomochain/p2p/netutil"
 	"github.com/tomochain/tomochain/params"
@@ -190,6 +192,26 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	GCRetainFlag = cli.Uint64Flag{
+		Name:  "gcmode.retain",
+		Usage: "Keep the full state of every Nth block in full mode, e.g. the epoch length (0 = disabled)",
+	}
+	StateVerifyFlag = cli.DurationFlag{
+		Name:  "gcmode.verify",
+		Usage: "Interval between background integrity checks of a sample of recent state (0 = disabled)",
+	}
+	HistoryExpiryFlag = cli.Uint64Flag{
+		Name:  "history.expiry",
+		Usage: "Delete the block bodies and receipts below this block number, keeping the headers (0 = keep all)",
+	}
+	CrossValidateFlag = cli.BoolFlag{
+		Name:  "posv.crossvalidate",
+		Usage: "Check the masternodes of every checkpoint against the validator contract state, rejecting the ones disagreeing",
+	}
+	NoPreimagesFlag = cli.BoolFlag{
+		Name:  "nopreimages",
+		Usage: "Disable recording the SHA3 preimages of trie keys (breaks preimage lookups and exports)",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -289,6 +311,16 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolGapPolicyFlag = cli.StringFlag{
+		Name:  "txpool.gappolicy",
+		Usage: "Handling of the normal transactions during the gap blocks before a checkpoint (include, delay, reject)",
+		Value: string(eth.DefaultConfig.TxPool.GapPolicy),
+	}
+	TxPoolSpecialGapPolicyFlag = cli.StringFlag{
+		Name:  "txpool.specialgappolicy",
+		Usage: "Handling of the sign and randomize transactions during the gap blocks before a checkpoint (include, delay, reject)",
+		Value: string(eth.DefaultConfig.TxPool.SpecialGapPolicy),
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -298,7 +330,12 @@ var (
 	CacheDatabaseFlag = cli.IntFlag{
 		Name:  "cache.database",
 		Usage: "Percentage of cache memory allowance to use for database io",
-		Value: 75,
+		Value: 50,
+	}
+	CacheTrieFlag = cli.IntFlag{
+		Name:  "cache.trie",
+		Usage: "Percentage of cache memory allowance to use for the clean trie node cache (shared by state and TomoX tries)",
+		Value: 25,
 	}
 	CacheGCFlag = cli.IntFlag{
 		Name:  "cache.gc",
@@ -334,6 +371,29 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	HealthIntervalFlag = cli.DurationFlag{
+		Name:  "health.interval",
+		Usage: "Interval between health checks of the masternode (0 = disabled)",
+	}
+	HealthMinPeersFlag = cli.IntFlag{
+		Name:  "health.minpeers",
+		Usage: "Fewest connected peers of a healthy masternode",
+		Value: eth.DefaultHealthConfig.MinPeers,
+	}
+	HealthMaxDriftFlag = cli.DurationFlag{
+		Name:  "health.maxdrift",
+		Usage: "Largest drift of the system clock against NTP of a healthy masternode (0 = unchecked)",
+		Value: eth.DefaultHealthConfig.MaxClockDrift,
+	}
+	HealthMaxDBLatencyFlag = cli.DurationFlag{
+		Name:  "health.maxdblatency",
+		Usage: "Slowest database read of a healthy masternode (0 = unchecked)",
+		Value: eth.DefaultHealthConfig.MaxDBLatency,
+	}
+	HealthStopSealingFlag = cli.BoolFlag{
+		Name:  "health.stopsealing",
+		Usage: "Hold block sealing while the health checks fail, instead of only warning",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -377,6 +437,32 @@ var (
 		Usage: "HTTP-RPC server listening interface",
 		Value: node.DefaultHTTPHost,
 	}
+	ExportFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: `Export format ("rlp" or "csv")`,
+		Value: "rlp",
+	}
+	ExportWorkersFlag = cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of block ranges converted in parallel by columnar exports",
+		Value: runtime.NumCPU(),
+	}
+	OverrideTomoXFlag = cli.Uint64Flag{
+		Name:  "override.tomox",
+		Usage: "Manually specify the TomoX fork block, overriding the bundled setting",
+	}
+	OverrideTomoXLendingFlag = cli.Uint64Flag{
+		Name:  "override.tomoxlending",
+		Usage: "Manually specify the TomoX lending fork block, overriding the bundled setting",
+	}
+	OverrideBerlinFlag = cli.Uint64Flag{
+		Name:  "override.berlin",
+		Usage: "Manually specify the Berlin fork block, overriding the bundled setting",
+	}
+	OverrideLondonFlag = cli.Uint64Flag{
+		Name:  "override.london",
+		Usage: "Manually specify the London fork block, overriding the bundled setting",
+	}
 	RewoundFlag = cli.IntFlag{
 		Name:  "rewound",
 		Usage: "Rewound blocks",
@@ -397,11 +483,23 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.HTTPVirtualHosts, ","),
 	}
+	RPCDeprecationMetricsFlag = cli.BoolFlag{
+		Name:  "rpcdeprecationmetrics",
+		Usage: "Count calls to deprecated RPC methods and fields (requires --metrics)",
+	}
 	RPCApiFlag = cli.StringFlag{
 		Name:  "rpcapi",
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCStateVerifyFlag = cli.Float64Flag{
+		Name:  "rpc.verifystate",
+		Usage: "Fraction (0-1) of RPC state reads verified against Merkle proofs of the state root",
+	}
+	RPCCallCacheFlag = cli.IntFlag{
+		Name:  "rpc.callcache",
+		Usage: "Number of eth_call results cached by block hash (0 = disabled)",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -454,6 +552,16 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: 0,
 	}
+	MaxPeersPerIPFlag = cli.IntFlag{
+		Name:  "maxpeersperip",
+		Usage: "Maximum number of inbound connections from a single IP address (unlimited if set to 0)",
+		Value: 0,
+	}
+	MaxPeersPerSubnetFlag = cli.IntFlag{
+		Name:  "maxpeerspersubnet",
+		Usage: "Maximum number of inbound connections from a single /24 subnet (unlimited if set to 0)",
+		Value: 0,
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -484,7 +592,7 @@ var (
 	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
-		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
+		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>|stun[:<host:port>])",
 		Value: "any",
 	}
 	NoDiscoverFlag = cli.BoolFlag{
@@ -499,6 +607,14 @@ var (
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	DiscoveryDNSFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of the DNS node lists to dial the nodes of",
+	}
+	NetFilterFlag = cli.StringFlag{
+		Name:  "netfilter",
+		Usage: "File of \"allow <cidr>\" and \"deny <cidr>\" lines filtering the inbound connections, reloaded when modified",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
@@ -556,6 +672,45 @@ var (
 		Name:  "tomox.dbReplicaSetName",
 		Usage: "ReplicaSetName if Master-Slave is setup",
 	}
+	TomoXSinkPostgresFlag = cli.StringFlag{
+		Name:  "tomox.sink.postgres",
+		Usage: "PostgreSQL connection string of a database the SDK node also reports its orders and trades to",
+	}
+	TomoXSinkKafkaFlag = cli.StringFlag{
+		Name:  "tomox.sink.kafka",
+		Usage: "URL of a Kafka REST proxy the SDK node also reports its orders and trades to",
+	}
+	TomoXSinkKafkaTopicFlag = cli.StringFlag{
+		Name:  "tomox.sink.kafkatopic",
+		Usage: "Prefix of the Kafka topics of the orders, trades and lending records",
+		Value: tomox.DefaultSinkKafkaTopic,
+	}
+	TomoXBookSamplingFlag = cli.BoolFlag{
+		Name:  "tomox.booksampling",
+		Usage: "Exchange order book samples with peers to detect matching divergence",
+	}
+	TomoXGossipRateFlag = cli.IntFlag{
+		Name:  "tomox.gossiprate",
+		Usage: "Maximum number of orders per second accepted from a peer of the tomox protocol",
+		Value: tomox.DefaultOrderGossipRate,
+	}
+	TomoXGossipPoolFlag = cli.IntFlag{
+		Name:  "tomox.gossippool",
+		Usage: "Maximum number of gossiped orders kept to be relayed to the peers",
+		Value: tomox.DefaultOrderGossipPoolSize,
+	}
+	TomoXCandlesFlag = cli.BoolFlag{
+		Name:  "tomox.candles",
+		Usage: "Aggregate the matched trades into candles served by tomox_getCandles",
+	}
+	TomoXAddressIndexFlag = cli.BoolFlag{
+		Name:  "tomox.addressindex",
+		Usage: "Index the orders and lending trades by user address for tomox_getOrdersByAddress and tomoxlending_getTradesByAddress",
+	}
+	TomoXVerifyFlag = cli.BoolFlag{
+		Name:  "tomox.verify",
+		Usage: "Reject the imported blocks whose trading, lending and finalized trade transactions disagree with the matching of the node",
+	}
 	TomoSlaveModeFlag = cli.BoolFlag{
 		Name:  "slave",
 		Usage: "Enable slave mode",
@@ -869,6 +1024,12 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(MaxPeersPerIPFlag.Name) {
+		cfg.MaxPeersPerIP = ctx.GlobalInt(MaxPeersPerIPFlag.Name)
+	}
+	if ctx.GlobalIsSet(MaxPeersPerSubnetFlag.Name) {
+		cfg.MaxPeersPerSubnet = ctx.GlobalInt(MaxPeersPerSubnetFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -890,6 +1051,18 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NetRestrict = list
 	}
+	if urls := ctx.GlobalString(DiscoveryDNSFlag.Name); urls != "" {
+		for _, url := range strings.Split(urls, ",") {
+			url = strings.TrimSpace(url)
+			if err := dnsdisc.ParseURL(url); err != nil {
+				Fatalf("Option %q: %v", DiscoveryDNSFlag.Name, err)
+			}
+			cfg.DiscoveryDNS = append(cfg.DiscoveryDNS, url)
+		}
+	}
+	if ctx.GlobalIsSet(NetFilterFlag.Name) {
+		cfg.NetFilterFile = ctx.GlobalString(NetFilterFlag.Name)
+	}
 
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
@@ -931,6 +1104,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(AnnounceTxsFlag.Name) {
 		cfg.AnnounceTxs = ctx.GlobalBool(AnnounceTxsFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCDeprecationMetricsFlag.Name) {
+		cfg.RPCDeprecationMetrics = ctx.GlobalBool(RPCDeprecationMetricsFlag.Name)
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
@@ -973,6 +1149,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolGapPolicyFlag.Name) {
+		cfg.GapPolicy = core.GapPolicy(ctx.GlobalString(TxPoolGapPolicyFlag.Name))
+	}
+	if ctx.GlobalIsSet(TxPoolSpecialGapPolicyFlag.Name) {
+		cfg.SpecialGapPolicy = core.GapPolicy(ctx.GlobalString(TxPoolSpecialGapPolicyFlag.Name))
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -1078,6 +1260,51 @@ func SetTomoXConfig(ctx *cli.Context, cfg *tomox.Config, tomoDataDir string) {
 	if ctx.GlobalIsSet(TomoXDBReplicaSetNameFlag.Name) {
 		cfg.ReplicaSetName = ctx.GlobalString(TomoXDBReplicaSetNameFlag.Name)
 	}
+	if ctx.GlobalIsSet(TomoXSinkPostgresFlag.Name) {
+		cfg.SinkPostgresURL = ctx.GlobalString(TomoXSinkPostgresFlag.Name)
+	}
+	if ctx.GlobalIsSet(TomoXSinkKafkaFlag.Name) {
+		cfg.SinkKafkaURL = ctx.GlobalString(TomoXSinkKafkaFlag.Name)
+	}
+	if ctx.GlobalIsSet(TomoXSinkKafkaTopicFlag.Name) {
+		cfg.SinkKafkaTopic = ctx.GlobalString(TomoXSinkKafkaTopicFlag.Name)
+	} else if cfg.SinkKafkaTopic == "" {
+		cfg.SinkKafkaTopic = TomoXSinkKafkaTopicFlag.Value
+	}
+	if ctx.GlobalIsSet(TomoXGossipRateFlag.Name) {
+		cfg.OrderGossipRate = ctx.GlobalInt(TomoXGossipRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TomoXGossipPoolFlag.Name) {
+		cfg.OrderGossipPoolSize = ctx.GlobalInt(TomoXGossipPoolFlag.Name)
+	}
+	if ctx.GlobalIsSet(TomoXCandlesFlag.Name) {
+		cfg.Candles = ctx.GlobalBool(TomoXCandlesFlag.Name)
+	}
+	if ctx.GlobalIsSet(TomoXAddressIndexFlag.Name) {
+		cfg.AddressIndex = ctx.GlobalBool(TomoXAddressIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(TomoXVerifyFlag.Name) {
+		cfg.Verify = ctx.GlobalBool(TomoXVerifyFlag.Name)
+	}
+}
+
+// setHealthConfig applies the masternode health check flags to the config.
+func setHealthConfig(ctx *cli.Context, cfg *eth.HealthConfig) {
+	if ctx.GlobalIsSet(HealthIntervalFlag.Name) {
+		cfg.Interval = ctx.GlobalDuration(HealthIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMinPeersFlag.Name) {
+		cfg.MinPeers = ctx.GlobalInt(HealthMinPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxDriftFlag.Name) {
+		cfg.MaxClockDrift = ctx.GlobalDuration(HealthMaxDriftFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxDBLatencyFlag.Name) {
+		cfg.MaxDBLatency = ctx.GlobalDuration(HealthMaxDBLatencyFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthStopSealingFlag.Name) {
+		cfg.StopSealing = ctx.GlobalBool(HealthStopSealingFlag.Name)
+	}
 }
 
 // SetEthConfig applies eth-related command line flags to the config.
@@ -1111,6 +1338,18 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
+	if ctx.GlobalIsSet(OverrideTomoXFlag.Name) {
+		cfg.Overrides.TIPTomoX = new(big.Int).SetUint64(ctx.GlobalUint64(OverrideTomoXFlag.Name))
+	}
+	if ctx.GlobalIsSet(OverrideTomoXLendingFlag.Name) {
+		cfg.Overrides.TIPTomoXLending = new(big.Int).SetUint64(ctx.GlobalUint64(OverrideTomoXLendingFlag.Name))
+	}
+	if ctx.GlobalIsSet(OverrideBerlinFlag.Name) {
+		cfg.Overrides.Berlin = new(big.Int).SetUint64(ctx.GlobalUint64(OverrideBerlinFlag.Name))
+	}
+	if ctx.GlobalIsSet(OverrideLondonFlag.Name) {
+		cfg.Overrides.London = new(big.Int).SetUint64(ctx.GlobalUint64(OverrideLondonFlag.Name))
+	}
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheDatabaseFlag.Name) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
@@ -1121,13 +1360,47 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	cfg.NoPreimages = ctx.GlobalBool(NoPreimagesFlag.Name)
+	if ctx.GlobalIsSet(GCRetainFlag.Name) {
+		if cfg.NoPruning {
+			log.Warn("State retention is ignored in archive mode", "flag", GCRetainFlag.Name)
+		}
+		cfg.RetainState = ctx.GlobalUint64(GCRetainFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateVerifyFlag.Name) {
+		cfg.StateVerifyInterval = ctx.GlobalDuration(StateVerifyFlag.Name)
+	}
+	if ctx.GlobalIsSet(HistoryExpiryFlag.Name) {
+		cfg.HistoryExpiry = ctx.GlobalUint64(HistoryExpiryFlag.Name)
+	}
+	if ctx.GlobalIsSet(CrossValidateFlag.Name) {
+		cfg.CheckpointCrossValidation = ctx.GlobalBool(CrossValidateFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(RPCStateVerifyFlag.Name) {
+		ratio := ctx.GlobalFloat64(RPCStateVerifyFlag.Name)
+		if ratio < 0 || ratio > 1 {
+			Fatalf("--%s must be between 0 and 1", RPCStateVerifyFlag.Name)
+		}
+		cfg.RPCStateVerifyRatio = ratio
+	}
+	if ctx.GlobalIsSet(RPCCallCacheFlag.Name) {
+		cfg.RPCCallCacheSize = ctx.GlobalInt(RPCCallCacheFlag.Name)
+	}
+	if ctx.GlobalIsSet(TomoXBookSamplingFlag.Name) {
+		cfg.OrderBookSampling = ctx.GlobalBool(TomoXBookSamplingFlag.Name)
+	}
 
+	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
+		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
 	if ctx.GlobalIsSet(StakerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(StakerThreadsFlag.Name)
 	}
+	setHealthConfig(ctx, &cfg.Health)
 	if ctx.GlobalIsSet(DocRootFlag.Name) {
 		cfg.DocRoot = ctx.GlobalString(DocRootFlag.Name)
 	}
@@ -1255,10 +1528,22 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		NoPreimages:   ctx.GlobalBool(NoPreimagesFlag.Name),
+		RetainBlocks:  ctx.GlobalUint64(GCRetainFlag.Name),
+
+		StateVerifyInterval: ctx.GlobalDuration(StateVerifyFlag.Name),
+		HistoryExpiry:       ctx.GlobalUint64(HistoryExpiryFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	cleans := eth.DefaultConfig.TrieCleanCache
+	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
+		cleans = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
+	}
+	if cleans > 0 {
+		cache.TrieCleans = fastcache.New(cleans * 1024 * 1024)
+	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
 	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg)
 	if err != nil {
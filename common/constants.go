@@ -27,6 +27,21 @@ const (
 	OneYear                    = uint64(31536000)
 	BlocksPerYear              = uint64(15768000)
 	LiquidateLendingTradeBlock = uint64(100)
+
+	// MaxOrderCancels is the most orders a batch cancellation may cancel
+	MaxOrderCancels = 100
+
+	// MaxTriggeredOrders is the most stop orders activated per block, the
+	// others being activated in the next blocks
+	MaxTriggeredOrders = 100
+
+	// LendingAuctionBlocks is the number of blocks over which the price of the
+	// collateral of a liquidated lending trade descends to its floor
+	LendingAuctionBlocks = uint64(450)
+
+	// OraclePriceMaxAge is the number of blocks after which a price reported by
+	// a feeder of the lending oracle is stale
+	OraclePriceMaxAge = uint64(900)
 )
 
 var Rewound = uint64(0)
@@ -41,6 +56,10 @@ var (
 	TIPTomoXLendingBlock         = big.NewInt(21430200)
 	TIPTomoXCancellationFeeBlock = big.NewInt(30915660)
 
+	// TIPExtraV1Block switches the header extra-data to the tagged RLP layout
+	// (nil = not scheduled).
+	TIPExtraV1Block *big.Int
+
 	IsTestnet         bool = false
 	StoreRewardFolder string
 	RollbackHash      Hash
@@ -65,6 +84,11 @@ var (
 	RateTopUp           = big.NewInt(90) // 90%
 	BaseTopUp           = big.NewInt(100)
 	BaseRecall          = big.NewInt(100)
+	AuctionStartRate    = big.NewInt(105) // 105% of the collateral value
+	AuctionFloorRate    = big.NewInt(80)  // 80% of the collateral value
+	BaseAuction         = big.NewInt(100)
+	OracleMaxDeviation  = big.NewInt(10) // 10% from the median price
+	BaseOracle          = big.NewInt(100)
 
 	RelayerRegistrationSMC = "0x16c63b79f9C8784168103C0b74E6A59EC2de4a02"
 	LendingRegistrationSMC = "0x7d761afd7ff65a79e4173897594a194e3c506e57"
@@ -39,6 +39,7 @@ const (
 	TradingStateAddr                  = "0x0000000000000000000000000000000000000092"
 	TomoXLendingAddress               = "0x0000000000000000000000000000000000000093"
 	TomoXLendingFinalizedTradeAddress = "0x0000000000000000000000000000000000000094"
+	SlashingEvidenceAddr              = "0x0000000000000000000000000000000000000095"
 	TomoNativeAddress                 = "0x0000000000000000000000000000000000000001"
 	LendingLockAddress                = "0x0000000000000000000000000000000000000011"
 	VoteMethod                        = "0x6dd7d8ea"
@@ -46,6 +47,7 @@ const (
 	ProposeMethod                     = "0x01267951"
 	ResignMethod                      = "0xae6e43f5"
 	SignMethod                        = "0xe341eaa4"
+	RotateSignerMethod                = "0xe83a462e"
 	TomoXApplyMethod                  = "0xc6b32f34"
 	TomoZApplyMethod                  = "0xc6b32f34"
 )
@@ -30,6 +30,7 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/hexutil"
 	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/exchange"
 	"github.com/tomochain/tomochain/consensus/misc"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
@@ -206,6 +207,8 @@ type Clique struct {
 	signer common.Address // Ethereum address of the signing key
 	signFn SignerFn       // Signer function to authorize hashes with
 	lock   sync.RWMutex   // Protects the signer fields
+
+	exchange.Services // TomoX services of private networks processing the trading and lending transactions
 }
 
 // New creates a Clique proof-of-authority consensus engine with the initial
@@ -105,6 +105,16 @@ type PoW interface {
 	Hashrate() float64
 }
 
+// Finality is a consensus engine finalizing blocks, which a chain reorganisation
+// must never drop.
+type Finality interface {
+	Engine
+
+	// Finalized returns the hash and number of the highest finalized block, the
+	// zero hash if no block was finalized yet.
+	Finalized() (common.Hash, uint64)
+}
+
 // ChainContext supports retrieving headers and consensus parameters from the
 // current blockchain to be used during transaction processing.
 type ChainContext interface {
@@ -0,0 +1,66 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dev
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestSealInstant(t *testing.T) {
+	engine := New(&params.DevConfig{})
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(time.Now().Unix())}
+
+	// Blocks with the TomoX state roots only wait for transactions
+	stateRoot := types.NewTransaction(0, common.HexToAddress(common.TradingStateAddr), new(big.Int), 0, new(big.Int), nil)
+	if _, err := engine.Seal(nil, types.NewBlock(header, []*types.Transaction{stateRoot}, nil, nil), nil); err != errWaitTransactions {
+		t.Fatalf("empty block sealing error mismatch: have %v, want %v", err, errWaitTransactions)
+	}
+	transfer := types.NewTransaction(0, common.Address{1}, big.NewInt(1), 21000, new(big.Int), nil)
+	block := types.NewBlock(header, []*types.Transaction{stateRoot, transfer}, nil, nil)
+	sealed, err := engine.Seal(nil, block, nil)
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if sealed.Hash() != block.Hash() {
+		t.Errorf("sealed block mismatch: have %x, want %x", sealed.Hash(), block.Hash())
+	}
+	// Genesis can't be sealed
+	if _, err := engine.Seal(nil, types.NewBlock(&types.Header{Number: new(big.Int), Time: new(big.Int)}, nil, nil, nil), nil); err != errUnknownBlock {
+		t.Errorf("genesis sealing error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+}
+
+func TestSealPeriod(t *testing.T) {
+	engine := New(&params.DevConfig{Period: 2})
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(time.Now().Unix() + 60)}
+
+	// Periodic chains seal empty blocks, once their time comes
+	stop := make(chan struct{})
+	close(stop)
+	if block, err := engine.Seal(nil, types.NewBlock(header, nil, nil, nil), stop); block != nil || err != nil {
+		t.Errorf("stopped sealing mismatch: have %v, %v, want nil, nil", block, err)
+	}
+	header.Time = big.NewInt(time.Now().Unix())
+	if block, err := engine.Seal(nil, types.NewBlock(header, nil, nil, nil), nil); block == nil || err != nil {
+		t.Errorf("failed to seal empty block: %v", err)
+	}
+}
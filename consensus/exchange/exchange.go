@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package exchange hands the TomoX trading and lending services to the consensus
+// engines whose blocks carry the matching transactions.
+package exchange
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
+)
+
+// TradingService is the TomoX service matching the orders of the trading pairs.
+type TradingService interface {
+	GetTradingStateRoot(block *types.Block, author common.Address) (common.Hash, error)
+	GetTradingState(block *types.Block, author common.Address) (*tradingstate.TradingStateDB, error)
+	HasTradingState(block *types.Block, author common.Address) bool
+	GetStateCache() tradingstate.Database
+	GetTriegc() *prque.Prque
+	ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tomoXstatedb *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error)
+	UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB) error
+	CancelDelistedOrders(header *types.Header, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB) error
+	PurgeExpiredOrders(header *types.Header, tradingStateDB *tradingstate.TradingStateDB) error
+	ProcessTriggeredOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB) error
+	IsSDKNode() bool
+	VerifyEnabled() bool
+	SyncDataToSDKNode(takerOrder *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, trades []map[string]string, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) error
+	RollbackReorgTxMatch(txhash common.Hash) error
+	CandlesEnabled() bool
+	AggregateCandles(block *types.Block, trades []map[string]string) error
+	RollbackCandles(block *types.Block) error
+	AddressIndexEnabled() bool
+	IndexOrders(block *types.Block, batches []tradingstate.TxMatchBatch) error
+	RollbackOrderIndex(block *types.Block) error
+	GetTokenDecimal(chain consensus.ChainContext, statedb *state.StateDB, tokenAddr common.Address) (*big.Int, error)
+}
+
+// LendingService is the TomoX service matching the orders of the lending books.
+type LendingService interface {
+	GetLendingStateRoot(block *types.Block, author common.Address) (common.Hash, error)
+	GetLendingState(block *types.Block, author common.Address) (*lendingstate.LendingStateDB, error)
+	HasLendingState(block *types.Block, author common.Address) bool
+	GetStateCache() lendingstate.Database
+	GetTriegc() *prque.Prque
+	ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error)
+	GetCollateralPrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, *big.Int, error)
+	GetMediumTradePriceBeforeEpoch(chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, baseToken common.Address, quoteToken common.Address) (*big.Int, error)
+	ProcessLiquidationData(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (updatedTrades map[common.Hash]*lendingstate.LendingTrade, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades []*lendingstate.LendingTrade, err error)
+	SyncDataToSDKNode(chain consensus.ChainContext, state *state.StateDB, block *types.Block, takerOrderInTx *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedOrders []*lendingstate.LendingItem, dirtyOrderCount *uint64) error
+	UpdateLiquidatedTrade(blockTime uint64, result lendingstate.FinalizedResult, trades map[common.Hash]*lendingstate.LendingTrade) error
+	RollbackLendingData(txhash common.Hash) error
+	IndexLendingTrades(block *types.Block, trades []*lendingstate.LendingTrade) error
+	RollbackTradeIndex(block *types.Block) error
+}
+
+// Engine is a consensus engine processing the TomoX transactions of its blocks.
+type Engine interface {
+	consensus.Engine
+
+	// GetTomoXService returns the trading service, nil if not set.
+	GetTomoXService() TradingService
+
+	// GetLendingService returns the lending service, nil if not set.
+	GetLendingService() LendingService
+
+	// SetServices sets the lookups of the services, which are created after the
+	// engine.
+	SetServices(trading func() TradingService, lending func() LendingService)
+}
+
+// Services carries the TomoX services of a consensus engine, implementing the
+// service methods of Engine when embedded.
+type Services struct {
+	trading func() TradingService
+	lending func() LendingService
+}
+
+// SetServices sets the lookups of the trading and lending services.
+func (s *Services) SetServices(trading func() TradingService, lending func() LendingService) {
+	s.trading, s.lending = trading, lending
+}
+
+// GetTomoXService returns the trading service, nil if not set.
+func (s *Services) GetTomoXService() TradingService {
+	if s.trading == nil {
+		return nil
+	}
+	return s.trading()
+}
+
+// GetLendingService returns the lending service, nil if not set.
+func (s *Services) GetLendingService() LendingService {
+	if s.lending == nil {
+		return nil
+	}
+	return s.lending()
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+)
+
+// londonConfig returns a chain config with London enabled at block 5.
+func londonConfig() *params.ChainConfig {
+	config := *params.TestChainConfig
+	config.LondonBlock = big.NewInt(5)
+	return &config
+}
+
+// TestVerifyEip1559Header tests the base fee checks for blocks both across
+// the EIP-1559 boundary and post-1559 blocks.
+func TestVerifyEip1559Header(t *testing.T) {
+	initial := new(big.Int).SetUint64(params.InitialBaseFee)
+
+	for i, tc := range []struct {
+		pNum    int64
+		baseFee *big.Int
+		gasUsed uint64
+		ok      bool
+	}{
+		{4, initial, 10000000, true},           // First London block takes the initial fee
+		{4, big.NewInt(1), 10000000, false},    // First London block with a wrong fee
+		{5, initial, 10000000, true},           // Parent at target keeps the fee
+		{5, nil, 10000000, false},              // Missing base fee
+		{5, initial, 20000001, false},          // Gas used above the limit
+		{5, big.NewInt(1000), 10000000, false}, // Wrong base fee
+	} {
+		parent := &types.Header{
+			GasUsed:  10000000,
+			GasLimit: 20000000,
+			BaseFee:  initial,
+			Number:   big.NewInt(tc.pNum),
+		}
+		header := &types.Header{
+			GasUsed:  tc.gasUsed,
+			GasLimit: 20000000,
+			BaseFee:  tc.baseFee,
+			Number:   big.NewInt(tc.pNum + 1),
+		}
+		err := VerifyEip1559Header(londonConfig(), parent, header)
+		if tc.ok && err != nil {
+			t.Errorf("test %d: Expected valid header: %s", i, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("test %d: Expected invalid header", i)
+		}
+	}
+}
+
+// TestCalcBaseFee assumes all blocks are 1559-blocks
+func TestCalcBaseFee(t *testing.T) {
+	tests := []struct {
+		parentBaseFee   int64
+		parentGasLimit  uint64
+		parentGasUsed   uint64
+		expectedBaseFee int64
+	}{
+		{1000000000, 20000000, 10000000, 1000000000}, // usage == target
+		{1000000000, 20000000, 9000000, 987500000},   // usage below target
+		{1000000000, 20000000, 11000000, 1012500000}, // usage above target
+		{1000000000, 20000000, 0, 875000000},         // empty parent block
+		{260000000, 20000000, 0, 250000000},          // never drops below the initial fee
+	}
+	for i, test := range tests {
+		parent := &types.Header{
+			Number:   big.NewInt(32),
+			GasLimit: test.parentGasLimit,
+			GasUsed:  test.parentGasUsed,
+			BaseFee:  big.NewInt(test.parentBaseFee),
+		}
+		if have, want := CalcBaseFee(londonConfig(), parent), big.NewInt(test.expectedBaseFee); have.Cmp(want) != 0 {
+			t.Errorf("test %d: have %d  want %d, ", i, have, want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/contracts/tomox/contract"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+// ApplyTomoXMakerRebateFork upgrades the deployed relayer registration contract
+// to the code that lets relayer owners set a maker rebate. The storage is kept,
+// the rebates live in a mapping slot the previous code never wrote to.
+func ApplyTomoXMakerRebateFork(statedb *state.StateDB) {
+	addr := common.HexToAddress(common.RelayerRegistrationSMC)
+	if statedb.GetCodeSize(addr) == 0 {
+		return
+	}
+	statedb.SetCode(addr, contract.RelayerRegistrationRuntime)
+}
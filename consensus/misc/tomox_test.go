@@ -0,0 +1,51 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/contracts/tomox/contract"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+// Tests that the maker rebate fork replaces the code of a deployed relayer
+// registration contract but keeps its storage, and leaves chains without one alone.
+func TestTomoXMakerRebateFork(t *testing.T) {
+	addr := common.HexToAddress(common.RelayerRegistrationSMC)
+	key, val := common.HexToHash("0x07"), common.HexToHash("0x2a")
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.SetCode(addr, []byte{0x60, 0x00, 0x80, 0xfd})
+	statedb.SetState(addr, key, val)
+	ApplyTomoXMakerRebateFork(statedb)
+
+	if !bytes.Equal(statedb.GetCode(addr), contract.RelayerRegistrationRuntime) {
+		t.Errorf("relayer registration code not upgraded")
+	}
+	if have := statedb.GetState(addr, key); have != val {
+		t.Errorf("relayer registration storage mismatch: have %x, want %x", have, val)
+	}
+
+	statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	ApplyTomoXMakerRebateFork(statedb)
+	if statedb.Exist(addr) {
+		t.Errorf("relayer registration created on a chain without one")
+	}
+}
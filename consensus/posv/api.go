@@ -16,6 +16,8 @@
 package posv
 
 import (
+	"context"
+	"errors"
 	"math/big"
 
 	"github.com/tomochain/tomochain/common"
@@ -97,6 +99,97 @@ func (api *API) GetSignersAtHash(hash common.Hash) ([]common.Address, error) {
 	return snap.GetSigners(), nil
 }
 
+// GetMasternodePerformance retrieves the performance of the masternodes over a
+// window of blocks, an epoch by default, ending at the specified block.
+func (api *API) GetMasternodePerformance(number *rpc.BlockNumber, window *uint64) (*PerformanceReport, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	blocks := uint64(0)
+	if window != nil {
+		blocks = *window
+	}
+	return api.posv.MasternodePerformance(api.chain, header, blocks)
+}
+
+// GetSigningReport retrieves the creator, validator and signers of the blocks
+// from the block from to the block to, with the signing slots each masternode
+// was expected to sign, signed and missed.
+func (api *API) GetSigningReport(from, to rpc.BlockNumber) (*SigningReport, error) {
+	number := func(n rpc.BlockNumber) uint64 {
+		if n == rpc.LatestBlockNumber || n == rpc.PendingBlockNumber {
+			return api.chain.CurrentHeader().Number.Uint64()
+		}
+		return uint64(n.Int64())
+	}
+	return api.posv.SigningReport(api.chain, number(from), number(to))
+}
+
+// GetCheckpointProof retrieves the proof of the masternodes of an epoch, for a
+// client trusting the masternodes of the previous epoch.
+func (api *API) GetCheckpointProof(epoch uint64) (*CheckpointProof, error) {
+	if epoch == 0 {
+		return nil, errors.New("genesis masternodes have no proof")
+	}
+	header := api.chain.GetHeaderByNumber(api.posv.config.EpochCheckpoint(epoch))
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return NewCheckpointProof(api.chain.Config(), header)
+}
+
+// GetStakeSnapshot retrieves the capacity of the candidates and the delegations
+// of their voters the masternodes of an epoch were elected from.
+func (api *API) GetStakeSnapshot(epoch uint64) (*StakeSnapshot, error) {
+	return api.posv.StakeSnapshot(api.chain, epoch)
+}
+
+// GetPenaltyDetails retrieves why the masternodes penalized at the checkpoint
+// of an epoch were penalized.
+func (api *API) GetPenaltyDetails(epoch uint64) (*PenaltyDetails, error) {
+	return api.posv.PenaltyDetails(api.chain, epoch)
+}
+
+// Masternodes creates a subscription notified each time a canonical checkpoint
+// block changes the set of masternodes, with the masternodes which joined, left
+// or were penalized.
+func (api *API) Masternodes(ctx context.Context) (*rpc.Subscription, error) {
+	feed, ok := api.chain.(MasternodesFeed)
+	if !ok {
+		return nil, errors.New("masternodes events not supported by the chain")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		masternodes := make(chan MasternodesEvent, 4)
+		sub := feed.SubscribeMasternodesEvent(masternodes)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-masternodes:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 func (api *API) NetworkInformation() NetworkInformation {
 	api.posv.lock.RLock()
 	defer api.posv.lock.RUnlock()
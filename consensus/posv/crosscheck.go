@@ -0,0 +1,115 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+)
+
+// contractMasternodesKey returns the database key of the masternodes derived
+// from the validator contract state of the gap block of the given hash.
+func contractMasternodesKey(hash common.Hash) []byte {
+	return append([]byte("posv-contract-masternodes-"), hash[:]...)
+}
+
+// contractMasternodes derives the masternodes of the next epoch from the state
+// of the validator contract: the candidates with the highest capacities.
+func contractMasternodes(statedb *state.StateDB) []common.Address {
+	var (
+		candidates []Masternode
+		seen       = make(map[common.Address]bool)
+	)
+	for _, candidate := range state.GetCandidates(statedb) {
+		// Resigned candidates are left as zero addresses in the contract
+		if candidate == (common.Address{}) || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		candidates = append(candidates, Masternode{Address: candidate, Stake: state.GetCandidateCap(statedb, candidate)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return stakeLess(candidates[i].Stake, candidates[j].Stake, candidates[i].Address, candidates[j].Address)
+	})
+	if len(candidates) > common.MaxMasternodes {
+		candidates = candidates[:common.MaxMasternodes]
+	}
+	masternodes := make([]common.Address, 0, len(candidates))
+	for _, candidate := range candidates {
+		masternodes = append(masternodes, candidate.Address)
+	}
+	return masternodes
+}
+
+// CrossValidate checks the masternodes of a checkpoint block against the ones
+// derived from the validator contract, to catch the snapshots going astray. The
+// state of the block must be the one it was processed into.
+//
+// The masternodes are derived at the gap block and recorded in the database
+// until the checkpoint ending its epoch, the state of the gap being pruned soon
+// after. Checkpoints whose gap was not processed by this node are not checked.
+func (c *Posv) CrossValidate(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB) error {
+	if !c.CrossValidation {
+		return nil
+	}
+	number := header.Number.Uint64()
+	if c.config.IsGap(number) {
+		blob, err := json.Marshal(contractMasternodes(statedb))
+		if err == nil {
+			err = c.db.Put(contractMasternodesKey(header.Hash()), blob)
+		}
+		if err != nil {
+			log.Warn("Failed to record the contract masternodes", "number", number, "err", err)
+		}
+		return nil
+	}
+	if number == 0 || !c.config.IsCheckpoint(number) {
+		return nil
+	}
+	gap := header
+	for step := uint64(1); gap != nil && step <= c.config.GapLength(number-1); step++ {
+		gap = chain.GetHeader(gap.ParentHash, number-step)
+	}
+	if gap == nil {
+		return nil
+	}
+	blob, err := c.db.Get(contractMasternodesKey(gap.Hash()))
+	if err != nil {
+		log.Debug("Skipped the checkpoint cross-validation", "number", number, "gap", gap.Number)
+		return nil
+	}
+	var expected []common.Address
+	if err := json.Unmarshal(blob, &expected); err != nil {
+		return err
+	}
+	snap, err := c.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	checkpointCheckedCounter.Inc(1)
+	if err := c.checkSignersOnCheckpoint(chain, header, snap, append([]common.Address{}, expected...)); err != nil {
+		checkpointMismatchCounter.Inc(1)
+		log.Error("Checkpoint disagrees with the validator contract", "number", number, "hash", header.Hash(), "masternodes", GetMasternodesFromCheckpointHeader(header), "contract", expected, "err", err)
+		return errCheckpointMismatch
+	}
+	return nil
+}
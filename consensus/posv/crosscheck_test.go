@@ -0,0 +1,77 @@
+package posv
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that the checkpoints are checked against the masternodes derived from
+// the validator contract state of their gap.
+func TestCrossValidate(t *testing.T) {
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 10, Gap: 5}}
+	engine := New(config.Posv, rawdb.NewMemoryDatabase())
+	engine.CrossValidation = true
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		first  = common.Address{0x01}
+		second = common.Address{0x02}
+		third  = common.Address{0x03}
+		owner  = common.Address{0x10}
+	)
+	stakes := &stakeState{statedb: statedb}
+	stakes.addCandidate(first, owner, 100)
+	stakes.addCandidate(common.Address{}, owner, 0)
+	stakes.addCandidate(second, owner, 300)
+	stakes.addCandidate(first, owner, 100)
+
+	if have, want := contractMasternodes(statedb), []common.Address{second, first}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("contract masternodes mismatch: have %x, want %x", have, want)
+	}
+	chain := &headerChain{config: config, headers: make(map[common.Hash]*types.Header)}
+	var parent common.Hash
+	for n := int64(0); n < 10; n++ {
+		header := &types.Header{Number: big.NewInt(n), ParentHash: parent}
+		chain.headers[header.Hash()] = header
+		parent = header.Hash()
+
+		if n == 5 {
+			if err := engine.CrossValidate(chain, header, statedb); err != nil {
+				t.Fatalf("failed to record the gap: %v", err)
+			}
+		}
+	}
+	engine.recents.Add(parent, newSnapshot(engine.config, engine.signatures, 9, parent, []common.Address{first, second}))
+
+	checkpoint := func(masternodes ...common.Address) *types.Header {
+		data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+		if err != nil {
+			t.Fatalf("failed to encode extra-data: %v", err)
+		}
+		return &types.Header{Number: big.NewInt(10), ParentHash: parent, Extra: data}
+	}
+	if err := engine.CrossValidate(chain, checkpoint(first, second), nil); err != nil {
+		t.Errorf("agreeing checkpoint: failed to validate: %v", err)
+	}
+	if err := engine.CrossValidate(chain, checkpoint(first, third), nil); err != errCheckpointMismatch {
+		t.Errorf("disagreeing checkpoint: error mismatch: have %v, want %v", err, errCheckpointMismatch)
+	}
+	// Checkpoints whose gap was not recorded are not checked
+	orphan := checkpoint(first, third)
+	orphan.ParentHash = common.Hash{0xff}
+	if err := engine.CrossValidate(chain, orphan, nil); err != nil {
+		t.Errorf("unrecorded gap: failed to validate: %v", err)
+	}
+	engine.CrossValidation = false
+	if err := engine.CrossValidate(chain, checkpoint(first, third), nil); err != nil {
+		t.Errorf("disabled cross-validation: failed to validate: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+)
+
+// MasternodesEvent is posted when a canonical checkpoint block changes the set
+// of masternodes of the previous checkpoint. Left holds all the masternodes no
+// longer in the set, penalized or not, and Penalized the masternodes penalized
+// by the checkpoint.
+type MasternodesEvent struct {
+	Number      uint64           `json:"number"`
+	Hash        common.Hash      `json:"hash"`
+	Masternodes []common.Address `json:"masternodes"`
+	Joined      []common.Address `json:"joined"`
+	Left        []common.Address `json:"left"`
+	Penalized   []common.Address `json:"penalized"`
+}
+
+// MasternodesFeed is a chain posting the changes of the set of masternodes.
+type MasternodesFeed interface {
+	SubscribeMasternodesEvent(ch chan<- MasternodesEvent) event.Subscription
+}
+
+// NewMasternodesEvent compares the masternodes of a checkpoint header with the
+// ones of the previous checkpoint header.
+func NewMasternodesEvent(previous, checkpoint *types.Header) MasternodesEvent {
+	ev := MasternodesEvent{
+		Number:      checkpoint.Number.Uint64(),
+		Hash:        checkpoint.Hash(),
+		Masternodes: GetMasternodesFromCheckpointHeader(checkpoint),
+		Joined:      []common.Address{},
+		Left:        []common.Address{},
+		Penalized:   common.ExtractAddressFromBytes(checkpoint.Penalties),
+	}
+	before := make(map[common.Address]bool)
+	for _, masternode := range GetMasternodesFromCheckpointHeader(previous) {
+		before[masternode] = true
+	}
+	for _, masternode := range ev.Masternodes {
+		if !before[masternode] {
+			ev.Joined = append(ev.Joined, masternode)
+		}
+		delete(before, masternode)
+	}
+	for _, masternode := range GetMasternodesFromCheckpointHeader(previous) {
+		if before[masternode] {
+			ev.Left = append(ev.Left, masternode)
+		}
+	}
+	return ev
+}
+
+// Changed returns whether the checkpoint changed the set of masternodes.
+func (ev MasternodesEvent) Changed() bool {
+	return len(ev.Joined) > 0 || len(ev.Left) > 0 || len(ev.Penalized) > 0
+}
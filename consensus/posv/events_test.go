@@ -0,0 +1,48 @@
+package posv
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+func TestNewMasternodesEvent(t *testing.T) {
+	checkpoint := func(number int64, masternodes []common.Address, penalties []common.Address) *types.Header {
+		data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+		if err != nil {
+			t.Fatalf("failed to encode extra-data: %v", err)
+		}
+		return &types.Header{Number: big.NewInt(number), Extra: data, Penalties: common.ExtractAddressToBytes(penalties)}
+	}
+	previous := checkpoint(900, []common.Address{{0x01}, {0x02}, {0x03}}, nil)
+	header := checkpoint(1800, []common.Address{{0x02}, {0x03}, {0x04}}, []common.Address{{0x01}})
+
+	ev := NewMasternodesEvent(previous, header)
+	if ev.Number != 1800 || ev.Hash != header.Hash() {
+		t.Errorf("checkpoint mismatch: have #%d %x, want #1800 %x", ev.Number, ev.Hash, header.Hash())
+	}
+	if want := []common.Address{{0x02}, {0x03}, {0x04}}; !reflect.DeepEqual(ev.Masternodes, want) {
+		t.Errorf("masternodes mismatch: have %x, want %x", ev.Masternodes, want)
+	}
+	if want := []common.Address{{0x04}}; !reflect.DeepEqual(ev.Joined, want) {
+		t.Errorf("joined masternodes mismatch: have %x, want %x", ev.Joined, want)
+	}
+	if want := []common.Address{{0x01}}; !reflect.DeepEqual(ev.Left, want) {
+		t.Errorf("left masternodes mismatch: have %x, want %x", ev.Left, want)
+	}
+	if want := []common.Address{{0x01}}; !reflect.DeepEqual(ev.Penalized, want) {
+		t.Errorf("penalized masternodes mismatch: have %x, want %x", ev.Penalized, want)
+	}
+	if !ev.Changed() {
+		t.Errorf("changed set of masternodes reported unchanged")
+	}
+	// Reordering the masternodes doesn't change the set
+	reordered := checkpoint(2700, []common.Address{{0x04}, {0x03}, {0x02}}, nil)
+	if ev := NewMasternodesEvent(header, reordered); ev.Changed() {
+		t.Errorf("unchanged set of masternodes reported changed: %+v", ev)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const signedHeadersLimit = 4096 // Number of signed headers to keep in memory for spotting double signs
+
+var (
+	errEvidenceNumbers  = errors.New("evidence headers have different numbers")
+	errEvidenceSameSeal = errors.New("evidence headers are the same signed header")
+)
+
+// Evidence proves that a masternode signed two different headers of the same
+// block number. Headers differing only by the signature of their validator are
+// the same signed header.
+type Evidence struct {
+	First  *types.Header
+	Second *types.Header
+}
+
+// EncodeEvidence returns the data of the evidence transaction carrying the
+// evidence.
+func EncodeEvidence(evidence *Evidence) ([]byte, error) {
+	return rlp.EncodeToBytes(evidence)
+}
+
+// DecodeEvidence decodes the data of an evidence transaction.
+func DecodeEvidence(data []byte) (*Evidence, error) {
+	evidence := new(Evidence)
+	if err := rlp.DecodeBytes(data, evidence); err != nil {
+		return nil, err
+	}
+	if evidence.First == nil || evidence.Second == nil || evidence.First.Number == nil || evidence.Second.Number == nil {
+		return nil, errors.New("evidence without headers")
+	}
+	return evidence, nil
+}
+
+// VerifyEvidence checks that both headers of the evidence are signed by the
+// same masternode, and returns it.
+func (c *Posv) VerifyEvidence(evidence *Evidence) (common.Address, error) {
+	first, second := evidence.First, evidence.Second
+	if first.Number.Cmp(second.Number) != 0 {
+		return common.Address{}, errEvidenceNumbers
+	}
+	if len(first.Extra) < extra.SealLength || len(second.Extra) < extra.SealLength {
+		return common.Address{}, errMissingSignature
+	}
+	if sigHash(first) == sigHash(second) {
+		return common.Address{}, errEvidenceSameSeal
+	}
+	signer, err := ecrecover(first, c.signatures)
+	if err != nil {
+		return common.Address{}, err
+	}
+	other, err := ecrecover(second, c.signatures)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if signer != other {
+		return common.Address{}, fmt.Errorf("evidence headers are signed by %x and %x", signer, other)
+	}
+	return signer, nil
+}
+
+// signedHeaders records the headers signed by each masternode per block
+// number, to spot the masternodes signing two of them.
+type signedHeaders struct {
+	headers  *lru.Cache // signed header of each masternode and number
+	reported *lru.Cache // masternodes and numbers an evidence was reported for
+}
+
+func newSignedHeaders() *signedHeaders {
+	headers, _ := lru.New(signedHeadersLimit)
+	reported, _ := lru.New(signedHeadersLimit)
+	return &signedHeaders{headers: headers, reported: reported}
+}
+
+type signedHeaderKey struct {
+	signer common.Address
+	number uint64
+}
+
+// record records a header signed by the signer, and returns the evidence of a
+// double sign if the signer signed another header of the same number before.
+// A double sign is only reported once.
+func (s *signedHeaders) record(signer common.Address, header *types.Header) *Evidence {
+	key := signedHeaderKey{signer: signer, number: header.Number.Uint64()}
+	known, ok := s.headers.Get(key)
+	if !ok {
+		s.headers.Add(key, header)
+		return nil
+	}
+	first := known.(*types.Header)
+	if sigHash(first) == sigHash(header) {
+		return nil
+	}
+	if ok, _ := s.reported.ContainsOrAdd(key, struct{}{}); ok {
+		return nil
+	}
+	return &Evidence{First: first, Second: header}
+}
+
+// recordSignedHeader records a header whose seal was verified, handing the
+// evidence of a double sign of its creator to HookEvidence.
+func (c *Posv) recordSignedHeader(creator common.Address, header *types.Header) {
+	evidence := c.signedHeaders.record(creator, header)
+	if evidence == nil {
+		return
+	}
+	log.Warn("Masternode signed two blocks of the same number", "masternode", creator, "number", header.Number, "first", evidence.First.Hash(), "second", evidence.Second.Hash())
+	if c.HookEvidence != nil {
+		// Report off the header verification, the evidence going through the tx pool
+		go func() {
+			if err := c.HookEvidence(evidence); err != nil {
+				log.Error("Failed to report double sign evidence", "masternode", creator, "number", header.Number, "err", err)
+			}
+		}()
+	}
+}
+
+// slashedMasternodes returns the candidates proven to have double signed by the
+// evidence transactions of the epoch ending at the checkpoint header. Only the
+// double signs of the last two epochs count, any earlier one being either
+// penalized already or too old.
+func (c *Posv) slashedMasternodes(chain consensus.ChainReader, header *types.Header, candidates []common.Address) []common.Address {
+	number := header.Number.Uint64()
+	since, _ := c.config.PreviousCheckpoint(number, 2)
+	start, _ := c.config.PreviousCheckpoint(number, 1)
+	isCandidate := make(map[common.Address]bool, len(candidates))
+	for _, candidate := range candidates {
+		isCandidate[candidate] = true
+	}
+	slashed := []common.Address{}
+	hash := header.ParentHash
+	for n := number - 1; n > start; n-- {
+		block := chain.GetBlock(hash, n)
+		if block == nil {
+			log.Error("Block of the epoch not found", "number", n, "hash", hash)
+			break
+		}
+		for _, tx := range block.Transactions() {
+			if !tx.IsSlashingEvidenceTransaction() {
+				continue
+			}
+			evidence, err := DecodeEvidence(tx.Data())
+			if err != nil {
+				log.Debug("Invalid double sign evidence", "tx", tx.Hash(), "err", err)
+				continue
+			}
+			if signed := evidence.First.Number.Uint64(); signed < since || signed >= number {
+				continue
+			}
+			signer, err := c.VerifyEvidence(evidence)
+			if err != nil {
+				log.Debug("Invalid double sign evidence", "tx", tx.Hash(), "err", err)
+				continue
+			}
+			signer = c.MasternodeOf(chain, evidence.First.Number.Uint64(), signer)
+			if isCandidate[signer] {
+				log.Debug("Slash double signing masternode", "address", signer, "number", number, "tx", tx.Hash())
+				slashed = append(slashed, signer)
+				delete(isCandidate, signer)
+			}
+		}
+		hash = block.ParentHash()
+	}
+	return slashed
+}
@@ -0,0 +1,163 @@
+package posv
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// blockChain is a consensus.ChainReader over a set of blocks.
+type blockChain struct {
+	headerChain
+	blocks map[common.Hash]*types.Block
+}
+
+func (bc *blockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	if block := bc.blocks[hash]; block != nil && block.NumberU64() == number {
+		return block
+	}
+	return nil
+}
+
+// signedHeader returns a header of the number sealed by the key.
+func signedHeader(t *testing.T, key *ecdsa.PrivateKey, number int64, time int64) *types.Header {
+	header := &types.Header{
+		Number:     big.NewInt(number),
+		Time:       big.NewInt(time),
+		Difficulty: big.NewInt(1),
+		Extra:      make([]byte, extra.VanityLength+extra.SealLength),
+	}
+	sealHeader(t, key, header)
+	return header
+}
+
+// sealHeader seals the header, whose extra-data ends with room for the seal.
+func sealHeader(t *testing.T, key *ecdsa.PrivateKey, header *types.Header) {
+	seal, err := crypto.Sign(sigHash(header).Bytes(), key)
+	if err != nil {
+		t.Fatalf("failed to seal header: %v", err)
+	}
+	copy(header.Extra[len(header.Extra)-extra.SealLength:], seal)
+}
+
+func TestVerifyEvidence(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	engine := New(&params.PosvConfig{Epoch: 900}, rawdb.NewMemoryDatabase())
+
+	first, second := signedHeader(t, key, 10, 1), signedHeader(t, key, 10, 2)
+	data, err := EncodeEvidence(&Evidence{First: first, Second: second})
+	if err != nil {
+		t.Fatalf("failed to encode evidence: %v", err)
+	}
+	evidence, err := DecodeEvidence(data)
+	if err != nil {
+		t.Fatalf("failed to decode evidence: %v", err)
+	}
+	if evidence.First.Hash() != first.Hash() || evidence.Second.Hash() != second.Hash() {
+		t.Fatalf("evidence headers mismatch after decoding")
+	}
+	if have, err := engine.VerifyEvidence(evidence); err != nil || have != signer {
+		t.Errorf("double signer mismatch: have %x (%v), want %x", have, err, signer)
+	}
+	// Signing a header again with another validator signature is no double sign
+	validated := types.CopyHeader(first)
+	validated.Validator = make([]byte, extra.SealLength)
+	if _, err := engine.VerifyEvidence(&Evidence{First: first, Second: validated}); err != errEvidenceSameSeal {
+		t.Errorf("same seal error mismatch: have %v, want %v", err, errEvidenceSameSeal)
+	}
+	if _, err := engine.VerifyEvidence(&Evidence{First: first, Second: signedHeader(t, key, 11, 2)}); err != errEvidenceNumbers {
+		t.Errorf("numbers error mismatch: have %v, want %v", err, errEvidenceNumbers)
+	}
+	if _, err := engine.VerifyEvidence(&Evidence{First: first, Second: signedHeader(t, other, 10, 2)}); err == nil {
+		t.Errorf("headers of different signers accepted as evidence")
+	}
+	if _, err := DecodeEvidence([]byte{0xc0}); err == nil {
+		t.Errorf("evidence without headers decoded")
+	}
+}
+
+func TestSignedHeadersRecord(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	headers := newSignedHeaders()
+
+	first := signedHeader(t, key, 10, 1)
+	if evidence := headers.record(signer, first); evidence != nil {
+		t.Fatalf("evidence of a single header")
+	}
+	validated := types.CopyHeader(first)
+	validated.Validator = make([]byte, extra.SealLength)
+	if evidence := headers.record(signer, validated); evidence != nil {
+		t.Fatalf("evidence of a header validated again")
+	}
+	if evidence := headers.record(signer, signedHeader(t, key, 11, 2)); evidence != nil {
+		t.Fatalf("evidence of headers of different numbers")
+	}
+	second := signedHeader(t, key, 10, 2)
+	evidence := headers.record(signer, second)
+	if evidence == nil {
+		t.Fatalf("no evidence of a double sign")
+	}
+	if evidence.First != first || evidence.Second != second {
+		t.Errorf("evidence headers mismatch")
+	}
+	if evidence := headers.record(signer, signedHeader(t, key, 10, 3)); evidence != nil {
+		t.Errorf("double sign reported twice")
+	}
+}
+
+func TestSlashedMasternodes(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	addrs := make([]common.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	evidenceTx := func(key *ecdsa.PrivateKey, number int64) *types.Transaction {
+		data, err := EncodeEvidence(&Evidence{First: signedHeader(t, key, number, 1), Second: signedHeader(t, key, number, 2)})
+		if err != nil {
+			t.Fatalf("failed to encode evidence: %v", err)
+		}
+		return types.NewTransaction(0, common.HexToAddress(common.SlashingEvidenceAddr), big.NewInt(0), 0, big.NewInt(0), data)
+	}
+	// Evidence transactions of the blocks of the epoch ending at block 30
+	txs := map[uint64][]*types.Transaction{
+		15: {evidenceTx(keys[0], 12)}, // previous epoch, not counted
+		22: {evidenceTx(keys[1], 5)},  // too old
+		24: {evidenceTx(keys[0], 18)},
+		26: {evidenceTx(keys[2], 25), evidenceTx(keys[0], 25)},
+		28: {types.NewTransaction(0, common.HexToAddress(common.SlashingEvidenceAddr), big.NewInt(0), 0, big.NewInt(0), []byte{0x01})},
+	}
+	chain := &blockChain{
+		headerChain: headerChain{config: &params.ChainConfig{}, headers: map[common.Hash]*types.Header{}},
+		blocks:      map[common.Hash]*types.Block{},
+	}
+	parent := common.Hash{}
+	for n := uint64(1); n < 30; n++ {
+		block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(n), ParentHash: parent}, txs[n], nil, nil)
+		chain.headers[block.Hash()], chain.blocks[block.Hash()] = block.Header(), block
+		parent = block.Hash()
+	}
+	engine := New(&params.PosvConfig{Epoch: 10}, rawdb.NewMemoryDatabase())
+	checkpoint := &types.Header{Number: big.NewInt(30), ParentHash: parent}
+
+	slashed := engine.slashedMasternodes(chain, checkpoint, addrs)
+	if want := []common.Address{addrs[2], addrs[0]}; !reflect.DeepEqual(slashed, want) {
+		t.Errorf("slashed masternodes mismatch: have %x, want %x", slashed, want)
+	}
+	// Only the candidates are slashed
+	slashed = engine.slashedMasternodes(chain, checkpoint, addrs[:2])
+	if want := []common.Address{addrs[0]}; !reflect.DeepEqual(slashed, want) {
+		t.Errorf("slashed candidates mismatch: have %x, want %x", slashed, want)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package extra implements the layout of the extra-data of PoSV block headers.
+//
+// The extra-data always starts with a fixed size vanity and ends with the seal
+// of the block creator. The body in between depends on the version in effect
+// at the block number. Version 0 is the original layout, holding the
+// concatenated masternode addresses on checkpoint blocks:
+//
+//	vanity (32) || masternodes (20 each) || seal (65)
+//
+// Version 1 tags the body and RLP encodes it, so that new fields can be added
+// without repurposing offsets:
+//
+//	vanity (32) || 0x01 || rlp([masternodes, commitments]) || seal (65)
+package extra
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+const (
+	VanityLength = 32 // Fixed number of extra-data prefix bytes reserved for signer vanity
+	SealLength   = 65 // Fixed number of extra-data suffix bytes reserved for signer seal
+)
+
+// Version is the layout version of the extra-data body.
+type Version uint8
+
+const (
+	VersionLegacy Version = 0 // Concatenated masternode addresses
+	VersionRLP    Version = 1 // Tagged RLP list of typed fields
+)
+
+var (
+	// ErrMissingVanity is returned if the extra-data is shorter than the vanity.
+	ErrMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// ErrMissingSeal is returned if the extra-data is too short to hold both the
+	// vanity and the seal.
+	ErrMissingSeal = errors.New("extra-data 65 byte suffix signature missing")
+
+	// ErrInvalidMasternodes is returned if the masternode list of the extra-data
+	// can't be decoded.
+	ErrInvalidMasternodes = errors.New("invalid signer list on checkpoint block")
+
+	// ErrInvalidVersion is returned if the extra-data body isn't tagged with the
+	// version in effect at the block number.
+	ErrInvalidVersion = errors.New("invalid extra-data version")
+)
+
+// VersionAt returns the extra-data version in effect at the given block number.
+func VersionAt(number *big.Int) Version {
+	if common.TIPExtraV1Block != nil && number != nil && number.Cmp(common.TIPExtraV1Block) >= 0 {
+		return VersionRLP
+	}
+	return VersionLegacy
+}
+
+// Extra is the decoded extra-data of a block header.
+type Extra struct {
+	Version     Version
+	Vanity      [VanityLength]byte
+	Masternodes []common.Address // Masternodes of the next epoch, checkpoint blocks only
+	Commitments []common.Hash    // Additional commitments, VersionRLP onwards
+	Seal        [SealLength]byte
+}
+
+// rlpBody is the body of the extra-data from VersionRLP on.
+type rlpBody struct {
+	Masternodes []common.Address
+	Commitments []common.Hash
+}
+
+// DecodeHeader decodes the extra-data of a header, using the version in effect
+// at its number.
+func DecodeHeader(header *types.Header) (*Extra, error) {
+	return Decode(VersionAt(header.Number), header.Extra)
+}
+
+// Decode decodes extra-data laid out in the given version.
+func Decode(version Version, data []byte) (*Extra, error) {
+	if len(data) < VanityLength {
+		return nil, ErrMissingVanity
+	}
+	if len(data) < VanityLength+SealLength {
+		return nil, ErrMissingSeal
+	}
+	extra := &Extra{Version: version}
+	copy(extra.Vanity[:], data)
+	copy(extra.Seal[:], data[len(data)-SealLength:])
+
+	body := data[VanityLength : len(data)-SealLength]
+	switch version {
+	case VersionLegacy:
+		if len(body)%common.AddressLength != 0 {
+			return nil, ErrInvalidMasternodes
+		}
+		extra.Masternodes = make([]common.Address, len(body)/common.AddressLength)
+		for i := range extra.Masternodes {
+			copy(extra.Masternodes[i][:], body[i*common.AddressLength:])
+		}
+	case VersionRLP:
+		if len(body) == 0 || Version(body[0]) != version {
+			return nil, ErrInvalidVersion
+		}
+		var dec rlpBody
+		if err := rlp.DecodeBytes(body[1:], &dec); err != nil {
+			return nil, fmt.Errorf("invalid extra-data body: %v", err)
+		}
+		extra.Masternodes, extra.Commitments = dec.Masternodes, dec.Commitments
+		if extra.Masternodes == nil {
+			extra.Masternodes = []common.Address{}
+		}
+	default:
+		return nil, fmt.Errorf("unknown extra-data version %d", version)
+	}
+	return extra, nil
+}
+
+// Encode returns the extra-data laid out in the version of the extra.
+func (e *Extra) Encode() ([]byte, error) {
+	data := append([]byte{}, e.Vanity[:]...)
+	switch e.Version {
+	case VersionLegacy:
+		if len(e.Commitments) > 0 {
+			return nil, fmt.Errorf("extra-data version %d can't hold commitments", e.Version)
+		}
+		for _, masternode := range e.Masternodes {
+			data = append(data, masternode[:]...)
+		}
+	case VersionRLP:
+		body, err := rlp.EncodeToBytes(&rlpBody{Masternodes: e.Masternodes, Commitments: e.Commitments})
+		if err != nil {
+			return nil, err
+		}
+		data = append(append(data, byte(e.Version)), body...)
+	default:
+		return nil, fmt.Errorf("unknown extra-data version %d", e.Version)
+	}
+	return append(data, e.Seal[:]...), nil
+}
+
+// Seal returns the seal at the end of the extra-data, which is at the same
+// place in every version.
+func Seal(data []byte) ([]byte, error) {
+	if len(data) < SealLength {
+		return nil, ErrMissingSeal
+	}
+	return data[len(data)-SealLength:], nil
+}
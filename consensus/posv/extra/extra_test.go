@@ -0,0 +1,117 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package extra
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// Tests that the legacy layout matches the original offset based encoding.
+func TestLegacyEncoding(t *testing.T) {
+	masternodes := []common.Address{{0x01}, {0x02}}
+	want := make([]byte, VanityLength+len(masternodes)*common.AddressLength+SealLength)
+	want[0] = 0xaa
+	for i, masternode := range masternodes {
+		copy(want[VanityLength+i*common.AddressLength:], masternode[:])
+	}
+	want[len(want)-1] = 0xbb
+
+	extra := &Extra{Version: VersionLegacy, Masternodes: masternodes}
+	extra.Vanity[0], extra.Seal[SealLength-1] = 0xaa, 0xbb
+	enc, err := extra.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("encoding mismatch: have %x, want %x", enc, want)
+	}
+	dec, err := Decode(VersionLegacy, enc)
+	if err != nil {
+		t.Fatalf("failed to decode extra-data: %v", err)
+	}
+	if !reflect.DeepEqual(dec, extra) {
+		t.Fatalf("decoded extra-data mismatch: have %+v, want %+v", dec, extra)
+	}
+	if _, err := Decode(VersionLegacy, want[:len(want)-1]); err != ErrInvalidMasternodes {
+		t.Errorf("partial masternode error mismatch: have %v, want %v", err, ErrInvalidMasternodes)
+	}
+	if _, err := Decode(VersionLegacy, want[:VanityLength+SealLength-1]); err != ErrMissingSeal {
+		t.Errorf("missing seal error mismatch: have %v, want %v", err, ErrMissingSeal)
+	}
+	if _, err := Decode(VersionLegacy, want[:VanityLength-1]); err != ErrMissingVanity {
+		t.Errorf("missing vanity error mismatch: have %v, want %v", err, ErrMissingVanity)
+	}
+	extra.Commitments = []common.Hash{{0x03}}
+	if _, err := extra.Encode(); err == nil {
+		t.Errorf("commitments encoded into legacy extra-data")
+	}
+}
+
+// Tests that the RLP layout round trips and keeps the seal at the end.
+func TestRLPEncoding(t *testing.T) {
+	extra := &Extra{
+		Version:     VersionRLP,
+		Masternodes: []common.Address{{0x01}},
+		Commitments: []common.Hash{{0x02}},
+	}
+	extra.Seal[0] = 0xbb
+
+	enc, err := extra.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if enc[VanityLength] != byte(VersionRLP) {
+		t.Errorf("version tag mismatch: have %d, want %d", enc[VanityLength], VersionRLP)
+	}
+	if seal, _ := Seal(enc); !bytes.Equal(seal, extra.Seal[:]) {
+		t.Errorf("seal mismatch: have %x, want %x", seal, extra.Seal)
+	}
+	dec, err := Decode(VersionRLP, enc)
+	if err != nil {
+		t.Fatalf("failed to decode extra-data: %v", err)
+	}
+	if !reflect.DeepEqual(dec, extra) {
+		t.Fatalf("decoded extra-data mismatch: have %+v, want %+v", dec, extra)
+	}
+	// Legacy extra-data must be rejected once the RLP layout is in effect
+	legacy, _ := (&Extra{Version: VersionLegacy}).Encode()
+	if _, err := Decode(VersionRLP, legacy); err != ErrInvalidVersion {
+		t.Errorf("legacy extra-data error mismatch: have %v, want %v", err, ErrInvalidVersion)
+	}
+}
+
+// Tests that the version switches at the fork block.
+func TestVersionAt(t *testing.T) {
+	defer func(fork *big.Int) { common.TIPExtraV1Block = fork }(common.TIPExtraV1Block)
+
+	common.TIPExtraV1Block = nil
+	if v := VersionAt(big.NewInt(1000000000)); v != VersionLegacy {
+		t.Errorf("unscheduled version mismatch: have %d, want %d", v, VersionLegacy)
+	}
+	common.TIPExtraV1Block = big.NewInt(100)
+	if v := VersionAt(big.NewInt(99)); v != VersionLegacy {
+		t.Errorf("pre-fork version mismatch: have %d, want %d", v, VersionLegacy)
+	}
+	if v := VersionAt(big.NewInt(100)); v != VersionRLP {
+		t.Errorf("fork version mismatch: have %d, want %d", v, VersionRLP)
+	}
+}
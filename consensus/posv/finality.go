@@ -0,0 +1,228 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/tomochain/tomochain/accounts"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/crypto/sha3"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	finalityVotesLimit = 1024 // Number of blocks to keep the votes of in memory
+	maxVoteDistance    = 900  // Most blocks a vote may lag behind the chain head
+)
+
+var (
+	errUnknownVoteBlock = errors.New("vote on an unknown block")
+	errStaleVote        = errors.New("vote on a stale block")
+	errVoteSigner       = errors.New("vote signer is not a masternode of the block")
+	errKnownVote        = errors.New("vote already known")
+	errConflictingVote  = errors.New("vote conflicting with another vote of the masternode at the same height")
+	errFinalityConflict = errors.New("voted block does not descend from the finalized block")
+
+	finalizedKey = []byte("posv-finalized") // Database key of the highest finalized block
+)
+
+// Vote is the vote of a masternode on a block. A block voted for by at least
+// two thirds of the masternodes of its epoch, every masternode weighing the
+// same, is finalized, and so are its ancestors.
+type Vote struct {
+	Hash      common.Hash // Hash of the block voted for
+	Number    uint64      // Number of the block voted for
+	Signature []byte      // Signature of the masternode over the vote hash
+}
+
+// SigHash returns the hash the masternode signs the vote over.
+func (v *Vote) SigHash() (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+	rlp.Encode(hasher, []interface{}{v.Hash, v.Number})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// ID returns the hash identifying the signed vote.
+func (v *Vote) ID() common.Hash {
+	return crypto.Keccak256Hash(v.Hash[:], v.Signature)
+}
+
+// finalizedBlock is the highest finalized block, as stored in the database.
+type finalizedBlock struct {
+	Hash   common.Hash
+	Number uint64
+}
+
+// finality collects the votes of the masternodes on the recent blocks.
+type finality struct {
+	votes     *lru.Cache // Signers of the votes on recent blocks, by block hash
+	cast      *lru.Cache // Blocks voted for by every signer, by block number
+	finalized *finalizedBlock
+	loaded    bool // Whether the finalized block was loaded from the database
+	lock      sync.Mutex
+}
+
+func newFinality() *finality {
+	votes, _ := lru.New(finalityVotesLimit)
+	cast, _ := lru.New(finalityVotesLimit)
+	return &finality{votes: votes, cast: cast, finalized: new(finalizedBlock)}
+}
+
+// SignVote signs a vote on the header with the local signing credentials.
+func (c *Posv) SignVote(header *types.Header) (*Vote, error) {
+	c.lock.RLock()
+	signer, signFn := c.signer, c.signFn
+	c.lock.RUnlock()
+
+	if signFn == nil {
+		return nil, errUnauthorized
+	}
+	vote := &Vote{Hash: header.Hash(), Number: header.Number.Uint64()}
+	signature, err := signFn(accounts.Account{Address: signer}, vote.SigHash().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	vote.Signature = signature
+	return vote, nil
+}
+
+// VoteSigner returns the masternode which signed the vote.
+func (c *Posv) VoteSigner(vote *Vote) (common.Address, error) {
+	if len(vote.Signature) != extra.SealLength {
+		return common.Address{}, errMissingSignature
+	}
+	pubkey, err := crypto.Ecrecover(vote.SigHash().Bytes(), vote.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// AddVote counts the vote of a masternode on a recent block imported already,
+// finalizing the block once two thirds of its masternodes voted for it. Votes
+// on blocks not above the finalized one are stale, and a masternode voting for
+// two blocks at the same height has its second vote refused. A block is only
+// finalized if it descends from the block finalized before it.
+func (c *Posv) AddVote(chain consensus.ChainReader, vote *Vote) error {
+	header := chain.GetHeader(vote.Hash, vote.Number)
+	if header == nil {
+		return errUnknownVoteBlock
+	}
+	if head := chain.CurrentHeader(); head != nil && vote.Number+maxVoteDistance < head.Number.Uint64() {
+		return errStaleVote
+	}
+	signer, err := c.VoteSigner(vote)
+	if err != nil {
+		return err
+	}
+	signer = c.MasternodeOf(chain, header.Number.Uint64(), signer)
+	masternodes := c.GetMasternodes(chain, header)
+	if position(masternodes, signer) < 0 {
+		return errVoteSigner
+	}
+	f := c.finality
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if vote.Number <= c.loadFinalized().Number {
+		return errStaleVote
+	}
+	// Refuse the masternodes voting for two different blocks at the same height
+	var cast map[common.Address]common.Hash
+	if known, ok := f.cast.Get(vote.Number); ok {
+		cast = known.(map[common.Address]common.Hash)
+	} else {
+		cast = make(map[common.Address]common.Hash)
+		f.cast.Add(vote.Number, cast)
+	}
+	if voted, ok := cast[signer]; ok && voted != vote.Hash {
+		log.Warn("Masternode voted for conflicting blocks", "signer", signer, "number", vote.Number, "voted", voted, "hash", vote.Hash)
+		return errConflictingVote
+	}
+	var signers map[common.Address]struct{}
+	if known, ok := f.votes.Get(vote.Hash); ok {
+		signers = known.(map[common.Address]struct{})
+	} else {
+		signers = make(map[common.Address]struct{})
+		f.votes.Add(vote.Hash, signers)
+	}
+	if _, ok := signers[signer]; ok {
+		return errKnownVote
+	}
+	signers[signer] = struct{}{}
+	cast[signer] = vote.Hash
+
+	if 3*len(signers) >= 2*len(masternodes) {
+		if finalized := c.loadFinalized(); finalized.Hash != (common.Hash{}) && !descends(chain, header, finalized) {
+			log.Warn("Refused to finalize a block off the finalized chain", "number", vote.Number, "hash", vote.Hash, "finalized", finalized.Hash)
+			return errFinalityConflict
+		}
+		f.finalized = &finalizedBlock{Hash: vote.Hash, Number: vote.Number}
+		if data, err := rlp.EncodeToBytes(f.finalized); err == nil {
+			if err := c.db.Put(finalizedKey, data); err != nil {
+				log.Error("Failed to store finalized block", "number", vote.Number, "hash", vote.Hash, "err", err)
+			}
+		}
+		log.Debug("Finalized block", "number", vote.Number, "hash", vote.Hash, "votes", len(signers), "masternodes", len(masternodes))
+	}
+	return nil
+}
+
+// descends returns whether the header descends from the block.
+func descends(chain consensus.ChainReader, header *types.Header, block *finalizedBlock) bool {
+	for header != nil && header.Number.Uint64() > block.Number {
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return header != nil && header.Hash() == block.Hash
+}
+
+// Finalized returns the hash and number of the highest finalized block, the
+// zero hash if no block was finalized yet.
+func (c *Posv) Finalized() (common.Hash, uint64) {
+	c.finality.lock.Lock()
+	defer c.finality.lock.Unlock()
+
+	finalized := c.loadFinalized()
+	return finalized.Hash, finalized.Number
+}
+
+// loadFinalized returns the highest finalized block, loading it from the
+// database first. The finality lock is held by the caller.
+func (c *Posv) loadFinalized() *finalizedBlock {
+	f := c.finality
+	if !f.loaded {
+		f.loaded = true
+		if data, err := c.db.Get(finalizedKey); err == nil {
+			stored := new(finalizedBlock)
+			if err := rlp.DecodeBytes(data, stored); err == nil {
+				f.finalized = stored
+			}
+		}
+	}
+	return f.finalized
+}
@@ -0,0 +1,163 @@
+package posv
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/accounts"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestFinalityVotes(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	masternodes := make([]common.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		if i < len(masternodes) {
+			masternodes[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		}
+	}
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	chain := &headerChain{config: &params.ChainConfig{}, headers: map[common.Hash]*types.Header{}}
+	for _, header := range []*types.Header{
+		{Number: big.NewInt(0), Extra: data},
+		{Number: big.NewInt(3)},
+		{Number: big.NewInt(5)},
+	} {
+		chain.headers[header.Hash()] = header
+	}
+	db := rawdb.NewMemoryDatabase()
+	engine := New(&params.PosvConfig{Epoch: 900}, db)
+
+	// vote returns the vote of the key on the block of the number
+	vote := func(key *ecdsa.PrivateKey, number uint64) *Vote {
+		engine.Authorize(crypto.PubkeyToAddress(key.PublicKey), func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+		vote, err := engine.SignVote(chain.GetHeaderByNumber(number))
+		if err != nil {
+			t.Fatalf("failed to sign vote: %v", err)
+		}
+		return vote
+	}
+	if err := engine.AddVote(chain, vote(keys[0], 5)); err != nil {
+		t.Fatalf("failed to add vote: %v", err)
+	}
+	if hash, _ := engine.Finalized(); hash != (common.Hash{}) {
+		t.Fatalf("block finalized by a third of the masternodes")
+	}
+	if err := engine.AddVote(chain, vote(keys[0], 5)); err != errKnownVote {
+		t.Errorf("known vote error mismatch: have %v, want %v", err, errKnownVote)
+	}
+	if err := engine.AddVote(chain, vote(keys[3], 5)); err != errVoteSigner {
+		t.Errorf("vote of no masternode error mismatch: have %v, want %v", err, errVoteSigner)
+	}
+	unknown := vote(keys[1], 5)
+	unknown.Hash = common.Hash{0x01}
+	if err := engine.AddVote(chain, unknown); err != errUnknownVoteBlock {
+		t.Errorf("vote on unknown block error mismatch: have %v, want %v", err, errUnknownVoteBlock)
+	}
+	forged := vote(keys[1], 5)
+	forged.Signature = vote(keys[3], 5).Signature
+	if err := engine.AddVote(chain, forged); err != errVoteSigner {
+		t.Errorf("forged vote error mismatch: have %v, want %v", err, errVoteSigner)
+	}
+	// Two thirds of the masternodes finalize the block
+	if err := engine.AddVote(chain, vote(keys[1], 5)); err != nil {
+		t.Fatalf("failed to add vote: %v", err)
+	}
+	want := chain.GetHeaderByNumber(5).Hash()
+	if hash, number := engine.Finalized(); hash != want || number != 5 {
+		t.Fatalf("finalized block mismatch: have %d %x, want 5 %x", number, hash, want)
+	}
+	if err := engine.AddVote(chain, vote(keys[2], 3)); err != errStaleVote {
+		t.Errorf("vote below the finalized block error mismatch: have %v, want %v", err, errStaleVote)
+	}
+	// The finalized block survives a restart
+	if hash, number := New(&params.PosvConfig{Epoch: 900}, db).Finalized(); hash != want || number != 5 {
+		t.Errorf("stored finalized block mismatch: have %d %x, want 5 %x", number, hash, want)
+	}
+}
+
+func TestFinalityConflicts(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	masternodes := make([]common.Address, len(keys))
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		masternodes[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	chain := &headerChain{config: &params.ChainConfig{}, headers: map[common.Hash]*types.Header{}}
+	child := func(parent *types.Header, time int64) *types.Header {
+		header := &types.Header{ParentHash: parent.Hash(), Number: new(big.Int).Add(parent.Number, common.Big1), Time: big.NewInt(time)}
+		chain.headers[header.Hash()] = header
+		return header
+	}
+	genesis := &types.Header{Number: big.NewInt(0), Extra: data}
+	chain.headers[genesis.Hash()] = genesis
+
+	// The canonical chain a1-a2-a3 and the side chain b1-b2 fork at the genesis
+	a1 := child(genesis, 1)
+	a2 := child(a1, 2)
+	a3 := child(a2, 3)
+	b1 := child(genesis, 10)
+	b2 := child(b1, 20)
+
+	engine := New(&params.PosvConfig{Epoch: 900}, rawdb.NewMemoryDatabase())
+	vote := func(key *ecdsa.PrivateKey, header *types.Header) *Vote {
+		engine.Authorize(crypto.PubkeyToAddress(key.PublicKey), func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+		vote, err := engine.SignVote(header)
+		if err != nil {
+			t.Fatalf("failed to sign vote: %v", err)
+		}
+		return vote
+	}
+	for _, key := range keys[:2] {
+		if err := engine.AddVote(chain, vote(key, a1)); err != nil {
+			t.Fatalf("failed to add vote: %v", err)
+		}
+	}
+	if hash, _ := engine.Finalized(); hash != a1.Hash() {
+		t.Fatalf("finalized block mismatch: have %x, want %x", hash, a1.Hash())
+	}
+	// A second vote of a masternode at the same height is refused
+	if err := engine.AddVote(chain, vote(keys[0], a2)); err != nil {
+		t.Fatalf("failed to add vote: %v", err)
+	}
+	if err := engine.AddVote(chain, vote(keys[0], b2)); err != errConflictingVote {
+		t.Errorf("conflicting vote error mismatch: have %v, want %v", err, errConflictingVote)
+	}
+	// A side chain block is never finalized over the finalized block
+	if err := engine.AddVote(chain, vote(keys[1], b2)); err != nil {
+		t.Fatalf("failed to add vote: %v", err)
+	}
+	if err := engine.AddVote(chain, vote(keys[2], b2)); err != errFinalityConflict {
+		t.Errorf("side chain finality error mismatch: have %v, want %v", err, errFinalityConflict)
+	}
+	if hash, _ := engine.Finalized(); hash != a1.Hash() {
+		t.Fatalf("finalized block mismatch: have %x, want %x", hash, a1.Hash())
+	}
+	// The descendants of the finalized block are still finalized
+	for _, key := range keys[1:] {
+		if err := engine.AddVote(chain, vote(key, a3)); err != nil {
+			t.Fatalf("failed to add vote: %v", err)
+		}
+	}
+	if hash, number := engine.Finalized(); hash != a3.Hash() || number != 3 {
+		t.Errorf("finalized block mismatch: have %d %x, want 3 %x", number, hash, a3.Hash())
+	}
+}
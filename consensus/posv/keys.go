@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+)
+
+const signingKeysCacheLimit = 16 // Number of epochs to keep the signing keys of in memory
+
+// signingKeysKey returns the database key of the signing keys registered at the
+// gap block of the given hash.
+func signingKeysKey(hash common.Hash) []byte {
+	return append([]byte("posv-keys-"), hash[:]...)
+}
+
+// signingKeys resolves the keys sealing the blocks of an epoch to their
+// masternodes.
+//
+// The keys registered in the validator contract by the gap block take effect
+// from the next checkpoint on. During the first epoch of a new key, the key it
+// replaces is still accepted, so that the masternode can switch its signer
+// without missing its turns.
+type signingKeys struct {
+	owners  map[common.Address]common.Address // Registered keys, new or retiring, to their masternode
+	retired map[common.Address]bool           // Masternodes whose own key was rotated out
+}
+
+// newSigningKeys accepts the keys registered for the epoch and the ones they
+// replace. The own key of a masternode retires once it registered a key for
+// two epochs in a row.
+func newSigningKeys(current, previous map[common.Address]common.Address) *signingKeys {
+	keys := &signingKeys{
+		owners:  make(map[common.Address]common.Address),
+		retired: make(map[common.Address]bool),
+	}
+	for masternode, key := range previous {
+		keys.owners[key] = masternode
+	}
+	for masternode, key := range current {
+		keys.owners[key] = masternode
+		if _, ok := previous[masternode]; ok {
+			keys.retired[masternode] = true
+		}
+	}
+	return keys
+}
+
+// masternode returns the masternode the key seals for, the key itself if it
+// was never rotated, or the zero address for a retired key.
+func (k *signingKeys) masternode(key common.Address) common.Address {
+	if masternode, ok := k.owners[key]; ok {
+		return masternode
+	}
+	if k.retired[key] {
+		return common.Address{}
+	}
+	return key
+}
+
+// MasternodeOf returns the masternode the key seals and signs the block of the
+// number for. Before TIPKeyRotation every masternode signs with its own key.
+func (c *Posv) MasternodeOf(chain consensus.ChainReader, number uint64, key common.Address) common.Address {
+	masternode, err := c.masternodeOf(chain, number, key)
+	if err != nil {
+		log.Debug("Failed to resolve the signing key", "number", number, "key", key, "err", err)
+		return key
+	}
+	return masternode
+}
+
+func (c *Posv) masternodeOf(chain consensus.ChainReader, number uint64, key common.Address) (common.Address, error) {
+	keys, err := c.signingKeys(chain, number)
+	if err != nil || keys == nil {
+		return key, err
+	}
+	return keys.masternode(key), nil
+}
+
+// signingKeys returns the keys sealing the blocks of the epoch of the number,
+// or nil if the keys could not rotate yet.
+func (c *Posv) signingKeys(chain consensus.ChainReader, number uint64) (*signingKeys, error) {
+	if c.HookSigningKeys == nil {
+		return nil, nil
+	}
+	// The keys of the epoch were registered by the gap of the previous one
+	gap, err := c.keysGap(chain, number, 1)
+	if gap == nil || err != nil {
+		return nil, err
+	}
+	if keys, ok := c.epochKeys.Get(gap.Hash()); ok {
+		return keys.(*signingKeys), nil
+	}
+	current, err := c.registeredKeys(chain, gap)
+	if err != nil {
+		return nil, err
+	}
+	var previous map[common.Address]common.Address
+	if gap, err := c.keysGap(chain, number, 2); err != nil {
+		return nil, err
+	} else if gap != nil {
+		if previous, err = c.registeredKeys(chain, gap); err != nil {
+			return nil, err
+		}
+	}
+	keys := newSigningKeys(current, previous)
+	c.epochKeys.Add(gap.Hash(), keys)
+	return keys, nil
+}
+
+// keysGap returns the gap block of the given number of epochs before the one of
+// the number, or nil if the keys could not rotate by then.
+func (c *Posv) keysGap(chain consensus.ChainReader, number uint64, epochs uint64) (*types.Header, error) {
+	checkpoint, ok := c.config.PreviousCheckpoint(number, epochs)
+	if !ok {
+		return nil, nil
+	}
+	gap := checkpoint + c.config.EpochLength(checkpoint) - c.config.GapLength(checkpoint)
+	if !chain.Config().IsTIPKeyRotation(new(big.Int).SetUint64(gap)) {
+		return nil, nil
+	}
+	header := chain.GetHeaderByNumber(gap)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return header, nil
+}
+
+// registeredKeys returns the keys registered for the candidates in the validator
+// contract at the gap block. The keys are read from the state once and recorded
+// in the database, the state of the gap being pruned soon after.
+func (c *Posv) registeredKeys(chain consensus.ChainReader, gap *types.Header) (map[common.Address]common.Address, error) {
+	if blob, err := c.db.Get(signingKeysKey(gap.Hash())); err == nil {
+		registered := make(map[common.Address]common.Address)
+		if err := json.Unmarshal(blob, &registered); err == nil {
+			return registered, nil
+		}
+	}
+	registered, err := c.HookSigningKeys(chain, gap)
+	if err != nil {
+		return nil, err
+	}
+	if blob, err := json.Marshal(registered); err == nil {
+		if err := c.db.Put(signingKeysKey(gap.Hash()), blob); err != nil {
+			log.Warn("Failed to record the signing keys", "number", gap.Number, "err", err)
+		}
+	}
+	return registered, nil
+}
@@ -0,0 +1,77 @@
+package posv
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that a rotated key seals for its masternode from the epoch after the
+// gap it was registered by, both keys being accepted for that epoch only.
+func TestSigningKeysRotation(t *testing.T) {
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 10, Gap: 5}, TIPKeyRotationBlock: big.NewInt(0)}
+	engine := New(config.Posv, rawdb.NewMemoryDatabase())
+
+	chain := &headerChain{config: config, headers: make(map[common.Hash]*types.Header)}
+	var parent common.Hash
+	for n := int64(0); n <= 40; n++ {
+		header := &types.Header{Number: big.NewInt(n), ParentHash: parent}
+		chain.headers[header.Hash()] = header
+		parent = header.Hash()
+	}
+	var (
+		masternode = common.Address{0x01}
+		other      = common.Address{0x02}
+		first      = common.Address{0xa1}
+		second     = common.Address{0xa2}
+	)
+	// The first key is registered by the gap 15, the second one by the gap 25
+	registered := map[uint64]map[common.Address]common.Address{
+		15: {masternode: first},
+		25: {masternode: second},
+		35: {masternode: second},
+	}
+	reads := 0
+	engine.HookSigningKeys = func(chain consensus.ChainReader, gap *types.Header) (map[common.Address]common.Address, error) {
+		reads++
+		return registered[gap.Number.Uint64()], nil
+	}
+	tests := []struct {
+		number uint64
+		key    common.Address
+		want   common.Address
+	}{
+		{19, masternode, masternode},
+		{19, first, first},
+		{20, masternode, masternode}, // own key accepted in the first epoch of the rotation
+		{20, first, masternode},
+		{29, other, other},      // keys never rotated seal for themselves
+		{30, first, masternode}, // replaced key accepted in the first epoch of the next rotation
+		{30, second, masternode},
+		{30, masternode, common.Address{}},
+		{40, first, first},
+		{40, second, masternode},
+	}
+	for i, tt := range tests {
+		have, err := engine.masternodeOf(chain, tt.number, tt.key)
+		if err != nil {
+			t.Fatalf("test %d: failed to resolve the key: %v", i, err)
+		}
+		if have != tt.want {
+			t.Errorf("test %d: masternode of %x at %d mismatch: have %x, want %x", i, tt.key, tt.number, have, tt.want)
+		}
+	}
+	// The registered keys are read once per gap, then cached and recorded
+	if reads != 4 {
+		t.Errorf("state reads mismatch: have %d, want %d", reads, 4)
+	}
+	engine.epochKeys.Purge()
+	if _, err := engine.masternodeOf(chain, 30, second); err != nil || reads != 4 {
+		t.Errorf("recorded keys not reused: reads %d, err %v", reads, err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/log"
+)
+
+// Reasons a masternode is penalized at a checkpoint for.
+const (
+	PenaltyMissedBlocks     = "missedBlocks"     // created too few blocks in the previous epoch
+	PenaltyUnsignedComeback = "unsignedComeback" // came back from a penalty without signing the recent blocks
+	PenaltyMissedSigning    = "missedSigning"    // signed no block of the previous epoch, before TIPSigning
+	PenaltyDoubleSign       = "doubleSign"       // proven to have signed two blocks of the same number
+)
+
+// PenaltyDetail is the penalty of a masternode at a checkpoint, with the turns
+// it had in the previous epoch.
+type PenaltyDetail struct {
+	Address  common.Address `json:"address"`
+	Reasons  []string       `json:"reasons"`
+	Proposed uint64         `json:"proposed"` // blocks created in the previous epoch
+	Missed   uint64         `json:"missed"`   // turns skipped in the previous epoch
+}
+
+// PenaltyDetails is the penalties of the masternodes at the checkpoint of an
+// epoch, in the order of the checkpoint header.
+type PenaltyDetails struct {
+	Epoch      uint64           `json:"epoch"`
+	Checkpoint uint64           `json:"checkpoint"`
+	Hash       common.Hash      `json:"hash"`
+	Penalties  []*PenaltyDetail `json:"penalties"`
+}
+
+// penaltyDetailsKey returns the database key of the penalties of a checkpoint.
+func penaltyDetailsKey(hash common.Hash) []byte {
+	return append([]byte("posv-penalties-"), hash[:]...)
+}
+
+// PenaltyDetails reports why the masternodes penalized at the checkpoint of the
+// epoch were penalized. The reasons are worked out from the blocks of the epoch
+// once, and recorded in the database.
+func (c *Posv) PenaltyDetails(chain consensus.ChainReader, epoch uint64) (*PenaltyDetails, error) {
+	if epoch == 0 {
+		return nil, errors.New("genesis masternodes have no penalty")
+	}
+	number := c.config.EpochCheckpoint(epoch)
+	header := chain.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	if blob, err := c.db.Get(penaltyDetailsKey(header.Hash())); err == nil {
+		details := new(PenaltyDetails)
+		if err := json.Unmarshal(blob, details); err == nil {
+			return details, nil
+		}
+	}
+	details := &PenaltyDetails{Epoch: epoch, Checkpoint: number, Hash: header.Hash(), Penalties: []*PenaltyDetail{}}
+	penalized := common.ExtractAddressFromBytes(header.Penalties)
+	if len(penalized) > 0 {
+		// Measure the turns of the blocks the penalties were decided on
+		previous, _ := c.config.PreviousCheckpoint(number, 1)
+		parent := chain.GetHeader(header.ParentHash, number-1)
+		if parent == nil {
+			return nil, consensus.ErrUnknownAncestor
+		}
+		performance := make(map[common.Address]*MasternodePerformance)
+		if window := number - 1 - previous; window > 0 {
+			report, err := c.MasternodePerformance(chain, parent, window)
+			if err != nil {
+				return nil, err
+			}
+			for _, stat := range report.Masternodes {
+				performance[stat.Address] = stat
+			}
+		}
+		slashed := make(map[common.Address]bool)
+		if chain.Config().IsTIPSlashing(header.Number) {
+			for _, address := range c.slashedMasternodes(chain, header, penalized) {
+				slashed[address] = true
+			}
+		}
+		comebacks := make(map[common.Address]bool)
+		signing := chain.Config().IsTIPSigning(header.Number)
+		if comeback, ok := c.config.PreviousCheckpoint(number, common.LimitPenaltyEpoch+1); signing && ok && comeback > 0 {
+			if comebackHeader := chain.GetHeaderByNumber(comeback); comebackHeader != nil {
+				for _, address := range common.ExtractAddressFromBytes(comebackHeader.Penalties) {
+					comebacks[address] = true
+				}
+			}
+		}
+		for _, address := range penalized {
+			detail := &PenaltyDetail{Address: address, Reasons: []string{}}
+			if stat := performance[address]; stat != nil {
+				detail.Proposed, detail.Missed = stat.Proposed, stat.Missed
+			}
+			// The double signers are slashed among the masternodes the hooks
+			// didn't penalize
+			switch {
+			case slashed[address]:
+				detail.Reasons = append(detail.Reasons, PenaltyDoubleSign)
+			case !signing:
+				detail.Reasons = append(detail.Reasons, PenaltyMissedSigning)
+			default:
+				if detail.Proposed < common.MinimunMinerBlockPerEpoch {
+					detail.Reasons = append(detail.Reasons, PenaltyMissedBlocks)
+				}
+				if comebacks[address] {
+					detail.Reasons = append(detail.Reasons, PenaltyUnsignedComeback)
+				}
+			}
+			details.Penalties = append(details.Penalties, detail)
+		}
+	}
+	if blob, err := json.Marshal(details); err == nil {
+		if err := c.db.Put(penaltyDetailsKey(header.Hash()), blob); err != nil {
+			log.Warn("Failed to record the penalty details", "number", number, "err", err)
+		}
+	}
+	return details, nil
+}
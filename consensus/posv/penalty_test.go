@@ -0,0 +1,85 @@
+package posv
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestPenaltyDetails(t *testing.T) {
+	defer func(tipSigning *big.Int) { common.TIPSigningBlock = tipSigning }(common.TIPSigningBlock)
+	common.TIPSigningBlock = big.NewInt(0)
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	masternodes := make([]common.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		masternodes[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 10, Gap: 5}}
+	genesis := &types.Header{Number: big.NewInt(0), Time: big.NewInt(100), Extra: data}
+	chain := &headerChain{config: config, headers: map[common.Hash]*types.Header{genesis.Hash(): genesis}}
+
+	// The third masternode misses all its turns and is penalized at the checkpoint
+	parent := genesis
+	for n := int64(1); n <= 10; n++ {
+		header := &types.Header{
+			Number:     big.NewInt(n),
+			ParentHash: parent.Hash(),
+			Time:       big.NewInt(100 + 2*n),
+			Extra:      make([]byte, extra.VanityLength+extra.SealLength),
+		}
+		if n == 10 {
+			header.Penalties = common.ExtractAddressToBytes(masternodes[2:])
+		}
+		sealHeader(t, keys[n%2], header)
+		chain.headers[header.Hash()] = header
+		parent = header
+	}
+	db := rawdb.NewMemoryDatabase()
+	engine := New(config.Posv, db)
+
+	details, err := engine.PenaltyDetails(chain, 1)
+	if err != nil {
+		t.Fatalf("failed to detail the penalties: %v", err)
+	}
+	if details.Checkpoint != 10 || details.Hash != parent.Hash() {
+		t.Errorf("checkpoint mismatch: have %d %x, want 10 %x", details.Checkpoint, details.Hash, parent.Hash())
+	}
+	if len(details.Penalties) != 1 {
+		t.Fatalf("penalties mismatch: have %d, want 1", len(details.Penalties))
+	}
+	detail := details.Penalties[0]
+	if detail.Address != masternodes[2] || !reflect.DeepEqual(detail.Reasons, []string{PenaltyMissedBlocks}) {
+		t.Errorf("penalty mismatch: have %x %v, want %x %v", detail.Address, detail.Reasons, masternodes[2], []string{PenaltyMissedBlocks})
+	}
+	if detail.Proposed != 0 || detail.Missed == 0 {
+		t.Errorf("turns mismatch: have %d proposed, %d missed, want none proposed", detail.Proposed, detail.Missed)
+	}
+	// The details are recorded, not worked out again
+	if has, _ := db.Has(penaltyDetailsKey(parent.Hash())); !has {
+		t.Fatalf("penalty details not recorded")
+	}
+	recorded, err := New(config.Posv, db).PenaltyDetails(&headerChain{config: config, headers: map[common.Hash]*types.Header{parent.Hash(): parent}}, 1)
+	if err != nil {
+		t.Fatalf("failed to retrieve the recorded penalties: %v", err)
+	}
+	if !reflect.DeepEqual(recorded, details) {
+		t.Errorf("recorded penalties mismatch: have %+v, want %+v", recorded, details)
+	}
+	if _, err := engine.PenaltyDetails(chain, 0); err == nil {
+		t.Errorf("penalties of the genesis masternodes detailed")
+	}
+}
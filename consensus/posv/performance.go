@@ -0,0 +1,151 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+const (
+	blockTurnsCacheLimit = 9000  // Number of block turns to keep in memory for the performance of the masternodes
+	maxPerformanceWindow = 18000 // Most blocks the performance of the masternodes is measured over
+)
+
+// MasternodePerformance is the performance of a masternode over a window of
+// blocks. A masternode misses its turn when the block of its turn is created by
+// the next masternode instead.
+type MasternodePerformance struct {
+	Address        common.Address `json:"address"`
+	Turns          uint64         `json:"turns"`          // turns proposed or missed
+	Proposed       uint64         `json:"proposed"`       // blocks created
+	Missed         uint64         `json:"missed"`         // turns skipped by the block of a later masternode
+	SuccessRate    float64        `json:"successRate"`    // proposed turns out of all turns
+	AverageLatency float64        `json:"averageLatency"` // mean seconds from the parent to the blocks created
+	MaxLatency     uint64         `json:"maxLatency"`     // most seconds from the parent to a block created
+}
+
+// PerformanceReport is the performance of the masternodes over the blocks from
+// From to To, in ascending order of their addresses.
+type PerformanceReport struct {
+	From        uint64                   `json:"from"`
+	To          uint64                   `json:"to"`
+	Masternodes []*MasternodePerformance `json:"masternodes"`
+}
+
+// blockTurn is the turn a block was created at.
+type blockTurn struct {
+	creator common.Address
+	latency uint64           // seconds from the parent
+	missed  []common.Address // masternodes whose turns the block skipped
+}
+
+// turnOf returns the turn of a block, as the masternodes rotate from the
+// creator of its parent.
+func (c *Posv) turnOf(chain consensus.ChainReader, header, parent *types.Header) (*blockTurn, error) {
+	if turn, ok := c.blockTurns.Get(header.Hash()); ok {
+		return turn.(*blockTurn), nil
+	}
+	creator, err := ecrecover(header, c.signatures)
+	if err != nil {
+		return nil, err
+	}
+	creator = c.MasternodeOf(chain, header.Number.Uint64(), creator)
+	turn := &blockTurn{creator: creator}
+	if header.Time.Cmp(parent.Time) > 0 {
+		turn.latency = header.Time.Uint64() - parent.Time.Uint64()
+	}
+	if parent.Number.Uint64() > 0 {
+		pre, err := ecrecover(parent, c.signatures)
+		if err != nil {
+			return nil, err
+		}
+		pre = c.MasternodeOf(chain, parent.Number.Uint64(), pre)
+		masternodes := c.GetMasternodes(chain, parent)
+		preIndex, curIndex := position(masternodes, pre), position(masternodes, creator)
+		if preIndex >= 0 && curIndex >= 0 {
+			for i := 1; i <= Hop(len(masternodes), preIndex, curIndex); i++ {
+				turn.missed = append(turn.missed, masternodes[(preIndex+i)%len(masternodes)])
+			}
+		}
+	}
+	c.blockTurns.Add(header.Hash(), turn)
+	return turn, nil
+}
+
+// MasternodePerformance measures the performance of the masternodes over the
+// window of blocks ending at the header, an epoch by default. The masternodes
+// of the header are reported even if they had no turn.
+func (c *Posv) MasternodePerformance(chain consensus.ChainReader, header *types.Header, window uint64) (*PerformanceReport, error) {
+	if window == 0 {
+		window = c.config.EpochLength(header.Number.Uint64())
+	}
+	if window > maxPerformanceWindow {
+		return nil, fmt.Errorf("window of %d blocks exceeds the limit of %d", window, maxPerformanceWindow)
+	}
+	report := &PerformanceReport{From: header.Number.Uint64(), To: header.Number.Uint64(), Masternodes: []*MasternodePerformance{}}
+	stats := make(map[common.Address]*MasternodePerformance)
+	stat := func(address common.Address) *MasternodePerformance {
+		if stats[address] == nil {
+			stats[address] = &MasternodePerformance{Address: address}
+		}
+		return stats[address]
+	}
+	for _, address := range c.GetMasternodes(chain, header) {
+		stat(address)
+	}
+	latencies := make(map[common.Address]uint64)
+	for i := uint64(0); i < window && header.Number.Uint64() > 0; i++ {
+		parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil {
+			return nil, consensus.ErrUnknownAncestor
+		}
+		turn, err := c.turnOf(chain, header, parent)
+		if err != nil {
+			return nil, err
+		}
+		creator := stat(turn.creator)
+		creator.Proposed++
+		latencies[turn.creator] += turn.latency
+		if turn.latency > creator.MaxLatency {
+			creator.MaxLatency = turn.latency
+		}
+		for _, address := range turn.missed {
+			stat(address).Missed++
+		}
+		report.From = header.Number.Uint64()
+		header = parent
+	}
+	for address, stat := range stats {
+		stat.Turns = stat.Proposed + stat.Missed
+		if stat.Turns > 0 {
+			stat.SuccessRate = float64(stat.Proposed) / float64(stat.Turns)
+		}
+		if stat.Proposed > 0 {
+			stat.AverageLatency = float64(latencies[address]) / float64(stat.Proposed)
+		}
+		report.Masternodes = append(report.Masternodes, stat)
+	}
+	sort.Slice(report.Masternodes, func(i, j int) bool {
+		return bytes.Compare(report.Masternodes[i].Address[:], report.Masternodes[j].Address[:]) < 0
+	})
+	return report, nil
+}
@@ -0,0 +1,89 @@
+package posv
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestMasternodePerformance(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	masternodes := make([]common.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		masternodes[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 900}}
+	genesis := &types.Header{Number: big.NewInt(0), Time: big.NewInt(100), Extra: data}
+	chain := &headerChain{config: config, headers: map[common.Hash]*types.Header{genesis.Hash(): genesis}}
+
+	// The second masternode misses its turn at block 2, the third one is late
+	creators := []int{0, 2, 0, 1}
+	times := []int64{102, 106, 108, 110}
+	parent := genesis
+	for i, creator := range creators {
+		header := &types.Header{
+			Number:     big.NewInt(int64(i + 1)),
+			ParentHash: parent.Hash(),
+			Time:       big.NewInt(times[i]),
+			Extra:      make([]byte, extra.VanityLength+extra.SealLength),
+		}
+		sealHeader(t, keys[creator], header)
+		chain.headers[header.Hash()] = header
+		parent = header
+	}
+	engine := New(config.Posv, rawdb.NewMemoryDatabase())
+
+	report, err := engine.MasternodePerformance(chain, parent, 0)
+	if err != nil {
+		t.Fatalf("failed to measure the masternodes: %v", err)
+	}
+	if report.From != 1 || report.To != 4 {
+		t.Errorf("window mismatch: have %d-%d, want 1-4", report.From, report.To)
+	}
+	want := map[common.Address]MasternodePerformance{
+		masternodes[0]: {Address: masternodes[0], Turns: 2, Proposed: 2, SuccessRate: 1, AverageLatency: 2, MaxLatency: 2},
+		masternodes[1]: {Address: masternodes[1], Turns: 2, Proposed: 1, Missed: 1, SuccessRate: 0.5, AverageLatency: 2, MaxLatency: 2},
+		masternodes[2]: {Address: masternodes[2], Turns: 1, Proposed: 1, SuccessRate: 1, AverageLatency: 4, MaxLatency: 4},
+	}
+	if len(report.Masternodes) != len(want) {
+		t.Fatalf("masternodes mismatch: have %d, want %d", len(report.Masternodes), len(want))
+	}
+	for _, have := range report.Masternodes {
+		if !reflect.DeepEqual(*have, want[have.Address]) {
+			t.Errorf("performance of %x mismatch: have %+v, want %+v", have.Address, *have, want[have.Address])
+		}
+	}
+	// The window slides back from the requested block
+	report, err = engine.MasternodePerformance(chain, parent, 2)
+	if err != nil {
+		t.Fatalf("failed to measure the masternodes: %v", err)
+	}
+	if report.From != 3 || report.To != 4 {
+		t.Errorf("window mismatch: have %d-%d, want 3-4", report.From, report.To)
+	}
+	for _, have := range report.Masternodes {
+		proposed := uint64(1)
+		if have.Address == masternodes[2] {
+			proposed = 0
+		}
+		if have.Proposed != proposed || have.Turns != proposed {
+			t.Errorf("performance of %x mismatch: have %+v, want %d proposed turns", have.Address, *have, proposed)
+		}
+	}
+	if _, err := engine.MasternodePerformance(chain, parent, maxPerformanceWindow+1); err == nil {
+		t.Errorf("window over the limit accepted")
+	}
+}
@@ -31,17 +31,15 @@ import (
 	"sync"
 	"time"
 
-	"github.com/tomochain/tomochain/tomox/tradingstate"
-	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
-	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
-
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/accounts"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/hexutil"
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/consensus/clique"
+	"github.com/tomochain/tomochain/consensus/exchange"
 	"github.com/tomochain/tomochain/consensus/misc"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/crypto"
@@ -64,42 +62,10 @@ type Masternode struct {
 	Stake   *big.Int
 }
 
-type TradingService interface {
-	GetTradingStateRoot(block *types.Block, author common.Address) (common.Hash, error)
-	GetTradingState(block *types.Block, author common.Address) (*tradingstate.TradingStateDB, error)
-	HasTradingState(block *types.Block, author common.Address) bool
-	GetStateCache() tradingstate.Database
-	GetTriegc() *prque.Prque
-	ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tomoXstatedb *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error)
-	UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB) error
-	IsSDKNode() bool
-	SyncDataToSDKNode(takerOrder *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, trades []map[string]string, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) error
-	RollbackReorgTxMatch(txhash common.Hash) error
-	GetTokenDecimal(chain consensus.ChainContext, statedb *state.StateDB, tokenAddr common.Address) (*big.Int, error)
-}
-
-type LendingService interface {
-	GetLendingStateRoot(block *types.Block, author common.Address) (common.Hash, error)
-	GetLendingState(block *types.Block, author common.Address) (*lendingstate.LendingStateDB, error)
-	HasLendingState(block *types.Block, author common.Address) bool
-	GetStateCache() lendingstate.Database
-	GetTriegc() *prque.Prque
-	ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error)
-	GetCollateralPrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, *big.Int, error)
-	GetMediumTradePriceBeforeEpoch(chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, baseToken common.Address, quoteToken common.Address) (*big.Int, error)
-	ProcessLiquidationData(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (updatedTrades map[common.Hash]*lendingstate.LendingTrade, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades []*lendingstate.LendingTrade, err error)
-	SyncDataToSDKNode(chain consensus.ChainContext, state *state.StateDB, block *types.Block, takerOrderInTx *lendingstate.LendingItem, txHash common.Hash, txMatchTime time.Time, trades []*lendingstate.LendingTrade, rejectedOrders []*lendingstate.LendingItem, dirtyOrderCount *uint64) error
-	UpdateLiquidatedTrade(blockTime uint64, result lendingstate.FinalizedResult, trades map[common.Hash]*lendingstate.LendingTrade) error
-	RollbackLendingData(txhash common.Hash) error
-}
-
 // Posv proof-of-stake-voting protocol constants.
 var (
 	epochLength = uint64(900) // Default number of blocks after which to checkpoint and reset the pending votes
 
-	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for signer vanity
-	extraSeal   = 65 // Fixed number of extra-data suffix bytes reserved for signer seal
-
 	nonceAuthVote = hexutil.MustDecode("0xffffffffffffffff") // Magic nonce number to vote on adding a new signer
 	nonceDropVote = hexutil.MustDecode("0x0000000000000000") // Magic nonce number to vote on removing a signer.
 
@@ -132,11 +98,11 @@ var (
 
 	// errMissingVanity is returned if a block's extra-data section is shorter than
 	// 32 bytes, which is required to store the signer vanity.
-	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+	errMissingVanity = extra.ErrMissingVanity
 
 	// errMissingSignature is returned if a block's extra-data section doesn't seem
 	// to contain a 65 byte secp256k1 signature.
-	errMissingSignature = errors.New("extra-data 65 byte suffix signature missing")
+	errMissingSignature = extra.ErrMissingSeal
 
 	// errExtraSigners is returned if non-checkpoint block contain signer data in
 	// their extra-data fields.
@@ -145,10 +111,14 @@ var (
 	// errInvalidCheckpointSigners is returned if a checkpoint block contains an
 	// invalid list of signers (i.e. non divisible by 20 bytes, or not the correct
 	// ones).
-	errInvalidCheckpointSigners = errors.New("invalid signer list on checkpoint block")
+	errInvalidCheckpointSigners = extra.ErrInvalidMasternodes
 
 	errInvalidCheckpointPenalties = errors.New("invalid penalty list on checkpoint block")
 
+	// errCheckpointMismatch is returned if the masternodes of a checkpoint block
+	// disagree with the ones derived from the validator contract state.
+	errCheckpointMismatch = errors.New("checkpoint masternodes mismatch the validator contract")
+
 	// errInvalidMixDigest is returned if a block's mix digest is non-zero.
 	errInvalidMixDigest = errors.New("non-zero mix digest")
 
@@ -194,7 +164,7 @@ var (
 func sigHash(header *types.Header) (hash common.Hash) {
 	hasher := sha3.NewKeccak256()
 
-	rlp.Encode(hasher, []interface{}{
+	enc := []interface{}{
 		header.ParentHash,
 		header.UncleHash,
 		header.Coinbase,
@@ -207,10 +177,14 @@ func sigHash(header *types.Header) (hash common.Hash) {
 		header.GasLimit,
 		header.GasUsed,
 		header.Time,
-		header.Extra[:len(header.Extra)-65], // Yes, this will panic if extra is too short
+		header.Extra[:len(header.Extra)-extra.SealLength], // Yes, this will panic if extra is too short
 		header.MixDigest,
 		header.Nonce,
-	})
+	}
+	if header.BaseFee != nil {
+		enc = append(enc, header.BaseFee)
+	}
+	rlp.Encode(hasher, enc)
 	hasher.Sum(hash[:0])
 	return hash
 }
@@ -227,10 +201,10 @@ func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, er
 		return address.(common.Address), nil
 	}
 	// Retrieve the signature from the header extra-data
-	if len(header.Extra) < extraSeal {
-		return common.Address{}, errMissingSignature
+	signature, err := extra.Seal(header.Extra)
+	if err != nil {
+		return common.Address{}, err
 	}
-	signature := header.Extra[len(header.Extra)-extraSeal:]
 
 	// Recover the public key and the Ethereum address
 	pubkey, err := crypto.Ecrecover(sigHash(header).Bytes(), signature)
@@ -254,21 +228,31 @@ type Posv struct {
 	signatures          *lru.ARCCache // Signatures of recent blocks to speed up mining
 	validatorSignatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 	verifiedHeaders     *lru.ARCCache
+	signedHeaders       *signedHeaders          // Headers signed by the masternodes, to spot double signs
+	blockTurns          *lru.ARCCache           // Turns of recent blocks to speed up measuring the masternodes
+	shuffledProposers   *lru.ARCCache           // Order of the proposers of recent checkpoints
+	epochKeys           *lru.ARCCache           // Signing keys of the masternodes of recent epochs
+	finality            *finality               // Votes of the masternodes finalizing recent blocks
 	proposals           map[common.Address]bool // Current list of proposals we are pushing
+	now                 func() time.Time        // Source of the current time, mocked by the simulations
 
 	signer common.Address  // Ethereum address of the signing key
 	signFn clique.SignerFn // Signer function to authorize hashes with
 	lock   sync.RWMutex    // Protects the signer fields
 
+	exchange.Services // TomoX services processing the trading and lending transactions
+
 	BlockSigners               *lru.Cache
 	HookReward                 func(chain consensus.ChainReader, state *state.StateDB, parentState *state.StateDB, header *types.Header) (error, map[string]interface{})
 	HookPenalty                func(chain consensus.ChainReader, blockNumberEpoc uint64) ([]common.Address, error)
 	HookPenaltyTIPSigning      func(chain consensus.ChainReader, header *types.Header, candidate []common.Address) ([]common.Address, error)
 	HookValidator              func(header *types.Header, signers []common.Address) ([]byte, error)
 	HookVerifyMNs              func(header *types.Header, signers []common.Address) error
-	GetTomoXService            func() TradingService
-	GetLendingService          func() LendingService
 	HookGetSignersFromContract func(blockHash common.Hash) ([]common.Address, error)
+	HookEvidence               func(evidence *Evidence) error
+	HookSigningKeys            func(chain consensus.ChainReader, gap *types.Header) (map[common.Address]common.Address, error)
+
+	CrossValidation bool // Whether to check the checkpoints against the validator contract state
 }
 
 // New creates a PoSV proof-of-stake-voting consensus engine with the initial
@@ -285,6 +269,9 @@ func New(config *params.PosvConfig, db ethdb.Database) *Posv {
 	signatures, _ := lru.NewARC(inmemorySnapshots)
 	validatorSignatures, _ := lru.NewARC(inmemorySnapshots)
 	verifiedHeaders, _ := lru.NewARC(inmemorySnapshots)
+	blockTurns, _ := lru.NewARC(blockTurnsCacheLimit)
+	shuffledProposers, _ := lru.NewARC(proposersCacheLimit)
+	epochKeys, _ := lru.NewARC(signingKeysCacheLimit)
 	return &Posv{
 		config:              &conf,
 		db:                  db,
@@ -293,10 +280,22 @@ func New(config *params.PosvConfig, db ethdb.Database) *Posv {
 		signatures:          signatures,
 		verifiedHeaders:     verifiedHeaders,
 		validatorSignatures: validatorSignatures,
+		signedHeaders:       newSignedHeaders(),
+		blockTurns:          blockTurns,
+		shuffledProposers:   shuffledProposers,
+		epochKeys:           epochKeys,
+		finality:            newFinality(),
 		proposals:           make(map[common.Address]bool),
+		now:                 time.Now,
 	}
 }
 
+// SetClock replaces the source of the current time the headers are stamped and
+// checked against, for simulations to run on a mock clock.
+func (c *Posv) SetClock(now func() time.Time) {
+	c.now = now
+}
+
 // Author implements consensus.Engine, returning the Ethereum address recovered
 // from the signature in the header's extra-data section.
 func (c *Posv) Author(header *types.Header) (common.Address, error) {
@@ -358,12 +357,12 @@ func (c *Posv) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 			return consensus.ErrNoValidatorSignature
 		}
 		// Don't waste time checking blocks from the future
-		if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		if header.Time.Cmp(big.NewInt(c.now().Unix())) > 0 {
 			return consensus.ErrFutureBlock
 		}
 	}
 	// Checkpoint blocks need to enforce zero beneficiary
-	checkpoint := (number % c.config.Epoch) == 0
+	checkpoint := c.config.IsCheckpoint(number)
 	if checkpoint && header.Coinbase != (common.Address{}) {
 		return errInvalidCheckpointBeneficiary
 	}
@@ -375,21 +374,18 @@ func (c *Posv) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
 		return errInvalidCheckpointVote
 	}
-	// Check that the extra-data contains both the vanity and signature
-	if len(header.Extra) < extraVanity {
-		return errMissingVanity
+	// Check that the extra-data contains both the vanity and signature, and a
+	// signer list on checkpoint, but none otherwise
+	decoded, err := extra.DecodeHeader(header)
+	if err == extra.ErrInvalidMasternodes && !checkpoint {
+		return errExtraSigners
 	}
-	if len(header.Extra) < extraVanity+extraSeal {
-		return errMissingSignature
+	if err != nil {
+		return err
 	}
-	// Ensure that the extra-data contains a signer list on checkpoint, but none otherwise
-	signersBytes := len(header.Extra) - extraVanity - extraSeal
-	if !checkpoint && signersBytes != 0 {
+	if !checkpoint && len(decoded.Masternodes) != 0 {
 		return errExtraSigners
 	}
-	if checkpoint && signersBytes%common.AddressLength != 0 {
-		return errInvalidCheckpointSigners
-	}
 	// Ensure that the mix digest is zero as we don't have fork protection currently
 	if header.MixDigest != (common.Hash{}) {
 		return errInvalidMixDigest
@@ -429,10 +425,21 @@ func (c *Posv) verifyCascadingFields(chain consensus.ChainReader, header *types.
 	if parent.Time.Uint64()+c.config.Period > header.Time.Uint64() {
 		return ErrInvalidTimestamp
 	}
+	// Verify the header's EIP-1559 attributes.
+	if !chain.Config().IsLondon(header.Number) {
+		if header.BaseFee != nil {
+			return fmt.Errorf("invalid baseFee before fork: have %d, want <nil>", header.BaseFee)
+		}
+	} else if err := misc.VerifyEip1559Header(chain.Config(), parent, header); err != nil {
+		return err
+	}
 
-	if number%c.config.Epoch != 0 {
+	if !c.config.IsCheckpoint(number) {
 		return c.verifySeal(chain, header, parents, fullVerify)
 	}
+	if err := c.verifyProposerSeed(chain, header, parent, parents); err != nil {
+		return err
+	}
 
 	/*
 		BUG: snapshot returns wrong signers sometimes
@@ -445,7 +452,7 @@ func (c *Posv) verifyCascadingFields(chain consensus.ChainReader, header *types.
 	}
 
 	signers := snap.GetSigners()
-	err = c.checkSignersOnCheckpoint(chain, header, signers)
+	err = c.checkSignersOnCheckpoint(chain, header, snap, signers)
 	if err == nil {
 		return c.verifySeal(chain, header, parents, fullVerify)
 	}
@@ -454,7 +461,7 @@ func (c *Posv) verifyCascadingFields(chain consensus.ChainReader, header *types.
 	if err != nil {
 		return err
 	}
-	err = c.checkSignersOnCheckpoint(chain, header, signers)
+	err = c.checkSignersOnCheckpoint(chain, header, snap, signers)
 	if err == nil {
 		return c.verifySeal(chain, header, parents, fullVerify)
 	}
@@ -462,7 +469,7 @@ func (c *Posv) verifyCascadingFields(chain consensus.ChainReader, header *types.
 	return err
 }
 
-func (c *Posv) checkSignersOnCheckpoint(chain consensus.ChainReader, header *types.Header, signers []common.Address) error {
+func (c *Posv) checkSignersOnCheckpoint(chain consensus.ChainReader, header *types.Header, snap *Snapshot, signers []common.Address) error {
 	number := header.Number.Uint64()
 	// ignore signerCheck at checkpoint block 14458500 due to wrong snapshot at gap 14458495
 	if number == common.IgnoreSignerCheckBlock {
@@ -482,19 +489,26 @@ func (c *Posv) checkSignersOnCheckpoint(chain consensus.ChainReader, header *typ
 		for _, address := range penPenalties {
 			log.Debug("Penalty Info", "address", address, "number", number)
 		}
-		bytePenalties := common.ExtractAddressToBytes(penPenalties)
-		if !bytes.Equal(header.Penalties, bytePenalties) {
-			return errInvalidCheckpointPenalties
-		}
 	}
 	signers = common.RemoveItemFromArray(signers, penPenalties)
 	for i := 1; i <= common.LimitPenaltyEpoch; i++ {
-		if number > uint64(i)*c.config.Epoch {
-			signers = RemovePenaltiesFromBlock(chain, signers, number-uint64(i)*c.config.Epoch)
+		if checkpoint, ok := c.config.PreviousCheckpoint(number, uint64(i)); ok && checkpoint > 0 {
+			signers = common.RemoveItemFromArray(signers, c.penalties(chain, snap, checkpoint))
 		}
 	}
-	extraSuffix := len(header.Extra) - extraSeal
-	masternodesFromCheckpointHeader := common.ExtractAddressFromBytes(header.Extra[extraVanity:extraSuffix])
+	slashing := chain.Config().IsTIPSlashing(header.Number)
+	if slashing {
+		slashed := c.slashedMasternodes(chain, header, signers)
+		signers = common.RemoveItemFromArray(signers, slashed)
+		penPenalties = append(penPenalties, slashed...)
+	}
+	if c.HookPenalty != nil || c.HookPenaltyTIPSigning != nil || slashing {
+		bytePenalties := common.ExtractAddressToBytes(penPenalties)
+		if !bytes.Equal(header.Penalties, bytePenalties) {
+			return errInvalidCheckpointPenalties
+		}
+	}
+	masternodesFromCheckpointHeader := GetMasternodesFromCheckpointHeader(header)
 	validSigners := compareSignersLists(masternodesFromCheckpointHeader, signers)
 
 	if !validSigners {
@@ -551,16 +565,17 @@ func position(list []common.Address, x common.Address) int {
 
 func (c *Posv) GetMasternodes(chain consensus.ChainReader, header *types.Header) []common.Address {
 	n := header.Number.Uint64()
-	e := c.config.Epoch
-	switch {
-	case n%e == 0:
-		return c.GetMasternodesFromCheckpointHeader(header, n, e)
-	case n%e != 0:
-		h := chain.GetHeaderByNumber(n - (n % e))
-		return c.GetMasternodesFromCheckpointHeader(h, n, e)
-	default:
-		return []common.Address{}
+	e := c.config.EpochLength(n)
+	h := header
+	if !c.config.IsCheckpoint(n) {
+		h = chain.GetHeaderByNumber(c.config.Checkpoint(n))
+	}
+	masternodes := c.GetMasternodesFromCheckpointHeader(h, n, e)
+	if h == nil {
+		return masternodes
 	}
+	// The masternodes propose blocks in the order shuffled by the checkpoint
+	return c.proposers(chain, h, masternodes)
 }
 
 func (c *Posv) GetPeriod() uint64 { return c.config.Period }
@@ -595,9 +610,11 @@ func (c *Posv) YourTurn(chain consensus.ChainReader, parent *types.Header, signe
 		if err != nil {
 			return 0, 0, 0, false, err
 		}
+		pre = c.MasternodeOf(chain, parent.Number.Uint64(), pre)
 		preIndex = position(masternodes, pre)
 	}
-	curIndex := position(masternodes, signer)
+	masternode, _ := c.masternodeOf(chain, parent.Number.Uint64()+1, signer)
+	curIndex := position(masternodes, masternode)
 	if signer == c.signer {
 		log.Debug("Masternodes cycle info", "number of masternodes", len(masternodes), "previous", pre, "position", preIndex, "current", signer, "position", curIndex)
 	}
@@ -623,9 +640,8 @@ func (c *Posv) snapshot(chain consensus.ChainReader, number uint64, hash common.
 			snap = s.(*Snapshot)
 			break
 		}
-		// If an on-disk checkpoint snapshot can be found, use that
-		// checkpoint snapshot = checkpoint - gap
-		if (number+c.config.Gap)%c.config.Epoch == 0 {
+		// If an on-disk gap or checkpoint snapshot can be found, use that
+		if c.config.IsGap(number) || c.config.IsCheckpoint(number) {
 			if s, err := loadSnapshot(c.config, c.signatures, c.db, hash); err == nil {
 				log.Trace("Loaded voting snapshot form disk", "number", number, "hash", hash)
 				snap = s
@@ -638,10 +654,7 @@ func (c *Posv) snapshot(chain consensus.ChainReader, number uint64, hash common.
 			if err := c.VerifyHeader(chain, genesis, true); err != nil {
 				return nil, err
 			}
-			signers := make([]common.Address, (len(genesis.Extra)-extraVanity-extraSeal)/common.AddressLength)
-			for i := 0; i < len(signers); i++ {
-				copy(signers[i][:], genesis.Extra[extraVanity+i*common.AddressLength:])
-			}
+			signers := GetMasternodesFromCheckpointHeader(genesis)
 			snap = newSnapshot(c.config, c.signatures, 0, genesis.Hash(), signers)
 			if err := snap.store(c.db); err != nil {
 				return nil, err
@@ -649,6 +662,16 @@ func (c *Posv) snapshot(chain consensus.ChainReader, number uint64, hash common.
 			log.Trace("Stored genesis voting snapshot to disk")
 			break
 		}
+		// If we're at an epoch checkpoint without a parent (light client synced from
+		// a trusted checkpoint), consider the checkpoint trusted and snapshot it
+		if c.config.IsCheckpoint(number) && len(parents) == 0 {
+			if checkpoint := chain.GetHeader(hash, number); checkpoint != nil && chain.GetHeader(checkpoint.ParentHash, number-1) == nil {
+				signers := GetMasternodesFromCheckpointHeader(checkpoint)
+				snap = newSnapshot(c.config, c.signatures, number, hash, signers)
+				log.Info("Snapshotted trusted checkpoint without ancestors", "number", number, "hash", hash, "masternodes", len(signers))
+				break
+			}
+		}
 		// No snapshot for this header, gather the header and move backward
 		var header *types.Header
 		if len(parents) > 0 {
@@ -678,8 +701,8 @@ func (c *Posv) snapshot(chain consensus.ChainReader, number uint64, hash common.
 	}
 	c.recents.Add(snap.Hash, snap)
 
-	// If we've generated a new checkpoint snapshot, save to disk
-	if (snap.Number+c.config.Gap)%c.config.Epoch == 0 {
+	// If we've generated a new gap or checkpoint snapshot, save to disk
+	if c.config.IsGap(snap.Number) || c.config.IsCheckpoint(snap.Number) {
 		if err = snap.store(c.db); err != nil {
 			return nil, err
 		}
@@ -722,7 +745,7 @@ func (c *Posv) verifySeal(chain consensus.ChainReader, header *types.Header, par
 	}
 
 	// Resolve the authorization key and check against signers
-	creator, err := ecrecover(header, c.signatures)
+	signer, err := ecrecover(header, c.signatures)
 	if err != nil {
 		return err
 	}
@@ -732,14 +755,19 @@ func (c *Posv) verifySeal(chain consensus.ChainReader, header *types.Header, par
 	} else {
 		parent = chain.GetHeader(header.ParentHash, number-1)
 	}
-	difficulty := c.calcDifficulty(chain, parent, creator)
-	log.Debug("verify seal block", "number", header.Number, "hash", header.Hash(), "block difficulty", header.Difficulty, "calc difficulty", difficulty, "creator", creator)
+	difficulty := c.calcDifficulty(chain, parent, signer)
+	log.Debug("verify seal block", "number", header.Number, "hash", header.Hash(), "block difficulty", header.Difficulty, "calc difficulty", difficulty, "creator", signer)
 	// Ensure that the block's difficulty is meaningful (may not be correct at this point)
 	if number > 0 {
 		if header.Difficulty.Int64() != difficulty.Int64() {
 			return errInvalidDifficulty
 		}
 	}
+	// Resolve the masternode the key seals for, in case its key was rotated
+	creator, err := c.masternodeOf(chain, number, signer)
+	if err != nil {
+		return err
+	}
 	masternodes := c.GetMasternodes(chain, header)
 	mstring := []string{}
 	for _, m := range masternodes {
@@ -764,12 +792,12 @@ func (c *Posv) verifySeal(chain consensus.ChainReader, header *types.Header, par
 	}
 	if len(masternodes) > 1 {
 		for seen, recent := range snap.Recents {
-			if recent == creator {
+			if recent == signer {
 				// Signer is among recents, only fail if the current block doesn't shift it out
 				// There is only case that we don't allow signer to create two continuous blocks.
 				if limit := uint64(2); seen > number-limit {
 					// Only take into account the non-epoch blocks
-					if number%c.config.Epoch != 0 {
+					if !c.config.IsCheckpoint(number) {
 						return errUnauthorized
 					}
 				}
@@ -784,6 +812,9 @@ func (c *Posv) verifySeal(chain consensus.ChainReader, header *types.Header, par
 		if err != nil {
 			return err
 		}
+		if validator, err = c.masternodeOf(chain, number, validator); err != nil {
+			return err
+		}
 
 		// verify validator
 		assignedValidator, err := c.GetValidator(creator, chain, header)
@@ -795,22 +826,21 @@ func (c *Posv) verifySeal(chain consensus.ChainReader, header *types.Header, par
 			return errFailedDoubleValidation
 		}
 	}
+	if chain.Config().IsTIPSlashing(header.Number) {
+		c.recordSignedHeader(signer, header)
+	}
 	return nil
 }
 
 func (c *Posv) GetValidator(creator common.Address, chain consensus.ChainReader, header *types.Header) (common.Address, error) {
-	epoch := c.config.Epoch
 	no := header.Number.Uint64()
-	cpNo := no
-	if no%epoch != 0 {
-		cpNo = no - (no % epoch)
-	}
+	cpNo := c.config.Checkpoint(no)
 	if cpNo == 0 {
 		return common.Address{}, nil
 	}
 	cpHeader := chain.GetHeaderByNumber(cpNo)
 	if cpHeader == nil {
-		if no%epoch == 0 {
+		if cpNo == no {
 			cpHeader = header
 		} else {
 			return common.Address{}, fmt.Errorf("couldn't find checkpoint header")
@@ -836,7 +866,7 @@ func (c *Posv) Prepare(chain consensus.ChainReader, header *types.Header) error
 	if err != nil {
 		return err
 	}
-	if number%c.config.Epoch != 0 {
+	if !c.config.IsCheckpoint(number) {
 		c.lock.RLock()
 
 		// Gather all the proposals that make sense voting on
@@ -865,12 +895,10 @@ func (c *Posv) Prepare(chain consensus.ChainReader, header *types.Header) error
 	header.Difficulty = c.calcDifficulty(chain, parent, c.signer)
 	log.Debug("CalcDifficulty ", "number", header.Number, "difficulty", header.Difficulty)
 	// Ensure the extra data has all it's components
-	if len(header.Extra) < extraVanity {
-		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
-	}
-	header.Extra = header.Extra[:extraVanity]
+	headerExtra := &extra.Extra{Version: extra.VersionAt(header.Number)}
+	copy(headerExtra.Vanity[:], header.Extra)
 	masternodes := snap.GetSigners()
-	if number >= c.config.Epoch && number%c.config.Epoch == 0 {
+	if number >= c.config.Epoch && c.config.IsCheckpoint(number) {
 		if c.HookPenalty != nil || c.HookPenaltyTIPSigning != nil {
 			var penMasternodes []common.Address = nil
 			var err error = nil
@@ -893,12 +921,21 @@ func (c *Posv) Prepare(chain consensus.ChainReader, header *types.Header) error
 		}
 		// Prevent penalized masternode(s) within 4 recent epochs
 		for i := 1; i <= common.LimitPenaltyEpoch; i++ {
-			if number > uint64(i)*c.config.Epoch {
-				masternodes = RemovePenaltiesFromBlock(chain, masternodes, number-uint64(i)*c.config.Epoch)
+			if checkpoint, ok := c.config.PreviousCheckpoint(number, uint64(i)); ok && checkpoint > 0 {
+				masternodes = common.RemoveItemFromArray(masternodes, c.penalties(chain, snap, checkpoint))
+			}
+		}
+		// Penalize the masternode(s) proven to have signed two blocks of the same number
+		if chain.Config().IsTIPSlashing(header.Number) {
+			if slashed := c.slashedMasternodes(chain, header, masternodes); len(slashed) > 0 {
+				masternodes = common.RemoveItemFromArray(masternodes, slashed)
+				penalties := append(common.ExtractAddressFromBytes(header.Penalties), slashed...)
+				header.Penalties = common.ExtractAddressToBytes(penalties)
 			}
 		}
-		for _, masternode := range masternodes {
-			header.Extra = append(header.Extra, masternode[:]...)
+		headerExtra.Masternodes = masternodes
+		if randomProposers(chain, header.Number) {
+			headerExtra.Commitments = []common.Hash{c.proposerSeed(chain, header, parent, nil)}
 		}
 		if c.HookValidator != nil {
 			validators, err := c.HookValidator(header, masternodes)
@@ -908,7 +945,9 @@ func (c *Posv) Prepare(chain consensus.ChainReader, header *types.Header) error
 			header.Validators = validators
 		}
 	}
-	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+	if header.Extra, err = headerExtra.Encode(); err != nil {
+		return err
+	}
 
 	// Mix digest is reserved for now, set to empty
 	header.MixDigest = common.Hash{}
@@ -916,8 +955,8 @@ func (c *Posv) Prepare(chain consensus.ChainReader, header *types.Header) error
 	// Ensure the timestamp has the correct delay
 
 	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(c.config.Period))
-	if header.Time.Int64() < time.Now().Unix() {
-		header.Time = big.NewInt(time.Now().Unix())
+	if now := c.now().Unix(); header.Time.Int64() < now {
+		header.Time = big.NewInt(now)
 	}
 	return nil
 }
@@ -940,6 +979,11 @@ func (c *Posv) UpdateMasternodes(chain consensus.ChainReader, header *types.Head
 		nm = append(nm, n.Address.String())
 	}
 	c.recents.Add(snap.Hash, snap)
+
+	// Persist the new set, for restarts not to snapshot the previous one
+	if err := snap.store(c.db); err != nil {
+		return err
+	}
 	log.Info("New set of masternodes has been updated to snapshot", "number", snap.Number, "hash", snap.Hash, "new masternodes", nm)
 	return nil
 }
@@ -999,7 +1043,7 @@ func (c *Posv) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 	}
 	// For 0-period chains, refuse to seal empty blocks (no reward but would spin sealing)
 	// checkpoint blocks have no tx
-	if c.config.Period == 0 && len(block.Transactions()) == 0 && number%c.config.Epoch != 0 {
+	if c.config.Period == 0 && len(block.Transactions()) == 0 && !c.config.IsCheckpoint(number) {
 		return nil, errWaitTransactions
 	}
 	// Don't hold the signer fields for the entire sealing procedure
@@ -1012,11 +1056,15 @@ func (c *Posv) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 	if err != nil {
 		return nil, err
 	}
+	masternode, err := c.masternodeOf(chain, number, signer)
+	if err != nil {
+		return nil, err
+	}
 	masternodes := c.GetMasternodes(chain, header)
-	if _, authorized := snap.Signers[signer]; !authorized {
+	if _, authorized := snap.Signers[masternode]; !authorized {
 		valid := false
 		for _, m := range masternodes {
-			if m == signer {
+			if m == masternode {
 				valid = true
 				break
 			}
@@ -1034,7 +1082,7 @@ func (c *Posv) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 				// There is only case that we don't allow signer to create two continuous blocks.
 				if limit := uint64(2); number < limit || seen > number-limit {
 					// Only take into account the non-epoch blocks
-					if number%c.config.Epoch != 0 {
+					if !c.config.IsCheckpoint(number) {
 						log.Info("Signed recently, must wait for others ", "len(masternodes)", len(masternodes), "number", number, "limit", limit, "seen", seen, "recent", recent.String(), "snap.Recents", snap.Recents)
 						<-stop
 						return nil, nil
@@ -1053,12 +1101,12 @@ func (c *Posv) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 	if err != nil {
 		return nil, err
 	}
-	copy(header.Extra[len(header.Extra)-extraSeal:], sighash)
-	m2, err := c.GetValidator(signer, chain, header)
+	copy(header.Extra[len(header.Extra)-extra.SealLength:], sighash)
+	m2, err := c.GetValidator(masternode, chain, header)
 	if err != nil {
 		return nil, fmt.Errorf("can't get block validator: %v", err)
 	}
-	if m2 == signer {
+	if m2 == masternode {
 		header.Validator = sighash
 	}
 	return block.WithSeal(header), nil
@@ -1102,7 +1150,7 @@ func (c *Posv) RecoverValidator(header *types.Header) (common.Address, error) {
 	}
 	// Retrieve the signature from the header.Validator
 	// len equals 65 bytes
-	if len(header.Validator) != extraSeal {
+	if len(header.Validator) != extra.SealLength {
 		return common.Address{}, consensus.ErrFailValidatorSignature
 	}
 	// Recover the public key and the Ethereum address
@@ -1123,12 +1171,7 @@ func (c *Posv) GetMasternodesFromCheckpointHeader(preCheckpointHeader *types.Hea
 		log.Info("Previous checkpoint's header is empty", "block number", n, "epoch", e)
 		return []common.Address{}
 	}
-	masternodes := make([]common.Address, (len(preCheckpointHeader.Extra)-extraVanity-extraSeal)/common.AddressLength)
-	for i := 0; i < len(masternodes); i++ {
-		copy(masternodes[i][:], preCheckpointHeader.Extra[extraVanity+i*common.AddressLength:])
-	}
-
-	return masternodes
+	return GetMasternodesFromCheckpointHeader(preCheckpointHeader)
 }
 
 func (c *Posv) CacheData(header *types.Header, txs []*types.Transaction, receipts []*types.Receipt) []*types.Transaction {
@@ -1177,6 +1220,20 @@ func (c *Posv) GetDb() ethdb.Database {
 	return c.db
 }
 
+// penalties returns the masternodes penalized by a recent checkpoint, as
+// recorded by the snapshot, or by the checkpoint header if the snapshot misses
+// it.
+func (c *Posv) penalties(chain consensus.ChainReader, snap *Snapshot, checkpoint uint64) []common.Address {
+	if penalties, ok := snap.Penalties[checkpoint]; ok {
+		return penalties
+	}
+	header := chain.GetHeaderByNumber(checkpoint)
+	if header == nil {
+		return nil
+	}
+	return common.ExtractAddressFromBytes(header.Penalties)
+}
+
 // Extract validators from byte array.
 func RemovePenaltiesFromBlock(chain consensus.ChainReader, masternodes []common.Address, epochNumber uint64) []common.Address {
 	if epochNumber <= 0 {
@@ -1194,16 +1251,17 @@ func RemovePenaltiesFromBlock(chain consensus.ChainReader, masternodes []common.
 
 // Get masternodes address from checkpoint Header.
 func GetMasternodesFromCheckpointHeader(checkpointHeader *types.Header) []common.Address {
-	masternodes := make([]common.Address, (len(checkpointHeader.Extra)-extraVanity-extraSeal)/common.AddressLength)
-	for i := 0; i < len(masternodes); i++ {
-		copy(masternodes[i][:], checkpointHeader.Extra[extraVanity+i*common.AddressLength:])
+	decoded, err := extra.DecodeHeader(checkpointHeader)
+	if err != nil {
+		log.Error("Failed to decode checkpoint header extra-data", "number", checkpointHeader.Number, "err", err)
+		return []common.Address{}
 	}
-	return masternodes
+	return decoded.Masternodes
 }
 
 // Get m2 list from checkpoint block.
 func GetM1M2FromCheckpointHeader(checkpointHeader *types.Header, currentHeader *types.Header, config *params.ChainConfig) (map[common.Address]common.Address, error) {
-	if checkpointHeader.Number.Uint64()%common.EpocBlockRandomize != 0 {
+	if config.Posv == nil || !config.Posv.IsCheckpoint(checkpointHeader.Number.Uint64()) {
 		return nil, errors.New("This block is not checkpoint block epoc.")
 	}
 	// Get signers from this block.
@@ -1226,7 +1284,7 @@ func getM1M2(masternodes []common.Address, validators []int64, currentHeader *ty
 	if maxMNs > 0 {
 		isForked := config.IsTIPRandomize(currentHeader.Number)
 		if isForked {
-			moveM2 = ((currentHeader.Number.Uint64() % config.Posv.Epoch) / uint64(maxMNs)) % uint64(maxMNs)
+			moveM2 = (config.Posv.EpochOffset(currentHeader.Number.Uint64()) / uint64(maxMNs)) % uint64(maxMNs)
 		}
 		for i, m1 := range masternodes {
 			m2Index := uint64(validators[i] % int64(maxMNs))
@@ -1296,7 +1354,7 @@ func (c *Posv) CheckMNTurn(chain consensus.ChainReader, parent *types.Header, si
 func (c *Posv) GetSignersFromContract(chain consensus.ChainReader, checkpointHeader *types.Header) ([]common.Address, error) {
 	startGapBlockHeader := checkpointHeader
 	number := checkpointHeader.Number.Uint64()
-	for step := uint64(1); step <= chain.Config().Posv.Gap; step++ {
+	for step := uint64(1); step <= c.config.GapLength(number-1); step++ {
 		startGapBlockHeader = chain.GetHeader(startGapBlockHeader.ParentHash, number-step)
 	}
 	signers, err := c.HookGetSignersFromContract(startGapBlockHeader.Hash())
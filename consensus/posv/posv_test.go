@@ -3,10 +3,15 @@ package posv
 import (
 	"fmt"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
 	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/params"
 )
 
@@ -82,3 +87,156 @@ func TestCompareSignersLists(t *testing.T) {
 		t.Error("Failed with list has only one signer")
 	}
 }
+
+// headerChain is a consensus.ChainReader over a set of headers, without blocks.
+type headerChain struct {
+	config  *params.ChainConfig
+	headers map[common.Hash]*types.Header
+}
+
+func (hc *headerChain) Config() *params.ChainConfig  { return hc.config }
+func (hc *headerChain) CurrentHeader() *types.Header { return nil }
+func (hc *headerChain) GetHeaderByNumber(number uint64) *types.Header {
+	for _, header := range hc.headers {
+		if header.Number.Uint64() == number {
+			return header
+		}
+	}
+	return nil
+}
+func (hc *headerChain) GetHeaderByHash(hash common.Hash) *types.Header        { return hc.headers[hash] }
+func (hc *headerChain) GetBlock(hash common.Hash, number uint64) *types.Block { return nil }
+func (hc *headerChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := hc.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// Tests that an epoch checkpoint without ancestors, as a light client synced
+// from a trusted checkpoint has, is snapshotted from its own masternode list.
+func TestSnapshotTrustedCheckpoint(t *testing.T) {
+	masternodes := []common.Address{{0x01}, {0x02}, {0x03}}
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 900}}
+	engine := New(config.Posv, rawdb.NewMemoryDatabase())
+
+	checkpoint := &types.Header{Number: big.NewInt(1800), ParentHash: common.Hash{0xff}, Extra: data}
+	chain := &headerChain{config: config, headers: map[common.Hash]*types.Header{checkpoint.Hash(): checkpoint}}
+
+	snap, err := engine.snapshot(chain, 1800, checkpoint.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to snapshot trusted checkpoint: %v", err)
+	}
+	if signers := snap.GetSigners(); !reflect.DeepEqual(signers, masternodes) {
+		t.Errorf("signers mismatch: have %x, want %x", signers, masternodes)
+	}
+	// A checkpoint with a known parent is not trusted blindly
+	parent := &types.Header{Number: big.NewInt(2699), ParentHash: common.Hash{0xff}}
+	linked := &types.Header{Number: big.NewInt(2700), ParentHash: parent.Hash(), Extra: data}
+	chain.headers[parent.Hash()], chain.headers[linked.Hash()] = parent, linked
+
+	if _, err := engine.snapshot(chain, 2700, linked.Hash(), nil); err != consensus.ErrUnknownAncestor {
+		t.Errorf("linked checkpoint error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+}
+
+// Tests that the masternodes of a block are read from the checkpoint of its
+// epoch across a change of the length of the epochs.
+func TestGetMasternodesAcrossEpochFork(t *testing.T) {
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 900, Gap: 450, EpochForks: []params.PosvEpochFork{
+		{Block: big.NewInt(1800), Epoch: 300, Gap: 100},
+	}}}
+	engine := New(config.Posv, rawdb.NewMemoryDatabase())
+	chain := &headerChain{config: config, headers: map[common.Hash]*types.Header{}}
+
+	sets := map[int64][]common.Address{
+		900:  {{0x01}, {0x02}},
+		1800: {{0x03}, {0x04}},
+		2100: {{0x05}, {0x06}},
+	}
+	for number, masternodes := range sets {
+		data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+		if err != nil {
+			t.Fatalf("failed to encode extra-data: %v", err)
+		}
+		checkpoint := &types.Header{Number: big.NewInt(number), ParentHash: common.Hash{0xff}, Extra: data}
+		chain.headers[checkpoint.Hash()] = checkpoint
+	}
+	for number, checkpoint := range map[int64]int64{1799: 900, 1800: 1800, 2099: 1800, 2100: 2100, 2399: 2100} {
+		header := &types.Header{Number: big.NewInt(number)}
+		if number == checkpoint {
+			header = chain.GetHeaderByNumber(uint64(number))
+		}
+		if masternodes := engine.GetMasternodes(chain, header); !reflect.DeepEqual(masternodes, sets[checkpoint]) {
+			t.Errorf("block %d: masternodes mismatch: have %x, want %x", number, masternodes, sets[checkpoint])
+		}
+	}
+	// Checkpoints of the new epochs are snapshotted as such
+	checkpoint := chain.GetHeaderByNumber(2100)
+	snap, err := engine.snapshot(chain, 2100, checkpoint.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to snapshot checkpoint after the epoch fork: %v", err)
+	}
+	if signers := snap.GetSigners(); !reflect.DeepEqual(signers, sets[2100]) {
+		t.Errorf("signers mismatch: have %x, want %x", signers, sets[2100])
+	}
+}
+
+// Tests that the snapshots record the penalties of the recent checkpoints,
+// persisting them along with the signers.
+func TestSnapshotPenalties(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 10, Gap: 5}}
+	db := rawdb.NewMemoryDatabase()
+	engine := New(config.Posv, db)
+	chain := &headerChain{config: config, headers: map[common.Hash]*types.Header{}}
+
+	var headers []*types.Header
+	for number := int64(1); number <= 60; number++ {
+		header := &types.Header{
+			Number:     big.NewInt(number),
+			Time:       big.NewInt(number),
+			Difficulty: big.NewInt(1),
+			Extra:      make([]byte, extra.VanityLength+extra.SealLength),
+		}
+		if number%10 == 0 {
+			header.Penalties = common.ExtractAddressToBytes([]common.Address{{byte(number)}})
+		}
+		sealHeader(t, key, header)
+		headers = append(headers, header)
+		chain.headers[header.Hash()] = header
+	}
+	genesis := newSnapshot(engine.config, engine.signatures, 0, common.Hash{}, []common.Address{crypto.PubkeyToAddress(key.PublicKey)})
+	snap, err := genesis.apply(headers)
+	if err != nil {
+		t.Fatalf("failed to apply headers: %v", err)
+	}
+	// Only the checkpoints whose penalties are in force are kept
+	if len(snap.Penalties) != common.LimitPenaltyEpoch+1 {
+		t.Fatalf("recorded checkpoints mismatch: have %d, want %d", len(snap.Penalties), common.LimitPenaltyEpoch+1)
+	}
+	if err := snap.store(db); err != nil {
+		t.Fatalf("failed to store snapshot: %v", err)
+	}
+	loaded, err := loadSnapshot(engine.config, engine.signatures, db, snap.Hash)
+	if err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Penalties, snap.Penalties) {
+		t.Errorf("loaded penalties mismatch: have %x, want %x", loaded.Penalties, snap.Penalties)
+	}
+	for checkpoint := uint64(10); checkpoint <= 60; checkpoint += 10 {
+		want := []common.Address{{byte(checkpoint)}}
+		if have := engine.penalties(chain, loaded, checkpoint); !reflect.DeepEqual(have, want) {
+			t.Errorf("checkpoint %d: penalties mismatch: have %x, want %x", checkpoint, have, want)
+		}
+	}
+	// Checkpoints no longer recorded are read from their headers
+	if _, ok := loaded.Penalties[10]; ok {
+		t.Errorf("penalties of checkpoint 10 not pruned")
+	}
+}
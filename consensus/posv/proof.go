@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+var errNotCheckpoint = errors.New("header is not a checkpoint")
+
+// CheckpointProof proves the set of masternodes of an epoch to a client which
+// trusts the masternodes of the previous epoch: the checkpoint header starting
+// the epoch is sealed by its creator (M1), one of the trusted masternodes, and
+// signed by the validator (M2) the checkpoint assigns to the creator.
+type CheckpointProof struct {
+	Epoch       uint64           `json:"epoch"`
+	Header      hexutil.Bytes    `json:"header"` // RLP encoded checkpoint header
+	Masternodes []common.Address `json:"masternodes"`
+	Creator     common.Address   `json:"creator"`
+	Validator   common.Address   `json:"validator"`
+}
+
+// NewCheckpointProof returns the proof of the masternodes of a checkpoint
+// header. The proof isn't verified.
+func NewCheckpointProof(config *params.ChainConfig, header *types.Header) (*CheckpointProof, error) {
+	if config.Posv == nil || !config.Posv.IsCheckpoint(header.Number.Uint64()) {
+		return nil, errNotCheckpoint
+	}
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return nil, err
+	}
+	proof := &CheckpointProof{
+		Epoch:       config.Posv.EpochOf(header.Number.Uint64()),
+		Header:      data,
+		Masternodes: GetMasternodesFromCheckpointHeader(header),
+	}
+	if proof.Creator, err = recoverSignature(header, header.Extra); err != nil {
+		return nil, err
+	}
+	if proof.Validator, err = recoverSignature(header, header.Validator); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// VerifyCheckpointProof verifies the proof of the masternodes of an epoch
+// against the trusted masternodes of the previous epoch, and returns the
+// masternodes of the epoch, to be trusted for verifying the proof of the next
+// epoch.
+func VerifyCheckpointProof(config *params.ChainConfig, proof *CheckpointProof, trusted []common.Address) ([]common.Address, error) {
+	if config.Posv == nil {
+		return nil, errors.New("chain is not proof-of-stake-voting")
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(proof.Header, header); err != nil {
+		return nil, err
+	}
+	if header.Number == nil || header.Number.Sign() == 0 || header.Number.Uint64() != config.Posv.EpochCheckpoint(proof.Epoch) {
+		return nil, errNotCheckpoint
+	}
+	creator, err := recoverSignature(header, header.Extra)
+	if err != nil {
+		return nil, err
+	}
+	if position(trusted, creator) < 0 {
+		return nil, fmt.Errorf("checkpoint creator %x is not a trusted masternode", creator)
+	}
+	validator, err := recoverSignature(header, header.Validator)
+	if err != nil {
+		return nil, err
+	}
+	m1m2, err := GetM1M2FromCheckpointHeader(header, header, config)
+	if err != nil {
+		return nil, err
+	}
+	if m1m2[creator] != validator {
+		return nil, fmt.Errorf("checkpoint validator %x is not the validator %x of the creator", validator, m1m2[creator])
+	}
+	return GetMasternodesFromCheckpointHeader(header), nil
+}
+
+// recoverSignature returns the signer of the header, over its seal hash, from
+// the signature ending the data.
+func recoverSignature(header *types.Header, data []byte) (common.Address, error) {
+	if len(header.Extra) < extra.SealLength || len(data) < extra.SealLength {
+		return common.Address{}, errMissingSignature
+	}
+	pubkey, err := crypto.Ecrecover(sigHash(header).Bytes(), data[len(data)-extra.SealLength:])
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
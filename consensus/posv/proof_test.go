@@ -0,0 +1,78 @@
+package posv
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestCheckpointProof(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	masternodes := make([]common.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		masternodes[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	config := &params.ChainConfig{Posv: &params.PosvConfig{Epoch: 900}}
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	// The validators assign the second masternode to the first one
+	var validators []byte
+	for _, m2 := range []string{"1", "2", "0"} {
+		validators = append(validators, common.LeftPadBytes([]byte(m2), M2ByteLength)...)
+	}
+	checkpoint := func(validator *ecdsa.PrivateKey) *types.Header {
+		header := &types.Header{Number: big.NewInt(1800), Time: big.NewInt(1), Extra: common.CopyBytes(data), Validators: validators}
+		sealHeader(t, keys[0], header)
+		signature, err := crypto.Sign(sigHash(header).Bytes(), validator)
+		if err != nil {
+			t.Fatalf("failed to sign header: %v", err)
+		}
+		header.Validator = signature
+		return header
+	}
+	proof, err := NewCheckpointProof(config, checkpoint(keys[1]))
+	if err != nil {
+		t.Fatalf("failed to create proof: %v", err)
+	}
+	if proof.Epoch != 2 || proof.Creator != masternodes[0] || proof.Validator != masternodes[1] {
+		t.Errorf("proof mismatch: have epoch %d, creator %x, validator %x", proof.Epoch, proof.Creator, proof.Validator)
+	}
+	trusted := []common.Address{{0x01}, masternodes[0]}
+	verified, err := VerifyCheckpointProof(config, proof, trusted)
+	if err != nil {
+		t.Fatalf("failed to verify proof: %v", err)
+	}
+	if !reflect.DeepEqual(verified, masternodes) {
+		t.Errorf("verified masternodes mismatch: have %x, want %x", verified, masternodes)
+	}
+	// The creator must be trusted
+	if _, err := VerifyCheckpointProof(config, proof, []common.Address{masternodes[1], masternodes[2]}); err == nil {
+		t.Errorf("proof of an untrusted creator verified")
+	}
+	// The validator must be the one of the creator
+	wrong, err := NewCheckpointProof(config, checkpoint(keys[2]))
+	if err != nil {
+		t.Fatalf("failed to create proof: %v", err)
+	}
+	if _, err := VerifyCheckpointProof(config, wrong, trusted); err == nil {
+		t.Errorf("proof of a wrong validator verified")
+	}
+	// The header must be the checkpoint of the epoch
+	proof.Epoch = 3
+	if _, err := VerifyCheckpointProof(config, proof, trusted); err != errNotCheckpoint {
+		t.Errorf("epoch mismatch error: have %v, want %v", err, errNotCheckpoint)
+	}
+	if _, err := NewCheckpointProof(config, &types.Header{Number: big.NewInt(1801)}); err != errNotCheckpoint {
+		t.Errorf("non checkpoint error: have %v, want %v", err, errNotCheckpoint)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+const (
+	proposerSeedCommitment = 0  // Index of the proposer seed in the commitments of the checkpoint extra-data
+	proposersCacheLimit    = 16 // Number of shuffled orders of the proposers to keep in memory
+)
+
+var errInvalidProposerSeed = errors.New("invalid proposer seed on checkpoint block")
+
+// randomProposers returns whether the checkpoint of the number commits a seed
+// shuffling the proposers of its epoch, which takes the commitments of the
+// extra-data layout with typed fields.
+func randomProposers(chain consensus.ChainReader, number *big.Int) bool {
+	return chain.Config().IsTIPRandomProposer(number) && extra.VersionAt(number) == extra.VersionRLP
+}
+
+// ProposerSeed returns the seed committed by a checkpoint header to shuffle the
+// proposers of its epoch, and false if it commits none.
+func ProposerSeed(checkpoint *types.Header) (common.Hash, bool) {
+	decoded, err := extra.DecodeHeader(checkpoint)
+	if err != nil || len(decoded.Commitments) <= proposerSeedCommitment {
+		return common.Hash{}, false
+	}
+	return decoded.Commitments[proposerSeedCommitment], true
+}
+
+// NextProposerSeed chains the seed of the previous checkpoint with the seal of
+// the parent of the checkpoint, the last block of the epoch. The seed is thus
+// unknown until that block is sealed, and anyone can verify it.
+func NextProposerSeed(previous common.Hash, parent *types.Header) common.Hash {
+	seal, err := extra.Seal(parent.Extra)
+	if err != nil {
+		return crypto.Keccak256Hash(previous[:])
+	}
+	return crypto.Keccak256Hash(previous[:], seal)
+}
+
+// ShuffleProposers returns the masternodes in the order they propose blocks, a
+// Fisher-Yates shuffle drawing from the hash chain of the seed.
+func ShuffleProposers(masternodes []common.Address, seed common.Hash) []common.Address {
+	proposers := append([]common.Address{}, masternodes...)
+	draw := seed
+	for i := len(proposers) - 1; i > 0; i-- {
+		draw = crypto.Keccak256Hash(draw[:])
+		j := new(big.Int).Mod(draw.Big(), big.NewInt(int64(i+1))).Int64()
+		proposers[i], proposers[j] = proposers[j], proposers[i]
+	}
+	return proposers
+}
+
+// proposerSeed returns the seed the checkpoint header must commit, from the
+// seed of the previous checkpoint if it committed any. The previous checkpoint
+// is looked up in the batch of parents being verified first.
+func (c *Posv) proposerSeed(chain consensus.ChainReader, header, parent *types.Header, parents []*types.Header) common.Hash {
+	previous := common.Hash{}
+	if number, ok := c.config.PreviousCheckpoint(header.Number.Uint64(), 1); ok {
+		var checkpoint *types.Header
+		for _, ancestor := range parents {
+			if ancestor.Number.Uint64() == number {
+				checkpoint = ancestor
+				break
+			}
+		}
+		if checkpoint == nil {
+			checkpoint = chain.GetHeaderByNumber(number)
+		}
+		if checkpoint != nil && randomProposers(chain, checkpoint.Number) {
+			previous, _ = ProposerSeed(checkpoint)
+		}
+	}
+	return NextProposerSeed(previous, parent)
+}
+
+// verifyProposerSeed checks the seed committed by a checkpoint header.
+func (c *Posv) verifyProposerSeed(chain consensus.ChainReader, header, parent *types.Header, parents []*types.Header) error {
+	if !randomProposers(chain, header.Number) {
+		return nil
+	}
+	if seed, ok := ProposerSeed(header); !ok || seed != c.proposerSeed(chain, header, parent, parents) {
+		return errInvalidProposerSeed
+	}
+	return nil
+}
+
+// proposers returns the masternodes of a checkpoint header in the order they
+// propose the blocks of its epoch. The order is a copy the caller may modify.
+func (c *Posv) proposers(chain consensus.ChainReader, checkpoint *types.Header, masternodes []common.Address) []common.Address {
+	if !randomProposers(chain, checkpoint.Number) {
+		return masternodes
+	}
+	if proposers, ok := c.shuffledProposers.Get(checkpoint.Hash()); ok {
+		return append([]common.Address{}, proposers.([]common.Address)...)
+	}
+	seed, ok := ProposerSeed(checkpoint)
+	if !ok {
+		return masternodes
+	}
+	proposers := ShuffleProposers(masternodes, seed)
+	c.shuffledProposers.Add(checkpoint.Hash(), proposers)
+	return append([]common.Address{}, proposers...)
+}
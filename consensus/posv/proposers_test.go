@@ -0,0 +1,83 @@
+package posv
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestShuffleProposers(t *testing.T) {
+	masternodes := []common.Address{{0x01}, {0x02}, {0x03}, {0x04}, {0x05}, {0x06}, {0x07}, {0x08}}
+	proposers := ShuffleProposers(masternodes, common.Hash{0x01})
+	if !reflect.DeepEqual(proposers, ShuffleProposers(masternodes, common.Hash{0x01})) {
+		t.Fatalf("shuffle of the same seed differs")
+	}
+	if reflect.DeepEqual(proposers, ShuffleProposers(masternodes, common.Hash{0x02})) {
+		t.Errorf("shuffles of different seeds are the same")
+	}
+	sorted := append([]common.Address{}, proposers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+	if !reflect.DeepEqual(sorted, masternodes) {
+		t.Errorf("shuffle is no permutation: have %x", proposers)
+	}
+	if masternodes[0] != (common.Address{0x01}) || masternodes[7] != (common.Address{0x08}) {
+		t.Errorf("shuffle modified the masternodes")
+	}
+}
+
+func TestProposerSeed(t *testing.T) {
+	defer func(fork *big.Int) { common.TIPExtraV1Block = fork }(common.TIPExtraV1Block)
+	common.TIPExtraV1Block = big.NewInt(900)
+
+	config := &params.ChainConfig{TIPRandomProposerBlock: big.NewInt(900), Posv: &params.PosvConfig{Epoch: 900}}
+	engine := New(config.Posv, rawdb.NewMemoryDatabase())
+	chain := &headerChain{config: config, headers: map[common.Hash]*types.Header{}}
+	masternodes := []common.Address{{0x01}, {0x02}, {0x03}, {0x04}, {0x05}}
+
+	// checkpoint returns the checkpoint of the number committing the given seeds
+	checkpoint := func(number int64, commitments ...common.Hash) *types.Header {
+		data, err := (&extra.Extra{Version: extra.VersionAt(big.NewInt(number)), Masternodes: masternodes, Commitments: commitments}).Encode()
+		if err != nil {
+			t.Fatalf("failed to encode extra-data: %v", err)
+		}
+		return &types.Header{Number: big.NewInt(number), Extra: data}
+	}
+	genesis := checkpoint(0)
+	chain.headers[genesis.Hash()] = genesis
+
+	// The first checkpoint of the fork chains the seal of its parent from nothing
+	parent := &types.Header{Number: big.NewInt(899), Extra: make([]byte, extra.VanityLength+extra.SealLength)}
+	parent.Extra[len(parent.Extra)-1] = 0x01
+	first := checkpoint(900, NextProposerSeed(common.Hash{}, parent))
+	if err := engine.verifyProposerSeed(chain, first, parent, nil); err != nil {
+		t.Fatalf("valid proposer seed rejected: %v", err)
+	}
+	if err := engine.verifyProposerSeed(chain, checkpoint(900, common.Hash{0x01}), parent, nil); err != errInvalidProposerSeed {
+		t.Errorf("forged proposer seed error mismatch: have %v, want %v", err, errInvalidProposerSeed)
+	}
+	if err := engine.verifyProposerSeed(chain, checkpoint(900), parent, nil); err != errInvalidProposerSeed {
+		t.Errorf("missing proposer seed error mismatch: have %v, want %v", err, errInvalidProposerSeed)
+	}
+	// The next checkpoint chains the seed of the first, found among the parents
+	seed, _ := ProposerSeed(first)
+	second := checkpoint(1800, NextProposerSeed(seed, parent))
+	if err := engine.verifyProposerSeed(chain, second, parent, []*types.Header{first}); err != nil {
+		t.Errorf("chained proposer seed rejected: %v", err)
+	}
+	// The masternodes of the epoch propose in the order shuffled by the seed
+	chain.headers[first.Hash()] = first
+	want := ShuffleProposers(masternodes, seed)
+	if have := engine.GetMasternodes(chain, &types.Header{Number: big.NewInt(950)}); !reflect.DeepEqual(have, want) {
+		t.Errorf("proposers mismatch: have %x, want %x", have, want)
+	}
+	if have := engine.GetMasternodes(chain, &types.Header{Number: big.NewInt(50)}); !reflect.DeepEqual(have, masternodes) {
+		t.Errorf("proposers before the fork mismatch: have %x, want %x", have, masternodes)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+var errSigningRange = errors.New("invalid range of blocks")
+
+// BlockSigning is the signing of a block: its creator (M1), the validator (M2)
+// which double validated it and, for a signing slot, the masternodes whose sign
+// transactions of the block were recorded on the chain.
+type BlockSigning struct {
+	Number    uint64           `json:"number"`
+	Hash      common.Hash      `json:"hash"`
+	Creator   common.Address   `json:"creator"`
+	Validator common.Address   `json:"validator"`         // zero before double validation
+	Signers   []common.Address `json:"signers,omitempty"` // only for signing slots
+}
+
+// MasternodeSigning is the signing of a masternode over a range of blocks. A
+// masternode is expected to sign all the signing slots of its epochs.
+type MasternodeSigning struct {
+	Address   common.Address `json:"address"`
+	Expected  uint64         `json:"expected"`  // signing slots while a masternode
+	Signed    uint64         `json:"signed"`    // signing slots whose sign transaction was recorded
+	Missed    uint64         `json:"missed"`    // signing slots without a recorded sign transaction
+	Validated uint64         `json:"validated"` // blocks double validated as M2
+}
+
+// SigningReport is the signing of the blocks from From to To, and of their
+// masternodes in ascending order of their addresses.
+type SigningReport struct {
+	From        uint64               `json:"from"`
+	To          uint64               `json:"to"`
+	Blocks      []*BlockSigning      `json:"blocks"`
+	Masternodes []*MasternodeSigning `json:"masternodes"`
+}
+
+// isSigningSlot returns whether the masternodes are expected to sign the block
+// of the number, as rewarded at the checkpoints.
+func isSigningSlot(chain consensus.ChainReader, number uint64) bool {
+	return number%common.MergeSignRange == 0 || !chain.Config().IsTIP2019(new(big.Int).SetUint64(number))
+}
+
+// signTxs returns the sign transactions included in a canonical block.
+func (c *Posv) signTxs(chain consensus.ChainReader, header *types.Header) ([]*types.Transaction, error) {
+	if txs, ok := c.BlockSigners.Get(header.Hash()); ok {
+		return txs.([]*types.Transaction), nil
+	}
+	block := chain.GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return nil, errUnknownBlock
+	}
+	return c.CacheSigner(header.Hash(), block.Transactions()), nil
+}
+
+// SigningReport reports the signing of the canonical blocks from the number
+// from to the number to. Sign transactions are looked up until an epoch after
+// the last block, as late signs are still rewarded at the next checkpoint.
+// Blocks before TIPSigning aren't reported, their sign transactions counting
+// only if successful as per their receipts.
+func (c *Posv) SigningReport(chain consensus.ChainReader, from, to uint64) (*SigningReport, error) {
+	if from == 0 || from > to {
+		return nil, errSigningRange
+	}
+	if to-from+1 > maxPerformanceWindow {
+		return nil, fmt.Errorf("range of %d blocks exceeds the limit of %d", to-from+1, maxPerformanceWindow)
+	}
+	if !chain.Config().IsTIPSigning(new(big.Int).SetUint64(from)) {
+		return nil, fmt.Errorf("signing is only reported since block %v", common.TIPSigningBlock)
+	}
+	report := &SigningReport{From: from, To: to, Blocks: []*BlockSigning{}, Masternodes: []*MasternodeSigning{}}
+	stats := make(map[common.Address]*MasternodeSigning)
+	stat := func(address common.Address) *MasternodeSigning {
+		if stats[address] == nil {
+			stats[address] = &MasternodeSigning{Address: address}
+		}
+		return stats[address]
+	}
+	// Collect the signers of the blocks, whether they are masternodes or not
+	signers := make(map[common.Hash]map[common.Address]bool)
+	end := to + c.config.EpochLength(to)
+	for n := from; n <= end; n++ {
+		header := chain.GetHeaderByNumber(n)
+		if header == nil {
+			if n <= to {
+				return nil, errUnknownBlock
+			}
+			break
+		}
+		txs, err := c.signTxs(chain, header)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			data, sender := tx.Data(), tx.From()
+			if len(data) < common.HashLength || sender == nil {
+				continue
+			}
+			signed := common.BytesToHash(data[len(data)-common.HashLength:])
+			if signers[signed] == nil {
+				signers[signed] = make(map[common.Address]bool)
+			}
+			signers[signed][c.MasternodeOf(chain, header.Number.Uint64(), *sender)] = true
+		}
+		if n > to {
+			continue
+		}
+		block := &BlockSigning{Number: n, Hash: header.Hash()}
+		if block.Creator, err = ecrecover(header, c.signatures); err != nil {
+			return nil, err
+		}
+		block.Creator = c.MasternodeOf(chain, header.Number.Uint64(), block.Creator)
+		if validator, err := c.RecoverValidator(header); err == nil {
+			validator = c.MasternodeOf(chain, header.Number.Uint64(), validator)
+			block.Validator = validator
+			stat(validator).Validated++
+		}
+		report.Blocks = append(report.Blocks, block)
+	}
+	// Count the signs of the masternodes expected to sign the slots
+	for _, block := range report.Blocks {
+		if !isSigningSlot(chain, block.Number) {
+			continue
+		}
+		block.Signers = []common.Address{}
+		for _, masternode := range c.GetMasternodes(chain, chain.GetHeaderByNumber(block.Number)) {
+			s := stat(masternode)
+			s.Expected++
+			if signers[block.Hash][masternode] {
+				s.Signed++
+				block.Signers = append(block.Signers, masternode)
+			} else {
+				s.Missed++
+			}
+		}
+	}
+	for _, stat := range stats {
+		report.Masternodes = append(report.Masternodes, stat)
+	}
+	sort.Slice(report.Masternodes, func(i, j int) bool {
+		return bytes.Compare(report.Masternodes[i].Address[:], report.Masternodes[j].Address[:]) < 0
+	})
+	return report, nil
+}
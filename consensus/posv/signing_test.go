@@ -0,0 +1,119 @@
+package posv
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+func TestSigningReport(t *testing.T) {
+	defer func(tip2019, tipSigning *big.Int) {
+		common.TIP2019Block, common.TIPSigningBlock = tip2019, tipSigning
+	}(common.TIP2019Block, common.TIPSigningBlock)
+	common.TIP2019Block, common.TIPSigningBlock = big.NewInt(0), big.NewInt(0)
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	addrs := make([]common.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	masternodes := addrs[:2]
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	chain := &blockChain{
+		headerChain: headerChain{config: &params.ChainConfig{}, headers: map[common.Hash]*types.Header{}},
+		blocks:      map[common.Hash]*types.Block{},
+	}
+	genesis := &types.Header{Number: big.NewInt(0), Extra: data}
+	chain.headers[genesis.Hash()] = genesis
+
+	// signTx returns the sign transaction of the key for the block
+	signTx := func(key *ecdsa.PrivateKey, block *types.Block) *types.Transaction {
+		data := append(common.Hex2Bytes(common.HexSignMethod), common.LeftPadBytes(block.Number().Bytes(), 32)...)
+		data = append(data, block.Hash().Bytes()...)
+		tx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress(common.BlockSigners), big.NewInt(0), 200000, big.NewInt(0), data), types.HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return tx
+	}
+	// Masternode 0 signs block 15 and masternode 1 block 30 after the range, an
+	// address which isn't a masternode signing block 15 too
+	blocks := make(map[uint64]*types.Block)
+	parent := genesis.Hash()
+	for n := uint64(1); n <= 31; n++ {
+		var txs []*types.Transaction
+		switch n {
+		case 16:
+			txs = []*types.Transaction{signTx(keys[0], blocks[15]), signTx(keys[2], blocks[15])}
+		case 31:
+			txs = []*types.Transaction{signTx(keys[1], blocks[30])}
+		}
+		header := types.NewBlock(&types.Header{
+			Number:     new(big.Int).SetUint64(n),
+			ParentHash: parent,
+			Time:       new(big.Int).SetUint64(n),
+			Difficulty: big.NewInt(1),
+			Extra:      make([]byte, extra.VanityLength+extra.SealLength),
+		}, txs, nil, nil).Header()
+		sealHeader(t, keys[0], header)
+		if n == 15 {
+			validator, err := crypto.Sign(sigHash(header).Bytes(), keys[1])
+			if err != nil {
+				t.Fatalf("failed to validate header: %v", err)
+			}
+			header.Validator = validator
+		}
+		block := types.NewBlockWithHeader(header).WithBody(txs, nil)
+		chain.headers[block.Hash()], chain.blocks[block.Hash()] = block.Header(), block
+		blocks[n], parent = block, block.Hash()
+	}
+	engine := New(&params.PosvConfig{Epoch: 900}, rawdb.NewMemoryDatabase())
+
+	report, err := engine.SigningReport(chain, 1, 30)
+	if err != nil {
+		t.Fatalf("failed to report signing: %v", err)
+	}
+	if len(report.Blocks) != 30 {
+		t.Fatalf("reported blocks mismatch: have %d, want 30", len(report.Blocks))
+	}
+	if block := report.Blocks[14]; block.Creator != addrs[0] || block.Validator != addrs[1] || !reflect.DeepEqual(block.Signers, []common.Address{addrs[0]}) {
+		t.Errorf("block 15 signing mismatch: have %+v", block)
+	}
+	if block := report.Blocks[29]; !reflect.DeepEqual(block.Signers, []common.Address{addrs[1]}) {
+		t.Errorf("block 30 signers mismatch: have %x, want %x", block.Signers, addrs[1:2])
+	}
+	if block := report.Blocks[15]; block.Signers != nil {
+		t.Errorf("block 16 is no signing slot: have signers %x", block.Signers)
+	}
+	stats := make(map[common.Address]MasternodeSigning)
+	for _, stat := range report.Masternodes {
+		stats[stat.Address] = *stat
+	}
+	if want := (MasternodeSigning{Address: addrs[0], Expected: 2, Signed: 1, Missed: 1}); stats[addrs[0]] != want {
+		t.Errorf("masternode 0 signing mismatch: have %+v, want %+v", stats[addrs[0]], want)
+	}
+	if want := (MasternodeSigning{Address: addrs[1], Expected: 2, Signed: 1, Missed: 1, Validated: 1}); stats[addrs[1]] != want {
+		t.Errorf("masternode 1 signing mismatch: have %+v, want %+v", stats[addrs[1]], want)
+	}
+	if _, ok := stats[addrs[2]]; ok {
+		t.Errorf("signer which is no masternode reported")
+	}
+	if _, err := engine.SigningReport(chain, 20, 10); err != errSigningRange {
+		t.Errorf("invalid range error mismatch: have %v, want %v", err, errSigningRange)
+	}
+	if _, err := engine.SigningReport(chain, 1, 40); err != errUnknownBlock {
+		t.Errorf("unknown block error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+}
@@ -0,0 +1,496 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulation runs networks of in-process PoSV masternodes on a mock
+// clock, for regression testing the consensus changes before they fork the
+// main network.
+//
+// The masternodes seal their blocks in turn as the miner does, the backups
+// stepping in after waiting for the missing ones, and exchange them with the
+// peers they reach. Partitions and failures of the masternodes are scripted
+// against the mock clock, so that a simulation always runs the same way.
+package simulation
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/accounts"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/contracts"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+const (
+	waitPeriod           = 10 * time.Second // Wait for each missing masternode, as the miner does
+	waitPeriodCheckpoint = 20 * time.Second // Wait for each missing masternode near the checkpoints
+)
+
+// genesisTime is the timestamp of the genesis block, and the start of the mock
+// clock. It lies in the past for the chains not to hold the blocks as future.
+var genesisTime = time.Unix(1500000000, 0)
+
+var errUnknownNode = errors.New("unknown node")
+
+// Config is the configuration of a simulated network.
+type Config struct {
+	Masternodes int    // Number of masternodes, all of them running a node
+	Period      uint64 // Seconds between the blocks
+	Epoch       uint64 // Blocks of an epoch
+	Gap         uint64 // Blocks before the checkpoint the next masternodes are settled at
+}
+
+// Node is a simulated masternode, running its own chain and consensus engine.
+type Node struct {
+	Index   int
+	Address common.Address
+
+	key    *ecdsa.PrivateKey
+	engine *posv.Posv
+	chain  *core.BlockChain
+	online bool
+}
+
+// Chain returns the chain of the node.
+func (n *Node) Chain() *core.BlockChain {
+	return n.chain
+}
+
+// Engine returns the consensus engine of the node.
+func (n *Node) Engine() *posv.Posv {
+	return n.engine
+}
+
+// Online returns whether the node is running.
+func (n *Node) Online() bool {
+	return n.online
+}
+
+// action is a scripted change of the network.
+type action struct {
+	at time.Time
+	fn func(net *Network)
+}
+
+// Network is a set of simulated masternodes sharing a mock clock.
+type Network struct {
+	config  *params.ChainConfig
+	nodes   []*Node
+	groups  []int    // Partition each node belongs to, the nodes of one reaching each other
+	actions []action // Scripted changes, in the order of their time
+
+	now  time.Time
+	lock sync.RWMutex // Protects the mock clock, read by the chains in the background
+	quit chan struct{}
+}
+
+// NewNetwork creates a network of masternodes from the same genesis, the first
+// one holding the highest stake.
+func NewNetwork(config Config) (*Network, error) {
+	if config.Masternodes <= 0 || config.Epoch == 0 || config.Gap >= config.Epoch {
+		return nil, fmt.Errorf("invalid simulation config: %+v", config)
+	}
+	net := &Network{
+		config: &params.ChainConfig{
+			ChainId:        big.NewInt(1337),
+			HomesteadBlock: big.NewInt(0),
+			EIP150Block:    big.NewInt(0),
+			EIP155Block:    big.NewInt(0),
+			EIP158Block:    big.NewInt(0),
+			ByzantiumBlock: big.NewInt(0),
+			Posv: &params.PosvConfig{
+				Period:           config.Period,
+				Epoch:            config.Epoch,
+				Gap:              config.Gap,
+				RewardCheckpoint: config.Epoch,
+			},
+		},
+		groups: make([]int, config.Masternodes),
+		now:    genesisTime,
+		quit:   make(chan struct{}),
+	}
+	// The keys are derived from the indices, for the runs to seal the same blocks
+	masternodes := make([]common.Address, config.Masternodes)
+	keys := make([]*ecdsa.PrivateKey, config.Masternodes)
+	for i := range keys {
+		key, err := crypto.ToECDSA(crypto.Keccak256([]byte(fmt.Sprintf("posv-simulation-%d", i))))
+		if err != nil {
+			return nil, err
+		}
+		keys[i], masternodes[i] = key, crypto.PubkeyToAddress(key.PublicKey)
+	}
+	genesis, err := net.genesis(masternodes)
+	if err != nil {
+		return nil, err
+	}
+	go net.drainCheckpoints()
+
+	for i, key := range keys {
+		key := key
+		db := rawdb.NewMemoryDatabase()
+		genesis.MustCommit(db)
+
+		engine := posv.New(net.config.Posv, db)
+		engine.SetClock(net.Now)
+		engine.HookValidator = validators
+		engine.Authorize(masternodes[i], func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+		chain, err := core.NewBlockChain(db, nil, net.config, engine, vm.Config{})
+		if err != nil {
+			net.Close()
+			return nil, err
+		}
+		net.nodes = append(net.nodes, &Node{Index: i, Address: masternodes[i], key: key, engine: engine, chain: chain, online: true})
+	}
+	return net, nil
+}
+
+// genesis assembles the genesis block, registering the masternodes in the
+// validator contract with decreasing stakes.
+func (net *Network) genesis(masternodes []common.Address) (*core.Genesis, error) {
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: masternodes}).Encode()
+	if err != nil {
+		return nil, err
+	}
+	storage := make(map[common.Hash]common.Hash)
+	slot := state.GetLocSimpleVariable(3)
+	for i, masternode := range masternodes {
+		storage[state.GetLocDynamicArrAtElement(slot, uint64(i), 1)] = masternode.Hash()
+
+		loc := state.GetLocMappingAtKey(masternode.Hash(), 1)
+		storage[state.GetLocOfStructElement(loc, big.NewInt(0))] = masternode.Hash()
+		storage[state.GetLocOfStructElement(loc, big.NewInt(1))] = common.BigToHash(big.NewInt(int64(len(masternodes) - i)))
+	}
+	storage[slot] = common.BigToHash(big.NewInt(int64(len(masternodes))))
+
+	return &core.Genesis{
+		Config:     net.config,
+		Timestamp:  uint64(genesisTime.Unix()),
+		ExtraData:  data,
+		GasLimit:   params.GenesisGasLimit,
+		Difficulty: big.NewInt(1),
+		Alloc: core.GenesisAlloc{
+			common.HexToAddress(common.MasternodeVotingSMC): {Balance: new(big.Int), Storage: storage},
+		},
+	}, nil
+}
+
+// validators assigns each masternode to double validate the blocks of the one
+// before it.
+func validators(header *types.Header, signers []common.Address) ([]byte, error) {
+	m2 := make([]int64, len(signers))
+	for i := range m2 {
+		m2[i] = int64(i + 1)
+	}
+	return contracts.BuildValidatorFromM2(m2), nil
+}
+
+// drainCheckpoints consumes the notifications of the chains reaching the
+// checkpoints, which would block them otherwise.
+func (net *Network) drainCheckpoints() {
+	for {
+		select {
+		case <-core.CheckpointCh:
+		case <-net.quit:
+			return
+		}
+	}
+}
+
+// Close stops the chains of all the nodes.
+func (net *Network) Close() {
+	for _, n := range net.nodes {
+		n.chain.Stop()
+	}
+	close(net.quit)
+}
+
+// Now returns the time of the mock clock.
+func (net *Network) Now() time.Time {
+	net.lock.RLock()
+	defer net.lock.RUnlock()
+
+	return net.now
+}
+
+// Nodes returns the nodes of the network.
+func (net *Network) Nodes() []*Node {
+	return net.nodes
+}
+
+// Node returns the node of the index.
+func (net *Network) Node(index int) *Node {
+	if index < 0 || index >= len(net.nodes) {
+		return nil
+	}
+	return net.nodes[index]
+}
+
+// At schedules an action at the offset of the mock clock from the genesis, to
+// be taken by the runs reaching it.
+func (net *Network) At(offset time.Duration, fn func(net *Network)) {
+	net.actions = append(net.actions, action{at: genesisTime.Add(offset), fn: fn})
+	sort.SliceStable(net.actions, func(i, j int) bool {
+		return net.actions[i].at.Before(net.actions[j].at)
+	})
+}
+
+// Partition splits the network into groups of nodes, reaching the nodes of
+// their group only. The nodes left out form one more group.
+func (net *Network) Partition(groups ...[]int) {
+	for i := range net.groups {
+		net.groups[i] = 0
+	}
+	for g, group := range groups {
+		for _, index := range group {
+			net.groups[index] = g + 1
+		}
+	}
+}
+
+// Heal joins the partitions back into one network.
+func (net *Network) Heal() {
+	net.Partition()
+}
+
+// Stop takes the node offline, neither sealing nor receiving blocks.
+func (net *Network) Stop(index int) error {
+	n := net.Node(index)
+	if n == nil {
+		return errUnknownNode
+	}
+	n.online = false
+	return nil
+}
+
+// Start brings the node back online, to catch up with its peers.
+func (net *Network) Start(index int) error {
+	n := net.Node(index)
+	if n == nil {
+		return errUnknownNode
+	}
+	n.online = true
+	return nil
+}
+
+// reachable returns whether the nodes are online and in the same partition.
+func (net *Network) reachable(n, peer *Node) bool {
+	return n.online && peer.online && net.groups[n.Index] == net.groups[peer.Index]
+}
+
+// Run advances the mock clock second by second for the duration, taking the
+// scripted actions due, letting the nodes seal their blocks in turn and
+// exchange them with their peers.
+func (net *Network) Run(d time.Duration) error {
+	end := net.Now().Add(d)
+	for net.Now().Before(end) {
+		net.lock.Lock()
+		net.now = net.now.Add(time.Second)
+		net.lock.Unlock()
+
+		for len(net.actions) > 0 && !net.actions[0].at.After(net.Now()) {
+			fn := net.actions[0].fn
+			net.actions = net.actions[1:]
+			fn(net)
+		}
+		for _, n := range net.nodes {
+			if !n.online {
+				continue
+			}
+			if err := net.seal(n); err != nil {
+				return fmt.Errorf("node %d: %v", n.Index, err)
+			}
+			if err := net.sync(); err != nil {
+				return err
+			}
+		}
+		if err := net.sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seal lets the node seal a block on its head if it is its turn, or if it
+// waited long enough for the masternodes before it, as the miner does.
+func (net *Network) seal(n *Node) error {
+	parent := n.chain.CurrentBlock()
+	now := net.Now()
+	if now.Unix() < parent.Time().Int64()+int64(net.config.Posv.Period) {
+		return nil
+	}
+	masternodes, preIndex, curIndex, ok, err := n.engine.YourTurn(n.chain, parent.Header(), n.Address)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if preIndex == -1 || curIndex == -1 {
+			return nil
+		}
+		hops := posv.Hop(masternodes, preIndex, curIndex)
+		wait := waitPeriod * time.Duration(hops)
+		number := parent.NumberU64()
+		if uint64(hops) >= net.config.Posv.EpochLength(number)-net.config.Posv.EpochOffset(number) {
+			wait = waitPeriodCheckpoint * time.Duration(hops)
+		}
+		if now.Sub(time.Unix(parent.Time().Int64(), 0)) < wait {
+			return nil
+		}
+	}
+	// The engine holds the masternodes back from sealing twice in a row
+	if masternodes > 1 && parent.NumberU64() > 0 {
+		if creator, err := n.engine.RecoverSigner(parent.Header()); err == nil && creator == n.Address {
+			return nil
+		}
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Time:       big.NewInt(now.Unix()),
+	}
+	if err := n.engine.Prepare(n.chain, header); err != nil {
+		return err
+	}
+	statedb, err := n.chain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	block, err := n.engine.Finalize(n.chain, header, statedb, statedb.Copy(), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	block, err = n.engine.Seal(n.chain, block, make(chan struct{}))
+	if err != nil || block == nil {
+		return err
+	}
+	// Past the first epoch, the blocks need the signature of their validator
+	header = block.Header()
+	if header.Number.Uint64() > net.config.Posv.Epoch && len(header.Validator) == 0 {
+		m2, err := n.engine.GetValidator(n.Address, n.chain, header)
+		if err != nil {
+			return err
+		}
+		validator := net.nodeOf(m2)
+		if validator == nil || !net.reachable(n, validator) {
+			return nil
+		}
+		if header.Validator, err = crypto.Sign(posv.SigHash(header).Bytes(), validator.key); err != nil {
+			return err
+		}
+		block = types.NewBlockWithHeader(header).WithBody(block.Transactions(), block.Uncles())
+	}
+	_, err = n.chain.InsertChain(types.Blocks{block})
+	return err
+}
+
+// nodeOf returns the node of the masternode, or nil if unknown.
+func (net *Network) nodeOf(masternode common.Address) *Node {
+	for _, n := range net.nodes {
+		if n.Address == masternode {
+			return n
+		}
+	}
+	return nil
+}
+
+// sync propagates the heaviest chains across the reachable nodes, until none
+// of them has a heavier chain to pull from a peer.
+func (net *Network) sync() error {
+	for progressed := true; progressed; {
+		progressed = false
+		for _, n := range net.nodes {
+			for _, peer := range net.nodes {
+				if n == peer || !net.reachable(n, peer) {
+					continue
+				}
+				pulled, err := pull(n, peer)
+				if err != nil {
+					return fmt.Errorf("node %d: failed to import from node %d: %v", n.Index, peer.Index, err)
+				}
+				progressed = progressed || pulled
+			}
+		}
+	}
+	return nil
+}
+
+// pull imports the chain of the peer into the node if it is heavier than its
+// own, returning whether it did.
+func pull(n, peer *Node) (bool, error) {
+	head, local := peer.chain.CurrentBlock(), n.chain.CurrentBlock()
+	if head.Hash() == local.Hash() {
+		return false, nil
+	}
+	td, localTd := peer.chain.GetTd(head.Hash(), head.NumberU64()), n.chain.GetTd(local.Hash(), local.NumberU64())
+	if td.Cmp(localTd) <= 0 {
+		return false, nil
+	}
+	var blocks types.Blocks
+	for block := head; !n.chain.HasBlock(block.Hash(), block.NumberU64()); block = peer.chain.GetBlock(block.ParentHash(), block.NumberU64()-1) {
+		blocks = append(blocks, block)
+	}
+	// The engine verifies the turns against the snapshots of the chain, so the
+	// blocks are imported one by one as propagated rather than in batches
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if _, err := n.chain.InsertChain(blocks[i : i+1]); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Heads returns the head blocks of the nodes.
+func (net *Network) Heads() []*types.Block {
+	heads := make([]*types.Block, len(net.nodes))
+	for i, n := range net.nodes {
+		heads[i] = n.chain.CurrentBlock()
+	}
+	return heads
+}
+
+// Converged returns an error unless all the online nodes share the same head.
+func (net *Network) Converged() error {
+	var first *Node
+	for _, n := range net.nodes {
+		if !n.online {
+			continue
+		}
+		if first == nil {
+			first = n
+			continue
+		}
+		head, current := first.chain.CurrentBlock(), n.chain.CurrentBlock()
+		if current.Hash() != head.Hash() {
+			return fmt.Errorf("heads diverged: node %d at #%d [%x…], node %d at #%d [%x…]",
+				first.Index, head.NumberU64(), head.Hash().Bytes()[:4], n.Index, current.NumberU64(), current.Hash().Bytes()[:4])
+		}
+	}
+	return nil
+}
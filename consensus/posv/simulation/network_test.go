@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"testing"
+	"time"
+)
+
+var testConfig = Config{Masternodes: 4, Period: 2, Epoch: 20, Gap: 5}
+
+func newTestNetwork(t *testing.T) *Network {
+	net, err := NewNetwork(testConfig)
+	if err != nil {
+		t.Fatalf("failed to create the network: %v", err)
+	}
+	return net
+}
+
+// Tests that the masternodes seal in turn across the checkpoints.
+func TestSimulationConverges(t *testing.T) {
+	net := newTestNetwork(t)
+	defer net.Close()
+
+	if err := net.Run(2 * time.Minute); err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+	if err := net.Converged(); err != nil {
+		t.Fatal(err)
+	}
+	// Blocks are sealed every period, through two checkpoints
+	if head := net.Node(0).Chain().CurrentBlock().NumberU64(); head != 60 {
+		t.Errorf("head mismatch: have #%d, want #%d", head, 60)
+	}
+}
+
+// Tests that the partitions of the network fork, and reorganise onto the
+// heaviest chain once healed.
+func TestSimulationPartition(t *testing.T) {
+	net := newTestNetwork(t)
+	defer net.Close()
+
+	net.At(30*time.Second, func(net *Network) { net.Partition([]int{0, 1}, []int{2, 3}) })
+	net.At(90*time.Second, func(net *Network) { net.Heal() })
+
+	if err := net.Run(80 * time.Second); err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+	if err := net.Converged(); err == nil {
+		t.Fatalf("partitions did not fork")
+	}
+	if err := net.Run(2 * time.Minute); err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+	if err := net.Converged(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that the backups seal the blocks of a failed masternode, which catches
+// up once back online.
+func TestSimulationFailure(t *testing.T) {
+	net := newTestNetwork(t)
+	defer net.Close()
+
+	net.At(20*time.Second, func(net *Network) { net.Stop(2) })
+	net.At(100*time.Second, func(net *Network) { net.Start(2) })
+
+	if err := net.Run(90 * time.Second); err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+	if err := net.Converged(); err != nil {
+		t.Fatal(err)
+	}
+	stalled, head := net.Node(2).Chain().CurrentBlock(), net.Node(0).Chain().CurrentBlock()
+	if stalled.NumberU64() >= head.NumberU64() {
+		t.Fatalf("chain progressed on the failed masternode: #%d, others #%d", stalled.NumberU64(), head.NumberU64())
+	}
+	if err := net.Run(time.Minute); err != nil {
+		t.Fatalf("failed to run: %v", err)
+	}
+	if err := net.Converged(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that the simulations seal the same chain on every run.
+func TestSimulationDeterministic(t *testing.T) {
+	var heads []string
+	for i := 0; i < 2; i++ {
+		net := newTestNetwork(t)
+		net.At(10*time.Second, func(net *Network) { net.Stop(1) })
+		net.At(40*time.Second, func(net *Network) { net.Partition([]int{0}) })
+		net.At(70*time.Second, func(net *Network) { net.Heal(); net.Start(1) })
+		if err := net.Run(2 * time.Minute); err != nil {
+			t.Fatalf("run %d: failed to run: %v", i, err)
+		}
+		heads = append(heads, net.Node(0).Chain().CurrentBlock().Hash().Hex())
+		net.Close()
+	}
+	if heads[0] != heads[1] {
+		t.Errorf("head mismatch across runs: %s != %s", heads[0], heads[1])
+	}
+}
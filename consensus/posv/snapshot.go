@@ -18,6 +18,7 @@ package posv
 import (
 	"bytes"
 	"encoding/json"
+	"sort"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/common"
@@ -54,6 +55,8 @@ type Snapshot struct {
 	Recents map[uint64]common.Address       `json:"recents"` // Set of recent signers for spam protections
 	Votes   []*clique.Vote                  `json:"votes"`   // List of votes cast in chronological order
 	Tally   map[common.Address]clique.Tally `json:"tally"`   // Current vote tally to avoid recalculating
+
+	Penalties map[uint64][]common.Address `json:"penalties,omitempty"` // Masternodes penalized by the recent checkpoints
 }
 
 // newSnapshot creates a new snapshot with the specified startup parameters. This
@@ -68,6 +71,8 @@ func newSnapshot(config *params.PosvConfig, sigcache *lru.ARCCache, number uint6
 		Signers:  make(map[common.Address]struct{}),
 		Recents:  make(map[uint64]common.Address),
 		Tally:    make(map[common.Address]clique.Tally),
+
+		Penalties: make(map[uint64][]common.Address),
 	}
 	for _, signer := range signers {
 		snap.Signers[signer] = struct{}{}
@@ -87,6 +92,9 @@ func loadSnapshot(config *params.PosvConfig, sigcache *lru.ARCCache, db ethdb.Da
 	}
 	snap.config = config
 	snap.sigcache = sigcache
+	if snap.Penalties == nil {
+		snap.Penalties = make(map[uint64][]common.Address)
+	}
 
 	return snap, nil
 }
@@ -111,6 +119,8 @@ func (s *Snapshot) copy() *Snapshot {
 		Recents:  make(map[uint64]common.Address),
 		Votes:    make([]*clique.Vote, len(s.Votes)),
 		Tally:    make(map[common.Address]clique.Tally),
+
+		Penalties: make(map[uint64][]common.Address),
 	}
 	for signer := range s.Signers {
 		cpy.Signers[signer] = struct{}{}
@@ -121,6 +131,9 @@ func (s *Snapshot) copy() *Snapshot {
 	for address, tally := range s.Tally {
 		cpy.Tally[address] = tally
 	}
+	for checkpoint, penalties := range s.Penalties {
+		cpy.Penalties[checkpoint] = penalties
+	}
 	copy(cpy.Votes, s.Votes)
 
 	return cpy
@@ -192,9 +205,10 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 	for _, header := range headers {
 		// Remove any votes on checkpoint blocks
 		number := header.Number.Uint64()
-		if number%s.config.Epoch == 0 {
+		if s.config.IsCheckpoint(number) {
 			snap.Votes = nil
 			snap.Tally = make(map[common.Address]clique.Tally)
+			snap.penalize(number, common.ExtractAddressFromBytes(header.Penalties))
 		}
 		// Delete the oldest signer from the recent list to allow it signing again
 		if limit := uint64(len(snap.Signers)/2 + 1); number >= limit {
@@ -285,6 +299,24 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 	return snap, nil
 }
 
+// penalize records the masternodes penalized by a checkpoint, only keeping the
+// checkpoints whose penalties are still in force.
+func (s *Snapshot) penalize(checkpoint uint64, penalties []common.Address) {
+	if penalties == nil {
+		penalties = []common.Address{}
+	}
+	s.Penalties[checkpoint] = penalties
+
+	checkpoints := make([]uint64, 0, len(s.Penalties))
+	for number := range s.Penalties {
+		checkpoints = append(checkpoints, number)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i] > checkpoints[j] })
+	for i := common.LimitPenaltyEpoch + 1; i < len(checkpoints); i++ {
+		delete(s.Penalties, checkpoints[i])
+	}
+}
+
 // signers retrieves the list of authorized signers in ascending order.
 func (s *Snapshot) GetSigners() []common.Address {
 	signers := make([]common.Address, 0, len(s.Signers))
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package posv
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+// StakeSnapshot is the stake of the candidates to the masternodes, as recorded
+// by the validator contract in the state of the checkpoint starting an epoch.
+// The candidates are sorted by descending capacity.
+type StakeSnapshot struct {
+	Epoch      uint64            `json:"epoch"`
+	Number     uint64            `json:"number"`
+	Hash       common.Hash       `json:"hash"`
+	Candidates []*CandidateStake `json:"candidates"`
+}
+
+// CandidateStake is the capacity of a candidate, the stake of its owner and
+// voters together, and the delegations of its voters by descending capacity.
+type CandidateStake struct {
+	Address    common.Address `json:"address"`
+	Owner      common.Address `json:"owner"`
+	Capacity   *big.Int       `json:"capacity"`
+	Masternode bool           `json:"masternode"` // whether a masternode of the epoch
+	Voters     []*VoterStake  `json:"voters"`
+}
+
+// VoterStake is the capacity a voter delegated to a candidate.
+type VoterStake struct {
+	Address  common.Address `json:"address"`
+	Capacity *big.Int       `json:"capacity"`
+}
+
+// stakeLess orders stakes by descending capacity, then ascending address.
+func stakeLess(capacity, other *big.Int, address, otherAddress common.Address) bool {
+	if cmp := capacity.Cmp(other); cmp != 0 {
+		return cmp > 0
+	}
+	return bytes.Compare(address[:], otherAddress[:]) < 0
+}
+
+// newStakeSnapshot reads the stake of the candidates from the state of the
+// checkpoint header. Candidates and voters without capacity, having resigned
+// or withdrawn their stake, are left out.
+func newStakeSnapshot(epoch uint64, checkpoint *types.Header, statedb *state.StateDB) *StakeSnapshot {
+	snap := &StakeSnapshot{Epoch: epoch, Number: checkpoint.Number.Uint64(), Hash: checkpoint.Hash(), Candidates: []*CandidateStake{}}
+	masternodes := make(map[common.Address]bool)
+	for _, masternode := range GetMasternodesFromCheckpointHeader(checkpoint) {
+		masternodes[masternode] = true
+	}
+	seen := make(map[common.Address]bool)
+	for _, candidate := range state.GetCandidates(statedb) {
+		capacity := state.GetCandidateCap(statedb, candidate)
+		if seen[candidate] || capacity.Sign() == 0 {
+			continue
+		}
+		seen[candidate] = true
+		stake := &CandidateStake{
+			Address:    candidate,
+			Owner:      state.GetCandidateOwner(statedb, candidate),
+			Capacity:   capacity,
+			Masternode: masternodes[candidate],
+			Voters:     []*VoterStake{},
+		}
+		voted := make(map[common.Address]bool)
+		for _, voter := range state.GetVoters(statedb, candidate) {
+			capacity := state.GetVoterCap(statedb, candidate, voter)
+			if voted[voter] || capacity.Sign() == 0 {
+				continue
+			}
+			voted[voter] = true
+			stake.Voters = append(stake.Voters, &VoterStake{Address: voter, Capacity: capacity})
+		}
+		voters := stake.Voters
+		sort.Slice(voters, func(i, j int) bool {
+			return stakeLess(voters[i].Capacity, voters[j].Capacity, voters[i].Address, voters[j].Address)
+		})
+		snap.Candidates = append(snap.Candidates, stake)
+	}
+	candidates := snap.Candidates
+	sort.Slice(candidates, func(i, j int) bool {
+		return stakeLess(candidates[i].Capacity, candidates[j].Capacity, candidates[i].Address, candidates[j].Address)
+	})
+	return snap
+}
+
+// StakeSnapshot returns the stake of the candidates at the checkpoint starting
+// the epoch. The state of the checkpoint must still be available.
+func (c *Posv) StakeSnapshot(chain consensus.ChainReader, epoch uint64) (*StakeSnapshot, error) {
+	checkpoint := chain.GetHeaderByNumber(c.config.EpochCheckpoint(epoch))
+	if checkpoint == nil {
+		return nil, errUnknownBlock
+	}
+	statedb, err := state.New(checkpoint.Root, state.NewDatabase(c.db))
+	if err != nil {
+		return nil, err
+	}
+	return newStakeSnapshot(epoch, checkpoint, statedb), nil
+}
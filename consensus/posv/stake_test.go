@@ -0,0 +1,107 @@
+package posv
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv/extra"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// stakeState stores candidates and their voters in the validator contract
+// state, as laid out by the contract.
+type stakeState struct {
+	statedb    *state.StateDB
+	candidates uint64
+}
+
+func (s *stakeState) set(key common.Hash, value common.Hash) {
+	s.statedb.SetState(common.HexToAddress(common.MasternodeVotingSMC), key, value)
+}
+
+func (s *stakeState) addCandidate(candidate, owner common.Address, capacity int64) {
+	slot := state.GetLocSimpleVariable(3)
+	s.set(state.GetLocDynamicArrAtElement(slot, s.candidates, 1), candidate.Hash())
+	s.candidates++
+	s.set(slot, common.BigToHash(new(big.Int).SetUint64(s.candidates)))
+
+	loc := state.GetLocMappingAtKey(candidate.Hash(), 1)
+	s.set(state.GetLocOfStructElement(loc, big.NewInt(0)), owner.Hash())
+	s.set(state.GetLocOfStructElement(loc, big.NewInt(1)), common.BigToHash(big.NewInt(capacity)))
+}
+
+func (s *stakeState) addVoter(candidate, voter common.Address, index uint64, capacity int64) {
+	voters := common.BigToHash(state.GetLocMappingAtKey(candidate.Hash(), 2))
+	s.set(state.GetLocDynamicArrAtElement(voters, index, 1), voter.Hash())
+	s.set(voters, common.BigToHash(new(big.Int).SetUint64(index+1)))
+
+	loc := state.GetLocOfStructElement(state.GetLocMappingAtKey(candidate.Hash(), 1), big.NewInt(2))
+	s.set(crypto.Keccak256Hash(voter.Hash().Bytes(), loc.Bytes()), common.BigToHash(big.NewInt(capacity)))
+}
+
+func TestStakeSnapshot(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	var (
+		first    = common.Address{0x01}
+		second   = common.Address{0x02}
+		resigned = common.Address{0x03}
+		owner    = common.Address{0x10}
+		voter    = common.Address{0x20}
+	)
+	stakes := &stakeState{statedb: statedb}
+	stakes.addCandidate(first, owner, 100)
+	stakes.addCandidate(second, owner, 300)
+	stakes.addCandidate(resigned, owner, 0)
+	stakes.addCandidate(second, owner, 300)
+	stakes.addVoter(second, owner, 0, 100)
+	stakes.addVoter(second, voter, 1, 200)
+	stakes.addVoter(second, voter, 2, 200)
+	stakes.addVoter(first, voter, 0, 0)
+
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	data, err := (&extra.Extra{Version: extra.VersionLegacy, Masternodes: []common.Address{second}}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	chain := &headerChain{config: &params.ChainConfig{}, headers: map[common.Hash]*types.Header{}}
+	checkpoint := &types.Header{Number: big.NewInt(900), Root: root, Extra: data}
+	chain.headers[checkpoint.Hash()] = checkpoint
+
+	engine := New(&params.PosvConfig{Epoch: 900}, db)
+	if _, err := engine.StakeSnapshot(chain, 2); err != errUnknownBlock {
+		t.Errorf("unknown epoch error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+	snap, err := engine.StakeSnapshot(chain, 1)
+	if err != nil {
+		t.Fatalf("failed to snapshot stakes: %v", err)
+	}
+	if snap.Epoch != 1 || snap.Number != 900 || snap.Hash != checkpoint.Hash() {
+		t.Errorf("snapshot checkpoint mismatch: have epoch %d block %d %x", snap.Epoch, snap.Number, snap.Hash)
+	}
+	if len(snap.Candidates) != 2 {
+		t.Fatalf("candidates mismatch: have %d, want 2", len(snap.Candidates))
+	}
+	top, other := snap.Candidates[0], snap.Candidates[1]
+	if top.Address != second || top.Capacity.Int64() != 300 || !top.Masternode || top.Owner != owner {
+		t.Errorf("top candidate mismatch: have %x cap %v masternode %v owner %x", top.Address, top.Capacity, top.Masternode, top.Owner)
+	}
+	if len(top.Voters) != 2 || top.Voters[0].Address != voter || top.Voters[0].Capacity.Int64() != 200 || top.Voters[1].Address != owner {
+		t.Errorf("top candidate voters mismatch: have %d voters", len(top.Voters))
+	}
+	if other.Address != first || other.Masternode || len(other.Voters) != 0 {
+		t.Errorf("other candidate mismatch: have %x masternode %v %d voters", other.Address, other.Masternode, len(other.Voters))
+	}
+}
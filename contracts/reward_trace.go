@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package contracts
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+// RewardTrace is the full calculation of the rewards paid at a reward
+// checkpoint: the signatures counted over the blocks from From to To, and the
+// share of the reward of the chain of each masternode that signed them.
+type RewardTrace struct {
+	Number           uint64               `json:"number"`
+	Hash             common.Hash          `json:"hash"`
+	From             uint64               `json:"from"`
+	To               uint64               `json:"to"`
+	ChainReward      *big.Int             `json:"chainReward"`
+	TotalSigns       uint64               `json:"totalSigns"`
+	Foundation       common.Address       `json:"foundation"`
+	FoundationReward *big.Int             `json:"foundationReward"` // sum of the foundation shares
+	Signers          []*SignerRewardTrace `json:"signers"`
+}
+
+// SignerRewardTrace is the reward of a masternode for its signatures, and its
+// split between the owner of the masternode, its voters and the foundation.
+type SignerRewardTrace struct {
+	Address          common.Address      `json:"address"`
+	Signs            uint64              `json:"signs"`
+	Reward           *big.Int            `json:"reward"`
+	Owner            common.Address      `json:"owner"`
+	OwnerReward      *big.Int            `json:"ownerReward"`
+	FoundationReward *big.Int            `json:"foundationReward"`
+	VotersCap        *big.Int            `json:"votersCap"` // capacity voted for the masternode
+	Voters           []*VoterRewardTrace `json:"voters"`
+}
+
+// VoterRewardTrace is the share of a voter in the reward of a masternode, in
+// proportion to the capacity it voted.
+type VoterRewardTrace struct {
+	Address common.Address `json:"address"`
+	Cap     *big.Int       `json:"cap"`
+	Reward  *big.Int       `json:"reward"`
+}
+
+// TraceRewards calculates the rewards of the reward checkpoint header as the
+// consensus does, from the state of its parent, without paying them.
+func TraceRewards(c *posv.Posv, chain consensus.ChainReader, header *types.Header, parentState *state.StateDB, chainReward *big.Int) (*RewardTrace, error) {
+	config := chain.Config().Posv
+	rCheckpoint := config.RewardCheckpoint
+	number := header.Number.Uint64()
+	trace := &RewardTrace{
+		Number:           number,
+		Hash:             header.Hash(),
+		From:             number - rCheckpoint*2 + 1,
+		To:               number - rCheckpoint,
+		ChainReward:      chainReward,
+		Foundation:       config.FoudationWalletAddr,
+		FoundationReward: new(big.Int),
+		Signers:          []*SignerRewardTrace{},
+	}
+	signers, err := GetRewardForCheckpoint(c, chain, header, rCheckpoint, &trace.TotalSigns)
+	if err != nil {
+		return nil, err
+	}
+	rewardSigners, err := CalculateRewardForSigner(chainReward, signers, trace.TotalSigns)
+	if err != nil {
+		return nil, err
+	}
+	for signer, reward := range rewardSigners {
+		signerTrace := &SignerRewardTrace{
+			Address:          signer,
+			Signs:            signers[signer].Sign,
+			Reward:           reward,
+			FoundationReward: new(big.Int),
+			VotersCap:        new(big.Int),
+			Voters:           []*VoterRewardTrace{},
+		}
+		if _, err := rewardBalancesRate(config.FoudationWalletAddr, parentState, signer, reward, number, signerTrace); err != nil {
+			return nil, err
+		}
+		sort.Slice(signerTrace.Voters, func(i, j int) bool {
+			return bytes.Compare(signerTrace.Voters[i].Address[:], signerTrace.Voters[j].Address[:]) < 0
+		})
+		trace.FoundationReward.Add(trace.FoundationReward, signerTrace.FoundationReward)
+		trace.Signers = append(trace.Signers, signerTrace)
+	}
+	sort.Slice(trace.Signers, func(i, j int) bool {
+		return bytes.Compare(trace.Signers[i].Address[:], trace.Signers[j].Address[:]) < 0
+	})
+	return trace, nil
+}
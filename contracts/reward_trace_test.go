@@ -0,0 +1,44 @@
+package contracts
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+func TestRewardBalancesRateTrace(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	foundation := common.HexToAddress("0x0000000000000000000000000000000000000068")
+	reward := big.NewInt(1000)
+
+	trace := &SignerRewardTrace{Address: acc1Addr, Reward: reward}
+	traced, err := rewardBalancesRate(foundation, statedb, acc1Addr, reward, 1800, trace)
+	if err != nil {
+		t.Fatalf("failed to trace reward split: %v", err)
+	}
+	balances, err := GetRewardBalancesRate(foundation, statedb, acc1Addr, reward, 1800)
+	if err != nil {
+		t.Fatalf("failed to split reward: %v", err)
+	}
+	if len(traced) != len(balances) {
+		t.Fatalf("traced balances mismatch: have %v, want %v", traced, balances)
+	}
+	for addr, balance := range balances {
+		if traced[addr].Cmp(balance) != 0 {
+			t.Errorf("balance of %x mismatch: have %v, want %v", addr, traced[addr], balance)
+		}
+	}
+	// Without voters, the owner gets its share and the foundation its own
+	if trace.Owner != (common.Address{}) || trace.OwnerReward.Cmp(big.NewInt(400)) != 0 {
+		t.Errorf("owner reward mismatch: have %x %v, want %x 400", trace.Owner, trace.OwnerReward, common.Address{})
+	}
+	if trace.FoundationReward.Cmp(big.NewInt(100)) != 0 || balances[foundation].Cmp(trace.FoundationReward) != 0 {
+		t.Errorf("foundation reward mismatch: have %v, want 100", trace.FoundationReward)
+	}
+	if len(trace.Voters) != 0 {
+		t.Errorf("voters traced without votes: %v", trace.Voters)
+	}
+}
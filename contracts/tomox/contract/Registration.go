@@ -203,10 +203,10 @@ func (_AbstractTOMOXListing *AbstractTOMOXListingCallerSession) GetTokenStatus(a
 }
 
 // RelayerRegistrationABI is the input ABI used to generate the binding from.
-const RelayerRegistrationABI = "[{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"fromToken\",\"type\":\"address\"},{\"name\":\"toToken\",\"type\":\"address\"}],\"name\":\"listToken\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MaximumRelayers\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"tradeFee\",\"type\":\"uint16\"}],\"name\":\"updateFee\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"owner\",\"type\":\"address\"}],\"name\":\"changeContractOwner\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"RELAYER_LIST\",\"outputs\":[{\"name\":\"_deposit\",\"type\":\"uint256\"},{\"name\":\"_tradeFee\",\"type\":\"uint16\"},{\"name\":\"_index\",\"type\":\"uint256\"},{\"name\":\"_owner\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"depositMore\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"RELAYER_COINBASES\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"RESIGN_REQUESTS\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"getRelayerByCoinbase\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"},{\"name\":\"\",\"type\":\"address\"},{\"name\":\"\",\"type\":\"uint256\"},{\"name\":\"\",\"type\":\"uint16\"},{\"name\":\"\",\"type\":\"address[]\"},{\"name\":\"\",\"type\":\"address[]\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"update\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"maxRelayer\",\"type\":\"uint256\"},{\"name\":\"maxToken\",\"type\":\"uint256\"},{\"name\":\"minDeposit\",\"type\":\"uint256\"}],\"name\":\"reconfigure\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"cancelSelling\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"ActiveRelayerCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"fromToken\",\"type\":\"address\"},{\"name\":\"toToken\",\"type\":\"address\"}],\"name\":\"deListToken\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"price\",\"type\":\"uint256\"}],\"name\":\"sellRelayer\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"RelayerCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"RELAYER_ON_SALE_LIST\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"resign\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"new_owner\",\"type\":\"address\"}],\"name\":\"transfer\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MinimumDeposit\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"register\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MaximumTokenList\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"buyRelayer\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"refund\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"CONTRACT_OWNER\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"name\":\"tomoxListing\",\"type\":\"address\"},{\"name\":\"maxRelayers\",\"type\":\"uint256\"},{\"name\":\"maxTokenList\",\"type\":\"uint256\"},{\"name\":\"minDeposit\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"max_relayer\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"max_token\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"min_deposit\",\"type\":\"uint256\"}],\"name\":\"ConfigEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"deposit\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"indexed\":false,\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"RegisterEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"deposit\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"indexed\":false,\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"UpdateEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"}],\"name\":\"UpdateFeeEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"owner\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"deposit\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"indexed\":false,\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"TransferEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"deposit_release_time\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"deposit_amount\",\"type\":\"uint256\"}],\"name\":\"ResignEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"success\",\"type\":\"bool\"},{\"indexed\":false,\"name\":\"remaining_time\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"deposit_amount\",\"type\":\"uint256\"}],\"name\":\"RefundEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"is_on_sale\",\"type\":\"bool\"},{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"price\",\"type\":\"uint256\"}],\"name\":\"SellEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"success\",\"type\":\"bool\"},{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"price\",\"type\":\"uint256\"}],\"name\":\"BuyEvent\",\"type\":\"event\"}]"
+const RelayerRegistrationABI = "[{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"fromToken\",\"type\":\"address\"},{\"name\":\"toToken\",\"type\":\"address\"}],\"name\":\"listToken\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MaximumRelayers\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"tradeFee\",\"type\":\"uint16\"}],\"name\":\"updateFee\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"owner\",\"type\":\"address\"}],\"name\":\"changeContractOwner\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"RELAYER_LIST\",\"outputs\":[{\"name\":\"_deposit\",\"type\":\"uint256\"},{\"name\":\"_tradeFee\",\"type\":\"uint16\"},{\"name\":\"_index\",\"type\":\"uint256\"},{\"name\":\"_owner\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"depositMore\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"name\":\"RELAYER_COINBASES\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"RESIGN_REQUESTS\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"getRelayerByCoinbase\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"},{\"name\":\"\",\"type\":\"address\"},{\"name\":\"\",\"type\":\"uint256\"},{\"name\":\"\",\"type\":\"uint16\"},{\"name\":\"\",\"type\":\"address[]\"},{\"name\":\"\",\"type\":\"address[]\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"update\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"maxRelayer\",\"type\":\"uint256\"},{\"name\":\"maxToken\",\"type\":\"uint256\"},{\"name\":\"minDeposit\",\"type\":\"uint256\"}],\"name\":\"reconfigure\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"cancelSelling\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"ActiveRelayerCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"fromToken\",\"type\":\"address\"},{\"name\":\"toToken\",\"type\":\"address\"}],\"name\":\"deListToken\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"price\",\"type\":\"uint256\"}],\"name\":\"sellRelayer\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"RelayerCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"RELAYER_ON_SALE_LIST\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"resign\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"new_owner\",\"type\":\"address\"}],\"name\":\"transfer\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"\",\"type\":\"address\"}],\"name\":\"RELAYER_MAKER_REBATES\",\"outputs\":[{\"name\":\"\",\"type\":\"uint16\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MinimumDeposit\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"register\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"MaximumTokenList\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"},{\"name\":\"makerRebate\",\"type\":\"uint16\"}],\"name\":\"updateMakerRebate\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"buyRelayer\",\"outputs\":[],\"payable\":true,\"stateMutability\":\"payable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"coinbase\",\"type\":\"address\"}],\"name\":\"refund\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"CONTRACT_OWNER\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"name\":\"tomoxListing\",\"type\":\"address\"},{\"name\":\"maxRelayers\",\"type\":\"uint256\"},{\"name\":\"maxTokenList\",\"type\":\"uint256\"},{\"name\":\"minDeposit\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"max_relayer\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"max_token\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"min_deposit\",\"type\":\"uint256\"}],\"name\":\"ConfigEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"deposit\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"indexed\":false,\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"RegisterEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"deposit\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"indexed\":false,\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"UpdateEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"}],\"name\":\"UpdateFeeEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"makerRebate\",\"type\":\"uint16\"}],\"name\":\"UpdateMakerRebateEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"owner\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"deposit\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"tradeFee\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"fromTokens\",\"type\":\"address[]\"},{\"indexed\":false,\"name\":\"toTokens\",\"type\":\"address[]\"}],\"name\":\"TransferEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"deposit_release_time\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"deposit_amount\",\"type\":\"uint256\"}],\"name\":\"ResignEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"success\",\"type\":\"bool\"},{\"indexed\":false,\"name\":\"remaining_time\",\"type\":\"uint256\"},{\"indexed\":false,\"name\":\"deposit_amount\",\"type\":\"uint256\"}],\"name\":\"RefundEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"is_on_sale\",\"type\":\"bool\"},{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"price\",\"type\":\"uint256\"}],\"name\":\"SellEvent\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"success\",\"type\":\"bool\"},{\"indexed\":false,\"name\":\"coinbase\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"price\",\"type\":\"uint256\"}],\"name\":\"BuyEvent\",\"type\":\"event\"}]"
 
 // RelayerRegistrationBin is the compiled bytecode used for deploying new contracts.
-const RelayerRegistrationBin = `0x608060405234801561001057600080fd5b50604051608080613d298339810160409081528151602083015191830151606090930151600a8054600160a060020a03909316600160a060020a0319938416179055600060078190556009819055600193909355600293909355600892909255805490911633179055613ca1806100886000396000f30060806040526004361061012f5763ffffffff60e060020a60003504166308764b9d81146101345780630e5c0fee146101635780633ea2391f1461018a5780633ead67b5146101b257806349ba1f70146101d35780634ce69bf5146102265780634fa339271461023a578063500f99f71461026e578063540105c71461028f57806356246b681461037f57806357ea3c41146104235780635b673b1f14610441578063735db683146104625780637aa667301461047757806387c6bbcd146104a457806387d340ab146104c8578063885b7137146104dd578063ae6e43f5146104fe578063ba45b0b81461051f578063c635a9f214610546578063c6c71aed1461055b578063cfaece12146105f2578063e699df0e14610607578063fa89401a1461061b578063fd301c491461063c575b600080fd5b34801561014057600080fd5b50610161600160a060020a0360043581169060243581169060443516610651565b005b34801561016f57600080fd5b506101786109c1565b60408051918252519081900360200190f35b34801561019657600080fd5b50610161600160a060020a036004351661ffff602435166109c7565b3480156101be57600080fd5b50610161600160a060020a0360043516610bf0565b3480156101df57600080fd5b506101f4600160a060020a0360043516610c89565b6040805194855261ffff909316602085015283830191909152600160a060020a03166060830152519081900360800190f35b610161600160a060020a0360043516610cbe565b34801561024657600080fd5b50610252600435611010565b60408051600160a060020a039092168252519081900360200190f35b34801561027a57600080fd5b50610178600160a060020a036004351661102b565b34801561029b57600080fd5b506102b0600160a060020a036004351661103d565b6040518087815260200186600160a060020a0316600160a060020a031681526020018581526020018461ffff1661ffff1681526020018060200180602001838103835285818151815260200191508051906020019060200280838360005b8381101561032657818101518382015260200161030e565b50505050905001838103825284818151815260200191508051906020019060200280838360005b8381101561036557818101518382015260200161034d565b505050509050019850505050505050505060405180910390f35b34801561038b57600080fd5b506040805160206004604435818101358381028086018501909652808552610161958335600160a060020a0316956024803561ffff1696369695606495939492019291829185019084908082843750506040805187358901803560208181028481018201909552818452989b9a9989019892975090820195509350839250850190849080828437509497506111509650505050505050565b34801561042f57600080fd5b50610161600435602435604435611595565b34801561044d57600080fd5b50610161600160a060020a0360043516611684565b34801561046e57600080fd5b50610178611850565b34801561048357600080fd5b50610161600160a060020a0360043581169060243581169060443516611856565b3480156104b057600080fd5b50610161600160a060020a0360043516602435611b79565b3480156104d457600080fd5b50610178611d30565b3480156104e957600080fd5b50610178600160a060020a0360043516611d36565b34801561050a57600080fd5b50610161600160a060020a0360043516611d48565b34801561052b57600080fd5b50610161600160a060020a0360043581169060243516611f94565b34801561055257600080fd5b506101786122dc565b6040805160206004604435818101358381028086018501909652808552610161958335600160a060020a0316956024803561ffff1696369695606495939492019291829185019084908082843750506040805187358901803560208181028481018201909552818452989b9a9989019892975090820195509350839250850190849080828437509497506122e29650505050505050565b3480156105fe57600080fd5b5061017861299f565b610161600160a060020a03600435166129a5565b34801561062757600080fd5b50610161600160a060020a0360043516612c5e565b34801561064857600080fd5b50610252612f7b565b600160a060020a0383811660009081526003602052604090206005015484911633146106b5576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038416600090815260056020526040902054849015610727576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038516600090815260066020526040902054859015610799576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60028054600160a060020a0388166000908152600360205260409020909101541061080e576040805160e560020a62461bcd02815260206004820152601f60248201527f457863656564696e67206e756d626572206f6620747261646520706169727300604482015290519081900360640190fd5b610819868686612f8a565b1515600114610872576040805160e560020a62461bcd02815260206004820152600c60248201527f496e76616c696420706169720000000000000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a0380871660008181526003602081815260408084206002810180546001818101835582885285882090910180548f8b16600160a060020a031991821617909155958301805480830182558189528689200180549a8f169a909716999099179095559590945283549290930154835183815261ffff90911691810182905260809381018481528554948201859052600080516020613c168339815191529693959294929392606083019060a08401908690801561095e57602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610940575b505083810382528481815481526020019150805480156109a757602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610989575b5050965050505050505060405180910390a1505050505050565b60015481565b600160a060020a038281166000908152600360205260409020600501548391163314610a2b576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038316600090815260056020526040902054839015610a9d576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038416600090815260066020526040902054849015610b0f576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60008461ffff1610158015610b2957506103e88461ffff16105b1515610b7f576040805160e560020a62461bcd02815260206004820152601160248201527f496e76616c6964204d616b657220466565000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a038516600081815260036020908152604091829020600101805461ffff191661ffff89811691909117918290558351948552169083015280517f5a4f79c1a68f4f5ef7148ac4f279a5a6caeb3237082c64f692e92c9e02ffc4599281900390910190a15050505050565b600054600160a060020a03163314610c52576040805160e560020a62461bcd02815260206004820152601460248201527f436f6e7472616374204f776e6572204f6e6c792e000000000000000000000000604482015290519081900360640190fd5b600160a060020a0381161515610c6757600080fd5b60008054600160a060020a031916600160a060020a0392909216919091179055565b6003602052600090815260409020805460018201546004830154600590930154919261ffff90911691600160a060020a031684565b600160a060020a038181166000908152600360205260409020600501548291163314610d22576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038216600090815260056020526040902054829015610d94576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038316600090815260066020526040902054839015610e06576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60003411610e5e576040805160e560020a62461bcd02815260206004820152601a60248201527f5472616e736665722076616c7565206d757374206265203e2030000000000000604482015290519081900360640190fd5b670de0b6b3a7640000341015610ee4576040805160e560020a62461bcd02815260206004820152603160248201527f4174206c65617374203120544f4d4f20697320726571756972656420666f722060448201527f61206465706f7369742072657175657374000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a038416600090815260036020526040902054610f0d903463ffffffff61333c16565b600160a060020a0385166000908152600360208181526040928390208481556001810154845186815261ffff9091169281018390526080948101858152600283018054968301879052600080516020613c16833981519152979694959094930192606083019060a084019086908015610faf57602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610f91575b50508381038252848181548152602001915080548015610ff857602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610fda575b5050965050505050505060405180910390a150505050565b600460205260009081526040902054600160a060020a031681565b60056020526000908152604090205481565b600160a060020a03808216600090815260036020818152604080842060048101546005820154825460018401546002850180548751818a0281018a01909852808852999a8b9a8b9a8b9a60609a8b9a989094169761ffff90961695909101929184918301828280156110d857602002820191906000526020600020905b8154600160a060020a031681526001909101906020018083116110ba575b505050505091508080548060200260200160405190810160405280929190818152602001828054801561113457602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611116575b5050505050905095509550955095509550955091939550919395565b600160a060020a0384811660009081526003602052604090206005015485911633146111b4576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038516600090815260056020526040902054859015611226576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038616600090815260066020526040902054869015611298576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60008661ffff16101580156112b257506103e88661ffff16105b1515611308576040805160e560020a62461bcd02815260206004820152601160248201527f496e76616c6964204d616b657220466565000000000000000000000000000000604482015290519081900360640190fd5b60025485511115611363576040805160e560020a62461bcd02815260206004820152601f60248201527f457863656564696e67206e756d626572206f6620747261646520706169727300604482015290519081900360640190fd5b84518451146113bc576040805160e560020a62461bcd02815260206004820152601960248201527f4e6f742076616c6964206e756d626572206f6620506169727300000000000000604482015290519081900360640190fd5b6113c68585613359565b151560011461141f576040805160e560020a62461bcd02815260206004820152601460248201527f496e76616c69642071756f746520746f6b656e73000000000000000000000000604482015290519081900360640190fd5b600160a060020a038716600090815260036020908152604090912060018101805461ffff191661ffff8a16179055865161146192600290920191880190613aee565b50600160a060020a0387166000908152600360208181526040909220865161149193919092019190870190613aee565b50600160a060020a03871660009081526003602081815260409283902080546001820154855182815261ffff9091169381018490526080958101868152600284018054978301889052600080516020613c1683398151915297939690940192606083019060a08401908690801561153157602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611513575b5050838103825284818154815260200191508054801561157a57602002820191906000526020600020905b8154600160a060020a0316815260019091019060200180831161155c575b5050965050505050505060405180910390a150505050505050565b600054600160a060020a031633146115f7576040805160e560020a62461bcd02815260206004820152601460248201527f436f6e7472616374204f776e6572204f6e6c792e000000000000000000000000604482015290519081900360640190fd5b60095483101561160657600080fd5b60048211801561161757506103e982105b151561162257600080fd5b612710811161163057600080fd5b600183905560028290556008819055604080518481526020810184905280820183905290517f8f6bd709a98381db4e403a67ba106d598972dad177e946f19b54777f54d939239181900360600190a1505050565b600160a060020a0381811660009081526003602052604090206005015482911633146116e8576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a03821660009081526005602052604090205482901561175a576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038316600090815260066020526040812054116117ee576040805160e560020a62461bcd02815260206004820152602160248201527f52656c61796572206973206e6f742063757272656e746c7920666f722073616c60448201527f6500000000000000000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a03831660008181526006602090815260408083208390558051838152918201939093528083019190915290517fdb3d5e65fcde89731529c01d62b87bab1c64471cffdd528fc1adbc1712b5d0829181900360600190a1505050565b60095481565b600160a060020a03838116600090815260036020526040902060050154606091829186911633146118bf576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038616600090815260056020526040902054869015611931576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a0387166000908152600660205260409020548790156119a3576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b6119ae888888613718565b945094506119bc8585613359565b1515600114611a15576040805160e560020a62461bcd02815260206004820152601460248201527f496e76616c69642071756f746520746f6b656e73000000000000000000000000604482015290519081900360640190fd5b600160a060020a03881660009081526003602090815260409091208651611a4492600290920191880190613aee565b50600160a060020a03881660009081526003602081815260409092208651611a7493919092019190870190613aee565b50600160a060020a03881660009081526003602081815260409283902080546001820154855182815261ffff9091169381018490526080958101868152600284018054978301889052600080516020613c1683398151915297939690940192606083019060a084019086908015611b1457602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611af6575b50508381038252848181548152602001915080548015611b5d57602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611b3f575b5050965050505050505060405180910390a15050505050505050565b600160a060020a038281166000908152600360205260409020600501548391163314611bdd576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038316600090815260056020526040902054839015611c4f576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b60008311611ccd576040805160e560020a62461bcd02815260206004820152602860248201527f507269636520746167206d75737420626520646966666572656e74207468616e60448201527f205a65726f283029000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a03841660008181526006602090815260409182902086905581516001815290810192909252818101859052517fdb3d5e65fcde89731529c01d62b87bab1c64471cffdd528fc1adbc1712b5d0829181900360600190a150505050565b60075481565b60066020526000908152604090205481565b600160a060020a038181166000908152600360205260409020600501548291163314611dac576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038216600090815260066020526040902054829015611e1e576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b600160a060020a03831660009081526003602052604081205411611eb2576040805160e560020a62461bcd02815260206004820152602760248201527f4e6f2072656c61796572206173736f636961746564207769746820746869732060448201527f6164647265737300000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a03831660009081526005602052604090205415611f20576040805160e560020a62461bcd02815260206004820152601860248201527f5265717565737420616c72656164792072656365697665640000000000000000604482015290519081900360640190fd5b600160a060020a03831660009081526005602090815260408083206224ea0042018155600980546000190190555460038352928190205481519384529183019190915280517f2e821a4329d6351a6b13fe0c12fd7674cd0f4a2283685a4713e1325f36415ae59281900390910190a1505050565b600160a060020a038281166000908152600360205260409020600501548391163314611ff8576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a03831660009081526005602052604090205483901561206a576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a0384166000908152600660205260409020548490156120dc576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b600160a060020a038416158015906120fd5750600160a060020a0384163314155b151561210857600080fd5b600160a060020a0384811660009081526003602052604090206005015416156121a1576040805160e560020a62461bcd02815260206004820152603c60248201527f4f776e65722061646472657373206d757374206e6f742062652063757272656e60448201527f746c7920757365642061732072656c617965722d636f696e6261736500000000606482015290519081900360840190fd5b600160a060020a03858116600090815260036020818152604092839020600581018054600160a060020a0319168a871617908190558154600183015486519290971680835293820181905261ffff90961694810185905260a0606082018181526002840180549284018390527fc13ab794f75ba420a1f52192a8e35a2cf2c74ae31ed94f53f47ce7712011b66298959795969094019291608083019060c08401908690801561227957602002820191906000526020600020905b8154600160a060020a0316815260019091019060200180831161225b575b505083810382528481815481526020019150805480156122c257602002820191906000526020600020905b8154600160a060020a031681526001909101906020018083116122a4575b505097505050505050505060405180910390a15050505050565b60085481565b600054600160a060020a031633141561236b576040805160e560020a62461bcd02815260206004820152602f60248201527f436f6e7472616374204f776e657220697320666f7262696464656e20746f206360448201527f726561746520612052656c617965720000000000000000000000000000000000606482015290519081900360840190fd5b33600160a060020a03851614156123f2576040805160e560020a62461bcd02815260206004820152603660248201527f436f696e6261736520616e642052656c617965724f776e65722061646472657360448201527f73206d757374206e6f74206265207468652073616d6500000000000000000000606482015290519081900360840190fd5b600054600160a060020a038581169116141561247e576040805160e560020a62461bcd02815260206004820152602b60248201527f436f696e62617365206d757374206e6f742062652073616d6520617320434f4e60448201527f54524143545f4f574e4552000000000000000000000000000000000000000000606482015290519081900360840190fd5b6008543410156124d8576040805160e560020a62461bcd02815260206004820152601e60248201527f4d696e696d756d206465706f736974206e6f74207361746973666965642e0000604482015290519081900360640190fd5b60008361ffff16101580156124f257506103e88361ffff16105b1515612548576040805160e560020a62461bcd02815260206004820152601160248201527f496e76616c6964204d616b657220466565000000000000000000000000000000604482015290519081900360640190fd5b600254825111156125a3576040805160e560020a62461bcd02815260206004820152601f60248201527f457863656564696e67206e756d626572206f6620747261646520706169727300604482015290519081900360640190fd5b81518151146125fc576040805160e560020a62461bcd02815260206004820152601960248201527f4e6f742076616c6964206e756d626572206f6620506169727300000000000000604482015290519081900360640190fd5b600160a060020a0384166000908152600360205260409020541561266a576040805160e560020a62461bcd02815260206004820152601c60248201527f436f696e6261736520616c726561647920726567697374657265642e00000000604482015290519081900360640190fd5b600160a060020a038416600090815260056020526040902054156126da576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b60015460095410612735576040805160e560020a62461bcd02815260206004820152601b60248201527f4d6178696d756d2072656c617965727320726567697374657265640000000000604482015290519081900360640190fd5b61273f8282613359565b1515600114612798576040805160e560020a62461bcd02815260206004820152601460248201527f496e76616c69642071756f746520746f6b656e73000000000000000000000000604482015290519081900360640190fd5b6007805460009081526004602090815260408083208054600160a060020a031916600160a060020a038a16908117909155815160c08101835234815261ffff8981168286019081528285018a8152606084018a9052975460808401523360a0840152928652600385529290942084518155905160018201805461ffff19169190931617909155925180519293926128359260028501920190613aee565b5060608201518051612851916003840191602090910190613aee565b50608082810151600483015560a09283015160059092018054600160a060020a031916600160a060020a039384161790556007805460019081019091556009805482019055918716600090815260036020818152604092839020805495810154845187815261ffff9091169281018390529384018581526002820180549686018790527fcf24380d990b0bb3dd21518926bca48f81495ac131ee92655696db28c43b1b1b9893969095929094019391929091606084019184019086908015610faf57602002820191906000526020600020908154600160a060020a03168152600190910190602001808311610f915750508381038252848181548152602001915080548015610ff857602002820191906000526020600020908154600160a060020a03168152600190910190602001808311610fda575050965050505050505060405180910390a150505050565b60025481565b600160a060020a0381166000908152600560205260408120548190839015612a19576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a03841660009081526006602052604081205493508311612ab0576040805160e560020a62461bcd02815260206004820152602160248201527f52656c61796572206973206e6f742063757272656e746c7920666f722073616c60448201527f6500000000000000000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b348314612b07576040805160e560020a62461bcd02815260206004820152601960248201527f50726963652d746167206d757374206265206d61746368656400000000000000604482015290519081900360640190fd5b600160a060020a038085166000908152600360205260409020600501541691503315801590612b3f575033600160a060020a03831614155b8015612b535750600160a060020a03821615155b1515612ba9576040805160e560020a62461bcd02815260206004820152601160248201527f41646472657373206e6f742076616c6964000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a0380851660009081526003602090815260408083206005018054600160a060020a031916331790556006909152808220829055519184169185156108fc0291869190818181858888f19350505050158015612c0f573d6000803e3d6000fd5b506040805160018152600160a060020a0386166020820152348183015290517f07e248a3b3d2184a9491c3b45089a6e15aac742b9d974e691e7beb0f6e7c58c69181900360600190a150505050565b600160a060020a038181166000908152600360205260408120600501549091829182918591163314612cc8576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038516600090815260066020526040902054859015612d3a576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b600160a060020a03861660009081526005602052604081205411612da8576040805160e560020a62461bcd02815260206004820152601160248201527f52657175657374206e6f7420666f756e64000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a0386166000908152600360209081526040808320805460049091015460059093529220549196509450421115612f1657600160a060020a038616600090815260036020526040812081815560018101805461ffff1916905590612e156002830182613b53565b612e23600383016000613b53565b506000600482810182905560059283018054600160a060020a0319908116909155600160a060020a038a811684526020948552604080852085905560078054600019908101875285885282872080548087169091558c88528388208054919095169516851790935583865260039096528085209093018990558454019093555191945033916108fc88150291889190818181858888f19350505050158015612ecf573d6000803e3d6000fd5b5060408051600181526000602082015280820187905290517ffaba1aac53309af4c1c439f38c29500d3828405ee1ca5e7641b0432d17d302509181900360600190a1612f73565b600160a060020a038616600090815260056020908152604080832054815193845242900391830191909152818101879052517ffaba1aac53309af4c1c439f38c29500d3828405ee1ca5e7641b0432d17d302509181900360600190a15b505050505050565b600054600160a060020a031681565b600160a060020a0383166000908152600360208181526040808420909201548251600190910180825280830282019092019092528291606091839182918015612fdd578160200160208202803883390190505b50600a54604080517fa3ff31b5000000000000000000000000000000000000000000000000000000008152600160a060020a038a81166004830152915193965091169163a3ff31b5916024808201926020929091908290030181600087803b15801561304857600080fd5b505af115801561305c573d6000803e3d6000fd5b505050506040513d602081101561307257600080fd5b5051806130885750600160a060020a0386166001145b915081801561313a5750600a54604080517fa3ff31b5000000000000000000000000000000000000000000000000000000008152600160a060020a038a811660048301529151919092169163a3ff31b59160248083019260209291908290030181600087803b1580156130fa57600080fd5b505af115801561310e573d6000803e3d6000fd5b505050506040513d602081101561312457600080fd5b50518061313a5750600160a060020a0387166001145b915081151561314c5760009450613331565b600160a060020a0387166001148061316d5750600160a060020a0386166001145b1561317b5760019450613331565b5060005b600160a060020a0388166000908152600360208190526040909120015481101561331357600160a060020a038816600090815260036020819052604090912001805460019190839081106131cf57fe5b600091825260209091200154600160a060020a0316141561325957600160a060020a038816600090815260036020526040902060020180548290811061321157fe5b6000918252602090912001548351600160a060020a039091169084908690811061323757fe5b600160a060020a0390921660209283029091019091015260019093019261330b565b600160a060020a03881660009081526003602052604090206002018054600191908390811061328457fe5b600091825260209091200154600160a060020a0316141561330b57600160a060020a0388166000908152600360208190526040909120018054829081106132c757fe5b6000918252602090912001548351600160a060020a03909116908490869081106132ed57fe5b600160a060020a039092166020928302909101909101526001909301925b60010161317f565b61331d8387613a95565b151561332c5760009450613331565b600194505b505050509392505050565b60008282018381101561334e57600080fd5b8091505b5092915050565b60008060006060806000806000809650600095508951604051908082528060200260200182016040528015613398578160200160208202803883390190505b50945089516040519080825280602002602001820160405280156133c6578160200160208202803883390190505b509350600092505b88518310156136c257600a548951600160a060020a039091169063a3ff31b5908b90869081106133fa57fe5b906020019060200201516040518263ffffffff1660e060020a0281526004018082600160a060020a0316600160a060020a03168152602001915050602060405180830381600087803b15801561344f57600080fd5b505af1158015613463573d6000803e3d6000fd5b505050506040513d602081101561347957600080fd5b5051806134a7575088516001908a908590811061349257fe5b90602001906020020151600160a060020a0316145b91508180156135815750600a548a51600160a060020a039091169063a3ff31b5908c90869081106134d457fe5b906020019060200201516040518263ffffffff1660e060020a0281526004018082600160a060020a0316600160a060020a03168152602001915050602060405180830381600087803b15801561352957600080fd5b505af115801561353d573d6000803e3d6000fd5b505050506040513d602081101561355357600080fd5b505180613581575089516001908b908590811061356c57fe5b90602001906020020151600160a060020a0316145b9150811515613593576000975061370b565b88516001908a90859081106135a457fe5b90602001906020020151600160a060020a031614156136055789838151811015156135cb57fe5b9060200190602002015185888151811015156135e357fe5b600160a060020a039092166020928302909101909101526001909601956136b7565b89516001908b908590811061361657fe5b90602001906020020151600160a060020a0316141561367357888381518110151561363d57fe5b90602001906020020151858881518110151561365557fe5b600160a060020a039092166020928302909101909101526001909601955b888381518110151561368157fe5b90602001906020020151848781518110151561369957fe5b600160a060020a039092166020928302909101909101526001909501945b6001909201916133ce565b5060005b85811015613706576136ef8585838151811015156136e057fe5b90602001906020020151613a95565b15156136fe576000975061370b565b6001016136c6565b600197505b5050505050505092915050565b6060806060806000806060806000600360008d600160a060020a0316600160a060020a031681526020019081526020016000206003018054905060405190808252806020026020018201604052801561377b578160200160208202803883390190505b50600160a060020a038d16600090815260036020818152604092839020909101548251818152818302810190920190925291985080156137c5578160200160208202803883390190505b50955060009450600093505b600160a060020a038c166000908152600360208190526040909120015484101561395c57600160a060020a038c81166000908152600360208190526040909120018054918c16918690811061382257fe5b600091825260209091200154600160a060020a03161415806138835750600160a060020a038c811660009081526003602052604090206002018054918d16918690811061386b57fe5b600091825260209091200154600160a060020a031614155b1561395157600160a060020a038c1660009081526003602052604090206002018054859081106138af57fe5b6000918252602090912001548751600160a060020a03909116908890879081106138d557fe5b600160a060020a039283166020918202909201810191909152908d166000908152600391829052604090200180548590811061390d57fe5b6000918252602090912001548651600160a060020a039091169087908790811061393357fe5b600160a060020a039092166020928302909101909101526001909401935b6001909301926137d1565b600160a060020a038c16600090815260036020819052604090912001548514613a7f5760018651036040519080825280602002602001820160405280156139ad578160200160208202803883390190505b50925060018651036040519080825280602002602001820160405280156139de578160200160208202803883390190505b509150600090505b6001865103811015613a74578681815181101515613a0057fe5b906020019060200201518382815181101515613a1857fe5b600160a060020a039092166020928302909101909101528551869082908110613a3d57fe5b906020019060200201518282815181101515613a5557fe5b600160a060020a039092166020928302909101909101526001016139e6565b828298509850613a86565b8686985098505b50505050505050935093915050565b6000805b8351811015613ae45782600160a060020a03168482815181101515613aba57fe5b90602001906020020151600160a060020a03161415613adc5760019150613352565b600101613a99565b5060009392505050565b828054828255906000526020600020908101928215613b43579160200282015b82811115613b435782518254600160a060020a031916600160a060020a03909116178255602090920191600190910190613b0e565b50613b4f929150613b74565b5090565b5080546000825590600052602060002090810190613b719190613b9b565b50565b613b9891905b80821115613b4f578054600160a060020a0319168155600101613b7a565b90565b613b9891905b80821115613b4f5760008155600101613ba1560052656c61796572204f776e6572204f6e6c792e000000000000000000000000005468652072656c6179657220686173206265656e2072657175657374656420745468652072656c61796572206d757374206265206e6f742063757272656e746ccaa8c94daf6ecfd00518cea95158f5273730574cca907eb0cd47e50732314c4f6f20636c6f73652e0000000000000000000000000000000000000000000000007920666f722053616c6500000000000000000000000000000000000000000000a165627a7a723058207d9c49eecfd59b2ae9911da7296be4a9f5e5d938cbf3dfb6327f454bc1c46a4f0029`
+const RelayerRegistrationBin = `0x608060405234801561001057600080fd5b506040516080806140028339810160409081528151602083015191830151606090930151600a8054600160a060020a03909316600160a060020a0319938416179055600060078190556009819055600193909355600293909355600892909255805490911633179055613f7a806100886000396000f30060806040526004361061012f5763ffffffff60e060020a60003504166308764b9d81146101345780630e5c0fee146101635780633ea2391f1461018a5780633ead67b5146101b257806349ba1f70146101d35780634ce69bf5146102265780634fa339271461023a578063500f99f71461026e578063540105c71461028f57806356246b681461037f57806357ea3c41146104235780635b673b1f14610441578063735db683146104625780637aa667301461047757806387c6bbcd146104a457806387d340ab146104c8578063885b7137146104dd578063ae6e43f5146104fe578063ba45b0b81461051f578063c635a9f214610546578063c6c71aed1461055b578063cfaece12146105f2578063e699df0e14610607578063fa89401a14613f44578063fd301c491461063c575b613ca1565b34801561014057600080fd5b50610161600160a060020a0360043581169060243581169060443516610651565b005b34801561016f57600080fd5b506101786109c1565b60408051918252519081900360200190f35b34801561019657600080fd5b50610161600160a060020a036004351661ffff602435166109c7565b3480156101be57600080fd5b50610161600160a060020a0360043516610bf0565b3480156101df57600080fd5b506101f4600160a060020a0360043516610c89565b6040805194855261ffff909316602085015283830191909152600160a060020a03166060830152519081900360800190f35b610161600160a060020a0360043516610cbe565b34801561024657600080fd5b50610252600435611010565b60408051600160a060020a039092168252519081900360200190f35b34801561027a57600080fd5b50610178600160a060020a036004351661102b565b34801561029b57600080fd5b506102b0600160a060020a036004351661103d565b6040518087815260200186600160a060020a0316600160a060020a031681526020018581526020018461ffff1661ffff1681526020018060200180602001838103835285818151815260200191508051906020019060200280838360005b8381101561032657818101518382015260200161030e565b50505050905001838103825284818151815260200191508051906020019060200280838360005b8381101561036557818101518382015260200161034d565b505050509050019850505050505050505060405180910390f35b34801561038b57600080fd5b506040805160206004604435818101358381028086018501909652808552610161958335600160a060020a0316956024803561ffff1696369695606495939492019291829185019084908082843750506040805187358901803560208181028481018201909552818452989b9a9989019892975090820195509350839250850190849080828437509497506111509650505050505050565b34801561042f57600080fd5b50610161600435602435604435611595565b34801561044d57600080fd5b50610161600160a060020a0360043516611684565b34801561046e57600080fd5b50610178611850565b34801561048357600080fd5b50610161600160a060020a0360043581169060243581169060443516611856565b3480156104b057600080fd5b50610161600160a060020a0360043516602435611b79565b3480156104d457600080fd5b50610178611d30565b3480156104e957600080fd5b50610178600160a060020a0360043516611d36565b34801561050a57600080fd5b50610161600160a060020a0360043516611d48565b34801561052b57600080fd5b50610161600160a060020a0360043581169060243516611f94565b34801561055257600080fd5b506101786122dc565b6040805160206004604435818101358381028086018501909652808552610161958335600160a060020a0316956024803561ffff1696369695606495939492019291829185019084908082843750506040805187358901803560208181028481018201909552818452989b9a9989019892975090820195509350839250850190849080828437509497506122e29650505050505050565b3480156105fe57600080fd5b5061017861299f565b610161600160a060020a03600435166129a5565b34801561062757600080fd5b50610161600160a060020a0360043516612c5e565b34801561064857600080fd5b50610252612f7b565b600160a060020a0383811660009081526003602052604090206005015484911633146106b5576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038416600090815260056020526040902054849015610727576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038516600090815260066020526040902054859015610799576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60028054600160a060020a0388166000908152600360205260409020909101541061080e576040805160e560020a62461bcd02815260206004820152601f60248201527f457863656564696e67206e756d626572206f6620747261646520706169727300604482015290519081900360640190fd5b610819868686612f8a565b1515600114610872576040805160e560020a62461bcd02815260206004820152600c60248201527f496e76616c696420706169720000000000000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a0380871660008181526003602081815260408084206002810180546001818101835582885285882090910180548f8b16600160a060020a031991821617909155958301805480830182558189528689200180549a8f169a909716999099179095559590945283549290930154835183815261ffff90911691810182905260809381018481528554948201859052600080516020613c168339815191529693959294929392606083019060a08401908690801561095e57602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610940575b505083810382528481815481526020019150805480156109a757602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610989575b5050965050505050505060405180910390a1505050505050565b60015481565b600160a060020a038281166000908152600360205260409020600501548391163314610a2b576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038316600090815260056020526040902054839015610a9d576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038416600090815260066020526040902054849015610b0f576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60008461ffff1610158015610b2957506103e88461ffff16105b1515610b7f576040805160e560020a62461bcd02815260206004820152601160248201527f496e76616c6964204d616b657220466565000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a038516600081815260036020908152604091829020600101805461ffff191661ffff89811691909117918290558351948552169083015280517f5a4f79c1a68f4f5ef7148ac4f279a5a6caeb3237082c64f692e92c9e02ffc4599281900390910190a15050505050565b600054600160a060020a03163314610c52576040805160e560020a62461bcd02815260206004820152601460248201527f436f6e7472616374204f776e6572204f6e6c792e000000000000000000000000604482015290519081900360640190fd5b600160a060020a0381161515610c6757600080fd5b60008054600160a060020a031916600160a060020a0392909216919091179055565b6003602052600090815260409020805460018201546004830154600590930154919261ffff90911691600160a060020a031684565b600160a060020a038181166000908152600360205260409020600501548291163314610d22576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038216600090815260056020526040902054829015610d94576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038316600090815260066020526040902054839015610e06576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60003411610e5e576040805160e560020a62461bcd02815260206004820152601a60248201527f5472616e736665722076616c7565206d757374206265203e2030000000000000604482015290519081900360640190fd5b670de0b6b3a7640000341015610ee4576040805160e560020a62461bcd02815260206004820152603160248201527f4174206c65617374203120544f4d4f20697320726571756972656420666f722060448201527f61206465706f7369742072657175657374000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a038416600090815260036020526040902054610f0d903463ffffffff61333c16565b600160a060020a0385166000908152600360208181526040928390208481556001810154845186815261ffff9091169281018390526080948101858152600283018054968301879052600080516020613c16833981519152979694959094930192606083019060a084019086908015610faf57602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610f91575b50508381038252848181548152602001915080548015610ff857602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311610fda575b5050965050505050505060405180910390a150505050565b600460205260009081526040902054600160a060020a031681565b60056020526000908152604090205481565b600160a060020a03808216600090815260036020818152604080842060048101546005820154825460018401546002850180548751818a0281018a01909852808852999a8b9a8b9a8b9a60609a8b9a989094169761ffff90961695909101929184918301828280156110d857602002820191906000526020600020905b8154600160a060020a031681526001909101906020018083116110ba575b505050505091508080548060200260200160405190810160405280929190818152602001828054801561113457602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611116575b5050505050905095509550955095509550955091939550919395565b600160a060020a0384811660009081526003602052604090206005015485911633146111b4576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038516600090815260056020526040902054859015611226576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038616600090815260066020526040902054869015611298576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60008661ffff16101580156112b257506103e88661ffff16105b1515611308576040805160e560020a62461bcd02815260206004820152601160248201527f496e76616c6964204d616b657220466565000000000000000000000000000000604482015290519081900360640190fd5b60025485511115611363576040805160e560020a62461bcd02815260206004820152601f60248201527f457863656564696e67206e756d626572206f6620747261646520706169727300604482015290519081900360640190fd5b84518451146113bc576040805160e560020a62461bcd02815260206004820152601960248201527f4e6f742076616c6964206e756d626572206f6620506169727300000000000000604482015290519081900360640190fd5b6113c68585613359565b151560011461141f576040805160e560020a62461bcd02815260206004820152601460248201527f496e76616c69642071756f746520746f6b656e73000000000000000000000000604482015290519081900360640190fd5b600160a060020a038716600090815260036020908152604090912060018101805461ffff191661ffff8a16179055865161146192600290920191880190613aee565b50600160a060020a0387166000908152600360208181526040909220865161149193919092019190870190613aee565b50600160a060020a03871660009081526003602081815260409283902080546001820154855182815261ffff9091169381018490526080958101868152600284018054978301889052600080516020613c1683398151915297939690940192606083019060a08401908690801561153157602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611513575b5050838103825284818154815260200191508054801561157a57602002820191906000526020600020905b8154600160a060020a0316815260019091019060200180831161155c575b5050965050505050505060405180910390a150505050505050565b600054600160a060020a031633146115f7576040805160e560020a62461bcd02815260206004820152601460248201527f436f6e7472616374204f776e6572204f6e6c792e000000000000000000000000604482015290519081900360640190fd5b60095483101561160657600080fd5b60048211801561161757506103e982105b151561162257600080fd5b612710811161163057600080fd5b600183905560028290556008819055604080518481526020810184905280820183905290517f8f6bd709a98381db4e403a67ba106d598972dad177e946f19b54777f54d939239181900360600190a1505050565b600160a060020a0381811660009081526003602052604090206005015482911633146116e8576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a03821660009081526005602052604090205482901561175a576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038316600090815260066020526040812054116117ee576040805160e560020a62461bcd02815260206004820152602160248201527f52656c61796572206973206e6f742063757272656e746c7920666f722073616c60448201527f6500000000000000000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a03831660008181526006602090815260408083208390558051838152918201939093528083019190915290517fdb3d5e65fcde89731529c01d62b87bab1c64471cffdd528fc1adbc1712b5d0829181900360600190a1505050565b60095481565b600160a060020a03838116600090815260036020526040902060050154606091829186911633146118bf576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038616600090815260056020526040902054869015611931576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a0387166000908152600660205260409020548790156119a3576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b6119ae888888613718565b945094506119bc8585613359565b1515600114611a15576040805160e560020a62461bcd02815260206004820152601460248201527f496e76616c69642071756f746520746f6b656e73000000000000000000000000604482015290519081900360640190fd5b600160a060020a03881660009081526003602090815260409091208651611a4492600290920191880190613aee565b50600160a060020a03881660009081526003602081815260409092208651611a7493919092019190870190613aee565b50600160a060020a03881660009081526003602081815260409283902080546001820154855182815261ffff9091169381018490526080958101868152600284018054978301889052600080516020613c1683398151915297939690940192606083019060a084019086908015611b1457602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611af6575b50508381038252848181548152602001915080548015611b5d57602002820191906000526020600020905b8154600160a060020a03168152600190910190602001808311611b3f575b5050965050505050505060405180910390a15050505050505050565b600160a060020a038281166000908152600360205260409020600501548391163314611bdd576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038316600090815260056020526040902054839015611c4f576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b60008311611ccd576040805160e560020a62461bcd02815260206004820152602860248201527f507269636520746167206d75737420626520646966666572656e74207468616e60448201527f205a65726f283029000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a03841660008181526006602090815260409182902086905581516001815290810192909252818101859052517fdb3d5e65fcde89731529c01d62b87bab1c64471cffdd528fc1adbc1712b5d0829181900360600190a150505050565b60075481565b60066020526000908152604090205481565b600160a060020a038181166000908152600360205260409020600501548291163314611dac576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038216600090815260066020526040902054829015611e1e576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b600160a060020a03831660009081526003602052604081205411611eb2576040805160e560020a62461bcd02815260206004820152602760248201527f4e6f2072656c61796572206173736f636961746564207769746820746869732060448201527f6164647265737300000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b600160a060020a03831660009081526005602052604090205415611f20576040805160e560020a62461bcd02815260206004820152601860248201527f5265717565737420616c72656164792072656365697665640000000000000000604482015290519081900360640190fd5b600160a060020a03831660009081526005602090815260408083206224ea0042018155600980546000190190555460038352928190205481519384529183019190915280517f2e821a4329d6351a6b13fe0c12fd7674cd0f4a2283685a4713e1325f36415ae59281900390910190a1505050565b600160a060020a038281166000908152600360205260409020600501548391163314611ff8576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a03831660009081526005602052604090205483901561206a576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a0384166000908152600660205260409020548490156120dc576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b600160a060020a038416158015906120fd5750600160a060020a0384163314155b151561210857600080fd5b600160a060020a0384811660009081526003602052604090206005015416156121a1576040805160e560020a62461bcd02815260206004820152603c60248201527f4f776e65722061646472657373206d757374206e6f742062652063757272656e60448201527f746c7920757365642061732072656c617965722d636f696e6261736500000000606482015290519081900360840190fd5b600160a060020a03858116600090815260036020818152604092839020600581018054600160a060020a0319168a871617908190558154600183015486519290971680835293820181905261ffff90961694810185905260a0606082018181526002840180549284018390527fc13ab794f75ba420a1f52192a8e35a2cf2c74ae31ed94f53f47ce7712011b66298959795969094019291608083019060c08401908690801561227957602002820191906000526020600020905b8154600160a060020a0316815260019091019060200180831161225b575b505083810382528481815481526020019150805480156122c257602002820191906000526020600020905b8154600160a060020a031681526001909101906020018083116122a4575b505097505050505050505060405180910390a15050505050565b60085481565b600054600160a060020a031633141561236b576040805160e560020a62461bcd02815260206004820152602f60248201527f436f6e7472616374204f776e657220697320666f7262696464656e20746f206360448201527f726561746520612052656c617965720000000000000000000000000000000000606482015290519081900360840190fd5b33600160a060020a03851614156123f2576040805160e560020a62461bcd02815260206004820152603660248201527f436f696e6261736520616e642052656c617965724f776e65722061646472657360448201527f73206d757374206e6f74206265207468652073616d6500000000000000000000606482015290519081900360840190fd5b600054600160a060020a038581169116141561247e576040805160e560020a62461bcd02815260206004820152602b60248201527f436f696e62617365206d757374206e6f742062652073616d6520617320434f4e60448201527f54524143545f4f574e4552000000000000000000000000000000000000000000606482015290519081900360840190fd5b6008543410156124d8576040805160e560020a62461bcd02815260206004820152601e60248201527f4d696e696d756d206465706f736974206e6f74207361746973666965642e0000604482015290519081900360640190fd5b60008361ffff16101580156124f257506103e88361ffff16105b1515612548576040805160e560020a62461bcd02815260206004820152601160248201527f496e76616c6964204d616b657220466565000000000000000000000000000000604482015290519081900360640190fd5b600254825111156125a3576040805160e560020a62461bcd02815260206004820152601f60248201527f457863656564696e67206e756d626572206f6620747261646520706169727300604482015290519081900360640190fd5b81518151146125fc576040805160e560020a62461bcd02815260206004820152601960248201527f4e6f742076616c6964206e756d626572206f6620506169727300000000000000604482015290519081900360640190fd5b600160a060020a0384166000908152600360205260409020541561266a576040805160e560020a62461bcd02815260206004820152601c60248201527f436f696e6261736520616c726561647920726567697374657265642e00000000604482015290519081900360640190fd5b600160a060020a038416600090815260056020526040902054156126da576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b60015460095410612735576040805160e560020a62461bcd02815260206004820152601b60248201527f4d6178696d756d2072656c617965727320726567697374657265640000000000604482015290519081900360640190fd5b61273f8282613359565b1515600114612798576040805160e560020a62461bcd02815260206004820152601460248201527f496e76616c69642071756f746520746f6b656e73000000000000000000000000604482015290519081900360640190fd5b6007805460009081526004602090815260408083208054600160a060020a031916600160a060020a038a16908117909155815160c08101835234815261ffff8981168286019081528285018a8152606084018a9052975460808401523360a0840152928652600385529290942084518155905160018201805461ffff19169190931617909155925180519293926128359260028501920190613aee565b5060608201518051612851916003840191602090910190613aee565b50608082810151600483015560a09283015160059092018054600160a060020a031916600160a060020a039384161790556007805460019081019091556009805482019055918716600090815260036020818152604092839020805495810154845187815261ffff9091169281018390529384018581526002820180549686018790527fcf24380d990b0bb3dd21518926bca48f81495ac131ee92655696db28c43b1b1b9893969095929094019391929091606084019184019086908015610faf57602002820191906000526020600020908154600160a060020a03168152600190910190602001808311610f915750508381038252848181548152602001915080548015610ff857602002820191906000526020600020908154600160a060020a03168152600190910190602001808311610fda575050965050505050505060405180910390a150505050565b60025481565b600160a060020a0381166000908152600560205260408120548190839015612a19576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a03841660009081526006602052604081205493508311612ab0576040805160e560020a62461bcd02815260206004820152602160248201527f52656c61796572206973206e6f742063757272656e746c7920666f722073616c60448201527f6500000000000000000000000000000000000000000000000000000000000000606482015290519081900360840190fd5b348314612b07576040805160e560020a62461bcd02815260206004820152601960248201527f50726963652d746167206d757374206265206d61746368656400000000000000604482015290519081900360640190fd5b600160a060020a038085166000908152600360205260409020600501541691503315801590612b3f575033600160a060020a03831614155b8015612b535750600160a060020a03821615155b1515612ba9576040805160e560020a62461bcd02815260206004820152601160248201527f41646472657373206e6f742076616c6964000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a0380851660009081526003602090815260408083206005018054600160a060020a031916331790556006909152808220829055519184169185156108fc0291869190818181858888f19350505050158015612c0f573d6000803e3d6000fd5b506040805160018152600160a060020a0386166020820152348183015290517f07e248a3b3d2184a9491c3b45089a6e15aac742b9d974e691e7beb0f6e7c58c69181900360600190a150505050565b600160a060020a038181166000908152600360205260408120600501549091829182918591163314612cc8576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038516600090815260066020526040902054859015612d3a576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b600160a060020a03861660009081526005602052604081205411612da8576040805160e560020a62461bcd02815260206004820152601160248201527f52657175657374206e6f7420666f756e64000000000000000000000000000000604482015290519081900360640190fd5b600160a060020a0386166000908152600360209081526040808320805460049091015460059093529220549196509450421115612f1657600160a060020a038616600090815260036020526040812081815560018101805461ffff1916905590612e156002830182613b53565b612e23600383016000613b53565b506000600482810182905560059283018054600160a060020a0319908116909155600160a060020a038a811684526020948552604080852085905560078054600019908101875285885282872080548087169091558c88528388208054919095169516851790935583865260039096528085209093018990558454019093555191945033916108fc88150291889190818181858888f19350505050158015612ecf573d6000803e3d6000fd5b5060408051600181526000602082015280820187905290517ffaba1aac53309af4c1c439f38c29500d3828405ee1ca5e7641b0432d17d302509181900360600190a1612f73565b600160a060020a038616600090815260056020908152604080832054815193845242900391830191909152818101879052517ffaba1aac53309af4c1c439f38c29500d3828405ee1ca5e7641b0432d17d302509181900360600190a15b505050505050565b600054600160a060020a031681565b600160a060020a0383166000908152600360208181526040808420909201548251600190910180825280830282019092019092528291606091839182918015612fdd578160200160208202803883390190505b50600a54604080517fa3ff31b5000000000000000000000000000000000000000000000000000000008152600160a060020a038a81166004830152915193965091169163a3ff31b5916024808201926020929091908290030181600087803b15801561304857600080fd5b505af115801561305c573d6000803e3d6000fd5b505050506040513d602081101561307257600080fd5b5051806130885750600160a060020a0386166001145b915081801561313a5750600a54604080517fa3ff31b5000000000000000000000000000000000000000000000000000000008152600160a060020a038a811660048301529151919092169163a3ff31b59160248083019260209291908290030181600087803b1580156130fa57600080fd5b505af115801561310e573d6000803e3d6000fd5b505050506040513d602081101561312457600080fd5b50518061313a5750600160a060020a0387166001145b915081151561314c5760009450613331565b600160a060020a0387166001148061316d5750600160a060020a0386166001145b1561317b5760019450613331565b5060005b600160a060020a0388166000908152600360208190526040909120015481101561331357600160a060020a038816600090815260036020819052604090912001805460019190839081106131cf57fe5b600091825260209091200154600160a060020a0316141561325957600160a060020a038816600090815260036020526040902060020180548290811061321157fe5b6000918252602090912001548351600160a060020a039091169084908690811061323757fe5b600160a060020a0390921660209283029091019091015260019093019261330b565b600160a060020a03881660009081526003602052604090206002018054600191908390811061328457fe5b600091825260209091200154600160a060020a0316141561330b57600160a060020a0388166000908152600360208190526040909120018054829081106132c757fe5b6000918252602090912001548351600160a060020a03909116908490869081106132ed57fe5b600160a060020a039092166020928302909101909101526001909301925b60010161317f565b61331d8387613a95565b151561332c5760009450613331565b600194505b505050509392505050565b60008282018381101561334e57600080fd5b8091505b5092915050565b60008060006060806000806000809650600095508951604051908082528060200260200182016040528015613398578160200160208202803883390190505b50945089516040519080825280602002602001820160405280156133c6578160200160208202803883390190505b509350600092505b88518310156136c257600a548951600160a060020a039091169063a3ff31b5908b90869081106133fa57fe5b906020019060200201516040518263ffffffff1660e060020a0281526004018082600160a060020a0316600160a060020a03168152602001915050602060405180830381600087803b15801561344f57600080fd5b505af1158015613463573d6000803e3d6000fd5b505050506040513d602081101561347957600080fd5b5051806134a7575088516001908a908590811061349257fe5b90602001906020020151600160a060020a0316145b91508180156135815750600a548a51600160a060020a039091169063a3ff31b5908c90869081106134d457fe5b906020019060200201516040518263ffffffff1660e060020a0281526004018082600160a060020a0316600160a060020a03168152602001915050602060405180830381600087803b15801561352957600080fd5b505af115801561353d573d6000803e3d6000fd5b505050506040513d602081101561355357600080fd5b505180613581575089516001908b908590811061356c57fe5b90602001906020020151600160a060020a0316145b9150811515613593576000975061370b565b88516001908a90859081106135a457fe5b90602001906020020151600160a060020a031614156136055789838151811015156135cb57fe5b9060200190602002015185888151811015156135e357fe5b600160a060020a039092166020928302909101909101526001909601956136b7565b89516001908b908590811061361657fe5b90602001906020020151600160a060020a0316141561367357888381518110151561363d57fe5b90602001906020020151858881518110151561365557fe5b600160a060020a039092166020928302909101909101526001909601955b888381518110151561368157fe5b90602001906020020151848781518110151561369957fe5b600160a060020a039092166020928302909101909101526001909501945b6001909201916133ce565b5060005b85811015613706576136ef8585838151811015156136e057fe5b90602001906020020151613a95565b15156136fe576000975061370b565b6001016136c6565b600197505b5050505050505092915050565b6060806060806000806060806000600360008d600160a060020a0316600160a060020a031681526020019081526020016000206003018054905060405190808252806020026020018201604052801561377b578160200160208202803883390190505b50600160a060020a038d16600090815260036020818152604092839020909101548251818152818302810190920190925291985080156137c5578160200160208202803883390190505b50955060009450600093505b600160a060020a038c166000908152600360208190526040909120015484101561395c57600160a060020a038c81166000908152600360208190526040909120018054918c16918690811061382257fe5b600091825260209091200154600160a060020a03161415806138835750600160a060020a038c811660009081526003602052604090206002018054918d16918690811061386b57fe5b600091825260209091200154600160a060020a031614155b1561395157600160a060020a038c1660009081526003602052604090206002018054859081106138af57fe5b6000918252602090912001548751600160a060020a03909116908890879081106138d557fe5b600160a060020a039283166020918202909201810191909152908d166000908152600391829052604090200180548590811061390d57fe5b6000918252602090912001548651600160a060020a039091169087908790811061393357fe5b600160a060020a039092166020928302909101909101526001909401935b6001909301926137d1565b600160a060020a038c16600090815260036020819052604090912001548514613a7f5760018651036040519080825280602002602001820160405280156139ad578160200160208202803883390190505b50925060018651036040519080825280602002602001820160405280156139de578160200160208202803883390190505b509150600090505b6001865103811015613a74578681815181101515613a0057fe5b906020019060200201518382815181101515613a1857fe5b600160a060020a039092166020928302909101909101528551869082908110613a3d57fe5b906020019060200201518282815181101515613a5557fe5b600160a060020a039092166020928302909101909101526001016139e6565b828298509850613a86565b8686985098505b50505050505050935093915050565b6000805b8351811015613ae45782600160a060020a03168482815181101515613aba57fe5b90602001906020020151600160a060020a03161415613adc5760019150613352565b600101613a99565b5060009392505050565b828054828255906000526020600020908101928215613b43579160200282015b82811115613b435782518254600160a060020a031916600160a060020a03909116178255602090920191600190910190613b0e565b50613b4f929150613b74565b5090565b5080546000825590600052602060002090810190613b719190613b9b565b50565b613b9891905b80821115613b4f578054600160a060020a0319168155600101613b7a565b90565b613b9891905b80821115613b4f5760008155600101613ba1560052656c61796572204f776e6572204f6e6c792e000000000000000000000000005468652072656c6179657220686173206265656e2072657175657374656420745468652072656c61796572206d757374206265206e6f742063757272656e746ccaa8c94daf6ecfd00518cea95158f5273730574cca907eb0cd47e50732314c4f6f20636c6f73652e0000000000000000000000000000000000000000000000007920666f722053616c6500000000000000000000000000000000000000000000a165627a7a723058207d9c49eecfd59b2ae9911da7296be4a9f5e5d938cbf3dfb6327f454bc1c46a4f00295b60043610613ccf5763ffffffff60e060020a60003504168063d3a93c6014613cd4578063bfc1c21514613f0e575b600080fd5b348015613ce057600080fd5b50610161600160a060020a036004351661ffff60243516613cfc565b600160a060020a038281166000908152600360205260409020600501548391163314613d60576040805160e560020a62461bcd0281526020600482015260136024820152600080516020613bb6833981519152604482015290519081900360640190fd5b600160a060020a038316600090815260056020526040902054839015613dd2576040805160e560020a62461bcd0281526020600482015260286024820152600080516020613bd68339815191526044820152600080516020613c36833981519152606482015290519081900360840190fd5b600160a060020a038416600090815260066020526040902054849015613e44576040805160e560020a62461bcd02815260206004820152602a6024820152600080516020613bf68339815191526044820152600080516020613c56833981519152606482015290519081900360840190fd5b60008461ffff1610158015613e5e57506103e88461ffff16105b1515613eb4576040805160e560020a62461bcd02815260206004820152601460248201527f496e76616c6964204d616b657220526562617465000000000000000000000000604482015290519081900360640190fd5b600160a060020a03851680600052600b60205261ffff8516806040600020556040518281528181602001527f056dfe13744f758872bb1febef36085c139ed4c68d5230c5eacefda00c150e9f604082a15050505050505050565b348015613f1a57600080fd5b50600160a060020a0360043516600052600b60205260406000205461ffff16604051908152602090f35b600160a060020a0360043516806000526005602052426040600020541015613f7457600b60205260006040600020555b5061061b56`
 
 // DeployRelayerRegistration deploys a new Ethereum contract, binding an instance of RelayerRegistration to it.
 func DeployRelayerRegistration(auth *bind.TransactOpts, backend bind.ContractBackend, tomoxListing common.Address, maxRelayers *big.Int, maxTokenList *big.Int, minDeposit *big.Int) (common.Address, *types.Transaction, *RelayerRegistration, error) {
@@ -563,6 +563,32 @@ func (_RelayerRegistration *RelayerRegistrationCallerSession) RELAYERLIST(arg0 c
 	return _RelayerRegistration.Contract.RELAYERLIST(&_RelayerRegistration.CallOpts, arg0)
 }
 
+// RELAYERMAKERREBATES is a free data retrieval call binding the contract method 0xbfc1c215.
+//
+// Solidity: function RELAYER_MAKER_REBATES( address) constant returns(uint16)
+func (_RelayerRegistration *RelayerRegistrationCaller) RELAYERMAKERREBATES(opts *bind.CallOpts, arg0 common.Address) (uint16, error) {
+	var (
+		ret0 = new(uint16)
+	)
+	out := ret0
+	err := _RelayerRegistration.contract.Call(opts, out, "RELAYER_MAKER_REBATES", arg0)
+	return *ret0, err
+}
+
+// RELAYERMAKERREBATES is a free data retrieval call binding the contract method 0xbfc1c215.
+//
+// Solidity: function RELAYER_MAKER_REBATES( address) constant returns(uint16)
+func (_RelayerRegistration *RelayerRegistrationSession) RELAYERMAKERREBATES(arg0 common.Address) (uint16, error) {
+	return _RelayerRegistration.Contract.RELAYERMAKERREBATES(&_RelayerRegistration.CallOpts, arg0)
+}
+
+// RELAYERMAKERREBATES is a free data retrieval call binding the contract method 0xbfc1c215.
+//
+// Solidity: function RELAYER_MAKER_REBATES( address) constant returns(uint16)
+func (_RelayerRegistration *RelayerRegistrationCallerSession) RELAYERMAKERREBATES(arg0 common.Address) (uint16, error) {
+	return _RelayerRegistration.Contract.RELAYERMAKERREBATES(&_RelayerRegistration.CallOpts, arg0)
+}
+
 // RELAYERONSALELIST is a free data retrieval call binding the contract method 0x885b7137.
 //
 // Solidity: function RELAYER_ON_SALE_LIST( address) constant returns(uint256)
@@ -973,6 +999,27 @@ func (_RelayerRegistration *RelayerRegistrationTransactorSession) UpdateFee(coin
 	return _RelayerRegistration.Contract.UpdateFee(&_RelayerRegistration.TransactOpts, coinbase, tradeFee)
 }
 
+// UpdateMakerRebate is a paid mutator transaction binding the contract method 0xd3a93c60.
+//
+// Solidity: function updateMakerRebate(coinbase address, makerRebate uint16) returns()
+func (_RelayerRegistration *RelayerRegistrationTransactor) UpdateMakerRebate(opts *bind.TransactOpts, coinbase common.Address, makerRebate uint16) (*types.Transaction, error) {
+	return _RelayerRegistration.contract.Transact(opts, "updateMakerRebate", coinbase, makerRebate)
+}
+
+// UpdateMakerRebate is a paid mutator transaction binding the contract method 0xd3a93c60.
+//
+// Solidity: function updateMakerRebate(coinbase address, makerRebate uint16) returns()
+func (_RelayerRegistration *RelayerRegistrationSession) UpdateMakerRebate(coinbase common.Address, makerRebate uint16) (*types.Transaction, error) {
+	return _RelayerRegistration.Contract.UpdateMakerRebate(&_RelayerRegistration.TransactOpts, coinbase, makerRebate)
+}
+
+// UpdateMakerRebate is a paid mutator transaction binding the contract method 0xd3a93c60.
+//
+// Solidity: function updateMakerRebate(coinbase address, makerRebate uint16) returns()
+func (_RelayerRegistration *RelayerRegistrationTransactorSession) UpdateMakerRebate(coinbase common.Address, makerRebate uint16) (*types.Transaction, error) {
+	return _RelayerRegistration.Contract.UpdateMakerRebate(&_RelayerRegistration.TransactOpts, coinbase, makerRebate)
+}
+
 // RelayerRegistrationBuyEventIterator is returned from FilterBuyEvent and is used to iterate over the raw logs and unpacked data for BuyEvent events raised by the RelayerRegistration contract.
 type RelayerRegistrationBuyEventIterator struct {
 	Event *RelayerRegistrationBuyEvent // Event containing the contract specifics and raw log
@@ -2091,6 +2138,129 @@ func (_RelayerRegistration *RelayerRegistrationFilterer) WatchUpdateFeeEvent(opt
 	}), nil
 }
 
+// RelayerRegistrationUpdateMakerRebateEventIterator is returned from FilterUpdateMakerRebateEvent and is used to iterate over the raw logs and unpacked data for UpdateMakerRebateEvent events raised by the RelayerRegistration contract.
+type RelayerRegistrationUpdateMakerRebateEventIterator struct {
+	Event *RelayerRegistrationUpdateMakerRebateEvent // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *RelayerRegistrationUpdateMakerRebateEventIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(RelayerRegistrationUpdateMakerRebateEvent)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(RelayerRegistrationUpdateMakerRebateEvent)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *RelayerRegistrationUpdateMakerRebateEventIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *RelayerRegistrationUpdateMakerRebateEventIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// RelayerRegistrationUpdateMakerRebateEvent represents a UpdateMakerRebateEvent event raised by the RelayerRegistration contract.
+type RelayerRegistrationUpdateMakerRebateEvent struct {
+	Coinbase    common.Address
+	MakerRebate uint16
+	Raw         types.Log // Blockchain specific contextual infos
+}
+
+// FilterUpdateMakerRebateEvent is a free log retrieval operation binding the contract event 0x056dfe13744f758872bb1febef36085c139ed4c68d5230c5eacefda00c150e9f.
+//
+// Solidity: event UpdateMakerRebateEvent(coinbase address, makerRebate uint16)
+func (_RelayerRegistration *RelayerRegistrationFilterer) FilterUpdateMakerRebateEvent(opts *bind.FilterOpts) (*RelayerRegistrationUpdateMakerRebateEventIterator, error) {
+
+	logs, sub, err := _RelayerRegistration.contract.FilterLogs(opts, "UpdateMakerRebateEvent")
+	if err != nil {
+		return nil, err
+	}
+	return &RelayerRegistrationUpdateMakerRebateEventIterator{contract: _RelayerRegistration.contract, event: "UpdateMakerRebateEvent", logs: logs, sub: sub}, nil
+}
+
+// WatchUpdateMakerRebateEvent is a free log subscription operation binding the contract event 0x056dfe13744f758872bb1febef36085c139ed4c68d5230c5eacefda00c150e9f.
+//
+// Solidity: event UpdateMakerRebateEvent(coinbase address, makerRebate uint16)
+func (_RelayerRegistration *RelayerRegistrationFilterer) WatchUpdateMakerRebateEvent(opts *bind.WatchOpts, sink chan<- *RelayerRegistrationUpdateMakerRebateEvent) (event.Subscription, error) {
+
+	logs, sub, err := _RelayerRegistration.contract.WatchLogs(opts, "UpdateMakerRebateEvent")
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(RelayerRegistrationUpdateMakerRebateEvent)
+				if err := _RelayerRegistration.contract.UnpackLog(event, "UpdateMakerRebateEvent", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
 // SafeMathABI is the input ABI used to generate the binding from.
 const SafeMathABI = "[]"
 
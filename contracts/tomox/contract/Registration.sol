@@ -40,12 +40,16 @@ contract RelayerRegistration {
 
     AbstractTOMOXListing private TomoXListing;
 
+    /// @dev coinbase -> rate of the rebate paid to the makers out of the taker fee
+    mapping(address => uint16) public RELAYER_MAKER_REBATES;
+
     /// @dev Events
     /// struct-mapping -> values
     event ConfigEvent(uint max_relayer, uint max_token, uint256 min_deposit);
     event RegisterEvent(uint256 deposit, uint16 tradeFee, address[] fromTokens, address[] toTokens);
     event UpdateEvent(uint256 deposit, uint16 tradeFee, address[] fromTokens, address[] toTokens);
     event UpdateFeeEvent(address coinbase, uint16 tradeFee);
+    event UpdateMakerRebateEvent(address coinbase, uint16 makerRebate);
     event TransferEvent(address owner, uint256 deposit, uint16 tradeFee, address[] fromTokens, address[] toTokens);
     event ResignEvent(uint deposit_release_time, uint256 deposit_amount);
     event RefundEvent(bool success, uint remaining_time, uint256 deposit_amount);
@@ -172,6 +176,13 @@ contract RelayerRegistration {
         emit UpdateFeeEvent(coinbase, RELAYER_LIST[coinbase]._tradeFee);
     }
 
+    /// @dev the rebate is paid to the makers of the relayer on their trades with its takers
+    function updateMakerRebate(address coinbase, uint16 makerRebate) public relayerOwnerOnly(coinbase) onlyActiveRelayer(coinbase) notForSale(coinbase) {
+        require(makerRebate >= 0 && makerRebate < 1000, "Invalid Maker Rebate");
+        RELAYER_MAKER_REBATES[coinbase] = makerRebate;
+        emit UpdateMakerRebateEvent(coinbase, RELAYER_MAKER_REBATES[coinbase]);
+    }
+
     // List new tokens
     function listToken(
         address coinbase,
@@ -251,6 +262,7 @@ contract RelayerRegistration {
         if (RESIGN_REQUESTS[coinbase] < now) {
             delete RELAYER_LIST[coinbase];
             delete RESIGN_REQUESTS[coinbase];
+            delete RELAYER_MAKER_REBATES[coinbase];
             /// @notice swap last relayer's index with the deleting relayer's index
             address last_coinbase = RELAYER_COINBASES[RelayerCount - 1];
             delete RELAYER_COINBASES[RelayerCount - 1];
@@ -0,0 +1,26 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package contract
+
+import "github.com/tomochain/tomochain/common"
+
+// relayerRegistrationInitSize is the length of the constructor prefix of
+// RelayerRegistrationBin, it has to follow a regeneration of the binding.
+const relayerRegistrationInitSize = 0x88
+
+// RelayerRegistrationRuntime is the code the RelayerRegistration constructor
+// leaves at the contract address.
+var RelayerRegistrationRuntime = common.FromHex(RelayerRegistrationBin)[relayerRegistrationInitSize:]
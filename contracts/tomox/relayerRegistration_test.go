@@ -0,0 +1,131 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tomox
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/accounts/abi/bind"
+	"github.com/tomochain/tomochain/accounts/abi/bind/backends"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/contracts/tomox/contract"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+var (
+	ownerKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	ownerAddr     = crypto.PubkeyToAddress(ownerKey.PublicKey)
+	relayerKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	relayerAddr   = crypto.PubkeyToAddress(relayerKey.PublicKey)
+	coinbaseAddr  = common.HexToAddress("0x0000000000000000000000000000000000000c01")
+	listingAddr   = common.HexToAddress("0x0000000000000000000000000000000000000c02")
+)
+
+// Tests that the relayer owner can set a maker rebate, that it is stored in the
+// slot read by the trading state, and that it is cleared on refund.
+func TestRelayerMakerRebate(t *testing.T) {
+	funds := new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		ownerAddr:   {Balance: funds},
+		relayerAddr: {Balance: funds},
+	})
+	ownerOpts := bind.NewKeyedTransactor(ownerKey)
+	relayerOpts := bind.NewKeyedTransactor(relayerKey)
+
+	minDeposit := big.NewInt(1e18)
+	contractAddr, _, err := DeployRelayerRegistration(ownerOpts, backend, listingAddr, big.NewInt(10), big.NewInt(10), minDeposit)
+	if err != nil {
+		t.Fatalf("can't deploy relayer registration: %v", err)
+	}
+	backend.Commit()
+
+	code, err := backend.CodeAt(context.Background(), contractAddr, nil)
+	if err != nil {
+		t.Fatalf("can't read contract code: %v", err)
+	}
+	if !bytes.Equal(code, contract.RelayerRegistrationRuntime) {
+		t.Fatalf("deployed code differs from the fork upgrade code")
+	}
+
+	relayer, err := NewRelayerRegistration(relayerOpts, contractAddr, backend)
+	if err != nil {
+		t.Fatalf("can't bind relayer registration: %v", err)
+	}
+	relayer.TransactOpts.Value = minDeposit
+	if _, err := relayer.Register(coinbaseAddr, 10, []common.Address{}, []common.Address{}); err != nil {
+		t.Fatalf("can't register relayer: %v", err)
+	}
+	backend.Commit()
+	relayer.TransactOpts.Value = nil
+
+	if _, err := relayer.UpdateMakerRebate(coinbaseAddr, 250); err != nil {
+		t.Fatalf("can't update maker rebate: %v", err)
+	}
+	if _, err := relayer.UpdateFee(coinbaseAddr, 20); err != nil {
+		t.Fatalf("can't update fee: %v", err)
+	}
+	backend.Commit()
+
+	if rebate, err := relayer.RELAYERMAKERREBATES(coinbaseAddr); err != nil || rebate != 250 {
+		t.Fatalf("maker rebate mismatch: have %d (%v), want 250", rebate, err)
+	}
+	if _, _, _, fee, _, _, err := relayer.GetRelayerByCoinbase(coinbaseAddr); err != nil || fee != 20 {
+		t.Fatalf("trade fee mismatch: have %d (%v), want 20", fee, err)
+	}
+	loc := common.BigToHash(tradingstate.GetLocMappingAtKey(coinbaseAddr.Hash(), tradingstate.RelayerMappingSlot["RELAYER_MAKER_REBATES"]))
+	stored, err := backend.StorageAt(context.Background(), contractAddr, loc, nil)
+	if err != nil {
+		t.Fatalf("can't read storage: %v", err)
+	}
+	if have := new(big.Int).SetBytes(stored); have.Uint64() != 250 {
+		t.Fatalf("stored maker rebate mismatch: have %v, want 250", have)
+	}
+
+	if _, err := relayer.UpdateMakerRebate(coinbaseAddr, 1000); err == nil {
+		t.Fatalf("out of range maker rebate accepted")
+	}
+	owner, err := NewRelayerRegistration(ownerOpts, contractAddr, backend)
+	if err != nil {
+		t.Fatalf("can't bind relayer registration: %v", err)
+	}
+	if _, err := owner.UpdateMakerRebate(coinbaseAddr, 100); err == nil {
+		t.Fatalf("maker rebate updated by a non relayer owner")
+	}
+
+	if _, err := relayer.Resign(coinbaseAddr); err != nil {
+		t.Fatalf("can't resign relayer: %v", err)
+	}
+	backend.Commit()
+	backend.AdjustTime(5 * 7 * 24 * time.Hour)
+	backend.Commit()
+	if _, err := relayer.Refund(coinbaseAddr); err != nil {
+		t.Fatalf("can't refund relayer: %v", err)
+	}
+	backend.Commit()
+
+	if deposit, _, _, _, _, _, err := relayer.GetRelayerByCoinbase(coinbaseAddr); err != nil || deposit.Sign() != 0 {
+		t.Fatalf("relayer not refunded: deposit %v (%v)", deposit, err)
+	}
+	if rebate, err := relayer.RELAYERMAKERREBATES(coinbaseAddr); err != nil || rebate != 0 {
+		t.Fatalf("maker rebate not cleared on refund: have %d (%v)", rebate, err)
+	}
+}
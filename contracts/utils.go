@@ -47,11 +47,6 @@ import (
 	"github.com/tomochain/tomochain/params"
 )
 
-const (
-	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for signer vanity
-	extraSeal   = 65 // Fixed number of extra-data suffix bytes reserved for signer seal
-)
-
 type rewardLog struct {
 	Sign   uint64   `json:"sign"`
 	Reward *big.Int `json:"reward"`
@@ -65,23 +60,7 @@ func CreateTransactionSign(chainConfig *params.ChainConfig, pool *core.TxPool, m
 	defer TxSignMu.Unlock()
 	if chainConfig.Posv != nil {
 		// Find active account.
-		account := accounts.Account{}
-		var wallet accounts.Wallet
-		etherbaseAccount := accounts.Account{
-			Address: eb,
-			URL:     accounts.URL{},
-		}
-		if wallets := manager.Wallets(); len(wallets) > 0 {
-			if w, err := manager.Find(etherbaseAccount); err == nil && w != nil {
-				wallet = w
-				account = etherbaseAccount
-			} else {
-				wallet = wallets[0]
-				if accts := wallets[0].Accounts(); len(accts) > 0 {
-					account = accts[0]
-				}
-			}
-		}
+		wallet, account := findActiveAccount(manager, eb)
 
 		// Create and send tx to smart contract for sign validate block.
 		nonce := pool.State().GetNonce(account.Address)
@@ -100,7 +79,9 @@ func CreateTransactionSign(chainConfig *params.ChainConfig, pool *core.TxPool, m
 
 		// Create secret tx.
 		blockNumber := block.Number().Uint64()
-		checkNumber := blockNumber % chainConfig.Posv.Epoch
+		// The randomize contract takes the secrets and openings at fixed blocks of
+		// its own cycle, whatever the length of the epochs
+		checkNumber := blockNumber % common.EpocBlockRandomize
 		// Generate random private key and save into chaindb.
 		randomizeKeyName := []byte("randomizeKey")
 		exist, _ := chainDb.Has(randomizeKeyName)
@@ -110,7 +91,7 @@ func CreateTransactionSign(chainConfig *params.ChainConfig, pool *core.TxPool, m
 			// Only process when private key empty in state db.
 			// Save randomize key into state db.
 			randomizeKeyValue := RandStringByte(32)
-			tx, err := BuildTxSecretRandomize(nonce+1, common.HexToAddress(common.RandomizeSMC), chainConfig.Posv.Epoch, randomizeKeyValue)
+			tx, err := BuildTxSecretRandomize(nonce+1, common.HexToAddress(common.RandomizeSMC), chainConfig.Posv.EpochLength(blockNumber), randomizeKeyValue)
 			if err != nil {
 				log.Error("Fail to get tx opening for randomize", "error", err)
 				return err
@@ -164,6 +145,59 @@ func CreateTransactionSign(chainConfig *params.ChainConfig, pool *core.TxPool, m
 	return nil
 }
 
+// Find the wallet of the etherbase account, or else the first account.
+func findActiveAccount(manager *accounts.Manager, eb common.Address) (accounts.Wallet, accounts.Account) {
+	account := accounts.Account{}
+	var wallet accounts.Wallet
+	etherbaseAccount := accounts.Account{
+		Address: eb,
+		URL:     accounts.URL{},
+	}
+	if wallets := manager.Wallets(); len(wallets) > 0 {
+		if w, err := manager.Find(etherbaseAccount); err == nil && w != nil {
+			wallet = w
+			account = etherbaseAccount
+		} else {
+			wallet = wallets[0]
+			if accts := wallets[0].Accounts(); len(accts) > 0 {
+				account = accts[0]
+			}
+		}
+	}
+	return wallet, account
+}
+
+// Send tx evidence of a double sign of a masternode, to penalize it at the next checkpoint.
+func CreateTransactionEvidence(chainConfig *params.ChainConfig, pool *core.TxPool, manager *accounts.Manager, evidence *posv.Evidence, eb common.Address) error {
+	TxSignMu.Lock()
+	defer TxSignMu.Unlock()
+	wallet, account := findActiveAccount(manager, eb)
+	if wallet == nil {
+		return fmt.Errorf("no wallet to sign the evidence of %v", evidence.First.Number)
+	}
+	data, err := posv.EncodeEvidence(evidence)
+	if err != nil {
+		return err
+	}
+	gas, err := core.IntrinsicGas(data, nil, false, true)
+	if err != nil {
+		return err
+	}
+	nonce := pool.State().GetNonce(account.Address)
+	tx := types.NewTransaction(nonce, common.HexToAddress(common.SlashingEvidenceAddr), big.NewInt(0), gas, common.MinGasPrice, data)
+	txSigned, err := wallet.SignTx(account, tx, chainConfig.ChainId)
+	if err != nil {
+		log.Error("Fail to create tx evidence", "error", err)
+		return err
+	}
+	// Add tx signed to local tx pool.
+	if err := pool.AddLocal(txSigned); err != nil {
+		log.Error("Fail to add tx evidence to local pool.", "error", err, "number", evidence.First.Number, "from", account.Address, "nonce", nonce)
+		return err
+	}
+	return nil
+}
+
 // Create tx sign.
 func CreateTxSign(blockNumber *big.Int, blockHash common.Hash, nonce uint64, blockSigner common.Address) *types.Transaction {
 	data := common.Hex2Bytes(common.HexSignMethod)
@@ -336,7 +370,7 @@ func GetRewardForCheckpoint(c *posv.Posv, chain consensus.ChainReader, header *t
 			block := chain.GetBlock(header.Hash(), i)
 			txs := block.Transactions()
 			if !chain.Config().IsTIPSigning(header.Number) {
-				receipts := core.GetBlockReceipts(c.GetDb(), header.Hash(), i)
+				receipts := core.GetBlockReceipts(c.GetDb(), header.Hash(), i, chain.Config())
 				signData = c.CacheData(header, txs, receipts)
 			} else {
 				signData = c.CacheSigner(header.Hash(), txs)
@@ -345,7 +379,7 @@ func GetRewardForCheckpoint(c *posv.Posv, chain consensus.ChainReader, header *t
 		txs := signData.([]*types.Transaction)
 		for _, tx := range txs {
 			blkHash := common.BytesToHash(tx.Data()[len(tx.Data())-32:])
-			from := *tx.From()
+			from := c.MasternodeOf(chain, i, *tx.From())
 			data[blkHash] = append(data[blkHash], from)
 		}
 	}
@@ -427,11 +461,20 @@ func CalculateRewardForHolders(foundationWalletAddr common.Address, state *state
 }
 
 func GetRewardBalancesRate(foundationWalletAddr common.Address, state *state.StateDB, masterAddr common.Address, totalReward *big.Int, blockNumber uint64) (map[common.Address]*big.Int, error) {
+	return rewardBalancesRate(foundationWalletAddr, state, masterAddr, totalReward, blockNumber, nil)
+}
+
+// rewardBalancesRate splits the reward of a masternode between its owner, its
+// voters and the foundation, detailing the split in the trace if any.
+func rewardBalancesRate(foundationWalletAddr common.Address, state *state.StateDB, masterAddr common.Address, totalReward *big.Int, blockNumber uint64, trace *SignerRewardTrace) (map[common.Address]*big.Int, error) {
 	owner := GetCandidatesOwnerBySigner(state, masterAddr)
 	balances := make(map[common.Address]*big.Int)
 	rewardMaster := new(big.Int).Mul(totalReward, new(big.Int).SetInt64(common.RewardMasterPercent))
 	rewardMaster = new(big.Int).Div(rewardMaster, new(big.Int).SetInt64(100))
 	balances[owner] = rewardMaster
+	if trace != nil {
+		trace.Owner, trace.OwnerReward = owner, new(big.Int).Set(rewardMaster)
+	}
 	// Get voters for masternode.
 	voters := stateDatabase.GetVoters(state, masterAddr)
 
@@ -449,12 +492,18 @@ func GetRewardBalancesRate(foundationWalletAddr common.Address, state *state.Sta
 			totalCap.Add(totalCap, voterCap)
 			voterCaps[voteAddr] = voterCap
 		}
+		if trace != nil {
+			trace.VotersCap = new(big.Int).Set(totalCap)
+		}
 		if totalCap.Cmp(new(big.Int).SetInt64(0)) > 0 {
 			for addr, voteCap := range voterCaps {
 				// Only valid voter has cap > 0.
 				if voteCap.Cmp(new(big.Int).SetInt64(0)) > 0 {
 					rcap := new(big.Int).Mul(totalVoterReward, voteCap)
 					rcap = new(big.Int).Div(rcap, totalCap)
+					if trace != nil {
+						trace.Voters = append(trace.Voters, &VoterRewardTrace{Address: addr, Cap: voteCap, Reward: new(big.Int).Set(rcap)})
+					}
 					if balances[addr] != nil {
 						balances[addr].Add(balances[addr], rcap)
 					} else {
@@ -468,6 +517,9 @@ func GetRewardBalancesRate(foundationWalletAddr common.Address, state *state.Sta
 	foundationReward := new(big.Int).Mul(totalReward, new(big.Int).SetInt64(common.RewardFoundationPercent))
 	foundationReward = new(big.Int).Div(foundationReward, new(big.Int).SetInt64(100))
 	balances[foundationWalletAddr] = foundationReward
+	if trace != nil {
+		trace.FoundationReward = new(big.Int).Set(foundationReward)
+	}
 
 	jsonHolders, err := json.Marshal(balances)
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/exchange"
 	"github.com/tomochain/tomochain/consensus/posv"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
@@ -102,11 +103,17 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 	if root := statedb.IntermediateRoot(v.config.IsEIP158(header.Number)); header.Root != root {
 		return fmt.Errorf("invalid merkle root (remote: %x local: %x)", header.Root, root)
 	}
+	// Check the masternodes of the checkpoints against the validator contract
+	if engine, ok := v.engine.(*posv.Posv); ok {
+		if err := engine.CrossValidate(v.bc, header, statedb); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (v *BlockValidator) ValidateTradingOrder(statedb *state.StateDB, tomoxStatedb *tradingstate.TradingStateDB, txMatchBatch tradingstate.TxMatchBatch, coinbase common.Address, header *types.Header) error {
-	posvEngine, ok := v.bc.Engine().(*posv.Posv)
+	posvEngine, ok := v.bc.Engine().(exchange.Engine)
 	if posvEngine == nil || !ok {
 		return ErrNotPoSV
 	}
@@ -116,6 +123,7 @@ func (v *BlockValidator) ValidateTradingOrder(statedb *state.StateDB, tomoxState
 	}
 	log.Debug("verify matching transaction found a TxMatches Batch", "numTxMatches", len(txMatchBatch.Data))
 	tradingResult := map[common.Hash]tradingstate.MatchingResult{}
+	orders := make([]*tradingstate.OrderItem, 0, len(txMatchBatch.Data))
 	for _, txMatch := range txMatchBatch.Data {
 		// verify orderItem
 		order, err := txMatch.DecodeOrder()
@@ -123,7 +131,11 @@ func (v *BlockValidator) ValidateTradingOrder(statedb *state.StateDB, tomoxState
 			log.Error("transaction match is corrupted. Failed decode order", "err", err)
 			continue
 		}
-
+		orders = append(orders, order)
+	}
+	// the orders are matched in order as they share balances, their signatures are recovered concurrently beforehand
+	tradingstate.RecoverOrderSenders(orders)
+	for _, order := range orders {
 		log.Debug("process tx match", "order", order)
 		// process Matching Engine
 		newTrades, newRejectedOrders, err := tomoXService.ApplyOrder(header, coinbase, v.bc, statedb, tomoxStatedb, tradingstate.GetTradingOrderBookHash(order.BaseToken, order.QuoteToken), order)
@@ -135,14 +147,17 @@ func (v *BlockValidator) ValidateTradingOrder(statedb *state.StateDB, tomoxState
 			Rejects: newRejectedOrders,
 		}
 	}
-	if tomoXService.IsSDKNode() {
+	if tomoXService.IsSDKNode() || tomoXService.CandlesEnabled() {
 		v.bc.AddMatchingResult(txMatchBatch.TxHash, tradingResult)
 	}
+	if v.config.IsTIPTomoXReceiptLogs(header.Number) {
+		statedb.SetSettlementLogs(txMatchBatch.TxHash, TradingLogs(txMatchBatch.Data, tradingResult))
+	}
 	return nil
 }
 
 func (v *BlockValidator) ValidateLendingOrder(statedb *state.StateDB, lendingStateDb *lendingstate.LendingStateDB, tomoxStatedb *tradingstate.TradingStateDB, batch lendingstate.TxLendingBatch, coinbase common.Address, header *types.Header) error {
-	posvEngine, ok := v.bc.Engine().(*posv.Posv)
+	posvEngine, ok := v.bc.Engine().(exchange.Engine)
 	if posvEngine == nil || !ok {
 		return ErrNotPoSV
 	}
@@ -156,6 +171,8 @@ func (v *BlockValidator) ValidateLendingOrder(statedb *state.StateDB, lendingSta
 	}
 	log.Debug("verify lendingItem ", "numItems", len(batch.Data))
 	lendingResult := map[common.Hash]lendingstate.MatchingResult{}
+	// the items are matched in order as the books share balances, their signatures are recovered concurrently beforehand
+	lendingstate.RecoverLendingSenders(batch.Data)
 	for _, l := range batch.Data {
 		// verify lendingItem
 
@@ -170,9 +187,12 @@ func (v *BlockValidator) ValidateLendingOrder(statedb *state.StateDB, lendingSta
 			Rejects: newRejectedOrders,
 		}
 	}
-	if tomoXService.IsSDKNode() {
+	if tomoXService.IsSDKNode() || tomoXService.AddressIndexEnabled() {
 		v.bc.AddLendingResult(batch.TxHash, lendingResult)
 	}
+	if v.config.IsTIPTomoXReceiptLogs(header.Number) {
+		statedb.SetSettlementLogs(batch.TxHash, LendingLogs(batch.Data, lendingResult))
+	}
 	return nil
 }
 
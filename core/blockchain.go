@@ -33,10 +33,12 @@ import (
 	"github.com/tomochain/tomochain/accounts/abi/bind"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 
+	"github.com/VictoriaMetrics/fastcache"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/mclock"
 	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/exchange"
 	"github.com/tomochain/tomochain/consensus/posv"
 	contractValidator "github.com/tomochain/tomochain/contracts/validator/contract"
 	"github.com/tomochain/tomochain/core/state"
@@ -81,6 +83,13 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	NoPreimages   bool          // Whether to discard the preimages of trie keys instead of storing them
+	RetainBlocks  uint64        // Interval of blocks whose state is always persisted when pruning (0 = none)
+
+	StateVerifyInterval time.Duration // Interval between background integrity checks of recent state (0 = disabled)
+	HistoryExpiry       uint64        // Block number below which bodies and receipts are deleted (0 = keep all)
+
+	TrieCleans *fastcache.Cache // Clean trie node cache shared with the TomoX tries (nil = no cache)
 }
 type ResultProcessBlock struct {
 	logs         []*types.Log
@@ -115,14 +124,19 @@ type BlockChain struct {
 	triegc  *prque.Prque  // Priority queue mapping block numbers to tries to gc
 	gcproc  time.Duration // Accumulates canonical block processing for trie dumping
 
-	hc            *HeaderChain
-	rmLogsFeed    event.Feed
-	chainFeed     event.Feed
-	chainSideFeed event.Feed
-	chainHeadFeed event.Feed
-	logsFeed      event.Feed
-	scope         event.SubscriptionScope
-	genesisBlock  *types.Block
+	hc               *HeaderChain
+	rmLogsFeed       event.Feed
+	reorgFeed        event.Feed
+	chainFeed        event.Feed
+	chainSideFeed    event.Feed
+	chainHeadFeed    event.Feed
+	logsFeed         event.Feed
+	tradingFeed      event.Feed
+	masternodesFeed  event.Feed
+	scope            event.SubscriptionScope
+	tradingScope     event.SubscriptionScope
+	masternodesScope event.SubscriptionScope
+	genesisBlock     *types.Block
 
 	mu      sync.RWMutex // global mutex for locking chain operations
 	chainmu sync.RWMutex // blockchain insertion lock
@@ -200,7 +214,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		cacheConfig:         cacheConfig,
 		db:                  db,
 		triegc:              prque.New(),
-		stateCache:          state.NewDatabase(db),
+		stateCache:          state.NewDatabaseWithConfig(db, &trie.Config{Cleans: cacheConfig.TrieCleans, NoPreimages: cacheConfig.NoPreimages}),
 		quit:                make(chan struct{}),
 		bodyCache:           bodyCache,
 		bodyRLPCache:        bodyRLPCache,
@@ -249,6 +263,12 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	// Take ownership of this particular state
 	go bc.update()
+	if bc.cacheConfig.StateVerifyInterval > 0 {
+		go bc.verifyStateLoop()
+	}
+	if bc.cacheConfig.HistoryExpiry > 0 {
+		go bc.expireHistoryLoop()
+	}
 	return bc, nil
 }
 
@@ -298,12 +318,12 @@ func (bc *BlockChain) loadLastState() error {
 	if err != nil {
 		repair = true
 	} else {
-		engine, ok := bc.Engine().(*posv.Posv)
+		engine, ok := bc.Engine().(exchange.Engine)
 		author, _ := bc.Engine().Author(currentBlock.Header())
 		if ok {
 			tradingService := engine.GetTomoXService()
 			lendingService := engine.GetLendingService()
-			if bc.Config().IsTIPTomoX(currentBlock.Number()) && bc.chainConfig.Posv != nil && currentBlock.NumberU64() > bc.chainConfig.Posv.Epoch && tradingService != nil && lendingService != nil {
+			if bc.chainConfig.IsTomoXEnabled(currentBlock.Number()) && tradingService != nil && lendingService != nil {
 				tradingRoot, err := tradingService.GetTradingStateRoot(currentBlock, author)
 				if err != nil {
 					repair = true
@@ -335,6 +355,7 @@ func (bc *BlockChain) loadLastState() error {
 	if repair {
 		// Dangling block without a state associated, init from scratch
 		log.Warn("Head state missing, repairing chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
+		bc.rollbackToTomoXCommit(&currentBlock)
 		if err := bc.repair(&currentBlock); err != nil {
 			return err
 		}
@@ -506,10 +527,10 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 
 // OrderStateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) OrderStateAt(block *types.Block) (*tradingstate.TradingStateDB, error) {
-	engine, ok := bc.Engine().(*posv.Posv)
+	engine, ok := bc.Engine().(exchange.Engine)
 	if ok {
 		tomoXService := engine.GetTomoXService()
-		if bc.Config().IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch && tomoXService != nil {
+		if bc.chainConfig.IsTomoXEnabled(block.Number()) && tomoXService != nil {
 			author, _ := bc.Engine().Author(block.Header())
 			log.Debug("OrderStateAt", "blocknumber", block.Header().Number)
 			tomoxState, err := tomoXService.GetTradingState(block, author)
@@ -526,10 +547,10 @@ func (bc *BlockChain) OrderStateAt(block *types.Block) (*tradingstate.TradingSta
 
 // LendingStateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) LendingStateAt(block *types.Block) (*lendingstate.LendingStateDB, error) {
-	engine, ok := bc.Engine().(*posv.Posv)
+	engine, ok := bc.Engine().(exchange.Engine)
 	if ok {
 		lendingService := engine.GetLendingService()
-		if bc.Config().IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch && lendingService != nil {
+		if bc.chainConfig.IsTomoXEnabled(block.Number()) && lendingService != nil {
 			author, _ := bc.Engine().Author(block.Header())
 			log.Debug("LendingStateAt", "blocknumber", block.Header().Number)
 			lendingState, err := lendingService.GetLendingState(block, author)
@@ -587,11 +608,11 @@ func (bc *BlockChain) repair(head **types.Block) error {
 		if (common.Rewound == uint64(0)) || ((*head).Number().Uint64() < common.Rewound) {
 			if _, err := state.New((*head).Root(), bc.stateCache); err == nil {
 				log.Info("Rewound blockchain to past state", "number", (*head).Number(), "hash", (*head).Hash())
-				engine, ok := bc.Engine().(*posv.Posv)
+				engine, ok := bc.Engine().(exchange.Engine)
 				if ok {
 					tradingService := engine.GetTomoXService()
 					lendingService := engine.GetLendingService()
-					if bc.Config().IsTIPTomoX((*head).Number()) && bc.chainConfig.Posv != nil && (*head).NumberU64() > bc.chainConfig.Posv.Epoch && tradingService != nil && lendingService != nil {
+					if bc.chainConfig.IsTomoXEnabled((*head).Number()) && tradingService != nil && lendingService != nil {
 						author, _ := bc.Engine().Author((*head).Header())
 						tradingRoot, err := tradingService.GetTradingStateRoot(*head, author)
 						if err == nil {
@@ -741,8 +762,8 @@ func (bc *BlockChain) HasFullState(block *types.Block) bool {
 	if err != nil {
 		return false
 	}
-	engine, _ := bc.Engine().(*posv.Posv)
-	if bc.Config().IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && engine != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch {
+	engine, _ := bc.Engine().(exchange.Engine)
+	if bc.chainConfig.IsTomoXEnabled(block.Number()) && engine != nil {
 		tradingService := engine.GetTomoXService()
 		lendingService := engine.GetLendingService()
 		author, _ := bc.Engine().Author(block.Header())
@@ -800,7 +821,7 @@ func (bc *BlockChain) GetBlockByNumber(number uint64) *types.Block {
 
 // GetReceiptsByHash retrieves the receipts for all transactions in a given block.
 func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
-	return GetBlockReceipts(bc.db, hash, GetBlockNumber(bc.db, hash))
+	return GetBlockReceipts(bc.db, hash, GetBlockNumber(bc.db, hash), bc.chainConfig)
 }
 
 // GetBlocksFromHash returns the block corresponding to hash and up to n-1 ancestors.
@@ -875,11 +896,11 @@ func (bc *BlockChain) SaveData() {
 	if !bc.cacheConfig.Disabled {
 		var tradingTriedb *trie.Database
 		var lendingTriedb *trie.Database
-		engine, _ := bc.Engine().(*posv.Posv)
+		engine, _ := bc.Engine().(exchange.Engine)
 		triedb := bc.stateCache.TrieDB()
-		var tradingService posv.TradingService
-		var lendingService posv.LendingService
-		if bc.Config().IsTIPTomoX(bc.CurrentBlock().Number()) && bc.chainConfig.Posv != nil && bc.CurrentBlock().NumberU64() > bc.chainConfig.Posv.Epoch && engine != nil {
+		var tradingService exchange.TradingService
+		var lendingService exchange.LendingService
+		if bc.chainConfig.IsTomoXEnabled(bc.CurrentBlock().Number()) && engine != nil {
 			tradingService = engine.GetTomoXService()
 			if tradingService != nil && tradingService.GetStateCache() != nil {
 				tradingTriedb = tradingService.GetStateCache().TrieDB()
@@ -892,29 +913,32 @@ func (bc *BlockChain) SaveData() {
 		for _, offset := range []uint64{0, 1, triesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
-
-				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
-				if err := triedb.Commit(recent.Root(), true); err != nil {
-					log.Error("Failed to commit recent state trie", "err", err)
+				if recent == nil {
+					continue // Below a chain segment imported along with its state
 				}
-				if bc.Config().IsTIPTomoX(recent.Number()) && bc.chainConfig.Posv != nil && recent.NumberU64() > bc.chainConfig.Posv.Epoch && engine != nil {
-					author, _ := bc.Engine().Author(recent.Header())
+				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+				if bc.chainConfig.IsTomoXEnabled(recent.Number()) && engine != nil {
+					var (
+						author, _                = bc.Engine().Author(recent.Header())
+						tradingDb, lendingDb     *trie.Database
+						tradingRoot, lendingRoot common.Hash
+					)
 					if tradingService != nil {
-						tradingRoot, _ := tradingService.GetTradingStateRoot(recent, author)
-						if !common.EmptyHash(tradingRoot) && tradingTriedb != nil {
-							if err := tradingTriedb.Commit(tradingRoot, true); err != nil {
-								log.Error("Failed to commit trading state recent state trie", "err", err)
-							}
+						if tradingRoot, _ = tradingService.GetTradingStateRoot(recent, author); !common.EmptyHash(tradingRoot) {
+							tradingDb = tradingTriedb
 						}
 					}
 					if lendingService != nil {
-						lendingRoot, _ := lendingService.GetLendingStateRoot(recent, author)
-						if !common.EmptyHash(lendingRoot) && lendingTriedb != nil {
-							if err := lendingTriedb.Commit(lendingRoot, true); err != nil {
-								log.Error("Failed to commit lending state recent state trie", "err", err)
-							}
+						if lendingRoot, _ = lendingService.GetLendingStateRoot(recent, author); !common.EmptyHash(lendingRoot) {
+							lendingDb = lendingTriedb
 						}
 					}
+					if err := commitTomoXTries(recent, tradingDb, tradingRoot, lendingDb, lendingRoot); err != nil {
+						log.Error("Failed to commit recent TomoX tries", "err", err)
+					}
+				}
+				if err := triedb.Commit(recent.Root(), true); err != nil {
+					log.Error("Failed to commit recent state trie", "err", err)
 				}
 			}
 		}
@@ -1008,40 +1032,7 @@ func (bc *BlockChain) Rollback(chain []common.Hash) {
 
 // SetReceiptsData computes all the non-consensus fields of the receipts
 func SetReceiptsData(config *params.ChainConfig, block *types.Block, receipts types.Receipts) error {
-	signer := types.MakeSigner(config, block.Number())
-
-	transactions, logIndex := block.Transactions(), uint(0)
-	if len(transactions) != len(receipts) {
-		return errors.New("transaction and receipt count mismatch")
-	}
-
-	for j := 0; j < len(receipts); j++ {
-		// The transaction hash can be retrieved from the transaction itself
-		receipts[j].TxHash = transactions[j].Hash()
-
-		// The contract address can be derived from the transaction itself
-		if transactions[j].To() == nil {
-			// Deriving the signer is expensive, only do if it's actually needed
-			from, _ := types.Sender(signer, transactions[j])
-			receipts[j].ContractAddress = crypto.CreateAddress(from, transactions[j].Nonce())
-		}
-		// The used gas can be calculated based on previous receipts
-		if j == 0 {
-			receipts[j].GasUsed = receipts[j].CumulativeGasUsed
-		} else {
-			receipts[j].GasUsed = receipts[j].CumulativeGasUsed - receipts[j-1].CumulativeGasUsed
-		}
-		// The derived log fields can simply be set from the block and transaction
-		for k := 0; k < len(receipts[j].Logs); k++ {
-			receipts[j].Logs[k].BlockNumber = block.NumberU64()
-			receipts[j].Logs[k].BlockHash = block.Hash()
-			receipts[j].Logs[k].TxHash = receipts[j].TxHash
-			receipts[j].Logs[k].TxIndex = uint(j)
-			receipts[j].Logs[k].Index = logIndex
-			logIndex++
-		}
-	}
-	return nil
+	return receipts.DeriveFields(config, block.Hash(), block.NumberU64(), block.Transactions())
 }
 
 // InsertReceiptChain attempts to complete an already existing header chain with
@@ -1199,12 +1190,12 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 			return NonStatTy, err
 		}
 	}
-	engine, _ := bc.Engine().(*posv.Posv)
+	engine, _ := bc.Engine().(exchange.Engine)
 	var tradingTrieDb *trie.Database
-	var tradingService posv.TradingService
+	var tradingService exchange.TradingService
 	var lendingTrieDb *trie.Database
-	var lendingService posv.LendingService
-	if bc.Config().IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch && engine != nil {
+	var lendingService exchange.LendingService
+	if bc.chainConfig.IsTomoXEnabled(block.Number()) && engine != nil {
 		tradingService = engine.GetTomoXService()
 		if tradingService != nil {
 			tradingTrieDb = tradingService.GetStateCache().TrieDB()
@@ -1215,20 +1206,17 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		}
 	}
 	triedb := bc.stateCache.TrieDB()
+	var uncacheState func()
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.Disabled {
-		if err := triedb.Commit(root, false); err != nil {
+		// Persist the TomoX tries ahead of the block, so that the chain never
+		// references trading or lending roots missing from the TomoX database
+		if err := commitTomoXTries(block, tradingTrieDb, tradingRoot, lendingTrieDb, lendingRoot); err != nil {
 			return NonStatTy, err
 		}
-		if tradingTrieDb != nil {
-			if err := tradingTrieDb.Commit(tradingRoot, false); err != nil {
-				return NonStatTy, err
-			}
-		}
-		if lendingTrieDb != nil {
-			if err := lendingTrieDb.Commit(lendingRoot, false); err != nil {
-				return NonStatTy, err
-			}
+		// The state trie is written atomically with the block itself
+		if uncacheState, err = triedb.CommitTo(root, batch); err != nil {
+			return NonStatTy, err
 		}
 	} else {
 		// Full but not archive node, do proper garbage collection
@@ -1267,7 +1255,10 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 			if nodes > limit || imgs > 4*1024*1024 {
 				triedb.Cap(limit - ethdb.IdealBatchSize)
 			}
-			if bc.gcproc > bc.cacheConfig.TrieTimeLimit || chosen > lastWrite+triesInMemory {
+			// Blocks at the retention interval keep their full state on disk, so that
+			// historical calls can be served at those blocks without an archive node
+			retain := bc.cacheConfig.RetainBlocks > 0 && chosen%bc.cacheConfig.RetainBlocks == 0
+			if retain || bc.gcproc > bc.cacheConfig.TrieTimeLimit || chosen > lastWrite+triesInMemory {
 				// If the header is missing (canonical chain behind), we're reorging a low
 				// diff sidechain. Suspend committing until this operation is completed.
 				header := bc.GetHeaderByNumber(chosen)
@@ -1279,18 +1270,19 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 					if chosen < lastWrite+triesInMemory && bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit {
 						log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", bc.cacheConfig.TrieTimeLimit, "optimum", float64(chosen-lastWrite)/triesInMemory)
 					}
-					// Flush an entire trie and restart the counters
-					triedb.Commit(header.Root, true)
-					lastWrite = chosen
-					bc.gcproc = 0
+					// Flush the TomoX tries ahead of the state one and restart the counters
 					if tradingTrieDb != nil && lendingTrieDb != nil {
 						b := bc.GetBlock(header.Hash(), current-triesInMemory)
 						author, _ := bc.Engine().Author(b.Header())
 						oldTradingRoot, _ = tradingService.GetTradingStateRoot(b, author)
 						oldLendingRoot, _ = lendingService.GetLendingStateRoot(b, author)
-						tradingTrieDb.Commit(oldTradingRoot, true)
-						lendingTrieDb.Commit(oldLendingRoot, true)
+						if err := commitTomoXTries(b, tradingTrieDb, oldTradingRoot, lendingTrieDb, oldLendingRoot); err != nil {
+							log.Error("Failed to commit TomoX tries", "number", chosen, "err", err)
+						}
 					}
+					triedb.Commit(header.Root, true)
+					lastWrite = chosen
+					bc.gcproc = 0
 				}
 			}
 			// Garbage collect anything below our required write retention
@@ -1358,6 +1350,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err := batch.Write(); err != nil {
 		return NonStatTy, err
 	}
+	if uncacheState != nil {
+		uncacheState()
+	}
 
 	// Set new head.
 	if status == CanonStatTy {
@@ -1390,7 +1385,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 // only reason this method exists as a separate one is to make locking cleaner
 // with deferred statements.
 func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*types.Log, error) {
-	engine, _ := bc.Engine().(*posv.Posv)
+	engine, _ := bc.Engine().(exchange.Engine)
 
 	// Do a sanity check that the provided chain is actually ordered and linked
 	for i := 1; i < len(chain); i++ {
@@ -1534,14 +1529,20 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		// clear the previous dry-run cache
 		var tradingState *tradingstate.TradingStateDB
 		var lendingState *lendingstate.LendingStateDB
-		var tradingService posv.TradingService
-		var lendingService posv.LendingService
+		var tradingService exchange.TradingService
+		var lendingService exchange.LendingService
 		isSDKNode := false
-		if bc.Config().IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && engine != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch {
+		if bc.chainConfig.IsTomoXEnabled(block.Number()) && engine != nil {
 			tradingService = engine.GetTomoXService()
 			lendingService = engine.GetLendingService()
 			if tradingService != nil && lendingService != nil {
 				isSDKNode = tradingService.IsSDKNode()
+				if tradingService.VerifyEnabled() {
+					if err := verifyTomoXTransactions(block, author, bc.chainConfig.IsTIPTomoXLending(block.Number())); err != nil {
+						bc.reportBlock(block, nil, err)
+						return i, events, coalescedLogs, err
+					}
+				}
 				txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
 				if err != nil {
 					bc.reportBlock(block, nil, err)
@@ -1552,15 +1553,26 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 					bc.reportBlock(block, nil, err)
 					return i, events, coalescedLogs, err
 				}
+				tradingState.SetPruning(bc.chainConfig.IsTIPTomoXPrune(block.Number()))
 				lendingState, err = lendingService.GetLendingState(parent, parentAuthor)
 				if err != nil {
 					bc.reportBlock(block, nil, err)
 					return i, events, coalescedLogs, err
 				}
-				if (block.NumberU64() % bc.chainConfig.Posv.Epoch) == 0 {
-					if err := tradingService.UpdateMediumPriceBeforeEpoch(block.NumberU64()/bc.chainConfig.Posv.Epoch, tradingState, statedb); err != nil {
+				if bc.chainConfig.ExchangeEpochs().IsCheckpoint(block.NumberU64()) {
+					if err := tradingService.UpdateMediumPriceBeforeEpoch(bc.chainConfig.ExchangeEpochs().EpochOf(block.NumberU64()), tradingState, statedb); err != nil {
 						return i, events, coalescedLogs, err
 					}
+					if bc.chainConfig.IsTIPTomoXDelisting(block.Number()) {
+						if err := tradingService.CancelDelistedOrders(block.Header(), tradingState, statedb); err != nil {
+							return i, events, coalescedLogs, err
+						}
+					}
+					if bc.chainConfig.IsTIPTomoXOrderExpiry(block.Number()) {
+						if err := tradingService.PurgeExpiredOrders(block.Header(), tradingState); err != nil {
+							return i, events, coalescedLogs, err
+						}
+					}
 				} else {
 					for _, txMatchBatch := range txMatchBatchData {
 						log.Debug("Verify matching transaction", "txHash", txMatchBatch.TxHash.Hex())
@@ -1570,6 +1582,12 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 							return i, events, coalescedLogs, err
 						}
 					}
+					if bc.chainConfig.IsTIPTomoXStopOrder(block.Number()) {
+						if err := tradingService.ProcessTriggeredOrders(block.Header(), author, bc, statedb, tradingState); err != nil {
+							bc.reportBlock(block, nil, err)
+							return i, events, coalescedLogs, err
+						}
+					}
 					//
 					batches, err := ExtractLendingTransactions(block.Transactions())
 					if err != nil {
@@ -1585,18 +1603,30 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 						}
 					}
 					// liquidate / finalize open lendingTrades
-					if block.Number().Uint64()%bc.chainConfig.Posv.Epoch == common.LiquidateLendingTradeBlock {
+					if bc.chainConfig.ExchangeEpochs().EpochOffset(block.NumberU64()) == common.LiquidateLendingTradeBlock {
 						finalizedTrades := map[common.Hash]*lendingstate.LendingTrade{}
-						finalizedTrades, _, _, _, _, err = lendingService.ProcessLiquidationData(block.Header(), bc, statedb, tradingState, lendingState)
+						var liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades []*lendingstate.LendingTrade
+						finalizedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err = lendingService.ProcessLiquidationData(block.Header(), bc, statedb, tradingState, lendingState)
 						if err != nil {
 							return i, events, coalescedLogs, fmt.Errorf("failed to ProcessLiquidationData. Err: %v ", err)
 						}
-						if isSDKNode {
+						if tradingService.VerifyEnabled() {
+							if err := verifyFinalizedTrades(block, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades); err != nil {
+								bc.reportBlock(block, nil, err)
+								return i, events, coalescedLogs, err
+							}
+						}
+						if isSDKNode || bc.chainConfig.IsTIPTomoXReceiptLogs(block.Number()) {
 							finalizedTx := lendingstate.FinalizedResult{}
 							if finalizedTx, err = ExtractLendingFinalizedTradeTransactions(block.Transactions()); err != nil {
 								return i, events, coalescedLogs, err
 							}
-							bc.AddFinalizedTrades(finalizedTx.TxHash, finalizedTrades)
+							if isSDKNode {
+								bc.AddFinalizedTrades(finalizedTx.TxHash, finalizedTrades)
+							}
+							if bc.chainConfig.IsTIPTomoXReceiptLogs(block.Number()) {
+								statedb.SetSettlementLogs(finalizedTx.TxHash, LiquidationLogs(liquidatedTrades))
+							}
 						}
 					}
 				}
@@ -1673,9 +1703,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			// Only count canonical blocks for GC processing time
 			bc.gcproc += proctime
 			bc.UpdateBlocksHashCache(block)
-			if bc.chainConfig.IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch {
+			if bc.chainConfig.IsTomoXEnabled(block.Number()) {
 				bc.logExchangeData(block)
 				bc.logLendingData(block)
+				bc.indexAddresses(block)
 			}
 		case SideStatTy:
 			log.Debug("Inserted forked block from downloader", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
@@ -1691,12 +1722,12 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		stats.report(chain, i, dirty)
 		if bc.chainConfig.Posv != nil {
 			// epoch block
-			if (chain[i].NumberU64() % bc.chainConfig.Posv.Epoch) == 0 {
+			if bc.chainConfig.Posv.IsCheckpoint(chain[i].NumberU64()) {
 				CheckpointCh <- 1
 
 			}
 			// prepare set of masternodes for the next epoch
-			if (chain[i].NumberU64() % bc.chainConfig.Posv.Epoch) == (bc.chainConfig.Posv.Epoch - bc.chainConfig.Posv.Gap) {
+			if bc.chainConfig.Posv.IsGap(chain[i].NumberU64()) {
 				err := bc.UpdateM1()
 				if err != nil {
 					log.Crit("Error when update masternodes set. Stopping node", "err", err)
@@ -1817,7 +1848,7 @@ func (bc *BlockChain) getResultBlock(block *types.Block, verifiedM2 bool) (*Resu
 	if err != nil {
 		return nil, err
 	}
-	engine, _ := bc.Engine().(*posv.Posv)
+	engine, _ := bc.Engine().(exchange.Engine)
 	author, err := bc.Engine().Author(block.Header()) // Ignore error, we're past header validation
 	if err != nil {
 		bc.reportBlock(block, nil, err)
@@ -1827,28 +1858,45 @@ func (bc *BlockChain) getResultBlock(block *types.Block, verifiedM2 bool) (*Resu
 
 	var tradingState *tradingstate.TradingStateDB
 	var lendingState *lendingstate.LendingStateDB
-	var tradingService posv.TradingService
-	var lendingService posv.LendingService
+	var tradingService exchange.TradingService
+	var lendingService exchange.LendingService
 	isSDKNode := false
-	if bc.Config().IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && engine != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch {
+	if bc.chainConfig.IsTomoXEnabled(block.Number()) && engine != nil {
 		tradingService = engine.GetTomoXService()
 		lendingService = engine.GetLendingService()
 		if tradingService != nil && lendingService != nil {
 			isSDKNode = tradingService.IsSDKNode()
+			if tradingService.VerifyEnabled() {
+				if err := verifyTomoXTransactions(block, author, bc.chainConfig.IsTIPTomoXLending(block.Number())); err != nil {
+					bc.reportBlock(block, nil, err)
+					return nil, err
+				}
+			}
 			tradingState, err = tradingService.GetTradingState(parent, parentAuthor)
 			if err != nil {
 				bc.reportBlock(block, nil, err)
 				return nil, err
 			}
+			tradingState.SetPruning(bc.chainConfig.IsTIPTomoXPrune(block.Number()))
 			lendingState, err = lendingService.GetLendingState(parent, parentAuthor)
 			if err != nil {
 				bc.reportBlock(block, nil, err)
 				return nil, err
 			}
-			if (block.NumberU64() % bc.chainConfig.Posv.Epoch) == 0 {
-				if err := tradingService.UpdateMediumPriceBeforeEpoch(block.NumberU64()/bc.chainConfig.Posv.Epoch, tradingState, statedb); err != nil {
+			if bc.chainConfig.ExchangeEpochs().IsCheckpoint(block.NumberU64()) {
+				if err := tradingService.UpdateMediumPriceBeforeEpoch(bc.chainConfig.ExchangeEpochs().EpochOf(block.NumberU64()), tradingState, statedb); err != nil {
 					return nil, err
 				}
+				if bc.chainConfig.IsTIPTomoXDelisting(block.Number()) {
+					if err := tradingService.CancelDelistedOrders(block.Header(), tradingState, statedb); err != nil {
+						return nil, err
+					}
+				}
+				if bc.chainConfig.IsTIPTomoXOrderExpiry(block.Number()) {
+					if err := tradingService.PurgeExpiredOrders(block.Header(), tradingState); err != nil {
+						return nil, err
+					}
+				}
 			} else {
 				txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
 				if err != nil {
@@ -1863,6 +1911,12 @@ func (bc *BlockChain) getResultBlock(block *types.Block, verifiedM2 bool) (*Resu
 						return nil, err
 					}
 				}
+				if bc.chainConfig.IsTIPTomoXStopOrder(block.Number()) {
+					if err := tradingService.ProcessTriggeredOrders(block.Header(), author, bc, statedb, tradingState); err != nil {
+						bc.reportBlock(block, nil, err)
+						return nil, err
+					}
+				}
 				batches, err := ExtractLendingTransactions(block.Transactions())
 				if err != nil {
 					bc.reportBlock(block, nil, err)
@@ -1877,18 +1931,30 @@ func (bc *BlockChain) getResultBlock(block *types.Block, verifiedM2 bool) (*Resu
 					}
 				}
 				// liquidate / finalize open lendingTrades
-				if block.Number().Uint64()%bc.chainConfig.Posv.Epoch == common.LiquidateLendingTradeBlock {
+				if bc.chainConfig.ExchangeEpochs().EpochOffset(block.NumberU64()) == common.LiquidateLendingTradeBlock {
 					finalizedTrades := map[common.Hash]*lendingstate.LendingTrade{}
-					finalizedTrades, _, _, _, _, err = lendingService.ProcessLiquidationData(block.Header(), bc, statedb, tradingState, lendingState)
+					var liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades []*lendingstate.LendingTrade
+					finalizedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err = lendingService.ProcessLiquidationData(block.Header(), bc, statedb, tradingState, lendingState)
 					if err != nil {
 						return nil, fmt.Errorf("failed to ProcessLiquidationData. Err: %v ", err)
 					}
-					if isSDKNode {
+					if tradingService.VerifyEnabled() {
+						if err := verifyFinalizedTrades(block, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades); err != nil {
+							bc.reportBlock(block, nil, err)
+							return nil, err
+						}
+					}
+					if isSDKNode || bc.chainConfig.IsTIPTomoXReceiptLogs(block.Number()) {
 						finalizedTx := lendingstate.FinalizedResult{}
 						if finalizedTx, err = ExtractLendingFinalizedTradeTransactions(block.Transactions()); err != nil {
 							return nil, err
 						}
-						bc.AddFinalizedTrades(finalizedTx.TxHash, finalizedTrades)
+						if isSDKNode {
+							bc.AddFinalizedTrades(finalizedTx.TxHash, finalizedTrades)
+						}
+						if bc.chainConfig.IsTIPTomoXReceiptLogs(block.Number()) {
+							statedb.SetSettlementLogs(finalizedTx.TxHash, LiquidationLogs(liquidatedTrades))
+						}
 					}
 				}
 			}
@@ -2016,9 +2082,10 @@ func (bc *BlockChain) insertBlock(block *types.Block) ([]interface{}, []*types.L
 		// Only count canonical blocks for GC processing time
 		bc.gcproc += result.proctime
 		bc.UpdateBlocksHashCache(block)
-		if bc.chainConfig.IsTIPTomoX(block.Number()) && bc.chainConfig.Posv != nil && block.NumberU64() > bc.chainConfig.Posv.Epoch {
+		if bc.chainConfig.IsTomoXEnabled(block.Number()) {
 			bc.logExchangeData(block)
 			bc.logLendingData(block)
+			bc.indexAddresses(block)
 		}
 	case SideStatTy:
 		log.Debug("Inserted forked block from fetcher", "number", block.Number(), "hash", block.Hash(), "diff", block.Difficulty(), "elapsed",
@@ -2035,12 +2102,12 @@ func (bc *BlockChain) insertBlock(block *types.Block) ([]interface{}, []*types.L
 	stats.report(types.Blocks{block}, 0, dirty)
 	if bc.chainConfig.Posv != nil {
 		// epoch block
-		if (block.NumberU64() % bc.chainConfig.Posv.Epoch) == 0 {
+		if bc.chainConfig.Posv.IsCheckpoint(block.NumberU64()) {
 			CheckpointCh <- 1
 
 		}
 		// prepare set of masternodes for the next epoch
-		if (block.NumberU64() % bc.chainConfig.Posv.Epoch) == (bc.chainConfig.Posv.Epoch - bc.chainConfig.Posv.Gap) {
+		if bc.chainConfig.Posv.IsGap(block.NumberU64()) {
 			err := bc.UpdateM1()
 			if err != nil {
 				log.Error("Error when update masternodes set. Stopping node", "err", err)
@@ -2120,7 +2187,7 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		// These logs are later announced as deleted.
 		collectLogs = func(h common.Hash) {
 			// Coalesce logs and set 'Removed'.
-			receipts := GetBlockReceipts(bc.db, h, bc.hc.GetBlockNumber(h))
+			receipts := GetBlockReceipts(bc.db, h, bc.hc.GetBlockNumber(h), bc.chainConfig)
 			for _, receipt := range receipts {
 				for _, log := range receipt.Logs {
 					del := *log
@@ -2172,6 +2239,17 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("Invalid new chain")
 		}
 	}
+	// Never drop a block finalized by the votes of the masternodes
+	if finality, ok := bc.engine.(consensus.Finality); ok {
+		if hash, number := finality.Finalized(); number > commonBlock.NumberU64() {
+			for _, block := range oldChain {
+				if block.Hash() == hash {
+					log.Error("Refused to reorg below the finalized block", "number", number, "hash", hash, "common", commonBlock.Number())
+					return ErrFinalizedReorg
+				}
+			}
+		}
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Debug
@@ -2205,14 +2283,67 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		go bc.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
 	}
 	if len(oldChain) > 0 {
+		go bc.reorgFeed.Send(ChainReorgEvent{
+			Ancestor: commonBlock,
+			OldChain: oldChain,
+			NewChain: newChain,
+			Dropped:  diff,
+			Included: types.TxDifference(addedTxs, deletedTxs),
+		})
 		go func() {
 			for _, block := range oldChain {
 				bc.chainSideFeed.Send(ChainSideEvent{Block: block})
 			}
 		}()
 	}
-	if bc.chainConfig.IsTIPTomoX(commonBlock.Number()) && bc.chainConfig.Posv != nil && commonBlock.NumberU64() > bc.chainConfig.Posv.Epoch {
-		bc.reorgTxMatches(deletedTxs, newChain)
+	if bc.chainConfig.IsTomoXEnabled(commonBlock.Number()) {
+		bc.reorgTxMatches(oldChain, deletedTxs, newChain)
+	}
+	return nil
+}
+
+// commitTomoXTries persists the trading and lending tries of a block in a single
+// write to the TomoX database backing both, so that neither can be stored
+// without the other. The write also journals the block as the last one whose
+// TomoX state is complete on disk. Nil trie databases are skipped.
+func commitTomoXTries(block *types.Block, tradingTrieDb *trie.Database, tradingRoot common.Hash, lendingTrieDb *trie.Database, lendingRoot common.Hash) error {
+	var (
+		diskdb   ethdb.KeyValueReader
+		batch    ethdb.Batch
+		uncaches []func()
+	)
+	for _, tries := range []struct {
+		db   *trie.Database
+		root common.Hash
+	}{{tradingTrieDb, tradingRoot}, {lendingTrieDb, lendingRoot}} {
+		if tries.db == nil {
+			continue
+		}
+		if batch == nil {
+			diskdb, batch = tries.db.DiskDB(), tries.db.NewBatch()
+		} else if tries.db.DiskDB() != diskdb {
+			return errors.New("TomoX tries backed by different databases")
+		}
+		uncache, err := tries.db.CommitTo(tries.root, batch)
+		if err != nil {
+			return err
+		}
+		uncaches = append(uncaches, uncache)
+	}
+	if batch == nil {
+		return nil
+	}
+	// Journal the most recent block flushed, older ones are written on shutdown
+	if last := readTomoXCommit(diskdb); last == nil || last.Number <= block.NumberU64() {
+		if err := writeTomoXCommit(batch, &tomoXCommit{Number: block.NumberU64(), Hash: block.Hash(), TradingRoot: tradingRoot, LendingRoot: lendingRoot}); err != nil {
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	for _, uncache := range uncaches {
+		uncache()
 	}
 	return nil
 }
@@ -2229,6 +2360,16 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 		switch ev := event.(type) {
 		case ChainEvent:
 			bc.chainFeed.Send(ev)
+			if bc.tradingScope.Count() > 0 {
+				if trading, ok := bc.collectTradingEvent(ev.Block); ok {
+					bc.tradingFeed.Send(trading)
+				}
+			}
+			if bc.masternodesScope.Count() > 0 {
+				if masternodes, ok := bc.collectMasternodesEvent(ev.Block); ok {
+					bc.masternodesFeed.Send(masternodes)
+				}
+			}
 
 		case ChainHeadEvent:
 			bc.chainHeadFeed.Send(ev)
@@ -2407,6 +2548,11 @@ func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) even
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
 }
 
+// SubscribeChainReorgEvent registers a subscription of ChainReorgEvent.
+func (bc *BlockChain) SubscribeChainReorgEvent(ch chan<- ChainReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
 // SubscribeChainEvent registers a subscription of ChainEvent.
 func (bc *BlockChain) SubscribeChainEvent(ch chan<- ChainEvent) event.Subscription {
 	return bc.scope.Track(bc.chainFeed.Subscribe(ch))
@@ -2417,6 +2563,16 @@ func (bc *BlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Su
 	return bc.scope.Track(bc.chainHeadFeed.Subscribe(ch))
 }
 
+// SubscribeTradingEvent registers a subscription of TradingEvent.
+func (bc *BlockChain) SubscribeTradingEvent(ch chan<- TradingEvent) event.Subscription {
+	return bc.scope.Track(bc.tradingScope.Track(bc.tradingFeed.Subscribe(ch)))
+}
+
+// SubscribeMasternodesEvent registers a subscription of posv.MasternodesEvent.
+func (bc *BlockChain) SubscribeMasternodesEvent(ch chan<- posv.MasternodesEvent) event.Subscription {
+	return bc.scope.Track(bc.masternodesScope.Track(bc.masternodesFeed.Subscribe(ch)))
+}
+
 // SubscribeChainSideEvent registers a subscription of ChainSideEvent.
 func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Subscription {
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
@@ -2448,35 +2604,41 @@ func (bc *BlockChain) UpdateM1() error {
 		return ErrNotPoSV
 	}
 	log.Info("It's time to update new set of masternodes for the next epoch...")
-	// get masternodes information from smart contract
-	client, err := bc.GetClient()
-	if err != nil {
-		return err
-	}
-	addr := common.HexToAddress(common.MasternodeVotingSMC)
-	validator, err := contractValidator.NewTomoValidator(addr, client)
-	if err != nil {
-		return err
-	}
-	opts := new(bind.CallOpts)
-
-	var candidates []common.Address
-
-	// get candidates from slot of stateDB
+	var (
+		candidates []common.Address
+		capacity   func(candidate common.Address) (*big.Int, error)
+	)
+	// get candidates and their capacities from slots of stateDB
 	// if can't get anything, request from contracts
 	stateDB, err := bc.State()
 	if err != nil {
+		client, err := bc.GetClient()
+		if err != nil {
+			return err
+		}
+		addr := common.HexToAddress(common.MasternodeVotingSMC)
+		validator, err := contractValidator.NewTomoValidator(addr, client)
+		if err != nil {
+			return err
+		}
+		opts := new(bind.CallOpts)
 		candidates, err = validator.GetCandidates(opts)
 		if err != nil {
 			return err
 		}
+		capacity = func(candidate common.Address) (*big.Int, error) {
+			return validator.GetCandidateCap(opts, candidate)
+		}
 	} else {
 		candidates = state.GetCandidates(stateDB)
+		capacity = func(candidate common.Address) (*big.Int, error) {
+			return state.GetCandidateCap(stateDB, candidate), nil
+		}
 	}
 
 	var ms []posv.Masternode
 	for _, candidate := range candidates {
-		v, err := validator.GetCandidateCap(opts, candidate)
+		v, err := capacity(candidate)
 		if err != nil {
 			return err
 		}
@@ -2512,12 +2674,12 @@ func (bc *BlockChain) UpdateM1() error {
 }
 
 func (bc *BlockChain) logExchangeData(block *types.Block) {
-	engine, ok := bc.Engine().(*posv.Posv)
+	engine, ok := bc.Engine().(exchange.Engine)
 	if !ok || engine == nil {
 		return
 	}
 	tomoXService := engine.GetTomoXService()
-	if tomoXService == nil || !tomoXService.IsSDKNode() {
+	if tomoXService == nil || !(tomoXService.IsSDKNode() || tomoXService.CandlesEnabled()) {
 		return
 	}
 	txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
@@ -2528,10 +2690,12 @@ func (bc *BlockChain) logExchangeData(block *types.Block) {
 	if len(txMatchBatchData) == 0 {
 		return
 	}
-	currentState, err := bc.State()
-	if err != nil {
-		log.Crit("logExchangeData: failed to get current state", "err", err)
-		return
+	var currentState *state.StateDB
+	if tomoXService.IsSDKNode() {
+		if currentState, err = bc.State(); err != nil {
+			log.Crit("logExchangeData: failed to get current state", "err", err)
+			return
+		}
 	}
 	start := time.Now()
 	defer func() {
@@ -2540,6 +2704,7 @@ func (bc *BlockChain) logExchangeData(block *types.Block) {
 		log.Debug("logExchangeData takes", "time", common.PrettyDuration(time.Since(start)), "blockNumber", block.NumberU64())
 	}()
 
+	var blockTrades []map[string]string
 	for _, txMatchBatch := range txMatchBatchData {
 		dirtyOrderCount := uint64(0)
 		for _, txMatch := range txMatchBatch.Data {
@@ -2559,6 +2724,10 @@ func (bc *BlockChain) logExchangeData(block *types.Block) {
 			if ok && resultTrades != nil {
 				trades = resultTrades.([]map[string]string)
 			}
+			blockTrades = append(blockTrades, trades...)
+			if !tomoXService.IsSDKNode() {
+				continue
+			}
 
 			// getRejectedOrder from cache
 			rejected, ok := bc.rejectedOrders.Get(cacheKey)
@@ -2573,16 +2742,21 @@ func (bc *BlockChain) logExchangeData(block *types.Block) {
 			}
 		}
 	}
+	if tomoXService.CandlesEnabled() {
+		if err := tomoXService.AggregateCandles(block, blockTrades); err != nil {
+			log.Error("Failed to aggregate candles", "blockNumber", block.Number(), "err", err)
+		}
+	}
 }
 
-func (bc *BlockChain) reorgTxMatches(deletedTxs types.Transactions, newChain types.Blocks) {
-	engine, ok := bc.Engine().(*posv.Posv)
+func (bc *BlockChain) reorgTxMatches(oldChain types.Blocks, deletedTxs types.Transactions, newChain types.Blocks) {
+	engine, ok := bc.Engine().(exchange.Engine)
 	if !ok || engine == nil {
 		return
 	}
 	tomoXService := engine.GetTomoXService()
 	lendingService := engine.GetLendingService()
-	if tomoXService == nil || !tomoXService.IsSDKNode() {
+	if tomoXService == nil || !(tomoXService.IsSDKNode() || tomoXService.CandlesEnabled() || tomoXService.AddressIndexEnabled()) {
 		return
 	}
 	start := time.Now()
@@ -2591,17 +2765,39 @@ func (bc *BlockChain) reorgTxMatches(deletedTxs types.Transactions, newChain typ
 		// That's why we should put this log statement in an anonymous function
 		log.Debug("reorgTxMatches takes", "time", common.PrettyDuration(time.Since(start)))
 	}()
-	for _, deletedTx := range deletedTxs {
-		if deletedTx.IsTradingTransaction() {
-			log.Debug("Rollback reorg txMatch", "txhash", deletedTx.Hash())
-			if err := tomoXService.RollbackReorgTxMatch(deletedTx.Hash()); err != nil {
-				log.Crit("Reorg trading failed", "err", err, "hash", deletedTx.Hash())
+	if tomoXService.CandlesEnabled() {
+		// the old chain is ordered from its head down, so candles are restored in reverse order of aggregation
+		for _, block := range oldChain {
+			if err := tomoXService.RollbackCandles(block); err != nil {
+				log.Error("Failed to roll back candles", "blockNumber", block.Number(), "hash", block.Hash(), "err", err)
 			}
 		}
-		if lendingService != nil && (deletedTx.IsLendingTransaction() || deletedTx.IsLendingFinalizedTradeTransaction()) {
-			log.Debug("Rollback reorg lendingItem", "txhash", deletedTx.Hash())
-			if err := lendingService.RollbackLendingData(deletedTx.Hash()); err != nil {
-				log.Crit("Reorg lending failed", "err", err, "hash", deletedTx.Hash())
+	}
+	if tomoXService.AddressIndexEnabled() {
+		for _, block := range oldChain {
+			if err := tomoXService.RollbackOrderIndex(block); err != nil {
+				log.Error("Failed to roll back order index", "blockNumber", block.Number(), "hash", block.Hash(), "err", err)
+			}
+			if lendingService != nil {
+				if err := lendingService.RollbackTradeIndex(block); err != nil {
+					log.Error("Failed to roll back lending trade index", "blockNumber", block.Number(), "hash", block.Hash(), "err", err)
+				}
+			}
+		}
+	}
+	if tomoXService.IsSDKNode() {
+		for _, deletedTx := range deletedTxs {
+			if deletedTx.IsTradingTransaction() {
+				log.Debug("Rollback reorg txMatch", "txhash", deletedTx.Hash())
+				if err := tomoXService.RollbackReorgTxMatch(deletedTx.Hash()); err != nil {
+					log.Crit("Reorg trading failed", "err", err, "hash", deletedTx.Hash())
+				}
+			}
+			if lendingService != nil && (deletedTx.IsLendingTransaction() || deletedTx.IsLendingFinalizedTradeTransaction()) {
+				log.Debug("Rollback reorg lendingItem", "txhash", deletedTx.Hash())
+				if err := lendingService.RollbackLendingData(deletedTx.Hash()); err != nil {
+					log.Crit("Reorg lending failed", "err", err, "hash", deletedTx.Hash())
+				}
 			}
 		}
 	}
@@ -2610,11 +2806,12 @@ func (bc *BlockChain) reorgTxMatches(deletedTxs types.Transactions, newChain typ
 	for i := len(newChain) - 1; i >= 0; i-- {
 		bc.logExchangeData(newChain[i])
 		bc.logLendingData(newChain[i])
+		bc.indexAddresses(newChain[i])
 	}
 }
 
 func (bc *BlockChain) logLendingData(block *types.Block) {
-	engine, ok := bc.Engine().(*posv.Posv)
+	engine, ok := bc.Engine().(exchange.Engine)
 	if !ok || engine == nil {
 		return
 	}
@@ -2668,7 +2865,7 @@ func (bc *BlockChain) logLendingData(block *types.Block) {
 	}
 
 	// update finalizedTrades
-	if block.Number().Uint64()%bc.chainConfig.Posv.Epoch == common.LiquidateLendingTradeBlock {
+	if bc.chainConfig.ExchangeEpochs().EpochOffset(block.NumberU64()) == common.LiquidateLendingTradeBlock {
 		finalizedTx, err := ExtractLendingFinalizedTradeTransactions(block.Transactions())
 		if err != nil {
 			log.Crit("failed to extract finalizedTrades transaction", "err", err)
@@ -2686,6 +2883,54 @@ func (bc *BlockChain) logLendingData(block *types.Block) {
 	}
 }
 
+// indexAddresses indexes the orders and the lending trades of a canonical block
+// by the addresses of their users, if the address index is enabled.
+func (bc *BlockChain) indexAddresses(block *types.Block) {
+	engine, ok := bc.Engine().(exchange.Engine)
+	if !ok || engine == nil {
+		return
+	}
+	tomoXService := engine.GetTomoXService()
+	if tomoXService == nil || !tomoXService.AddressIndexEnabled() {
+		return
+	}
+	txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
+	if err != nil {
+		log.Error("Failed to extract matching transactions", "blockNumber", block.Number(), "err", err)
+		return
+	}
+	if err := tomoXService.IndexOrders(block, txMatchBatchData); err != nil {
+		log.Error("Failed to index orders", "blockNumber", block.Number(), "err", err)
+	}
+	lendingService := engine.GetLendingService()
+	if lendingService == nil || !bc.chainConfig.IsTIPTomoXLending(block.Number()) {
+		return
+	}
+	batches, err := ExtractLendingTransactions(block.Transactions())
+	if err != nil {
+		log.Error("Failed to extract lending transactions", "blockNumber", block.Number(), "err", err)
+		return
+	}
+	var trades []*lendingstate.LendingTrade
+	for _, batch := range batches {
+		for _, item := range batch.Data {
+			cached, ok := bc.resultLendingTrade.Get(crypto.Keccak256Hash(batch.TxHash.Bytes(), lendingstate.GetLendingCacheKey(item).Bytes()))
+			if !ok || cached == nil {
+				continue
+			}
+			// the cached trades are shared, the hash of their transaction is set on copies
+			for _, trade := range cached.([]*lendingstate.LendingTrade) {
+				indexed := *trade
+				indexed.TxHash = batch.TxHash
+				trades = append(trades, &indexed)
+			}
+		}
+	}
+	if err := lendingService.IndexLendingTrades(block, trades); err != nil {
+		log.Error("Failed to index lending trades", "blockNumber", block.Number(), "err", err)
+	}
+}
+
 func (bc *BlockChain) AddMatchingResult(txHash common.Hash, matchingResults map[common.Hash]tradingstate.MatchingResult) {
 	for hash, result := range matchingResults {
 		cacheKey := crypto.Keccak256Hash(txHash.Bytes(), hash.Bytes())
@@ -2704,3 +2949,94 @@ func (bc *BlockChain) AddLendingResult(txHash common.Hash, lendingResults map[co
 func (bc *BlockChain) AddFinalizedTrades(txHash common.Hash, trades map[common.Hash]*lendingstate.LendingTrade) {
 	bc.finalizedTrade.Add(txHash, trades)
 }
+
+// collectMasternodesEvent compares the masternodes of the given checkpoint block
+// with the ones of the previous checkpoint, if the block is a checkpoint.
+func (bc *BlockChain) collectMasternodesEvent(block *types.Block) (posv.MasternodesEvent, bool) {
+	if bc.chainConfig.Posv == nil || block.NumberU64() == 0 || !bc.chainConfig.Posv.IsCheckpoint(block.NumberU64()) {
+		return posv.MasternodesEvent{}, false
+	}
+	checkpoint, _ := bc.chainConfig.Posv.PreviousCheckpoint(block.NumberU64(), 1)
+	previous := bc.GetHeaderByNumber(checkpoint)
+	if previous == nil {
+		return posv.MasternodesEvent{}, false
+	}
+	masternodes := posv.NewMasternodesEvent(previous, block.Header())
+	return masternodes, masternodes.Changed()
+}
+
+// collectTradingEvent gathers the order fills and lending trades of the given
+// block from the matching results cached while the block was processed.
+func (bc *BlockChain) collectTradingEvent(block *types.Block) (TradingEvent, bool) {
+	trading := TradingEvent{Block: block}
+	if bc.chainConfig.ExchangeEpochs() == nil || !bc.chainConfig.IsTIPTomoX(block.Number()) {
+		return trading, false
+	}
+	txMatchBatchData, err := ExtractTradingTransactions(block.Transactions())
+	if err != nil {
+		log.Debug("Failed to extract matching transactions", "number", block.Number(), "err", err)
+		return trading, false
+	}
+	for _, txMatchBatch := range txMatchBatchData {
+		for _, txMatch := range txMatchBatch.Data {
+			takerOrder, err := txMatch.DecodeOrder()
+			if err != nil {
+				continue
+			}
+			cached, ok := bc.resultTrade.Get(crypto.Keccak256Hash(txMatchBatch.TxHash.Bytes(), tradingstate.GetMatchingResultCacheKey(takerOrder).Bytes()))
+			if !ok || cached == nil {
+				continue
+			}
+			for _, trade := range cached.([]map[string]string) {
+				if trade == nil {
+					continue
+				}
+				trading.Trades = append(trading.Trades, &tradingstate.Trade{
+					Taker:          takerOrder.UserAddress,
+					Maker:          common.HexToAddress(trade[tradingstate.TradeMaker]),
+					BaseToken:      takerOrder.BaseToken,
+					QuoteToken:     takerOrder.QuoteToken,
+					MakerOrderHash: common.HexToHash(trade[tradingstate.TradeMakerOrderHash]),
+					TakerOrderHash: takerOrder.Hash,
+					MakerExchange:  common.HexToAddress(trade[tradingstate.TradeMakerExchange]),
+					TakerExchange:  takerOrder.ExchangeAddress,
+					TxHash:         txMatchBatch.TxHash,
+					PricePoint:     tradingstate.ToBigInt(trade[tradingstate.TradePrice]),
+					Amount:         tradingstate.ToBigInt(trade[tradingstate.TradeQuantity]),
+					Status:         tradingstate.TradeStatusSuccess,
+					TakerOrderSide: takerOrder.Side,
+					TakerOrderType: takerOrder.Type,
+					MakerOrderType: trade[tradingstate.MakerOrderType],
+				})
+			}
+		}
+	}
+	if bc.chainConfig.IsTIPTomoXLending(block.Number()) {
+		batches, err := ExtractLendingTransactions(block.Transactions())
+		if err != nil {
+			log.Debug("Failed to extract lending transactions", "number", block.Number(), "err", err)
+			return trading, false
+		}
+		for _, batch := range batches {
+			for _, item := range batch.Data {
+				cached, ok := bc.resultLendingTrade.Get(crypto.Keccak256Hash(batch.TxHash.Bytes(), lendingstate.GetLendingCacheKey(item).Bytes()))
+				if ok && cached != nil {
+					trading.LendingTrades = append(trading.LendingTrades, cached.([]*lendingstate.LendingTrade)...)
+				}
+			}
+		}
+		if bc.chainConfig.ExchangeEpochs().EpochOffset(block.NumberU64()) == common.LiquidateLendingTradeBlock {
+			if finalizedTx, err := ExtractLendingFinalizedTradeTransactions(block.Transactions()); err == nil {
+				if cached, ok := bc.finalizedTrade.Get(finalizedTx.TxHash); ok && cached != nil {
+					finalized := []*lendingstate.LendingTrade{}
+					for _, trade := range cached.(map[common.Hash]*lendingstate.LendingTrade) {
+						finalized = append(finalized, trade)
+					}
+					sort.Slice(finalized, func(i, j int) bool { return finalized[i].TradeId < finalized[j].TradeId })
+					trading.LendingTrades = append(trading.LendingTrades, finalized...)
+				}
+			}
+		}
+	}
+	return trading, len(trading.Trades) > 0 || len(trading.LendingTrades) > 0
+}
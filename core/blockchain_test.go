@@ -28,6 +28,7 @@ import (
 	"github.com/tomochain/tomochain/core/rawdb"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/consensus/ethash"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
@@ -413,6 +414,43 @@ func testReorg(t *testing.T, first, second []int64, td int64, full bool) {
 	}
 }
 
+// finalizingEngine is a fake engine with a fixed finalized block.
+type finalizingEngine struct {
+	consensus.Engine
+	hash   common.Hash
+	number uint64
+}
+
+func (e *finalizingEngine) Finalized() (common.Hash, uint64) { return e.hash, e.number }
+
+// Tests that a difficult chain is refused if it drops a finalized block.
+func TestReorgBelowFinalized(t *testing.T) {
+	engine := &finalizingEngine{Engine: ethash.NewFaker()}
+	db, blockchain, err := newCanonical(engine, 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	easyBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {
+		b.OffsetTime(0)
+	})
+	diffBlocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), ethash.NewFaker(), db, 4, func(i int, b *BlockGen) {
+		b.OffsetTime(-9)
+	})
+	if _, err := blockchain.InsertChain(easyBlocks); err != nil {
+		t.Fatalf("failed to insert easy chain: %v", err)
+	}
+	engine.hash, engine.number = easyBlocks[1].Hash(), easyBlocks[1].NumberU64()
+
+	if _, err := blockchain.InsertChain(diffBlocks); err != ErrFinalizedReorg {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrFinalizedReorg)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != easyBlocks[2].Hash() {
+		t.Errorf("head mismatch: have #%d %x, want #%d %x", head.NumberU64(), head.Hash(), easyBlocks[2].NumberU64(), easyBlocks[2].Hash())
+	}
+}
+
 // Tests that the insertion functions detect banned hashes.
 func TestBadHeaderHashes(t *testing.T) { testBadHashes(t, false) }
 func TestBadBlockHashes(t *testing.T)  { testBadHashes(t, true) }
@@ -624,7 +662,7 @@ func TestFastVsFullChains(t *testing.T) {
 		} else if types.CalcUncleHash(fblock.Uncles()) != types.CalcUncleHash(ablock.Uncles()) {
 			t.Errorf("block #%d [%x]: uncles mismatch: have %v, want %v", num, hash, fblock.Uncles(), ablock.Uncles())
 		}
-		if freceipts, areceipts := GetBlockReceipts(fastDb, hash, GetBlockNumber(fastDb, hash)), GetBlockReceipts(archiveDb, hash, GetBlockNumber(archiveDb, hash)); types.DeriveSha(freceipts) != types.DeriveSha(areceipts) {
+		if freceipts, areceipts := GetBlockReceipts(fastDb, hash, GetBlockNumber(fastDb, hash), gspec.Config), GetBlockReceipts(archiveDb, hash, GetBlockNumber(archiveDb, hash), gspec.Config); types.DeriveSha(freceipts) != types.DeriveSha(areceipts) {
 			t.Errorf("block #%d [%x]: receipts mismatch: have %v, want %v", num, hash, freceipts, areceipts)
 		}
 	}
@@ -800,16 +838,35 @@ func TestChainTxReorgs(t *testing.T) {
 			gen.AddTx(futureAdd) // This transaction will be added after a full reorg
 		}
 	})
+	reorgs := make(chan ChainReorgEvent, 1)
+	sub := blockchain.SubscribeChainReorgEvent(reorgs)
+	defer sub.Unsubscribe()
+
 	if _, err := blockchain.InsertChain(chain); err != nil {
 		t.Fatalf("failed to insert forked chain: %v", err)
 	}
+	// reorg event
+	select {
+	case ev := <-reorgs:
+		if ev.Ancestor.Hash() != genesis.Hash() || len(ev.OldChain) != 3 || len(ev.NewChain) != 4 {
+			t.Errorf("reorg segments mismatch: ancestor #%d, dropped %d blocks, added %d", ev.Ancestor.NumberU64(), len(ev.OldChain), len(ev.NewChain))
+		}
+		if len(types.TxDifference(ev.Dropped, types.Transactions{pastDrop, freshDrop})) != 0 || len(ev.Dropped) != 2 {
+			t.Errorf("dropped transactions mismatch: have %d", len(ev.Dropped))
+		}
+		if len(types.TxDifference(ev.Included, types.Transactions{pastAdd, freshAdd, futureAdd})) != 0 || len(ev.Included) != 3 {
+			t.Errorf("included transactions mismatch: have %d", len(ev.Included))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("reorg event not posted")
+	}
 
 	// removed tx
 	for i, tx := range (types.Transactions{pastDrop, freshDrop}) {
 		if txn, _, _, _ := GetTransaction(db, tx.Hash()); txn != nil {
 			t.Errorf("drop %d: tx %v found while shouldn't have been", i, txn)
 		}
-		if rcpt, _, _, _ := GetReceipt(db, tx.Hash()); rcpt != nil {
+		if rcpt, _, _, _ := GetReceipt(db, tx.Hash(), gspec.Config); rcpt != nil {
 			t.Errorf("drop %d: receipt %v found while shouldn't have been", i, rcpt)
 		}
 	}
@@ -818,7 +875,7 @@ func TestChainTxReorgs(t *testing.T) {
 		if txn, _, _, _ := GetTransaction(db, tx.Hash()); txn == nil {
 			t.Errorf("add %d: expected tx to be found", i)
 		}
-		if rcpt, _, _, _ := GetReceipt(db, tx.Hash()); rcpt == nil {
+		if rcpt, _, _, _ := GetReceipt(db, tx.Hash(), gspec.Config); rcpt == nil {
 			t.Errorf("add %d: expected receipt to be found", i)
 		}
 	}
@@ -827,7 +884,7 @@ func TestChainTxReorgs(t *testing.T) {
 		if txn, _, _, _ := GetTransaction(db, tx.Hash()); txn == nil {
 			t.Errorf("share %d: expected tx to be found", i)
 		}
-		if rcpt, _, _, _ := GetReceipt(db, tx.Hash()); rcpt == nil {
+		if rcpt, _, _, _ := GetReceipt(db, tx.Hash(), gspec.Config); rcpt == nil {
 			t.Errorf("share %d: expected receipt to be found", i)
 		}
 	}
@@ -1313,6 +1370,36 @@ func TestTrieForkGC(t *testing.T) {
 	}
 }
 
+// Tests that pruning nodes keep the full state of the blocks at the retention
+// interval on disk, and only of those.
+func TestTrieRetention(t *testing.T) {
+	engine := ethash.NewFaker()
+
+	db := rawdb.NewMemoryDatabase()
+	genesis := new(Genesis).MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2*triesInMemory, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	diskdb := rawdb.NewMemoryDatabase()
+	new(Genesis).MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, RetainBlocks: 10}
+	chain, err := NewBlockChain(diskdb, cacheConfig, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for _, block := range blocks[:len(blocks)-triesInMemory] {
+		retained := block.NumberU64()%cacheConfig.RetainBlocks == 0
+		if ok, _ := diskdb.Has(block.Root().Bytes()); ok != retained {
+			t.Errorf("block %d: state persistence mismatch: have %v, want %v", block.NumberU64(), ok, retained)
+		}
+	}
+}
+
 // Tests that doing large reorgs works even if the state associated with the
 // forking point is not available any more.
 func TestLargeReorgTrieGC(t *testing.T) {
@@ -45,10 +45,12 @@ type DatabaseDeleter interface {
 }
 
 var (
-	headHeaderKey = []byte("LastHeader")
-	headBlockKey  = []byte("LastBlock")
-	headFastKey   = []byte("LastFast")
-	trieSyncKey   = []byte("TrieSync")
+	headHeaderKey  = []byte("LastHeader")
+	headBlockKey   = []byte("LastBlock")
+	headFastKey    = []byte("LastFast")
+	trieSyncKey    = []byte("TrieSync")
+	minerPauseKey  = []byte("MinerPaused")
+	historyTailKey = []byte("HistoryTail")
 
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
@@ -158,6 +160,23 @@ func GetTrieSyncProgress(db DatabaseReader) uint64 {
 	return new(big.Int).SetBytes(data).Uint64()
 }
 
+// GetMinerPaused retrieves whether block sealing was paused for maintenance, so
+// that the pause survives restarts.
+func GetMinerPaused(db DatabaseReader) bool {
+	data, _ := db.Get(minerPauseKey)
+	return len(data) > 0 && data[0] == 1
+}
+
+// GetHistoryTail retrieves the number of the oldest block whose body and receipts
+// weren't pruned by history expiry, 0 if no history was pruned.
+func GetHistoryTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(historyTailKey)
+	if len(data) == 0 {
+		return 0
+	}
+	return new(big.Int).SetBytes(data).Uint64()
+}
+
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
@@ -244,9 +263,10 @@ func GetBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block {
 	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
 }
 
-// GetBlockReceipts retrieves the receipts generated by the transactions included
-// in a block given by its hash.
-func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
+// GetRawBlockReceipts retrieves the receipts generated by the transactions included
+// in a block given by its hash, with only the fields kept in their storage
+// encoding. The consensus fields are all available.
+func GetRawBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
 	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
 	if len(data) == 0 {
 		return nil
@@ -263,6 +283,65 @@ func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64) types.
 	return receipts
 }
 
+// GetBlockReceipts retrieves the receipts generated by the transactions included
+// in a block given by its hash, reconstructing the fields derivable from the
+// block body.
+func GetBlockReceipts(db DatabaseReader, hash common.Hash, number uint64, config *params.ChainConfig) types.Receipts {
+	receipts := GetRawBlockReceipts(db, hash, number)
+	if receipts == nil {
+		return nil
+	}
+	body := GetBody(db, hash, number)
+	if body == nil {
+		log.Error("Missing body but have receipts", "hash", hash, "number", number)
+		return nil
+	}
+	if err := receipts.DeriveFields(config, hash, number, body.Transactions); err != nil {
+		log.Error("Failed to derive block receipts fields", "hash", hash, "number", number, "err", err)
+		return nil
+	}
+	return receipts
+}
+
+// GetBlockLogs retrieves the logs generated by the transactions included in a
+// block given by its hash, grouped by transaction. Only the logs of the stored
+// receipts are decoded, their block metadata being derived from the block body.
+func GetBlockLogs(db DatabaseReader, hash common.Hash, number uint64) [][]*types.Log {
+	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	receipts := []*types.ReceiptLogsForStorage{}
+	if err := rlp.DecodeBytes(data, &receipts); err != nil {
+		log.Error("Invalid receipt array RLP", "hash", hash, "err", err)
+		return nil
+	}
+	body := GetBody(db, hash, number)
+	if body == nil {
+		log.Error("Missing body but have receipts", "hash", hash, "number", number)
+		return nil
+	}
+	if len(body.Transactions) != len(receipts) {
+		log.Error("Transaction and receipt count mismatch", "hash", hash, "number", number, "txs", len(body.Transactions), "receipts", len(receipts))
+		return nil
+	}
+	logs := make([][]*types.Log, len(receipts))
+	logIndex := uint(0)
+	for i, receipt := range receipts {
+		txHash := body.Transactions[i].Hash()
+		for _, l := range receipt.Logs {
+			l.BlockNumber = number
+			l.BlockHash = hash
+			l.TxHash = txHash
+			l.TxIndex = uint(i)
+			l.Index = logIndex
+			logIndex++
+		}
+		logs[i] = receipt.Logs
+	}
+	return logs
+}
+
 // GetTxLookupEntry retrieves the positional metadata associated with a transaction
 // hash to allow retrieving the transaction or receipt by hash.
 func GetTxLookupEntry(db DatabaseReader, hash common.Hash) (common.Hash, uint64, uint64) {
@@ -317,12 +396,12 @@ func GetTransaction(db DatabaseReader, hash common.Hash) (*types.Transaction, co
 
 // GetReceipt retrieves a specific transaction receipt from the database, along with
 // its added positional metadata.
-func GetReceipt(db DatabaseReader, hash common.Hash) (*types.Receipt, common.Hash, uint64, uint64) {
+func GetReceipt(db DatabaseReader, hash common.Hash, config *params.ChainConfig) (*types.Receipt, common.Hash, uint64, uint64) {
 	// Retrieve the lookup metadata and resolve the receipt from the receipts
 	blockHash, blockNumber, receiptIndex := GetTxLookupEntry(db, hash)
 
 	if blockHash != (common.Hash{}) {
-		receipts := GetBlockReceipts(db, blockHash, blockNumber)
+		receipts := GetBlockReceipts(db, blockHash, blockNumber, config)
 		if len(receipts) <= int(receiptIndex) {
 			log.Error("Receipt refereced missing", "number", blockNumber, "hash", blockHash, "index", receiptIndex)
 			return nil, common.Hash{}, 0, 0
@@ -395,6 +474,27 @@ func WriteTrieSyncProgress(db ethdb.KeyValueWriter, count uint64) error {
 	return nil
 }
 
+// WriteMinerPaused stores whether block sealing is paused for maintenance.
+func WriteMinerPaused(db ethdb.KeyValueWriter, paused bool) error {
+	data := []byte{0}
+	if paused {
+		data[0] = 1
+	}
+	if err := db.Put(minerPauseKey, data); err != nil {
+		log.Crit("Failed to store miner pause flag", "err", err)
+	}
+	return nil
+}
+
+// WriteHistoryTail stores the number of the oldest block whose body and receipts
+// weren't pruned by history expiry.
+func WriteHistoryTail(db ethdb.KeyValueWriter, number uint64) error {
+	if err := db.Put(historyTailKey, new(big.Int).SetUint64(number).Bytes()); err != nil {
+		log.Crit("Failed to store history tail", "err", err)
+	}
+	return nil
+}
+
 // WriteHeader serializes a block header into the database.
 func WriteHeader(db ethdb.KeyValueWriter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
@@ -480,6 +580,29 @@ func WriteBlockReceipts(db ethdb.KeyValueWriter, hash common.Hash, number uint64
 	return nil
 }
 
+// MigrateBlockReceipts rewrites the receipts of a block given by its hash into
+// the compact storage encoding, dropping the derivable fields and blooms kept by
+// the legacy one. It reports whether the stored receipts were changed.
+func MigrateBlockReceipts(db ethdb.Database, hash common.Hash, number uint64) (bool, error) {
+	key := append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+	data, _ := db.Get(key)
+	if len(data) == 0 {
+		return false, nil
+	}
+	storageReceipts := []*types.ReceiptForStorage{}
+	if err := rlp.DecodeBytes(data, &storageReceipts); err != nil {
+		return false, err
+	}
+	compact, err := rlp.EncodeToBytes(storageReceipts)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(compact, data) {
+		return false, nil
+	}
+	return true, db.Put(key, compact)
+}
+
 // WriteTxLookupEntries stores a positional metadata for every transaction from
 // a block, enabling hash based transaction and receipt lookups.
 func WriteTxLookupEntries(db ethdb.KeyValueWriter, block *types.Block) error {
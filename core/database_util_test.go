@@ -286,6 +286,23 @@ func TestHeadStorage(t *testing.T) {
 	}
 }
 
+// Tests that the maintenance pause flag of the miner can be stored and cleared.
+func TestMinerPauseStorage(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	if GetMinerPaused(db) {
+		t.Fatalf("Pristine database reports a paused miner")
+	}
+	WriteMinerPaused(db, true)
+	if !GetMinerPaused(db) {
+		t.Fatalf("Pause flag not persisted")
+	}
+	WriteMinerPaused(db, false)
+	if GetMinerPaused(db) {
+		t.Fatalf("Pause flag not cleared")
+	}
+}
+
 // Tests that positional lookup metadata can be stored and retrieved.
 func TestLookupStorage(t *testing.T) {
 	db := rawdb.NewMemoryDatabase()
@@ -357,18 +374,20 @@ func TestBlockReceiptStorage(t *testing.T) {
 		ContractAddress: common.BytesToAddress([]byte{0x02, 0x22, 0x22}),
 		GasUsed:         222222,
 	}
+	receipt1.Bloom = types.CreateBloom(types.Receipts{receipt1})
+	receipt2.Bloom = types.CreateBloom(types.Receipts{receipt2})
 	receipts := []*types.Receipt{receipt1, receipt2}
 
 	// Check that no receipt entries are in a pristine database
 	hash := common.BytesToHash([]byte{0x03, 0x14})
-	if rs := GetBlockReceipts(db, hash, 0); len(rs) != 0 {
+	if rs := GetRawBlockReceipts(db, hash, 0); len(rs) != 0 {
 		t.Fatalf("non existent receipts returned: %v", rs)
 	}
 	// Insert the receipt slice into the database and check presence
 	if err := WriteBlockReceipts(db, hash, 0, receipts); err != nil {
 		t.Fatalf("failed to write block receipts: %v", err)
 	}
-	if rs := GetBlockReceipts(db, hash, 0); len(rs) == 0 {
+	if rs := GetRawBlockReceipts(db, hash, 0); len(rs) == 0 {
 		t.Fatalf("no receipts returned")
 	} else {
 		for i := 0; i < len(receipts); i++ {
@@ -382,7 +401,63 @@ func TestBlockReceiptStorage(t *testing.T) {
 	}
 	// Delete the receipt slice and check purge
 	DeleteBlockReceipts(db, hash, 0)
-	if rs := GetBlockReceipts(db, hash, 0); len(rs) != 0 {
+	if rs := GetRawBlockReceipts(db, hash, 0); len(rs) != 0 {
 		t.Fatalf("deleted receipts returned: %v", rs)
 	}
 }
+
+// Tests that receipts stored in the legacy format are rewritten into the compact
+// one, and that their logs are served with the block metadata derived.
+func TestMigrateBlockReceipts(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	tx := types.NewTransaction(1, common.BytesToAddress([]byte{0x11}), big.NewInt(111), 1111, big.NewInt(11111), nil)
+	receipt := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 1,
+		Logs: []*types.Log{
+			{Address: common.BytesToAddress([]byte{0x11}), Topics: []common.Hash{{0x01}}},
+			{Address: common.BytesToAddress([]byte{0x01, 0x11}), Data: []byte{0x02}},
+		},
+		TxHash:  tx.Hash(),
+		GasUsed: 1,
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	hash, number := common.BytesToHash([]byte{0x03, 0x14}), uint64(1)
+	if err := WriteBody(db, hash, number, &types.Body{Transactions: types.Transactions{tx}}); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	// Store the receipts in the legacy flattened format
+	logs := make([]*types.LogForStorage, len(receipt.Logs))
+	for i, l := range receipt.Logs {
+		logs[i] = (*types.LogForStorage)(l)
+	}
+	legacy, _ := rlp.EncodeToBytes([]interface{}{
+		[]interface{}{[]byte{0x01}, receipt.CumulativeGasUsed, receipt.Bloom, receipt.TxHash, receipt.ContractAddress, logs, receipt.GasUsed},
+	})
+	key := append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+	db.Put(key, legacy)
+
+	if changed, err := MigrateBlockReceipts(db, hash, number); err != nil || !changed {
+		t.Fatalf("legacy receipts not migrated: changed %v, err %v", changed, err)
+	}
+	if compact, _ := db.Get(key); len(compact) >= len(legacy) {
+		t.Errorf("receipts not compacted: have %d bytes, legacy %d", len(compact), len(legacy))
+	}
+	if changed, err := MigrateBlockReceipts(db, hash, number); err != nil || changed {
+		t.Fatalf("compact receipts rewritten: changed %v, err %v", changed, err)
+	}
+	if rs := GetRawBlockReceipts(db, hash, number); len(rs) != 1 || rs[0].Bloom != receipt.Bloom {
+		t.Fatalf("receipt bloom not regenerated: %v", rs)
+	}
+	blockLogs := GetBlockLogs(db, hash, number)
+	if len(blockLogs) != 1 || len(blockLogs[0]) != len(receipt.Logs) {
+		t.Fatalf("log count mismatch: have %v, want %d", blockLogs, len(receipt.Logs))
+	}
+	for i, l := range blockLogs[0] {
+		if l.Address != receipt.Logs[i].Address || l.BlockHash != hash || l.BlockNumber != number || l.TxHash != tx.Hash() || l.Index != uint(i) {
+			t.Errorf("log %d mismatch: %v", i, l)
+		}
+	}
+}
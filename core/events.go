@@ -19,6 +19,8 @@ package core
 import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
 )
 
 // TxPreEvent is posted when a transaction enters the transaction pool.
@@ -47,12 +49,33 @@ type RemovedTransactionEvent struct{ Txs types.Transactions }
 // RemovedLogsEvent is posted when a reorg happens
 type RemovedLogsEvent struct{ Logs []*types.Log }
 
+// ChainReorgEvent is posted when the canonical chain is reorganised. Both chain
+// segments are ordered from their head down to, but excluding, the common
+// ancestor. Dropped holds the transactions of the old segment missing from the
+// new one, which are returned to the pool, and Included those of the new segment
+// missing from the old one.
+type ChainReorgEvent struct {
+	Ancestor *types.Block
+	OldChain types.Blocks
+	NewChain types.Blocks
+	Dropped  types.Transactions
+	Included types.Transactions
+}
+
 type ChainEvent struct {
 	Block *types.Block
 	Hash  common.Hash
 	Logs  []*types.Log
 }
 
+// TradingEvent is posted when a canonical block carrying TomoX order fills or
+// lending trades has been imported.
+type TradingEvent struct {
+	Block         *types.Block
+	Trades        []*tradingstate.Trade
+	LendingTrades []*lendingstate.LendingTrade
+}
+
 type ChainSideEvent struct {
 	Block *types.Block
 }
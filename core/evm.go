@@ -34,6 +34,10 @@ func NewEVMContext(msg Message, header *types.Header, chain consensus.ChainConte
 	} else {
 		beneficiary = *author
 	}
+	var baseFee *big.Int
+	if header.BaseFee != nil {
+		baseFee = new(big.Int).Set(header.BaseFee)
+	}
 	return vm.Context{
 		CanTransfer: CanTransfer,
 		Transfer:    Transfer,
@@ -44,6 +48,7 @@ func NewEVMContext(msg Message, header *types.Header, chain consensus.ChainConte
 		Time:        new(big.Int).Set(header.Time),
 		Difficulty:  new(big.Int).Set(header.Difficulty),
 		GasLimit:    header.GasLimit,
+		BaseFee:     baseFee,
 		GasPrice:    new(big.Int).Set(msg.GasPrice()),
 	}
 }
@@ -55,6 +55,7 @@ type Genesis struct {
 	Mixhash    common.Hash         `json:"mixHash"`
 	Coinbase   common.Address      `json:"coinbase"`
 	Alloc      GenesisAlloc        `json:"alloc"      gencodec:"required"`
+	TomoX      *GenesisTomoX       `json:"tomox,omitempty"`
 
 	// These fields are used for consensus tests. Please don't use them
 	// in actual genesis blocks.
@@ -152,9 +153,58 @@ func (e *GenesisMismatchError) Error() string {
 //
 // The returned chain configuration is never nil.
 func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
+	return SetupGenesisBlockWithOverride(db, genesis, nil)
+}
+
+// ChainOverrides contains the fork blocks to move or schedule at startup, nil
+// fields keep the configured value.
+type ChainOverrides struct {
+	TIPTomoX        *big.Int // TomoX exchange fork block
+	TIPTomoXLending *big.Int // TomoX lending fork block
+	Berlin          *big.Int // Berlin fork block
+	London          *big.Int // London fork block
+}
+
+// apply returns a copy of the chain config with the overrides applied.
+func (o *ChainOverrides) apply(cfg *params.ChainConfig) *params.ChainConfig {
+	if o == nil || cfg == nil {
+		return cfg
+	}
+	cpy := *cfg
+	if o.TIPTomoX != nil {
+		cpy.TIPTomoXBlock = o.TIPTomoX
+	}
+	if o.TIPTomoXLending != nil {
+		cpy.TIPTomoXLendingBlock = o.TIPTomoXLending
+	}
+	if o.Berlin != nil {
+		cpy.BerlinBlock = o.Berlin
+	}
+	if o.London != nil {
+		cpy.LondonBlock = o.London
+	}
+	return &cpy
+}
+
+// empty returns whether no fork block is overridden.
+func (o *ChainOverrides) empty() bool {
+	return o == nil || (o.TIPTomoX == nil && o.TIPTomoXLending == nil && o.Berlin == nil && o.London == nil)
+}
+
+// SetupGenesisBlockWithOverride works like SetupGenesisBlock, additionally
+// moving the fork blocks given in overrides. The resulting chain config is
+// persisted, so the overrides remain in effect across restarts. Moving a fork
+// the chain has already passed yields a compatibility error like any other
+// config change.
+func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, overrides *ChainOverrides) (*params.ChainConfig, common.Hash, error) {
 	if genesis != nil && genesis.Config == nil {
 		return params.AllEthashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
+	if genesis != nil && genesis.Config.Posv != nil {
+		if err := genesis.Config.Posv.CheckEpochForks(); err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+	}
 
 	// Just commit the new block if there is no stored genesis block.
 	stored := GetCanonicalHash(db, 0)
@@ -165,6 +215,11 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 		} else {
 			log.Info("Writing custom genesis block")
 		}
+		if !overrides.empty() {
+			cpy := *genesis
+			cpy.Config = overrides.apply(genesis.Config)
+			genesis = &cpy
+		}
 		block, err := genesis.Commit(db)
 		return genesis.Config, block.Hash(), err
 	}
@@ -178,7 +233,7 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	}
 
 	// Get the existing chain configuration.
-	newcfg := genesis.configOrDefault(stored)
+	newcfg := overrides.apply(genesis.configOrDefault(stored))
 	storedcfg, err := GetChainConfig(db, stored)
 	if err != nil {
 		if err == ErrChainConfigNotFound {
@@ -192,7 +247,10 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	// config is supplied. These chains would get AllProtocolChanges (and a compat error)
 	// if we just continued here.
 	if genesis == nil && stored != params.VicMainnetGenesisHash && stored != params.VicTestnetGenesisHash {
-		return storedcfg, stored, nil
+		if overrides.empty() {
+			return storedcfg, stored, nil
+		}
+		newcfg = overrides.apply(storedcfg)
 	}
 
 	// Check config compatibility and write the config. Compatibility errors
@@ -240,6 +298,9 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 			statedb.SetState(addr, key, value)
 		}
 	}
+	if g.TomoX != nil {
+		g.TomoX.apply(statedb)
+	}
 	root := statedb.IntermediateRoot(false)
 	head := &types.Header{
 		Number:     new(big.Int).SetUint64(g.Number),
@@ -260,6 +321,9 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	if g.Difficulty == nil {
 		head.Difficulty = params.GenesisDifficulty
 	}
+	if g.Config != nil && g.Config.IsLondon(common.Big0) {
+		head.BaseFee = new(big.Int).SetUint64(params.InitialBaseFee)
+	}
 	statedb.Commit(false)
 	statedb.Database().TrieDB().Commit(root, true)
 
@@ -269,6 +333,11 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 // Commit writes the block and state of a genesis specification to the database.
 // The block is committed as the canonical head block.
 func (g *Genesis) Commit(db ethdb.Database) (*types.Block, error) {
+	if g.TomoX != nil {
+		if err := g.TomoX.validate(); err != nil {
+			return nil, err
+		}
+	}
 	block := g.ToBlock(db)
 	if block.Number().Sign() != 0 {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
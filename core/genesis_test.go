@@ -17,6 +17,7 @@
 package core
 
 import (
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
@@ -26,9 +27,12 @@ import (
 
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/vm"
 	"github.com/tomochain/tomochain/ethdb"
 	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
 )
 
 func TestDefaultGenesisBlock(t *testing.T) {
@@ -163,3 +167,146 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+// Tests that fork overrides are applied on top of the stored chain config and
+// persisted for later restarts.
+func TestSetupGenesisOverride(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := Genesis{Config: &params.ChainConfig{HomesteadBlock: big.NewInt(3)}}
+	hash := genesis.MustCommit(db).Hash()
+
+	overrides := &ChainOverrides{TIPTomoX: big.NewInt(100), London: big.NewInt(200)}
+	config, _, err := SetupGenesisBlockWithOverride(db, nil, overrides)
+	if err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if config.TIPTomoXBlock.Cmp(overrides.TIPTomoX) != 0 || config.LondonBlock.Cmp(overrides.London) != 0 {
+		t.Fatalf("overrides not applied: %v", config)
+	}
+	if config.HomesteadBlock.Cmp(big.NewInt(3)) != 0 || config.BerlinBlock != nil {
+		t.Fatalf("unrelated forks modified: %v", config)
+	}
+	if genesis.Config.LondonBlock != nil {
+		t.Fatalf("overrides modified the genesis config")
+	}
+	// A restart without overrides must keep the moved forks
+	stored, err := GetChainConfig(db, hash)
+	if err != nil {
+		t.Fatalf("failed to read chain config: %v", err)
+	}
+	if !reflect.DeepEqual(stored, config) {
+		t.Fatalf("stored config mismatch:\nhave %v\nwant %v", stored, config)
+	}
+	if config, _, _ = SetupGenesisBlock(db, nil); config.LondonBlock == nil || config.LondonBlock.Cmp(overrides.London) != 0 {
+		t.Fatalf("overrides lost on restart: %v", config)
+	}
+}
+
+// Tests that the TomoX section of a genesis file is written into the relayer
+// registration and lending contracts, as TomoX reads them.
+func TestGenesisTomoX(t *testing.T) {
+	var (
+		coinbase = common.HexToAddress("0x0000000000000000000000000000000000000c01")
+		owner    = common.HexToAddress("0x0000000000000000000000000000000000000c02")
+		btc      = common.HexToAddress("0x0000000000000000000000000000000000000b01")
+		usdt     = common.HexToAddress("0x0000000000000000000000000000000000000b02")
+		tomo     = common.HexToAddress(common.TomoNativeAddress)
+	)
+	spec := `{
+		"gasLimit": "0x47b760",
+		"difficulty": "0x1",
+		"alloc": {},
+		"tomox": {
+			"relayers": [{
+				"coinbase": "` + coinbase.Hex() + `",
+				"owner": "` + owner.Hex() + `",
+				"deposit": 25000000000000000000000,
+				"tradeFee": 10,
+				"pairs": [
+					{"baseToken": "` + btc.Hex() + `", "quoteToken": "` + tomo.Hex() + `"},
+					{"baseToken": "` + tomo.Hex() + `", "quoteToken": "` + usdt.Hex() + `"}
+				],
+				"lendingFee": 100,
+				"lendingBooks": [
+					{"lendingToken": "` + usdt.Hex() + `", "term": 86400},
+					{"lendingToken": "` + usdt.Hex() + `", "term": 604800}
+				]
+			}],
+			"collaterals": [{
+				"token": "` + btc.Hex() + `",
+				"depositRate": 150,
+				"liquidationRate": 110,
+				"recallRate": 200,
+				"prices": {"` + usdt.Hex() + `": 8000000000000000000000}
+			}]
+		}
+	}`
+	genesis := new(Genesis)
+	if err := json.Unmarshal([]byte(spec), genesis); err != nil {
+		t.Fatalf("failed to decode genesis: %v", err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	block, err := genesis.Commit(db)
+	if err != nil {
+		t.Fatalf("failed to commit genesis: %v", err)
+	}
+	statedb, err := state.New(block.Root(), state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open genesis state: %v", err)
+	}
+
+	pairs, err := tradingstate.GetAllTradingPairs(statedb)
+	if err != nil {
+		t.Fatalf("failed to get trading pairs: %v", err)
+	}
+	wantPairs := map[common.Hash]bool{
+		tradingstate.GetTradingOrderBookHash(btc, tomo):  true,
+		tradingstate.GetTradingOrderBookHash(tomo, usdt): true,
+	}
+	if !reflect.DeepEqual(pairs, wantPairs) {
+		t.Errorf("trading pairs mismatch: have %v, want %v", pairs, wantPairs)
+	}
+	if fee := tradingstate.GetExRelayerFee(coinbase, statedb); fee.Uint64() != 10 {
+		t.Errorf("relayer fee mismatch: have %v, want 10", fee)
+	}
+	if have := tradingstate.GetRelayerOwner(coinbase, statedb); have != owner {
+		t.Errorf("relayer owner mismatch: have %x, want %x", have, owner)
+	}
+	if balance := statedb.GetBalance(common.HexToAddress(common.RelayerRegistrationSMC)); balance.Cmp(genesis.TomoX.Relayers[0].Deposit) != 0 {
+		t.Errorf("locked deposit mismatch: have %v, want %v", balance, genesis.TomoX.Relayers[0].Deposit)
+	}
+
+	if !lendingstate.IsValidRelayer(statedb, coinbase) {
+		t.Errorf("genesis relayer not a valid lending relayer")
+	}
+	books, err := lendingstate.GetAllLendingBooks(statedb)
+	if err != nil {
+		t.Fatalf("failed to get lending books: %v", err)
+	}
+	wantBooks := map[common.Hash]bool{
+		lendingstate.GetLendingOrderBookHash(usdt, 86400):  true,
+		lendingstate.GetLendingOrderBookHash(usdt, 604800): true,
+	}
+	if !reflect.DeepEqual(books, wantBooks) {
+		t.Errorf("lending books mismatch: have %v, want %v", books, wantBooks)
+	}
+	if fee := lendingstate.GetFee(statedb, coinbase); fee.Uint64() != 100 {
+		t.Errorf("lending fee mismatch: have %v, want 100", fee)
+	}
+	if collaterals, _ := lendingstate.GetCollaterals(statedb, coinbase, usdt, 604800); !reflect.DeepEqual(collaterals, []common.Address{btc}) {
+		t.Errorf("collaterals mismatch: have %x, want [%x]", collaterals, btc)
+	}
+	depositRate, liquidationRate, recallRate := lendingstate.GetCollateralDetail(statedb, btc)
+	if depositRate.Uint64() != 150 || liquidationRate.Uint64() != 110 || recallRate.Uint64() != 200 {
+		t.Errorf("collateral rates mismatch: have %v/%v/%v, want 150/110/200", depositRate, liquidationRate, recallRate)
+	}
+	if price, _ := lendingstate.GetCollateralPrice(statedb, btc, usdt); price.Cmp(genesis.TomoX.Collaterals[0].Prices[usdt]) != 0 {
+		t.Errorf("collateral price mismatch: have %v, want %v", price, genesis.TomoX.Collaterals[0].Prices[usdt])
+	}
+
+	// Relayers which TomoX would reject must fail the genesis commit
+	genesis.TomoX.Relayers[0].Deposit = new(big.Int).Mul(common.BasePrice, common.RelayerLockedFund)
+	if _, err := genesis.Commit(rawdb.NewMemoryDatabase()); err == nil {
+		t.Errorf("genesis with an underfunded relayer committed")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// GenesisTomoX is the initial configuration of TomoX and TomoX lending. It's
+// written into the storage of the relayer registration and lending contracts of
+// the genesis state, so that a network can trade and lend from its first block
+// without sending the contract setup transactions.
+type GenesisTomoX struct {
+	Relayers    []GenesisRelayer    `json:"relayers,omitempty"`
+	Collaterals []GenesisCollateral `json:"collaterals,omitempty"`
+}
+
+// GenesisRelayer is a relayer registered in the genesis state.
+type GenesisRelayer struct {
+	Coinbase     common.Address       `json:"coinbase"`
+	Owner        common.Address       `json:"owner"`
+	Deposit      *big.Int             `json:"deposit"`
+	TradeFee     uint64               `json:"tradeFee,omitempty"`
+	Pairs        []GenesisTradingPair `json:"pairs,omitempty"`
+	LendingFee   uint64               `json:"lendingFee,omitempty"`
+	LendingBooks []GenesisLendingBook `json:"lendingBooks,omitempty"`
+}
+
+// GenesisTradingPair is a TomoX trading pair served by a genesis relayer.
+type GenesisTradingPair struct {
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+}
+
+// GenesisLendingBook is a lending book served by a genesis relayer.
+type GenesisLendingBook struct {
+	LendingToken common.Address `json:"lendingToken"`
+	Term         uint64         `json:"term"`
+}
+
+// GenesisCollateral is a default lending collateral token, along with its
+// initial prices keyed by lending token.
+type GenesisCollateral struct {
+	Token           common.Address              `json:"token"`
+	DepositRate     uint64                      `json:"depositRate"`
+	LiquidationRate uint64                      `json:"liquidationRate"`
+	RecallRate      uint64                      `json:"recallRate"`
+	Prices          map[common.Address]*big.Int `json:"prices,omitempty"`
+}
+
+// validate checks that the configured relayers would be accepted by TomoX and
+// TomoX lending once written into the genesis state.
+func (g *GenesisTomoX) validate() error {
+	lockedFund := new(big.Int).Mul(common.BasePrice, common.RelayerLockedFund)
+	coinbases := make(map[common.Address]bool)
+	for _, relayer := range g.Relayers {
+		if relayer.Coinbase == (common.Address{}) {
+			return fmt.Errorf("genesis relayer has no coinbase")
+		}
+		if coinbases[relayer.Coinbase] {
+			return fmt.Errorf("duplicate genesis relayer %x", relayer.Coinbase)
+		}
+		coinbases[relayer.Coinbase] = true
+		if relayer.Deposit == nil || relayer.Deposit.Cmp(lockedFund) <= 0 {
+			return fmt.Errorf("genesis relayer %x deposit %v must exceed %v", relayer.Coinbase, relayer.Deposit, lockedFund)
+		}
+		for _, book := range relayer.LendingBooks {
+			if book.LendingToken == (common.Address{}) || book.Term == 0 {
+				return fmt.Errorf("genesis relayer %x has an invalid lending book %x/%d", relayer.Coinbase, book.LendingToken, book.Term)
+			}
+		}
+	}
+	for _, collateral := range g.Collaterals {
+		if collateral.Token == (common.Address{}) {
+			return fmt.Errorf("genesis collateral has no token")
+		}
+	}
+	return nil
+}
+
+// apply writes the configuration into the relayer registration and lending
+// contract storage of the genesis state.
+func (g *GenesisTomoX) apply(statedb *state.StateDB) {
+	var (
+		baseTokens []common.Address
+		terms      []uint64
+		seenBases  = make(map[common.Address]bool)
+		seenTerms  = make(map[uint64]bool)
+	)
+	for _, relayer := range g.Relayers {
+		fromTokens := make([]common.Address, len(relayer.Pairs))
+		toTokens := make([]common.Address, len(relayer.Pairs))
+		for i, pair := range relayer.Pairs {
+			fromTokens[i], toTokens[i] = pair.BaseToken, pair.QuoteToken
+		}
+		tradingstate.RegisterRelayer(statedb, relayer.Coinbase, relayer.Owner, relayer.Deposit, new(big.Int).SetUint64(relayer.TradeFee), fromTokens, toTokens)
+
+		if len(relayer.LendingBooks) == 0 {
+			continue
+		}
+		bases := make([]common.Address, len(relayer.LendingBooks))
+		books := make([]uint64, len(relayer.LendingBooks))
+		for i, book := range relayer.LendingBooks {
+			bases[i], books[i] = book.LendingToken, book.Term
+			if !seenBases[book.LendingToken] {
+				seenBases[book.LendingToken] = true
+				baseTokens = append(baseTokens, book.LendingToken)
+			}
+			if !seenTerms[book.Term] {
+				seenTerms[book.Term] = true
+				terms = append(terms, book.Term)
+			}
+		}
+		lendingstate.RegisterLendingRelayer(statedb, relayer.Coinbase, new(big.Int).SetUint64(relayer.LendingFee), bases, books)
+	}
+	if len(baseTokens) > 0 {
+		lendingstate.SetSupportedLending(statedb, baseTokens, terms)
+	}
+	for _, collateral := range g.Collaterals {
+		lendingstate.AddCollateral(statedb, collateral.Token,
+			new(big.Int).SetUint64(collateral.DepositRate),
+			new(big.Int).SetUint64(collateral.LiquidationRate),
+			new(big.Int).SetUint64(collateral.RecallRate))
+		for lendingToken, price := range collateral.Prices {
+			lendingstate.SetCollateralPrice(statedb, collateral.Token, lendingToken, price, common.Big0)
+		}
+	}
+}
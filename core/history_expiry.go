@@ -0,0 +1,130 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/params"
+)
+
+const (
+	historyExpiryInterval = time.Minute // Interval between two rounds of history pruning
+	historyExpiryBatch    = 1024        // Number of blocks pruned per database batch
+)
+
+// historyExpiryMargin is the number of recent blocks whose history is always
+// kept, covering the deepest reorg the downloader accepts.
+var historyExpiryMargin = 3 * params.EpochDuration
+
+// HistoryTail returns the number of the oldest block whose body and receipts
+// are kept, apart from the genesis block. The history below it was pruned.
+func (bc *BlockChain) HistoryTail() uint64 {
+	return GetHistoryTail(bc.db)
+}
+
+// HistoryPruned reports whether the body and receipts of the block with the
+// given number were deleted by history expiry.
+func (bc *BlockChain) HistoryPruned(number uint64) bool {
+	return number > 0 && number < bc.HistoryTail()
+}
+
+// expireHistoryLoop periodically prunes the history below the configured block
+// as the chain progresses past it, until the blockchain is stopped.
+func (bc *BlockChain) expireHistoryLoop() {
+	ticker := time.NewTicker(historyExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		bc.expireHistory()
+		select {
+		case <-ticker.C:
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// expireHistory deletes the bodies, receipts and transaction lookups of the
+// canonical blocks below the configured history expiry block, keeping their
+// headers. The most recent blocks are kept regardless, so that reorgs can
+// still be processed.
+func (bc *BlockChain) expireHistory() {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	head := bc.CurrentBlock().NumberU64()
+	if head <= historyExpiryMargin {
+		return
+	}
+	limit := bc.cacheConfig.HistoryExpiry
+	if limit > head-historyExpiryMargin {
+		limit = head - historyExpiryMargin
+	}
+	tail := GetHistoryTail(bc.db)
+	if tail == 0 {
+		tail = 1 // The genesis block is never pruned
+	}
+	if tail >= limit {
+		return
+	}
+	var (
+		start  = time.Now()
+		from   = tail
+		txs    int
+		logged = time.Now()
+	)
+	for tail < limit {
+		batch := bc.db.NewBatch()
+		for end := tail + historyExpiryBatch; tail < limit && tail < end; tail++ {
+			hash := GetCanonicalHash(bc.db, tail)
+			if hash == (common.Hash{}) {
+				continue
+			}
+			if body := GetBody(bc.db, hash, tail); body != nil {
+				for _, tx := range body.Transactions {
+					DeleteTxLookupEntry(batch, tx.Hash())
+				}
+				txs += len(body.Transactions)
+			}
+			DeleteBody(batch, hash, tail)
+			DeleteBlockReceipts(batch, hash, tail)
+		}
+		WriteHistoryTail(batch, tail)
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to prune history", "number", tail, "err", err)
+			return
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning history", "number", tail, "limit", limit, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		select {
+		case <-bc.quit:
+			return
+		default:
+		}
+	}
+	// Drop the cached blocks which may hold pruned data
+	bc.bodyCache.Purge()
+	bc.bodyRLPCache.Purge()
+	bc.blockCache.Purge()
+
+	log.Info("Pruned history", "from", from, "to", tail, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+}
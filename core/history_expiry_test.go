@@ -0,0 +1,94 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that history expiry deletes the bodies, receipts and transaction lookups
+// below the configured block, keeps the headers and the recent blocks.
+func TestExpireHistory(t *testing.T) {
+	defer func(margin uint64) { historyExpiryMargin = margin }(historyExpiryMargin)
+	historyExpiryMargin = 16
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 64, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	// Expire below a block older than the retained margin
+	chain.cacheConfig.HistoryExpiry = 40
+	chain.expireHistory()
+
+	if tail := chain.HistoryTail(); tail != 40 {
+		t.Fatalf("history tail mismatch: have %d, want 40", tail)
+	}
+	for _, block := range blocks {
+		number, hash := block.NumberU64(), block.Hash()
+		pruned := number < 40
+		if chain.HistoryPruned(number) != pruned {
+			t.Errorf("block %d: pruned mismatch: have %v, want %v", number, !pruned, pruned)
+		}
+		if chain.GetHeaderByNumber(number) == nil {
+			t.Errorf("block %d: header missing", number)
+		}
+		if (GetBody(db, hash, number) == nil) != pruned {
+			t.Errorf("block %d: body presence mismatch, pruned %v", number, pruned)
+		}
+		if (GetBlockReceipts(db, hash, number, gspec.Config) == nil) != pruned {
+			t.Errorf("block %d: receipts presence mismatch, pruned %v", number, pruned)
+		}
+		if lookup, _, _ := GetTxLookupEntry(db, block.Transactions()[0].Hash()); (lookup == (common.Hash{})) != pruned {
+			t.Errorf("block %d: tx lookup presence mismatch, pruned %v", number, pruned)
+		}
+	}
+	if chain.GetBlockByNumber(0) == nil {
+		t.Errorf("genesis block pruned")
+	}
+	// A later expiry never reaches into the retained margin
+	chain.cacheConfig.HistoryExpiry = 60
+	chain.expireHistory()
+	if tail := chain.HistoryTail(); tail != 48 {
+		t.Fatalf("history tail mismatch: have %d, want 48", tail)
+	}
+}
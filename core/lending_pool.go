@@ -24,7 +24,7 @@ import (
 	"sync"
 	"time"
 
-	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/consensus/exchange"
 
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
@@ -50,6 +50,11 @@ var (
 	ErrInvalidCancelledLending   = errors.New("invalid cancel lending id")
 	ErrInvalidLendingTradeID     = errors.New("invalid lending trade ID")
 	ErrInvalidLendingCollateral  = errors.New("invalid collateral")
+	ErrPartialRepayNotActive     = errors.New("partial repayment not active")
+	ErrAutoRenewNotActive        = errors.New("auto-renewal not active")
+	ErrInvalidAutoRenew          = errors.New("auto-renewal only applies to borrowings")
+	ErrDutchAuctionNotActive     = errors.New("collateral auctions not active")
+	ErrNoLendingAuction          = errors.New("lending trade collateral not in auction")
 )
 
 var (
@@ -273,7 +278,7 @@ func (pool *LendingPool) loop() {
 // reset retrieves the current state of the blockchain and ensures the content
 // of the transaction pool is valid with regard to the chain state.
 func (pool *LendingPool) reset(oldHead, newblock *types.Block) {
-	if !pool.chainconfig.IsTIPTomoX(pool.chain.CurrentBlock().Number()) || pool.chain.Config().Posv == nil || pool.chain.CurrentBlock().NumberU64() <= pool.chain.Config().Posv.Epoch {
+	if !pool.chainconfig.IsTomoXEnabled(pool.chain.CurrentBlock().Number()) {
 		return
 	}
 	// If we're reorging an old state, reinject all dropped transactions
@@ -430,6 +435,15 @@ func (pool *LendingPool) validateNewLending(cloneStateDb *state.StateDB, cloneLe
 	if lendingType != LendingTypeLimit && lendingType != LendingTypeMarket {
 		return ErrInvalidLendingType
 	}
+	if tx.AutoRenewRate() != 0 {
+		next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+		if !pool.chainconfig.IsTIPTomoXAutoRenew(next) {
+			return ErrAutoRenewNotActive
+		}
+		if lendingSide != lendingstate.Borrowing {
+			return ErrInvalidAutoRenew
+		}
+	}
 	if tx.Side() == lendingstate.Borrowing {
 		if tx.CollateralToken().String() == lendingstate.EmptyAddress || tx.CollateralToken().String() == tx.LendingToken().String() {
 			return ErrInvalidLendingCollateral
@@ -473,6 +487,15 @@ func (pool *LendingPool) validateRepayLending(cloneStateDb *state.StateDB, clone
 	if tx.LendingTradeId() == 0 {
 		return ErrInvalidLendingTradeID
 	}
+	if tx.Type() == types.LendingPartialRepay {
+		next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+		if !pool.chainconfig.IsTIPTomoXPartialRepay(next) {
+			return ErrPartialRepayNotActive
+		}
+		if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+			return ErrInvalidLendingQuantity
+		}
+	}
 	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
 	lendingTrade := cloneLendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(tx.LendingTradeId()))
 	if lendingTrade == lendingstate.EmptyLendingTrade {
@@ -513,8 +536,33 @@ func (pool *LendingPool) validateTopupLending(cloneStateDb *state.StateDB, clone
 	return nil
 }
 
+func (pool *LendingPool) validateAuctionBidLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if !pool.chainconfig.IsTIPTomoXDutchAuction(next) {
+		return ErrDutchAuctionNotActive
+	}
+	if tx.LendingTradeId() == 0 {
+		return ErrInvalidLendingTradeID
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
+	lendingTrade := cloneLendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(tx.LendingTradeId()))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return ErrInvalidLendingTradeID
+	}
+	if lendingTrade.Auction == nil {
+		return ErrNoLendingAuction
+	}
+	if err := pool.validateBalance(cloneStateDb, cloneLendingStateDb, tx, lendingTrade.CollateralToken); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (pool *LendingPool) validateBalance(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction, collateralToken common.Address) error {
-	posvEngine, ok := pool.chain.Engine().(*posv.Posv)
+	posvEngine, ok := pool.chain.Engine().(exchange.Engine)
 	if !ok {
 		return ErrNotPoSV
 	}
@@ -615,6 +663,9 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 	if tx.IsRepayLending() {
 		return pool.validateRepayLending(cloneStateDb, cloneLendingStateDb, tx)
 	}
+	if tx.IsAuctionBidLending() {
+		return pool.validateAuctionBidLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
 
 	return ErrInvalidLendingStatus
 }
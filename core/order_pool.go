@@ -25,7 +25,7 @@ import (
 	"time"
 
 	"github.com/tomochain/tomochain/consensus"
-	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/consensus/exchange"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 
 	"github.com/tomochain/tomochain/common"
@@ -49,15 +49,31 @@ var (
 	ErrInvalidOrderPrice       = errors.New("invalid order price")
 	ErrInvalidOrderHash        = errors.New("invalid order hash")
 	ErrInvalidCancelledOrder   = errors.New("invalid cancel orderid")
+	ErrBatchCancelNotActive    = errors.New("batch cancellation not active")
+	ErrStopOrderNotActive      = errors.New("stop orders not active")
+	ErrInvalidTriggerPrice     = errors.New("invalid order trigger price")
+	ErrIcebergOrderNotActive   = errors.New("iceberg orders not active")
+	ErrInvalidDisplayQuantity  = errors.New("invalid order display quantity")
+	ErrPostOnlyNotActive       = errors.New("post-only orders not active")
+	ErrInvalidTimeInForce      = errors.New("invalid order time in force")
+	ErrTimeInForceNotActive    = errors.New("immediate-or-cancel and fill-or-kill orders not active")
+	ErrOrderExpiryNotActive    = errors.New("good-till-date orders not active")
+	ErrInvalidExpiry           = errors.New("invalid order expiry time")
+	ErrSelfTradeNotActive      = errors.New("self-trade prevention not active")
+	ErrInvalidSelfTrade        = errors.New("invalid order self-trade prevention")
+	ErrReplaceOrderNotActive   = errors.New("order replacement not active")
+	ErrInvalidReplace          = errors.New("invalid order replacement")
 )
 
 var (
-	OrderTypeLimit    = "LO"
-	OrderTypeMarket   = "MO"
-	OrderStatusNew    = "NEW"
-	OrderStatusCancle = "CANCELLED"
-	OrderSideBid      = "BUY"
-	OrderSideAsk      = "SELL"
+	OrderTypeLimit      = "LO"
+	OrderTypeMarket     = "MO"
+	OrderTypeStopLimit  = "SLO"
+	OrderTypeStopMarket = "SMO"
+	OrderStatusNew      = "NEW"
+	OrderStatusCancle   = "CANCELLED"
+	OrderSideBid        = "BUY"
+	OrderSideAsk        = "SELL"
 )
 
 var (
@@ -278,7 +294,7 @@ func (pool *OrderPool) loop() {
 // reset retrieves the current state of the blockchain and ensures the content
 // of the transaction pool is valid with regard to the chain state.
 func (pool *OrderPool) reset(oldHead, newblock *types.Block) {
-	if !pool.chainconfig.IsTIPTomoX(pool.chain.CurrentBlock().Number()) || pool.chain.Config().Posv == nil || pool.chain.CurrentBlock().NumberU64() <= pool.chain.Config().Posv.Epoch {
+	if !pool.chainconfig.IsTomoXEnabled(pool.chain.CurrentBlock().Number()) {
 		return
 	}
 	// If we're reorging an old state, reinject all dropped transactions
@@ -446,7 +462,7 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if quantity == nil || quantity.Cmp(big.NewInt(0)) <= 0 {
 			return ErrInvalidOrderQuantity
 		}
-		if orderType != OrderTypeMarket {
+		if orderType != OrderTypeMarket && orderType != OrderTypeStopMarket {
 			if price == nil || price.Cmp(big.NewInt(0)) <= 0 {
 				return ErrInvalidOrderPrice
 			}
@@ -455,15 +471,46 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if orderSide != OrderSideAsk && orderSide != OrderSideBid {
 			return ErrInvalidOrderSide
 		}
-		if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
+		if tx.IsStopOrder() {
+			if err := pool.validateStopOrder(tx); err != nil {
+				return err
+			}
+		} else if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
 			return ErrInvalidOrderType
+		} else if tx.TriggerPrice() != nil {
+			return ErrInvalidTriggerPrice
+		}
+		if tx.DisplayQuantity() != nil {
+			if err := pool.validateIcebergOrder(tx); err != nil {
+				return err
+			}
+		}
+		if tx.TimeInForce() != "" {
+			if err := pool.validateTimeInForce(tx); err != nil {
+				return err
+			}
+		}
+		if tx.ExpiresAt() != 0 {
+			if err := pool.validateExpiry(tx); err != nil {
+				return err
+			}
+		}
+		if tx.SelfTradePrevention() != "" {
+			if err := pool.validateSelfTradePrevention(tx); err != nil {
+				return err
+			}
+		}
+		if tx.ReplacedOrderID() != 0 {
+			if err := pool.validateReplace(tx); err != nil {
+				return err
+			}
 		}
 		if err := tradingstate.VerifyPair(cloneStateDb, tx.ExchangeAddress(), tx.BaseToken(), tx.QuoteToken()); err != nil {
 			return err
 		}
 
-		if orderType == OrderTypeLimit {
-			posvEngine, ok := pool.chain.Engine().(*posv.Posv)
+		if orderType == OrderTypeLimit || orderType == OrderTypeStopLimit {
+			posvEngine, ok := pool.chain.Engine().(exchange.Engine)
 			if !ok {
 				return ErrNotPoSV
 			}
@@ -500,12 +547,16 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 			tx.SetOrderHash(signer.Hash(tx))
 		}
 
+	} else if tx.IsBatchCancel() {
+		if err := pool.validateBatchCancel(tx, cloneTomoXStateDb); err != nil {
+			return err
+		}
 	} else {
 		if tx.OrderID() == 0 {
 			return ErrInvalidCancelledOrder
 		}
 		originOrder := cloneTomoXStateDb.GetOrder(tradingstate.GetTradingOrderBookHash(tx.BaseToken(), tx.QuoteToken()), common.BigToHash(new(big.Int).SetUint64(tx.OrderID())))
-		if originOrder == tradingstate.EmptyOrder {
+		if tradingstate.IsEmptyOrder(originOrder) {
 			log.Debug("Order not found ", "OrderId", tx.OrderID(), "BaseToken", tx.BaseToken().Hex(), "QuoteToken", tx.QuoteToken().Hex())
 			return ErrInvalidCancelledOrder
 		}
@@ -527,6 +578,121 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 	return nil
 }
 
+// validateBatchCancel checks that a batch cancellation is accepted by the next
+// block and lists existing orders of the pair, each of them once.
+func (pool *OrderPool) validateBatchCancel(tx *types.OrderTransaction, tomoXStateDb *tradingstate.TradingStateDB) error {
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if !pool.chainconfig.IsTIPTomoXBatchCancel(next) {
+		return ErrBatchCancelNotActive
+	}
+	if tx.OrderID() != 0 || len(tx.Cancels()) > common.MaxOrderCancels {
+		return ErrInvalidCancelledOrder
+	}
+	orderBook := tradingstate.GetTradingOrderBookHash(tx.BaseToken(), tx.QuoteToken())
+	seen := make(map[uint64]struct{}, len(tx.Cancels()))
+	for _, cancel := range tx.Cancels() {
+		if _, ok := seen[cancel.OrderID]; ok || cancel.OrderID == 0 {
+			return ErrInvalidCancelledOrder
+		}
+		seen[cancel.OrderID] = struct{}{}
+
+		originOrder := tomoXStateDb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(cancel.OrderID)))
+		if tradingstate.IsEmptyOrder(originOrder) {
+			log.Debug("Order not found ", "OrderId", cancel.OrderID, "BaseToken", tx.BaseToken().Hex(), "QuoteToken", tx.QuoteToken().Hex())
+			return ErrInvalidCancelledOrder
+		}
+		if originOrder.Hash != cancel.Hash {
+			log.Debug("Invalid order hash", "expected", originOrder.Hash.Hex(), "got", cancel.Hash.Hex())
+			return ErrInvalidOrderHash
+		}
+	}
+	return nil
+}
+
+// validateStopOrder checks that a stop order is accepted by the next block and
+// has a valid trigger price.
+func (pool *OrderPool) validateStopOrder(tx *types.OrderTransaction) error {
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if !pool.chainconfig.IsTIPTomoXStopOrder(next) {
+		return ErrStopOrderNotActive
+	}
+	trigger := tx.TriggerPrice()
+	if trigger == nil || trigger.Sign() <= 0 || common.BigToHash(trigger).Big().Cmp(trigger) != 0 {
+		return ErrInvalidTriggerPrice
+	}
+	return nil
+}
+
+// validateIcebergOrder checks that an iceberg order is accepted by the next block
+// and is a limit order showing a part of its quantity.
+func (pool *OrderPool) validateIcebergOrder(tx *types.OrderTransaction) error {
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if !pool.chainconfig.IsTIPTomoXIcebergOrder(next) {
+		return ErrIcebergOrderNotActive
+	}
+	display := tx.DisplayQuantity()
+	if tx.Type() != OrderTypeLimit || display.Sign() <= 0 || display.Cmp(tx.Quantity()) >= 0 {
+		return ErrInvalidDisplayQuantity
+	}
+	return nil
+}
+
+// validateTimeInForce checks that a post-only, immediate-or-cancel or
+// fill-or-kill order is accepted by the next block and is a limit order.
+func (pool *OrderPool) validateTimeInForce(tx *types.OrderTransaction) error {
+	if tx.Type() != OrderTypeLimit || (!tx.IsPostOnly() && !tx.IsImmediate()) {
+		return ErrInvalidTimeInForce
+	}
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if tx.IsPostOnly() && !pool.chainconfig.IsTIPTomoXPostOnly(next) {
+		return ErrPostOnlyNotActive
+	}
+	if tx.IsImmediate() && !pool.chainconfig.IsTIPTomoXTimeInForce(next) {
+		return ErrTimeInForceNotActive
+	}
+	return nil
+}
+
+// validateExpiry checks that a good-till-date order is accepted by the next
+// block, rests in the order book and isn't expired yet.
+func (pool *OrderPool) validateExpiry(tx *types.OrderTransaction) error {
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if !pool.chainconfig.IsTIPTomoXOrderExpiry(next) {
+		return ErrOrderExpiryNotActive
+	}
+	if tx.Type() == OrderTypeMarket || tx.IsImmediate() || tx.ExpiresAt() <= pool.chain.CurrentBlock().Time().Uint64() {
+		return ErrInvalidExpiry
+	}
+	return nil
+}
+
+// validateSelfTradePrevention checks that an order preventing self-trades is
+// accepted by the next block and names a known mode.
+func (pool *OrderPool) validateSelfTradePrevention(tx *types.OrderTransaction) error {
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if !pool.chainconfig.IsTIPTomoXSelfTrade(next) {
+		return ErrSelfTradeNotActive
+	}
+	switch tx.SelfTradePrevention() {
+	case types.OrderSelfTradeCancelNewest, types.OrderSelfTradeCancelOldest, types.OrderSelfTradeCancelBoth:
+		return nil
+	}
+	return ErrInvalidSelfTrade
+}
+
+// validateReplace checks that an order replacing a resting order is accepted
+// by the next block and is a limit order.
+func (pool *OrderPool) validateReplace(tx *types.OrderTransaction) error {
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if !pool.chainconfig.IsTIPTomoXReplaceOrder(next) {
+		return ErrReplaceOrderNotActive
+	}
+	if tx.Type() != OrderTypeLimit {
+		return ErrInvalidReplace
+	}
+	return nil
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *OrderPool) validateTx(tx *types.OrderTransaction, local bool) error {
@@ -0,0 +1,101 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that only the owner of a candidate registers its signing key, and that
+// a key of another candidate is refused.
+func TestApplyRotateSignerTransaction(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	validator := common.HexToAddress(common.MasternodeVotingSMC)
+
+	ownerKey, _ := crypto.GenerateKey()
+	owner := crypto.PubkeyToAddress(ownerKey.PublicKey)
+	candidates := []common.Address{{0x01}, {0x02}}
+	slot := state.GetLocSimpleVariable(3)
+	for i, candidate := range candidates {
+		statedb.SetState(validator, state.GetLocDynamicArrAtElement(slot, uint64(i), 1), candidate.Hash())
+		loc := state.GetLocMappingAtKey(candidate.Hash(), 1)
+		statedb.SetState(validator, state.GetLocOfStructElement(loc, big.NewInt(0)), owner.Hash())
+	}
+	statedb.SetState(validator, slot, common.BigToHash(big.NewInt(int64(len(candidates)))))
+
+	config := &params.ChainConfig{ChainId: big.NewInt(88), TIPKeyRotationBlock: big.NewInt(0)}
+	header := &types.Header{Number: big.NewInt(1)}
+	signer := types.MakeSigner(config, header.Number)
+
+	tests := []struct {
+		from      bool // whether the owner sends the transaction
+		candidate common.Address
+		key       common.Address
+		failed    bool
+		want      common.Address
+	}{
+		{true, candidates[0], common.Address{0xa1}, false, common.Address{0xa1}},
+		{false, candidates[0], common.Address{0xa2}, true, common.Address{0xa1}}, // not the owner
+		{true, candidates[0], candidates[1], true, common.Address{0xa1}},         // another candidate
+		{true, candidates[1], common.Address{0xa1}, true, common.Address{}},      // key of another candidate
+		{true, candidates[0], candidates[0], false, candidates[0]},               // back to its own key
+		{true, candidates[1], common.Address{0xa1}, false, common.Address{0xa1}}, // key released
+	}
+	nonces := make(map[common.Address]uint64)
+	for i, tt := range tests {
+		key := ownerKey
+		if !tt.from {
+			key, _ = crypto.GenerateKey()
+		}
+		from := crypto.PubkeyToAddress(key.PublicKey)
+		data := append(common.FromHex(common.RotateSignerMethod), common.LeftPadBytes(tt.candidate.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(tt.key.Bytes(), 32)...)
+		tx, err := types.SignTx(types.NewTransaction(nonces[from], validator, big.NewInt(0), 100000, big.NewInt(0), data), signer, key)
+		if err != nil {
+			t.Fatalf("test %d: failed to sign: %v", i, err)
+		}
+		if !tx.IsRotateSignerTransaction() {
+			t.Fatalf("test %d: not a rotation transaction", i)
+		}
+		receipt, gas, err, _ := ApplyTransaction(config, nil, nil, nil, new(GasPool).AddGas(1000000), statedb, nil, header, tx, new(uint64), vm.Config{})
+		if err != nil {
+			t.Fatalf("test %d: failed to apply: %v", i, err)
+		}
+		nonces[from]++
+		if gas != 0 {
+			t.Errorf("test %d: gas mismatch: have %d, want 0", i, gas)
+		}
+		if failed := receipt.Status == types.ReceiptStatusFailed; failed != tt.failed {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, failed, tt.failed)
+		}
+		if nonce := statedb.GetNonce(from); nonce != nonces[from] {
+			t.Errorf("test %d: nonce mismatch: have %d, want %d", i, nonce, nonces[from])
+		}
+		if have := state.GetCandidateSigner(statedb, tt.candidate); have != tt.want {
+			t.Errorf("test %d: signing key mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
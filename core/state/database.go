@@ -107,9 +107,15 @@ func NewDatabase(db ethdb.Database) Database {
 // is safe for concurrent use and retains a lot of collapsed RLP trie nodes in a
 // large memory cache.
 func NewDatabaseWithCache(db ethdb.Database, cache int) Database {
+	return NewDatabaseWithConfig(db, &trie.Config{Cache: cache})
+}
+
+// NewDatabaseWithConfig creates a backing store for state with the given trie
+// database options.
+func NewDatabaseWithConfig(db ethdb.Database, config *trie.Config) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
 	return &cachingDB{
-		db:            trie.NewDatabaseWithCache(db, cache),
+		db:            trie.NewDatabaseWithConfig(db, config),
 		codeSizeCache: csc,
 	}
 }
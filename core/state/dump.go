@@ -39,36 +39,151 @@ type Dump struct {
 	Accounts map[string]DumpAccount `json:"accounts"`
 }
 
+// IteratorDump is a chunk of a state dump, as returned by StateDB.IteratorDump.
+// Accounts are keyed by address, or by their hashed trie key if the address
+// preimage is not known.
+type IteratorDump struct {
+	Root     string                 `json:"root"`
+	Accounts map[string]DumpAccount `json:"accounts"`
+	NextKey  *common.Hash           `json:"nextKey"` // nil if Accounts includes the last account in the trie.
+}
+
+// DumpIterator walks the accounts of a state in trie order, that is by hashed
+// address, resolving their code and storage unless excluded.
+type DumpIterator struct {
+	state          *StateDB
+	it             *trie.Iterator
+	excludeCode    bool
+	excludeStorage bool
+
+	key     common.Hash
+	addr    *common.Address
+	account DumpAccount
+	err     error
+}
+
+// NewDumpIterator creates an iterator over the accounts of the state, starting
+// at the given hashed address. The key of any account can be used as the start
+// of a later iterator to continue a dump where it was left off.
+func (self *StateDB) NewDumpIterator(start common.Hash, excludeCode, excludeStorage bool) *DumpIterator {
+	return &DumpIterator{
+		state:          self,
+		it:             trie.NewIterator(self.trie.NodeIterator(start[:])),
+		excludeCode:    excludeCode,
+		excludeStorage: excludeStorage,
+	}
+}
+
+// Next moves the iterator to the next account, returning whether there is one.
+func (it *DumpIterator) Next() bool {
+	if it.err != nil || !it.it.Next() {
+		if it.err == nil {
+			it.err = it.it.Err
+		}
+		return false
+	}
+	var data Account
+	if err := rlp.DecodeBytes(it.it.Value, &data); err != nil {
+		it.err = err
+		return false
+	}
+	it.key, it.addr = common.BytesToHash(it.it.Key), nil
+	if preimage := it.state.trie.GetKey(it.it.Key); preimage != nil {
+		addr := common.BytesToAddress(preimage)
+		it.addr = &addr
+	}
+	it.account = DumpAccount{
+		Balance:  data.Balance.String(),
+		Nonce:    data.Nonce,
+		Root:     common.Bytes2Hex(data.Root[:]),
+		CodeHash: common.Bytes2Hex(data.CodeHash),
+	}
+	if it.excludeCode && it.excludeStorage {
+		return true
+	}
+	var addr common.Address
+	if it.addr != nil {
+		addr = *it.addr
+	}
+	obj := newObject(nil, addr, data, nil)
+	if !it.excludeCode {
+		it.account.Code = common.Bytes2Hex(obj.Code(it.state.db))
+	}
+	if !it.excludeStorage {
+		it.account.Storage = make(map[string]string)
+		storageIt := trie.NewIterator(obj.getTrie(it.state.db).NodeIterator(nil))
+		for storageIt.Next() {
+			it.account.Storage[common.Bytes2Hex(it.state.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+		}
+		if storageIt.Err != nil {
+			it.err = storageIt.Err
+			return false
+		}
+	}
+	return true
+}
+
+// Key returns the hashed address of the current account.
+func (it *DumpIterator) Key() common.Hash {
+	return it.key
+}
+
+// Address returns the address of the current account, or nil if its preimage
+// is not known.
+func (it *DumpIterator) Address() *common.Address {
+	return it.addr
+}
+
+// Account returns the dump of the current account.
+func (it *DumpIterator) Account() DumpAccount {
+	return it.account
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *DumpIterator) Err() error {
+	return it.err
+}
+
 func (self *StateDB) RawDump() Dump {
 	dump := Dump{
 		Root:     fmt.Sprintf("%x", self.trie.Hash()),
 		Accounts: make(map[string]DumpAccount),
 	}
-
-	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	it := self.NewDumpIterator(common.Hash{}, false, false)
 	for it.Next() {
-		addr := self.trie.GetKey(it.Key)
-		var data Account
-		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
-			panic(err)
+		var addr []byte
+		if it.Address() != nil {
+			addr = it.Address().Bytes()
 		}
+		dump.Accounts[common.Bytes2Hex(addr)] = it.Account()
+	}
+	if it.Err() != nil {
+		panic(it.Err())
+	}
+	return dump
+}
 
-		obj := newObject(nil, common.BytesToAddress(addr), data, nil)
-		account := DumpAccount{
-			Balance:  data.Balance.String(),
-			Nonce:    data.Nonce,
-			Root:     common.Bytes2Hex(data.Root[:]),
-			CodeHash: common.Bytes2Hex(data.CodeHash),
-			Code:     common.Bytes2Hex(obj.Code(self.db)),
-			Storage:  make(map[string]string),
-		}
-		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
-		for storageIt.Next() {
-			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
+// IteratorDump dumps at most maxResults accounts of the state, starting at the
+// given hashed address. The returned next key continues the dump.
+func (self *StateDB) IteratorDump(start common.Hash, maxResults int, excludeCode, excludeStorage bool) (IteratorDump, error) {
+	dump := IteratorDump{
+		Root:     fmt.Sprintf("%x", self.trie.Hash()),
+		Accounts: make(map[string]DumpAccount),
+	}
+	it := self.NewDumpIterator(start, excludeCode, excludeStorage)
+	for len(dump.Accounts) < maxResults && it.Next() {
+		key := common.Bytes2Hex(it.Key().Bytes())
+		if it.Address() != nil {
+			key = common.Bytes2Hex(it.Address().Bytes())
 		}
-		dump.Accounts[common.Bytes2Hex(addr)] = account
+		dump.Accounts[key] = it.Account()
 	}
-	return dump
+	// Add the 'next key' so clients can continue downloading.
+	if it.Err() == nil && it.it.Next() {
+		next := common.BytesToHash(it.it.Key)
+		dump.NextKey = &next
+	}
+	return dump, it.Err()
 }
 
 func (self *StateDB) Dump() []byte {
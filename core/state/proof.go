@@ -0,0 +1,78 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// ProveAccount reads the account of addr from the state trie with the given
+// root and checks it against a Merkle proof of the root, so that corrupted
+// trie nodes are detected. It returns nil if the account doesn't exist.
+func ProveAccount(db Database, root common.Hash, addr common.Address) (*Account, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := proveKey(tr, root, crypto.Keccak256(addr.Bytes()))
+	if err != nil || blob == nil {
+		return nil, err
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, fmt.Errorf("invalid account %x in proof: %v", addr, err)
+	}
+	return account, nil
+}
+
+// ProveStorage reads the storage slot key of addr from the storage trie with
+// the given root and checks it against a Merkle proof of the root.
+func ProveStorage(db Database, addr common.Address, root common.Hash, key common.Hash) (common.Hash, error) {
+	tr, err := db.OpenStorageTrie(crypto.Keccak256Hash(addr.Bytes()), root)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	blob, err := proveKey(tr, root, crypto.Keccak256(key.Bytes()))
+	if err != nil || blob == nil {
+		return common.Hash{}, err
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid storage slot %x of %x in proof: %v", key, addr, err)
+	}
+	return common.BytesToHash(content), nil
+}
+
+// proveKey constructs a proof for the hashed key and verifies it against root,
+// returning the proven value. Empty tries hold no nodes to prove.
+func proveKey(tr Trie, root common.Hash, key []byte) ([]byte, error) {
+	if root == types.EmptyRootHash {
+		return nil, nil
+	}
+	proof := memorydb.New()
+	if err := tr.Prove(key, 0, proof); err != nil {
+		return nil, err
+	}
+	return trie.VerifyProof(root, key, proof)
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+// Tests that accounts and storage slots are proven against the state root and
+// that corrupted trie nodes on disk are detected.
+func TestProveAccountAndStorage(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	sdb := NewDatabase(db)
+	state, _ := New(common.Hash{}, sdb)
+
+	addr, slot := common.Address{0x01}, common.Hash{0x02}
+	state.SetBalance(addr, big.NewInt(42))
+	state.SetNonce(addr, 3)
+	state.SetState(addr, slot, common.Hash{0x03})
+	for i := byte(0); i < 64; i++ {
+		state.SetBalance(common.Address{0xff, i}, big.NewInt(int64(i)))
+	}
+	root, _ := state.Commit(false)
+	if err := sdb.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	account, err := ProveAccount(sdb, root, addr)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	if account == nil || account.Nonce != 3 || account.Balance.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("proven account mismatch: %+v", account)
+	}
+	if value, err := ProveStorage(sdb, addr, account.Root, slot); err != nil || value != (common.Hash{0x03}) {
+		t.Fatalf("proven storage mismatch: have %x (%v), want %x", value, err, common.Hash{0x03})
+	}
+	if account, err := ProveAccount(sdb, root, common.Address{0xee}); account != nil || err != nil {
+		t.Fatalf("missing account proven: %+v (%v)", account, err)
+	}
+	// Corrupt every value stored under the root node's hash and ensure the
+	// proof verification fails
+	blob, err := db.Get(root.Bytes())
+	if err != nil {
+		t.Fatalf("state root missing from disk: %v", err)
+	}
+	corrupt := common.CopyBytes(blob)
+	corrupt[len(corrupt)-1] ^= 0xff
+	db.Put(root.Bytes(), corrupt)
+
+	if _, err := ProveAccount(NewDatabase(db), root, addr); err == nil {
+		t.Fatalf("corrupted state root proven")
+	}
+}
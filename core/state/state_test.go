@@ -87,6 +87,49 @@ func (s *StateSuite) TestDump(c *checker.C) {
 	}
 }
 
+func (s *StateSuite) TestIteratorDump(c *checker.C) {
+	for i := byte(1); i <= 5; i++ {
+		obj := s.state.GetOrNewStateObject(toAddr([]byte{i}))
+		obj.AddBalance(big.NewInt(int64(i)))
+		obj.SetState(s.state.db, common.Hash{i}, common.Hash{i})
+	}
+	s.state.Commit(false)
+
+	// Page through the state and check that every account is dumped once
+	var (
+		start common.Hash
+		seen  = make(map[string]DumpAccount)
+	)
+	for pages := 0; ; pages++ {
+		dump, err := s.state.IteratorDump(start, 2, true, false)
+		if err != nil {
+			c.Fatalf("failed to dump state: %v", err)
+		}
+		if len(dump.Accounts) > 2 {
+			c.Fatalf("page %d holds %d accounts, want at most 2", pages, len(dump.Accounts))
+		}
+		for addr, account := range dump.Accounts {
+			if _, ok := seen[addr]; ok {
+				c.Fatalf("account %s dumped twice", addr)
+			}
+			seen[addr] = account
+		}
+		if dump.NextKey == nil {
+			break
+		}
+		start = *dump.NextKey
+	}
+	full := s.state.RawDump()
+	if len(seen) != len(full.Accounts) {
+		c.Fatalf("dumped account count mismatch: have %d, want %d", len(seen), len(full.Accounts))
+	}
+	for addr, account := range full.Accounts {
+		if have := seen[addr]; have.Balance != account.Balance || len(have.Storage) != 1 || have.Code != "" {
+			c.Errorf("account %s mismatch: have %+v, want %+v", addr, have, account)
+		}
+	}
+}
+
 func (s *StateSuite) SetUpTest(c *checker.C) {
 	s.db= rawdb.NewMemoryDatabase()
 	s.state, _ = New(common.Hash{}, NewDatabase(s.db))
@@ -427,3 +427,57 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+func TestStateDBAccessList(t *testing.T) {
+	// Some helpers
+	addr := func(a string) common.Address {
+		return common.HexToAddress(a)
+	}
+	slot := func(a string) common.Hash {
+		return common.HexToHash(a)
+	}
+
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+
+	state.AddAddressToAccessList(addr("aa"))          // 1
+	state.AddSlotToAccessList(addr("bb"), slot("01")) // 2,3
+	state.AddSlotToAccessList(addr("bb"), slot("02")) // 4
+	if !state.AddressInAccessList(addr("aa")) || !state.AddressInAccessList(addr("bb")) {
+		t.Fatal("expected addresses to be present")
+	}
+	snapshot := state.Snapshot()
+	state.AddSlotToAccessList(addr("aa"), slot("01")) // 5
+	state.AddAddressToAccessList(addr("cc"))          // 6
+	if _, ok := state.SlotInAccessList(addr("aa"), slot("01")); !ok {
+		t.Fatal("expected slot to be present")
+	}
+	state.RevertToSnapshot(snapshot)
+	if state.AddressInAccessList(addr("cc")) {
+		t.Fatal("expected address to be reverted")
+	}
+	if addrOk, slotOk := state.SlotInAccessList(addr("aa"), slot("01")); !addrOk || slotOk {
+		t.Fatalf("expected (aa, 01) to be reverted, have addr %v slot %v", addrOk, slotOk)
+	}
+	for _, s := range []string{"01", "02"} {
+		if _, ok := state.SlotInAccessList(addr("bb"), slot(s)); !ok {
+			t.Fatalf("expected (bb, %s) to survive the revert", s)
+		}
+	}
+	// Copies must be independent from the original
+	cp := state.Copy()
+	cp.AddAddressToAccessList(addr("dd"))
+	if state.AddressInAccessList(addr("dd")) {
+		t.Fatal("access list of copy leaked into the original")
+	}
+	// Preparing the list for a new transaction must clear previous entries
+	dst := addr("ee")
+	state.PrepareAccessList(addr("ff"), &dst, []common.Address{addr("01")}, nil)
+	if state.AddressInAccessList(addr("aa")) {
+		t.Fatal("expected access list to be reset")
+	}
+	for _, a := range []string{"ee", "ff", "01"} {
+		if !state.AddressInAccessList(addr(a)) {
+			t.Fatalf("expected %s to be warm after prepare", a)
+		}
+	}
+}
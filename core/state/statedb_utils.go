@@ -78,6 +78,7 @@ var (
 		"maxValidatorNumber":     7,
 		"candidateWithdrawDelay": 8,
 		"voterWithdrawDelay":     9,
+		"candidateSigners":       10,
 	}
 )
 
@@ -143,3 +144,22 @@ func GetVoterCap(statedb *StateDB, candidate, voter common.Address) *big.Int {
 	ret := statedb.GetState(common.HexToAddress(common.MasternodeVotingSMC), common.BytesToHash(retByte))
 	return ret.Big()
 }
+
+// GetCandidateSigner returns the signing key registered for the candidate with
+// rotateSigner, or the zero address if it signs with its own key. The deployed
+// validator contract predates the rotations, so the mapping lives at a slot of
+// its storage the contract itself never uses.
+func GetCandidateSigner(statedb *StateDB, candidate common.Address) common.Address {
+	slot := slotValidatorMapping["candidateSigners"]
+	// candidateSigners[_candidate];
+	locCandidateSigner := GetLocMappingAtKey(candidate.Hash(), slot)
+	ret := statedb.GetState(common.HexToAddress(common.MasternodeVotingSMC), common.BigToHash(locCandidateSigner))
+	return common.HexToAddress(ret.Hex())
+}
+
+// SetCandidateSigner registers the signing key of the candidate.
+func SetCandidateSigner(statedb *StateDB, candidate, signer common.Address) {
+	slot := slotValidatorMapping["candidateSigners"]
+	locCandidateSigner := GetLocMappingAtKey(candidate.Hash(), slot)
+	statedb.SetState(common.HexToAddress(common.MasternodeVotingSMC), common.BigToHash(locCandidateSigner), signer.Hash())
+}
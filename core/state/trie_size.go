@@ -0,0 +1,119 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// trieSizeWindow is the number of most recent commits the rolling trie size
+// statistics are gathered over.
+const trieSizeWindow = 128
+
+var (
+	trieSizeLock     sync.Mutex
+	trieSizeCounters = make(map[string]*TrieSizeCounter)
+
+	// AccountTrieSize accounts the nodes committed to the account trie.
+	AccountTrieSize = NewTrieSizeCounter("account")
+	// StorageTrieSize accounts the nodes committed to the storage tries, along
+	// with the contract code.
+	StorageTrieSize = NewTrieSizeCounter("storage")
+)
+
+// TrieSizeStats are the statistics of the trie nodes committed by a subsystem.
+type TrieSizeStats struct {
+	Commits     uint64 `json:"commits"`     // Number of commits since startup
+	Nodes       uint64 `json:"nodes"`       // Nodes committed since startup
+	Size        uint64 `json:"size"`        // Bytes committed since startup
+	RecentNodes uint64 `json:"recentNodes"` // Nodes committed over the recent commits
+	RecentSize  uint64 `json:"recentSize"`  // Bytes committed over the recent commits
+}
+
+// TrieSizeCounter accumulates the trie nodes committed by a subsystem, in total
+// and over a rolling window of recent commits.
+type TrieSizeCounter struct {
+	stats  TrieSizeStats
+	window [trieSizeWindow][2]uint64 // Nodes and bytes of the recent commits
+	lock   sync.Mutex
+
+	nodesMeter metrics.Meter
+	sizeMeter  metrics.Meter
+}
+
+// NewTrieSizeCounter creates a trie size counter and registers it under the
+// given subsystem name, reported by TrieSizes.
+func NewTrieSizeCounter(name string) *TrieSizeCounter {
+	counter := &TrieSizeCounter{
+		nodesMeter: metrics.NewRegisteredMeter("trie/size/"+name+"/nodes", nil),
+		sizeMeter:  metrics.NewRegisteredMeter("trie/size/"+name+"/bytes", nil),
+	}
+	trieSizeLock.Lock()
+	trieSizeCounters[name] = counter
+	trieSizeLock.Unlock()
+
+	return counter
+}
+
+// Mark records a commit of the given number of nodes and bytes.
+func (c *TrieSizeCounter) Mark(nodes uint64, size common.StorageSize) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	slot := &c.window[c.stats.Commits%trieSizeWindow]
+	c.stats.RecentNodes = c.stats.RecentNodes - slot[0] + nodes
+	c.stats.RecentSize = c.stats.RecentSize - slot[1] + uint64(size)
+	slot[0], slot[1] = nodes, uint64(size)
+
+	c.stats.Commits++
+	c.stats.Nodes += nodes
+	c.stats.Size += uint64(size)
+
+	c.nodesMeter.Mark(int64(nodes))
+	c.sizeMeter.Mark(int64(size))
+}
+
+// MarkSince records a commit as the nodes inserted into the trie database since
+// the given counts, as returned by its Inserted method before the commit.
+func (c *TrieSizeCounter) MarkSince(db *trie.Database, nodes uint64, size common.StorageSize) {
+	newNodes, newSize := db.Inserted()
+	c.Mark(newNodes-nodes, newSize-size)
+}
+
+// Stats returns the statistics gathered so far.
+func (c *TrieSizeCounter) Stats() TrieSizeStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.stats
+}
+
+// TrieSizes returns the trie size statistics of every subsystem, keyed by name.
+func TrieSizes() map[string]TrieSizeStats {
+	trieSizeLock.Lock()
+	defer trieSizeLock.Unlock()
+
+	stats := make(map[string]TrieSizeStats, len(trieSizeCounters))
+	for name, counter := range trieSizeCounters {
+		stats[name] = counter.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/metrics"
+)
+
+// Tests that the rolling trie size statistics only cover the recent commits.
+func TestTrieSizeCounterWindow(t *testing.T) {
+	counter := &TrieSizeCounter{nodesMeter: metrics.NilMeter{}, sizeMeter: metrics.NilMeter{}}
+	for i := 1; i <= trieSizeWindow+10; i++ {
+		counter.Mark(uint64(i), common.StorageSize(2*i))
+	}
+	var (
+		stats  = counter.Stats()
+		total  = uint64((trieSizeWindow + 10) * (trieSizeWindow + 11) / 2)
+		recent = total - 10*11/2
+	)
+	if stats.Commits != trieSizeWindow+10 || stats.Nodes != total || stats.Size != 2*total {
+		t.Errorf("total stats mismatch: have %+v, want %d commits of %d nodes, %d bytes", stats, trieSizeWindow+10, total, 2*total)
+	}
+	if stats.RecentNodes != recent || stats.RecentSize != 2*recent {
+		t.Errorf("recent stats mismatch: have %d nodes, %d bytes, want %d, %d", stats.RecentNodes, stats.RecentSize, recent, 2*recent)
+	}
+}
+
+// Tests that state commits account the account and storage trie nodes apart.
+func TestTrieSizeAccounting(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, db)
+	statedb.AddBalance(common.Address{1}, big.NewInt(1))
+
+	account, storage := AccountTrieSize.Stats(), StorageTrieSize.Stats()
+	root, _ := statedb.Commit(false)
+	if have := AccountTrieSize.Stats(); have.Commits != account.Commits+1 || have.Nodes == account.Nodes {
+		t.Fatalf("account commit not accounted: have %+v, before %+v", have, account)
+	}
+	if have := StorageTrieSize.Stats(); have.Commits != storage.Commits+1 || have.Nodes != storage.Nodes {
+		t.Fatalf("storage accounted without storage changes: have %+v, before %+v", have, storage)
+	}
+
+	// Storage changes must be attributed to the storage tries
+	storage = StorageTrieSize.Stats()
+	statedb, _ = New(root, db)
+	statedb.SetState(common.Address{1}, common.Hash{1}, common.Hash{1})
+	statedb.Commit(false)
+	if have := StorageTrieSize.Stats(); have.Nodes == storage.Nodes || have.Size == storage.Size {
+		t.Errorf("storage commit not accounted: have %+v, before %+v", have, storage)
+	}
+	if sizes := TrieSizes(); sizes["account"] != AccountTrieSize.Stats() || sizes["storage"] != StorageTrieSize.Stats() {
+		t.Errorf("trie sizes mismatch: have %+v", sizes)
+	}
+}
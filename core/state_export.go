@@ -0,0 +1,515 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/exchange"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"github.com/tomochain/tomochain/trie"
+)
+
+const (
+	stateExportMagic   = "tomochain-state" // Identifier of the state export files
+	stateExportVersion = 1                 // Version of the state export file format
+
+	stateImportFlush = 100000 // Number of leaves imported into a trie before flushing it to disk
+)
+
+// Kinds of the records following the header of a state export file.
+const (
+	stateRecordBlock = iota // Block of the exported chain segment, with its total difficulty
+	stateRecordTrie         // Start of a trie, keyed by its root
+	stateRecordLeaf         // Leaf of the innermost trie started
+	stateRecordEnd          // End of the innermost trie started
+	stateRecordBlob         // Contract code referenced by the last leaf, keyed by its hash
+)
+
+var (
+	errStateExportFormat = errors.New("invalid state export file")
+	errStateImportChain  = errors.New("database already holds a chain beyond genesis")
+)
+
+// stateExportHeader opens a state export file, identifying the block whose
+// state follows it.
+type stateExportHeader struct {
+	Magic       string
+	Version     uint64
+	Genesis     common.Hash
+	Number      uint64
+	Hash        common.Hash
+	Root        common.Hash
+	TradingRoot common.Hash
+	LendingRoot common.Hash
+}
+
+// stateRecord is an entry of a state export file.
+type stateRecord struct {
+	Kind  uint64
+	Key   []byte
+	Value []byte
+}
+
+// exportedBlock is the content of a block record.
+type exportedBlock struct {
+	Block *types.Block
+	Td    *big.Int
+}
+
+// trieSchema returns the tries and the raw blobs referenced by a leaf of a state
+// trie, which are exported along with it.
+type trieSchema func(leaf []byte) (tries []nestedTrie, blobs []common.Hash)
+
+// nestedTrie is a trie referenced by a leaf, with the schema of its own leaves.
+type nestedTrie struct {
+	root   common.Hash
+	schema trieSchema
+}
+
+// tomoXSchema adapts a decoder of the trie roots held by the leaves of a TomoX
+// state trie, pairing each root with the schema at the same position.
+func tomoXSchema(decode func(leaf []byte) ([]common.Hash, error), schemas ...trieSchema) trieSchema {
+	return func(leaf []byte) ([]nestedTrie, []common.Hash) {
+		roots, err := decode(leaf)
+		if err != nil {
+			return nil, nil
+		}
+		var tries []nestedTrie
+		for i, root := range roots {
+			if root == tradingstate.EmptyRoot || root == (common.Hash{}) {
+				continue
+			}
+			var schema trieSchema
+			if i < len(schemas) {
+				schema = schemas[i]
+			}
+			tries = append(tries, nestedTrie{root: root, schema: schema})
+		}
+		return tries, nil
+	}
+}
+
+var (
+	// tradingListSchema describes the tries of price levels and lending books,
+	// whose leaves hold the trie of their entries.
+	tradingListSchema = tomoXSchema(tradingstate.OrderListTrieRoots)
+
+	// tradingSchema describes the trading state trie: order books holding the
+	// ask and bid price levels, the orders and the liquidation prices, the
+	// latter holding a trie of lending books each, then the stop order price
+	// levels and the good-till-date orders by expiry time.
+	tradingSchema = tomoXSchema(tradingstate.OrderBookTrieRoots,
+		tradingListSchema, tradingListSchema, nil, tomoXSchema(tradingstate.OrderListTrieRoots, tradingListSchema),
+		tradingListSchema, tradingListSchema, tradingListSchema)
+
+	// lendingListSchema describes the investing, borrowing and liquidation time
+	// tries, whose leaves hold the trie of their entries.
+	lendingListSchema = tomoXSchema(lendingstate.ItemListTrieRoots)
+
+	// lendingSchema describes the lending state trie.
+	lendingSchema = tomoXSchema(lendingstate.LendingBookTrieRoots,
+		lendingListSchema, lendingListSchema, lendingListSchema)
+)
+
+// accountSchema describes the account trie, whose leaves hold the storage trie
+// and the code of the accounts.
+func accountSchema(leaf []byte) ([]nestedTrie, []common.Hash) {
+	roots, blobs := accountTries(leaf)
+
+	tries := make([]nestedTrie, len(roots))
+	for i, root := range roots {
+		tries[i] = nestedTrie{root: root}
+	}
+	return tries, blobs
+}
+
+// ExportState writes the full state of the given canonical block into w: its
+// accounts with their storage and code and, if tomoxdb is set, its TomoX order
+// and lending books. The blocks back to the previous epoch checkpoint are
+// written along, so that a node importing the file can verify the blocks
+// following it.
+func (bc *BlockChain) ExportState(w io.Writer, block *types.Block, tomoxdb ethdb.Database) error {
+	var tradingRoot, lendingRoot common.Hash
+	if tomoxdb != nil {
+		tradingRoot, lendingRoot = bc.tomoXStateRoots(block)
+	}
+	return bc.exportState(w, block, tomoxdb, tradingRoot, lendingRoot)
+}
+
+// exportState writes the state of the block into w, along with the TomoX tries
+// with the given roots, a zero hash skipping a trie.
+func (bc *BlockChain) exportState(w io.Writer, block *types.Block, tomoxdb ethdb.Database, tradingRoot, lendingRoot common.Hash) error {
+	number := block.NumberU64()
+	if number == 0 {
+		return errors.New("export failed: genesis state is not exported")
+	}
+	if GetCanonicalHash(bc.db, number) != block.Hash() {
+		return fmt.Errorf("export failed: block #%d [%x…] not canonical", number, block.Hash().Bytes()[:4])
+	}
+	header := &stateExportHeader{
+		Magic:       stateExportMagic,
+		Version:     stateExportVersion,
+		Genesis:     bc.genesisBlock.Hash(),
+		Number:      number,
+		Hash:        block.Hash(),
+		Root:        block.Root(),
+		TradingRoot: tradingRoot,
+		LendingRoot: lendingRoot,
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return err
+	}
+	// Export the chain segment the state belongs to
+	first := number
+	if posv := bc.chainConfig.Posv; posv != nil {
+		if first -= first % posv.Epoch; first >= posv.Epoch {
+			first -= posv.Epoch
+		}
+		if first == 0 {
+			first = 1
+		}
+	}
+	for n := first; n <= number; n++ {
+		block := bc.GetBlockByNumber(n)
+		if block == nil {
+			return fmt.Errorf("export failed on #%d: not found", n)
+		}
+		enc, err := rlp.EncodeToBytes(&exportedBlock{Block: block, Td: bc.GetTd(block.Hash(), n)})
+		if err != nil {
+			return err
+		}
+		if err := rlp.Encode(w, &stateRecord{Kind: stateRecordBlock, Value: enc}); err != nil {
+			return err
+		}
+	}
+	// Export the state tries, then the TomoX ones
+	exporter := &stateExporter{w: w, start: time.Now(), logged: time.Now()}
+	if err := exporter.exportTrie(bc.stateCache.TrieDB(), block.Root(), accountSchema); err != nil {
+		return fmt.Errorf("export failed on state: %v", err)
+	}
+	if header.TradingRoot != (common.Hash{}) || header.LendingRoot != (common.Hash{}) {
+		triedb := trie.NewDatabase(tomoxdb)
+		if header.TradingRoot != (common.Hash{}) {
+			if err := exporter.exportTrie(triedb, header.TradingRoot, tradingSchema); err != nil {
+				return fmt.Errorf("export failed on trading state: %v", err)
+			}
+		}
+		if header.LendingRoot != (common.Hash{}) {
+			if err := exporter.exportTrie(triedb, header.LendingRoot, lendingSchema); err != nil {
+				return fmt.Errorf("export failed on lending state: %v", err)
+			}
+		}
+	}
+	log.Info("Exported state", "number", number, "hash", block.Hash(), "blocks", number-first+1, "tries", exporter.tries, "leaves", exporter.leaves, "elapsed", common.PrettyDuration(time.Since(exporter.start)))
+	return nil
+}
+
+// tomoXStateRoots returns the roots of the trading and lending state tries of
+// the block, as committed to by its author. Empty and inactive tries are
+// reported as zero hashes.
+func (bc *BlockChain) tomoXStateRoots(block *types.Block) (trading common.Hash, lending common.Hash) {
+	if _, ok := bc.Engine().(exchange.Engine); !ok || !bc.chainConfig.IsTomoXEnabled(block.Number()) {
+		return common.Hash{}, common.Hash{}
+	}
+	author, err := bc.Engine().Author(block.Header())
+	if err != nil {
+		return common.Hash{}, common.Hash{}
+	}
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil || tx.To().Hex() != common.TradingStateAddr || len(tx.Data()) < common.HashLength {
+			continue
+		}
+		if from := tx.From(); from == nil || *from != author {
+			continue
+		}
+		if root := common.BytesToHash(tx.Data()[:common.HashLength]); root != tradingstate.EmptyRoot {
+			trading = root
+		}
+		if len(tx.Data()) >= 2*common.HashLength && bc.Config().IsTIPTomoXLending(block.Number()) {
+			if root := common.BytesToHash(tx.Data()[common.HashLength : 2*common.HashLength]); root != lendingstate.EmptyRoot {
+				lending = root
+			}
+		}
+		break
+	}
+	return trading, lending
+}
+
+// stateExporter writes state tries into a state export file.
+type stateExporter struct {
+	w      io.Writer
+	tries  int
+	leaves int
+
+	start  time.Time
+	logged time.Time
+}
+
+// exportTrie writes the leaves of the trie with the given root, each followed
+// by the blobs and tries it references.
+func (e *stateExporter) exportTrie(db *trie.Database, root common.Hash, schema trieSchema) error {
+	tr, err := trie.New(root, db)
+	if err != nil {
+		return err
+	}
+	if err := rlp.Encode(e.w, &stateRecord{Kind: stateRecordTrie, Key: root[:]}); err != nil {
+		return err
+	}
+	e.tries++
+
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		if err := rlp.Encode(e.w, &stateRecord{Kind: stateRecordLeaf, Key: it.Key, Value: it.Value}); err != nil {
+			return err
+		}
+		if e.leaves++; time.Since(e.logged) > 8*time.Second {
+			log.Info("Exporting state", "tries", e.tries, "leaves", e.leaves, "elapsed", common.PrettyDuration(time.Since(e.start)))
+			e.logged = time.Now()
+		}
+		if schema == nil {
+			continue
+		}
+		tries, blobs := schema(it.Value)
+		for _, hash := range blobs {
+			blob, err := db.Node(hash)
+			if err != nil {
+				return err
+			}
+			if err := rlp.Encode(e.w, &stateRecord{Kind: stateRecordBlob, Key: hash[:], Value: blob}); err != nil {
+				return err
+			}
+		}
+		for _, nested := range tries {
+			if err := e.exportTrie(db, nested.root, nested.schema); err != nil {
+				return err
+			}
+		}
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	return rlp.Encode(e.w, &stateRecord{Kind: stateRecordEnd})
+}
+
+// trieImport is a trie being rebuilt from the leaves of a state export file.
+type trieImport struct {
+	root   common.Hash
+	db     ethdb.Database
+	triedb *trie.Database
+	trie   *trie.Trie
+	leaves int
+}
+
+// flush commits the leaves imported so far to disk, returning the root.
+func (t *trieImport) flush() (common.Hash, error) {
+	root, err := t.trie.Commit(nil)
+	if err != nil || root == types.EmptyRootHash {
+		return root, err
+	}
+	if err := t.triedb.Commit(root, false); err != nil {
+		return common.Hash{}, err
+	}
+	if t.trie, err = trie.New(root, t.triedb); err != nil {
+		return common.Hash{}, err
+	}
+	return root, nil
+}
+
+// ImportState reads a state export file and writes the exported block, the
+// chain segment it closes and its state into db, the TomoX state into tomoxdb,
+// then makes the block the head of the chain. The database must hold the same
+// genesis block as the exporting node and no other block.
+func ImportState(r io.Reader, db ethdb.Database, tomoxdb ethdb.Database) (*types.Block, error) {
+	var (
+		stream = rlp.NewStream(r, 0)
+		header stateExportHeader
+	)
+	if err := stream.Decode(&header); err != nil {
+		return nil, fmt.Errorf("%v: %v", errStateExportFormat, err)
+	}
+	if header.Magic != stateExportMagic {
+		return nil, errStateExportFormat
+	}
+	if header.Version != stateExportVersion {
+		return nil, fmt.Errorf("unsupported state export version %d, want %d", header.Version, stateExportVersion)
+	}
+	genesis := GetCanonicalHash(db, 0)
+	if genesis != header.Genesis {
+		return nil, fmt.Errorf("genesis mismatch: have %x, want %x", genesis, header.Genesis)
+	}
+	if GetHeadBlockHash(db) != genesis {
+		return nil, errStateImportChain
+	}
+	if (header.TradingRoot != (common.Hash{}) || header.LendingRoot != (common.Hash{})) && tomoxdb == nil {
+		return nil, errors.New("TomoX database required to import TomoX state")
+	}
+	var (
+		first, last *types.Block
+		stack       []*trieImport
+		tries       int
+		leaves      int
+		start       = time.Now()
+		logged      = time.Now()
+	)
+	for {
+		var record stateRecord
+		if err := stream.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%v: %v", errStateExportFormat, err)
+		}
+		switch record.Kind {
+		case stateRecordBlock:
+			var exported exportedBlock
+			if err := rlp.DecodeBytes(record.Value, &exported); err != nil {
+				return nil, fmt.Errorf("%v: %v", errStateExportFormat, err)
+			}
+			block := exported.Block
+			if last != nil && block.ParentHash() != last.Hash() {
+				return nil, fmt.Errorf("non contiguous block #%d [%x…]", block.NumberU64(), block.Hash().Bytes()[:4])
+			}
+			if err := WriteBlock(db, block); err != nil {
+				return nil, err
+			}
+			if err := WriteTd(db, block.Hash(), block.NumberU64(), exported.Td); err != nil {
+				return nil, err
+			}
+			if err := WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+				return nil, err
+			}
+			if first == nil {
+				first = block
+			}
+			last = block
+
+		case stateRecordTrie:
+			imported := &trieImport{root: common.BytesToHash(record.Key)}
+			switch {
+			case len(stack) > 0:
+				imported.db, imported.triedb = stack[len(stack)-1].db, stack[len(stack)-1].triedb
+			case imported.root == header.Root:
+				imported.db, imported.triedb = db, trie.NewDatabase(db)
+			case imported.root == header.TradingRoot || imported.root == header.LendingRoot:
+				imported.db, imported.triedb = tomoxdb, trie.NewDatabase(tomoxdb)
+			default:
+				return nil, fmt.Errorf("unexpected trie %x", imported.root)
+			}
+			imported.trie, _ = trie.New(common.Hash{}, imported.triedb)
+			stack = append(stack, imported)
+			tries++
+
+		case stateRecordLeaf:
+			if len(stack) == 0 {
+				return nil, errStateExportFormat
+			}
+			imported := stack[len(stack)-1]
+			if err := imported.trie.TryUpdate(record.Key, record.Value); err != nil {
+				return nil, err
+			}
+			if imported.leaves++; imported.leaves%stateImportFlush == 0 {
+				if _, err := imported.flush(); err != nil {
+					return nil, err
+				}
+			}
+			if leaves++; time.Since(logged) > 8*time.Second {
+				log.Info("Importing state", "tries", tries, "leaves", leaves, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+
+		case stateRecordEnd:
+			if len(stack) == 0 {
+				return nil, errStateExportFormat
+			}
+			imported := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			root, err := imported.flush()
+			if err != nil {
+				return nil, err
+			}
+			if root != imported.root {
+				return nil, fmt.Errorf("trie root mismatch: have %x, want %x", root, imported.root)
+			}
+
+		case stateRecordBlob:
+			if len(stack) == 0 {
+				return nil, errStateExportFormat
+			}
+			if hash := crypto.Keccak256Hash(record.Value); hash != common.BytesToHash(record.Key) {
+				return nil, fmt.Errorf("blob hash mismatch: have %x, want %x", hash, record.Key)
+			}
+			if err := stack[len(stack)-1].db.Put(record.Key, record.Value); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown state record kind %d", record.Kind)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%v: truncated trie %x", errStateExportFormat, stack[len(stack)-1].root)
+	}
+	if last == nil || last.Hash() != header.Hash || last.Root() != header.Root {
+		return nil, fmt.Errorf("%v: exported block #%d [%x…] missing", errStateExportFormat, header.Number, header.Hash.Bytes()[:4])
+	}
+	// Make sure every state trie was imported before moving the head
+	if _, err := trie.New(header.Root, trie.NewDatabase(db)); err != nil {
+		return nil, fmt.Errorf("state missing: %v", err)
+	}
+	for _, root := range []common.Hash{header.TradingRoot, header.LendingRoot} {
+		if root == (common.Hash{}) {
+			continue
+		}
+		if _, err := trie.New(root, trie.NewDatabase(tomoxdb)); err != nil {
+			return nil, fmt.Errorf("TomoX state missing: %v", err)
+		}
+	}
+	if tomoxdb != nil {
+		if err := writeTomoXCommit(tomoxdb, &tomoXCommit{Number: last.NumberU64(), Hash: last.Hash(), TradingRoot: header.TradingRoot, LendingRoot: header.LendingRoot}); err != nil {
+			return nil, err
+		}
+	}
+	// The history below the imported segment is unavailable
+	if first.NumberU64() > 1 {
+		if err := WriteHistoryTail(db, first.NumberU64()); err != nil {
+			return nil, err
+		}
+	}
+	if err := WriteHeadHeaderHash(db, last.Hash()); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadFastBlockHash(db, last.Hash()); err != nil {
+		return nil, err
+	}
+	if err := WriteHeadBlockHash(db, last.Hash()); err != nil {
+		return nil, err
+	}
+	log.Info("Imported state", "number", last.Number(), "hash", last.Hash(), "tries", tries, "leaves", leaves, "elapsed", common.PrettyDuration(time.Since(start)))
+	return last, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Tests that the state of a block, along with its TomoX state, can be exported
+// into a file and imported into a fresh database.
+func TestExportImportState(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		code     = []byte{0x60, 0x00, 0x54, 0x00}
+		slot     = common.HexToHash("0x01")
+		gspec    = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{
+			address:  {Balance: big.NewInt(1000000000)},
+			contract: {Balance: big.NewInt(1), Code: code, Storage: map[common.Hash]common.Hash{slot: common.HexToHash("0x2a")}},
+		}}
+		db      = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	// Create a trading state with orders and liquidation prices
+	var (
+		tomoxdb     = rawdb.NewMemoryDatabase()
+		orderBook   = common.StringToHash("BTC/TOMO")
+		lendingBook = common.StringToHash("BTC/USDT")
+	)
+	trading, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(tomoxdb))
+	for i := 1; i <= 4; i++ {
+		order := tradingstate.OrderItem{OrderID: uint64(i), Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(i)), Side: tradingstate.Ask, Signature: &tradingstate.Signature{V: 1}}
+		if i%2 == 0 {
+			order.Side = tradingstate.Bid
+		}
+		trading.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(int64(i))), order)
+	}
+	trading.InsertLiquidationPrice(orderBook, big.NewInt(100), lendingBook, 7)
+	tradingRoot, err := trading.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	if err := trading.Database().TrieDB().Commit(tradingRoot, false); err != nil {
+		t.Fatalf("failed to flush trading state: %v", err)
+	}
+	// Export the state of the head and import it into a fresh database
+	head := chain.CurrentBlock()
+
+	var file bytes.Buffer
+	if err := chain.exportState(&file, head, tomoxdb, tradingRoot, common.Hash{}); err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	var (
+		importdb      = rawdb.NewMemoryDatabase()
+		importtomoxdb = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(importdb)
+
+	exported := file.Bytes()
+	block, err := ImportState(bytes.NewReader(exported), importdb, importtomoxdb)
+	if err != nil {
+		t.Fatalf("failed to import state: %v", err)
+	}
+	if block.Hash() != head.Hash() {
+		t.Fatalf("imported block mismatch: have %x, want %x", block.Hash(), head.Hash())
+	}
+	imported, _ := NewBlockChain(importdb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer imported.Stop()
+
+	if imported.CurrentBlock().Hash() != head.Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", imported.CurrentBlock().Hash(), head.Hash())
+	}
+	statedb, err := state.New(head.Root(), state.NewDatabase(importdb))
+	if err != nil {
+		t.Fatalf("failed to open imported state: %v", err)
+	}
+	want, _ := chain.State()
+	for _, addr := range []common.Address{address, contract, {0x01}, {0x04}} {
+		if have, want := statedb.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("balance mismatch of %x: have %v, want %v", addr, have, want)
+		}
+	}
+	if have := statedb.GetCode(contract); !bytes.Equal(have, code) {
+		t.Errorf("code mismatch: have %x, want %x", have, code)
+	}
+	if have := statedb.GetState(contract, slot); have != common.HexToHash("0x2a") {
+		t.Errorf("storage mismatch: have %x, want 0x2a", have)
+	}
+	importedTrading, err := tradingstate.New(tradingRoot, tradingstate.NewDatabase(importtomoxdb))
+	if err != nil {
+		t.Fatalf("failed to open imported trading state: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		if order := importedTrading.GetOrder(orderBook, common.BigToHash(big.NewInt(int64(i)))); order.OrderID != uint64(i) {
+			t.Errorf("order %d missing", i)
+		}
+	}
+	if _, data := importedTrading.GetHighestLiquidationPriceData(orderBook, common.Big1); len(data[lendingBook]) != 1 {
+		t.Errorf("liquidation data mismatch: have %v", data)
+	}
+	if last := readTomoXCommit(importtomoxdb); last == nil || last.Hash != head.Hash() || last.TradingRoot != tradingRoot {
+		t.Errorf("TomoX journal entry mismatch: have %+v", last)
+	}
+	// A database holding a chain refuses a second import
+	if _, err := ImportState(bytes.NewReader(exported), importdb, importtomoxdb); err != errStateImportChain {
+		t.Errorf("reimport error mismatch: have %v, want %v", err, errStateImportChain)
+	}
+}
@@ -79,6 +79,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, tra
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
+	if p.config.TIPTomoXMakerRebateBlock != nil && p.config.TIPTomoXMakerRebateBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyTomoXMakerRebateFork(statedb)
+	}
 	if common.TIPSigningBlock.Cmp(header.Number) == 0 {
 		statedb.DeleteAddress(common.HexToAddress(common.BlockSigners))
 	}
@@ -152,6 +155,9 @@ func (p *StateProcessor) ProcessBlockNoValidator(cBlock *CalculatedBlock, stated
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
+	if p.config.TIPTomoXMakerRebateBlock != nil && p.config.TIPTomoXMakerRebateBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyTomoXMakerRebateFork(statedb)
+	}
 	if common.TIPSigningBlock.Cmp(header.Number) == 0 {
 		statedb.DeleteAddress(common.HexToAddress(common.BlockSigners))
 	}
@@ -232,6 +238,9 @@ func ApplyTransaction(config *params.ChainConfig, tokensFee map[common.Address]*
 	if tx.To() != nil && tx.To().String() == common.BlockSigners && config.IsTIPSigning(header.Number) {
 		return ApplySignTransaction(config, statedb, header, tx, usedGas)
 	}
+	if tx.IsRotateSignerTransaction() && config.IsTIPKeyRotation(header.Number) {
+		return ApplyRotateSignerTransaction(config, statedb, header, tx, usedGas)
+	}
 	if tx.To() != nil && tx.To().String() == common.TradingStateAddr && config.IsTIPTomoX(header.Number) {
 		return ApplyEmptyTransaction(config, statedb, header, tx, usedGas)
 	}
@@ -252,7 +261,7 @@ func ApplyTransaction(config *params.ChainConfig, tokensFee map[common.Address]*
 			balanceFee = value
 		}
 	}
-	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number), balanceFee, header.Number)
+	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number), balanceFee, header.Number, header.BaseFee)
 	if err != nil {
 		return nil, 0, err, false
 	}
@@ -484,6 +493,57 @@ func ApplySignTransaction(config *params.ChainConfig, statedb *state.StateDB, he
 	return receipt, 0, nil, false
 }
 
+// ApplyRotateSignerTransaction registers the signing key of a candidate in the
+// validator contract, on behalf of the owner of the candidate. The key is
+// refused if it belongs to another candidate, either as its address or as its
+// registered key, and the transaction then only consumes its nonce.
+func ApplyRotateSignerTransaction(config *params.ChainConfig, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64) (*types.Receipt, uint64, error, bool) {
+	// Update the state with pending changes
+	var root []byte
+	if config.IsByzantium(header.Number) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+	}
+	from, err := types.Sender(types.MakeSigner(config, header.Number), tx)
+	if err != nil {
+		return nil, 0, err, false
+	}
+	nonce := statedb.GetNonce(from)
+	if nonce < tx.Nonce() {
+		return nil, 0, ErrNonceTooHigh, false
+	} else if nonce > tx.Nonce() {
+		return nil, 0, ErrNonceTooLow, false
+	}
+	statedb.SetNonce(from, nonce+1)
+
+	data := tx.Data()
+	candidate := common.BytesToAddress(data[4:36])
+	signer := common.BytesToAddress(data[36:68])
+	failed := from != state.GetCandidateOwner(statedb, candidate) || signer == (common.Address{})
+	if !failed && signer != candidate {
+		for _, other := range state.GetCandidates(statedb) {
+			if other == signer || (other != candidate && state.GetCandidateSigner(statedb, other) == signer) {
+				failed = true
+				break
+			}
+		}
+	}
+	if !failed {
+		state.SetCandidateSigner(statedb, candidate, signer)
+	}
+	receipt := types.NewReceipt(root, failed, *usedGas)
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = 0
+	log := &types.Log{}
+	log.Address = *tx.To()
+	log.BlockNumber = header.Number.Uint64()
+	statedb.AddLog(log)
+	receipt.Logs = statedb.GetLogs(tx.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, 0, nil, false
+}
+
 func ApplyEmptyTransaction(config *params.ChainConfig, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64) (*types.Receipt, uint64, error, bool) {
 	// Update the state with pending changes
 	var root []byte
@@ -503,6 +563,12 @@ func ApplyEmptyTransaction(config *params.ChainConfig, statedb *state.StateDB, h
 	log.Address = *tx.To()
 	log.BlockNumber = header.Number.Uint64()
 	statedb.AddLog(log)
+	if config.IsTIPTomoXReceiptLogs(header.Number) {
+		for _, settlement := range statedb.SettlementLogs(tx.Hash()) {
+			settlement.BlockNumber = header.Number.Uint64()
+			statedb.AddLog(settlement)
+		}
+	}
 	receipt.Logs = statedb.GetLogs(tx.Hash())
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 	return receipt, 0, nil, false
@@ -0,0 +1,218 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/exchange"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"github.com/tomochain/tomochain/trie"
+)
+
+const (
+	stateVerifyDepth = 32    // Number of recent blocks the state integrity checks sample from
+	stateVerifyNodes = 10000 // Maximum number of nodes walked per check of a state trie
+)
+
+var (
+	stateVerifyNodeMeter = metrics.NewRegisteredMeter("chain/verify/nodes", nil)
+	stateVerifyFailMeter = metrics.NewRegisteredMeter("chain/verify/failures", nil)
+)
+
+// nestedTries returns the hashes of the tries and the raw blobs referenced by a
+// leaf of a state trie, which are verified along with it.
+type nestedTries func(leaf []byte) (tries []common.Hash, blobs []common.Hash)
+
+// verifyStateLoop periodically checks the integrity of a sample of the recent
+// state, until the blockchain is stopped.
+func (bc *BlockChain) verifyStateLoop() {
+	ticker := time.NewTicker(bc.cacheConfig.StateVerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			bc.verifyRecentState()
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// verifyRecentState picks a random recent block and walks a random range of its
+// account, trading and lending tries, checking that every node is present in
+// the database and matches its hash.
+func (bc *BlockChain) verifyRecentState() {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	head := bc.CurrentBlock().NumberU64()
+	depth := uint64(stateVerifyDepth)
+	if head < depth {
+		depth = head
+	}
+	block := bc.GetBlockByNumber(head - uint64(rand.Int63n(int64(depth)+1)))
+	if block == nil {
+		return
+	}
+	// Blocks without state (e.g. before a fast sync pivot) have nothing to check
+	if _, err := bc.stateCache.OpenTrie(block.Root()); err != nil {
+		return
+	}
+	bc.reportStateCheck("state", block, block.Root(), bc.stateCache.TrieDB(), accountTries)
+
+	engine, ok := bc.Engine().(exchange.Engine)
+	if !ok || !bc.chainConfig.IsTomoXEnabled(block.Number()) {
+		return
+	}
+	author, err := bc.Engine().Author(block.Header())
+	if err != nil {
+		return
+	}
+	if tradingService := engine.GetTomoXService(); tradingService != nil {
+		if root, err := tradingService.GetTradingStateRoot(block, author); err == nil && root != tradingstate.EmptyRoot {
+			bc.reportStateCheck("trading", block, root, tradingService.GetStateCache().TrieDB(), subTries(tradingstate.OrderBookTrieRoots))
+		}
+	}
+	if lendingService := engine.GetLendingService(); lendingService != nil && bc.Config().IsTIPTomoXLending(block.Number()) {
+		if root, err := lendingService.GetLendingStateRoot(block, author); err == nil && root != lendingstate.EmptyRoot {
+			bc.reportStateCheck("lending", block, root, lendingService.GetStateCache().TrieDB(), subTries(lendingstate.LendingBookTrieRoots))
+		}
+	}
+}
+
+// reportStateCheck verifies a random range of the given trie and reports the
+// outcome through the logs and metrics.
+func (bc *BlockChain) reportStateCheck(kind string, block *types.Block, root common.Hash, db *trie.Database, nested nestedTries) {
+	start := make([]byte, common.HashLength)
+	rand.Read(start)
+
+	nodes, err := verifyTrie(db, root, start, stateVerifyNodes, nested)
+	stateVerifyNodeMeter.Mark(int64(nodes))
+	if err != nil {
+		stateVerifyFailMeter.Mark(1)
+		log.Error("State integrity check failed", "kind", kind, "number", block.Number(), "hash", block.Hash(), "root", root, "nodes", nodes, "err", err)
+		return
+	}
+	log.Debug("State integrity check passed", "kind", kind, "number", block.Number(), "root", root, "nodes", nodes)
+}
+
+// verifyTrie walks the trie with the given root from the start key, checking at
+// most limit nodes, then the tries nested in its leaves within the remaining
+// limit. It returns the number of nodes checked.
+func verifyTrie(db *trie.Database, root common.Hash, start []byte, limit int, nested nestedTries) (int, error) {
+	tr, err := trie.New(root, db)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		nodes int
+		tries []common.Hash
+	)
+	it := tr.NodeIterator(start)
+	for nodes < limit && it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if err := verifyNode(db, hash); err != nil {
+				return nodes, err
+			}
+			nodes++
+		}
+		if it.Leaf() && nested != nil {
+			subtries, blobs := nested(it.LeafBlob())
+			for _, hash := range blobs {
+				if err := verifyNode(db, hash); err != nil {
+					return nodes, err
+				}
+			}
+			tries = append(tries, subtries...)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nodes, err
+	}
+	for _, root := range tries {
+		if nodes >= limit {
+			break
+		}
+		n, err := verifyTrie(db, root, nil, limit-nodes, nil)
+		if nodes += n; err != nil {
+			return nodes, fmt.Errorf("trie %x: %v", root, err)
+		}
+	}
+	return nodes, nil
+}
+
+// verifyNode checks that the node or blob with the given hash is present in the
+// database and that its content matches the hash.
+func verifyNode(db *trie.Database, hash common.Hash) error {
+	blob, err := db.Node(hash)
+	if err != nil {
+		return fmt.Errorf("missing node %x: %v", hash, err)
+	}
+	if have := crypto.Keccak256Hash(blob); have != hash {
+		return fmt.Errorf("corrupt node %x: content hashes to %x", hash, have)
+	}
+	return nil
+}
+
+// accountTries returns the storage trie and the code referenced by an account.
+func accountTries(leaf []byte) ([]common.Hash, []common.Hash) {
+	var (
+		account state.Account
+		tries   []common.Hash
+		blobs   []common.Hash
+	)
+	if err := rlp.DecodeBytes(leaf, &account); err != nil {
+		return nil, nil
+	}
+	if account.Root != types.EmptyRootHash {
+		tries = append(tries, account.Root)
+	}
+	if code := common.BytesToHash(account.CodeHash); code != crypto.Keccak256Hash(nil) {
+		blobs = append(blobs, code)
+	}
+	return tries, blobs
+}
+
+// subTries adapts a decoder of the trie roots held by the leaves of a TomoX
+// state trie, skipping the empty tries.
+func subTries(decode func(leaf []byte) ([]common.Hash, error)) nestedTries {
+	return func(leaf []byte) ([]common.Hash, []common.Hash) {
+		roots, err := decode(leaf)
+		if err != nil {
+			return nil, nil
+		}
+		var tries []common.Hash
+		for _, root := range roots {
+			if root != tradingstate.EmptyRoot && root != (common.Hash{}) {
+				tries = append(tries, root)
+			}
+		}
+		return tries, nil
+	}
+}
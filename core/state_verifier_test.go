@@ -0,0 +1,83 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// Tests that the state verifier walks the account trie along with the storage
+// tries and code, and detects missing and corrupt nodes.
+func TestVerifyTrie(t *testing.T) {
+	diskdb := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(diskdb))
+
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000100")
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	statedb.SetCode(contract, code)
+	for i := 0; i < 64; i++ {
+		statedb.SetState(contract, common.BigToHash(big.NewInt(int64(i))), common.BigToHash(big.NewInt(int64(i+1))))
+	}
+	for i := 0; i < 64; i++ {
+		statedb.AddBalance(common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(1))
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	nodes, err := verifyTrie(trie.NewDatabase(diskdb), root, nil, stateVerifyNodes, accountTries)
+	if err != nil {
+		t.Fatalf("failed to verify intact state: %v", err)
+	}
+	if nodes == 0 {
+		t.Fatalf("no nodes verified")
+	}
+	// A limit stops the walk early without reporting a failure
+	if n, err := verifyTrie(trie.NewDatabase(diskdb), root, nil, 3, accountTries); err != nil || n != 3 {
+		t.Fatalf("limited walk mismatch: have %d nodes, %v, want 3 nodes", n, err)
+	}
+	// Corrupt code is detected through the account referencing it
+	codeHash := crypto.Keccak256Hash(code)
+	diskdb.Put(codeHash[:], []byte{0x00})
+	if _, err := verifyTrie(trie.NewDatabase(diskdb), root, nil, stateVerifyNodes, accountTries); err == nil {
+		t.Fatalf("corrupt code not detected")
+	}
+	diskdb.Put(codeHash[:], code)
+
+	// A missing storage trie root is detected through the account referencing it
+	storageRoot := statedb.StorageTrie(contract).Hash()
+	blob, _ := diskdb.Get(storageRoot[:])
+	diskdb.Delete(storageRoot[:])
+	if _, err := verifyTrie(trie.NewDatabase(diskdb), root, nil, stateVerifyNodes, accountTries); err == nil {
+		t.Fatalf("missing storage root not detected")
+	}
+	diskdb.Put(storageRoot[:], blob)
+
+	if _, err := verifyTrie(trie.NewDatabase(diskdb), root, nil, stateVerifyNodes, accountTries); err != nil {
+		t.Fatalf("failed to verify restored state: %v", err)
+	}
+}
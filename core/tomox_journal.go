@@ -0,0 +1,90 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/exchange"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// tomoXCommitKey tracks the last block whose TomoX tries were flushed to the
+// TomoX database.
+var tomoXCommitKey = []byte("TomoXLastCommit")
+
+// tomoXCommit is the journal entry of a flush of the TomoX tries, written in the
+// same batch as the trie nodes. As the TomoX and chain databases are written
+// separately, it tells after an unclean shutdown which block the TomoX state on
+// disk belongs to, however far the chain database got.
+type tomoXCommit struct {
+	Number      uint64
+	Hash        common.Hash
+	TradingRoot common.Hash
+	LendingRoot common.Hash
+}
+
+// readTomoXCommit retrieves the journal entry of the last TomoX trie flush, nil
+// if none was recorded.
+func readTomoXCommit(db ethdb.KeyValueReader) *tomoXCommit {
+	data, _ := db.Get(tomoXCommitKey)
+	if len(data) == 0 {
+		return nil
+	}
+	commit := new(tomoXCommit)
+	if err := rlp.DecodeBytes(data, commit); err != nil {
+		log.Error("Invalid TomoX commit journal entry", "err", err)
+		return nil
+	}
+	return commit
+}
+
+// writeTomoXCommit stores the journal entry of a TomoX trie flush.
+func writeTomoXCommit(db ethdb.KeyValueWriter, commit *tomoXCommit) error {
+	data, err := rlp.EncodeToBytes(commit)
+	if err != nil {
+		return err
+	}
+	return db.Put(tomoXCommitKey, data)
+}
+
+// rollbackToTomoXCommit moves a head whose state is incomplete back to the last
+// block whose TomoX tries were flushed, if that block is canonical. None of the
+// blocks above it can have their TomoX state on disk, so this skips checking
+// them one by one. The chain state of the block is checked by repair.
+func (bc *BlockChain) rollbackToTomoXCommit(head **types.Block) {
+	engine, ok := bc.Engine().(exchange.Engine)
+	if !ok || common.Rewound != uint64(0) {
+		return
+	}
+	tradingService := engine.GetTomoXService()
+	if tradingService == nil || tradingService.GetStateCache() == nil {
+		return
+	}
+	commit := readTomoXCommit(tradingService.GetStateCache().TrieDB().DiskDB())
+	if commit == nil || commit.Number >= (*head).NumberU64() || GetCanonicalHash(bc.db, commit.Number) != commit.Hash {
+		return
+	}
+	block := bc.GetBlock(commit.Hash, commit.Number)
+	if block == nil {
+		return
+	}
+	log.Warn("Rolling back to the last TomoX commit", "number", commit.Number, "hash", commit.Hash, "head", (*head).Number())
+	*head = block
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Tests that flushing the TomoX tries journals the most recent block flushed,
+// along with the trie nodes.
+func TestTomoXCommitJournal(t *testing.T) {
+	var (
+		diskdb     = rawdb.NewMemoryDatabase()
+		stateCache = tradingstate.NewDatabase(diskdb)
+		orderBook  = common.StringToHash("BTC/TOMO")
+	)
+	commit := func(number int64, orders int) common.Hash {
+		statedb, _ := tradingstate.New(common.Hash{}, stateCache)
+		for i := 1; i <= orders; i++ {
+			order := tradingstate.OrderItem{OrderID: uint64(i), Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(i)), Side: tradingstate.Ask, Signature: &tradingstate.Signature{V: 1}}
+			statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(int64(i))), order)
+		}
+		root, err := statedb.Commit()
+		if err != nil {
+			t.Fatalf("failed to commit trading state: %v", err)
+		}
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})
+		if err := commitTomoXTries(block, stateCache.TrieDB(), root, nil, common.Hash{}); err != nil {
+			t.Fatalf("failed to flush TomoX tries: %v", err)
+		}
+		return root
+	}
+	if readTomoXCommit(diskdb) != nil {
+		t.Fatalf("journal entry present before any flush")
+	}
+	root := commit(10, 2)
+	if ok, _ := diskdb.Has(root[:]); !ok {
+		t.Fatalf("trading root not flushed")
+	}
+	if last := readTomoXCommit(diskdb); last == nil || last.Number != 10 || last.TradingRoot != root {
+		t.Fatalf("journal entry mismatch: have %+v, want block 10 root %x", last, root)
+	}
+	// Flushing an older block, as done on shutdown, keeps the most recent entry
+	commit(5, 1)
+	if last := readTomoXCommit(diskdb); last == nil || last.Number != 10 {
+		t.Fatalf("journal entry mismatch: have %+v, want block 10", last)
+	}
+	commit(11, 3)
+	if last := readTomoXCommit(diskdb); last == nil || last.Number != 11 {
+		t.Fatalf("journal entry mismatch: have %+v, want block 11", last)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// The events logged by the receipts of the trading and lending transactions
+// since TIPTomoXReceiptLogs, encoded like the events of a contract at the
+// address the transactions are sent to. The first three addresses are indexed.
+var (
+	// TradeMatched(baseToken, quoteToken, taker, maker, takerOrderHash, makerOrderHash, price, quantity)
+	TradeMatchedTopic = crypto.Keccak256Hash([]byte("TradeMatched(address,address,address,address,bytes32,bytes32,uint256,uint256)"))
+	// OrderCancelled(baseToken, quoteToken, user, orderHash, orderId)
+	OrderCancelledTopic = crypto.Keccak256Hash([]byte("OrderCancelled(address,address,address,bytes32,uint256)"))
+	// LoanOpened(lendingToken, collateralToken, borrower, investor, tradeHash, tradeId, amount, interest, term)
+	LoanOpenedTopic = crypto.Keccak256Hash([]byte("LoanOpened(address,address,address,address,bytes32,uint256,uint256,uint256,uint256)"))
+	// LoanLiquidated(lendingToken, collateralToken, borrower, investor, tradeHash, tradeId)
+	LoanLiquidatedTopic = crypto.Keccak256Hash([]byte("LoanLiquidated(address,address,address,address,bytes32,uint256)"))
+)
+
+// newSettlementLog creates the log of an event, its data being the given words.
+func newSettlementLog(address common.Address, topics []common.Hash, words ...common.Hash) *types.Log {
+	data := make([]byte, 0, len(words)*common.HashLength)
+	for _, word := range words {
+		data = append(data, word.Bytes()...)
+	}
+	return &types.Log{Address: address, Topics: topics, Data: data}
+}
+
+func addressWord(addr common.Address) common.Hash {
+	return common.BytesToHash(addr.Bytes())
+}
+
+func uintWord(n uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(n))
+}
+
+// TradingLogs returns the logs of the trades and cancellations settled by the
+// orders of a trading transaction, replaced orders included, in the order of
+// the transaction data, given the matching results of the orders.
+func TradingLogs(txMatches []tradingstate.TxDataMatch, results map[common.Hash]tradingstate.MatchingResult) []*types.Log {
+	var (
+		address = common.HexToAddress(common.TomoXAddr)
+		logs    []*types.Log
+	)
+	for _, txMatch := range txMatches {
+		order, err := txMatch.DecodeOrder()
+		if err != nil {
+			continue
+		}
+		result, ok := results[tradingstate.GetMatchingResultCacheKey(order)]
+		if !ok {
+			continue
+		}
+		if order.Status == tradingstate.OrderStatusCancelled || len(order.Cancels) > 0 {
+			// a rejected cancellation cancels nothing, batches being atomic
+			if len(result.Rejects) > 0 {
+				continue
+			}
+			cancels := order.Cancels
+			if len(cancels) == 0 {
+				cancels = []types.OrderCancel{{OrderID: order.OrderID, Hash: order.Hash}}
+			}
+			for _, cancel := range cancels {
+				logs = append(logs, newSettlementLog(address, tradingTopics(OrderCancelledTopic, order), cancel.Hash, uintWord(cancel.OrderID)))
+			}
+			continue
+		}
+		// the order replaced by a replacement is returned cancelled among its rejects
+		for _, reject := range result.Rejects {
+			if order.IsReplacement() && reject.OrderID == order.ReplacedOrderID && reject.Hash != order.Hash {
+				logs = append(logs, newSettlementLog(address, tradingTopics(OrderCancelledTopic, order), reject.Hash, uintWord(reject.OrderID)))
+			}
+		}
+		for _, trade := range result.Trades {
+			topics := tradingTopics(TradeMatchedTopic, order)
+			// the trades of a routed order are on the pairs of its tokens against TOMO
+			if baseToken, ok := trade[tradingstate.TradeBaseToken]; ok {
+				topics[1], topics[2] = addressWord(common.HexToAddress(baseToken)), addressWord(common.HexToAddress(trade[tradingstate.TradeQuoteToken]))
+			}
+			logs = append(logs, newSettlementLog(address, topics,
+				addressWord(common.HexToAddress(trade[tradingstate.TradeMaker])),
+				common.HexToHash(trade[tradingstate.TradeTakerOrderHash]),
+				common.HexToHash(trade[tradingstate.TradeMakerOrderHash]),
+				common.BigToHash(tradingstate.ToBigInt(trade[tradingstate.TradePrice])),
+				common.BigToHash(tradingstate.ToBigInt(trade[tradingstate.TradeQuantity])),
+			))
+		}
+	}
+	return logs
+}
+
+// LendingLogs returns the logs of the loans opened by the items of a lending
+// transaction, in the order of the transaction data, given the matching results
+// of the items.
+func LendingLogs(items []*lendingstate.LendingItem, results map[common.Hash]lendingstate.MatchingResult) []*types.Log {
+	var (
+		address = common.HexToAddress(common.TomoXLendingAddress)
+		logs    []*types.Log
+	)
+	for _, item := range items {
+		result, ok := results[lendingstate.GetLendingCacheKey(item)]
+		if !ok {
+			continue
+		}
+		for _, trade := range result.Trades {
+			logs = append(logs, newSettlementLog(address, lendingTopics(LoanOpenedTopic, trade),
+				addressWord(trade.Investor),
+				trade.Hash,
+				uintWord(trade.TradeId),
+				common.BigToHash(trade.Amount),
+				uintWord(trade.Interest),
+				uintWord(trade.Term),
+			))
+		}
+	}
+	return logs
+}
+
+// LiquidationLogs returns the logs of the loans liquidated by a lending
+// finalized trade transaction.
+func LiquidationLogs(liquidated []*lendingstate.LendingTrade) []*types.Log {
+	var (
+		address = common.HexToAddress(common.TomoXLendingFinalizedTradeAddress)
+		logs    []*types.Log
+	)
+	for _, trade := range liquidated {
+		logs = append(logs, newSettlementLog(address, lendingTopics(LoanLiquidatedTopic, trade),
+			addressWord(trade.Investor),
+			trade.Hash,
+			uintWord(trade.TradeId),
+		))
+	}
+	return logs
+}
+
+func tradingTopics(event common.Hash, order *tradingstate.OrderItem) []common.Hash {
+	return []common.Hash{event, addressWord(order.BaseToken), addressWord(order.QuoteToken), addressWord(order.UserAddress)}
+}
+
+func lendingTopics(event common.Hash, trade *lendingstate.LendingTrade) []common.Hash {
+	return []common.Hash{event, addressWord(trade.LendingToken), addressWord(trade.CollateralToken), addressWord(trade.Borrower)}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+var (
+	logBaseToken  = common.HexToAddress("0x0000000000000000000000000000000000000011")
+	logQuoteToken = common.HexToAddress("0x0000000000000000000000000000000000000022")
+)
+
+func testTxMatch(t *testing.T, order *tradingstate.OrderItem) tradingstate.TxDataMatch {
+	order.Signature = &tradingstate.Signature{V: 1}
+	enc, err := tradingstate.EncodeBytesItem(order)
+	if err != nil {
+		t.Fatalf("failed to encode order: %v", err)
+	}
+	return tradingstate.TxDataMatch{Order: enc}
+}
+
+func TestTradingLogs(t *testing.T) {
+	var (
+		taker, maker = common.HexToAddress("0x01"), common.HexToAddress("0x02")
+		limit        = &tradingstate.OrderItem{UserAddress: taker, Nonce: big.NewInt(1), BaseToken: logBaseToken, QuoteToken: logQuoteToken, Hash: common.HexToHash("0xa1")}
+		cancel       = &tradingstate.OrderItem{UserAddress: taker, Nonce: big.NewInt(2), BaseToken: logBaseToken, QuoteToken: logQuoteToken, Hash: common.HexToHash("0xa2"), OrderID: 7, Status: tradingstate.OrderStatusCancelled}
+		batch        = &tradingstate.OrderItem{UserAddress: taker, Nonce: big.NewInt(3), BaseToken: logBaseToken, QuoteToken: logQuoteToken, Cancels: []types.OrderCancel{{OrderID: 8, Hash: common.HexToHash("0xa3")}, {OrderID: 9, Hash: common.HexToHash("0xa4")}}}
+		rejected     = &tradingstate.OrderItem{UserAddress: taker, Nonce: big.NewInt(4), BaseToken: logBaseToken, QuoteToken: logQuoteToken, Hash: common.HexToHash("0xa5"), Status: tradingstate.OrderStatusCancelled}
+	)
+	trade := map[string]string{
+		tradingstate.TradeMaker:          maker.Hex(),
+		tradingstate.TradeTakerOrderHash: limit.Hash.Hex(),
+		tradingstate.TradeMakerOrderHash: common.HexToHash("0xb1").Hex(),
+		tradingstate.TradePrice:          "100",
+		tradingstate.TradeQuantity:       "5",
+	}
+	results := map[common.Hash]tradingstate.MatchingResult{
+		tradingstate.GetMatchingResultCacheKey(limit):    {Trades: []map[string]string{trade, trade}},
+		tradingstate.GetMatchingResultCacheKey(cancel):   {},
+		tradingstate.GetMatchingResultCacheKey(batch):    {},
+		tradingstate.GetMatchingResultCacheKey(rejected): {Rejects: []*tradingstate.OrderItem{rejected}},
+	}
+	txMatches := []tradingstate.TxDataMatch{testTxMatch(t, limit), testTxMatch(t, cancel), testTxMatch(t, batch), testTxMatch(t, rejected)}
+
+	logs := TradingLogs(txMatches, results)
+	if len(logs) != 5 {
+		t.Fatalf("log count mismatch: have %d, want 5", len(logs))
+	}
+	wantTopics := []common.Hash{TradeMatchedTopic, TradeMatchedTopic, OrderCancelledTopic, OrderCancelledTopic, OrderCancelledTopic}
+	for i, log := range logs {
+		if log.Address != common.HexToAddress(common.TomoXAddr) {
+			t.Errorf("log %d: address mismatch: have %x", i, log.Address)
+		}
+		if len(log.Topics) != 4 || log.Topics[0] != wantTopics[i] || log.Topics[1] != addressWord(logBaseToken) || log.Topics[3] != addressWord(taker) {
+			t.Errorf("log %d: topics mismatch: have %x", i, log.Topics)
+		}
+	}
+	if len(logs[0].Data) != 5*common.HashLength || common.BytesToAddress(logs[0].Data[:32]) != maker || new(big.Int).SetBytes(logs[0].Data[96:128]).Int64() != 100 || new(big.Int).SetBytes(logs[0].Data[128:]).Int64() != 5 {
+		t.Errorf("trade data mismatch: have %x", logs[0].Data)
+	}
+	for i, want := range []uint64{7, 8, 9} {
+		if id := new(big.Int).SetBytes(logs[2+i].Data[32:]).Uint64(); id != want {
+			t.Errorf("cancellation %d: order id mismatch: have %d, want %d", i, id, want)
+		}
+	}
+	// The trades of a routed order are logged on their own pair
+	hop := map[string]string{tradingstate.TradeBaseToken: logQuoteToken.Hex(), tradingstate.TradeQuoteToken: common.TomoNativeAddress}
+	for k, v := range trade {
+		hop[k] = v
+	}
+	logs = TradingLogs(txMatches[:1], map[common.Hash]tradingstate.MatchingResult{
+		tradingstate.GetMatchingResultCacheKey(limit): {Trades: []map[string]string{hop}},
+	})
+	if len(logs) != 1 || logs[0].Topics[1] != addressWord(logQuoteToken) || logs[0].Topics[2] != addressWord(common.HexToAddress(common.TomoNativeAddress)) {
+		t.Errorf("routed trade topics mismatch: have %v", logs)
+	}
+	// The order replaced by a replacement is logged cancelled
+	replacement := &tradingstate.OrderItem{UserAddress: taker, Nonce: big.NewInt(5), BaseToken: logBaseToken, QuoteToken: logQuoteToken, Hash: common.HexToHash("0xa6"), OrderID: 7, ReplacedOrderID: 7}
+	logs = TradingLogs([]tradingstate.TxDataMatch{testTxMatch(t, replacement)}, map[common.Hash]tradingstate.MatchingResult{
+		tradingstate.GetMatchingResultCacheKey(replacement): {Rejects: []*tradingstate.OrderItem{{OrderID: 7, Hash: cancel.Hash, Status: tradingstate.OrderStatusCancelled}}},
+	})
+	if len(logs) != 1 || logs[0].Topics[0] != OrderCancelledTopic || common.BytesToHash(logs[0].Data[:32]) != cancel.Hash {
+		t.Errorf("replaced order logs mismatch: have %v", logs)
+	}
+}
+
+func TestLendingLogs(t *testing.T) {
+	var (
+		borrower = common.HexToAddress("0x01")
+		item     = &lendingstate.LendingItem{UserAddress: borrower, Nonce: big.NewInt(1)}
+		trade    = &lendingstate.LendingTrade{Borrower: borrower, Investor: common.HexToAddress("0x02"), LendingToken: logQuoteToken, CollateralToken: logBaseToken, Amount: big.NewInt(1000), Interest: 5, Term: 86400, TradeId: 3, Hash: common.HexToHash("0xc1")}
+	)
+	logs := LendingLogs([]*lendingstate.LendingItem{item}, map[common.Hash]lendingstate.MatchingResult{
+		lendingstate.GetLendingCacheKey(item): {Trades: []*lendingstate.LendingTrade{trade}},
+	})
+	if len(logs) != 1 || logs[0].Topics[0] != LoanOpenedTopic || logs[0].Topics[3] != addressWord(borrower) || len(logs[0].Data) != 6*common.HashLength {
+		t.Fatalf("loan logs mismatch: have %v", logs)
+	}
+	if amount := new(big.Int).SetBytes(logs[0].Data[96:128]); amount.Int64() != 1000 {
+		t.Errorf("loan amount mismatch: have %v, want 1000", amount)
+	}
+	logs = LiquidationLogs([]*lendingstate.LendingTrade{trade})
+	if len(logs) != 1 || logs[0].Topics[0] != LoanLiquidatedTopic || logs[0].Address != common.HexToAddress(common.TomoXLendingFinalizedTradeAddress) {
+		t.Fatalf("liquidation logs mismatch: have %v", logs)
+	}
+}
+
+// Tests that the settlement logs are added to the receipts of the special
+// transactions from the TIPTomoXReceiptLogs fork on.
+func TestSettlementReceiptLogs(t *testing.T) {
+	config := *params.TestChainConfig
+	config.TIPTomoXReceiptLogsBlock = big.NewInt(10)
+
+	tx := types.NewTransaction(0, common.HexToAddress(common.TomoXAddr), big.NewInt(0), 0, big.NewInt(0), nil)
+	for _, test := range []struct {
+		number int64
+		logs   int
+	}{{9, 1}, {10, 3}} {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+		statedb.SetSettlementLogs(tx.Hash(), []*types.Log{{Topics: []common.Hash{TradeMatchedTopic}}, {Topics: []common.Hash{OrderCancelledTopic}}})
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		receipt, _, err, _ := ApplyEmptyTransaction(&config, statedb, &types.Header{Number: big.NewInt(test.number)}, tx, new(uint64))
+		if err != nil {
+			t.Fatalf("block %d: failed to apply: %v", test.number, err)
+		}
+		if len(receipt.Logs) != test.logs {
+			t.Errorf("block %d: receipt log count mismatch: have %d, want %d", test.number, len(receipt.Logs), test.logs)
+		}
+		if test.logs > 1 && (receipt.Logs[1].Index != 1 || receipt.Logs[1].BlockNumber != uint64(test.number) || !types.BloomLookup(receipt.Bloom, TradeMatchedTopic)) {
+			t.Errorf("block %d: settlement log mismatch: have %+v", test.number, receipt.Logs[1])
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// TomoXMigration is the outcome of migrations of the trading state of an epoch
+// checkpoint.
+type TomoXMigration struct {
+	Epoch        uint64                       `json:"epoch"`
+	Number       uint64                       `json:"number"`
+	Hash         common.Hash                  `json:"hash"`
+	TradingRoot  common.Hash                  `json:"tradingRoot"`  // root of the checkpoint
+	MigratedRoot common.Hash                  `json:"migratedRoot"` // root once migrated
+	Migrations   []string                     `json:"migrations"`
+	OrderBooks   []tradingstate.OrderBookDiff `json:"orderBooks"` // order books changed by the migrations
+}
+
+// MigrateTomoX applies the migrations to the trading state, kept in tomoxdb, of
+// the checkpoint block of the given epoch. The chain is left untouched: if
+// commit is set, the migrated tries are only written into tomoxdb, for the hard
+// fork applying the same migrations to find them.
+func (bc *BlockChain) MigrateTomoX(epoch uint64, tomoxdb ethdb.Database, commit bool, migrations ...tradingstate.Migration) (*TomoXMigration, error) {
+	if bc.chainConfig.Posv == nil || bc.chainConfig.Posv.Epoch == 0 {
+		return nil, errNoEpoch
+	}
+	block := bc.GetBlockByNumber(bc.chainConfig.Posv.EpochCheckpoint(epoch))
+	if block == nil {
+		return nil, fmt.Errorf("checkpoint block of epoch %d not found", epoch)
+	}
+	tradingRoot, _ := bc.tomoXStateRoots(block)
+	if tradingRoot == (common.Hash{}) {
+		return nil, fmt.Errorf("block #%d has no trading state", block.NumberU64())
+	}
+	stateCache := tradingstate.NewDatabase(tomoxdb)
+	tradingState, err := tradingstate.New(tradingRoot, stateCache)
+	if err != nil {
+		return nil, fmt.Errorf("trading state of block #%d not available: %v", block.NumberU64(), err)
+	}
+	tradingState.SetPruning(bc.chainConfig.IsTIPTomoXPrune(block.Number()))
+	migrated, diffs, err := tradingstate.Migrate(tradingState, migrations...)
+	if err != nil {
+		return nil, err
+	}
+	result := &TomoXMigration{
+		Epoch:       epoch,
+		Number:      block.NumberU64(),
+		Hash:        block.Hash(),
+		TradingRoot: tradingRoot,
+		Migrations:  []string{},
+		OrderBooks:  diffs,
+	}
+	for _, migration := range migrations {
+		result.Migrations = append(result.Migrations, migration.Name)
+	}
+	if !commit {
+		result.MigratedRoot = migrated.IntermediateRoot()
+		return result, nil
+	}
+	if result.MigratedRoot, err = migrated.Commit(); err != nil {
+		return nil, err
+	}
+	if err := stateCache.TrieDB().Commit(result.MigratedRoot, false); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
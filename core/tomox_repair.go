@@ -0,0 +1,129 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// errNoConsistentTomoXBlock is returned if no block down to the genesis has
+// its chain and TomoX state complete.
+var errNoConsistentTomoXBlock = errors.New("no block with a consistent state found")
+
+// tomoXRootVerifier fully verifies TomoX state tries down to their innermost
+// tries, remembering the outcome for the roots shared by consecutive blocks.
+type tomoXRootVerifier struct {
+	db       *trie.Database
+	verified map[common.Hash]error
+}
+
+func newTomoXRootVerifier(tomoxdb ethdb.Database) *tomoXRootVerifier {
+	return &tomoXRootVerifier{db: trie.NewDatabase(tomoxdb), verified: make(map[common.Hash]error)}
+}
+
+// verify walks every node of the trie with the given root and of the tries
+// nested in its leaves as described by the schema, a zero hash meaning that
+// there is no trie to check.
+func (v *tomoXRootVerifier) verify(root common.Hash, schema trieSchema) error {
+	if root == (common.Hash{}) {
+		return nil
+	}
+	if err, ok := v.verified[root]; ok {
+		return err
+	}
+	err := v.verifyTrie(root, schema)
+	v.verified[root] = err
+	return err
+}
+
+func (v *tomoXRootVerifier) verifyTrie(root common.Hash, schema trieSchema) error {
+	tr, err := trie.New(root, v.db)
+	if err != nil {
+		return err
+	}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if err := verifyNode(v.db, hash); err != nil {
+				return err
+			}
+		}
+		if !it.Leaf() || schema == nil {
+			continue
+		}
+		tries, blobs := schema(it.LeafBlob())
+		for _, hash := range blobs {
+			if err := verifyNode(v.db, hash); err != nil {
+				return err
+			}
+		}
+		for _, nested := range tries {
+			if err := v.verifyTrie(nested.root, nested.schema); err != nil {
+				return fmt.Errorf("trie %x: %v", nested.root, err)
+			}
+		}
+	}
+	return it.Error()
+}
+
+// LastConsistentTomoXBlock walks the canonical chain down from the given block
+// and returns the first block whose chain state is present and whose trading
+// and lending tries are complete in tomoxdb.
+func (bc *BlockChain) LastConsistentTomoXBlock(tomoxdb ethdb.Database, from *types.Block) (*types.Block, error) {
+	verifier := newTomoXRootVerifier(tomoxdb)
+	for block := from; block != nil; block = bc.GetBlock(block.ParentHash(), block.NumberU64()-1) {
+		if GetCanonicalHash(bc.db, block.NumberU64()) != block.Hash() {
+			return nil, fmt.Errorf("block #%d [%x…] not canonical", block.NumberU64(), block.Hash().Bytes()[:4])
+		}
+		if _, err := bc.stateCache.OpenTrie(block.Root()); err != nil {
+			log.Warn("Skipping block without chain state", "number", block.Number(), "hash", block.Hash())
+			continue
+		}
+		trading, lending := bc.tomoXStateRoots(block)
+		if err := verifier.verify(trading, tradingSchema); err != nil {
+			log.Warn("Skipping block with a corrupt trading state", "number", block.Number(), "hash", block.Hash(), "root", trading, "err", err)
+			continue
+		}
+		if err := verifier.verify(lending, lendingSchema); err != nil {
+			log.Warn("Skipping block with a corrupt lending state", "number", block.Number(), "hash", block.Hash(), "root", lending, "err", err)
+			continue
+		}
+		return block, nil
+	}
+	return nil, errNoConsistentTomoXBlock
+}
+
+// RewindTomoX rolls the chain and the TomoX databases back to the given block,
+// journaling it as the last TomoX commit so that the blocks above are processed
+// again from its state on the next start.
+func (bc *BlockChain) RewindTomoX(tomoxdb ethdb.Database, block *types.Block) error {
+	trading, lending := bc.tomoXStateRoots(block)
+	if err := writeTomoXCommit(tomoxdb, &tomoXCommit{Number: block.NumberU64(), Hash: block.Hash(), TradingRoot: trading, LendingRoot: lending}); err != nil {
+		return err
+	}
+	if block.NumberU64() >= bc.CurrentBlock().NumberU64() {
+		return nil
+	}
+	return bc.SetHead(block.NumberU64())
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Tests that the TomoX roots are verified down to the order tries, and that a
+// missing node makes the trie inconsistent.
+func TestTomoXRootVerifier(t *testing.T) {
+	var (
+		diskdb     = rawdb.NewMemoryDatabase()
+		stateCache = tradingstate.NewDatabase(diskdb)
+		orderBook  = common.StringToHash("BTC/TOMO")
+	)
+	statedb, _ := tradingstate.New(common.Hash{}, stateCache)
+	for i := 1; i <= 20; i++ {
+		order := tradingstate.OrderItem{OrderID: uint64(i), Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(i)), Side: tradingstate.Ask, Signature: &tradingstate.Signature{V: 1}}
+		statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(int64(i))), order)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	if err := stateCache.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush trading state: %v", err)
+	}
+	if err := newTomoXRootVerifier(diskdb).verify(root, tradingSchema); err != nil {
+		t.Fatalf("complete trading state reported inconsistent: %v", err)
+	}
+	// Drop a node of a nested trie, leaving the root in place
+	var dropped bool
+	it := diskdb.NewIterator(nil, nil)
+	for it.Next() {
+		if key := it.Key(); len(key) == common.HashLength && common.BytesToHash(key) != root {
+			diskdb.Delete(common.CopyBytes(key))
+			dropped = true
+			break
+		}
+	}
+	it.Release()
+	if !dropped {
+		t.Fatalf("no trie node to drop")
+	}
+	verifier := newTomoXRootVerifier(diskdb)
+	if err := verifier.verify(root, tradingSchema); err == nil {
+		t.Fatalf("corrupt trading state reported consistent")
+	}
+	if _, ok := verifier.verified[root]; !ok {
+		t.Errorf("verification outcome not remembered")
+	}
+	if err := verifier.verify(common.Hash{}, tradingSchema); err != nil {
+		t.Errorf("missing trie reported inconsistent: %v", err)
+	}
+}
+
+// Tests that repairing the TomoX state rewinds the chain and journals the block
+// rolled back to.
+func TestRewindTomoX(t *testing.T) {
+	_, blockchain, err := newCanonical(ethash.NewFaker(), 10, true)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	tomoxdb := rawdb.NewMemoryDatabase()
+	head := blockchain.CurrentBlock()
+	block, err := blockchain.LastConsistentTomoXBlock(tomoxdb, head)
+	if err != nil || block.Hash() != head.Hash() {
+		t.Fatalf("consistent block mismatch: have %v, %v, want head %x", block, err, head.Hash())
+	}
+	target := blockchain.GetBlockByNumber(5)
+	if err := blockchain.RewindTomoX(tomoxdb, target); err != nil {
+		t.Fatalf("failed to rewind: %v", err)
+	}
+	if have := blockchain.CurrentBlock(); have.Hash() != target.Hash() {
+		t.Errorf("head mismatch: have #%d, want #5", have.NumberU64())
+	}
+	if commit := readTomoXCommit(tomoxdb); commit == nil || commit.Number != 5 || commit.Hash != target.Hash() {
+		t.Errorf("journal entry mismatch: have %+v, want block 5", commit)
+	}
+	if GetCanonicalHash(blockchain.db, 6) != (common.Hash{}) {
+		t.Errorf("blocks above the repaired one left canonical")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// TomoXSnapshotVersion is the version of the schema of the TomoX snapshots. It is
+// bumped on every change of the schema which isn't backward compatible.
+const TomoXSnapshotVersion = 1
+
+var errNoEpoch = errors.New("chain has no epoch")
+
+// TomoXSnapshot is the content of the TomoX order and lending books at an epoch
+// checkpoint, from which relayers can rebuild their off-chain database.
+type TomoXSnapshot struct {
+	Version      uint64                              `json:"version"`
+	Epoch        uint64                              `json:"epoch"`
+	Number       uint64                              `json:"number"`
+	Hash         common.Hash                         `json:"hash"`
+	TradingRoot  common.Hash                         `json:"tradingRoot"`
+	LendingRoot  common.Hash                         `json:"lendingRoot"`
+	OrderBooks   []*tradingstate.OrderBookSnapshot   `json:"orderBooks"`
+	LendingBooks []*lendingstate.LendingBookSnapshot `json:"lendingBooks"`
+}
+
+// NewTomoXSnapshot collects the content of the given trading and lending states
+// of a block. Either state may be nil if the block has none.
+func NewTomoXSnapshot(block *types.Block, epoch uint64, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (*TomoXSnapshot, error) {
+	snapshot := &TomoXSnapshot{
+		Version:      TomoXSnapshotVersion,
+		Epoch:        epoch,
+		Number:       block.NumberU64(),
+		Hash:         block.Hash(),
+		OrderBooks:   []*tradingstate.OrderBookSnapshot{},
+		LendingBooks: []*lendingstate.LendingBookSnapshot{},
+	}
+	if tradingState != nil {
+		snapshot.TradingRoot = tradingState.IntermediateRoot()
+		for _, orderBook := range tradingState.GetOrderBookHashes() {
+			book, err := tradingState.SnapshotOrderBook(orderBook)
+			if err != nil {
+				return nil, err
+			}
+			snapshot.OrderBooks = append(snapshot.OrderBooks, book)
+		}
+	}
+	if lendingState != nil {
+		snapshot.LendingRoot = lendingState.IntermediateRoot()
+		for _, lendingBook := range lendingState.GetLendingBookHashes() {
+			book, err := lendingState.SnapshotLendingBook(lendingBook)
+			if err != nil {
+				return nil, err
+			}
+			snapshot.LendingBooks = append(snapshot.LendingBooks, book)
+		}
+	}
+	return snapshot, nil
+}
+
+// TomoXSnapshot collects the content of the TomoX order and lending books, kept
+// in tomoxdb, at the checkpoint block of the given epoch.
+func (bc *BlockChain) TomoXSnapshot(epoch uint64, tomoxdb ethdb.Database) (*TomoXSnapshot, error) {
+	if bc.chainConfig.Posv == nil || bc.chainConfig.Posv.Epoch == 0 {
+		return nil, errNoEpoch
+	}
+	block := bc.GetBlockByNumber(bc.chainConfig.Posv.EpochCheckpoint(epoch))
+	if block == nil {
+		return nil, fmt.Errorf("checkpoint block of epoch %d not found", epoch)
+	}
+	var (
+		tradingState *tradingstate.TradingStateDB
+		lendingState *lendingstate.LendingStateDB
+		err          error
+	)
+	tradingRoot, lendingRoot := bc.tomoXStateRoots(block)
+	if tradingRoot != (common.Hash{}) {
+		if tradingState, err = tradingstate.New(tradingRoot, tradingstate.NewDatabase(tomoxdb)); err != nil {
+			return nil, fmt.Errorf("trading state of block #%d not available: %v", block.NumberU64(), err)
+		}
+		tradingState.SetPruning(bc.chainConfig.IsTIPTomoXPrune(block.Number()))
+	}
+	if lendingRoot != (common.Hash{}) {
+		if lendingState, err = lendingstate.New(lendingRoot, lendingstate.NewDatabase(tomoxdb)); err != nil {
+			return nil, fmt.Errorf("lending state of block #%d not available: %v", block.NumberU64(), err)
+		}
+	}
+	return NewTomoXSnapshot(block, epoch, tradingState, lendingState)
+}
+
+// ExportTomoXSnapshot writes the TomoX snapshot of the given epoch into w, as
+// indented JSON.
+func (bc *BlockChain) ExportTomoXSnapshot(w io.Writer, epoch uint64, tomoxdb ethdb.Database) (*TomoXSnapshot, error) {
+	snapshot, err := bc.TomoXSnapshot(epoch, tomoxdb)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
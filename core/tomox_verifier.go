@@ -0,0 +1,102 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// verifyTomoXTransactions checks the special transactions of a block as the
+// miner creates them: the matched orders, the lending items, the finalized
+// trades and the state roots are each sent at most once, by the author of the
+// block, and the state roots hold the trading root, followed by the lending
+// root once lending is enabled.
+func verifyTomoXTransactions(block *types.Block, author common.Address, lending bool) error {
+	roots := common.HashLength
+	if lending {
+		roots += common.HashLength
+	}
+	counts := make(map[string]int)
+	for _, tx := range block.Transactions() {
+		if tx.To() == nil {
+			continue
+		}
+		kind := tx.To().Hex()
+		switch kind {
+		case common.TomoXAddr, common.TomoXLendingAddress, common.TomoXLendingFinalizedTradeAddress:
+			if from := tx.From(); from == nil || *from != author {
+				return fmt.Errorf("tomox transaction %x to %s isn't sent by the block author %x", tx.Hash(), kind, author)
+			}
+		case common.TradingStateAddr:
+			// Anyone may send to the address, only the roots of the author count
+			if from := tx.From(); from == nil || *from != author {
+				continue
+			}
+			if len(tx.Data()) < roots {
+				return fmt.Errorf("tomox state roots transaction %x has %d bytes, want %d", tx.Hash(), len(tx.Data()), roots)
+			}
+		default:
+			continue
+		}
+		if counts[kind]++; counts[kind] > 1 {
+			return fmt.Errorf("tomox transaction %x to %s is duplicated", tx.Hash(), kind)
+		}
+	}
+	return nil
+}
+
+// verifyFinalizedTrades checks that the finalized trades transaction of a block
+// lists the lending trades the node liquidated, repaid, topped up, recalled and
+// renewed itself when processing the block. A block without any such trade has
+// no transaction of them.
+func verifyFinalizedTrades(block *types.Block, liquidated, autoRepay, autoTopUp, autoRecall, autoRenew []*lendingstate.LendingTrade) error {
+	result, err := ExtractLendingFinalizedTradeTransactions(block.Transactions())
+	if err != nil {
+		return err
+	}
+	lists := []struct {
+		name   string
+		trades []*lendingstate.LendingTrade
+		hashes []common.Hash
+	}{
+		{"liquidated", liquidated, result.Liquidated},
+		{"auto repaid", autoRepay, result.AutoRepay},
+		{"auto topped up", autoTopUp, result.AutoTopUp},
+		{"auto recalled", autoRecall, result.AutoRecall},
+		{"auto renewed", autoRenew, result.AutoRenew},
+	}
+	for _, list := range lists {
+		if len(list.trades) != len(list.hashes) {
+			return fmt.Errorf("invalid finalized trades: %d %s trades, want %d", len(list.hashes), list.name, len(list.trades))
+		}
+		want := make(map[common.Hash]bool, len(list.trades))
+		for _, trade := range list.trades {
+			want[trade.Hash] = true
+		}
+		for _, hash := range list.hashes {
+			if !want[hash] {
+				return fmt.Errorf("invalid finalized trades: unexpected %s trade %x", list.name, hash)
+			}
+			delete(want, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+var tomoxAuthorKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+func tomoxTx(t *testing.T, nonce uint64, to string, data []byte) *types.Transaction {
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.HexToAddress(to), big.NewInt(0), 0, big.NewInt(0), data), types.HomesteadSigner{}, tomoxAuthorKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+func TestVerifyTomoXTransactions(t *testing.T) {
+	author := crypto.PubkeyToAddress(tomoxAuthorKey.PublicKey)
+	roots := make([]byte, 2*common.HashLength)
+
+	tests := []struct {
+		txs   types.Transactions
+		other bool // whether the block is verified against another author
+		fail  bool
+	}{
+		{txs: types.Transactions{tomoxTx(t, 0, common.TomoXAddr, nil), tomoxTx(t, 1, common.TomoXLendingAddress, nil), tomoxTx(t, 2, common.TradingStateAddr, roots)}},
+		{txs: types.Transactions{tomoxTx(t, 0, common.TomoXAddr, nil)}, other: true, fail: true},
+		{txs: types.Transactions{tomoxTx(t, 0, common.TradingStateAddr, roots)}, other: true},
+		{txs: types.Transactions{tomoxTx(t, 0, common.TomoXAddr, nil), tomoxTx(t, 1, common.TomoXAddr, nil)}, fail: true},
+		{txs: types.Transactions{tomoxTx(t, 0, common.TradingStateAddr, roots), tomoxTx(t, 1, common.TradingStateAddr, roots)}, fail: true},
+		{txs: types.Transactions{tomoxTx(t, 0, common.TradingStateAddr, roots[:common.HashLength])}, fail: true},
+	}
+	for i, tt := range tests {
+		block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, tt.txs, nil, nil)
+		verified := author
+		if tt.other {
+			verified = common.HexToAddress("0x01")
+		}
+		if err := verifyTomoXTransactions(block, verified, true); (err != nil) != tt.fail {
+			t.Errorf("test %d: error mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
+
+func TestVerifyFinalizedTrades(t *testing.T) {
+	liquidated := []*lendingstate.LendingTrade{{Hash: common.HexToHash("0x01")}, {Hash: common.HexToHash("0x02")}}
+	renewed := []*lendingstate.LendingTrade{{Hash: common.HexToHash("0x03")}}
+	data, err := lendingstate.EncodeFinalizedResult(liquidated, nil, nil, nil, renewed)
+	if err != nil {
+		t.Fatalf("failed to encode finalized trades: %v", err)
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, types.Transactions{tomoxTx(t, 0, common.TomoXLendingFinalizedTradeAddress, data)}, nil, nil)
+
+	// the trades are listed in any order
+	reversed := []*lendingstate.LendingTrade{liquidated[1], liquidated[0]}
+	if err := verifyFinalizedTrades(block, reversed, nil, nil, nil, renewed); err != nil {
+		t.Errorf("failed to verify finalized trades: %v", err)
+	}
+	if err := verifyFinalizedTrades(block, liquidated, nil, nil, renewed, nil); err == nil {
+		t.Errorf("verified trades finalized differently")
+	}
+	if err := verifyFinalizedTrades(block, liquidated[:1], nil, nil, nil, renewed); err == nil {
+		t.Errorf("verified an unexpected liquidated trade")
+	}
+	if err := verifyFinalizedTrades(types.NewBlock(&types.Header{Number: big.NewInt(1)}, nil, nil, nil), liquidated, nil, nil, nil, nil); err == nil {
+		t.Errorf("verified a block missing its finalized trades")
+	}
+	if err := verifyFinalizedTrades(types.NewBlock(&types.Header{Number: big.NewInt(1)}, nil, nil, nil), nil, nil, nil, nil, nil); err != nil {
+		t.Errorf("failed to verify a block without finalized trades: %v", err)
+	}
+}
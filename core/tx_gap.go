@@ -0,0 +1,141 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+)
+
+// GapPolicy is the handling of the pool transactions during the gap blocks,
+// from the gap of an epoch to the checkpoint ending it, while the masternodes
+// of the next epoch settle.
+type GapPolicy string
+
+const (
+	GapPolicyInclude GapPolicy = "include" // include the transactions in the gap blocks as usual
+	GapPolicyDelay   GapPolicy = "delay"   // keep the transactions pending until after the checkpoint
+	GapPolicyReject  GapPolicy = "reject"  // reject the transactions arriving during the gap blocks
+)
+
+// sanitize returns the policy, or the include policy if it is unknown.
+func (policy GapPolicy) sanitize(name string) GapPolicy {
+	switch policy {
+	case GapPolicyInclude, GapPolicyDelay, GapPolicyReject:
+		return policy
+	case "":
+		return GapPolicyInclude
+	}
+	log.Warn("Sanitizing invalid txpool gap policy", "policy", name, "provided", policy, "updated", GapPolicyInclude)
+	return GapPolicyInclude
+}
+
+// TxGapStatus is the handling of the pending transactions by the next block.
+type TxGapStatus struct {
+	InGap         bool          // Whether the next block is a gap block
+	Checkpoint    uint64        // Checkpoint ending the epoch of the next block
+	Policy        GapPolicy     // Handling of the normal transactions in the gap blocks
+	SpecialPolicy GapPolicy     // Handling of the sign and randomize transactions in the gap blocks
+	Delayed       []common.Hash // Pending transactions delayed until after the checkpoint
+}
+
+// gapPolicy returns the policy handling the transaction in the gap blocks.
+func (pool *TxPool) gapPolicy(tx *types.Transaction) GapPolicy {
+	if tx.IsSpecialTransaction() {
+		return pool.config.SpecialGapPolicy
+	}
+	return pool.config.GapPolicy
+}
+
+// inGap returns whether the block of the number is a gap block.
+func (pool *TxPool) inGap(number uint64) bool {
+	return pool.chainconfig.Posv != nil && pool.chainconfig.Posv.IsInGap(number)
+}
+
+// firstDelayed returns the position of the first of the transactions, sorted by
+// nonce, delayed by the gap policies, or -1 if none is. All the transactions
+// after it are delayed too, their nonces depending on it.
+func (pool *TxPool) firstDelayed(txs types.Transactions) int {
+	for i, tx := range txs {
+		if pool.gapPolicy(tx) == GapPolicyDelay {
+			return i
+		}
+	}
+	return -1
+}
+
+// DelayGapTransactions drops from the pending transactions, grouped by account
+// and sorted by nonce, the ones the gap policies delay if the block of the
+// number is a gap block.
+func (pool *TxPool) DelayGapTransactions(pending map[common.Address]types.Transactions, number uint64) map[common.Address]types.Transactions {
+	if !pool.inGap(number) {
+		return pending
+	}
+	for addr, txs := range pending {
+		if i := pool.firstDelayed(txs); i == 0 {
+			delete(pending, addr)
+		} else if i > 0 {
+			pending[addr] = txs[:i]
+		}
+	}
+	return pending
+}
+
+// GapStatus returns the handling of the pending transactions by the next block.
+func (pool *TxPool) GapStatus() *TxGapStatus {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	number := pool.chain.CurrentBlock().NumberU64() + 1
+	status := &TxGapStatus{
+		InGap:         pool.inGap(number),
+		Policy:        pool.config.GapPolicy,
+		SpecialPolicy: pool.config.SpecialGapPolicy,
+		Delayed:       []common.Hash{},
+	}
+	if pool.chainconfig.Posv != nil {
+		status.Checkpoint = pool.chainconfig.Posv.NextCheckpoint(number)
+	}
+	if !status.InGap {
+		return status
+	}
+	for _, list := range pool.pending {
+		txs := list.Flatten()
+		if i := pool.firstDelayed(txs); i >= 0 {
+			for _, tx := range txs[i:] {
+				status.Delayed = append(status.Delayed, tx.Hash())
+			}
+		}
+	}
+	return status
+}
+
+// delayedUntil returns the checkpoint after which the gap policies let the
+// pending transaction of the sender be included, or zero if it isn't delayed.
+// The pool lock is held by the caller.
+func (pool *TxPool) delayedUntil(from common.Address, tx *types.Transaction) uint64 {
+	number := pool.chain.CurrentBlock().NumberU64() + 1
+	if !pool.inGap(number) {
+		return 0
+	}
+	txs := pool.pending[from].Flatten()
+	if i := pool.firstDelayed(txs); i < 0 || tx.Nonce() < txs[i].Nonce() {
+		return 0
+	}
+	return pool.chainconfig.Posv.NextCheckpoint(number)
+}
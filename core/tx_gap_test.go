@@ -0,0 +1,106 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/params"
+)
+
+// setupGapTxPool creates a pool with the gap policies whose next block, the
+// first one, is a gap block if gap is set.
+func setupGapTxPool(policy, special GapPolicy, gap bool) *TxPool {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	chainconfig := *params.TestChainConfig
+	chainconfig.Posv = &params.PosvConfig{Epoch: 4, Gap: 1}
+	if gap {
+		chainconfig.Posv.Gap = 3
+	}
+	config := testTxPoolConfig
+	config.GapPolicy, config.SpecialGapPolicy = policy, special
+	return NewTxPool(config, &chainconfig, blockchain)
+}
+
+func TestTxPoolGapReject(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	for _, gap := range []bool{false, true} {
+		pool := setupGapTxPool(GapPolicyReject, GapPolicyInclude, gap)
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), new(big.Int).Mul(big.NewInt(common.DefaultMinGasPrice), big.NewInt(1000000)))
+
+		err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(common.DefaultMinGasPrice), key))
+		if gap && err != ErrGapBlock {
+			t.Errorf("transaction in the gap blocks error mismatch: have %v, want %v", err, ErrGapBlock)
+		}
+		if !gap && err != nil {
+			t.Errorf("transaction out of the gap blocks rejected: %v", err)
+		}
+		pool.Stop()
+	}
+}
+
+func TestTxPoolGapDelay(t *testing.T) {
+	t.Parallel()
+
+	pool := setupGapTxPool(GapPolicyDelay, GapPolicyInclude, true)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+	pool.lockedReset(nil, nil)
+
+	special, _ := types.SignTx(types.NewTransaction(0, common.HexToAddress(common.BlockSigners), big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	txs := []*types.Transaction{transaction(1, 100000, key), transaction(2, 100000, key)}
+	pending := map[common.Address]types.Transactions{from: {special, txs[0], txs[1]}}
+	if have := pool.DelayGapTransactions(pending, 1); !reflect.DeepEqual(have[from], types.Transactions{special}) {
+		t.Errorf("transactions in the gap blocks mismatch: have %d, want the special one", len(have[from]))
+	}
+	pending = map[common.Address]types.Transactions{from: txs}
+	if have := pool.DelayGapTransactions(pending, 1); len(have) != 0 {
+		t.Errorf("delayed transactions included in the gap blocks: %d accounts", len(have))
+	}
+	if have := pool.DelayGapTransactions(map[common.Address]types.Transactions{from: txs}, 4); len(have[from]) != 2 {
+		t.Errorf("transactions delayed out of the gap blocks: have %d, want 2", len(have[from]))
+	}
+
+	tx := transaction(0, 100000, key)
+	pool.enqueueTx(tx.Hash(), tx)
+	pool.promoteExecutables([]common.Address{from})
+	if pool.pending[from] == nil {
+		t.Fatalf("transaction not pending")
+	}
+	status := pool.GapStatus()
+	if !status.InGap || status.Checkpoint != 4 || !reflect.DeepEqual(status.Delayed, []common.Hash{tx.Hash()}) {
+		t.Errorf("gap status mismatch: have %+v", status)
+	}
+	if info := pool.Info(tx.Hash()); info.DelayedUntil != 4 {
+		t.Errorf("delayed until mismatch: have %d, want 4", info.DelayedUntil)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/misc"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/event"
@@ -87,6 +88,14 @@ var (
 	ErrDuplicateSpecialTransaction = errors.New("duplicate a special transaction")
 
 	ErrMinDeploySMC = errors.New("smart contract creation cost is under allowance")
+
+	// ErrGapBlock is returned if a transaction arrives during the gap blocks
+	// before a checkpoint while its gap policy rejects it.
+	ErrGapBlock = errors.New("transaction rejected in the gap blocks before the checkpoint")
+
+	// ErrTxTypeNotSupported is returned if a transaction is not supported in the
+	// current network configuration.
+	ErrTxTypeNotSupported = types.ErrTxTypeNotSupported
 )
 
 var (
@@ -122,6 +131,23 @@ const (
 	TxStatusIncluded
 )
 
+// TxPoolInfo is the pool's view of a single transaction, meant to help users
+// understand why the transaction has not been included yet.
+type TxPoolInfo struct {
+	Status   TxStatus  // Whether the transaction is pending or queued
+	Arrival  time.Time // Time the transaction was first seen locally
+	Position int       // Estimated number of pending transactions to be included before it
+	NonceGap uint64    // Number of missing nonces before a queued transaction becomes executable
+
+	// DelayedUntil is the checkpoint after which the gap policies let a pending
+	// transaction be included, or zero if it isn't delayed.
+	DelayedUntil uint64
+
+	// ReplacementPrice is the minimum gas price a transaction with the same
+	// nonce needs to replace this one, or nil if it cannot be replaced.
+	ReplacementPrice *big.Int
+}
+
 // blockChain provides the state of blockchain and current gas limit to do
 // some pre checks in tx pool and event subscribers.
 type blockChain interface {
@@ -158,6 +184,9 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	GapPolicy        GapPolicy // Handling of the normal transactions during the gap blocks
+	SpecialGapPolicy GapPolicy // Handling of the sign and randomize transactions during the gap blocks
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -175,6 +204,9 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	GapPolicy:        GapPolicyInclude,
+	SpecialGapPolicy: GapPolicyInclude,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -193,6 +225,8 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	conf.GapPolicy = conf.GapPolicy.sanitize("normal")
+	conf.SpecialGapPolicy = conf.SpecialGapPolicy.sanitize("special")
 	return conf
 }
 
@@ -231,6 +265,9 @@ type TxPool struct {
 	wg sync.WaitGroup // for shutdown sync
 
 	homestead        bool
+	eip2718          bool     // Fork indicator whether we are using EIP-2718 type transactions.
+	eip1559          bool     // Fork indicator whether we are using EIP-1559 type transactions.
+	pendingBaseFee   *big.Int // Base fee of the pending block, nil before EIP-1559
 	IsSigner         func(address common.Address) bool
 	trc21FeeCapacity map[common.Address]*big.Int
 }
@@ -246,7 +283,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		config:           config,
 		chainconfig:      chainconfig,
 		chain:            chain,
-		signer:           types.NewEIP155Signer(chainconfig.ChainId),
+		signer:           types.LatestSigner(chainconfig),
 		pending:          make(map[common.Address]*txList),
 		queue:            make(map[common.Address]*txList),
 		beats:            make(map[common.Address]time.Time),
@@ -435,6 +472,16 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
+	// Update all fork indicator by next pending block number.
+	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
+	pool.eip2718 = pool.chainconfig.IsBerlin(next)
+	pool.eip1559 = pool.chainconfig.IsLondon(next)
+	if pool.eip1559 {
+		pool.pendingBaseFee = misc.CalcBaseFee(pool.chainconfig, newHead)
+	} else {
+		pool.pendingBaseFee = nil
+	}
+
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	pool.addTxsLocked(reinject, false)
@@ -595,6 +642,14 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		return fmt.Errorf("Reject transaction with receiver in black-list: %v", tx.To().Hex())
 	}
 
+	// Accept only legacy transactions until EIP-2718/2930 activates.
+	if !pool.eip2718 && tx.Type() != types.LegacyTxType {
+		return ErrTxTypeNotSupported
+	}
+	// Reject dynamic fee transactions until EIP-1559 activates.
+	if !pool.eip1559 && tx.Type() == types.DynamicFeeTxType {
+		return ErrTxTypeNotSupported
+	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
@@ -608,11 +663,30 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentMaxGas < tx.Gas() {
 		return ErrGasLimit
 	}
+	// Sanity check for extremely large numbers
+	if tx.GasFeeCap().BitLen() > 256 {
+		return ErrFeeCapVeryHigh
+	}
+	if tx.GasTipCap().BitLen() > 256 {
+		return ErrTipVeryHigh
+	}
+	// Ensure gasFeeCap is greater than or equal to gasTipCap.
+	if tx.GasFeeCapIntCmp(tx.GasTipCap()) < 0 {
+		return ErrTipAboveFeeCap
+	}
+	// Ensure a dynamic fee transaction can pay the base fee of the pending block.
+	if tx.Type() == types.DynamicFeeTxType && pool.pendingBaseFee != nil && tx.GasFeeCapIntCmp(pool.pendingBaseFee) < 0 {
+		return ErrFeeCapTooLow
+	}
 	// Make sure the transaction is signed properly
 	from, err := types.Sender(pool.signer, tx)
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Reject the transactions arriving during the gap blocks if their policy says so
+	if pool.gapPolicy(tx) == GapPolicyReject && pool.inGap(pool.chain.CurrentBlock().NumberU64()+1) {
+		return ErrGapBlock
+	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
 	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
@@ -649,7 +723,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	}
 
 	if tx.To() == nil || (tx.To() != nil && !tx.IsSpecialTransaction()) {
-		intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
+		intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, pool.homestead)
 		if err != nil {
 			return err
 		}
@@ -986,6 +1060,62 @@ func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
 	return status
 }
 
+// Info returns the pool metadata of the transaction identified by hash, or nil
+// if the transaction is not contained in the pool.
+//
+// The position of a pending transaction is only an estimate: it counts the
+// sender's own lower nonce transactions plus every pending transaction of other
+// accounts paying a higher gas price, ignoring gas limits and block capacity.
+func (pool *TxPool) Info(hash common.Hash) *TxPoolInfo {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	tx := pool.all[hash]
+	if tx == nil {
+		return nil
+	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	info := &TxPoolInfo{
+		Status:  TxStatusQueued,
+		Arrival: tx.Time(),
+	}
+	if !tx.IsSpecialTransaction() {
+		price := new(big.Int).Div(new(big.Int).Mul(tx.GasPrice(), big.NewInt(100+int64(pool.config.PriceBump))), big.NewInt(100))
+		if price.Cmp(tx.GasPrice()) <= 0 {
+			price.Add(tx.GasPrice(), common.Big1)
+		}
+		info.ReplacementPrice = price
+	}
+	if list := pool.pending[from]; list != nil && list.txs.items[tx.Nonce()] != nil {
+		info.Status = TxStatusPending
+		info.DelayedUntil = pool.delayedUntil(from, tx)
+		for addr, list := range pool.pending {
+			for _, other := range list.txs.items {
+				if addr == from {
+					if other.Nonce() < tx.Nonce() {
+						info.Position++
+					}
+				} else if other.GasPrice().Cmp(tx.GasPrice()) > 0 {
+					info.Position++
+				}
+			}
+		}
+		return info
+	}
+	if nonce := pool.pendingState.GetNonce(from); tx.Nonce() > nonce {
+		info.NonceGap = tx.Nonce() - nonce
+		if list := pool.queue[from]; list != nil {
+			// Queued transactions below this one already fill part of the gap
+			for _, other := range list.txs.items {
+				if other.Nonce() >= nonce && other.Nonce() < tx.Nonce() {
+					info.NonceGap--
+				}
+			}
+		}
+	}
+	return info
+}
+
 // Get returns a transaction if it is contained in the pool
 // and nil otherwise.
 func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
@@ -94,6 +94,32 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 	return tx
 }
 
+func dynamicFeeTransaction(nonce uint64, gaslimit uint64, gasTipCap, gasFeeCap *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   params.TestChainConfig.ChainId,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       gaslimit,
+		To:        &common.Address{},
+		Value:     big.NewInt(100),
+	}), types.NewLondonSigner(params.TestChainConfig.ChainId), key)
+	return tx
+}
+
+func accessListTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, accesses types.AccessList, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTx(&types.AccessListTx{
+		ChainID:    params.TestChainConfig.ChainId,
+		Nonce:      nonce,
+		GasPrice:   gasprice,
+		Gas:        gaslimit,
+		To:         &common.Address{},
+		Value:      big.NewInt(100),
+		AccessList: accesses,
+	}), types.NewEIP2930Signer(params.TestChainConfig.ChainId), key)
+	return tx
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	diskdb := rawdb.NewMemoryDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(diskdb))
@@ -278,6 +304,128 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+// Tests that dynamic fee transactions are only accepted once EIP-1559 is
+// active and that their fee caps are sanity checked.
+func TestDynamicFeeTransactions(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(0xffffffffffffff))
+
+	tx := dynamicFeeTransaction(0, 100000, big.NewInt(1), big.NewInt(2), key)
+	if err := pool.AddRemote(tx); err != ErrTxTypeNotSupported {
+		t.Error("expected", ErrTxTypeNotSupported, "got", err)
+	}
+	pool.eip2718, pool.eip1559 = true, true
+
+	tx = dynamicFeeTransaction(0, 100000, big.NewInt(3), big.NewInt(2), key)
+	if err := pool.AddRemote(tx); err != ErrTipAboveFeeCap {
+		t.Error("expected", ErrTipAboveFeeCap, "got", err)
+	}
+	pool.pendingBaseFee = big.NewInt(10)
+
+	tx = dynamicFeeTransaction(0, 100000, big.NewInt(1), big.NewInt(9), key)
+	if err := pool.AddRemote(tx); err != ErrFeeCapTooLow {
+		t.Error("expected", ErrFeeCapTooLow, "got", err)
+	}
+}
+
+func TestAccessListTransactions(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(0xffffffffffffff))
+
+	accesses := types.AccessList{{Address: common.Address{1}, StorageKeys: []common.Hash{{1}}}}
+	tx := accessListTransaction(0, 100000, big.NewInt(1), accesses, key)
+	if err := pool.AddRemote(tx); err != ErrTxTypeNotSupported {
+		t.Error("expected", ErrTxTypeNotSupported, "got", err)
+	}
+	pool.eip2718 = true
+	pool.signer = types.NewEIP2930Signer(params.TestChainConfig.ChainId)
+
+	// The access list is charged as part of the intrinsic gas
+	tx = accessListTransaction(0, params.TxGas, big.NewInt(1), accesses, key)
+	if err := pool.AddRemote(tx); err != ErrIntrinsicGas {
+		t.Error("expected", ErrIntrinsicGas, "got", err)
+	}
+	tx = accessListTransaction(0, 100000, big.NewInt(common.DefaultMinGasPrice), accesses, key)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Error("expected nil, got", err)
+	}
+}
+
+// Tests that the pool reports the classification, position estimate and
+// replacement price of the transactions it contains.
+func TestTransactionPoolInfo(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	for _, k := range []*ecdsa.PrivateKey{key, other} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(k.PublicKey), big.NewInt(1000000))
+	}
+	pool.lockedReset(nil, nil)
+
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100, big.NewInt(10), key),
+		pricedTransaction(1, 100, big.NewInt(10), key),
+		pricedTransaction(4, 100, big.NewInt(10), key),
+		pricedTransaction(0, 100, big.NewInt(20), other),
+		pricedTransaction(1, 100, big.NewInt(5), other),
+	}
+	for _, tx := range txs {
+		pool.enqueueTx(tx.Hash(), tx)
+	}
+	pool.promoteExecutables([]common.Address{crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(other.PublicKey)})
+
+	if info := pool.Info(common.Hash{}); info != nil {
+		t.Fatalf("unknown transaction info: have %v, want nil", info)
+	}
+	tests := []struct {
+		tx       *types.Transaction
+		status   TxStatus
+		position int
+		gap      uint64
+	}{
+		{txs[0], TxStatusPending, 1, 0},
+		{txs[1], TxStatusPending, 2, 0},
+		{txs[2], TxStatusQueued, 0, 2},
+		{txs[3], TxStatusPending, 0, 0},
+		{txs[4], TxStatusPending, 3, 0},
+	}
+	for i, tt := range tests {
+		info := pool.Info(tt.tx.Hash())
+		if info == nil {
+			t.Fatalf("test %d: missing transaction info", i)
+		}
+		if info.Status != tt.status {
+			t.Errorf("test %d: status mismatch: have %v, want %v", i, info.Status, tt.status)
+		}
+		if info.Position != tt.position {
+			t.Errorf("test %d: position mismatch: have %d, want %d", i, info.Position, tt.position)
+		}
+		if info.NonceGap != tt.gap {
+			t.Errorf("test %d: nonce gap mismatch: have %d, want %d", i, info.NonceGap, tt.gap)
+		}
+		if info.Arrival != tt.tx.Time() {
+			t.Errorf("test %d: arrival mismatch: have %v, want %v", i, info.Arrival, tt.tx.Time())
+		}
+	}
+	// A replacement must meet the price bump, and at least add one wei
+	if price := pool.Info(txs[0].Hash()).ReplacementPrice; price.Cmp(big.NewInt(11)) != 0 {
+		t.Errorf("replacement price mismatch: have %v, want %v", price, 11)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
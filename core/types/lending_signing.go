@@ -134,6 +134,10 @@ func (lendingsign LendingTxSigner) LendingCreateHash(tx *LendingTransaction) com
 			autoTopUp = int64(1)
 		}
 		sha.Write(common.BigToHash(big.NewInt(autoTopUp)).Bytes())
+		// a borrowing to be rolled over also signs its highest renewal rate
+		if tx.AutoRenewRate() > 0 {
+			sha.Write(common.BigToHash(new(big.Int).SetUint64(tx.AutoRenewRate())).Bytes())
+		}
 	}
 	return common.BytesToHash(sha.Sum(nil))
 }
@@ -162,6 +166,10 @@ func (lendingsign LendingTxSigner) LendingRepayHash(tx *LendingTransaction) comm
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
 	sha.Write([]byte(tx.Type()))
+	// a partial repayment also signs the amount repaid
+	if tx.Type() == LendingPartialRepay {
+		sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	}
 	return common.BytesToHash(sha.Sum(nil))
 }
 
@@ -180,6 +188,21 @@ func (lendingsign LendingTxSigner) LendingTopUpHash(tx *LendingTransaction) comm
 	return common.BytesToHash(sha.Sum(nil))
 }
 
+// LendingAuctionBidHash hash of a bid in the auction of a lending trade collateral
+func (lendingsign LendingTxSigner) LendingAuctionBidHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
@@ -195,6 +218,9 @@ func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
 	if tx.IsRepayLending() {
 		return lendingsign.LendingRepayHash(tx)
 	}
+	if tx.IsAuctionBidLending() {
+		return lendingsign.LendingAuctionBidHash(tx)
+	}
 	return common.Hash{}
 }
 
@@ -46,7 +46,9 @@ const (
 	LendingSideBorrow          = "BORROW"
 	LendingSideInvest          = "INVEST"
 	LendingRePay               = "REPAY"
+	LendingPartialRepay        = "PARTIAL_REPAY"
 	LendingTopup               = "TOPUP"
+	LendingAuctionBid          = "AUCTION_BID"
 )
 
 // LendingTransaction lending transaction
@@ -82,6 +84,9 @@ type lendingtxdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash"`
+
+	// Highest interest rate at which a borrowing is rolled over at maturity, 0 if it isn't
+	AutoRenewRate uint64 `json:"autoRenewRate,omitempty" rlp:"optional"`
 }
 
 // IsCreatedLending check if tx is cancelled transaction
@@ -100,9 +105,9 @@ func (tx *LendingTransaction) IsCancelledLending() bool {
 	return false
 }
 
-// IsRepayLending check if tx is repay lending transaction
+// IsRepayLending check if tx is repay lending transaction, in full or in part
 func (tx *LendingTransaction) IsRepayLending() bool {
-	if tx.Type() == LendingRePay {
+	if tx.Type() == LendingRePay || tx.Type() == LendingPartialRepay {
 		return true
 	}
 	return false
@@ -116,6 +121,15 @@ func (tx *LendingTransaction) IsTopupLending() bool {
 	return false
 }
 
+// IsAuctionBidLending check if tx is a bid in the auction of the collateral of a
+// liquidated lending trade
+func (tx *LendingTransaction) IsAuctionBidLending() bool {
+	if tx.Type() == LendingAuctionBid {
+		return true
+	}
+	return false
+}
+
 // IsMoTypeLending check if tx type is MO lending
 func (tx *LendingTransaction) IsMoTypeLending() bool {
 	if tx.Type() == LendingTypeMo {
@@ -175,6 +189,10 @@ func (tx *LendingTransaction) CollateralToken() common.Address { return tx.data.
 // CollateralToken return autoTopUp flag
 func (tx *LendingTransaction) AutoTopUp() bool { return tx.data.AutoTopUp }
 
+// AutoRenewRate return the highest interest rate at which a borrowing is rolled
+// over at maturity, 0 if it isn't
+func (tx *LendingTransaction) AutoRenewRate() uint64 { return tx.data.AutoRenewRate }
+
 // LendingToken return lending token address of transaction
 func (tx *LendingTransaction) LendingToken() common.Address { return tx.data.LendingToken }
 
@@ -205,6 +223,10 @@ func (tx *LendingTransaction) LendingTradeId() uint64 { return tx.data.LendingTr
 // SetLendingHash set hash of lending transaction hash
 func (tx *LendingTransaction) SetLendingHash(h common.Hash) { tx.data.Hash = h }
 
+// SetAutoRenewRate set the highest interest rate at which a borrowing is rolled
+// over at maturity, to be done before signing the borrowing
+func (tx *LendingTransaction) SetAutoRenewRate(rate uint64) { tx.data.AutoRenewRate = rate }
+
 // From get transaction from
 func (tx *LendingTransaction) From() *common.Address {
 	if tx.data.V != nil {
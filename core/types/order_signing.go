@@ -106,6 +106,17 @@ func (ordersign OrderTxSigner) SignatureValues(tx *OrderTransaction, sig []byte)
 	return r, s, v, nil
 }
 
+// Tags hashed before the optional fields of an order, so that an order can't
+// be re-encoded with the value of one of its optional fields moved to another.
+const (
+	orderTagTriggerPrice byte = iota + 1
+	orderTagDisplayQuantity
+	orderTagTimeInForce
+	orderTagExpiresAt
+	orderTagSelfTradePrevention
+	orderTagReplacedOrderID
+)
+
 // OrderCreateHash hash of new order
 func (ordersign OrderTxSigner) OrderCreateHash(tx *OrderTransaction) common.Hash {
 	sha := sha3.NewKeccak256()
@@ -114,7 +125,7 @@ func (ordersign OrderTxSigner) OrderCreateHash(tx *OrderTransaction) common.Hash
 	sha.Write(tx.BaseToken().Bytes())
 	sha.Write(tx.QuoteToken().Bytes())
 	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
-	if tx.IsLoTypeOrder() {
+	if tx.IsLoTypeOrder() || tx.Type() == OrderTypeSlo {
 		if tx.Price() != nil {
 			sha.Write(common.BigToHash(tx.Price()).Bytes())
 		}
@@ -123,6 +134,30 @@ func (ordersign OrderTxSigner) OrderCreateHash(tx *OrderTransaction) common.Hash
 	sha.Write([]byte(tx.Status()))
 	sha.Write([]byte(tx.Type()))
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	if tx.IsStopOrder() && tx.TriggerPrice() != nil {
+		sha.Write([]byte{orderTagTriggerPrice})
+		sha.Write(common.BigToHash(tx.TriggerPrice()).Bytes())
+	}
+	if tx.DisplayQuantity() != nil {
+		sha.Write([]byte{orderTagDisplayQuantity})
+		sha.Write(common.BigToHash(tx.DisplayQuantity()).Bytes())
+	}
+	if tx.TimeInForce() != "" {
+		sha.Write([]byte{orderTagTimeInForce})
+		sha.Write([]byte(tx.TimeInForce()))
+	}
+	if tx.ExpiresAt() != 0 {
+		sha.Write([]byte{orderTagExpiresAt})
+		sha.Write(common.BigToHash(new(big.Int).SetUint64(tx.ExpiresAt())).Bytes())
+	}
+	if tx.SelfTradePrevention() != "" {
+		sha.Write([]byte{orderTagSelfTradePrevention})
+		sha.Write([]byte(tx.SelfTradePrevention()))
+	}
+	if tx.ReplacedOrderID() != 0 {
+		sha.Write([]byte{orderTagReplacedOrderID})
+		sha.Write(common.BigToHash(new(big.Int).SetUint64(tx.ReplacedOrderID())).Bytes())
+	}
 	return common.BytesToHash(sha.Sum(nil))
 }
 
@@ -137,7 +172,10 @@ func (ordersign OrderTxSigner) OrderCancelHash(tx *OrderTransaction) common.Hash
 	sha.Write(tx.ExchangeAddress().Bytes())
 	sha.Write(tx.BaseToken().Bytes())
 	sha.Write(tx.QuoteToken().Bytes())
-
+	for _, cancel := range tx.Cancels() {
+		sha.Write(common.BigToHash(new(big.Int).SetUint64(cancel.OrderID)).Bytes())
+		sha.Write(cancel.Hash.Bytes())
+	}
 	return common.BytesToHash(sha.Sum(nil))
 }
 
@@ -43,6 +43,16 @@ const (
 	OrderStatusCancelled     = "CANCELLED"
 	OrderTypeMo              = "MO"
 	OrderTypeLo              = "LO"
+	OrderTypeSmo             = "SMO"
+	OrderTypeSlo             = "SLO"
+	OrderTimeInForcePostOnly = "PO"
+	OrderTimeInForceIoc      = "IOC"
+	OrderTimeInForceFok      = "FOK"
+
+	// self-trade prevention modes, naming the order cancelled in a self-trade
+	OrderSelfTradeCancelNewest = "CN"
+	OrderSelfTradeCancelOldest = "CO"
+	OrderSelfTradeCancelBoth   = "CB"
 )
 
 // OrderTransaction order transaction
@@ -73,6 +83,35 @@ type ordertxdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash"`
+
+	// Orders cancelled by a batch cancellation, after TIPTomoXBatchCancel
+	Cancels []OrderCancel `json:"cancels,omitempty" rlp:"optional"`
+
+	// Price at which a stop order is activated, after TIPTomoXStopOrder
+	TriggerPrice *big.Int `json:"triggerPrice,omitempty" rlp:"optional"`
+
+	// Quantity shown in the order book by an iceberg order, after TIPTomoXIcebergOrder
+	DisplayQuantity *big.Int `json:"displayQuantity,omitempty" rlp:"optional"`
+
+	// Time in force of a limit order: post-only (PO) after TIPTomoXPostOnly,
+	// immediate-or-cancel (IOC) and fill-or-kill (FOK) after TIPTomoXTimeInForce
+	TimeInForce string `json:"timeInForce,omitempty" rlp:"optional"`
+
+	// Unix time after which a good-till-date order expires, after TIPTomoXOrderExpiry
+	ExpiresAt uint64 `json:"expiresAt,omitempty" rlp:"optional"`
+
+	// Self-trade prevention mode: cancel newest (CN), oldest (CO) or both (CB),
+	// after TIPTomoXSelfTrade
+	SelfTradePrevention string `json:"selfTradePrevention,omitempty" rlp:"optional"`
+
+	// Order id of the resting order replaced by a limit order, after TIPTomoXReplaceOrder
+	ReplacedOrderID uint64 `json:"replacedOrderId,omitempty" rlp:"optional"`
+}
+
+// OrderCancel identifies one of the orders cancelled by a batch cancellation.
+type OrderCancel struct {
+	OrderID uint64      `json:"orderid"`
+	Hash    common.Hash `json:"hash"`
 }
 
 // IsCancelledOrder check if tx is cancelled transaction
@@ -83,6 +122,11 @@ func (tx *OrderTransaction) IsCancelledOrder() bool {
 	return false
 }
 
+// IsBatchCancel check if tx cancels several orders at once
+func (tx *OrderTransaction) IsBatchCancel() bool {
+	return tx.IsCancelledOrder() && len(tx.data.Cancels) > 0
+}
+
 // IsMoTypeOrder check if tx type is MO Order
 func (tx *OrderTransaction) IsMoTypeOrder() bool {
 	if tx.Type() == OrderTypeMo {
@@ -99,6 +143,23 @@ func (tx *OrderTransaction) IsLoTypeOrder() bool {
 	return false
 }
 
+// IsPostOnly check if tx is a limit order rejected if it would cross the spread
+func (tx *OrderTransaction) IsPostOnly() bool {
+	return tx.data.TimeInForce == OrderTimeInForcePostOnly
+}
+
+// IsImmediate check if tx is a limit order never added to the order book, being
+// immediate-or-cancel or fill-or-kill
+func (tx *OrderTransaction) IsImmediate() bool {
+	return tx.data.TimeInForce == OrderTimeInForceIoc || tx.data.TimeInForce == OrderTimeInForceFok
+}
+
+// IsStopOrder check if tx type is a stop order, resting until its trigger price
+// is reached
+func (tx *OrderTransaction) IsStopOrder() bool {
+	return tx.Type() == OrderTypeSmo || tx.Type() == OrderTypeSlo
+}
+
 // EncodeRLP implements rlp.Encoder
 func (tx *OrderTransaction) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, &tx.data)
@@ -129,6 +190,13 @@ func (tx *OrderTransaction) Type() string                    { return tx.data.Ty
 func (tx *OrderTransaction) Signature() (V, R, S *big.Int)   { return tx.data.V, tx.data.R, tx.data.S }
 func (tx *OrderTransaction) OrderHash() common.Hash          { return tx.data.Hash }
 func (tx *OrderTransaction) OrderID() uint64                 { return tx.data.OrderID }
+func (tx *OrderTransaction) Cancels() []OrderCancel          { return tx.data.Cancels }
+func (tx *OrderTransaction) TriggerPrice() *big.Int          { return tx.data.TriggerPrice }
+func (tx *OrderTransaction) DisplayQuantity() *big.Int       { return tx.data.DisplayQuantity }
+func (tx *OrderTransaction) TimeInForce() string             { return tx.data.TimeInForce }
+func (tx *OrderTransaction) ExpiresAt() uint64               { return tx.data.ExpiresAt }
+func (tx *OrderTransaction) SelfTradePrevention() string     { return tx.data.SelfTradePrevention }
+func (tx *OrderTransaction) ReplacedOrderID() uint64         { return tx.data.ReplacedOrderID }
 func (tx *OrderTransaction) EncodedSide() *big.Int {
 	if tx.Side() == "BUY" {
 		return big.NewInt(0)
@@ -138,6 +206,22 @@ func (tx *OrderTransaction) EncodedSide() *big.Int {
 }
 func (tx *OrderTransaction) SetOrderHash(h common.Hash) { tx.data.Hash = h }
 
+// SetTimeInForce set the time in force of a limit order, to be done before
+// signing the order
+func (tx *OrderTransaction) SetTimeInForce(tif string) { tx.data.TimeInForce = tif }
+
+// SetExpiresAt set the expiry time of a good-till-date order, to be done before
+// signing the order
+func (tx *OrderTransaction) SetExpiresAt(expiresAt uint64) { tx.data.ExpiresAt = expiresAt }
+
+// SetSelfTradePrevention set the self-trade prevention mode of an order, to be
+// done before signing the order
+func (tx *OrderTransaction) SetSelfTradePrevention(mode string) { tx.data.SelfTradePrevention = mode }
+
+// SetReplacedOrderID set the order id of the resting order replaced by a limit
+// order, to be done before signing the order
+func (tx *OrderTransaction) SetReplacedOrderID(id uint64) { tx.data.ReplacedOrderID = id }
+
 // From get transaction from
 func (tx *OrderTransaction) From() *common.Address {
 	if tx.data.V != nil {
@@ -213,6 +297,37 @@ func NewOrderTransaction(nonce uint64, quantity, price *big.Int, ex, ua, b, q co
 	return newOrderTransaction(nonce, quantity, price, ex, ua, b, q, status, side, t, hash, id)
 }
 
+// NewBatchCancelOrderTransaction init an order cancelling all the given orders
+// of a pair at once
+func NewBatchCancelOrderTransaction(nonce uint64, ex, ua, b, q common.Address, cancels []OrderCancel) *OrderTransaction {
+	tx := newOrderTransaction(nonce, nil, nil, ex, ua, b, q, OrderStatusCancelled, "", "", common.Hash{}, 0)
+	tx.data.Cancels = append([]OrderCancel{}, cancels...)
+	return tx
+}
+
+// NewStopOrderTransaction init a stop order, which becomes a market (SMO) or
+// limit (SLO) order once the price of the pair reaches the trigger price
+func NewStopOrderTransaction(nonce uint64, quantity, price, triggerPrice *big.Int, ex, ua, b, q common.Address, side, t string, hash common.Hash) *OrderTransaction {
+	tx := newOrderTransaction(nonce, quantity, price, ex, ua, b, q, OrderStatusNew, side, t, hash, 0)
+	tx.data.TriggerPrice = new(big.Int)
+	if triggerPrice != nil {
+		tx.data.TriggerPrice.Set(triggerPrice)
+	}
+	return tx
+}
+
+// NewIcebergOrderTransaction init a limit order showing at most displayQuantity
+// of its quantity in the order book, the rest being hidden until that part is
+// filled
+func NewIcebergOrderTransaction(nonce uint64, quantity, displayQuantity, price *big.Int, ex, ua, b, q common.Address, side string, hash common.Hash) *OrderTransaction {
+	tx := newOrderTransaction(nonce, quantity, price, ex, ua, b, q, OrderStatusNew, side, OrderTypeLo, hash, 0)
+	tx.data.DisplayQuantity = new(big.Int)
+	if displayQuantity != nil {
+		tx.data.DisplayQuantity.Set(displayQuantity)
+	}
+	return tx
+}
+
 func newOrderTransaction(nonce uint64, quantity, price *big.Int, ex, ua, b, q common.Address, status, side, t string, hash common.Hash, id uint64) *OrderTransaction {
 	d := ordertxdata{
 		AccountNonce:    nonce,
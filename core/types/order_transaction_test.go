@@ -0,0 +1,106 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// Tests that orders without cancellations keep their encoding, and that batch
+// cancellations round trip along with their signature.
+func TestBatchCancelOrderEncoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		user     = crypto.PubkeyToAddress(key.PublicKey)
+		exchange = common.HexToAddress("0x10")
+		base     = common.HexToAddress("0x20")
+		quote    = common.HexToAddress("0x30")
+	)
+	order := NewOrderTransaction(1, big.NewInt(10), big.NewInt(2), exchange, user, base, quote, OrderStatusNew, "BUY", OrderTypeLo, common.Hash{}, 0)
+	enc, err := rlp.EncodeToBytes(order)
+	if err != nil {
+		t.Fatalf("failed to encode order: %v", err)
+	}
+	legacy, _ := rlp.EncodeToBytes([]interface{}{
+		uint64(1), big.NewInt(10), big.NewInt(2), exchange, user, base, quote, OrderStatusNew, "BUY", OrderTypeLo, uint64(0),
+		new(big.Int), new(big.Int), new(big.Int), common.Hash{},
+	})
+	if string(enc) != string(legacy) {
+		t.Fatalf("order encoding changed: have %x, want %x", enc, legacy)
+	}
+	cancels := []OrderCancel{{OrderID: 3, Hash: common.HexToHash("0x03")}, {OrderID: 7, Hash: common.HexToHash("0x07")}}
+	batch, err := OrderSignTx(NewBatchCancelOrderTransaction(2, exchange, user, base, quote, cancels), OrderTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign batch cancellation: %v", err)
+	}
+	if enc, err = rlp.EncodeToBytes(batch); err != nil {
+		t.Fatalf("failed to encode batch cancellation: %v", err)
+	}
+	decoded := new(OrderTransaction)
+	if err := rlp.DecodeBytes(enc, decoded); err != nil {
+		t.Fatalf("failed to decode batch cancellation: %v", err)
+	}
+	if !decoded.IsBatchCancel() || !reflect.DeepEqual(decoded.Cancels(), cancels) {
+		t.Fatalf("cancellations mismatch: have %v, want %v", decoded.Cancels(), cancels)
+	}
+	if from, err := OrderSender(OrderTxSigner{}, decoded); err != nil || from != user {
+		t.Fatalf("sender mismatch: have %x, want %x, err %v", from, user, err)
+	}
+	// The signature covers the cancelled orders
+	tampered := NewBatchCancelOrderTransaction(2, exchange, user, base, quote, cancels[:1]).ImportSignature(batch.Signature())
+	if from, _ := OrderSender(OrderTxSigner{}, tampered); from == user {
+		t.Fatalf("signature valid for a different batch")
+	}
+}
+
+// Tests that the signature of an order doesn't hold for the order re-encoded
+// with the value of an optional field moved to another one.
+func TestOrderOptionalFieldsSigned(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		user     = crypto.PubkeyToAddress(key.PublicKey)
+		exchange = common.HexToAddress("0x10")
+		base     = common.HexToAddress("0x20")
+		quote    = common.HexToAddress("0x30")
+	)
+	order := NewOrderTransaction(1, big.NewInt(10), big.NewInt(2), exchange, user, base, quote, OrderStatusNew, "BUY", OrderTypeLo, common.Hash{}, 0)
+	order.SetExpiresAt(5)
+	signed, err := OrderSignTx(order, OrderTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign order: %v", err)
+	}
+	if from, err := OrderSender(OrderTxSigner{}, signed); err != nil || from != user {
+		t.Fatalf("sender mismatch: have %x, want %x, err %v", from, user, err)
+	}
+	// The expiry re-encoded as the display quantity of an iceberg order
+	tampered := NewIcebergOrderTransaction(1, big.NewInt(10), big.NewInt(5), big.NewInt(2), exchange, user, base, quote, "BUY", common.Hash{}).ImportSignature(signed.Signature())
+	if from, _ := OrderSender(OrderTxSigner{}, tampered); from == user {
+		t.Fatalf("signature valid for an iceberg order")
+	}
+	// The expiry re-encoded as the order replaced
+	tampered = NewOrderTransaction(1, big.NewInt(10), big.NewInt(2), exchange, user, base, quote, OrderStatusNew, "BUY", OrderTypeLo, common.Hash{}, 0)
+	tampered.SetReplacedOrderID(5)
+	if from, _ := OrderSender(OrderTxSigner{}, tampered.ImportSignature(signed.Signature())); from == user {
+		t.Fatalf("signature valid for a replacement order")
+	}
+}
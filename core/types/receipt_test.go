@@ -0,0 +1,133 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// Tests that the storage encoding of a receipt drops the derivable fields and
+// that decoding restores the consensus ones.
+func TestReceiptStorageEncoding(t *testing.T) {
+	receipt := &Receipt{
+		Status:            ReceiptStatusSuccessful,
+		CumulativeGasUsed: 1,
+		Logs: []*Log{
+			{Address: common.BytesToAddress([]byte{0x11}), Topics: []common.Hash{common.HexToHash("dead")}, Data: []byte{0x01}},
+		},
+		TxHash:          common.BytesToHash([]byte{0x11, 0x11}),
+		ContractAddress: common.BytesToAddress([]byte{0x01, 0x11, 0x11}),
+		GasUsed:         111111,
+	}
+	receipt.Bloom = CreateBloom(Receipts{receipt})
+
+	enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+	if err != nil {
+		t.Fatalf("failed to encode receipt: %v", err)
+	}
+	legacy, err := rlp.EncodeToBytes(&legacyStoredReceiptRLP{
+		PostStateOrStatus: receipt.statusEncoding(),
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+		Bloom:             receipt.Bloom,
+		TxHash:            receipt.TxHash,
+		ContractAddress:   receipt.ContractAddress,
+		Logs:              []*LogForStorage{(*LogForStorage)(receipt.Logs[0])},
+		GasUsed:           receipt.GasUsed,
+	})
+	if err != nil {
+		t.Fatalf("failed to encode legacy receipt: %v", err)
+	}
+	if len(enc) >= len(legacy) {
+		t.Errorf("storage encoding not compacted: have %d bytes, legacy %d", len(enc), len(legacy))
+	}
+	dec := new(ReceiptForStorage)
+	if err := rlp.DecodeBytes(enc, dec); err != nil {
+		t.Fatalf("failed to decode receipt: %v", err)
+	}
+	if dec.Status != receipt.Status || dec.CumulativeGasUsed != receipt.CumulativeGasUsed || dec.Bloom != receipt.Bloom {
+		t.Errorf("consensus fields mismatch: have %v/%d/%x, want %v/%d/%x", dec.Status, dec.CumulativeGasUsed, dec.Bloom, receipt.Status, receipt.CumulativeGasUsed, receipt.Bloom)
+	}
+	if !reflect.DeepEqual(dec.Logs, receipt.Logs) {
+		t.Errorf("logs mismatch: have %v, want %v", dec.Logs, receipt.Logs)
+	}
+	if dec.TxHash != (common.Hash{}) || dec.GasUsed != 0 {
+		t.Errorf("derivable fields stored: tx hash %x, gas used %d", dec.TxHash, dec.GasUsed)
+	}
+	// Receipts stored in the legacy format must remain readable in full
+	dec = new(ReceiptForStorage)
+	if err := rlp.DecodeBytes(legacy, dec); err != nil {
+		t.Fatalf("failed to decode legacy receipt: %v", err)
+	}
+	if dec.TxHash != receipt.TxHash || dec.ContractAddress != receipt.ContractAddress || dec.GasUsed != receipt.GasUsed || dec.Bloom != receipt.Bloom {
+		t.Errorf("legacy receipt mismatch: have %x/%x/%d, want %x/%x/%d", dec.TxHash, dec.ContractAddress, dec.GasUsed, receipt.TxHash, receipt.ContractAddress, receipt.GasUsed)
+	}
+}
+
+// Tests that the fields omitted from storage are reconstructed from the block.
+func TestDeriveFields(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := HomesteadSigner{}
+
+	transfer, _ := SignTx(NewTransaction(1, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil), signer, key)
+	create, _ := SignTx(NewContractCreation(2, big.NewInt(0), 100000, big.NewInt(1), nil), signer, key)
+	txs := Transactions{transfer, create}
+
+	receipts := Receipts{
+		{Status: ReceiptStatusSuccessful, CumulativeGasUsed: 21000, Logs: []*Log{{}, {}}},
+		{Status: ReceiptStatusSuccessful, CumulativeGasUsed: 71000, Logs: []*Log{{}}},
+	}
+	var (
+		hash   = common.HexToHash("beef")
+		number = uint64(7)
+	)
+	if err := receipts.DeriveFields(params.TestChainConfig, hash, number, txs); err != nil {
+		t.Fatalf("failed to derive fields: %v", err)
+	}
+	if receipts[0].TxHash != transfer.Hash() || receipts[1].TxHash != create.Hash() {
+		t.Errorf("tx hash mismatch")
+	}
+	if receipts[0].GasUsed != 21000 || receipts[1].GasUsed != 50000 {
+		t.Errorf("gas used mismatch: have %d/%d, want 21000/50000", receipts[0].GasUsed, receipts[1].GasUsed)
+	}
+	if receipts[0].ContractAddress != (common.Address{}) {
+		t.Errorf("contract address set on transfer: %x", receipts[0].ContractAddress)
+	}
+	if want := crypto.CreateAddress(from, 2); !bytes.Equal(receipts[1].ContractAddress[:], want[:]) {
+		t.Errorf("contract address mismatch: have %x, want %x", receipts[1].ContractAddress, want)
+	}
+	logIndex := uint(0)
+	for i, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			if l.BlockHash != hash || l.BlockNumber != number || l.TxHash != txs[i].Hash() || l.TxIndex != uint(i) || l.Index != logIndex {
+				t.Errorf("log %d mismatch: %+v", logIndex, l)
+			}
+			logIndex++
+		}
+	}
+	if err := receipts.DeriveFields(params.TestChainConfig, hash, number, txs[:1]); err == nil {
+		t.Errorf("receipt count mismatch not detected")
+	}
+}
@@ -144,7 +144,7 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 		}
 	}
 	// Sort the transactions and cross check the nonce ordering
-	txset, _ := NewTransactionsByPriceAndNonce(signer, groups, nil, map[common.Address]*big.Int{})
+	txset, _ := NewTransactionsByPriceAndNonce(signer, groups, nil, map[common.Address]*big.Int{}, nil)
 
 	txs := Transactions{}
 	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
@@ -233,3 +233,173 @@ func TestTransactionJSON(t *testing.T) {
 		}
 	}
 }
+
+// TestDynamicFeeTransactionCoding tests that EIP-1559 transactions survive the
+// RLP, binary and JSON encodings and still recover the original sender.
+func TestDynamicFeeTransactionCoding(t *testing.T) {
+	key, addr := defaultTestKey()
+	signer := NewLondonSigner(big.NewInt(88))
+	recipient := common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+
+	for i := uint64(0); i < 10; i++ {
+		var to *common.Address
+		if i%2 == 0 {
+			to = &recipient
+		}
+		tx, err := SignTx(NewTx(&DynamicFeeTx{
+			ChainID:    big.NewInt(88),
+			Nonce:      i,
+			GasTipCap:  big.NewInt(1),
+			GasFeeCap:  big.NewInt(10),
+			Gas:        123457,
+			To:         to,
+			Value:      big.NewInt(10),
+			Data:       []byte("abcdef"),
+			AccessList: AccessList{{Address: recipient, StorageKeys: []common.Hash{{0}}}},
+		}), signer, key)
+		if err != nil {
+			t.Fatalf("could not sign transaction: %v", err)
+		}
+		// RLP envelope
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatalf("rlp encode error: %v", err)
+		}
+		var rlpTx Transaction
+		if err := rlp.DecodeBytes(enc, &rlpTx); err != nil {
+			t.Fatalf("rlp decode error: %v", err)
+		}
+		// Canonical binary
+		bin, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatalf("binary encode error: %v", err)
+		}
+		if bin[0] != DynamicFeeTxType {
+			t.Fatalf("wrong type prefix: have %d, want %d", bin[0], DynamicFeeTxType)
+		}
+		var binTx Transaction
+		if err := binTx.UnmarshalBinary(bin); err != nil {
+			t.Fatalf("binary decode error: %v", err)
+		}
+		// JSON
+		data, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatalf("json encode error: %v", err)
+		}
+		var jsonTx Transaction
+		if err := json.Unmarshal(data, &jsonTx); err != nil {
+			t.Fatalf("json decode error: %v", err)
+		}
+		for name, parsed := range map[string]*Transaction{"rlp": &rlpTx, "binary": &binTx, "json": &jsonTx} {
+			if parsed.Hash() != tx.Hash() {
+				t.Errorf("%s: hash mismatch: have %x, want %x", name, parsed.Hash(), tx.Hash())
+			}
+			if parsed.Type() != DynamicFeeTxType {
+				t.Errorf("%s: type mismatch: have %d", name, parsed.Type())
+			}
+			from, err := Sender(signer, parsed)
+			if err != nil {
+				t.Fatalf("%s: sender recovery failed: %v", name, err)
+			}
+			if from != addr {
+				t.Errorf("%s: sender mismatch: have %x, want %x", name, from, addr)
+			}
+		}
+	}
+}
+
+func TestAccessListTransactionCoding(t *testing.T) {
+	key, addr := defaultTestKey()
+	signer := NewEIP2930Signer(big.NewInt(88))
+	recipient := common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+
+	for i := uint64(0); i < 10; i++ {
+		var to *common.Address
+		if i%2 == 0 {
+			to = &recipient
+		}
+		var accesses AccessList
+		if i%3 == 0 {
+			accesses = AccessList{{Address: recipient, StorageKeys: []common.Hash{{0}, {1}}}}
+		}
+		tx, err := SignTx(NewTx(&AccessListTx{
+			ChainID:    big.NewInt(88),
+			Nonce:      i,
+			GasPrice:   big.NewInt(10),
+			Gas:        123457,
+			To:         to,
+			Value:      big.NewInt(10),
+			Data:       []byte("abcdef"),
+			AccessList: accesses,
+		}), signer, key)
+		if err != nil {
+			t.Fatalf("could not sign transaction: %v", err)
+		}
+		bin, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatalf("binary encode error: %v", err)
+		}
+		if bin[0] != AccessListTxType {
+			t.Fatalf("wrong type prefix: have %d, want %d", bin[0], AccessListTxType)
+		}
+		var binTx Transaction
+		if err := binTx.UnmarshalBinary(bin); err != nil {
+			t.Fatalf("binary decode error: %v", err)
+		}
+		data, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatalf("json encode error: %v", err)
+		}
+		var jsonTx Transaction
+		if err := json.Unmarshal(data, &jsonTx); err != nil {
+			t.Fatalf("json decode error: %v", err)
+		}
+		for name, parsed := range map[string]*Transaction{"binary": &binTx, "json": &jsonTx} {
+			if parsed.Hash() != tx.Hash() {
+				t.Errorf("%s: hash mismatch: have %x, want %x", name, parsed.Hash(), tx.Hash())
+			}
+			if len(parsed.AccessList()) != len(accesses) {
+				t.Errorf("%s: access list mismatch: have %v, want %v", name, parsed.AccessList(), accesses)
+			}
+			// Both the Berlin and London signers must accept access list transactions
+			for _, s := range []Signer{signer, NewLondonSigner(big.NewInt(88))} {
+				from, err := Sender(s, parsed)
+				if err != nil {
+					t.Fatalf("%s: sender recovery failed: %v", name, err)
+				}
+				if from != addr {
+					t.Errorf("%s: sender mismatch: have %x, want %x", name, from, addr)
+				}
+			}
+		}
+		// Pre-Berlin signers know nothing about typed transactions
+		if _, err := Sender(NewEIP155Signer(big.NewInt(88)), tx); err != ErrTxTypeNotSupported {
+			t.Errorf("EIP155 signer: have %v, want %v", err, ErrTxTypeNotSupported)
+		}
+	}
+}
+
+// TestEffectiveGasTip tests the tip paid to the block producer for a dynamic
+// fee transaction under various base fees.
+func TestEffectiveGasTip(t *testing.T) {
+	tx := NewTx(&DynamicFeeTx{GasTipCap: big.NewInt(3), GasFeeCap: big.NewInt(10)})
+
+	for i, tt := range []struct {
+		baseFee *big.Int
+		tip     int64
+		err     error
+	}{
+		{nil, 3, nil},
+		{big.NewInt(5), 3, nil},
+		{big.NewInt(8), 2, nil},
+		{big.NewInt(11), -1, ErrGasFeeCapTooLow},
+	} {
+		tip, err := tx.EffectiveGasTip(tt.baseFee)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if tip.Int64() != tt.tip {
+			t.Errorf("test %d: tip mismatch: have %v, want %d", i, tip, tt.tip)
+		}
+	}
+}
@@ -660,6 +660,20 @@ func TestPrecompiledModExp(t *testing.T) {
 	}
 }
 
+// Tests the EIP-2565 repricing of the ModExp sample inputs.
+func TestPrecompiledModExpEIP2565(t *testing.T) {
+	p := PrecompiledContractsBerlin[common.HexToAddress("05")]
+	for i, want := range []uint64{1360, 1360} {
+		if have := p.RequiredGas(common.Hex2Bytes(modexpTests[i].input)); have != want {
+			t.Errorf("%s: gas mismatch: have %d, want %d", modexpTests[i].name, have, want)
+		}
+	}
+	// Trivial inputs are subject to the minimum price
+	if have := p.RequiredGas(nil); have != 200 {
+		t.Errorf("empty input: gas mismatch: have %d, want %d", have, 200)
+	}
+}
+
 // Benchmarks the sample inputs from the ModExp EIP 198.
 func BenchmarkPrecompiledModExp(bench *testing.B) {
 	for _, test := range modexpTests {
@@ -19,6 +19,7 @@ package eth
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -27,9 +28,12 @@ import (
 
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/contracts"
 	"github.com/tomochain/tomochain/core"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/miner"
 	"github.com/tomochain/tomochain/params"
@@ -169,6 +173,33 @@ func (api *PrivateMinerAPI) Stop() bool {
 	return true
 }
 
+// Pause stops proposing blocks once the current slot is done, while the node
+// keeps validating the chain. The pause survives restarts until Resume.
+func (api *PrivateMinerAPI) Pause() bool {
+	api.e.Miner().Pause()
+	return true
+}
+
+// Resume lifts the maintenance pause set by Pause.
+func (api *PrivateMinerAPI) Resume() bool {
+	api.e.Miner().Resume()
+	return true
+}
+
+// Paused returns whether block sealing is paused for maintenance.
+func (api *PrivateMinerAPI) Paused() bool {
+	return api.e.Miner().Paused()
+}
+
+// Health returns the outcome of the last health check of the node, running
+// one first if none ran yet.
+func (api *PrivateMinerAPI) Health() *HealthReport {
+	if report := api.e.health.report(); report != nil {
+		return report
+	}
+	return api.e.health.check()
+}
+
 // SetExtra sets the extra data string that is included when this miner mines a block.
 func (api *PrivateMinerAPI) SetExtra(extra string) (bool, error) {
 	if err := api.e.Miner().SetExtra([]byte(extra)); err != nil {
@@ -303,14 +334,42 @@ func NewPublicDebugAPI(eth *Ethereum) *PublicDebugAPI {
 	return &PublicDebugAPI{eth: eth}
 }
 
+// AccountRangeMaxResults is the maximum number of accounts returned by a single
+// debug_accountRange call.
+const AccountRangeMaxResults = 256
+
 // DumpBlock retrieves the entire state of the database at a given block.
 func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
+	stateDb, err := api.stateAtBlock(blockNr)
+	if err != nil {
+		return state.Dump{}, err
+	}
+	return stateDb.RawDump(), nil
+}
+
+// AccountRange retrieves a chunk of the state at a given block, starting at the
+// given hashed address and holding at most maxResults accounts. The next key of
+// the result continues the dump, so that the full state can be exported without
+// holding it in a single response.
+func (api *PublicDebugAPI) AccountRange(blockNr rpc.BlockNumber, start common.Hash, maxResults int, excludeCode, excludeStorage bool) (state.IteratorDump, error) {
+	stateDb, err := api.stateAtBlock(blockNr)
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	if maxResults <= 0 || maxResults > AccountRangeMaxResults {
+		maxResults = AccountRangeMaxResults
+	}
+	return stateDb.IteratorDump(start, maxResults, excludeCode, excludeStorage)
+}
+
+// stateAtBlock retrieves the state of the database at a given block.
+func (api *PublicDebugAPI) stateAtBlock(blockNr rpc.BlockNumber) (*state.StateDB, error) {
 	if blockNr == rpc.PendingBlockNumber {
 		// If we're dumping the pending state, we need to request
 		// both the pending block as well as the pending state from
 		// the miner and operate on those
 		_, stateDb := api.eth.miner.Pending()
-		return stateDb.RawDump(), nil
+		return stateDb, nil
 	}
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
@@ -319,13 +378,9 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
 	if block == nil {
-		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
+		return nil, fmt.Errorf("block #%d not found", blockNr)
 	}
-	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
-	if err != nil {
-		return state.Dump{}, err
-	}
-	return stateDb.RawDump(), nil
+	return api.eth.BlockChain().StateAt(block.Root())
 }
 
 // PrivateDebugAPI is the collection of Ethereum full node APIs exposed over
@@ -347,12 +402,82 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return db.Get(hash.Bytes())
 }
 
+// PreimageRangeResult is the result of a debug_preimages API call.
+type PreimageRangeResult struct {
+	Preimages map[common.Hash]hexutil.Bytes `json:"preimages"`
+	NextKey   *common.Hash                  `json:"nextKey"` // nil if Preimages includes the last known hash.
+}
+
+// Preimages is a debug API function that iterates over the known sha3 preimages
+// in hash order, returning at most maxResult of them starting at the given hash.
+func (api *PrivateDebugAPI) Preimages(ctx context.Context, start common.Hash, maxResult int) (PreimageRangeResult, error) {
+	return preimageRange(api.eth.ChainDb(), start, maxResult)
+}
+
+func preimageRange(db ethdb.Database, start common.Hash, maxResult int) (PreimageRangeResult, error) {
+	it := core.PreimageTable(db).NewIterator(nil, start.Bytes())
+	defer it.Release()
+
+	result := PreimageRangeResult{Preimages: make(map[common.Hash]hexutil.Bytes)}
+	for len(result.Preimages) < maxResult && it.Next() {
+		if len(it.Key()) != common.HashLength {
+			continue
+		}
+		result.Preimages[common.BytesToHash(it.Key())] = common.CopyBytes(it.Value())
+	}
+	// Add the 'next key' so clients can continue downloading.
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			next := common.BytesToHash(it.Key())
+			result.NextKey = &next
+			break
+		}
+	}
+	return result, it.Error()
+}
+
 // GetBadBLocks returns a list of the last 'bad blocks' that the client has seen on the network
 // and returns them as a JSON list of block-hashes
 func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockArgs, error) {
 	return api.eth.BlockChain().BadBlocks()
 }
 
+// TrieSizes returns the statistics of the trie nodes committed since startup by
+// each subsystem: the account and storage tries, and the trading and lending tries.
+func (api *PrivateDebugAPI) TrieSizes() map[string]state.TrieSizeStats {
+	return state.TrieSizes()
+}
+
+// TraceRewards recalculates the rewards paid at the reward checkpoint of an
+// epoch, detailing the signatures counted, the reward of each masternode and
+// its split between the owner, the voters and the foundation.
+func (api *PrivateDebugAPI) TraceRewards(epoch uint64) (*contracts.RewardTrace, error) {
+	engine, ok := api.eth.engine.(*posv.Posv)
+	if !ok || api.config.Posv == nil {
+		return nil, errors.New("chain is not proof-of-stake-voting")
+	}
+	if epoch < 2 {
+		return nil, errors.New("no rewards before epoch 2")
+	}
+	if api.config.Posv.FoudationWalletAddr == (common.Address{}) {
+		return nil, errors.New("foundation wallet address is empty")
+	}
+	number := epoch * api.config.Posv.RewardCheckpoint
+	header := api.eth.blockchain.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, fmt.Errorf("reward checkpoint %d not found", number)
+	}
+	parent := api.eth.blockchain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent of reward checkpoint %d not found", number)
+	}
+	parentState, err := api.eth.blockchain.StateAt(parent.Root)
+	if err != nil {
+		return nil, err
+	}
+	return contracts.TraceRewards(engine, api.eth.blockchain, header, parentState, calcChainReward(api.config, number))
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -408,53 +533,97 @@ func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeRes
 //
 // With one parameter, returns the list of accounts modified in the specified block.
 func (api *PrivateDebugAPI) GetModifiedAccountsByNumber(startNum uint64, endNum *uint64) ([]common.Address, error) {
+	startBlock, endBlock, err := api.blockRangeByNumber(startNum, endNum)
+	if err != nil {
+		return nil, err
+	}
+	return api.getModifiedAccounts(startBlock, endBlock)
+}
+
+// GetModifiedAccountsByHash returns all accounts that have changed between the
+// two blocks specified. A change is defined as a difference in nonce, balance,
+// code hash, or storage hash.
+//
+// With one parameter, returns the list of accounts modified in the specified block.
+func (api *PrivateDebugAPI) GetModifiedAccountsByHash(startHash common.Hash, endHash *common.Hash) ([]common.Address, error) {
+	startBlock, endBlock, err := api.blockRangeByHash(startHash, endHash)
+	if err != nil {
+		return nil, err
+	}
+	return api.getModifiedAccounts(startBlock, endBlock)
+}
+
+// GetModifiedStorageByNumber returns all accounts that have changed between the
+// two blocks specified, along with the storage slots changed in each of them.
+//
+// With one parameter, returns the changes made by the specified block.
+func (api *PrivateDebugAPI) GetModifiedStorageByNumber(startNum uint64, endNum *uint64) ([]ModifiedStorage, error) {
+	startBlock, endBlock, err := api.blockRangeByNumber(startNum, endNum)
+	if err != nil {
+		return nil, err
+	}
+	return api.getModifiedStorage(startBlock, endBlock)
+}
+
+// GetModifiedStorageByHash returns all accounts that have changed between the
+// two blocks specified, along with the storage slots changed in each of them.
+//
+// With one parameter, returns the changes made by the specified block.
+func (api *PrivateDebugAPI) GetModifiedStorageByHash(startHash common.Hash, endHash *common.Hash) ([]ModifiedStorage, error) {
+	startBlock, endBlock, err := api.blockRangeByHash(startHash, endHash)
+	if err != nil {
+		return nil, err
+	}
+	return api.getModifiedStorage(startBlock, endBlock)
+}
+
+// blockRangeByNumber resolves the blocks bounding a state diff. Without an end
+// block, the range covers the changes made by the start block.
+func (api *PrivateDebugAPI) blockRangeByNumber(startNum uint64, endNum *uint64) (*types.Block, *types.Block, error) {
 	var startBlock, endBlock *types.Block
 
 	startBlock = api.eth.blockchain.GetBlockByNumber(startNum)
 	if startBlock == nil {
-		return nil, fmt.Errorf("start block %x not found", startNum)
+		return nil, nil, fmt.Errorf("start block %x not found", startNum)
 	}
 
 	if endNum == nil {
 		endBlock = startBlock
 		startBlock = api.eth.blockchain.GetBlockByHash(startBlock.ParentHash())
 		if startBlock == nil {
-			return nil, fmt.Errorf("block %x has no parent", endBlock.Number())
+			return nil, nil, fmt.Errorf("block %x has no parent", endBlock.Number())
 		}
 	} else {
 		endBlock = api.eth.blockchain.GetBlockByNumber(*endNum)
 		if endBlock == nil {
-			return nil, fmt.Errorf("end block %d not found", *endNum)
+			return nil, nil, fmt.Errorf("end block %d not found", *endNum)
 		}
 	}
-	return api.getModifiedAccounts(startBlock, endBlock)
+	return startBlock, endBlock, nil
 }
 
-// GetModifiedAccountsByHash returns all accounts that have changed between the
-// two blocks specified. A change is defined as a difference in nonce, balance,
-// code hash, or storage hash.
-//
-// With one parameter, returns the list of accounts modified in the specified block.
-func (api *PrivateDebugAPI) GetModifiedAccountsByHash(startHash common.Hash, endHash *common.Hash) ([]common.Address, error) {
+// blockRangeByHash resolves the blocks bounding a state diff. Without an end
+// block, the range covers the changes made by the start block.
+func (api *PrivateDebugAPI) blockRangeByHash(startHash common.Hash, endHash *common.Hash) (*types.Block, *types.Block, error) {
 	var startBlock, endBlock *types.Block
 	startBlock = api.eth.blockchain.GetBlockByHash(startHash)
 	if startBlock == nil {
-		return nil, fmt.Errorf("start block %x not found", startHash)
+		return nil, nil, fmt.Errorf("start block %x not found", startHash)
 	}
 
 	if endHash == nil {
 		endBlock = startBlock
 		startBlock = api.eth.blockchain.GetBlockByHash(startBlock.ParentHash())
 		if startBlock == nil {
-			return nil, fmt.Errorf("block %x has no parent", endBlock.Number())
+			return nil, nil, fmt.Errorf("block %x has no parent", endBlock.Number())
 		}
 	} else {
 		endBlock = api.eth.blockchain.GetBlockByHash(*endHash)
 		if endBlock == nil {
-			return nil, fmt.Errorf("end block %x not found", *endHash)
+			return nil, nil, fmt.Errorf("end block %x not found", *endHash)
 		}
 	}
-	return api.getModifiedAccounts(startBlock, endBlock)
+	return startBlock, endBlock, nil
 }
 
 func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Block) ([]common.Address, error) {
@@ -485,6 +654,126 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 	return dirty, nil
 }
 
+// ModifiedStorage is an account changed between two blocks, along with the
+// storage slots changed in it, keyed by the hash of the slot.
+type ModifiedStorage struct {
+	Address common.Address `json:"address"`
+	Storage storageDiff    `json:"storage"`
+}
+
+type storageDiff map[common.Hash]storageChange
+
+type storageChange struct {
+	Key  *common.Hash `json:"key"`
+	From common.Hash  `json:"from"`
+	To   common.Hash  `json:"to"`
+}
+
+func (api *PrivateDebugAPI) getModifiedStorage(startBlock, endBlock *types.Block) ([]ModifiedStorage, error) {
+	if startBlock.Number().Uint64() >= endBlock.Number().Uint64() {
+		return nil, fmt.Errorf("start block height (%d) must be less than end block height (%d)", startBlock.Number().Uint64(), endBlock.Number().Uint64())
+	}
+	return modifiedStorage(trie.NewDatabase(api.eth.chainDb), startBlock.Root(), endBlock.Root())
+}
+
+// modifiedStorage returns the accounts of the state with the new root that differ
+// from the state with the old root, along with their changed storage slots.
+func modifiedStorage(db *trie.Database, oldRoot, newRoot common.Hash) ([]ModifiedStorage, error) {
+	oldTrie, err := trie.NewSecure(oldRoot, db)
+	if err != nil {
+		return nil, err
+	}
+	newTrie, err := trie.NewSecure(newRoot, db)
+	if err != nil {
+		return nil, err
+	}
+	diff, _ := trie.NewDifferenceIterator(oldTrie.NodeIterator([]byte{}), newTrie.NodeIterator([]byte{}))
+	iter := trie.NewIterator(diff)
+
+	var modified []ModifiedStorage
+	for iter.Next() {
+		key := newTrie.GetKey(iter.Key)
+		if key == nil {
+			return nil, fmt.Errorf("no preimage found for hash %x", iter.Key)
+		}
+		var newAccount, oldAccount state.Account
+		if err := rlp.DecodeBytes(iter.Value, &newAccount); err != nil {
+			return nil, err
+		}
+		oldAccount.Root = types.EmptyRootHash
+		if enc, err := oldTrie.TryGet(key); err != nil {
+			return nil, err
+		} else if len(enc) > 0 {
+			if err := rlp.DecodeBytes(enc, &oldAccount); err != nil {
+				return nil, err
+			}
+		}
+		storage := storageDiff{}
+		if oldAccount.Root != newAccount.Root {
+			if storage, err = diffStorage(db, oldAccount.Root, newAccount.Root); err != nil {
+				return nil, err
+			}
+		}
+		modified = append(modified, ModifiedStorage{Address: common.BytesToAddress(key), Storage: storage})
+	}
+	if iter.Err != nil {
+		return nil, iter.Err
+	}
+	return modified, nil
+}
+
+// diffStorage returns the slots that differ between two storage tries, cleared
+// slots included.
+func diffStorage(db *trie.Database, oldRoot, newRoot common.Hash) (storageDiff, error) {
+	// Storage tries are keyed by the slot hashes, look them up as plain tries
+	oldTrie, err := trie.New(oldRoot, db)
+	if err != nil {
+		return nil, err
+	}
+	newTrie, err := trie.New(newRoot, db)
+	if err != nil {
+		return nil, err
+	}
+	value := func(tr *trie.Trie, hash []byte) (common.Hash, error) {
+		enc, err := tr.TryGet(hash)
+		if err != nil || len(enc) == 0 {
+			return common.Hash{}, err
+		}
+		_, content, _, err := rlp.Split(enc)
+		return common.BytesToHash(content), err
+	}
+	result := storageDiff{}
+	// Slots set or updated in the new trie, then slots cleared from the old one
+	for _, tries := range [][2]*trie.Trie{{oldTrie, newTrie}, {newTrie, oldTrie}} {
+		diff, _ := trie.NewDifferenceIterator(tries[0].NodeIterator([]byte{}), tries[1].NodeIterator([]byte{}))
+		iter := trie.NewIterator(diff)
+		for iter.Next() {
+			hash := common.BytesToHash(iter.Key)
+			if _, ok := result[hash]; ok {
+				continue
+			}
+			from, err := value(oldTrie, iter.Key)
+			if err != nil {
+				return nil, err
+			}
+			to, err := value(newTrie, iter.Key)
+			if err != nil {
+				return nil, err
+			}
+			change := storageChange{From: from, To: to}
+			if preimage, _ := db.Preimage(hash); preimage != nil {
+				preimage := common.BytesToHash(preimage)
+				change.Key = &preimage
+			}
+			result[hash] = change
+		}
+		if iter.Err != nil {
+			return nil, iter.Err
+		}
+	}
+	return result, nil
+}
+
 func (api *PublicEthereumAPI) ChainId() hexutil.Uint64 {
 	chainID := new(big.Int)
 	if config := api.e.chainConfig; config.IsEIP155(api.e.blockchain.CurrentBlock().Number()) {
@@ -95,7 +95,11 @@ func (b *EthApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 	if blockNr == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock(), nil
 	}
-	return b.eth.blockchain.GetBlockByNumber(uint64(blockNr)), nil
+	block := b.eth.blockchain.GetBlockByNumber(uint64(blockNr))
+	if block == nil && b.eth.blockchain.HistoryPruned(uint64(blockNr)) {
+		return nil, core.ErrHistoryPruned
+	}
+	return block, nil
 }
 
 func (b *EthApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
@@ -113,26 +117,44 @@ func (b *EthApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	return stateDb, header, err
 }
 
+func (b *EthApiBackend) StateVerifyRatio() float64 {
+	return b.eth.config.RPCStateVerifyRatio
+}
+
+func (b *EthApiBackend) CallCacheSize() int {
+	return b.eth.config.RPCCallCacheSize
+}
+
 func (b *EthApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
-	return b.eth.blockchain.GetBlockByHash(blockHash), nil
+	block := b.eth.blockchain.GetBlockByHash(blockHash)
+	if block == nil && b.historyPruned(blockHash) {
+		return nil, core.ErrHistoryPruned
+	}
+	return block, nil
 }
 
 func (b *EthApiBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
-	return core.GetBlockReceipts(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash)), nil
+	receipts := core.GetBlockReceipts(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash), b.eth.chainConfig)
+	if receipts == nil && b.historyPruned(blockHash) {
+		return nil, core.ErrHistoryPruned
+	}
+	return receipts, nil
 }
 
 func (b *EthApiBackend) GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error) {
-	receipts := core.GetBlockReceipts(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash))
-	if receipts == nil {
-		return nil, nil
-	}
-	logs := make([][]*types.Log, len(receipts))
-	for i, receipt := range receipts {
-		logs[i] = receipt.Logs
+	logs := core.GetBlockLogs(b.eth.chainDb, blockHash, core.GetBlockNumber(b.eth.chainDb, blockHash))
+	if logs == nil && b.historyPruned(blockHash) {
+		return nil, core.ErrHistoryPruned
 	}
 	return logs, nil
 }
 
+// historyPruned reports whether the body and receipts of the block with the
+// given hash were deleted by history expiry.
+func (b *EthApiBackend) historyPruned(blockHash common.Hash) bool {
+	return b.eth.blockchain.HistoryPruned(core.GetBlockNumber(b.eth.chainDb, blockHash))
+}
+
 func (b *EthApiBackend) GetTd(blockHash common.Hash) *big.Int {
 	return b.eth.blockchain.GetTdByHash(blockHash)
 }
@@ -145,6 +167,10 @@ func (b *EthApiBackend) GetEVM(ctx context.Context, msg core.Message, state *sta
 	return vm.NewEVM(context, state, tomoxState, b.eth.chainConfig, vmCfg), vmError, nil
 }
 
+func (b *EthApiBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return b.eth.BlockChain().SubscribeChainReorgEvent(ch)
+}
+
 func (b *EthApiBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.eth.BlockChain().SubscribeRemovedLogsEvent(ch)
 }
@@ -195,6 +221,14 @@ func (b *EthApiBackend) GetPoolTransaction(hash common.Hash) *types.Transaction
 	return b.eth.txPool.Get(hash)
 }
 
+func (b *EthApiBackend) GetPoolTransactionInfo(hash common.Hash) *core.TxPoolInfo {
+	return b.eth.txPool.Info(hash)
+}
+
+func (b *EthApiBackend) GetPoolGapStatus() *core.TxGapStatus {
+	return b.eth.txPool.GapStatus()
+}
+
 func (b *EthApiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.eth.txPool.State().GetNonce(addr), nil
 }
@@ -304,7 +338,7 @@ func (b *EthApiBackend) GetVotersRewards(masternodeAddr common.Address) map[comm
 	number := block.Number().Uint64()
 	engine := b.GetEngine().(*posv.Posv)
 	foundationWalletAddr := chain.Config().Posv.FoudationWalletAddr
-	lastCheckpointNumber := number - (number % b.ChainConfig().Posv.Epoch) - b.ChainConfig().Posv.Epoch // calculate for 2 epochs ago
+	lastCheckpointNumber, _ := b.ChainConfig().Posv.PreviousCheckpoint(number, 1) // calculate for 2 epochs ago
 	lastCheckpointBlock := chain.GetBlockByNumber(lastCheckpointNumber)
 	rCheckpoint := chain.Config().Posv.RewardCheckpoint
 
@@ -323,14 +357,7 @@ func (b *EthApiBackend) GetVotersRewards(masternodeAddr common.Address) map[comm
 		return nil
 	}
 
-	// Get initial reward
-	initialRewardPerEpoch := new(big.Int).Mul(new(big.Int).SetUint64(chain.Config().Posv.Reward), new(big.Int).SetUint64(params.Ether))
-	chainReward := calcInitialReward(initialRewardPerEpoch, lastCheckpointNumber, common.BlocksPerYear)
-	// Get additional reward for Saigon upgrade
-	if chain.Config().IsSaigon(chain.Config().SaigonBlock) {
-		saigonRewardPerEpoch := new(big.Int).Mul(common.SaigonRewardPerEpoch, new(big.Int).SetUint64(params.Ether))
-		chainReward = new(big.Int).Add(chainReward, calcSaigonReward(saigonRewardPerEpoch, chain.Config().SaigonBlock, lastCheckpointNumber, common.BlocksPerYear))
-	}
+	chainReward := calcChainReward(chain.Config(), lastCheckpointNumber)
 
 	totalSigner := new(uint64)
 	signers, err := contracts.GetRewardForCheckpoint(engine, chain, lastCheckpointBlock.Header(), rCheckpoint, totalSigner)
@@ -393,9 +420,9 @@ func (b *EthApiBackend) GetEpochDuration() *big.Int {
 	chain := b.eth.blockchain
 	block := chain.CurrentBlock()
 	number := block.Number().Uint64()
-	lastCheckpointNumber := number - (number % b.ChainConfig().Posv.Epoch)
+	lastCheckpointNumber := b.ChainConfig().Posv.Checkpoint(number)
 	lastCheckpointBlockTime := chain.GetBlockByNumber(lastCheckpointNumber).Time()
-	secondToLastCheckpointNumber := lastCheckpointNumber - b.ChainConfig().Posv.Epoch
+	secondToLastCheckpointNumber, _ := b.ChainConfig().Posv.PreviousCheckpoint(number, 1)
 	secondToLastCheckpointBlockTime := chain.GetBlockByNumber(secondToLastCheckpointNumber).Time()
 
 	return secondToLastCheckpointBlockTime.Add(secondToLastCheckpointBlockTime, lastCheckpointBlockTime.Mul(lastCheckpointBlockTime, new(big.Int).SetInt64(-1)))
@@ -23,11 +23,52 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
 	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/crypto"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
 
+func TestPreimageRange(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	preimages := make(map[common.Hash][]byte)
+	for i := byte(0); i < 5; i++ {
+		blob := []byte{i}
+		preimages[crypto.Keccak256Hash(blob)] = blob
+	}
+	if err := core.WritePreimages(db, 0, preimages); err != nil {
+		t.Fatalf("failed to write preimages: %v", err)
+	}
+	// Page through all preimages and ensure every one is returned exactly once
+	var (
+		start common.Hash
+		found = make(map[common.Hash][]byte)
+	)
+	for pages := 0; ; pages++ {
+		if pages > len(preimages) {
+			t.Fatalf("iteration did not terminate")
+		}
+		result, err := preimageRange(db, start, 2)
+		if err != nil {
+			t.Fatalf("preimage range failed: %v", err)
+		}
+		for hash, blob := range result.Preimages {
+			if _, ok := found[hash]; ok {
+				t.Errorf("preimage %x returned twice", hash)
+			}
+			found[hash] = blob
+		}
+		if result.NextKey == nil {
+			break
+		}
+		start = *result.NextKey
+	}
+	if !reflect.DeepEqual(found, preimages) {
+		t.Errorf("preimage mismatch:\nhave %s\nwant %s", dumper.Sdump(found), dumper.Sdump(preimages))
+	}
+}
+
 func TestStorageRangeAt(t *testing.T) {
 	// Create a state where account 0x010000... has a few storage entries.
 	var (
@@ -89,3 +130,52 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+func TestModifiedStorage(t *testing.T) {
+	var (
+		db         = rawdb.NewMemoryDatabase()
+		sdb        = state.NewDatabase(db)
+		statedb, _ = state.New(common.Hash{}, sdb)
+		contract   = common.Address{0x01}
+		other      = common.Address{0x02}
+		untouched  = common.Address{0x03}
+	)
+	statedb.SetState(contract, common.Hash{0x01}, common.Hash{0x01})
+	statedb.SetState(contract, common.Hash{0x02}, common.Hash{0x02})
+	statedb.SetState(contract, common.Hash{0x03}, common.Hash{0x03})
+	statedb.SetNonce(other, 1)
+	statedb.SetNonce(untouched, 1)
+	oldRoot, _ := statedb.Commit(false)
+
+	// Update, clear and add a slot, and touch another account without storage
+	statedb, _ = state.New(oldRoot, sdb)
+	statedb.SetState(contract, common.Hash{0x01}, common.Hash{0x11})
+	statedb.SetState(contract, common.Hash{0x02}, common.Hash{})
+	statedb.SetState(contract, common.Hash{0x04}, common.Hash{0x04})
+	statedb.SetNonce(other, 2)
+	newRoot, _ := statedb.Commit(false)
+
+	modified, err := modifiedStorage(sdb.TrieDB(), oldRoot, newRoot)
+	if err != nil {
+		t.Fatalf("failed to diff state: %v", err)
+	}
+	change := func(key, from, to common.Hash) (common.Hash, storageChange) {
+		return crypto.Keccak256Hash(key[:]), storageChange{Key: &key, From: from, To: to}
+	}
+	want := map[common.Address]storageDiff{contract: {}, other: {}}
+	for _, c := range [][3]common.Hash{
+		{{0x01}, {0x01}, {0x11}},
+		{{0x02}, {0x02}, {}},
+		{{0x04}, {}, {0x04}},
+	} {
+		hash, entry := change(c[0], c[1], c[2])
+		want[contract][hash] = entry
+	}
+	have := make(map[common.Address]storageDiff)
+	for _, account := range modified {
+		have[account.Address] = account.Storage
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("storage diff mismatch:\nhave %s\nwant %s", dumper.Sdump(have), dumper.Sdump(want))
+	}
+}
@@ -33,14 +33,19 @@ import (
 	"github.com/tomochain/tomochain/common/hexutil"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/eth/filters"
+	"github.com/tomochain/tomochain/eth/watch"
 	"github.com/tomochain/tomochain/rlp"
 
 	"bytes"
 
+	"github.com/VictoriaMetrics/fastcache"
 	"github.com/tomochain/tomochain/accounts"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/clique"
+	"github.com/tomochain/tomochain/consensus/dev"
 	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/consensus/exchange"
 	"github.com/tomochain/tomochain/consensus/posv"
 	"github.com/tomochain/tomochain/contracts"
 	contractValidator "github.com/tomochain/tomochain/contracts/validator/contract"
@@ -105,6 +110,7 @@ type Ethereum struct {
 
 	networkId     uint64
 	netRPCService *ethapi.PublicNetAPI
+	health        *healthMonitor
 
 	lock    sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 	TomoX   *tomox.TomoX
@@ -129,11 +135,12 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 	if err != nil {
 		return nil, err
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
+	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, &config.Overrides)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
 
+	chainConfig.ApplyTomoXForks()
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
 	eth := &Ethereum{
@@ -168,16 +175,25 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, NoPreimages: config.NoPreimages, RetainBlocks: config.RetainState, StateVerifyInterval: config.StateVerifyInterval, HistoryExpiry: config.HistoryExpiry}
 	)
-	if eth.chainConfig.Posv != nil {
-		c := eth.engine.(*posv.Posv)
-		c.GetTomoXService = func() posv.TradingService {
+	// Share a single clean trie node cache between the state and the TomoX tries,
+	// so memory is spent on whichever nodes are hot.
+	if config.TrieCleanCache > 0 {
+		cacheConfig.TrieCleans = fastcache.New(config.TrieCleanCache * 1024 * 1024)
+	}
+	if tomoXServ != nil {
+		tomoXServ.SetTrieCleanCache(cacheConfig.TrieCleans)
+	}
+	if lendingServ != nil {
+		lendingServ.SetTrieCleanCache(cacheConfig.TrieCleans)
+	}
+	if engine, ok := eth.engine.(exchange.Engine); ok {
+		engine.SetServices(func() exchange.TradingService {
 			return eth.TomoX
-		}
-		c.GetLendingService = func() posv.LendingService {
+		}, func() exchange.LendingService {
 			return eth.Lending
-		}
+		})
 	}
 	eth.blockchain, err = core.NewBlockChainEx(chainDb, tomoXServ.GetLevelDB(), cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
@@ -196,6 +212,10 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 	}
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
 	eth.orderPool = core.NewOrderPool(eth.chainConfig, eth.blockchain)
+	if tomoXServ != nil {
+		// orders are gossiped over the tomox protocol from and to the order pool
+		tomoXServ.SetOrderPool(eth.orderPool)
+	}
 	eth.lendingPool = core.NewLendingPool(eth.chainConfig, eth.blockchain)
 	if common.RollbackHash != common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000") {
 		curBlock := eth.blockchain.CurrentBlock()
@@ -219,6 +239,8 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 	if eth.protocolManager, err = NewProtocolManagerEx(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.orderPool, eth.lendingPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	eth.protocolManager.bookSampling = config.OrderBookSampling
+	eth.health = newHealthMonitor(eth, config.Health)
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, ctx.GetConfig().AnnounceTxs)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
 
@@ -234,6 +256,7 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 
 	if eth.chainConfig.Posv != nil {
 		c := eth.engine.(*posv.Posv)
+		c.CrossValidation = config.CheckpointCrossValidation
 		signHook := func(block *types.Block) error {
 			eb, err := eth.Etherbase()
 			if err != nil {
@@ -305,7 +328,7 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 			if canonicalState == nil || err != nil {
 				log.Crit("Can't get state at head of canonical chain", "head number", eth.blockchain.CurrentHeader().Number.Uint64(), "err", err)
 			}
-			prevEpoc := blockNumberEpoc - chain.Config().Posv.Epoch
+			prevEpoc, _ := chain.Config().Posv.PreviousCheckpoint(blockNumberEpoc, 1)
 			if prevEpoc >= 0 {
 				start := time.Now()
 				prevHeader := chain.GetHeaderByNumber(prevEpoc)
@@ -347,25 +370,23 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 
 		// Hook scans for bad masternodes and decide to penalty them
 		c.HookPenaltyTIPSigning = func(chain consensus.ChainReader, header *types.Header, candidates []common.Address) ([]common.Address, error) {
-			prevEpoc := header.Number.Uint64() - chain.Config().Posv.Epoch
-			combackEpoch := uint64(0)
-			comebackLength := (common.LimitPenaltyEpoch + 1) * chain.Config().Posv.Epoch
-			if header.Number.Uint64() > comebackLength {
-				combackEpoch = header.Number.Uint64() - comebackLength
-			}
+			prevEpoc, _ := chain.Config().Posv.PreviousCheckpoint(header.Number.Uint64(), 1)
+			combackEpoch, _ := chain.Config().Posv.PreviousCheckpoint(header.Number.Uint64(), common.LimitPenaltyEpoch+1)
+			epochLength := header.Number.Uint64() - prevEpoc
 			if prevEpoc >= 0 {
 				start := time.Now()
 
-				listBlockHash := make([]common.Hash, chain.Config().Posv.Epoch)
+				listBlockHash := make([]common.Hash, epochLength)
 
 				// get list block hash & stats total created block
 				statMiners := make(map[common.Address]int)
 				listBlockHash[0] = header.ParentHash
 				parentnumber := header.Number.Uint64() - 1
 				parentHash := header.ParentHash
-				for i := uint64(1); i < chain.Config().Posv.Epoch; i++ {
+				for i := uint64(1); i < epochLength; i++ {
 					parentHeader := chain.GetHeader(parentHash, parentnumber)
 					miner, _ := c.RecoverSigner(parentHeader)
+					miner = c.MasternodeOf(chain, parentHeader.Number.Uint64(), miner)
 					value, exist := statMiners[miner]
 					if exist {
 						value = value + 1
@@ -428,7 +449,7 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 						// Check signer signed?
 						for _, tx := range txs {
 							blkHash := common.BytesToHash(tx.Data()[len(tx.Data())-32:])
-							from := *tx.From()
+							from := c.MasternodeOf(chain, blockNumber, *tx.From())
 							if mapBlockHash[blkHash] {
 								for j, addr := range penComebacks {
 									if from == addr {
@@ -503,6 +524,18 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 			return result, nil
 		}
 
+		// Hook sends the evidence of a masternode signing two blocks of the same number
+		c.HookEvidence = func(evidence *posv.Evidence) error {
+			eb, err := eth.Etherbase()
+			if err != nil {
+				return fmt.Errorf("etherbase missing: %v", err)
+			}
+			if eth.txPool.IsSigner == nil || !eth.txPool.IsSigner(eb) {
+				return nil
+			}
+			return contracts.CreateTransactionEvidence(chainConfig, eth.txPool, eth.accountManager, evidence, eb)
+		}
+
 		// Hook calculates reward for masternodes
 		c.HookReward = func(chain consensus.ChainReader, stateBlock *state.StateDB, parentState *state.StateDB, header *types.Header) (error, map[string]interface{}) {
 			number := header.Number.Uint64()
@@ -515,14 +548,7 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 			rewards := make(map[string]interface{})
 			if number > 0 && number-rCheckpoint > 0 && foundationWalletAddr != (common.Address{}) {
 				start := time.Now()
-				// Get initial reward
-				initialRewardPerEpoch := new(big.Int).Mul(new(big.Int).SetUint64(chain.Config().Posv.Reward), new(big.Int).SetUint64(params.Ether))
-				chainReward := calcInitialReward(initialRewardPerEpoch, number, common.BlocksPerYear)
-				// Get additional reward for Saigon upgrade
-				if chain.Config().IsSaigon(chain.Config().SaigonBlock) {
-					saigonRewardPerEpoch := new(big.Int).Mul(common.SaigonRewardPerEpoch, new(big.Int).SetUint64(params.Ether))
-					chainReward = new(big.Int).Add(chainReward, calcSaigonReward(saigonRewardPerEpoch, chain.Config().SaigonBlock, number, common.BlocksPerYear))
-				}
+				chainReward := calcChainReward(chain.Config(), number)
 
 				totalSigner := new(uint64)
 				signers, err := contracts.GetRewardForCheckpoint(c, chain, header, rCheckpoint, totalSigner)
@@ -558,10 +584,25 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 			return nil, rewards
 		}
 
+		// Hook reads the signing keys registered for the candidates at the gap block
+		c.HookSigningKeys = func(chain consensus.ChainReader, gap *types.Header) (map[common.Address]common.Address, error) {
+			statedb, err := eth.blockchain.StateAt(gap.Root)
+			if err != nil {
+				return nil, err
+			}
+			keys := make(map[common.Address]common.Address)
+			for _, candidate := range state.GetCandidates(statedb) {
+				if key := state.GetCandidateSigner(statedb, candidate); key != (common.Address{}) {
+					keys[candidate] = key
+				}
+			}
+			return keys, nil
+		}
+
 		// Hook verifies masternodes set
 		c.HookVerifyMNs = func(header *types.Header, signers []common.Address) error {
 			number := header.Number.Int64()
-			if number > 0 && number%common.EpocBlockRandomize == 0 {
+			if number > 0 && chainConfig.Posv.IsCheckpoint(uint64(number)) {
 				start := time.Now()
 				validators, err := GetValidators(eth.blockchain, signers)
 				log.Debug("Time Calculated HookVerifyMNs ", "block", header.Number.Uint64(), "time", common.PrettyDuration(time.Since(start)))
@@ -590,7 +631,7 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 				log.Error("Can't get snapshot with at ", "number", header.Number, "hash", header.Hash().Hex(), "err", err)
 				return false
 			}
-			if _, ok := snap.Signers[address]; ok {
+			if _, ok := snap.Signers[c.MasternodeOf(eth.blockchain, currentHeader.Number.Uint64(), address)]; ok {
 				return true
 			}
 			return false
@@ -632,6 +673,14 @@ func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chai
 	if chainConfig.Posv != nil {
 		return posv.New(chainConfig.Posv, db)
 	}
+	// Private networks may rather seal by proof-of-authority or instantly
+	if chainConfig.Clique != nil {
+		return clique.New(chainConfig.Clique, db)
+	}
+	if chainConfig.Dev != nil {
+		log.Warn("Blocks sealed instantly, for private networks only")
+		return dev.New(chainConfig.Dev)
+	}
 
 	// Otherwise assume proof-of-work
 	switch {
@@ -663,6 +712,15 @@ func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chai
 func (s *Ethereum) APIs() []rpc.API {
 	apis := ethapi.GetAPIs(s.ApiBackend)
 
+	// Proof-of-work leftovers kept around for tooling compatibility only
+	rpc.RegisterDeprecatedMethods(
+		"eth_hashrate",
+		"eth_getWork",
+		"eth_submitWork",
+		"eth_submitHashrate",
+		"miner_getHashrate",
+	)
+
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
@@ -693,6 +751,11 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   filters.NewPublicFilterAPI(s.ApiBackend, false),
 			Public:    true,
+		}, {
+			Namespace: "watch",
+			Version:   "1.0",
+			Service:   watch.NewPublicWatchAPI(s.blockchain),
+			Public:    true,
 		}, {
 			Namespace: "admin",
 			Version:   "1.0",
@@ -788,6 +851,14 @@ func (s *Ethereum) StartStaking(local bool) error {
 		}
 		posv.Authorize(eb, wallet.SignHash)
 	}
+	if clique, ok := s.engine.(*clique.Clique); ok {
+		wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
+		if wallet == nil || err != nil {
+			log.Error("Etherbase account unavailable locally", "err", err)
+			return fmt.Errorf("signer missing: %v", err)
+		}
+		clique.Authorize(eb, wallet.SignHash)
+	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
 		// mechanism introduced to speed sync times. CPU mining on mainnet is ludicrous
@@ -847,6 +918,9 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	if s.config.Health.Interval > 0 {
+		go s.health.loop()
+	}
 	return nil
 }
 func (s *Ethereum) SaveData() {
@@ -906,6 +980,20 @@ func GetValidators(bc *core.BlockChain, masternodes []common.Address) ([]byte, e
 	return nil, core.ErrNotFoundM1
 }
 
+// calcChainReward returns the reward of the chain shared by the signers
+// rewarded at the reward checkpoint.
+func calcChainReward(config *params.ChainConfig, number uint64) *big.Int {
+	// Get initial reward
+	initialRewardPerEpoch := new(big.Int).Mul(new(big.Int).SetUint64(config.Posv.Reward), new(big.Int).SetUint64(params.Ether))
+	chainReward := calcInitialReward(initialRewardPerEpoch, number, common.BlocksPerYear)
+	// Get additional reward for Saigon upgrade
+	if config.IsSaigon(config.SaigonBlock) {
+		saigonRewardPerEpoch := new(big.Int).Mul(common.SaigonRewardPerEpoch, new(big.Int).SetUint64(params.Ether))
+		chainReward = new(big.Int).Add(chainReward, calcSaigonReward(saigonRewardPerEpoch, config.SaigonBlock, number, common.BlocksPerYear))
+	}
+	return chainReward
+}
+
 func calcInitialReward(rewardPerEpoch *big.Int, number uint64, blockPerYear uint64) *big.Int {
 	// Stop reward from 8th year onwards
 	if blockPerYear*8 <= number {
@@ -0,0 +1,107 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	bookSampleInterval = 16 // Number of blocks between two rounds of order book samples
+	maxBookSamples     = 8  // Maximum number of order books sampled per round
+)
+
+// bookSampleLoop periodically samples the order books of the chain head and
+// sends the samples to the peers, until the chain head subscription ends.
+func (pm *ProtocolManager) bookSampleLoop() {
+	for {
+		select {
+		case ev := <-pm.chainHeadCh:
+			// Sampling an unsynced head only produces noise on the remote side
+			if atomic.LoadUint32(&pm.acceptTxs) == 0 || ev.Block.NumberU64()%bookSampleInterval != 0 {
+				continue
+			}
+			samples := pm.sampleOrderBooks(ev.Block)
+			if len(samples) == 0 {
+				continue
+			}
+			peers := pm.peers.PeersWithVersion(eth100)
+			for _, peer := range peers {
+				peer.SendOrderBookSamples(samples)
+			}
+			bookSampleOutMeter.Mark(int64(len(samples) * len(peers)))
+			log.Trace("Broadcast order book samples", "number", ev.Block.Number(), "samples", len(samples), "recipients", len(peers))
+
+		case <-pm.chainHeadSub.Err():
+			return
+		}
+	}
+}
+
+// sampleOrderBooks picks a random subset of the order books of the given block
+// and returns their state roots.
+func (pm *ProtocolManager) sampleOrderBooks(block *types.Block) []*orderBookSample {
+	tradingState, err := pm.blockchain.OrderStateAt(block)
+	if err != nil {
+		return nil
+	}
+	books := tradingState.GetAllOrderBooks()
+	rand.Shuffle(len(books), func(i, j int) { books[i], books[j] = books[j], books[i] })
+	if len(books) > maxBookSamples {
+		books = books[:maxBookSamples]
+	}
+	samples := make([]*orderBookSample, 0, len(books))
+	for _, book := range books {
+		samples = append(samples, &orderBookSample{Block: block.Hash(), Book: book, Root: tradingState.GetOrderBookRoot(book)})
+	}
+	return samples
+}
+
+// checkBookSamples compares the order book samples of a peer with the local
+// state, and reports the order books whose state diverges.
+func (pm *ProtocolManager) checkBookSamples(p *peer, samples []*orderBookSample) {
+	states := make(map[common.Hash]*tradingstate.TradingStateDB)
+	for _, sample := range samples {
+		tradingState, ok := states[sample.Block]
+		if !ok {
+			if block := pm.blockchain.GetBlockByHash(sample.Block); block != nil {
+				tradingState, _ = pm.blockchain.OrderStateAt(block)
+			}
+			states[sample.Block] = tradingState
+		}
+		if tradingState == nil {
+			// Block not imported yet, or no trading state to compare against
+			bookSampleUnknownMeter.Mark(1)
+			continue
+		}
+		if root := tradingState.GetOrderBookRoot(sample.Book); root != sample.Root {
+			bookSampleMismatchMeter.Mark(1)
+			log.Warn("Order book state diverges from peer", "peer", p.id, "block", sample.Block, "book", sample.Book, "local", root, "remote", sample.Root)
+			continue
+		}
+		bookSampleMatchMeter.Mark(1)
+	}
+}
@@ -43,14 +43,16 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     88,
-	LightPeers:    100,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   5 * time.Minute,
-	GasPrice:      big.NewInt(0.25 * params.Shannon),
+	NetworkId:      88,
+	LightPeers:     100,
+	DatabaseCache:  512,
+	TrieCleanCache: 256,
+	TrieCache:      256,
+	TrieTimeout:    5 * time.Minute,
+	GasPrice:       big.NewInt(0.25 * params.Shannon),
 
 	TxPool: core.DefaultTxPoolConfig,
+	Health: DefaultHealthConfig,
 	GPO: gasprice.Config{
 		Blocks:     20,
 		Percentile: 60,
@@ -78,19 +80,33 @@ type Config struct {
 	// If nil, the Ethereum main net block is used.
 	Genesis *core.Genesis `toml:",omitempty"`
 
+	// Fork blocks to move or schedule on startup, persisted in the chain config
+	Overrides core.ChainOverrides `toml:"-"`
+
 	// Protocol options
-	NetworkId uint64 // Network ID to use for selecting peers to connect to
-	SyncMode  downloader.SyncMode
-	NoPruning bool
+	NetworkId   uint64 // Network ID to use for selecting peers to connect to
+	SyncMode    downloader.SyncMode
+	NoPruning   bool
+	NoPreimages bool   // Whether to discard the preimages of trie keys
+	RetainState uint64 `toml:",omitempty"` // Interval of blocks whose full state is kept when pruning (0 = none)
+
+	StateVerifyInterval time.Duration `toml:",omitempty"` // Interval between background integrity checks of recent state (0 = disabled)
+	HistoryExpiry       uint64        `toml:",omitempty"` // Block number below which bodies and receipts are deleted (0 = keep all)
+
+	// Check the masternodes of the checkpoints against the validator contract state
+	CheckpointCrossValidation bool `toml:",omitempty"`
 
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"` // Trusted CHT checkpoint to start light syncing from
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	TrieCleanCache     int // Clean trie node cache shared by the state, trading and lending tries
 	TrieCache          int
 	TrieTimeout        time.Duration
 
@@ -112,6 +128,18 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Fraction of the RPC state reads verified against Merkle proofs (paranoid mode)
+	RPCStateVerifyRatio float64 `toml:",omitempty"`
+
+	// Number of eth_call results cached by block hash (zero disables the cache)
+	RPCCallCacheSize int `toml:",omitempty"`
+
+	// Exchange order book samples with peers to detect matching divergence
+	OrderBookSampling bool `toml:",omitempty"`
+
+	// Masternode health checks
+	Health HealthConfig `toml:",omitempty"`
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
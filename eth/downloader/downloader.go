@@ -571,6 +571,7 @@ func (d *Downloader) fetchHeight(p *peerConnection, hash common.Hash) (*types.He
 
 		case <-timeout:
 			p.log.Debug("Waiting for head header timed out", "elapsed", ttl)
+			p.MarkTimeout()
 			return nil, errTimeout
 
 		case <-d.bodyCh:
@@ -669,6 +670,7 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 
 		case <-timeout:
 			p.log.Debug("Waiting for head header timed out", "elapsed", ttl)
+			p.MarkTimeout()
 			return 0, errTimeout
 
 		case <-d.bodyCh:
@@ -733,6 +735,7 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 
 			case <-timeout:
 				p.log.Debug("Waiting for search header timed out", "elapsed", ttl)
+				p.MarkTimeout()
 				return 0, errTimeout
 
 			case <-d.bodyCh:
@@ -854,6 +857,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 			getHeaders(from)
 
 		case <-timeout.C:
+			p.MarkTimeout()
 			if d.dropPeer == nil {
 				// The dropPeer method is nil when `--copydb` is used for a local copy.
 				// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
@@ -1066,6 +1070,8 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 			// Check for fetch request timeouts and demote the responsible peers
 			for pid, fails := range expire() {
 				if peer := d.peers.Peer(pid); peer != nil {
+					peer.MarkTimeout()
+
 					// If a lot of retrieval elements expired, we might have overestimated the remote peer or perhaps
 					// ourselves. Only reset to minimal throughput but don't drop just yet. If even the minimal times
 					// out that sync wise we need to get rid of the peer.
@@ -1327,12 +1333,11 @@ func (d *Downloader) processFullSyncContent(height uint64) error {
 			return nil
 		}
 		if d.blockchain.Config() != nil && d.blockchain.Config().Posv != nil {
-			epoch := d.blockchain.Config().Posv.Epoch
-			gap := d.blockchain.Config().Posv.Gap
+			config := d.blockchain.Config().Posv
 			inserts := []*fetchResult{}
 			for i := 0; i < len(results); i++ {
-				number := results[i].Header.Number.Uint64() % epoch
-				if number == 0 || number == epoch-1 || number == epoch-gap {
+				number := results[i].Header.Number.Uint64()
+				if config.IsCheckpoint(number) || config.IsCheckpoint(number+1) || config.IsGap(number) {
 					inserts = append(inserts, results[i])
 					if d.chainInsertHook != nil {
 						d.chainInsertHook(inserts)
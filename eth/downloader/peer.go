@@ -90,6 +90,12 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// timeoutTracker is implemented by the peers keeping count of the requests
+// they failed to answer in time.
+type timeoutTracker interface {
+	MarkTimeout()
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -125,6 +131,14 @@ func newPeerConnection(id string, version int, peer Peer, logger log.Logger) *pe
 	}
 }
 
+// MarkTimeout reports a timed out request to the remote peer, if it keeps
+// count of them.
+func (p *peerConnection) MarkTimeout() {
+	if tracker, ok := p.peer.(timeoutTracker); ok {
+		tracker.MarkTimeout()
+	}
+}
+
 // Reset clears the internal state of a peer entity.
 func (p *peerConnection) Reset() {
 	p.lock.Lock()
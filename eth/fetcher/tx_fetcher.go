@@ -0,0 +1,363 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+)
+
+const (
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txAnnounceLimit = 4096                   // Maximum number of unique transactions a peer may have announced
+	txRequestLimit  = 256                    // Maximum number of transactions requested from a peer at once
+)
+
+// txKnownFn is a callback type to check whether a transaction is already known
+// locally, pooled or recently seen.
+type txKnownFn func(common.Hash) bool
+
+// txRequesterFn is a callback type for sending a transaction retrieval request.
+type txRequesterFn func(peer string, hashes []common.Hash) error
+
+// txAnnounce is the hash notification of the availability of new transactions
+// at a peer.
+type txAnnounce struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Hashes of the transactions being announced
+	time   time.Time     // Timestamp of the announcement
+}
+
+// txDelivery is a batch of transactions received from a peer.
+type txDelivery struct {
+	origin string        // Identifier of the peer delivering the transactions
+	hashes []common.Hash // Hashes of the delivered transactions
+	reply  bool          // Whether the transactions reply to a request, or were broadcast
+}
+
+// txAnnounced is the retrieval state of an announced transaction.
+type txAnnounced struct {
+	origins   []string  // Peers having announced the transaction, not requested yet
+	time      time.Time // Timestamp of the first announcement
+	fetching  string    // Peer the transaction is currently requested from, if any
+	requested time.Time // Timestamp of the pending request
+}
+
+// TxFetcher is responsible for retrieving the transactions announced by their
+// hashes, unless they are broadcast in full to the local node by then. Every
+// transaction is requested from a single peer at a time, the other announcers
+// being tried on timeouts and incomplete replies.
+type TxFetcher struct {
+	notify  chan *txAnnounce
+	deliver chan *txDelivery
+	drop    chan string
+	quit    chan struct{}
+
+	announces map[string]int                      // Per peer announce counts to prevent memory exhaustion
+	announced map[common.Hash]*txAnnounced        // Announced transactions, waiting or being fetched
+	requests  map[string]map[common.Hash]struct{} // Per peer transactions currently requested
+
+	// Callbacks
+	hasTx    txKnownFn     // Checks whether a transaction is already known locally
+	fetchTxs txRequesterFn // Requests a batch of transactions from a peer
+
+	// Testing hooks
+	now func() time.Time // Current time, replaced in tests
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve the transactions based
+// on hash announcements.
+func NewTxFetcher(hasTx txKnownFn, fetchTxs txRequesterFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		deliver:   make(chan *txDelivery),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		announces: make(map[string]int),
+		announced: make(map[common.Hash]*txAnnounced),
+		requests:  make(map[string]map[common.Hash]struct{}),
+		hasTx:     hasTx,
+		fetchTxs:  fetchTxs,
+		now:       time.Now,
+	}
+}
+
+// Start boots up the transaction fetcher, accepting announcements and deliveries
+// until termination requested.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the transaction fetcher, canceling all pending retrievals.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the availability of new transactions at a
+// peer.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash, time time.Time) error {
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: hashes, time: time}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Enqueue notifies the fetcher of the transactions received from a peer, in a
+// reply to a retrieval request or broadcast, so that they are not requested
+// anymore. The requested transactions missing from a reply are requested from
+// the other peers having announced them.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, reply bool) error {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.deliver <- &txDelivery{origin: peer, hashes: hashes, reply: reply}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Drop forgets the announcements of a disconnected peer, requesting the
+// transactions it was fetching from the other announcers.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main fetcher loop, processing the announcements and deliveries
+// and scheduling the retrievals.
+func (f *TxFetcher) loop() {
+	fetchTimer := time.NewTimer(0)
+	defer fetchTimer.Stop()
+
+	for {
+		select {
+		case <-f.quit:
+			return
+
+		case ann := <-f.notify:
+			txAnnounceInMeter.Mark(int64(len(ann.hashes)))
+			for i, hash := range ann.hashes {
+				if f.announces[ann.origin] >= txAnnounceLimit {
+					log.Debug("Peer exceeded outstanding transaction announces", "peer", ann.origin, "limit", txAnnounceLimit)
+					txAnnounceDOSMeter.Mark(int64(len(ann.hashes) - i))
+					break
+				}
+				if f.hasTx(hash) {
+					txAnnounceKnownMeter.Mark(1)
+					continue
+				}
+				tx := f.announced[hash]
+				if tx == nil {
+					tx = &txAnnounced{time: ann.time}
+					f.announced[hash] = tx
+				} else if tx.fetching == ann.origin || containsOrigin(tx.origins, ann.origin) {
+					continue
+				}
+				tx.origins = append(tx.origins, ann.origin)
+				f.announces[ann.origin]++
+			}
+
+		case delivery := <-f.deliver:
+			if delivery.reply {
+				txReplyInMeter.Mark(int64(len(delivery.hashes)))
+			} else {
+				txBroadcastInMeter.Mark(int64(len(delivery.hashes)))
+			}
+			for _, hash := range delivery.hashes {
+				f.forgetHash(hash)
+			}
+			// The transactions requested but not delivered are tried elsewhere
+			if delivery.reply {
+				for hash := range f.requests[delivery.origin] {
+					txRequestFailMeter.Mark(1)
+					f.refetch(hash)
+				}
+				delete(f.requests, delivery.origin)
+			}
+
+		case peer := <-f.drop:
+			for hash, tx := range f.announced {
+				if i := indexOrigin(tx.origins, peer); i >= 0 {
+					tx.origins = append(tx.origins[:i], tx.origins[i+1:]...)
+					if len(tx.origins) == 0 && tx.fetching == "" {
+						delete(f.announced, hash)
+					}
+				}
+			}
+			for hash := range f.requests[peer] {
+				f.refetch(hash)
+			}
+			delete(f.requests, peer)
+			delete(f.announces, peer)
+
+		case <-fetchTimer.C:
+			now := f.now()
+
+			// Retry the timed out requests from the other announcers
+			for peer, hashes := range f.requests {
+				for hash := range hashes {
+					if now.Sub(f.announced[hash].requested) > txFetchTimeout {
+						log.Trace("Transaction retrieval timed out", "peer", peer, "hash", hash)
+						txRequestTimeoutMeter.Mark(1)
+						f.refetch(hash)
+					}
+				}
+				if len(hashes) == 0 {
+					delete(f.requests, peer)
+				}
+			}
+			// Request the transactions still missing from idle announcers
+			request := make(map[string][]common.Hash)
+
+			for hash, tx := range f.announced {
+				if tx.fetching != "" || now.Sub(tx.time) < txArriveTimeout-gatherSlack {
+					continue
+				}
+				if f.hasTx(hash) {
+					f.forgetHash(hash)
+					continue
+				}
+				for _, i := range rand.Perm(len(tx.origins)) {
+					peer := tx.origins[i]
+					if f.requests[peer] != nil || len(request[peer]) >= txRequestLimit {
+						continue
+					}
+					request[peer] = append(request[peer], hash)
+					tx.origins = append(tx.origins[:i], tx.origins[i+1:]...)
+					tx.fetching, tx.requested = peer, now
+					break
+				}
+			}
+			for peer, hashes := range request {
+				log.Trace("Fetching announced transactions", "peer", peer, "count", len(hashes))
+				txRequestOutMeter.Mark(int64(len(hashes)))
+
+				f.requests[peer] = make(map[common.Hash]struct{}, len(hashes))
+				for _, hash := range hashes {
+					f.requests[peer][hash] = struct{}{}
+				}
+				go f.fetchTxs(peer, hashes)
+			}
+		}
+		f.rescheduleFetch(fetchTimer)
+	}
+}
+
+// rescheduleFetch resets the specified fetch timer to the earliest time an
+// announced transaction is due to be requested, or a request times out.
+func (f *TxFetcher) rescheduleFetch(fetch *time.Timer) {
+	var (
+		now      = f.now()
+		earliest time.Time
+	)
+	for _, tx := range f.announced {
+		deadline := tx.requested.Add(txFetchTimeout)
+		if tx.fetching == "" {
+			// Overdue transactions wait for one of their announcers to be idle
+			if deadline = tx.time.Add(txArriveTimeout); deadline.Before(now) {
+				if !f.hasIdleOrigin(tx) {
+					continue
+				}
+				deadline = now
+			}
+		}
+		if earliest.IsZero() || deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+	if !earliest.IsZero() {
+		fetch.Reset(earliest.Sub(now))
+	}
+}
+
+// refetch schedules a requested transaction to be requested immediately from
+// another of its announcers, or forgets it if none is left.
+func (f *TxFetcher) refetch(hash common.Hash) {
+	tx := f.announced[hash]
+	if tx == nil || tx.fetching == "" {
+		return
+	}
+	f.release(tx.fetching)
+	delete(f.requests[tx.fetching], hash)
+
+	tx.fetching, tx.requested = "", time.Time{}
+	if len(tx.origins) == 0 {
+		f.forgetHash(hash)
+		return
+	}
+	tx.time = f.now().Add(-txArriveTimeout)
+}
+
+// forgetHash removes all traces of an announced transaction.
+func (f *TxFetcher) forgetHash(hash common.Hash) {
+	tx := f.announced[hash]
+	if tx == nil {
+		return
+	}
+	for _, origin := range tx.origins {
+		f.release(origin)
+	}
+	if tx.fetching != "" {
+		f.release(tx.fetching)
+		delete(f.requests[tx.fetching], hash)
+	}
+	delete(f.announced, hash)
+}
+
+// release decrements the announce count of a peer.
+func (f *TxFetcher) release(peer string) {
+	if f.announces[peer]--; f.announces[peer] <= 0 {
+		delete(f.announces, peer)
+	}
+}
+
+// hasIdleOrigin reports whether a peer having announced the transaction has no
+// pending request.
+func (f *TxFetcher) hasIdleOrigin(tx *txAnnounced) bool {
+	for _, origin := range tx.origins {
+		if f.requests[origin] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOrigin(origins []string, peer string) int {
+	for i, origin := range origins {
+		if origin == peer {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsOrigin(origins []string, peer string) bool {
+	return indexOrigin(origins, peer) >= 0
+}
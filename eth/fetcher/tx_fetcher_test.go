@@ -0,0 +1,202 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+// txFetchRequest is a retrieval request sent out by the transaction fetcher.
+type txFetchRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// txFetcherTester is a test simulator for the transaction fetcher, recording
+// its requests and skewing its clock.
+type txFetcherTester struct {
+	fetcher  *TxFetcher
+	requests chan txFetchRequest
+	skew     int64 // Clock skew of the fetcher, accessed atomically
+
+	known map[common.Hash]bool
+	lock  sync.RWMutex
+}
+
+func newTxFetcherTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		requests: make(chan txFetchRequest, 16),
+		known:    make(map[common.Hash]bool),
+	}
+	tester.fetcher = NewTxFetcher(tester.hasTx, tester.fetchTxs)
+	tester.fetcher.now = func() time.Time {
+		return time.Now().Add(time.Duration(atomic.LoadInt64(&tester.skew)))
+	}
+	tester.fetcher.Start()
+	return tester
+}
+
+func (f *txFetcherTester) hasTx(hash common.Hash) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.known[hash]
+}
+
+func (f *txFetcherTester) fetchTxs(peer string, hashes []common.Hash) error {
+	f.requests <- txFetchRequest{peer: peer, hashes: hashes}
+	return nil
+}
+
+// announce notifies the fetcher of transactions announced long enough ago to be
+// requested right away.
+func (f *txFetcherTester) announce(peer string, hashes ...common.Hash) {
+	f.fetcher.Notify(peer, hashes, time.Now().Add(-txArriveTimeout))
+}
+
+// expectRequest waits for a retrieval request of the given hashes, in any order,
+// returning its peer.
+func (f *txFetcherTester) expectRequest(t *testing.T, hashes ...common.Hash) string {
+	t.Helper()
+
+	select {
+	case req := <-f.requests:
+		requested := make(map[common.Hash]bool)
+		for _, hash := range req.hashes {
+			requested[hash] = true
+		}
+		for _, hash := range hashes {
+			if !requested[hash] || len(req.hashes) != len(hashes) {
+				t.Fatalf("requested hashes mismatch: have %v, want %v", req.hashes, hashes)
+			}
+		}
+		return req.peer
+	case <-time.After(time.Second):
+		t.Fatalf("transactions %v not requested", hashes)
+	}
+	return ""
+}
+
+// expectNoRequest checks that no retrieval request is sent out.
+func (f *txFetcherTester) expectNoRequest(t *testing.T, wait time.Duration) {
+	t.Helper()
+
+	select {
+	case req := <-f.requests:
+		t.Fatalf("unexpected request to %s: %v", req.peer, req.hashes)
+	case <-time.After(wait):
+	}
+}
+
+func testTxs(n int) []*types.Transaction {
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	}
+	return txs
+}
+
+// Tests that the announced transactions unknown locally are requested from the
+// announcing peer.
+func TestTxFetcherRequest(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := testTxs(3)
+	tester.known[txs[1].Hash()] = true
+
+	tester.announce("A", txs[0].Hash(), txs[1].Hash(), txs[2].Hash())
+	if peer := tester.expectRequest(t, txs[0].Hash(), txs[2].Hash()); peer != "A" {
+		t.Errorf("requested peer mismatch: have %s, want A", peer)
+	}
+}
+
+// Tests that the transactions broadcast in full before their announcements are
+// due are not requested.
+func TestTxFetcherBroadcast(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := testTxs(1)
+	tester.fetcher.Notify("A", []common.Hash{txs[0].Hash()}, time.Now())
+	tester.fetcher.Enqueue("B", txs, false)
+
+	tester.expectNoRequest(t, 2*txArriveTimeout)
+}
+
+// Tests that the transactions missing from a reply are requested from another
+// announcing peer.
+func TestTxFetcherIncompleteReply(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := testTxs(2)
+	tester.announce("A", txs[0].Hash(), txs[1].Hash())
+	tester.expectRequest(t, txs[0].Hash(), txs[1].Hash())
+	tester.announce("B", txs[1].Hash())
+
+	tester.fetcher.Enqueue("A", txs[:1], true)
+	if peer := tester.expectRequest(t, txs[1].Hash()); peer != "B" {
+		t.Errorf("requested peer mismatch: have %s, want B", peer)
+	}
+	// Nobody else to ask after another incomplete reply
+	tester.fetcher.Enqueue("B", nil, true)
+	tester.expectNoRequest(t, 2*txArriveTimeout)
+}
+
+// Tests that the transactions not delivered in time, or by a dropped peer, are
+// requested from another announcing peer.
+func TestTxFetcherTimeoutAndDrop(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := testTxs(2)
+	tester.announce("A", txs[0].Hash())
+	tester.expectRequest(t, txs[0].Hash())
+	tester.announce("B", txs[0].Hash())
+	tester.announce("C", txs[0].Hash())
+
+	// Skewing the clock past the timeout, the next event fires the retry
+	atomic.StoreInt64(&tester.skew, int64(txFetchTimeout+time.Second))
+	tester.announce("A", txs[1].Hash())
+
+	var first string
+	for i := 0; i < 2; i++ {
+		select {
+		case req := <-tester.requests:
+			if req.hashes[0] == txs[0].Hash() {
+				first = req.peer
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("transactions not requested")
+		}
+	}
+	if first == "" || first == "A" {
+		t.Fatalf("timed out transaction requested from %q", first)
+	}
+
+	tester.fetcher.Drop(first)
+	if peer := tester.expectRequest(t, txs[0].Hash()); peer == "A" || peer == first {
+		t.Errorf("requested peer mismatch: have %s", peer)
+	}
+}
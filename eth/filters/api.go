@@ -28,6 +28,7 @@ import (
 	ethereum "github.com/tomochain/tomochain"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/ethdb"
 	"github.com/tomochain/tomochain/event"
@@ -229,6 +230,78 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// ReorgBlock identifies a block of a reorganised chain segment.
+type ReorgBlock struct {
+	Hash   common.Hash    `json:"hash"`
+	Number hexutil.Uint64 `json:"number"`
+}
+
+// ChainReorg is the notification of a canonical chain reorganisation. The chain
+// segments are ordered from their head down to the common ancestor.
+type ChainReorg struct {
+	Ancestor    ReorgBlock    `json:"ancestor"`
+	OldChain    []ReorgBlock  `json:"oldChain"`
+	NewChain    []ReorgBlock  `json:"newChain"`
+	DroppedTxs  []common.Hash `json:"droppedTransactions"`
+	IncludedTxs []common.Hash `json:"includedTransactions"`
+}
+
+// newChainReorg converts a chain reorganisation event into its notification.
+func newChainReorg(ev core.ChainReorgEvent) *ChainReorg {
+	blocks := func(chain types.Blocks) []ReorgBlock {
+		result := make([]ReorgBlock, len(chain))
+		for i, block := range chain {
+			result[i] = ReorgBlock{Hash: block.Hash(), Number: hexutil.Uint64(block.NumberU64())}
+		}
+		return result
+	}
+	hashes := func(txs types.Transactions) []common.Hash {
+		result := make([]common.Hash, len(txs))
+		for i, tx := range txs {
+			result[i] = tx.Hash()
+		}
+		return result
+	}
+	return &ChainReorg{
+		Ancestor:    ReorgBlock{Hash: ev.Ancestor.Hash(), Number: hexutil.Uint64(ev.Ancestor.NumberU64())},
+		OldChain:    blocks(ev.OldChain),
+		NewChain:    blocks(ev.NewChain),
+		DroppedTxs:  hashes(ev.Dropped),
+		IncludedTxs: hashes(ev.Included),
+	}
+}
+
+// ChainReorg sends a notification each time the canonical chain is reorganised,
+// listing the dropped and newly included blocks and transactions.
+func (api *PublicFilterAPI) ChainReorg(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan core.ChainReorgEvent)
+		reorgsSub := api.events.SubscribeChainReorgs(reorgs)
+
+		for {
+			select {
+			case ev := <-reorgs:
+				notifier.Notify(rpcSub.ID, newChainReorg(ev))
+			case <-rpcSub.Err():
+				reorgsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
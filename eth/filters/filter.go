@@ -19,6 +19,8 @@ package filters
 import (
 	"context"
 	"math/big"
+	"runtime"
+	"sync"
 
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core"
@@ -29,6 +31,11 @@ import (
 	"github.com/tomochain/tomochain/rpc"
 )
 
+var (
+	logsShardSize uint64 = 4096             // Number of blocks searched by a log filtering worker at a time
+	logsWorkers          = runtime.NumCPU() // Number of concurrent workers a long range log search is split among
+)
+
 type Backend interface {
 	ChainDb() ethdb.Database
 	EventMux() *event.TypeMux
@@ -39,6 +46,7 @@ type Backend interface {
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
+	SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
 	BloomStatus() (uint64, uint64)
@@ -109,6 +117,17 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
+	// Split long ranges among concurrent workers
+	if f.begin >= 0 && end >= uint64(f.begin)+logsShardSize && logsWorkers > 1 {
+		return f.shardedLogs(ctx, uint64(f.begin), end)
+	}
+	return f.rangeLogs(ctx, end)
+}
+
+// rangeLogs returns the logs matching the filter criteria from the start of the
+// filter up to the end block, using the bloom bits index for the sections it
+// covers and raw block iteration for the rest.
+func (f *Filter) rangeLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
@@ -130,6 +149,81 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// shardedLogs returns the logs matching the filter criteria between the begin
+// and end blocks, searching sub-ranges of logsShardSize blocks on concurrent
+// workers and merging their results in block order. The first failure, or the
+// cancellation of the context, aborts the remaining workers.
+func (f *Filter) shardedLogs(ctx context.Context, begin, end uint64) ([]*types.Log, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		shards  = int((end-begin)/logsShardSize) + 1
+		results = make([][]*types.Log, shards)
+		failed  = shards // Index of the first shard that failed
+		failure error
+		lock    sync.Mutex
+		pending sync.WaitGroup
+		tasks   = make(chan int)
+	)
+	workers := logsWorkers
+	if workers > shards {
+		workers = shards
+	}
+	for i := 0; i < workers; i++ {
+		pending.Add(1)
+		go func() {
+			defer pending.Done()
+
+			for shard := range tasks {
+				first := begin + uint64(shard)*logsShardSize
+				last := first + logsShardSize - 1
+				if last > end {
+					last = end
+				}
+				logs, err := New(f.backend, int64(first), int64(last), f.addresses, f.topics).rangeLogs(ctx, last)
+				results[shard] = logs
+				if err != nil {
+					// Keep the failure closest to the start of the range, any
+					// other being possibly caused by the cancellation
+					lock.Lock()
+					if shard < failed {
+						failed, failure = shard, err
+					}
+					lock.Unlock()
+					cancel()
+				}
+			}
+		}()
+	}
+feed:
+	for shard := 0; shard < shards; shard++ {
+		select {
+		case tasks <- shard:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(tasks)
+	pending.Wait()
+
+	// Merge the results up to the first failed shard
+	var logs []*types.Log
+	for shard := 0; shard < failed; shard++ {
+		logs = append(logs, results[shard]...)
+	}
+	if failed < shards {
+		logs = append(logs, results[failed]...)
+		return logs, failure
+	}
+	// A shard may have been left out if the context was cancelled by the caller
+	if err := ctx.Err(); err != nil {
+		return logs, err
+	}
+	f.begin = int64(end) + 1
+	return logs, nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
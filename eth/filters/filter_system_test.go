@@ -28,6 +28,7 @@ import (
 
 	ethereum "github.com/tomochain/tomochain"
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/bitutil"
 	"github.com/tomochain/tomochain/consensus/ethash"
 	"github.com/tomochain/tomochain/core"
 	"github.com/tomochain/tomochain/core/bloombits"
@@ -46,6 +47,7 @@ type testBackend struct {
 	rmLogsFeed *event.Feed
 	logsFeed   *event.Feed
 	chainFeed  *event.Feed
+	reorgFeed  *event.Feed
 }
 
 func (b *testBackend) ChainDb() ethdb.Database {
@@ -71,12 +73,12 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 
 func (b *testBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
 	number := core.GetBlockNumber(b.db, blockHash)
-	return core.GetBlockReceipts(b.db, blockHash, number), nil
+	return core.GetRawBlockReceipts(b.db, blockHash, number), nil
 }
 
 func (b *testBackend) GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error) {
 	number := core.GetBlockNumber(b.db, blockHash)
-	receipts := core.GetBlockReceipts(b.db, blockHash, number)
+	receipts := core.GetRawBlockReceipts(b.db, blockHash, number)
 
 	logs := make([][]*types.Log, len(receipts))
 	for i, receipt := range receipts {
@@ -101,6 +103,10 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return b.reorgFeed.Subscribe(ch)
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
@@ -123,7 +129,9 @@ func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.Matc
 				for i, section := range task.Sections {
 					if rand.Int()%4 != 0 { // Handle occasional missing deliveries
 						head := core.GetCanonicalHash(b.db, (section+1)*params.BloomBitsBlocks-1)
-						task.Bitsets[i], _ = core.GetBloomBits(b.db, task.Bit, section, head)
+						if compVector, err := core.GetBloomBits(b.db, task.Bit, section, head); err == nil {
+							task.Bitsets[i], _ = bitutil.DecompressBytes(compVector, int(params.BloomBitsBlocks)/8)
+						}
 					}
 				}
 				request <- task
@@ -147,7 +155,7 @@ func TestBlockSubscription(t *testing.T) {
 		rmLogsFeed  = new(event.Feed)
 		logsFeed    = new(event.Feed)
 		chainFeed   = new(event.Feed)
-		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api         = NewPublicFilterAPI(backend, false)
 		genesis     = new(core.Genesis).MustCommit(db)
 		chain, _    = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
@@ -193,6 +201,60 @@ func TestBlockSubscription(t *testing.T) {
 	<-sub1.Err()
 }
 
+// TestChainReorgSubscription tests if a chain reorg subscription receives the
+// reorganisations posted by the chain.
+func TestChainReorgSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mux       = new(event.TypeMux)
+		db        = rawdb.NewMemoryDatabase()
+		reorgFeed = new(event.Feed)
+		backend   = &testBackend{mux, db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), reorgFeed}
+		api       = NewPublicFilterAPI(backend, false)
+		genesis   = new(core.Genesis).MustCommit(db)
+		oldChain  = types.Blocks{types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("old")})}
+		newChain  = types.Blocks{
+			types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), Extra: []byte("new")}),
+			types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: []byte("new")}),
+		}
+		dropped  = types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		included = types.NewTransaction(1, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	)
+	reorgs := make(chan core.ChainReorgEvent)
+	sub := api.events.SubscribeChainReorgs(reorgs)
+	defer sub.Unsubscribe()
+
+	reorgFeed.Send(core.ChainReorgEvent{
+		Ancestor: genesis,
+		OldChain: oldChain,
+		NewChain: newChain,
+		Dropped:  types.Transactions{dropped},
+		Included: types.Transactions{included},
+	})
+	select {
+	case ev := <-reorgs:
+		reorg := newChainReorg(ev)
+		if reorg.Ancestor.Hash != genesis.Hash() || reorg.Ancestor.Number != 0 {
+			t.Errorf("ancestor mismatch: have %x #%d, want %x #0", reorg.Ancestor.Hash, reorg.Ancestor.Number, genesis.Hash())
+		}
+		if len(reorg.OldChain) != 1 || reorg.OldChain[0].Hash != oldChain[0].Hash() {
+			t.Errorf("old chain mismatch: %v", reorg.OldChain)
+		}
+		if len(reorg.NewChain) != 2 || reorg.NewChain[0].Hash != newChain[0].Hash() || reorg.NewChain[0].Number != 2 {
+			t.Errorf("new chain mismatch: %v", reorg.NewChain)
+		}
+		if len(reorg.DroppedTxs) != 1 || reorg.DroppedTxs[0] != dropped.Hash() {
+			t.Errorf("dropped transactions mismatch: %v", reorg.DroppedTxs)
+		}
+		if len(reorg.IncludedTxs) != 1 || reorg.IncludedTxs[0] != included.Hash() {
+			t.Errorf("included transactions mismatch: %v", reorg.IncludedTxs)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("reorg not delivered")
+	}
+}
+
 // TestPendingTxFilter tests whether pending tx filters retrieve all pending transactions that are posted to the event mux.
 func TestPendingTxFilter(t *testing.T) {
 	t.Parallel()
@@ -204,7 +266,7 @@ func TestPendingTxFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		transactions = []*types.Transaction{
@@ -267,7 +329,7 @@ func TestLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		testCases = []struct {
@@ -316,7 +378,7 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 	)
 
@@ -346,7 +408,7 @@ func TestLogFilter(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
@@ -465,7 +527,7 @@ func TestPendingLogsSubscription(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		api        = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
@@ -25,8 +25,10 @@ import (
 	"testing"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/bitutil"
 	"github.com/tomochain/tomochain/consensus/ethash"
 	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/bloombits"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/event"
@@ -56,7 +58,7 @@ func BenchmarkFilters(b *testing.B) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
 		addr2      = common.BytesToAddress([]byte("jeff"))
@@ -121,7 +123,7 @@ func TestFilters(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr       = crypto.PubkeyToAddress(key1.PublicKey)
 
@@ -241,3 +243,141 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// Tests that log searches served from the bloombits index return the same logs
+// as the searches iterating over every header bloom.
+func TestIndexedFilters(t *testing.T) {
+	var (
+		db        = rawdb.NewMemoryDatabase()
+		mux       = new(event.TypeMux)
+		backend   = &testBackend{mux, db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		key1, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr      = crypto.PubkeyToAddress(key1.PublicKey)
+		logBlocks = map[common.Hash]bool{} // topics of the logs, named after their block
+		topics    []common.Hash
+	)
+	for _, number := range []int64{10, 2048, 4095, 4100} {
+		topic := common.BigToHash(big.NewInt(number))
+		logBlocks[topic], topics = true, append(topics, topic)
+	}
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, int(params.BloomBitsBlocks)+10, func(i int, gen *core.BlockGen) {
+		if topic := common.BigToHash(big.NewInt(int64(i + 1))); logBlocks[topic] {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{topic}}}
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	core.WriteCanonicalHash(db, genesis.Hash(), 0)
+	for i, block := range chain {
+		core.WriteBlock(db, block)
+		if err := core.WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteHeadBlockHash(db, block.Hash()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i]); err != nil {
+			t.Fatal("error writing block receipts:", err)
+		}
+	}
+	// Index the first section of the chain the way the bloom indexer does
+	gen, err := bloombits.NewGenerator(uint(params.BloomBitsBlocks))
+	if err != nil {
+		t.Fatalf("failed to create bloom generator: %v", err)
+	}
+	gen.AddBloom(0, genesis.Bloom())
+	for _, block := range chain[:params.BloomBitsBlocks-1] {
+		gen.AddBloom(uint(block.NumberU64()), block.Bloom())
+	}
+	head := chain[params.BloomBitsBlocks-2].Hash()
+	for i := 0; i < types.BloomBitLength; i++ {
+		bits, err := gen.Bitset(uint(i))
+		if err != nil {
+			t.Fatalf("failed to retrieve bitset %d: %v", i, err)
+		}
+		core.WriteBloomBits(db, uint(i), 0, head, bitutil.CompressBytes(bits))
+	}
+	for _, sections := range []uint64{0, 1} {
+		backend.sections = sections
+
+		logs, err := New(backend, 0, -1, []common.Address{addr}, [][]common.Hash{topics}).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("sections %d: log search failed: %v", sections, err)
+		}
+		if len(logs) != len(logBlocks) {
+			t.Fatalf("sections %d: log count mismatch: have %d, want %d", sections, len(logs), len(logBlocks))
+		}
+		for _, log := range logs {
+			if !logBlocks[log.Topics[0]] {
+				t.Errorf("sections %d: unexpected log with topic %x", sections, log.Topics[0])
+			}
+		}
+	}
+}
+
+// Tests that log searches split among concurrent workers return the same logs,
+// in the same order, as sequential searches, and abort once cancelled.
+func TestShardedFilters(t *testing.T) {
+	defer func(size uint64, workers int) { logsShardSize, logsWorkers = size, workers }(logsShardSize, logsWorkers)
+	logsShardSize = 16
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		topic   = common.BytesToHash([]byte("topic"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 200, func(i int, gen *core.BlockGen) {
+		if i%7 == 0 {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{topic}}, {Address: addr, Topics: []common.Hash{topic, common.BigToHash(big.NewInt(int64(i)))}}}
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range chain {
+		core.WriteBlock(db, block)
+		if err := core.WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteHeadBlockHash(db, block.Hash()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i]); err != nil {
+			t.Fatal("error writing block receipts:", err)
+		}
+	}
+	search := func(workers int, begin, end int64) []*types.Log {
+		logsWorkers = workers
+		logs, err := New(backend, begin, end, []common.Address{addr}, [][]common.Hash{{topic}}).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("workers %d: log search failed: %v", workers, err)
+		}
+		return logs
+	}
+	for _, limits := range [][2]int64{{0, -1}, {5, 150}, {16, 47}, {3, 20}} {
+		want := search(1, limits[0], limits[1])
+		if len(want) == 0 {
+			t.Fatalf("range %v: no logs found", limits)
+		}
+		have := search(4, limits[0], limits[1])
+		if len(have) != len(want) {
+			t.Fatalf("range %v: log count mismatch: have %d, want %d", limits, len(have), len(want))
+		}
+		for i := range have {
+			if have[i].BlockNumber != want[i].BlockNumber || len(have[i].Topics) != len(want[i].Topics) {
+				t.Errorf("range %v: log %d mismatch: have block %d, want block %d", limits, i, have[i].BlockNumber, want[i].BlockNumber)
+			}
+		}
+	}
+	// Cancelled searches fail instead of returning partial results
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	logsWorkers = 4
+	if _, err := New(backend, 0, -1, []common.Address{addr}, nil).Logs(ctx); err != context.Canceled {
+		t.Errorf("cancelled search error mismatch: have %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync/atomic"
+
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/log"
+)
+
+// voteLoop votes on the chain heads with the signing key of the local
+// masternode, if any, until the chain head subscription ends.
+func (pm *ProtocolManager) voteLoop() {
+	for {
+		select {
+		case ev := <-pm.voteHeadCh:
+			// Heads imported while syncing are too old to be worth a vote
+			if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+				continue
+			}
+			vote, err := pm.posv.SignVote(ev.Block.Header())
+			if err != nil {
+				continue // not mining
+			}
+			if err := pm.posv.AddVote(pm.blockchain, vote); err != nil {
+				log.Trace("Skipped voting on chain head", "number", vote.Number, "hash", vote.Hash, "err", err)
+				continue
+			}
+			pm.BroadcastVote(vote)
+
+		case <-pm.voteHeadSub.Err():
+			return
+		}
+	}
+}
+
+// handleVote counts the finality vote of a peer and relays it to the peers
+// without it. Invalid votes are dropped without blaming the peer, which may be
+// ahead of or behind the local chain.
+func (pm *ProtocolManager) handleVote(p *peer, vote *posv.Vote) {
+	voteInMeter.Mark(1)
+	if err := pm.posv.AddVote(pm.blockchain, vote); err != nil {
+		voteInvalidMeter.Mark(1)
+		log.Trace("Dropped finality vote", "peer", p.id, "number", vote.Number, "hash", vote.Hash, "err", err)
+		return
+	}
+	pm.BroadcastVote(vote)
+}
+
+// BroadcastVote sends a finality vote to all the peers without it.
+func (pm *ProtocolManager) BroadcastVote(vote *posv.Vote) {
+	peers := pm.peers.PeersWithoutVote(vote.ID())
+	for _, peer := range peers {
+		peer.SendVote(vote)
+	}
+	voteOutMeter.Mark(int64(len(peers)))
+	log.Trace("Broadcast finality vote", "number", vote.Number, "hash", vote.Hash, "recipients", len(peers))
+}
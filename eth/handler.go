@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
 	"sync/atomic"
@@ -30,6 +31,7 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/consensus/misc"
+	"github.com/tomochain/tomochain/consensus/posv"
 	"github.com/tomochain/tomochain/core"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/eth/downloader"
@@ -39,6 +41,7 @@ import (
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/p2p"
 	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/enr"
 	"github.com/tomochain/tomochain/params"
 	"github.com/tomochain/tomochain/rlp"
 )
@@ -46,6 +49,7 @@ import (
 const (
 	softResponseLimit = 2 * 1024 * 1024 // Target maximum size of returned blocks, headers or node data.
 	estHeaderRlpSize  = 500             // Approximate size of an RLP encoded block header
+	maxTxServe        = 256             // Maximum number of pooled transactions looked up per request
 
 	// txChanSize is the size of channel listening to TxPreEvent.
 	// The number is referenced from the size of tx pool.
@@ -79,6 +83,7 @@ type ProtocolManager struct {
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
 
 	SubProtocols []p2p.Protocol
@@ -92,6 +97,16 @@ type ProtocolManager struct {
 	lendingTxSub  event.Subscription
 	minedBlockSub *event.TypeMuxSubscription
 
+	// order book sampling, see booksample.go
+	bookSampling bool
+	chainHeadCh  chan core.ChainHeadEvent
+	chainHeadSub event.Subscription
+
+	// finality votes of the masternodes, see finality.go
+	posv        *posv.Posv
+	voteHeadCh  chan core.ChainHeadEvent
+	voteHeadSub event.Subscription
+
 	// channels for fetcher, syncer, txsyncLoop
 	newPeerCh   chan *peer
 	txsyncCh    chan *txsync
@@ -143,6 +158,9 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		orderTxSub:     nil,
 		lendingTxSub:   nil,
 	}
+	if engine, ok := engine.(*posv.Posv); ok {
+		manager.posv = engine
+	}
 	// Figure out whether to allow fast sync or not
 	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
@@ -184,6 +202,8 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 				}
 				return nil
 			},
+			Attributes: []enr.Entry{ethEntry{NetworkId: networkID}},
+			DialFilter: manager.dialFilter,
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
@@ -217,7 +237,19 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.PrepareBlock(block)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, prepare, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, prepare, manager.dropInvalidPeer)
+
+	hasTx := func(hash common.Hash) bool {
+		return txpool.Get(hash) != nil || manager.knownTxs.Contains(hash)
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := manager.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestTxs(hashes)
+	}
+	manager.txFetcher = fetcher.NewTxFetcher(hasTx, fetchTxs)
 
 	return manager, nil
 }
@@ -228,6 +260,16 @@ func (pm *ProtocolManager) addOrderPoolProtocol(orderpool orderPool) {
 func (pm *ProtocolManager) addLendingPoolProtocol(lendingpool lendingPool) {
 	pm.lendingpool = lendingpool
 }
+
+// dialFilter accepts the discovered nodes advertising the network of the node.
+func (pm *ProtocolManager) dialFilter(r *enr.Record) bool {
+	var entry ethEntry
+	if err := r.Load(&entry); err != nil {
+		return false
+	}
+	return entry.NetworkId == pm.networkId
+}
+
 func (pm *ProtocolManager) removePeer(id string) {
 	// Short circuit if the peer was already removed
 	peer := pm.peers.Peer(id)
@@ -238,6 +280,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 	// Unregister the peer from the downloader and Ethereum peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Warn("Peer removal failed", "peer", id, "err", err)
 	}
@@ -247,6 +290,15 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// dropInvalidPeer penalises and drops a peer which propagated an invalid block,
+// the score it keeps delaying its reconnection.
+func (pm *ProtocolManager) dropInvalidPeer(id string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.MarkInvalidBlock()
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -269,6 +321,20 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.minedBlockSub = pm.eventMux.Subscribe(core.NewMinedBlockEvent{})
 	go pm.minedBroadcastLoop()
 
+	// exchange order book samples
+	if pm.bookSampling {
+		pm.chainHeadCh = make(chan core.ChainHeadEvent, chainHeadChanSize)
+		pm.chainHeadSub = pm.blockchain.SubscribeChainHeadEvent(pm.chainHeadCh)
+		go pm.bookSampleLoop()
+	}
+
+	// vote on the chain heads as a masternode
+	if pm.posv != nil {
+		pm.voteHeadCh = make(chan core.ChainHeadEvent, chainHeadChanSize)
+		pm.voteHeadSub = pm.blockchain.SubscribeChainHeadEvent(pm.voteHeadCh)
+		go pm.voteLoop()
+	}
+
 	// start sync handlers
 	go pm.syncer()
 	go pm.txsyncLoop()
@@ -285,6 +351,12 @@ func (pm *ProtocolManager) Stop() {
 		pm.lendingTxSub.Unsubscribe()
 	}
 	pm.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
+	if pm.chainHeadSub != nil {
+		pm.chainHeadSub.Unsubscribe() // quits bookSampleLoop
+	}
+	if pm.voteHeadSub != nil {
+		pm.voteHeadSub.Unsubscribe() // quits voteLoop
+	}
 
 	// Quit the sync loop.
 	// After this send has completed, no new peers will be accepted.
@@ -306,7 +378,10 @@ func (pm *ProtocolManager) Stop() {
 }
 
 func (pm *ProtocolManager) newPeer(pv int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
-	return newPeer(pv, p, newMeteredMsgWriter(rw))
+	peer := newPeer(pv, p, newMeteredMsgWriter(rw))
+	peer.score = pm.peers.Score(peer.id)
+	peer.drop = func() { go pm.removePeer(peer.id) }
+	return peer
 }
 
 // handle is the callback invoked to manage the life cycle of an eth peer. When
@@ -316,6 +391,12 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	if pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
 		return p2p.DiscTooManyPeers
 	}
+	// Refuse the peers dropped for their score until it has recovered
+	if score := p.score.Value(); score < minPeerScore {
+		p.Log().Debug("Ethereum peer refused for its score", "score", score)
+		peerScoreRejectMeter.Mark(1)
+		return p2p.DiscUselessPeer
+	}
 	p.Log().Debug("Ethereum peer connected", "name", p.Name())
 
 	// Execute the Ethereum handshake
@@ -352,7 +433,8 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		// If we're DAO hard-fork aware, validate any remote peer with regard to the hard-fork
 		if daoBlock := pm.chainconfig.DAOForkBlock; daoBlock != nil {
 			// Request the peer's DAO fork header for extra-data validation
-			if err := p.RequestHeadersByNumber(daoBlock.Uint64(), 1, 0, false); err != nil {
+			query := &getBlockHeadersData{Origin: hashOrNumber{Number: daoBlock.Uint64()}, Amount: 1}
+			if err := p.sendRequest(GetBlockHeadersMsg, query, BlockHeadersMsg, originUnknown); err != nil {
 				return err
 			}
 			// Start a timer to disconnect if the peer doesn't reply in time
@@ -391,6 +473,18 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	}
 	defer msg.Discard()
 
+	// Ignore the data retrieval requests over the rate limit of the peer
+	allowed, err := p.limiter.Allow(msg.Code, 1, time.Now())
+	if err != nil {
+		reqAbuseMeter.Mark(1)
+		return errResp(ErrRequestRateExceeded, "message code %d: %v", msg.Code, err)
+	}
+	if allowed == 0 {
+		p.Log().Trace("Ignoring request over the rate limit", "code", msg.Code)
+		reqThrottledMeter.Mark(1)
+		return nil
+	}
+
 	// Handle the message depending on its contents
 	switch {
 	case msg.Code == StatusMsg:
@@ -401,7 +495,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	case msg.Code == GetBlockHeadersMsg:
 		// Decode the complex header query
 		var query getBlockHeadersData
-		if err := msg.Decode(&query); err != nil {
+		id, err := p.decodeMsg(msg, &query)
+		if err != nil {
 			return errResp(ErrDecode, "%v: %v", msg, err)
 		}
 		hashMode := query.Origin.Hash != (common.Hash{})
@@ -474,14 +569,21 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				query.Origin.Number += query.Skip + 1
 			}
 		}
-		return p.SendBlockHeaders(headers)
+		return p.SendBlockHeaders(id, headers)
 
 	case msg.Code == BlockHeadersMsg:
 		// A batch of headers arrived to one of our previous requests
 		var headers []*types.Header
-		if err := msg.Decode(&headers); err != nil {
+		id, err := p.decodeMsg(msg, &headers)
+		if err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
+		origin, ok := p.fulfil(id, msg.Code)
+		if !ok {
+			p.Log().Debug("Dropping headers to an unknown or abandoned request", "id", id)
+			reqStaleMeter.Mark(1)
+			return nil
+		}
 		// If no headers were received, but we're expending a DAO fork check, maybe it's that
 		if len(headers) == 0 && p.forkDrop != nil {
 			// Possibly an empty reply to the fork header checks, sanity check TDs
@@ -502,7 +604,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return nil
 			}
 		}
-		// Filter out any explicitly requested headers, deliver the rest to the downloader
+		// Filter out any explicitly requested headers, deliver the rest to the downloader.
+		// The responses to the eth/66 requests only go to the retriever of the request.
 		filter := len(headers) == 1
 		if filter {
 			// If it's a potential DAO fork check, validate against the rules
@@ -520,19 +623,24 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return nil
 			}
 			// Irrelevant of the fork checks, send the header to the fetcher just in case
-			headers = pm.fetcher.FilterHeaders(p.id, headers, time.Now())
+			if origin != originDownloader {
+				headers = pm.fetcher.FilterHeaders(p.id, headers, time.Now())
+			}
 		}
-		if len(headers) > 0 || !filter {
+		if (len(headers) > 0 || !filter) && origin != originFetcher {
 			err := pm.downloader.DeliverHeaders(p.id, headers)
 			if err != nil {
 				log.Debug("Failed to deliver headers", "err", err)
+				p.MarkUseless()
+			} else if len(headers) > 0 {
+				p.MarkUseful()
 			}
 		}
 
 	case msg.Code == GetBlockBodiesMsg:
 		// Decode the retrieval message
-		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err := msgStream.List(); err != nil {
+		msgStream, id, err := p.requestStream(msg)
+		if err != nil {
 			return err
 		}
 		// Gather blocks until the fetch or network limits is reached
@@ -554,14 +662,21 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(data)
 			}
 		}
-		return p.SendBlockBodiesRLP(bodies)
+		return p.SendBlockBodiesRLP(id, bodies)
 
 	case msg.Code == BlockBodiesMsg:
 		// A batch of block bodies arrived to one of our previous requests
 		var request blockBodiesData
-		if err := msg.Decode(&request); err != nil {
+		id, err := p.decodeMsg(msg, &request)
+		if err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
+		origin, ok := p.fulfil(id, msg.Code)
+		if !ok {
+			p.Log().Debug("Dropping bodies to an unknown or abandoned request", "id", id)
+			reqStaleMeter.Mark(1)
+			return nil
+		}
 		// Deliver them all to the downloader for queuing
 		trasactions := make([][]*types.Transaction, len(request))
 		uncles := make([][]*types.Header, len(request))
@@ -570,24 +685,151 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			trasactions[i] = body.Transactions
 			uncles[i] = body.Uncles
 		}
-		// Filter out any explicitly requested bodies, deliver the rest to the downloader
-		filter := len(trasactions) > 0 || len(uncles) > 0
+		// Filter out any explicitly requested bodies, deliver the rest to the downloader.
+		// The responses to the eth/66 requests only go to the retriever of the request.
+		filter := (len(trasactions) > 0 || len(uncles) > 0) && origin != originDownloader
 		if filter {
 			trasactions, uncles = pm.fetcher.FilterBodies(p.id, trasactions, uncles, time.Now())
 		}
-		if len(trasactions) > 0 || len(uncles) > 0 || !filter {
+		if (len(trasactions) > 0 || len(uncles) > 0 || !filter) && origin != originFetcher {
 			err := pm.downloader.DeliverBodies(p.id, trasactions, uncles)
 			if err != nil {
 				log.Debug("Failed to deliver bodies", "err", err)
+				p.MarkUseless()
+			} else if len(trasactions) > 0 {
+				p.MarkUseful()
 			}
 		}
 
-	case p.version >= eth63 && msg.Code == GetNodeDataMsg:
+	case p.version >= eth100 && msg.Code == GetBlockTxHashesMsg:
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 		if _, err := msgStream.List(); err != nil {
 			return err
 		}
+		// Gather compact bodies until the fetch or network limits is reached
+		var (
+			hash   common.Hash
+			bytes  int
+			bodies []*blockTxHashes
+		)
+		for bytes < softResponseLimit && len(bodies) < downloader.MaxBlockFetch {
+			// Retrieve the hash of the next block
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested block, stopping if enough was found
+			if block := pm.blockchain.GetBlockByHash(hash); block != nil {
+				body := &blockTxHashes{
+					Hash:         hash,
+					Transactions: make([]common.Hash, len(block.Transactions())),
+					Uncles:       block.Uncles(),
+				}
+				for i, tx := range block.Transactions() {
+					body.Transactions[i] = tx.Hash()
+				}
+				bodies = append(bodies, body)
+				bytes += len(body.Transactions)*common.HashLength + len(body.Uncles)*estHeaderRlpSize
+			}
+		}
+		return p.SendBlockTxHashes(bodies)
+
+	case p.version >= eth100 && msg.Code == BlockTxHashesMsg:
+		// A batch of compact block bodies arrived to one of the fetcher's requests
+		var request blockTxHashesData
+		if err := msg.Decode(&request); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		transactions, uncles, err := pm.reconstructBodies(p, request)
+		if err != nil {
+			return err
+		}
+		if len(transactions) > 0 {
+			pm.fetcher.FilterBodies(p.id, transactions, uncles, time.Now())
+		}
+
+	case p.version >= eth100 && msg.Code == GetBlockTxsMsg:
+		// Decode the retrieval message
+		var query getBlockTxsData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		var txs []*types.Transaction
+		if block := pm.blockchain.GetBlockByHash(query.Hash); block != nil {
+			all := block.Transactions()
+			if len(query.Indexes) > len(all) {
+				return errResp(ErrDecode, "msg %v: requested %d transactions from block with %d", msg, len(query.Indexes), len(all))
+			}
+			for _, index := range query.Indexes {
+				if index >= uint64(len(all)) {
+					return errResp(ErrDecode, "msg %v: transaction index %d out of range", msg, index)
+				}
+				txs = append(txs, all[index])
+			}
+		}
+		return p.SendBlockTxs(query.Hash, txs)
+
+	case p.version >= eth100 && msg.Code == BlockTxsMsg:
+		// The transactions missing from a compact body arrived
+		var response blockTxsData
+		if err := msg.Decode(&response); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		partial := p.takePartialBody(response.Hash)
+		if partial == nil {
+			// Not requested, or already timed out
+			p.MarkUseless()
+			break
+		}
+		complete := len(response.Transactions) == len(partial.missing)
+		for i := 0; complete && i < len(partial.missing); i++ {
+			index := partial.missing[i]
+			if response.Transactions[i].Hash() != partial.hashes[index] {
+				complete = false
+				break
+			}
+			partial.txs[index] = response.Transactions[i]
+		}
+		if !complete {
+			// The peer couldn't fill in the gaps, fall back to retrieving the full body
+			log.Debug("Compact body completion failed, fetching full body", "peer", p.id, "hash", response.Hash)
+			return p.FetchBodies([]common.Hash{response.Hash})
+		}
+		compactTxFetchMeter.Mark(int64(len(partial.missing)))
+		pm.fetcher.FilterBodies(p.id, [][]*types.Transaction{partial.txs}, [][]*types.Header{partial.uncles}, time.Now())
+
+	case p.version >= eth100 && msg.Code == OrderBookSampleMsg:
+		// Order book samples arrived, check them against the local state if enabled
+		var samples []*orderBookSample
+		if err := msg.Decode(&samples); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if len(samples) > maxBookSamples {
+			return errResp(ErrDecode, "msg %v: %d order book samples, limit %d", msg, len(samples), maxBookSamples)
+		}
+		if pm.bookSampling {
+			pm.checkBookSamples(p, samples)
+		}
+
+	case p.version >= eth100 && msg.Code == VoteMsg:
+		// A finality vote arrived, count it and relay it if valid
+		vote := new(posv.Vote)
+		if err := msg.Decode(vote); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.MarkVote(vote.ID())
+		if pm.posv != nil {
+			pm.handleVote(p, vote)
+		}
+
+	case p.version >= eth63 && msg.Code == GetNodeDataMsg:
+		// Decode the retrieval message
+		msgStream, id, err := p.requestStream(msg)
+		if err != nil {
+			return err
+		}
 		// Gather state data until the fetch or network limits is reached
 		var (
 			hash  common.Hash
@@ -607,23 +849,33 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(entry)
 			}
 		}
-		return p.SendNodeData(data)
+		return p.SendNodeData(id, data)
 
 	case p.version >= eth63 && msg.Code == NodeDataMsg:
 		// A batch of node state data arrived to one of our previous requests
 		var data [][]byte
-		if err := msg.Decode(&data); err != nil {
+		id, err := p.decodeMsg(msg, &data)
+		if err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
+		_, ok := p.fulfil(id, msg.Code)
+		if !ok {
+			p.Log().Debug("Dropping node data to an unknown or abandoned request", "id", id)
+			reqStaleMeter.Mark(1)
+			return nil
+		}
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverNodeData(p.id, data); err != nil {
 			log.Debug("Failed to deliver node state data", "err", err)
+			p.MarkUseless()
+		} else if len(data) > 0 {
+			p.MarkUseful()
 		}
 
 	case p.version >= eth63 && msg.Code == GetReceiptsMsg:
 		// Decode the retrieval message
-		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
-		if _, err := msgStream.List(); err != nil {
+		msgStream, id, err := p.requestStream(msg)
+		if err != nil {
 			return err
 		}
 		// Gather state data until the fetch or network limits is reached
@@ -654,17 +906,27 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				bytes += len(encoded)
 			}
 		}
-		return p.SendReceiptsRLP(receipts)
+		return p.SendReceiptsRLP(id, receipts)
 
 	case p.version >= eth63 && msg.Code == ReceiptsMsg:
 		// A batch of receipts arrived to one of our previous requests
 		var receipts [][]*types.Receipt
-		if err := msg.Decode(&receipts); err != nil {
+		id, err := p.decodeMsg(msg, &receipts)
+		if err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
+		_, ok := p.fulfil(id, msg.Code)
+		if !ok {
+			p.Log().Debug("Dropping receipts to an unknown or abandoned request", "id", id)
+			reqStaleMeter.Mark(1)
+			return nil
+		}
 		// Deliver all to the downloader
 		if err := pm.downloader.DeliverReceipts(p.id, receipts); err != nil {
 			log.Debug("Failed to deliver receipts", "err", err)
+			p.MarkUseless()
+		} else if len(receipts) > 0 {
+			p.MarkUseful()
 		}
 
 	case msg.Code == NewBlockHashesMsg:
@@ -683,8 +945,13 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				unknown = append(unknown, block)
 			}
 		}
+		// Peers supporting compact bodies let us reuse transactions from the local pool
+		bodyFetcher := p.FetchBodies
+		if p.version >= eth100 {
+			bodyFetcher = p.RequestBlockTxHashes
+		}
 		for _, block := range unknown {
-			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, p.RequestBodies)
+			pm.fetcher.Notify(p.id, block.Hash, block.Number, time.Now(), p.RequestOneHeader, bodyFetcher)
 		}
 
 	case msg.Code == NewBlockMsg:
@@ -696,6 +963,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		request.Block.ReceivedAt = msg.ReceivedAt
 		request.Block.ReceivedFrom = p
 
+		// Rate the peer on how fast it propagates the blocks above our head
+		if request.Block.NumberU64() > pm.blockchain.CurrentBlock().NumberU64() {
+			p.MarkPropagation(msg.ReceivedAt.Sub(time.Unix(request.Block.Time().Int64(), 0)))
+		}
 		// Mark the peer as owning the block and schedule it for import
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
@@ -746,6 +1017,77 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		}
 		pm.txpool.AddRemotes(txs)
+		pm.txFetcher.Enqueue(p.id, txs, false)
+
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		// Transactions were announced, make sure we have a valid and fresh chain to handle them
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Mark the hashes as present at the remote node and schedule the unknown ones
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+		}
+		pm.txFetcher.Notify(p.id, hashes, time.Now())
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash   common.Hash
+			bytes  int
+			hashes []common.Hash
+			txs    []rlp.RawValue
+		)
+		for i := 0; bytes < softResponseLimit && i < maxTxServe; i++ {
+			// Retrieve the hash of the next transaction
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested transaction, skipping if unknown to us
+			tx := pm.txpool.Get(hash)
+			if tx == nil {
+				continue
+			}
+			encoded, err := rlp.EncodeToBytes(tx)
+			if err != nil {
+				log.Error("Failed to encode transaction", "err", err)
+				continue
+			}
+			hashes = append(hashes, hash)
+			txs = append(txs, encoded)
+			bytes += len(encoded)
+		}
+		return p.SendPooledTransactionsRLP(hashes, txs)
+
+	case p.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		// Transactions arrived to one of our previous requests
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+			p.MarkTransaction(tx.Hash())
+			pm.knownTxs.Add(tx.Hash(), true)
+		}
+		pm.txpool.AddRemotes(txs)
+		pm.txFetcher.Enqueue(p.id, txs, true)
 
 	case msg.Code == OrderTxMsg:
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
@@ -815,6 +1157,56 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// reconstructBodies assembles the full block bodies out of a batch of compact
+// ones, using the transactions already present in the local pool. Bodies with
+// transactions missing from the pool are tracked and their gaps requested from
+// the peer; only the fully reconstructed bodies are returned.
+func (pm *ProtocolManager) reconstructBodies(p *peer, request blockTxHashesData) ([][]*types.Transaction, [][]*types.Header, error) {
+	var (
+		transactions = make([][]*types.Transaction, 0, len(request))
+		uncles       = make([][]*types.Header, 0, len(request))
+	)
+	for _, body := range request {
+		var (
+			txs     = make([]*types.Transaction, len(body.Transactions))
+			missing []uint64
+		)
+		for i, hash := range body.Transactions {
+			if txs[i] = pm.txpool.Get(hash); txs[i] == nil {
+				missing = append(missing, uint64(i))
+			}
+		}
+		compactTxPoolMeter.Mark(int64(len(txs) - len(missing)))
+
+		if len(missing) == 0 {
+			compactBodyCompleteMeter.Mark(1)
+			transactions = append(transactions, txs)
+			uncles = append(uncles, body.Uncles)
+			continue
+		}
+		compactBodyPartialMeter.Mark(1)
+
+		partial := &partialBody{
+			hashes:  body.Transactions,
+			txs:     txs,
+			uncles:  body.Uncles,
+			missing: missing,
+			time:    time.Now(),
+		}
+		if !p.trackPartialBody(body.Hash, partial) {
+			// Too many bodies in flight, fall back to retrieving the full body
+			if err := p.FetchBodies([]common.Hash{body.Hash}); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if err := p.RequestBlockTxs(body.Hash, missing); err != nil {
+			return nil, nil, err
+		}
+	}
+	return transactions, uncles, nil
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -848,15 +1240,23 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 }
 
 // BroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
+// already have the given transaction: in full to the square root of them and to
+// the peers not supporting announcements, by hash to the others.
 func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction) {
-	// Broadcast transaction to a batch of peers not knowing about it
-	peers := pm.peers.PeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for _, peer := range peers {
-		peer.SendTransactions(types.Transactions{tx})
+	var (
+		peers     = pm.peers.PeersWithoutTx(hash)
+		transfer  = int(math.Sqrt(float64(len(peers))))
+		announced int
+	)
+	for i, peer := range peers {
+		if i < transfer || peer.version < eth65 {
+			peer.SendTransactions(types.Transactions{tx})
+		} else {
+			peer.SendPooledTransactionHashes([]common.Hash{hash})
+			announced++
+		}
 	}
-	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
+	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers)-announced, "announced", announced)
 }
 
 // OrderBroadcastTx will propagate a transaction to all peers which are not known to
@@ -21,6 +21,7 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -450,6 +451,158 @@ func testGetReceipt(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that compact block bodies can be retrieved from a remote chain based on
+// their hashes.
+func TestGetBlockTxHashes100(t *testing.T) { testGetBlockTxHashes(t, 100) }
+
+func testGetBlockTxHashes(t *testing.T, protocol int) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, compactBodyGenerator(), nil)
+	peer, _ := newTestPeer("peer", protocol, pm, true)
+	defer peer.close()
+
+	// Collect the hashes to request, and the response to expect
+	hashes, bodies := []common.Hash{}, []*blockTxHashes{}
+	for i := uint64(0); i <= pm.blockchain.CurrentBlock().NumberU64(); i++ {
+		block := pm.blockchain.GetBlockByNumber(i)
+
+		body := &blockTxHashes{Hash: block.Hash(), Transactions: []common.Hash{}, Uncles: block.Uncles()}
+		for _, tx := range block.Transactions() {
+			body.Transactions = append(body.Transactions, tx.Hash())
+		}
+		hashes = append(hashes, block.Hash())
+		bodies = append(bodies, body)
+	}
+	// Unknown blocks should be silently skipped
+	hashes = append(hashes, common.Hash{})
+
+	// Send the hash request and verify the response
+	p2p.Send(peer.app, GetBlockTxHashesMsg, hashes)
+	if err := p2p.ExpectMsg(peer.app, BlockTxHashesMsg, bodies); err != nil {
+		t.Errorf("compact bodies mismatch: %v", err)
+	}
+}
+
+// Tests that a subset of a block's transactions can be retrieved from a remote
+// chain based on their positions.
+func TestGetBlockTxs100(t *testing.T) { testGetBlockTxs(t, 100) }
+
+func testGetBlockTxs(t *testing.T, protocol int) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, compactBodyGenerator(), nil)
+	peer, _ := newTestPeer("peer", protocol, pm, true)
+	defer peer.close()
+
+	block := pm.blockchain.GetBlockByNumber(2)
+	txs := block.Transactions()
+
+	// Request the transactions out of order and verify the response
+	p2p.Send(peer.app, GetBlockTxsMsg, getBlockTxsData{Hash: block.Hash(), Indexes: []uint64{1, 0}})
+	if err := p2p.ExpectMsg(peer.app, BlockTxsMsg, blockTxsData{Hash: block.Hash(), Transactions: []*types.Transaction{txs[1], txs[0]}}); err != nil {
+		t.Errorf("transactions mismatch: %v", err)
+	}
+}
+
+// Tests that the requests over the rate limit of a peer are ignored, and that a
+// peer flooding them is disconnected.
+func TestRequestRateLimit(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, nil, nil)
+	peer, errc := newTestPeer("peer", eth63, pm, true)
+	defer peer.close()
+
+	replies := make(chan struct{}, 1024)
+	go func() {
+		for {
+			msg, err := peer.app.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+			replies <- struct{}{}
+		}
+	}()
+	query := &getBlockHeadersData{Origin: hashOrNumber{Number: 1}, Amount: 1}
+	for i := 0; i < 2*int(requestRates[GetBlockHeadersMsg]); i++ {
+		if err := p2p.Send(peer.app, GetBlockHeadersMsg, query); err != nil {
+			t.Fatalf("failed to send query %d: %v", i, err)
+		}
+	}
+	if served := len(replies); served > int(requestRates[GetBlockHeadersMsg])+10 {
+		t.Errorf("served requests mismatch: have %d, want at most %d", served, int(requestRates[GetBlockHeadersMsg])+10)
+	}
+	go func() {
+		for p2p.Send(peer.app, GetBlockHeadersMsg, query) == nil {
+		}
+	}()
+	select {
+	case err := <-errc:
+		if err == nil || !strings.HasPrefix(err.Error(), errCode(ErrRequestRateExceeded).String()) {
+			t.Errorf("disconnect error mismatch: have %v, want %v", err, errCode(ErrRequestRateExceeded))
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("flooding peer not disconnected")
+	}
+}
+
+// Tests that compact block bodies are reconstructed from the local transaction
+// pool, and that only the transactions missing from it are requested.
+func TestCompactBodyReconstruction100(t *testing.T) { testCompactBodyReconstruction(t, 100) }
+
+func testCompactBodyReconstruction(t *testing.T, protocol int) {
+	// Generate a chain to lift the transactions from, and an empty node to import into
+	source, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, compactBodyGenerator(), nil)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	peer, _ := newTestPeer("peer", protocol, pm, true)
+	defer peer.close()
+
+	block := source.blockchain.GetBlockByNumber(2)
+	txs := block.Transactions()
+
+	// Make only the first transaction known to the local pool
+	pm.txpool.AddRemotes(types.Transactions{txs[0]})
+
+	body := &blockTxHashes{Hash: block.Hash(), Transactions: []common.Hash{txs[0].Hash(), txs[1].Hash()}, Uncles: []*types.Header{}}
+	p2p.Send(peer.app, BlockTxHashesMsg, blockTxHashesData{body})
+	if err := p2p.ExpectMsg(peer.app, GetBlockTxsMsg, getBlockTxsData{Hash: block.Hash(), Indexes: []uint64{1}}); err != nil {
+		t.Fatalf("missing transaction request mismatch: %v", err)
+	}
+	// Answer with a bogus transaction and ensure the full body is requested instead
+	p2p.Send(peer.app, BlockTxsMsg, blockTxsData{Hash: block.Hash(), Transactions: []*types.Transaction{txs[0]}})
+	msg, err := peer.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read full body request: %v", err)
+	}
+	if msg.Code != GetBlockBodiesMsg {
+		t.Fatalf("full body fallback code mismatch: have %x, want %x", msg.Code, GetBlockBodiesMsg)
+	}
+	var hashes []common.Hash
+	if _, err := peer.decodeMsg(msg, &hashes); err != nil {
+		t.Fatalf("failed to decode full body request: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != block.Hash() {
+		t.Fatalf("full body fallback mismatch: have %x, want %x", hashes, block.Hash())
+	}
+}
+
+// compactBodyGenerator creates a chain generator with a few blocks containing
+// transactions, to test compact body transfers with.
+func compactBodyGenerator() func(int, *core.BlockGen) {
+	acc1Key, _ := crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	acc1Addr := crypto.PubkeyToAddress(acc1Key.PublicKey)
+
+	signer := types.HomesteadSigner{}
+	return func(i int, block *core.BlockGen) {
+		switch i {
+		case 0:
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), acc1Addr, big.NewInt(10000), params.TxGas, nil, nil), signer, testBankKey)
+			block.AddTx(tx)
+		case 1:
+			tx1, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), acc1Addr, big.NewInt(1000), params.TxGas, nil, nil), signer, testBankKey)
+			tx2, _ := types.SignTx(types.NewTransaction(block.TxNonce(acc1Addr), testBank, big.NewInt(1000), params.TxGas, nil, nil), signer, acc1Key)
+			block.AddTx(tx1)
+			block.AddTx(tx2)
+		}
+	}
+}
+
 // Tests that post eth protocol handshake, DAO fork-enabled clients also execute
 // a DAO "challenge" verifying each others' DAO fork headers to ensure they're on
 // compatible chains.
@@ -0,0 +1,169 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/accounts"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// healthSigHash is the hash signed to check that the signing key is available.
+var healthSigHash = crypto.Keccak256([]byte("tomochain-health-check"))
+
+// HealthConfig are the thresholds of the health checks of a masternode, which
+// warn about a node at risk of proposing late or invalid blocks.
+type HealthConfig struct {
+	Interval      time.Duration `toml:",omitempty"` // Interval between health checks (0 = disabled)
+	MinPeers      int           `toml:",omitempty"` // Fewest connected peers of a healthy node
+	MaxClockDrift time.Duration `toml:",omitempty"` // Largest drift of the system clock against NTP (0 = unchecked)
+	MaxDBLatency  time.Duration `toml:",omitempty"` // Slowest database read of a healthy node (0 = unchecked)
+	StopSealing   bool          `toml:",omitempty"` // Whether to hold sealing while unhealthy, instead of only warning
+}
+
+// DefaultHealthConfig contains the default health check thresholds.
+var DefaultHealthConfig = HealthConfig{
+	MinPeers:      3,
+	MaxClockDrift: time.Second,
+	MaxDBLatency:  500 * time.Millisecond,
+}
+
+// HealthReport is the outcome of a health check. Durations are in nanoseconds.
+type HealthReport struct {
+	Time        time.Time      `json:"time"`
+	Peers       int            `json:"peers"`
+	ClockDrift  time.Duration  `json:"clockDrift"` // zero if NTP is unreachable
+	DBLatency   time.Duration  `json:"dbLatency"`
+	Sealing     bool           `json:"sealing"` // whether the node seals blocks, held or not
+	Signer      common.Address `json:"signer"`
+	SignerReady bool           `json:"signerReady"` // whether the key of the signer is unlocked
+	Problems    []string       `json:"problems"`
+	Held        bool           `json:"held"` // whether sealing is held by the health checks
+}
+
+// problems returns the thresholds the report fails, the signing key being only
+// required while sealing.
+func (c *HealthConfig) problems(report *HealthReport) []string {
+	problems := []string{}
+	if report.Peers < c.MinPeers {
+		problems = append(problems, fmt.Sprintf("%d peers, less than %d", report.Peers, c.MinPeers))
+	}
+	if drift := report.ClockDrift; c.MaxClockDrift > 0 && (drift > c.MaxClockDrift || drift < -c.MaxClockDrift) {
+		problems = append(problems, fmt.Sprintf("clock drift of %v, more than %v", drift, c.MaxClockDrift))
+	}
+	if c.MaxDBLatency > 0 && report.DBLatency > c.MaxDBLatency {
+		problems = append(problems, fmt.Sprintf("database latency of %v, more than %v", report.DBLatency, c.MaxDBLatency))
+	}
+	if report.Sealing && !report.SignerReady {
+		problems = append(problems, fmt.Sprintf("signing key of %x unavailable", report.Signer))
+	}
+	return problems
+}
+
+// healthMonitor checks the health of the node periodically, warning about the
+// failed checks and holding the sealing of blocks until they pass if enabled.
+type healthMonitor struct {
+	config     HealthConfig
+	eth        *Ethereum
+	clockDrift func() (time.Duration, error)
+
+	last *HealthReport
+	lock sync.RWMutex
+}
+
+func newHealthMonitor(eth *Ethereum, config HealthConfig) *healthMonitor {
+	return &healthMonitor{config: config, eth: eth, clockDrift: discover.ClockDrift}
+}
+
+// loop checks the health of the node until the service is stopped.
+func (h *healthMonitor) loop() {
+	ticker := time.NewTicker(h.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.check()
+		case <-h.eth.shutdownChan:
+			return
+		}
+	}
+}
+
+// check runs the health checks and acts on their outcome.
+func (h *healthMonitor) check() *HealthReport {
+	miner := h.eth.Miner()
+	report := &HealthReport{
+		Time:    time.Now(),
+		Peers:   h.eth.protocolManager.peers.Len(),
+		Sealing: miner.Mining(),
+		Held:    !miner.Healthy(),
+	}
+	if h.config.MaxClockDrift > 0 {
+		drift, err := h.clockDrift()
+		if err != nil {
+			log.Debug("Failed to measure clock drift", "err", err)
+		}
+		report.ClockDrift = drift
+	}
+	start := time.Now()
+	core.GetHeadHeaderHash(h.eth.chainDb)
+	report.DBLatency = time.Since(start)
+
+	if signer, err := h.eth.Etherbase(); err == nil {
+		report.Signer = signer
+		account := accounts.Account{Address: signer}
+		if wallet, err := h.eth.accountManager.Find(account); err == nil {
+			_, err = wallet.SignHash(account, healthSigHash)
+			report.SignerReady = err == nil
+		}
+	}
+	report.Problems = h.config.problems(report)
+
+	switch {
+	case len(report.Problems) > 0:
+		log.Warn("Masternode health check failed", "problems", strings.Join(report.Problems, ", "))
+		if h.config.StopSealing && report.Sealing && !report.Held {
+			miner.SetHealthy(false)
+			report.Held = true
+			log.Error("Held block sealing until the health checks pass")
+		}
+	case report.Held:
+		miner.SetHealthy(true)
+		report.Held = false
+		log.Info("Released block sealing held by the health checks")
+	}
+	h.lock.Lock()
+	h.last = report
+	h.lock.Unlock()
+	return report
+}
+
+// report returns the outcome of the last health check, nil if none ran yet.
+func (h *healthMonitor) report() *HealthReport {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.last
+}
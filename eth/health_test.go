@@ -0,0 +1,48 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthProblems(t *testing.T) {
+	config := DefaultHealthConfig
+	tests := []struct {
+		report   HealthReport
+		problems int
+	}{
+		// Healthy nodes, sealing or not
+		{HealthReport{Peers: 3, ClockDrift: -time.Second, DBLatency: time.Millisecond}, 0},
+		{HealthReport{Peers: 5, Sealing: true, SignerReady: true}, 0},
+		// The signing key is only required while sealing
+		{HealthReport{Peers: 3, Sealing: true}, 1},
+		{HealthReport{Peers: 2, ClockDrift: -2 * time.Second}, 2},
+		{HealthReport{Peers: 3, ClockDrift: 2 * time.Second, DBLatency: time.Second, Sealing: true}, 3},
+	}
+	for i, tt := range tests {
+		if problems := config.problems(&tt.report); len(problems) != tt.problems {
+			t.Errorf("test %d: problems mismatch: have %v, want %d", i, problems, tt.problems)
+		}
+	}
+	// Zero thresholds leave the clock and the database unchecked
+	config.MaxClockDrift, config.MaxDBLatency = 0, 0
+	if problems := config.problems(&HealthReport{Peers: 3, ClockDrift: time.Hour, DBLatency: time.Hour}); len(problems) != 0 {
+		t.Errorf("unchecked thresholds reported: %v", problems)
+	}
+}
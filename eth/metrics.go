@@ -54,6 +54,32 @@ var (
 	miscInTrafficMeter        = metrics.NewRegisteredMeter("eth/misc/in/traffic", nil)
 	miscOutPacketsMeter       = metrics.NewRegisteredMeter("eth/misc/out/packets", nil)
 	miscOutTrafficMeter       = metrics.NewRegisteredMeter("eth/misc/out/traffic", nil)
+
+	propTxnHashInPacketsMeter  = metrics.NewRegisteredMeter("eth/prop/txhashes/in/packets", nil)
+	propTxnHashInTrafficMeter  = metrics.NewRegisteredMeter("eth/prop/txhashes/in/traffic", nil)
+	propTxnHashOutPacketsMeter = metrics.NewRegisteredMeter("eth/prop/txhashes/out/packets", nil)
+	propTxnHashOutTrafficMeter = metrics.NewRegisteredMeter("eth/prop/txhashes/out/traffic", nil)
+
+	compactBodyCompleteMeter = metrics.NewRegisteredMeter("eth/compact/bodies/complete", nil)
+	compactBodyPartialMeter  = metrics.NewRegisteredMeter("eth/compact/bodies/partial", nil)
+	compactTxPoolMeter       = metrics.NewRegisteredMeter("eth/compact/txs/pooled", nil)
+	compactTxFetchMeter      = metrics.NewRegisteredMeter("eth/compact/txs/fetched", nil)
+
+	bookSampleOutMeter      = metrics.NewRegisteredMeter("eth/booksample/out", nil)
+	bookSampleMatchMeter    = metrics.NewRegisteredMeter("eth/booksample/match", nil)
+	bookSampleMismatchMeter = metrics.NewRegisteredMeter("eth/booksample/mismatch", nil)
+	bookSampleUnknownMeter  = metrics.NewRegisteredMeter("eth/booksample/unknown", nil)
+
+	voteInMeter      = metrics.NewRegisteredMeter("eth/votes/in", nil)
+	voteOutMeter     = metrics.NewRegisteredMeter("eth/votes/out", nil)
+	voteInvalidMeter = metrics.NewRegisteredMeter("eth/votes/invalid", nil)
+
+	peerScoreDropMeter   = metrics.NewRegisteredMeter("eth/peers/score/dropped", nil)
+	peerScoreRejectMeter = metrics.NewRegisteredMeter("eth/peers/score/rejected", nil)
+
+	reqThrottledMeter = metrics.NewRegisteredMeter("eth/req/throttled", nil)
+	reqAbuseMeter     = metrics.NewRegisteredMeter("eth/req/abuse", nil)
+	reqStaleMeter     = metrics.NewRegisteredMeter("eth/req/stale", nil)
 )
 
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
@@ -91,6 +117,8 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		packets, traffic = reqHeaderInPacketsMeter, reqHeaderInTrafficMeter
 	case msg.Code == BlockBodiesMsg:
 		packets, traffic = reqBodyInPacketsMeter, reqBodyInTrafficMeter
+	case rw.version >= eth100 && (msg.Code == BlockTxHashesMsg || msg.Code == BlockTxsMsg):
+		packets, traffic = reqBodyInPacketsMeter, reqBodyInTrafficMeter
 
 	case rw.version >= eth63 && msg.Code == NodeDataMsg:
 		packets, traffic = reqStateInPacketsMeter, reqStateInTrafficMeter
@@ -103,6 +131,10 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		packets, traffic = propBlockInPacketsMeter, propBlockInTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnInPacketsMeter, propTxnInTrafficMeter
+	case rw.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		packets, traffic = propTxnHashInPacketsMeter, propTxnHashInTrafficMeter
+	case rw.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		packets, traffic = propTxnInPacketsMeter, propTxnInTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
@@ -118,6 +150,8 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		packets, traffic = reqHeaderOutPacketsMeter, reqHeaderOutTrafficMeter
 	case msg.Code == BlockBodiesMsg:
 		packets, traffic = reqBodyOutPacketsMeter, reqBodyOutTrafficMeter
+	case rw.version >= eth100 && (msg.Code == BlockTxHashesMsg || msg.Code == BlockTxsMsg):
+		packets, traffic = reqBodyOutPacketsMeter, reqBodyOutTrafficMeter
 
 	case rw.version >= eth63 && msg.Code == NodeDataMsg:
 		packets, traffic = reqStateOutPacketsMeter, reqStateOutTrafficMeter
@@ -130,6 +164,10 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		packets, traffic = propBlockOutPacketsMeter, propBlockOutTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnOutPacketsMeter, propTxnOutTrafficMeter
+	case rw.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		packets, traffic = propTxnHashOutPacketsMeter, propTxnHashOutTrafficMeter
+	case rw.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		packets, traffic = propTxnOutPacketsMeter, propTxnOutTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
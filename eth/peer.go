@@ -24,7 +24,9 @@ import (
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/p2p"
 	"github.com/tomochain/tomochain/rlp"
@@ -41,6 +43,9 @@ const (
 	maxKnownOrderTxs   = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownLendingTxs = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownBlocks     = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
+	maxKnownVotes      = 32768 // Maximum finality votes to keep in the known list (prevent DOS)
+	maxPartialBodies   = 64    // Maximum number of partially reconstructed bodies awaiting transactions
+	partialBodyTimeout = 5 * time.Second
 	handshakeTimeout   = 5 * time.Second
 )
 
@@ -50,6 +55,8 @@ type PeerInfo struct {
 	Version    int      `json:"version"`    // Ethereum protocol version negotiated
 	Difficulty *big.Int `json:"difficulty"` // Total difficulty of the peer's blockchain
 	Head       string   `json:"head"`       // SHA3 hash of the peer's best owned block
+
+	Score *PeerScoreInfo `json:"score"` // Reputation of the peer
 }
 
 type peer struct {
@@ -70,6 +77,26 @@ type peer struct {
 	knownBlocks     mapset.Set // Set of block hashes known to be known by this peer
 	knownOrderTxs   mapset.Set // Set of order transaction hashes known to be known by this peer
 	knownLendingTxs mapset.Set // Set of lending transaction hashes known to be known by this peer
+	knownVotes      mapset.Set // Set of finality vote IDs known to be known by this peer
+
+	partials    map[common.Hash]*partialBody // Block bodies waiting for missing transactions from this peer
+	partialLock sync.Mutex                   // Protects the partial bodies
+
+	score *peerScore // Reputation of the peer, shared with its paired connection
+	drop  func()     // Drops the peer once its score falls below minPeerScore
+
+	limiter  *p2p.MsgRateLimiter // Limit of the data retrieval requests served to the peer
+	requests *requestTracker     // Requests pending a response, shared with the paired connection
+}
+
+// partialBody is a block body reconstructed from the local transaction pool,
+// waiting for the transactions missing locally to arrive from the remote peer.
+type partialBody struct {
+	hashes  []common.Hash        // Hashes of all the transactions in the block
+	txs     []*types.Transaction // Transactions of the block, nil where missing
+	uncles  []*types.Header      // Uncles of the block
+	missing []uint64             // Positions of the transactions requested from the peer
+	time    time.Time            // Time the missing transactions were requested
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -84,9 +111,45 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		knownBlocks:     mapset.NewSet(),
 		knownOrderTxs:   mapset.NewSet(),
 		knownLendingTxs: mapset.NewSet(),
+		knownVotes:      mapset.NewSet(),
+		partials:        make(map[common.Hash]*partialBody),
+		score:           newPeerScore(),
+		limiter:         p2p.NewMsgRateLimiter(requestRates, time.Now()),
+		requests:        newRequestTracker(),
 	}
 }
 
+// trackPartialBody stores a partially reconstructed block body until the missing
+// transactions are delivered by the peer. It returns false if the peer already
+// has too many outstanding partial bodies.
+func (p *peer) trackPartialBody(hash common.Hash, body *partialBody) bool {
+	p.partialLock.Lock()
+	defer p.partialLock.Unlock()
+
+	// Drop any bodies the peer failed to complete in time
+	for hash, partial := range p.partials {
+		if time.Since(partial.time) > partialBodyTimeout {
+			delete(p.partials, hash)
+		}
+	}
+	if len(p.partials) >= maxPartialBodies {
+		return false
+	}
+	p.partials[hash] = body
+	return true
+}
+
+// takePartialBody retrieves and forgets the partial block body with the given
+// hash, or nil if no such body was requested from the peer.
+func (p *peer) takePartialBody(hash common.Hash) *partialBody {
+	p.partialLock.Lock()
+	defer p.partialLock.Unlock()
+
+	body := p.partials[hash]
+	delete(p.partials, hash)
+	return body
+}
+
 // Info gathers and returns a collection of metadata known about a peer.
 func (p *peer) Info() *PeerInfo {
 	hash, td := p.Head()
@@ -95,6 +158,42 @@ func (p *peer) Info() *PeerInfo {
 		Version:    p.version,
 		Difficulty: td,
 		Head:       hash.Hex(),
+		Score:      p.score.Info(),
+	}
+}
+
+// MarkUseful rewards the peer for a response worth processing.
+func (p *peer) MarkUseful() {
+	p.rescore(p.score.add(scoreUseful))
+}
+
+// MarkUseless penalises the peer for an unrequested or undeliverable response.
+func (p *peer) MarkUseless() {
+	p.rescore(p.score.markUseless())
+}
+
+// MarkTimeout penalises the peer for a request left unanswered in time. It is
+// called by the downloader.
+func (p *peer) MarkTimeout() {
+	p.rescore(p.score.markTimeout())
+}
+
+// MarkInvalidBlock penalises the peer for propagating an invalid block.
+func (p *peer) MarkInvalidBlock() {
+	p.rescore(p.score.markInvalidBlock())
+}
+
+// MarkPropagation rates the peer on the latency of a block it propagated.
+func (p *peer) MarkPropagation(latency time.Duration) {
+	p.rescore(p.score.markPropagation(latency))
+}
+
+// rescore drops the peer if its new score is too low.
+func (p *peer) rescore(score float64) {
+	if score < minPeerScore && p.drop != nil {
+		p.Log().Debug("Dropping peer with low score", "score", score)
+		peerScoreDropMeter.Mark(1)
+		p.drop()
 	}
 }
 
@@ -157,6 +256,16 @@ func (p *peer) MarkLendingTransaction(hash common.Hash) {
 	p.knownLendingTxs.Add(hash)
 }
 
+// MarkVote marks a finality vote as known for the peer, ensuring that it will
+// never be propagated to this particular peer.
+func (p *peer) MarkVote(id common.Hash) {
+	// If we reached the memory allowance, drop a previously known vote
+	for p.knownVotes.Cardinality() >= maxKnownVotes {
+		p.knownVotes.Pop()
+	}
+	p.knownVotes.Add(id)
+}
+
 // SendTransactions sends transactions to the peer and includes the hashes
 // in its transaction hash set for future reference.
 func (p *peer) SendTransactions(txs types.Transactions) error {
@@ -166,6 +275,25 @@ func (p *peer) SendTransactions(txs types.Transactions) error {
 	return p2p.Send(p.rw, TxMsg, txs)
 }
 
+// SendPooledTransactionHashes announces the availability of transactions to the
+// peer and includes the hashes in its transaction hash set for future reference.
+func (p *peer) SendPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.MarkTransaction(hash)
+	}
+	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+}
+
+// SendPooledTransactionsRLP sends the requested pooled transactions, already
+// RLP encoded, to the peer and includes their hashes in its transaction hash set
+// for future reference.
+func (p *peer) SendPooledTransactionsRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.MarkTransaction(hash)
+	}
+	return p2p.Send(p.rw, PooledTransactionsMsg, txs)
+}
+
 // SendTransactions sends transactions to the peer and includes the hashes
 // in its transaction hash set for future reference.
 func (p *peer) SendOrderTransactions(txs types.OrderTransactions) error {
@@ -208,95 +336,142 @@ func (p *peer) SendNewBlock(block *types.Block, td *big.Int) error {
 	}
 }
 
-// SendBlockHeaders sends a batch of block headers to the remote peer.
-func (p *peer) SendBlockHeaders(headers []*types.Header) error {
+// SendBlockHeaders sends a batch of block headers to the remote peer, in
+// response to the request with the given ID.
+func (p *peer) SendBlockHeaders(id uint64, headers []*types.Header) error {
+	return p.sendResponse(BlockHeadersMsg, id, headers)
+}
+
+// SendBlockBodies sends a batch of block contents to the remote peer, in
+// response to the request with the given ID.
+func (p *peer) SendBlockBodies(id uint64, bodies []*blockBody) error {
+	return p.sendResponse(BlockBodiesMsg, id, blockBodiesData(bodies))
+}
+
+// SendBlockBodiesRLP sends a batch of block contents to the remote peer from
+// an already RLP encoded format, in response to the request with the given ID.
+func (p *peer) SendBlockBodiesRLP(id uint64, bodies []rlp.RawValue) error {
+	return p.sendResponse(BlockBodiesMsg, id, bodies)
+}
+
+// SendBlockTxHashes sends a batch of compact block contents to the remote peer.
+func (p *peer) SendBlockTxHashes(bodies []*blockTxHashes) error {
 	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, BlockHeadersMsg, headers)
+		return p2p.Send(p.pairRw, BlockTxHashesMsg, blockTxHashesData(bodies))
 	} else {
-		return p2p.Send(p.rw, BlockHeadersMsg, headers)
+		return p2p.Send(p.rw, BlockTxHashesMsg, blockTxHashesData(bodies))
 	}
 }
 
-// SendBlockBodies sends a batch of block contents to the remote peer.
-func (p *peer) SendBlockBodies(bodies []*blockBody) error {
+// SendBlockTxs sends a subset of a block's transactions to the remote peer.
+func (p *peer) SendBlockTxs(hash common.Hash, txs []*types.Transaction) error {
 	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, BlockBodiesMsg, blockBodiesData(bodies))
+		return p2p.Send(p.pairRw, BlockTxsMsg, &blockTxsData{Hash: hash, Transactions: txs})
 	} else {
-		return p2p.Send(p.rw, BlockBodiesMsg, blockBodiesData(bodies))
+		return p2p.Send(p.rw, BlockTxsMsg, &blockTxsData{Hash: hash, Transactions: txs})
 	}
 }
 
-// SendBlockBodiesRLP sends a batch of block contents to the remote peer from
-// an already RLP encoded format.
-func (p *peer) SendBlockBodiesRLP(bodies []rlp.RawValue) error {
+// SendOrderBookSamples sends a batch of order book state samples to the remote peer.
+func (p *peer) SendOrderBookSamples(samples []*orderBookSample) error {
 	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, BlockBodiesMsg, bodies)
+		return p2p.Send(p.pairRw, OrderBookSampleMsg, samples)
 	} else {
-		return p2p.Send(p.rw, BlockBodiesMsg, bodies)
+		return p2p.Send(p.rw, OrderBookSampleMsg, samples)
 	}
 }
 
-// SendNodeDataRLP sends a batch of arbitrary internal data, corresponding to the
-// hashes requested.
-func (p *peer) SendNodeData(data [][]byte) error {
+// SendVote sends a finality vote to the remote peer and includes its ID in the
+// set of votes known by the peer.
+func (p *peer) SendVote(vote *posv.Vote) error {
+	p.MarkVote(vote.ID())
 	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, NodeDataMsg, data)
+		return p2p.Send(p.pairRw, VoteMsg, vote)
 	} else {
-		return p2p.Send(p.rw, NodeDataMsg, data)
+		return p2p.Send(p.rw, VoteMsg, vote)
 	}
 }
 
+// SendNodeData sends a batch of arbitrary internal data, corresponding to the
+// hashes requested by the request with the given ID.
+func (p *peer) SendNodeData(id uint64, data [][]byte) error {
+	return p.sendResponse(NodeDataMsg, id, data)
+}
+
 // SendReceiptsRLP sends a batch of transaction receipts, corresponding to the
-// ones requested from an already RLP encoded format.
-func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
-	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, ReceiptsMsg, receipts)
-	} else {
-		return p2p.Send(p.rw, ReceiptsMsg, receipts)
-	}
+// ones requested by the request with the given ID, from an already RLP encoded
+// format.
+func (p *peer) SendReceiptsRLP(id uint64, receipts []rlp.RawValue) error {
+	return p.sendResponse(ReceiptsMsg, id, receipts)
 }
 
 // RequestOneHeader is a wrapper around the header query functions to fetch a
 // single header. It is used solely by the fetcher.
 func (p *peer) RequestOneHeader(hash common.Hash) error {
 	p.Log().Debug("Fetching single header", "hash", hash)
-	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
-	} else {
-		return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false})
-	}
+	query := &getBlockHeadersData{Origin: hashOrNumber{Hash: hash}, Amount: uint64(1), Skip: uint64(0), Reverse: false}
+	return p.sendRequest(GetBlockHeadersMsg, query, BlockHeadersMsg, originFetcher)
 }
 
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
 func (p *peer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
 	p.Log().Debug("Fetching batch of headers", "count", amount, "fromhash", origin, "skip", skip, "reverse", reverse)
-	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
-	} else {
-		return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
-	}
+	query := &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse}
+	return p.sendRequest(GetBlockHeadersMsg, query, BlockHeadersMsg, originDownloader)
 }
 
 // RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the number of an origin block.
 func (p *peer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
 	p.Log().Debug("Fetching batch of headers", "count", amount, "fromnum", origin, "skip", skip, "reverse", reverse)
-	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
-	} else {
-		return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
-	}
+	query := &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse}
+	return p.sendRequest(GetBlockHeadersMsg, query, BlockHeadersMsg, originDownloader)
 }
 
 // RequestBodies fetches a batch of blocks' bodies corresponding to the hashes
 // specified.
 func (p *peer) RequestBodies(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of block bodies", "count", len(hashes))
+	return p.sendRequest(GetBlockBodiesMsg, hashes, BlockBodiesMsg, originDownloader)
+}
+
+// FetchBodies fetches a batch of blocks' bodies like RequestBodies, on behalf
+// of the block fetcher.
+func (p *peer) FetchBodies(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of propagated block bodies", "count", len(hashes))
+	return p.sendRequest(GetBlockBodiesMsg, hashes, BlockBodiesMsg, originFetcher)
+}
+
+// RequestBlockTxHashes fetches a batch of compact block bodies, containing only
+// the transaction hashes, corresponding to the hashes specified.
+func (p *peer) RequestBlockTxHashes(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of compact block bodies", "count", len(hashes))
 	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, GetBlockBodiesMsg, hashes)
+		return p2p.Send(p.pairRw, GetBlockTxHashesMsg, hashes)
 	} else {
-		return p2p.Send(p.rw, GetBlockBodiesMsg, hashes)
+		return p2p.Send(p.rw, GetBlockTxHashesMsg, hashes)
+	}
+}
+
+// RequestBlockTxs fetches the transactions at the given positions within the
+// block with the specified hash.
+func (p *peer) RequestBlockTxs(hash common.Hash, indexes []uint64) error {
+	p.Log().Debug("Fetching missing block transactions", "hash", hash, "count", len(indexes))
+	if p.pairRw != nil {
+		return p2p.Send(p.pairRw, GetBlockTxsMsg, &getBlockTxsData{Hash: hash, Indexes: indexes})
+	} else {
+		return p2p.Send(p.rw, GetBlockTxsMsg, &getBlockTxsData{Hash: hash, Indexes: indexes})
+	}
+}
+
+// RequestTxs fetches a batch of pooled transactions announced by the peer.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
+	if p.pairRw != nil {
+		return p2p.Send(p.pairRw, GetPooledTransactionsMsg, hashes)
+	} else {
+		return p2p.Send(p.rw, GetPooledTransactionsMsg, hashes)
 	}
 }
 
@@ -304,21 +479,82 @@ func (p *peer) RequestBodies(hashes []common.Hash) error {
 // data, corresponding to the specified hashes.
 func (p *peer) RequestNodeData(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of state data", "count", len(hashes))
-	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, GetNodeDataMsg, hashes)
-	} else {
-		return p2p.Send(p.rw, GetNodeDataMsg, hashes)
-	}
+	return p.sendRequest(GetNodeDataMsg, hashes, NodeDataMsg, originDownloader)
 }
 
 // RequestReceipts fetches a batch of transaction receipts from a remote node.
 func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of receipts", "count", len(hashes))
+	return p.sendRequest(GetReceiptsMsg, hashes, ReceiptsMsg, originDownloader)
+}
+
+// sendRequest sends a data retrieval request expecting a response with the
+// given code, tagging it with a new request ID for the eth/66 peers.
+func (p *peer) sendRequest(code uint64, data interface{}, answer uint64, origin requestOrigin) error {
+	if p.version >= eth66 {
+		data = &requestPacket{RequestId: p.requests.track(answer, origin, time.Now()), Data: data}
+	}
 	if p.pairRw != nil {
-		return p2p.Send(p.pairRw, GetReceiptsMsg, hashes)
+		return p2p.Send(p.pairRw, code, data)
 	} else {
-		return p2p.Send(p.rw, GetReceiptsMsg, hashes)
+		return p2p.Send(p.rw, code, data)
+	}
+}
+
+// sendResponse sends the response to a data retrieval request, tagging it with
+// the ID of the request for the eth/66 peers.
+func (p *peer) sendResponse(code uint64, id uint64, data interface{}) error {
+	if p.version >= eth66 {
+		data = &requestPacket{RequestId: id, Data: data}
+	}
+	if p.pairRw != nil {
+		return p2p.Send(p.pairRw, code, data)
+	} else {
+		return p2p.Send(p.rw, code, data)
+	}
+}
+
+// decodeMsg decodes a data retrieval request or response into val, returning
+// the request ID of the eth/66 peers.
+func (p *peer) decodeMsg(msg p2p.Msg, val interface{}) (uint64, error) {
+	if p.version < eth66 {
+		return 0, msg.Decode(val)
+	}
+	var packet rawRequestPacket
+	if err := msg.Decode(&packet); err != nil {
+		return 0, err
+	}
+	return packet.RequestId, rlp.DecodeBytes(packet.Data, val)
+}
+
+// requestStream opens the list of hashes of a data retrieval request for
+// streaming, returning the request ID of the eth/66 peers.
+func (p *peer) requestStream(msg p2p.Msg) (*rlp.Stream, uint64, error) {
+	var id uint64
+
+	stream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+	if _, err := stream.List(); err != nil {
+		return nil, 0, err
+	}
+	if p.version >= eth66 {
+		if err := stream.Decode(&id); err != nil {
+			return nil, 0, err
+		}
+		if _, err := stream.List(); err != nil {
+			return nil, 0, err
+		}
 	}
+	return stream, id, nil
+}
+
+// fulfil matches a response to its pending request, returning the retriever to
+// deliver it to, or false if no request awaits it. The responses of the peers
+// before eth/66 are offered to every retriever.
+func (p *peer) fulfil(id uint64, code uint64) (requestOrigin, bool) {
+	if p.version < eth66 {
+		return originUnknown, true
+	}
+	return p.requests.fulfil(id, code)
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
@@ -394,14 +630,17 @@ func (p *peer) String() string {
 // the Ethereum sub-protocol.
 type peerSet struct {
 	peers  map[string]*peer
+	scores *lru.Cache // Scores of the recently disconnected peers
 	lock   sync.RWMutex
 	closed bool
 }
 
 // newPeerSet creates a new peer set to track the active participants.
 func newPeerSet() *peerSet {
+	scores, _ := lru.New(maxKnownScores)
 	return &peerSet{
-		peers: make(map[string]*peer),
+		peers:  make(map[string]*peer),
+		scores: scores,
 	}
 }
 
@@ -421,6 +660,7 @@ func (ps *peerSet) Register(p *peer) error {
 		existPeer.PairPeer = p.Peer
 		existPeer.pairRw = p.rw
 		p.PairPeer = existPeer.Peer
+		p.requests = existPeer.requests
 		return p2p.ErrAddPairPeer
 	}
 	ps.peers[p.id] = p
@@ -433,13 +673,30 @@ func (ps *peerSet) Unregister(id string) error {
 	ps.lock.Lock()
 	defer ps.lock.Unlock()
 
-	if _, ok := ps.peers[id]; !ok {
+	p, ok := ps.peers[id]
+	if !ok {
 		return errNotRegistered
 	}
+	ps.scores.Add(id, p.score)
 	delete(ps.peers, id)
 	return nil
 }
 
+// Score retrieves the score of the peer with the given id: the one of the
+// registered peer, the one remembered since its disconnection, or a new one.
+func (ps *peerSet) Score(id string) *peerScore {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	if p, ok := ps.peers[id]; ok {
+		return p.score
+	}
+	if score, ok := ps.scores.Get(id); ok {
+		return score.(*peerScore)
+	}
+	return newPeerScore()
+}
+
 // Peer retrieves the registered peer with the given id.
 func (ps *peerSet) Peer(id string) *peer {
 	ps.lock.RLock()
@@ -456,6 +713,36 @@ func (ps *peerSet) Len() int {
 	return len(ps.peers)
 }
 
+// PeersWithVersion retrieves a list of peers running at least the given
+// protocol version.
+func (ps *peerSet) PeersWithVersion(version int) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if p.version >= version {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// PeersWithoutVote retrieves a list of peers running at least eth/100 that do
+// not have a given finality vote in their set of known votes.
+func (ps *peerSet) PeersWithoutVote(id common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if p.version >= eth100 && !p.knownVotes.Contains(id) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
 // PeersWithoutBlock retrieves a list of peers that do not have a given block in
 // their set of known hashes.
 func (ps *peerSet) PeersWithoutBlock(hash common.Hash) []*peer {
@@ -516,18 +803,22 @@ func (ps *peerSet) LendingPeersWithoutTx(hash common.Hash) []*peer {
 	return list
 }
 
-// BestPeer retrieves the known peer with the currently highest total difficulty.
+// BestPeer retrieves the known peer with the currently highest total difficulty,
+// the best scored one among those of equal difficulty.
 func (ps *peerSet) BestPeer() *peer {
 	ps.lock.RLock()
 	defer ps.lock.RUnlock()
 
 	var (
-		bestPeer *peer
-		bestTd   *big.Int
+		bestPeer  *peer
+		bestTd    *big.Int
+		bestScore float64
 	)
 	for _, p := range ps.peers {
-		if _, td := p.Head(); bestPeer == nil || td.Cmp(bestTd) > 0 {
-			bestPeer, bestTd = p, td
+		_, td := p.Head()
+		score := p.score.Value()
+		if bestPeer == nil || td.Cmp(bestTd) > 0 || (td.Cmp(bestTd) == 0 && score > bestScore) {
+			bestPeer, bestTd, bestScore = p, td, score
 		}
 	}
 	return bestPeer
@@ -32,19 +32,39 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	eth65 = 65
+	eth66 = 66
+
+	// eth100 is the Tomochain extension of eth/66, adding the compact block
+	// bodies, the order book samples and the votes. It's numbered apart from
+	// the standard versions, whose meanings it doesn't follow.
+	eth100 = 100
 )
 
 // Official short name of the protocol used during capability negotiation.
 var ProtocolName = "eth"
 
 // Supported versions of the eth protocol (first is primary).
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{eth100, eth66, eth65, eth63, eth62}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{26, 26, 26, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
+// requestRates is the number of data retrieval requests per second served to a
+// peer, per message code. The requests over the rate are ignored, and the peers
+// persistently exceeding it disconnected.
+var requestRates = map[uint64]float64{
+	GetBlockHeadersMsg:       100,
+	GetBlockBodiesMsg:        100,
+	GetNodeDataMsg:           100,
+	GetReceiptsMsg:           100,
+	GetBlockTxHashesMsg:      100,
+	GetBlockTxsMsg:           100,
+	GetPooledTransactionsMsg: 100,
+}
+
 // eth protocol message codes
 const (
 	// Protocol messages belonging to eth/62
@@ -63,6 +83,17 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+	// Protocol messages belonging to eth/100
+	GetBlockTxHashesMsg = 0x11
+	BlockTxHashesMsg    = 0x12
+	GetBlockTxsMsg      = 0x13
+	BlockTxsMsg         = 0x14
+	OrderBookSampleMsg  = 0x15
+	VoteMsg             = 0x16
+	// Protocol messages belonging to eth/65
+	NewPooledTransactionHashesMsg = 0x17
+	GetPooledTransactionsMsg      = 0x18
+	PooledTransactionsMsg         = 0x19
 )
 
 type errCode int
@@ -77,6 +108,7 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrRequestRateExceeded
 )
 
 func (e errCode) String() string {
@@ -94,12 +126,17 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrRequestRateExceeded:     "Request rate exceeded",
 }
 
 type txPool interface {
 	// AddRemotes should add the given transactions to the pool.
 	AddRemotes([]*types.Transaction) []error
 
+	// Get should return a transaction if it is contained in the pool, or nil
+	// otherwise.
+	Get(hash common.Hash) *types.Transaction
+
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
@@ -144,6 +181,18 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+// ethEntry is the "eth" entry of the node record, advertising the network of
+// the node to the peers discovering it.
+type ethEntry struct {
+	NetworkId uint64
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ethEntry) ENRKey() string { return "eth" }
+
 // newBlockHashesData is the network packet for the block announcements.
 type newBlockHashesData []struct {
 	Hash   common.Hash // Hash of one particular block being announced
@@ -208,3 +257,49 @@ type blockBody struct {
 
 // blockBodiesData is the network packet for block content distribution.
 type blockBodiesData []*blockBody
+
+// blockTxHashes represents the compact content of a single block, listing the
+// hashes of the contained transactions instead of the transactions themselves.
+type blockTxHashes struct {
+	Hash         common.Hash     // Hash of the block the body belongs to
+	Transactions []common.Hash   // Hashes of the transactions contained within the block
+	Uncles       []*types.Header // Uncles contained within the block
+}
+
+// blockTxHashesData is the network packet for compact block content distribution.
+type blockTxHashesData []*blockTxHashes
+
+// getBlockTxsData represents a query for a subset of a block's transactions.
+type getBlockTxsData struct {
+	Hash    common.Hash // Hash of the block to retrieve the transactions from
+	Indexes []uint64    // Positions of the requested transactions within the block
+}
+
+// blockTxsData is the network packet for partial block content distribution.
+type blockTxsData struct {
+	Hash         common.Hash          // Hash of the block the transactions belong to
+	Transactions []*types.Transaction // Requested transactions, in the order of the query
+}
+
+// requestPacket is the eth/66 network packet of the header, body, node data and
+// receipt requests and responses, tagging the packet of the older versions with
+// the ID of the request.
+type requestPacket struct {
+	RequestId uint64      // ID of the request, echoed by its response
+	Data      interface{} // Packet of the older protocol versions
+}
+
+// rawRequestPacket is a requestPacket whose data is decoded depending on the
+// message code.
+type rawRequestPacket struct {
+	RequestId uint64
+	Data      rlp.RawValue
+}
+
+// orderBookSample is a commitment to the state of an order book at a block,
+// exchanged between peers to detect diverging order matching early.
+type orderBookSample struct {
+	Block common.Hash // Hash of the block the order book state belongs to
+	Book  common.Hash // Hash of the trading pair of the order book
+	Root  common.Hash // Hash of the order book state at the block
+}
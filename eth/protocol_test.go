@@ -27,6 +27,7 @@ import (
 	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/eth/downloader"
 	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/enr"
 	"github.com/tomochain/tomochain/rlp"
 )
 
@@ -123,6 +124,7 @@ func testRecvTransactions(t *testing.T, protocol int) {
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions65(t *testing.T) { testSendTransactions(t, 65) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -146,18 +148,31 @@ func testSendTransactions(t *testing.T, protocol int) {
 			seen[tx.Hash()] = false
 		}
 		for n := 0; n < len(alltxs) && !t.Failed(); {
-			var txs []*types.Transaction
+			var hashes []common.Hash
 			msg, err := p.app.ReadMsg()
 			if err != nil {
 				t.Errorf("%v: read error: %v", p.Peer, err)
-			} else if msg.Code != TxMsg {
-				t.Errorf("%v: got code %d, want TxMsg", p.Peer, msg.Code)
-			}
-			if err := msg.Decode(&txs); err != nil {
-				t.Errorf("%v: %v", p.Peer, err)
+			} else if protocol >= eth65 {
+				// The transactions are announced to the peers retrieving them
+				if msg.Code != NewPooledTransactionHashesMsg {
+					t.Errorf("%v: got code %d, want NewPooledTransactionHashesMsg", p.Peer, msg.Code)
+				}
+				if err := msg.Decode(&hashes); err != nil {
+					t.Errorf("%v: %v", p.Peer, err)
+				}
+			} else {
+				if msg.Code != TxMsg {
+					t.Errorf("%v: got code %d, want TxMsg", p.Peer, msg.Code)
+				}
+				var txs []*types.Transaction
+				if err := msg.Decode(&txs); err != nil {
+					t.Errorf("%v: %v", p.Peer, err)
+				}
+				for _, tx := range txs {
+					hashes = append(hashes, tx.Hash())
+				}
 			}
-			for _, tx := range txs {
-				hash := tx.Hash()
+			for _, hash := range hashes {
 				seentx, want := seen[hash]
 				if seentx {
 					t.Errorf("%v: got tx more than once: %x", p.Peer, hash)
@@ -178,6 +193,81 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that the transactions announced by a peer are retrieved from it and
+// added to the local pool.
+func TestRecvTransactionAnnouncements65(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if err := p2p.Send(p.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, GetPooledTransactionsMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("transaction not requested: %v", err)
+	}
+	if err := p2p.Send(p.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added transactions mismatch: have %v, want %v", added, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no TxPreEvent received within 2 seconds")
+	}
+}
+
+// Tests that the pooled transactions are served on request, the unknown ones
+// being skipped.
+func TestGetPooledTransactions65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	pm.txpool.AddRemotes([]*types.Transaction{tx})
+
+	if err := p2p.Send(p.app, GetPooledTransactionsMsg, []common.Hash{{1}, tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Errorf("pooled transactions mismatch: %v", err)
+	}
+}
+
+// Tests that the eth/66 requests are answered with their request IDs, and that
+// the responses to unknown requests are dropped without disconnecting the peer.
+func TestRequestIDs66(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, nil, nil)
+	p, errc := newTestPeer("peer", eth66, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	if err := p2p.Send(p.app, BlockHeadersMsg, &requestPacket{RequestId: 42, Data: []*types.Header{}}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	query := &getBlockHeadersData{Origin: hashOrNumber{Number: 1}, Amount: 2}
+	if err := p2p.Send(p.app, GetBlockHeadersMsg, &requestPacket{RequestId: 42, Data: query}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	headers := []*types.Header{pm.blockchain.GetHeaderByNumber(1), pm.blockchain.GetHeaderByNumber(2)}
+	if err := p2p.ExpectMsg(p.app, BlockHeadersMsg, &requestPacket{RequestId: 42, Data: headers}); err != nil {
+		t.Errorf("headers mismatch: %v", err)
+	}
+	select {
+	case err := <-errc:
+		t.Errorf("peer dropped: %v", err)
+	default:
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
@@ -221,3 +311,28 @@ func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the discovered nodes are dialed only if they advertise the network
+// of the node in their record.
+func TestDialFilter(t *testing.T) {
+	pm := &ProtocolManager{networkId: 88}
+
+	tests := []struct {
+		entry  enr.Entry
+		accept bool
+	}{
+		{ethEntry{NetworkId: 88}, true},
+		{ethEntry{NetworkId: 89}, false},
+		{enr.WithEntry("eth", "88"), false},
+		{nil, false},
+	}
+	for i, tt := range tests {
+		var r enr.Record
+		if tt.entry != nil {
+			r.Set(tt.entry)
+		}
+		if accept := pm.dialFilter(&r); accept != tt.accept {
+			t.Errorf("test %d: acceptance mismatch: have %v, want %v", i, accept, tt.accept)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// requestTTL is the time after which a request is forgotten, its late response
+// being dropped.
+const requestTTL = time.Minute
+
+// requestOrigin identifies the retriever a request was sent by, the only one its
+// response is delivered to.
+type requestOrigin int
+
+const (
+	originUnknown    requestOrigin = iota // Response offered to every retriever, as before eth/66
+	originDownloader                      // Request of the downloader, superseding its previous one of the same kind
+	originFetcher                         // Request of the block fetcher
+)
+
+// pendingRequest is a data retrieval request waiting for its response.
+type pendingRequest struct {
+	code   uint64        // Code of the response message expected
+	origin requestOrigin // Retriever the response is delivered to
+	sent   time.Time     // Time the request was sent
+}
+
+// requestTracker matches the responses of an eth/66 peer to the requests sent
+// to it by their IDs, so that concurrent requests of the downloader and of the
+// fetcher are told apart, and the responses to the abandoned requests dropped.
+// It is shared by the paired connections of the peer.
+type requestTracker struct {
+	next    uint64
+	pending map[uint64]*pendingRequest
+	lock    sync.Mutex
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		next:    rand.Uint64(),
+		pending: make(map[uint64]*pendingRequest),
+	}
+}
+
+// track records a request expecting a response with the given code, returning
+// the ID of the request. As the downloader has a single request of each kind in
+// flight to a peer, its new requests supersede the previous ones.
+func (t *requestTracker) track(code uint64, origin requestOrigin, now time.Time) uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for id, req := range t.pending {
+		superseded := origin == originDownloader && req.origin == originDownloader && req.code == code
+		if superseded || now.Sub(req.sent) > requestTTL {
+			delete(t.pending, id)
+		}
+	}
+	t.next++
+	t.pending[t.next] = &pendingRequest{code: code, origin: origin, sent: now}
+	return t.next
+}
+
+// fulfil forgets the request answered by a response with the given ID and code,
+// returning the origin of the request, or false if no such request is pending.
+func (t *requestTracker) fulfil(id uint64, code uint64) (requestOrigin, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	req := t.pending[id]
+	if req == nil || req.code != code {
+		return originUnknown, false
+	}
+	delete(t.pending, id)
+	return req.origin, true
+}
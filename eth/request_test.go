@@ -0,0 +1,54 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the responses are matched to their requests, the ones to the
+// superseded, expired or unknown requests being rejected.
+func TestRequestTracker(t *testing.T) {
+	tracker := newRequestTracker()
+	now := time.Now()
+
+	fetch := tracker.track(BlockHeadersMsg, originFetcher, now)
+	old := tracker.track(BlockHeadersMsg, originDownloader, now)
+	bodies := tracker.track(BlockBodiesMsg, originDownloader, now)
+	headers := tracker.track(BlockHeadersMsg, originDownloader, now)
+
+	if _, ok := tracker.fulfil(old, BlockHeadersMsg); ok {
+		t.Errorf("superseded request fulfilled")
+	}
+	if _, ok := tracker.fulfil(bodies, BlockHeadersMsg); ok {
+		t.Errorf("request fulfilled by a response of another kind")
+	}
+	if origin, ok := tracker.fulfil(fetch, BlockHeadersMsg); !ok || origin != originFetcher {
+		t.Errorf("fetcher request: have origin %d, ok %v", origin, ok)
+	}
+	if origin, ok := tracker.fulfil(headers, BlockHeadersMsg); !ok || origin != originDownloader {
+		t.Errorf("downloader request: have origin %d, ok %v", origin, ok)
+	}
+	if _, ok := tracker.fulfil(headers, BlockHeadersMsg); ok {
+		t.Errorf("request fulfilled twice")
+	}
+	// Tracking a request past the TTL of the pending ones forgets them
+	tracker.track(ReceiptsMsg, originDownloader, now.Add(requestTTL+time.Second))
+	if _, ok := tracker.fulfil(bodies, BlockBodiesMsg); ok {
+		t.Errorf("expired request fulfilled")
+	}
+}
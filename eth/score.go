@@ -0,0 +1,160 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	scoreUseful       = 1   // Reward of a response or block worth processing
+	scoreUseless      = -2  // Penalty of an unrequested or undeliverable response
+	scoreTimeout      = -5  // Penalty of a request left unanswered in time
+	scoreInvalidBlock = -25 // Penalty of a block failing verification
+	scoreLateBlock    = -1  // Penalty of a block propagated later than slowPropagation
+
+	maxPeerScore   = 100 // Score above which rewards are ignored
+	minPeerScore   = -50 // Score below which peers are dropped and refused
+	scoreHalfLife  = 10 * time.Minute
+	maxKnownScores = 1024 // Maximum scores of disconnected peers to remember
+
+	fastPropagation = 3 * time.Second  // Latency of the blocks rewarded as fresh
+	slowPropagation = 10 * time.Second // Latency of the blocks penalised as stale
+	latencyImpact   = 0.1              // Impact of a block on the average propagation latency
+)
+
+// PeerScoreInfo represents the reputation of a peer as reported by admin_peers.
+type PeerScoreInfo struct {
+	Value         float64 `json:"value"`         // Current score, decaying towards zero
+	Useless       uint64  `json:"useless"`       // Number of useless responses
+	Timeouts      uint64  `json:"timeouts"`      // Number of timed out requests
+	InvalidBlocks uint64  `json:"invalidBlocks"` // Number of invalid blocks propagated
+	Latency       float64 `json:"latency"`       // Average block propagation latency in seconds
+}
+
+// peerScore tracks the reputation of a peer. The score decays towards zero over
+// time, so that only persistent misbehaviour gets a peer dropped, and outlives
+// the connection to refuse the dropped peers until it has recovered.
+type peerScore struct {
+	value   float64
+	updated time.Time
+
+	useless  uint64
+	timeouts uint64
+	invalid  uint64
+	latency  time.Duration // Moving average of the block propagation latency
+
+	lock sync.Mutex
+}
+
+func newPeerScore() *peerScore {
+	return &peerScore{updated: time.Now()}
+}
+
+// decay halves the score every scoreHalfLife since its last update. The lock
+// must be held.
+func (s *peerScore) decay() {
+	now := time.Now()
+	s.value *= math.Pow(0.5, float64(now.Sub(s.updated))/float64(scoreHalfLife))
+	s.updated = now
+}
+
+// add changes the score by delta, returning the new score.
+func (s *peerScore) add(delta float64) float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.decay()
+	s.value = math.Min(s.value+delta, maxPeerScore)
+	return s.value
+}
+
+// Value returns the current score.
+func (s *peerScore) Value() float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.decay()
+	return s.value
+}
+
+// markUseless accounts for a useless response, returning the new score.
+func (s *peerScore) markUseless() float64 {
+	s.lock.Lock()
+	s.useless++
+	s.lock.Unlock()
+
+	return s.add(scoreUseless)
+}
+
+// markTimeout accounts for a timed out request, returning the new score.
+func (s *peerScore) markTimeout() float64 {
+	s.lock.Lock()
+	s.timeouts++
+	s.lock.Unlock()
+
+	return s.add(scoreTimeout)
+}
+
+// markInvalidBlock accounts for an invalid block, returning the new score.
+func (s *peerScore) markInvalidBlock() float64 {
+	s.lock.Lock()
+	s.invalid++
+	s.lock.Unlock()
+
+	return s.add(scoreInvalidBlock)
+}
+
+// markPropagation accounts for the latency of a propagated block, rewarding the
+// fresh blocks and penalising the stale ones. It returns the new score.
+func (s *peerScore) markPropagation(latency time.Duration) float64 {
+	if latency < 0 {
+		latency = 0
+	}
+	s.lock.Lock()
+	if s.latency == 0 {
+		s.latency = latency
+	} else {
+		s.latency = time.Duration((1-latencyImpact)*float64(s.latency) + latencyImpact*float64(latency))
+	}
+	s.lock.Unlock()
+
+	switch {
+	case latency <= fastPropagation:
+		return s.add(scoreUseful)
+	case latency >= slowPropagation:
+		return s.add(scoreLateBlock)
+	default:
+		return s.Value()
+	}
+}
+
+// Info gathers the score and the counters of the peer.
+func (s *peerScore) Info() *PeerScoreInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.decay()
+	return &PeerScoreInfo{
+		Value:         s.value,
+		Useless:       s.useless,
+		Timeouts:      s.timeouts,
+		InvalidBlocks: s.invalid,
+		Latency:       s.latency.Seconds(),
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/eth/downloader"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// Tests that the scores are capped and decay towards zero over time.
+func TestPeerScoreDecay(t *testing.T) {
+	s := newPeerScore()
+	if have := s.add(2 * maxPeerScore); have != maxPeerScore {
+		t.Errorf("score not capped: have %v, want %v", have, maxPeerScore)
+	}
+	s.markInvalidBlock()
+	s.markInvalidBlock()
+	s.updated = s.updated.Add(-scoreHalfLife)
+	if have, want := s.Value(), float64(maxPeerScore+2*scoreInvalidBlock)/2; math.Abs(have-want) > 0.1 {
+		t.Errorf("score not decayed: have %v, want %v", have, want)
+	}
+	if info := s.Info(); info.InvalidBlocks != 2 {
+		t.Errorf("invalid block count mismatch: have %d, want %d", info.InvalidBlocks, 2)
+	}
+}
+
+// Tests that the fresh blocks are rewarded, the stale ones penalised, and their
+// latency averaged.
+func TestPeerScorePropagation(t *testing.T) {
+	s := newPeerScore()
+	if have := s.markPropagation(time.Second); have != scoreUseful {
+		t.Errorf("fresh block: score mismatch: have %v, want %v", have, scoreUseful)
+	}
+	if have := s.markPropagation(time.Minute); math.Abs(have-(scoreUseful+scoreLateBlock)) > 0.01 {
+		t.Errorf("stale block: score mismatch: have %v, want %v", have, scoreUseful+scoreLateBlock)
+	}
+	if have, want := s.Info().Latency, 0.9*1+0.1*60; math.Abs(have-want) > 0.01 {
+		t.Errorf("latency mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that the best peer is the one with the highest difficulty, the best
+// scored one among those of equal difficulty.
+func TestBestPeerScore(t *testing.T) {
+	var (
+		ps    = newPeerSet()
+		peers []*peer
+	)
+	for i, td := range []int64{100, 200, 200} {
+		p := newPeer(eth63, p2p.NewPeer(discover.NodeID{byte(i)}, "", nil), nil)
+		p.td = big.NewInt(td)
+		p.score.add(float64(i))
+		if err := ps.Register(p); err != nil {
+			t.Fatalf("failed to register peer %d: %v", i, err)
+		}
+		peers = append(peers, p)
+	}
+	if best := ps.BestPeer(); best != peers[2] {
+		t.Errorf("best peer mismatch: have %v, want %v", best, peers[2])
+	}
+	// Difficulty prevails over score
+	peers[0].score.add(minPeerScore / 2)
+	peers[0].td = big.NewInt(300)
+	if best := ps.BestPeer(); best != peers[0] {
+		t.Errorf("best peer mismatch: have %v, want %v", best, peers[0])
+	}
+}
+
+// Tests that a peer sending useless responses is dropped, and refused until its
+// score recovers.
+func TestUselessPeerDrop(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	peer, _ := newTestPeer("peer", eth63, pm, true)
+	defer peer.close()
+
+	for i := 0; i < -minPeerScore/-scoreUseless+1; i++ {
+		if err := p2p.Send(peer.app, ReceiptsMsg, [][]*types.Receipt{}); err != nil {
+			t.Fatalf("failed to send receipts: %v", err)
+		}
+	}
+	for i := 0; pm.peers.Peer(peer.id) != nil; i++ {
+		if i == 100 {
+			t.Fatal("useless peer not dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info := pm.peers.Score(peer.id).Info(); info.Useless == 0 {
+		t.Error("useless responses not remembered")
+	}
+	// Reconnecting with the same identity is refused
+	_, net := p2p.MsgPipe()
+	again := pm.newPeer(eth63, p2p.NewPeer(peer.Peer.ID(), "peer", nil), net)
+	if err := pm.handle(again); err != p2p.DiscUselessPeer {
+		t.Errorf("reconnection error mismatch: have %v, want %v", err, p2p.DiscUselessPeer)
+	}
+}
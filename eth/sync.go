@@ -85,9 +85,19 @@ func (pm *ProtocolManager) txsyncLoop() {
 		if len(s.txs) == 0 {
 			delete(pending, s.p.ID())
 		}
-		// Send the pack in the background.
-		s.p.Log().Trace("Sending batch of transactions", "count", len(pack.txs), "bytes", size)
+		// Send the pack in the background, or only announce it to the peers
+		// retrieving the transactions they miss.
 		sending = true
+		if s.p.version >= eth65 {
+			hashes := make([]common.Hash, len(pack.txs))
+			for i, tx := range pack.txs {
+				hashes[i] = tx.Hash()
+			}
+			s.p.Log().Trace("Announcing batch of transactions", "count", len(hashes))
+			go func() { done <- pack.p.SendPooledTransactionHashes(hashes) }()
+			return
+		}
+		s.p.Log().Trace("Sending batch of transactions", "count", len(pack.txs), "bytes", size)
 		go func() { done <- pack.p.SendTransactions(pack.txs) }()
 	}
 
@@ -135,6 +145,8 @@ func (pm *ProtocolManager) syncer() {
 	// Start and ensure cleanup of sync mechanisms
 	pm.fetcher.Start()
 	defer pm.fetcher.Stop()
+	pm.txFetcher.Start()
+	defer pm.txFetcher.Stop()
 	defer pm.downloader.Terminate()
 
 	// Wait for different events to fire synchronisation operations
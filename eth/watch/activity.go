@@ -0,0 +1,148 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package watch
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Kinds of address activity.
+const (
+	KindBalance  = "balance"
+	KindTransfer = "transfer"
+	KindTrade    = "trade"
+	KindLending  = "lending"
+)
+
+// transferTopic is the topic of the ERC20/TRC21 Transfer event.
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// Activity is a single notification about an event touching a watched address.
+type Activity struct {
+	Address         common.Address             `json:"address"`
+	Kind            string                     `json:"kind"`
+	BlockHash       common.Hash                `json:"blockHash"`
+	BlockNumber     hexutil.Uint64             `json:"blockNumber"`
+	Balance         *hexutil.Big               `json:"balance,omitempty"`
+	PreviousBalance *hexutil.Big               `json:"previousBalance,omitempty"`
+	Log             *types.Log                 `json:"log,omitempty"`
+	Trade           *tradingstate.Trade        `json:"trade,omitempty"`
+	LendingTrade    *lendingstate.LendingTrade `json:"lendingTrade,omitempty"`
+}
+
+// chainActivity returns the balance changes and token transfers of an imported
+// block which touch the watched addresses.
+func chainActivity(backend Backend, ev core.ChainEvent, list *watchlist) []*Activity {
+	var (
+		block      = ev.Block
+		activities []*Activity
+	)
+	addresses := list.list()
+	if len(addresses) == 0 {
+		return nil
+	}
+	sort.Slice(addresses, func(i, j int) bool { return bytes.Compare(addresses[i][:], addresses[j][:]) < 0 })
+
+	if parent := backend.GetHeader(block.ParentHash(), block.NumberU64()-1); parent != nil {
+		prevState, err := backend.StateAt(parent.Root)
+		if err != nil {
+			log.Debug("Failed to open parent state for watchlists", "number", block.Number(), "err", err)
+			return nil
+		}
+		curState, err := backend.StateAt(block.Root())
+		if err != nil {
+			log.Debug("Failed to open block state for watchlists", "number", block.Number(), "err", err)
+			return nil
+		}
+		for _, addr := range addresses {
+			prev, cur := prevState.GetBalance(addr), curState.GetBalance(addr)
+			if prev.Cmp(cur) == 0 {
+				continue
+			}
+			activities = append(activities, &Activity{
+				Address:         addr,
+				Kind:            KindBalance,
+				BlockHash:       ev.Hash,
+				BlockNumber:     hexutil.Uint64(block.NumberU64()),
+				Balance:         (*hexutil.Big)(cur),
+				PreviousBalance: (*hexutil.Big)(prev),
+			})
+		}
+	}
+	for _, l := range ev.Logs {
+		if len(l.Topics) != 3 || l.Topics[0] != transferTopic {
+			continue
+		}
+		from, to := common.BytesToAddress(l.Topics[1].Bytes()), common.BytesToAddress(l.Topics[2].Bytes())
+		for _, addr := range uniqueAddresses(from, to) {
+			if list.contains(addr) {
+				activities = append(activities, &Activity{
+					Address:     addr,
+					Kind:        KindTransfer,
+					BlockHash:   ev.Hash,
+					BlockNumber: hexutil.Uint64(block.NumberU64()),
+					Log:         l,
+				})
+			}
+		}
+	}
+	return activities
+}
+
+// tradingActivity returns the order fills and lending trades of an imported
+// block which touch the watched addresses.
+func tradingActivity(ev core.TradingEvent, list *watchlist) []*Activity {
+	var (
+		hash       = ev.Block.Hash()
+		number     = hexutil.Uint64(ev.Block.NumberU64())
+		activities []*Activity
+	)
+	for _, trade := range ev.Trades {
+		for _, addr := range uniqueAddresses(trade.Taker, trade.Maker) {
+			if list.contains(addr) {
+				activities = append(activities, &Activity{Address: addr, Kind: KindTrade, BlockHash: hash, BlockNumber: number, Trade: trade})
+			}
+		}
+	}
+	for _, trade := range ev.LendingTrades {
+		for _, addr := range uniqueAddresses(trade.Borrower, trade.Investor) {
+			if list.contains(addr) {
+				activities = append(activities, &Activity{Address: addr, Kind: KindLending, BlockHash: hash, BlockNumber: number, LendingTrade: trade})
+			}
+		}
+	}
+	return activities
+}
+
+// uniqueAddresses returns the two parties of an event, deduplicated so that
+// self-transfers and self-trades are notified once.
+func uniqueAddresses(a, b common.Address) []common.Address {
+	if a == b {
+		return []common.Address{a}
+	}
+	return []common.Address{a, b}
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package watch implements address watchlist subscriptions, pushing every
+// balance change, token transfer, order fill and lending trade touching the
+// watched addresses as blocks are imported.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/rpc"
+)
+
+// maxWatchedAddresses is the maximum number of addresses a single watchlist
+// may hold.
+const maxWatchedAddresses = 1000
+
+var errWatchlistNotFound = errors.New("watchlist not found")
+
+// Backend is the chain access needed to compute address activity.
+type Backend interface {
+	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+	SubscribeTradingEvent(ch chan<- core.TradingEvent) event.Subscription
+	GetHeader(hash common.Hash, number uint64) *types.Header
+	StateAt(root common.Hash) (*state.StateDB, error)
+}
+
+// watchlist is the set of addresses watched by a single subscription.
+type watchlist struct {
+	lock      sync.RWMutex
+	addresses map[common.Address]struct{}
+}
+
+// PublicWatchAPI offers address watchlist subscriptions over RPC.
+type PublicWatchAPI struct {
+	backend Backend
+
+	lock       sync.Mutex
+	watchlists map[rpc.ID]*watchlist
+}
+
+// NewPublicWatchAPI creates a new address watchlist API.
+func NewPublicWatchAPI(backend Backend) *PublicWatchAPI {
+	return &PublicWatchAPI{
+		backend:    backend,
+		watchlists: make(map[rpc.ID]*watchlist),
+	}
+}
+
+// Activity creates a subscription that is notified of every balance change,
+// token transfer, order fill and lending trade touching the given addresses.
+// The watchlist can be changed later on through AddAddresses and
+// RemoveAddresses using the subscription id.
+func (api *PublicWatchAPI) Activity(ctx context.Context, addresses []common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	list := &watchlist{addresses: make(map[common.Address]struct{})}
+	if err := list.add(addresses); err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	api.lock.Lock()
+	api.watchlists[rpcSub.ID] = list
+	api.lock.Unlock()
+
+	go func() {
+		chainCh := make(chan core.ChainEvent, 16)
+		tradingCh := make(chan core.TradingEvent, 16)
+		chainSub := api.backend.SubscribeChainEvent(chainCh)
+		tradingSub := api.backend.SubscribeTradingEvent(tradingCh)
+		defer func() {
+			chainSub.Unsubscribe()
+			tradingSub.Unsubscribe()
+
+			api.lock.Lock()
+			delete(api.watchlists, rpcSub.ID)
+			api.lock.Unlock()
+		}()
+		for {
+			select {
+			case ev := <-chainCh:
+				for _, activity := range chainActivity(api.backend, ev, list) {
+					notifier.Notify(rpcSub.ID, activity)
+				}
+			case ev := <-tradingCh:
+				for _, activity := range tradingActivity(ev, list) {
+					notifier.Notify(rpcSub.ID, activity)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// AddAddresses adds the given addresses to the watchlist of a subscription.
+func (api *PublicWatchAPI) AddAddresses(id rpc.ID, addresses []common.Address) error {
+	list, err := api.watchlist(id)
+	if err != nil {
+		return err
+	}
+	return list.add(addresses)
+}
+
+// RemoveAddresses removes the given addresses from the watchlist of a subscription.
+func (api *PublicWatchAPI) RemoveAddresses(id rpc.ID, addresses []common.Address) error {
+	list, err := api.watchlist(id)
+	if err != nil {
+		return err
+	}
+	list.lock.Lock()
+	defer list.lock.Unlock()
+
+	for _, addr := range addresses {
+		delete(list.addresses, addr)
+	}
+	return nil
+}
+
+// Addresses returns the addresses watched by a subscription.
+func (api *PublicWatchAPI) Addresses(id rpc.ID) ([]common.Address, error) {
+	list, err := api.watchlist(id)
+	if err != nil {
+		return nil, err
+	}
+	return list.list(), nil
+}
+
+func (api *PublicWatchAPI) watchlist(id rpc.ID) (*watchlist, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	list, ok := api.watchlists[id]
+	if !ok {
+		return nil, errWatchlistNotFound
+	}
+	return list, nil
+}
+
+func (w *watchlist) add(addresses []common.Address) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, addr := range addresses {
+		if _, ok := w.addresses[addr]; ok {
+			continue
+		}
+		if len(w.addresses) >= maxWatchedAddresses {
+			return fmt.Errorf("watchlist exceeds %d addresses", maxWatchedAddresses)
+		}
+		w.addresses[addr] = struct{}{}
+	}
+	return nil
+}
+
+func (w *watchlist) contains(addr common.Address) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, ok := w.addresses[addr]
+	return ok
+}
+
+func (w *watchlist) list() []common.Address {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	addresses := make([]common.Address, 0, len(w.addresses))
+	for addr := range w.addresses {
+		addresses = append(addresses, addr)
+	}
+	return addresses
+}
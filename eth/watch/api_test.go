@@ -0,0 +1,160 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package watch
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Tests that balance changes of watched addresses are pushed to subscribers as
+// blocks are imported, and that watchlists can be changed afterwards.
+func TestActivitySubscription(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		bank    = crypto.PubkeyToAddress(key.PublicKey)
+		watched = common.Address{0x01}
+		other   = common.Address{0x02}
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{bank: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+		to := watched
+		if i == 1 {
+			to = other
+		}
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(bank), to, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, key)
+		gen.AddTx(tx)
+	})
+	api := NewPublicWatchAPI(blockchain)
+	server := rpc.NewServer()
+	if err := server.RegisterName("watch", api); err != nil {
+		t.Fatalf("failed to register api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	activities := make(chan *Activity, 16)
+	sub, err := client.Subscribe(context.Background(), "watch", activities, "activity", []common.Address{watched})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Wait for the subscription to be installed before importing
+	var id rpc.ID
+	for i := 0; i < 100 && id == ""; i++ {
+		api.lock.Lock()
+		for subId := range api.watchlists {
+			id = subId
+		}
+		api.lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := api.AddAddresses(id, []common.Address{other}); err != nil {
+		t.Fatalf("failed to extend watchlist: %v", err)
+	}
+	if addresses, _ := api.Addresses(id); len(addresses) != 2 {
+		t.Fatalf("watchlist length mismatch: have %d, want 2", len(addresses))
+	}
+	if err := api.AddAddresses("unknown", []common.Address{other}); err != errWatchlistNotFound {
+		t.Fatalf("unknown watchlist extended: %v", err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for i, want := range []common.Address{watched, other} {
+		select {
+		case activity := <-activities:
+			if activity.Kind != KindBalance || activity.Address != want || uint64(activity.BlockNumber) != uint64(i+1) {
+				t.Fatalf("activity %d mismatch: have %s of %x at %d, want balance of %x at %d", i, activity.Kind, activity.Address, activity.BlockNumber, want, i+1)
+			}
+			if activity.Balance.ToInt().Cmp(big.NewInt(1000)) != 0 || activity.PreviousBalance.ToInt().Sign() != 0 {
+				t.Fatalf("activity %d balance mismatch: have %v (was %v), want 1000 (was 0)", i, activity.Balance, activity.PreviousBalance)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("activity %d not delivered", i)
+		}
+	}
+}
+
+// headerlessBackend is a backend without any parent headers, so that no
+// balance changes are reported.
+type headerlessBackend struct{ Backend }
+
+func (headerlessBackend) GetHeader(hash common.Hash, number uint64) *types.Header { return nil }
+
+// Tests that token transfers, order fills and lending trades are matched
+// against the watchlist on both of their parties.
+func TestActivityMatching(t *testing.T) {
+	var (
+		watched = common.Address{0x01}
+		other   = common.Address{0x02}
+		list    = &watchlist{addresses: map[common.Address]struct{}{watched: {}}}
+		block   = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	)
+	transfer := &types.Log{Topics: []common.Hash{transferTopic, other.Hash(), watched.Hash()}}
+	approval := &types.Log{Topics: []common.Hash{crypto.Keccak256Hash([]byte("Approval(address,address,uint256)")), watched.Hash(), other.Hash()}}
+
+	activities := chainActivity(headerlessBackend{}, core.ChainEvent{Block: block, Hash: block.Hash(), Logs: []*types.Log{transfer, approval}}, list)
+	if len(activities) != 1 || activities[0].Kind != KindTransfer || activities[0].Log != transfer {
+		t.Fatalf("transfer activity mismatch: %+v", activities)
+	}
+	activities = tradingActivity(core.TradingEvent{
+		Block: block,
+		Trades: []*tradingstate.Trade{
+			{Taker: other, Maker: watched},
+			{Taker: other, Maker: other},
+			{Taker: watched, Maker: watched},
+		},
+		LendingTrades: []*lendingstate.LendingTrade{
+			{Borrower: watched, Investor: other},
+		},
+	}, list)
+	kinds := []string{KindTrade, KindTrade, KindLending}
+	if len(activities) != len(kinds) {
+		t.Fatalf("trading activity count mismatch: have %d, want %d", len(activities), len(kinds))
+	}
+	for i, activity := range activities {
+		if activity.Kind != kinds[i] || activity.Address != watched {
+			t.Errorf("activity %d mismatch: have %s of %x, want %s of %x", i, activity.Kind, activity.Address, kinds[i], watched)
+		}
+	}
+}
@@ -74,6 +74,9 @@ type Database struct {
 	level0CompGauge    metrics.Gauge // Gauge for tracking the number of table compaction in level0
 	nonlevel0CompGauge metrics.Gauge // Gauge for tracking the number of table compaction in non0 level
 	seekCompGauge      metrics.Gauge // Gauge for tracking the number of table compaction caused by read opt
+	writePausedGauge   metrics.Gauge // Gauge for tracking whether writes are stalled until compaction catches up
+	openTablesGauge    metrics.Gauge // Gauge for tracking the number of table files held open
+	blockCacheGauge    metrics.Gauge // Gauge for tracking the size of the block cache
 
 	quitLock sync.Mutex      // Mutex protecting the quit channel access
 	quitChan chan chan error // Quit channel to stop the metrics collection before closing the database
@@ -127,6 +130,9 @@ func New(file string, cache int, handles int, namespace string) (*Database, erro
 	ldb.level0CompGauge = metrics.NewRegisteredGauge(namespace+"compact/level0", nil)
 	ldb.nonlevel0CompGauge = metrics.NewRegisteredGauge(namespace+"compact/nonlevel0", nil)
 	ldb.seekCompGauge = metrics.NewRegisteredGauge(namespace+"compact/seek", nil)
+	ldb.writePausedGauge = metrics.NewRegisteredGauge(namespace+"compact/writedelay/paused", nil)
+	ldb.openTablesGauge = metrics.NewRegisteredGauge(namespace+"disk/tables", nil)
+	ldb.blockCacheGauge = metrics.NewRegisteredGauge(namespace+"cache/block/size", nil)
 
 	// Start up the metrics gathering and return
 	go ldb.meter(metricsGatheringInterval)
@@ -334,6 +340,13 @@ func (db *Database) meter(refresh time.Duration) {
 		if db.writeDelayMeter != nil {
 			db.writeDelayMeter.Mark(duration.Nanoseconds() - delaystats[1])
 		}
+		if db.writePausedGauge != nil {
+			if paused {
+				db.writePausedGauge.Update(1)
+			} else {
+				db.writePausedGauge.Update(0)
+			}
+		}
 		// If a warning that db is performing compaction has been displayed, any subsequent
 		// warnings will be withheld for one minute not to overwhelm the user.
 		if paused && delayN-delaystats[0] == 0 && duration.Nanoseconds()-delaystats[1] == 0 &&
@@ -398,6 +411,16 @@ func (db *Database) meter(refresh time.Duration) {
 		db.nonlevel0CompGauge.Update(int64(nonLevel0Comp))
 		db.seekCompGauge.Update(int64(seekComp))
 
+		// Retrieve the open files and cache usage
+		var dbstats leveldb.DBStats
+		if err := db.db.Stats(&dbstats); err != nil {
+			db.log.Error("Failed to read database statistics", "err", err)
+			merr = err
+			continue
+		}
+		db.openTablesGauge.Update(int64(dbstats.OpenedTablesCount))
+		db.blockCacheGauge.Update(int64(dbstats.BlockCacheSize))
+
 		// Sleep a bit, then repeat the stats collection
 		select {
 		case errc = <-db.quitChan:
@@ -31,6 +31,7 @@ require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/karalabe/hid v1.0.0
 	github.com/kkdai/bstream v0.0.0-20161212061736-f391b8402d23 // indirect
+	github.com/lib/pq v1.10.9
 	github.com/maruel/panicparse v0.0.0-20160720141634-ad661195ed0e // indirect
 	github.com/mattn/go-colorable v0.1.0
 	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b // indirect
@@ -158,6 +158,33 @@ func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+// RPCGapStatus is the handling of the pending transactions by the next block, if
+// it is one of the gap blocks before a checkpoint.
+type RPCGapStatus struct {
+	InGap         bool           `json:"inGap"`
+	Checkpoint    hexutil.Uint64 `json:"checkpoint"`
+	Policy        string         `json:"policy"`
+	SpecialPolicy string         `json:"specialPolicy"`
+	Delayed       []common.Hash  `json:"delayed"`
+}
+
+// Gap returns whether the next block is a gap block, the gap policies of the
+// normal and special transactions, and the pending transactions they delay
+// until after the checkpoint.
+func (s *PublicTxPoolAPI) Gap() (*RPCGapStatus, error) {
+	status := s.b.GetPoolGapStatus()
+	if status == nil {
+		return nil, errors.New("no gap policies for the pool of a light client")
+	}
+	return &RPCGapStatus{
+		InGap:         status.InGap,
+		Checkpoint:    hexutil.Uint64(status.Checkpoint),
+		Policy:        string(status.Policy),
+		SpecialPolicy: string(status.SpecialPolicy),
+		Delayed:       status.Delayed,
+	}, nil
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
 func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
@@ -403,8 +430,8 @@ func (s *PrivateAccountAPI) SignTransaction(ctx context.Context, args SendTxArgs
 	if args.Gas == nil {
 		return nil, fmt.Errorf("gas not specified")
 	}
-	if args.GasPrice == nil {
-		return nil, fmt.Errorf("gasPrice not specified")
+	if args.GasPrice == nil && (args.MaxFeePerGas == nil || args.MaxPriorityFeePerGas == nil) {
+		return nil, fmt.Errorf("missing gasPrice or maxFeePerGas/maxPriorityFeePerGas")
 	}
 	if args.Nonce == nil {
 		return nil, fmt.Errorf("nonce not specified")
@@ -424,7 +451,8 @@ func (s *PrivateAccountAPI) SignTransaction(ctx context.Context, args SendTxArgs
 // safely used to calculate a signature from.
 //
 // The hash is calulcated as
-//   keccak256("\x19Ethereum Signed Message:\n"${message length}${message}).
+//
+//	keccak256("\x19Ethereum Signed Message:\n"${message length}${message}).
 //
 // This gives context to the signed message and prevents signing of transactions.
 func signHash(data []byte) []byte {
@@ -495,12 +523,13 @@ func (s *PrivateAccountAPI) SignAndSendTransaction(ctx context.Context, args Sen
 // PublicBlockChainAPI provides an API to access the Ethereum blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
-	b Backend
+	b         Backend
+	callCache *callCache // Cache of eth_call results, nil if disabled
 }
 
 // NewPublicBlockChainAPI creates a new Ethereum blockchain API.
 func NewPublicBlockChainAPI(b Backend) *PublicBlockChainAPI {
-	return &PublicBlockChainAPI{b}
+	return &PublicBlockChainAPI{b: b, callCache: newCallCache(b.CallCacheSize())}
 }
 
 // BlockNumber returns the block number of the chain head.
@@ -518,10 +547,13 @@ func (s *PublicBlockChainAPI) GetRewardByHash(hash common.Hash) map[string]map[s
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
 func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	if err := verifyStateRead(s.b, blockNr, state, header, address); err != nil {
+		return nil, err
+	}
 	b := state.GetBalance(address)
 	return b, state.Error()
 }
@@ -608,10 +640,13 @@ func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, bloc
 
 // GetCode returns the code stored at the given address in the state for the given block number.
 func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	if err := verifyStateRead(s.b, blockNr, state, header, address); err != nil {
+		return nil, err
+	}
 	code := state.GetCode(address)
 	return code, state.Error()
 }
@@ -620,10 +655,13 @@ func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Addres
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
 func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	if err := verifyStateRead(s.b, blockNr, state, header, address, common.HexToHash(key)); err != nil {
+		return nil, err
+	}
 	res := state.GetState(address, common.HexToHash(key))
 	return res[:], state.Error()
 }
@@ -680,6 +718,25 @@ func (s *PublicBlockChainAPI) GetBlockFinalityByNumber(ctx context.Context, bloc
 	return s.findFinalityOfBlock(ctx, block, masternodes)
 }
 
+// GetFinalizedBlock returns the highest block finalized by the votes of two thirds
+// of its masternodes, or nil if no block was finalized yet. When fullTx is true
+// all transactions in the block are returned in full detail.
+func (s *PublicBlockChainAPI) GetFinalizedBlock(ctx context.Context, fullTx bool) (map[string]interface{}, error) {
+	engine, ok := s.b.GetEngine().(*posv.Posv)
+	if !ok {
+		return nil, errors.New("finality requires the posv consensus engine")
+	}
+	hash, _ := engine.Finalized()
+	if hash == (common.Hash{}) {
+		return nil, nil
+	}
+	block, err := s.b.GetBlock(ctx, hash)
+	if block != nil {
+		return s.rpcOutputBlock(block, true, fullTx, ctx)
+	}
+	return nil, err
+}
+
 // GetMasternodes returns masternodes set at the starting block of epoch of the given block
 func (s *PublicBlockChainAPI) GetMasternodes(ctx context.Context, b *types.Block) ([]common.Address, error) {
 	var masternodes []common.Address
@@ -692,10 +749,10 @@ func (s *PublicBlockChainAPI) GetMasternodes(ctx context.Context, b *types.Block
 		}
 		if engine, ok := s.b.GetEngine().(*posv.Posv); ok {
 			// Get block epoc latest.
-			lastCheckpointNumber := prevBlockNumber - (prevBlockNumber % s.b.ChainConfig().Posv.Epoch)
+			lastCheckpointNumber := s.b.ChainConfig().Posv.Checkpoint(prevBlockNumber)
 			prevCheckpointBlock, _ := s.b.BlockByNumber(ctx, rpc.BlockNumber(lastCheckpointNumber))
 			if prevCheckpointBlock != nil {
-				masternodes = engine.GetMasternodesFromCheckpointHeader(prevCheckpointBlock.Header(), curBlockNumber, s.b.ChainConfig().Posv.Epoch)
+				masternodes = engine.GetMasternodesFromCheckpointHeader(prevCheckpointBlock.Header(), curBlockNumber, s.b.ChainConfig().Posv.EpochLength(curBlockNumber))
 			}
 		} else {
 			log.Error("Undefined POSV consensus engine")
@@ -723,8 +780,6 @@ func (s *PublicBlockChainAPI) GetCandidateStatus(ctx context.Context, coinbaseAd
 		fieldSuccess:  true,
 	}
 
-	epochConfig := s.b.ChainConfig().Posv.Epoch
-
 	// checkpoint block
 	checkpointNumber, epochNumber = s.GetPreviousCheckpointFromEpoch(ctx, epoch)
 	result[fieldEpoch] = epochNumber.Int64()
@@ -783,7 +838,7 @@ func (s *PublicBlockChainAPI) GetCandidateStatus(ctx context.Context, coinbaseAd
 
 	// Second, Find candidates that have masternode status
 	if engine, ok := s.b.GetEngine().(*posv.Posv); ok {
-		masternodes = engine.GetMasternodesFromCheckpointHeader(header, block.Number().Uint64(), s.b.ChainConfig().Posv.Epoch)
+		masternodes = engine.GetMasternodesFromCheckpointHeader(header, block.Number().Uint64(), s.b.ChainConfig().Posv.EpochLength(block.Number().Uint64()))
 		if len(masternodes) == 0 {
 			log.Error("Failed to get masternodes", "err", err, "len(masternodes)", len(masternodes), "blockNum", header.Number.Uint64())
 			result[fieldSuccess] = false
@@ -804,10 +859,10 @@ func (s *PublicBlockChainAPI) GetCandidateStatus(ctx context.Context, coinbaseAd
 	penalties = append(penalties, header.Penalties...)
 	// check last 5 epochs to find penalize masternodes
 	for i := 1; i <= common.LimitPenaltyEpoch; i++ {
-		if header.Number.Uint64() < epochConfig*uint64(i) {
+		blockNum, ok := s.b.ChainConfig().Posv.PreviousCheckpoint(header.Number.Uint64(), uint64(i))
+		if !ok {
 			break
 		}
-		blockNum := header.Number.Uint64() - epochConfig*uint64(i)
 		checkpointHeader, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(blockNum))
 		if checkpointHeader == nil || err != nil {
 			log.Error("Failed to get header by number", "num", blockNum, "err", err)
@@ -843,7 +898,6 @@ func (s *PublicBlockChainAPI) GetCandidates(ctx context.Context, epoch rpc.Epoch
 	result := map[string]interface{}{
 		fieldSuccess: true,
 	}
-	epochConfig := s.b.ChainConfig().Posv.Epoch
 	candidatesStatusMap := map[string]map[string]interface{}{}
 
 	checkpointNumber, epochNumber = s.GetPreviousCheckpointFromEpoch(ctx, epoch)
@@ -894,7 +948,7 @@ func (s *PublicBlockChainAPI) GetCandidates(ctx context.Context, epoch rpc.Epoch
 
 	// Second, Find candidates that have masternode status
 	if engine, ok := s.b.GetEngine().(*posv.Posv); ok {
-		masternodes = engine.GetMasternodesFromCheckpointHeader(header, block.Number().Uint64(), s.b.ChainConfig().Posv.Epoch)
+		masternodes = engine.GetMasternodesFromCheckpointHeader(header, block.Number().Uint64(), s.b.ChainConfig().Posv.EpochLength(block.Number().Uint64()))
 		if len(masternodes) == 0 {
 			log.Error("Failed to get masternodes", "err", err, "len(masternodes)", len(masternodes), "blockNum", header.Number.Uint64())
 			result[fieldSuccess] = false
@@ -914,10 +968,10 @@ func (s *PublicBlockChainAPI) GetCandidates(ctx context.Context, epoch rpc.Epoch
 	penalties = append(penalties, header.Penalties...)
 	// check last 5 epochs to find penalize masternodes
 	for i := 1; i <= common.LimitPenaltyEpoch; i++ {
-		if header.Number.Uint64() < epochConfig*uint64(i) {
+		blockNum, ok := s.b.ChainConfig().Posv.PreviousCheckpoint(header.Number.Uint64(), uint64(i))
+		if !ok {
 			break
 		}
-		blockNum := header.Number.Uint64() - epochConfig*uint64(i)
 		checkpointHeader, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(blockNum))
 		if checkpointHeader == nil || err != nil {
 			log.Error("Failed to get header by number", "num", blockNum, "err", err)
@@ -957,21 +1011,20 @@ func (s *PublicBlockChainAPI) GetCandidates(ctx context.Context, epoch rpc.Epoch
 // GetPreviousCheckpointFromEpoch returns header of the previous checkpoint
 func (s *PublicBlockChainAPI) GetPreviousCheckpointFromEpoch(ctx context.Context, epochNum rpc.EpochNumber) (rpc.BlockNumber, rpc.EpochNumber) {
 	var checkpointNumber uint64
-	epoch := s.b.ChainConfig().Posv.Epoch
+	config := s.b.ChainConfig().Posv
 
 	if epochNum == rpc.LatestEpochNumber {
 		blockNumer := s.b.CurrentBlock().Number().Uint64()
-		diff := blockNumer % epoch
 		// checkpoint number
-		checkpointNumber = blockNumer - diff
-		epochNum = rpc.EpochNumber(checkpointNumber / epoch)
-		if diff > 0 {
+		checkpointNumber = config.Checkpoint(blockNumer)
+		epochNum = rpc.EpochNumber(config.EpochOf(checkpointNumber))
+		if checkpointNumber != blockNumer {
 			epochNum += 1
 		}
 	} else if epochNum < 2 {
 		checkpointNumber = 0
 	} else {
-		checkpointNumber = epoch * (uint64(epochNum) - 1)
+		checkpointNumber = config.EpochCheckpoint(uint64(epochNum) - 1)
 	}
 	return rpc.BlockNumber(checkpointNumber), epochNum
 }
@@ -1017,12 +1070,15 @@ func (s *PublicBlockChainAPI) getCandidatesFromSmartContract() ([]posv.Masternod
 
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      hexutil.Uint64  `json:"gas"`
-	GasPrice hexutil.Big     `json:"gasPrice"`
-	Value    hexutil.Big     `json:"value"`
-	Data     hexutil.Bytes   `json:"data"`
+	From                 common.Address    `json:"from"`
+	To                   *common.Address   `json:"to"`
+	Gas                  hexutil.Uint64    `json:"gas"`
+	GasPrice             hexutil.Big       `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas"`
+	Value                hexutil.Big       `json:"value"`
+	Data                 hexutil.Bytes     `json:"data"`
+	AccessList           *types.AccessList `json:"accessList"`
 }
 
 func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
@@ -1049,10 +1105,21 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
 	}
+	gasFeeCap, gasTipCap := gasPrice, gasPrice
+	if args.MaxFeePerGas != nil {
+		gasFeeCap = args.MaxFeePerGas.ToInt()
+	}
+	if args.MaxPriorityFeePerGas != nil {
+		gasTipCap = args.MaxPriorityFeePerGas.ToInt()
+	}
+	var accessList types.AccessList
+	if args.AccessList != nil {
+		accessList = *args.AccessList
+	}
 	balanceTokenFee := big.NewInt(0).SetUint64(gas)
 	balanceTokenFee = balanceTokenFee.Mul(balanceTokenFee, gasPrice)
 	// Create new call message
-	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false, balanceTokenFee)
+	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, gasFeeCap, gasTipCap, args.Data, accessList, false, balanceTokenFee)
 
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
@@ -1098,14 +1165,17 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if err := vmError(); err != nil {
 		return nil, 0, false, err
 	}
+	// If the timer or the caller aborted the call, its result is incomplete
+	if evm.Cancelled() {
+		return nil, 0, false, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+	}
 	return res, gas, failed, err
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
-	return (hexutil.Bytes)(result), err
+	return s.cachedCall(ctx, args, blockNr, 5*time.Second)
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
@@ -1246,6 +1316,9 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		"validator":        hexutil.Bytes(head.Validator),
 		"penalties":        hexutil.Bytes(head.Penalties),
 	}
+	if head.BaseFee != nil {
+		fields["baseFeePerGas"] = (*hexutil.Big)(head.BaseFee)
+	}
 
 	if inclTx {
 		formatTx := func(tx *types.Transaction) (interface{}, error) {
@@ -1293,7 +1366,7 @@ func (s *PublicBlockChainAPI) findNearestSignedBlock(ctx context.Context, b *typ
 	}
 
 	// Get block epoc latest
-	checkpointNumber := signedBlockNumber - (signedBlockNumber % s.b.ChainConfig().Posv.Epoch)
+	checkpointNumber := s.b.ChainConfig().Posv.Checkpoint(signedBlockNumber)
 	checkpointBlock, _ := s.b.BlockByNumber(ctx, rpc.BlockNumber(checkpointNumber))
 
 	if checkpointBlock != nil {
@@ -1305,8 +1378,8 @@ func (s *PublicBlockChainAPI) findNearestSignedBlock(ctx context.Context, b *typ
 }
 
 /*
-	findFinalityOfBlock return finality of a block
-	Use blocksHashCache for to keep track - refer core/blockchain.go for more detail
+findFinalityOfBlock return finality of a block
+Use blocksHashCache for to keep track - refer core/blockchain.go for more detail
 */
 func (s *PublicBlockChainAPI) findFinalityOfBlock(ctx context.Context, b *types.Block, masternodes []common.Address) (uint, error) {
 	engine, _ := s.b.GetEngine().(*posv.Posv)
@@ -1371,7 +1444,7 @@ func (s *PublicBlockChainAPI) findFinalityOfBlock(ctx context.Context, b *types.
 }
 
 /*
-	Extract signers from block
+Extract signers from block
 */
 func (s *PublicBlockChainAPI) getSigners(ctx context.Context, block *types.Block, engine *posv.Posv) ([]common.Address, error) {
 	var err error
@@ -1380,10 +1453,10 @@ func (s *PublicBlockChainAPI) getSigners(ctx context.Context, block *types.Block
 	blockNumber := block.Number().Uint64()
 
 	// Get block epoc latest.
-	checkpointNumber := blockNumber - (blockNumber % s.b.ChainConfig().Posv.Epoch)
+	checkpointNumber := s.b.ChainConfig().Posv.Checkpoint(blockNumber)
 	checkpointBlock, _ := s.b.BlockByNumber(ctx, rpc.BlockNumber(checkpointNumber))
 
-	masternodes := engine.GetMasternodesFromCheckpointHeader(checkpointBlock.Header(), blockNumber, s.b.ChainConfig().Posv.Epoch)
+	masternodes := engine.GetMasternodesFromCheckpointHeader(checkpointBlock.Header(), blockNumber, s.b.ChainConfig().Posv.EpochLength(blockNumber))
 	signers, err = GetSignersFromBlocks(s.b, block.NumberU64(), block.Hash(), masternodes)
 	if err != nil {
 		log.Error("Fail to get signers from block signer SC.", "error", err)
@@ -1428,28 +1501,33 @@ func (s *PublicBlockChainAPI) rpcOutputBlockSigners(b *types.Block, ctx context.
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash        common.Hash     `json:"blockHash"`
-	BlockNumber      *hexutil.Big    `json:"blockNumber"`
-	From             common.Address  `json:"from"`
-	Gas              hexutil.Uint64  `json:"gas"`
-	GasPrice         *hexutil.Big    `json:"gasPrice"`
-	Hash             common.Hash     `json:"hash"`
-	Input            hexutil.Bytes   `json:"input"`
-	Nonce            hexutil.Uint64  `json:"nonce"`
-	To               *common.Address `json:"to"`
-	TransactionIndex hexutil.Uint    `json:"transactionIndex"`
-	Value            *hexutil.Big    `json:"value"`
-	V                *hexutil.Big    `json:"v"`
-	R                *hexutil.Big    `json:"r"`
-	S                *hexutil.Big    `json:"s"`
+	BlockHash        common.Hash       `json:"blockHash"`
+	BlockNumber      *hexutil.Big      `json:"blockNumber"`
+	From             common.Address    `json:"from"`
+	Gas              hexutil.Uint64    `json:"gas"`
+	GasPrice         *hexutil.Big      `json:"gasPrice"`
+	GasFeeCap        *hexutil.Big      `json:"maxFeePerGas,omitempty"`
+	GasTipCap        *hexutil.Big      `json:"maxPriorityFeePerGas,omitempty"`
+	Hash             common.Hash       `json:"hash"`
+	Input            hexutil.Bytes     `json:"input"`
+	Nonce            hexutil.Uint64    `json:"nonce"`
+	To               *common.Address   `json:"to"`
+	TransactionIndex hexutil.Uint      `json:"transactionIndex"`
+	Value            *hexutil.Big      `json:"value"`
+	Type             hexutil.Uint64    `json:"type"`
+	Accesses         *types.AccessList `json:"accessList,omitempty"`
+	ChainID          *hexutil.Big      `json:"chainId,omitempty"`
+	V                *hexutil.Big      `json:"v"`
+	R                *hexutil.Big      `json:"r"`
+	S                *hexutil.Big      `json:"s"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
 // representation, with the given location metadata set (if available).
-func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) *RPCTransaction {
+func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64, baseFee *big.Int) *RPCTransaction {
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
+		signer = types.LatestSignerForChainID(tx.ChainId())
 	}
 	from, _ := types.Sender(signer, tx)
 	v, r, s := tx.RawSignatureValues()
@@ -1463,6 +1541,7 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		Nonce:    hexutil.Uint64(tx.Nonce()),
 		To:       tx.To(),
 		Value:    (*hexutil.Big)(tx.Value()),
+		Type:     hexutil.Uint64(tx.Type()),
 		V:        (*hexutil.Big)(v),
 		R:        (*hexutil.Big)(r),
 		S:        (*hexutil.Big)(s),
@@ -1472,12 +1551,38 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		result.BlockNumber = (*hexutil.Big)(new(big.Int).SetUint64(blockNumber))
 		result.TransactionIndex = hexutil.Uint(index)
 	}
+	switch tx.Type() {
+	case types.AccessListTxType:
+		al := tx.AccessList()
+		result.Accesses = &al
+		result.ChainID = (*hexutil.Big)(tx.ChainId())
+	case types.DynamicFeeTxType:
+		al := tx.AccessList()
+		result.Accesses = &al
+		result.ChainID = (*hexutil.Big)(tx.ChainId())
+		result.GasFeeCap = (*hexutil.Big)(tx.GasFeeCap())
+		result.GasTipCap = (*hexutil.Big)(tx.GasTipCap())
+		// Once mined, report the price the transaction actually paid.
+		if baseFee != nil && blockHash != (common.Hash{}) {
+			result.GasPrice = (*hexutil.Big)(effectiveGasPrice(tx, baseFee))
+		}
+	}
 	return result
 }
 
+// effectiveGasPrice returns the gas price a transaction paid in a block with
+// the given base fee: min(tip + baseFee, feeCap).
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	price := new(big.Int).Add(tx.GasTipCap(), baseFee)
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		price.Set(tx.GasFeeCap())
+	}
+	return price
+}
+
 // newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
 func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
-	return newRPCTransaction(tx, common.Hash{}, 0, 0)
+	return newRPCTransaction(tx, common.Hash{}, 0, 0, nil)
 }
 
 // newRPCTransactionFromBlockIndex returns a transaction that will serialize to the RPC representation.
@@ -1486,7 +1591,7 @@ func newRPCTransactionFromBlockIndex(b *types.Block, index uint64) *RPCTransacti
 	if index >= uint64(len(txs)) {
 		return nil
 	}
-	return newRPCTransaction(txs[index], b.Hash(), b.NumberU64(), index)
+	return newRPCTransaction(txs[index], b.Hash(), b.NumberU64(), index, b.BaseFee())
 }
 
 // newRPCRawTransactionFromBlockIndex returns the bytes of a transaction given a block and a transaction index.
@@ -1583,10 +1688,13 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByBlockHashAndIndex(ctx cont
 
 // GetTransactionCount returns the number of transactions the given address has sent for the given block number
 func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*hexutil.Uint64, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	if err := verifyStateRead(s.b, blockNr, state, header, address); err != nil {
+		return nil, err
+	}
 	nonce := state.GetNonce(address)
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
@@ -1595,7 +1703,11 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		var baseFee *big.Int
+		if block, _ := s.b.GetBlock(ctx, blockHash); block != nil {
+			baseFee = block.BaseFee()
+		}
+		return newRPCTransaction(tx, blockHash, blockNumber, index, baseFee)
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
@@ -1605,6 +1717,54 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	return nil
 }
 
+// RPCPoolInfo represents the transaction pool's view of a pending transaction.
+type RPCPoolInfo struct {
+	Status              string         `json:"status"`
+	Arrival             hexutil.Uint64 `json:"arrival"`
+	Position            hexutil.Uint64 `json:"position"`
+	NonceGap            hexutil.Uint64 `json:"nonceGap"`
+	DelayedUntil        hexutil.Uint64 `json:"delayedUntil,omitempty"`
+	ReplacementGasPrice *hexutil.Big   `json:"replacementGasPrice"`
+}
+
+// RPCExtendedTransaction is a transaction extended with the pool metadata if it
+// is still waiting for inclusion.
+type RPCExtendedTransaction struct {
+	*RPCTransaction
+	PoolInfo *RPCPoolInfo `json:"poolInfo,omitempty"`
+}
+
+// GetTransactionByHashExtended returns the transaction for the given hash like
+// GetTransactionByHash does. For transactions still in the pool, it includes the
+// arrival time, the queued/pending classification, an estimate of the number of
+// pending transactions ahead, the checkpoint a transaction delayed by the gap
+// policies waits for and the minimum gas price needed to replace it.
+func (s *PublicTransactionPoolAPI) GetTransactionByHashExtended(ctx context.Context, hash common.Hash) *RPCExtendedTransaction {
+	tx := s.GetTransactionByHash(ctx, hash)
+	if tx == nil {
+		return nil
+	}
+	result := &RPCExtendedTransaction{RPCTransaction: tx}
+	if tx.BlockHash != (common.Hash{}) {
+		return result
+	}
+	if info := s.b.GetPoolTransactionInfo(hash); info != nil {
+		status := "queued"
+		if info.Status == core.TxStatusPending {
+			status = "pending"
+		}
+		result.PoolInfo = &RPCPoolInfo{
+			Status:              status,
+			Arrival:             hexutil.Uint64(info.Arrival.Unix()),
+			Position:            hexutil.Uint64(info.Position),
+			NonceGap:            hexutil.Uint64(info.NonceGap),
+			DelayedUntil:        hexutil.Uint64(info.DelayedUntil),
+			ReplacementGasPrice: (*hexutil.Big)(info.ReplacementPrice),
+		}
+	}
+	return result
+}
+
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
 func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	var tx *types.Transaction
@@ -1637,7 +1797,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
+		signer = types.LatestSignerForChainID(tx.ChainId())
 	}
 	from, _ := types.Sender(signer, tx)
 
@@ -1653,6 +1813,17 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"contractAddress":   nil,
 		"logs":              receipt.Logs,
 		"logsBloom":         receipt.Bloom,
+		"type":              hexutil.Uint(tx.Type()),
+	}
+	// Assign the effective gas price paid
+	if tx.Type() == types.DynamicFeeTxType {
+		if block, _ := s.b.GetBlock(ctx, blockHash); block != nil && block.BaseFee() != nil {
+			fields["effectiveGasPrice"] = (*hexutil.Big)(effectiveGasPrice(tx, block.BaseFee()))
+		} else {
+			fields["effectiveGasPrice"] = (*hexutil.Big)(tx.GasFeeCap())
+		}
+	} else {
+		fields["effectiveGasPrice"] = (*hexutil.Big)(tx.GasPrice())
 	}
 
 	// Assign receipt status or post state.
@@ -1690,16 +1861,22 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 
 // SendTxArgs represents the arguments to sumbit a new transaction into the transaction pool.
 type SendTxArgs struct {
-	From     common.Address  `json:"from"`
-	To       *common.Address `json:"to"`
-	Gas      *hexutil.Uint64 `json:"gas"`
-	GasPrice *hexutil.Big    `json:"gasPrice"`
-	Value    *hexutil.Big    `json:"value"`
-	Nonce    *hexutil.Uint64 `json:"nonce"`
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
 	// We accept "data" and "input" for backwards-compatibility reasons. "input" is the
 	// newer name and should be preferred by clients.
 	Data  *hexutil.Bytes `json:"data"`
 	Input *hexutil.Bytes `json:"input"`
+
+	// Introduced by AccessListTxType transaction.
+	AccessList *types.AccessList `json:"accessList,omitempty"`
+	ChainID    *hexutil.Big      `json:"chainId,omitempty"`
 }
 
 // setDefaults is a helper function that fills in default values for unspecified tx fields.
@@ -1708,13 +1885,39 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 		args.Gas = new(hexutil.Uint64)
 		*(*uint64)(args.Gas) = 90000
 	}
-	if args.GasPrice == nil {
+	if args.GasPrice != nil && (args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil) {
+		return errors.New("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	}
+	if args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil {
+		// Dynamic fee transaction: default the tip to the suggested price and
+		// leave room for the base fee to double before the cap is reached.
+		if args.MaxPriorityFeePerGas == nil {
+			tip, err := b.SuggestPrice(ctx)
+			if err != nil {
+				return err
+			}
+			args.MaxPriorityFeePerGas = (*hexutil.Big)(tip)
+		}
+		if args.MaxFeePerGas == nil {
+			feeCap := new(big.Int).Set(args.MaxPriorityFeePerGas.ToInt())
+			if head := b.CurrentBlock().Header(); head.BaseFee != nil {
+				feeCap.Add(feeCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+			}
+			args.MaxFeePerGas = (*hexutil.Big)(feeCap)
+		}
+		if args.MaxFeePerGas.ToInt().Cmp(args.MaxPriorityFeePerGas.ToInt()) < 0 {
+			return fmt.Errorf("maxFeePerGas (%v) < maxPriorityFeePerGas (%v)", args.MaxFeePerGas, args.MaxPriorityFeePerGas)
+		}
+	} else if args.GasPrice == nil {
 		price, err := b.SuggestPrice(ctx)
 		if err != nil {
 			return err
 		}
 		args.GasPrice = (*hexutil.Big)(price)
 	}
+	if args.ChainID == nil && (args.MaxFeePerGas != nil || args.AccessList != nil) {
+		args.ChainID = (*hexutil.Big)(b.ChainConfig().ChainId)
+	}
 	if args.Value == nil {
 		args.Value = new(hexutil.Big)
 	}
@@ -1728,6 +1931,9 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
 		return errors.New(`Both "data" and "input" are set and not equal. Please use "input" to pass transaction call data.`)
 	}
+	if args.Data != nil {
+		rpc.MarkDeprecatedField("eth_sendTransaction.data")
+	}
 	if args.To == nil {
 		// Contract creation
 		var input []byte
@@ -1750,6 +1956,35 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 	} else if args.Input != nil {
 		input = *args.Input
 	}
+	var al types.AccessList
+	if args.AccessList != nil {
+		al = *args.AccessList
+	}
+	switch {
+	case args.MaxFeePerGas != nil:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    (*big.Int)(args.ChainID),
+			Nonce:      uint64(*args.Nonce),
+			GasTipCap:  (*big.Int)(args.MaxPriorityFeePerGas),
+			GasFeeCap:  (*big.Int)(args.MaxFeePerGas),
+			Gas:        uint64(*args.Gas),
+			To:         args.To,
+			Value:      (*big.Int)(args.Value),
+			Data:       input,
+			AccessList: al,
+		})
+	case args.AccessList != nil:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    (*big.Int)(args.ChainID),
+			Nonce:      uint64(*args.Nonce),
+			GasPrice:   (*big.Int)(args.GasPrice),
+			Gas:        uint64(*args.Gas),
+			To:         args.To,
+			Value:      (*big.Int)(args.Value),
+			Data:       input,
+			AccessList: al,
+		})
+	}
 	if args.To == nil {
 		return types.NewContractCreation(uint64(*args.Nonce), (*big.Int)(args.Value), uint64(*args.Gas), (*big.Int)(args.GasPrice), input)
 	}
@@ -1981,6 +2216,33 @@ type OrderMsg struct {
 
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash" rlp:"-"`
+
+	// Orders cancelled by a batch cancellation
+	Cancels []OrderCancelMsg `json:"cancels,omitempty"`
+
+	// Price activating a stop order
+	TriggerPrice *hexutil.Big `json:"triggerPrice,omitempty"`
+
+	// Quantity shown in the order book by an iceberg order
+	DisplayQuantity *hexutil.Big `json:"displayQuantity,omitempty"`
+
+	// Time in force of a limit order: PO, IOC or FOK
+	TimeInForce string `json:"timeInForce,omitempty"`
+
+	// Unix time after which a good-till-date order expires
+	ExpiresAt hexutil.Uint64 `json:"expiresAt,omitempty"`
+
+	// Self-trade prevention mode: CN, CO or CB
+	SelfTradePrevention string `json:"selfTradePrevention,omitempty"`
+
+	// Order id of the resting order replaced by a limit order
+	ReplacedOrderID hexutil.Uint64 `json:"replacedOrderId,omitempty"`
+}
+
+// OrderCancelMsg api message for an order cancelled by a batch cancellation
+type OrderCancelMsg struct {
+	OrderID hexutil.Uint64 `json:"orderid"`
+	Hash    common.Hash    `json:"hash"`
 }
 
 // LendingMsg api message for lending
@@ -2008,6 +2270,9 @@ type LendingMsg struct {
 
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash" rlp:"-"`
+
+	// Highest interest rate at which a borrowing is rolled over at maturity
+	AutoRenewRate hexutil.Uint64 `json:"autoRenewRate,omitempty"`
 }
 
 type PriceVolume struct {
@@ -2020,10 +2285,40 @@ type InterestVolume struct {
 	Volume   *big.Int `json:"volume,omitempty"`
 }
 
+// RelayerInfo is the registration of a relayer in the relayer and lending
+// registration contracts.
+type RelayerInfo struct {
+	*tradingstate.RelayerInfo
+	Lending *lendingstate.LendingRelayerInfo `json:"lending,omitempty"`
+}
+
 // SendOrder will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTomoXTransactionPoolAPI) SendOrder(ctx context.Context, msg OrderMsg) (common.Hash, error) {
 	tx := types.NewOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.Price.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.OrderID))
+	if len(msg.Cancels) > 0 {
+		cancels := make([]types.OrderCancel, len(msg.Cancels))
+		for i, cancel := range msg.Cancels {
+			cancels[i] = types.OrderCancel{OrderID: uint64(cancel.OrderID), Hash: cancel.Hash}
+		}
+		tx = types.NewBatchCancelOrderTransaction(uint64(msg.AccountNonce), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, cancels)
+	} else if msg.TriggerPrice != nil {
+		tx = types.NewStopOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.Price.ToInt(), msg.TriggerPrice.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Side, msg.Type, msg.Hash)
+	} else if msg.DisplayQuantity != nil {
+		tx = types.NewIcebergOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.DisplayQuantity.ToInt(), msg.Price.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Side, msg.Hash)
+	}
+	if msg.TimeInForce != "" {
+		tx.SetTimeInForce(msg.TimeInForce)
+	}
+	if msg.ExpiresAt != 0 {
+		tx.SetExpiresAt(uint64(msg.ExpiresAt))
+	}
+	if msg.SelfTradePrevention != "" {
+		tx.SetSelfTradePrevention(msg.SelfTradePrevention)
+	}
+	if msg.ReplacedOrderID != 0 {
+		tx.SetReplacedOrderID(uint64(msg.ReplacedOrderID))
+	}
 	tx = tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt())
 	return submitOrderTransaction(ctx, s.b, tx)
 }
@@ -2032,6 +2327,9 @@ func (s *PublicTomoXTransactionPoolAPI) SendOrder(ctx context.Context, msg Order
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTomoXTransactionPoolAPI) SendLending(ctx context.Context, msg LendingMsg) (common.Hash, error) {
 	tx := types.NewLendingTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), uint64(msg.Interest), uint64(msg.Term), msg.RelayerAddress, msg.UserAddress, msg.LendingToken, msg.CollateralToken, msg.AutoTopUp, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.LendingId), uint64(msg.LendingTradeId), msg.ExtraData)
+	if msg.AutoRenewRate != 0 {
+		tx.SetAutoRenewRate(uint64(msg.AutoRenewRate))
+	}
 	tx = tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt())
 	return submitLendingTransaction(ctx, s.b, tx)
 }
@@ -2046,22 +2344,41 @@ func (s *PublicTomoXTransactionPoolAPI) GetOrderCount(ctx context.Context, addr
 	return (*hexutil.Uint64)(&nonce), err
 }
 
-func (s *PublicTomoXTransactionPoolAPI) GetBestBid(ctx context.Context, baseToken, quoteToken common.Address) (PriceVolume, error) {
-
-	result := PriceVolume{}
+// tradingStateAt opens the trading state of the given block, the current block
+// if none is given. The state of older blocks is only available as long as its
+// tries haven't been garbage collected.
+func (s *PublicTomoXTransactionPoolAPI) tradingStateAt(ctx context.Context, blockNr *rpc.BlockNumber) (*tradingstate.TradingStateDB, error) {
 	block := s.b.CurrentBlock()
+	if blockNr != nil {
+		var err error
+		if block, err = s.b.BlockByNumber(ctx, *blockNr); err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", *blockNr)
+		}
+	}
 	if block == nil {
-		return result, errors.New("Current block not found")
+		return nil, errors.New("Current block not found")
 	}
 	tomoxService := s.b.TomoxService()
 	if tomoxService == nil {
-		return result, errors.New("TomoX service not found")
+		return nil, errors.New("TomoX service not found")
 	}
 	author, err := s.b.GetEngine().Author(block.Header())
 	if err != nil {
-		return result, err
+		return nil, err
 	}
 	tomoxState, err := tomoxService.GetTradingState(block, author)
+	if err != nil {
+		return nil, fmt.Errorf("trading state of block #%d not available: %v", block.NumberU64(), err)
+	}
+	return tomoxState, nil
+}
+
+func (s *PublicTomoXTransactionPoolAPI) GetBestBid(ctx context.Context, baseToken, quoteToken common.Address, blockNr *rpc.BlockNumber) (PriceVolume, error) {
+	result := PriceVolume{}
+	tomoxState, err := s.tradingStateAt(ctx, blockNr)
 	if err != nil {
 		return result, err
 	}
@@ -2072,21 +2389,9 @@ func (s *PublicTomoXTransactionPoolAPI) GetBestBid(ctx context.Context, baseToke
 	return result, nil
 }
 
-func (s *PublicTomoXTransactionPoolAPI) GetBestAsk(ctx context.Context, baseToken, quoteToken common.Address) (PriceVolume, error) {
+func (s *PublicTomoXTransactionPoolAPI) GetBestAsk(ctx context.Context, baseToken, quoteToken common.Address, blockNr *rpc.BlockNumber) (PriceVolume, error) {
 	result := PriceVolume{}
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return result, errors.New("Current block not found")
-	}
-	tomoxService := s.b.TomoxService()
-	if tomoxService == nil {
-		return result, errors.New("TomoX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return result, err
-	}
-	tomoxState, err := tomoxService.GetTradingState(block, author)
+	tomoxState, err := s.tradingStateAt(ctx, blockNr)
 	if err != nil {
 		return result, err
 	}
@@ -2097,20 +2402,8 @@ func (s *PublicTomoXTransactionPoolAPI) GetBestAsk(ctx context.Context, baseToke
 	return result, nil
 }
 
-func (s *PublicTomoXTransactionPoolAPI) GetBidTree(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]tradingstate.DumpOrderList, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	tomoxService := s.b.TomoxService()
-	if tomoxService == nil {
-		return nil, errors.New("TomoX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	tomoxState, err := tomoxService.GetTradingState(block, author)
+func (s *PublicTomoXTransactionPoolAPI) GetBidTree(ctx context.Context, baseToken, quoteToken common.Address, blockNr *rpc.BlockNumber) (map[*big.Int]tradingstate.DumpOrderList, error) {
+	tomoxState, err := s.tradingStateAt(ctx, blockNr)
 	if err != nil {
 		return nil, err
 	}
@@ -2193,20 +2486,8 @@ func (s *PublicTomoXTransactionPoolAPI) GetCurrentEpochPrice(ctx context.Context
 	return price, nil
 }
 
-func (s *PublicTomoXTransactionPoolAPI) GetAskTree(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]tradingstate.DumpOrderList, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	tomoxService := s.b.TomoxService()
-	if tomoxService == nil {
-		return nil, errors.New("TomoX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	tomoxState, err := tomoxService.GetTradingState(block, author)
+func (s *PublicTomoXTransactionPoolAPI) GetAskTree(ctx context.Context, baseToken, quoteToken common.Address, blockNr *rpc.BlockNumber) (map[*big.Int]tradingstate.DumpOrderList, error) {
+	tomoxState, err := s.tradingStateAt(ctx, blockNr)
 	if err != nil {
 		return nil, err
 	}
@@ -2242,20 +2523,8 @@ func (s *PublicTomoXTransactionPoolAPI) GetOrderById(ctx context.Context, baseTo
 	return orderitem, nil
 }
 
-func (s *PublicTomoXTransactionPoolAPI) GetTradingOrderBookInfo(ctx context.Context, baseToken, quoteToken common.Address) (*tradingstate.DumpOrderBookInfo, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	tomoxService := s.b.TomoxService()
-	if tomoxService == nil {
-		return nil, errors.New("TomoX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	tomoxState, err := tomoxService.GetTradingState(block, author)
+func (s *PublicTomoXTransactionPoolAPI) GetTradingOrderBookInfo(ctx context.Context, baseToken, quoteToken common.Address, blockNr *rpc.BlockNumber) (*tradingstate.DumpOrderBookInfo, error) {
+	tomoxState, err := s.tradingStateAt(ctx, blockNr)
 	if err != nil {
 		return nil, err
 	}
@@ -2499,20 +2768,8 @@ func (s *PublicTomoXTransactionPoolAPI) GetBestBorrowing(ctx context.Context, le
 	return result, nil
 }
 
-func (s *PublicTomoXTransactionPoolAPI) GetBids(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	tomoxService := s.b.TomoxService()
-	if tomoxService == nil {
-		return nil, errors.New("TomoX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	tomoxState, err := tomoxService.GetTradingState(block, author)
+func (s *PublicTomoXTransactionPoolAPI) GetBids(ctx context.Context, baseToken, quoteToken common.Address, blockNr *rpc.BlockNumber) (map[*big.Int]*big.Int, error) {
+	tomoxState, err := s.tradingStateAt(ctx, blockNr)
 	if err != nil {
 		return nil, err
 	}
@@ -2523,20 +2780,8 @@ func (s *PublicTomoXTransactionPoolAPI) GetBids(ctx context.Context, baseToken,
 	return result, nil
 }
 
-func (s *PublicTomoXTransactionPoolAPI) GetAsks(ctx context.Context, baseToken, quoteToken common.Address) (map[*big.Int]*big.Int, error) {
-	block := s.b.CurrentBlock()
-	if block == nil {
-		return nil, errors.New("Current block not found")
-	}
-	tomoxService := s.b.TomoxService()
-	if tomoxService == nil {
-		return nil, errors.New("TomoX service not found")
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
-	if err != nil {
-		return nil, err
-	}
-	tomoxState, err := tomoxService.GetTradingState(block, author)
+func (s *PublicTomoXTransactionPoolAPI) GetAsks(ctx context.Context, baseToken, quoteToken common.Address, blockNr *rpc.BlockNumber) (map[*big.Int]*big.Int, error) {
+	tomoxState, err := s.tradingStateAt(ctx, blockNr)
 	if err != nil {
 		return nil, err
 	}
@@ -2682,6 +2927,62 @@ func (s *PublicTomoXTransactionPoolAPI) GetLendingTradeById(ctx context.Context,
 	return lendingItem, nil
 }
 
+// GetRelayerInfo returns the registration of the relayer with the given coinbase
+// at the given block, the current block if none is given. It's nil if the
+// address isn't the coinbase of a relayer.
+func (s *PublicTomoXTransactionPoolAPI) GetRelayerInfo(ctx context.Context, coinbase common.Address, blockNr *rpc.BlockNumber) (*RelayerInfo, error) {
+	number := rpc.LatestBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	statedb, _, err := s.b.StateAndHeaderByNumber(ctx, number)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	info, err := tradingstate.GetRelayerInfo(coinbase, statedb)
+	if info == nil || err != nil {
+		return nil, err
+	}
+	return &RelayerInfo{RelayerInfo: info, Lending: lendingstate.GetLendingRelayerInfo(statedb, coinbase)}, statedb.Error()
+}
+
+// GetEpochSnapshot returns the content of the TomoX order and lending books at
+// the checkpoint block of the given epoch, from which relayers can rebuild their
+// off-chain database.
+func (s *PublicTomoXTransactionPoolAPI) GetEpochSnapshot(ctx context.Context, epoch uint64) (*core.TomoXSnapshot, error) {
+	posv := s.b.ChainConfig().Posv
+	if posv == nil || posv.Epoch == 0 {
+		return nil, errors.New("chain has no epoch")
+	}
+	number := rpc.BlockNumber(epoch * posv.Epoch)
+	block, err := s.b.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("checkpoint block #%d of epoch %d not found", number, epoch)
+	}
+	tomoxService := s.b.TomoxService()
+	if tomoxService == nil {
+		return nil, errors.New("TomoX service not found")
+	}
+	author, err := s.b.GetEngine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := tomoxService.GetTradingState(block, author)
+	if err != nil {
+		return nil, fmt.Errorf("trading state of block #%d not available: %v", block.NumberU64(), err)
+	}
+	var lendingState *lendingstate.LendingStateDB
+	if lendingService := s.b.LendingService(); lendingService != nil && s.b.ChainConfig().IsTIPTomoXLending(block.Number()) {
+		if lendingState, err = lendingService.GetLendingState(block, author); err != nil {
+			return nil, fmt.Errorf("lending state of block #%d not available: %v", block.NumberU64(), err)
+		}
+	}
+	return core.NewTomoXSnapshot(block, epoch, tradingState, lendingState)
+}
+
 // Sign calculates an ECDSA signature for:
 // keccack256("\x19Ethereum Signed Message:\n" + len(message) + message).
 //
@@ -2720,8 +3021,8 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if args.Gas == nil {
 		return nil, fmt.Errorf("gas not specified")
 	}
-	if args.GasPrice == nil {
-		return nil, fmt.Errorf("gasPrice not specified")
+	if args.GasPrice == nil && (args.MaxFeePerGas == nil || args.MaxPriorityFeePerGas == nil) {
+		return nil, fmt.Errorf("missing gasPrice or maxFeePerGas/maxPriorityFeePerGas")
 	}
 	if args.Nonce == nil {
 		return nil, fmt.Errorf("nonce not specified")
@@ -2752,7 +3053,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 	for _, tx := range pending {
 		var signer types.Signer = types.HomesteadSigner{}
 		if tx.Protected() {
-			signer = types.NewEIP155Signer(tx.ChainId())
+			signer = types.LatestSignerForChainID(tx.ChainId())
 		}
 		from, _ := types.Sender(signer, tx)
 		if _, err := s.b.AccountManager().Find(accounts.Account{Address: from}); err == nil {
@@ -2780,7 +3081,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 	for _, p := range pending {
 		var signer types.Signer = types.HomesteadSigner{}
 		if p.Protected() {
-			signer = types.NewEIP155Signer(p.ChainId())
+			signer = types.LatestSignerForChainID(p.ChainId())
 		}
 		wantSigHash := signer.Hash(matchTx)
 
@@ -2965,10 +3266,11 @@ func GetSignersFromBlocks(b Backend, blockNumber uint64, blockHash common.Hash,
 // GetStakerROI Estimate ROI for stakers using the last epoc reward
 // then multiple by epoch per year, if the address is not masternode of last epoch - return 0
 // Formular:
-// 		ROI = average_latest_epoch_reward_for_voters*number_of_epoch_per_year/latest_total_cap*100
+//
+//	ROI = average_latest_epoch_reward_for_voters*number_of_epoch_per_year/latest_total_cap*100
 func (s *PublicBlockChainAPI) GetStakerROI() float64 {
 	blockNumber := s.b.CurrentBlock().Number().Uint64()
-	lastCheckpointNumber := blockNumber - (blockNumber % s.b.ChainConfig().Posv.Epoch) - s.b.ChainConfig().Posv.Epoch // calculate for 2 epochs ago
+	lastCheckpointNumber, _ := s.b.ChainConfig().Posv.PreviousCheckpoint(blockNumber, 1) // calculate for 2 epochs ago
 	totalCap := new(big.Int).SetUint64(0)
 
 	mastersCap := s.b.GetMasternodesCap(lastCheckpointNumber)
@@ -2991,7 +3293,8 @@ func (s *PublicBlockChainAPI) GetStakerROI() float64 {
 // GetStakerROIMasternode Estimate ROI for stakers of a specific masternode using the last epoc reward
 // then multiple by epoch per year, if the address is not masternode of last epoch - return 0
 // Formular:
-// 		ROI = latest_epoch_reward_for_voters*number_of_epoch_per_year/latest_total_cap*100
+//
+//	ROI = latest_epoch_reward_for_voters*number_of_epoch_per_year/latest_total_cap*100
 func (s *PublicBlockChainAPI) GetStakerROIMasternode(masternode common.Address) float64 {
 	votersReward := s.b.GetVotersRewards(masternode)
 	if votersReward == nil {
@@ -3006,7 +3309,7 @@ func (s *PublicBlockChainAPI) GetStakerROIMasternode(masternode common.Address)
 	}
 
 	blockNumber := s.b.CurrentBlock().Number().Uint64()
-	lastCheckpointNumber := blockNumber - blockNumber%s.b.ChainConfig().Posv.Epoch
+	lastCheckpointNumber := s.b.ChainConfig().Posv.Checkpoint(blockNumber)
 	totalCap := new(big.Int).SetUint64(0)
 	votersCap := s.b.GetVotersCap(new(big.Int).SetUint64(lastCheckpointNumber), masternode, voters)
 
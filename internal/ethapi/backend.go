@@ -58,6 +58,8 @@ type Backend interface {
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+	StateVerifyRatio() float64 // Fraction of RPC state reads verified against Merkle proofs
+	CallCacheSize() int        // Number of eth_call results cached by block hash, zero if disabled
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetTd(blockHash common.Hash) *big.Int
@@ -70,6 +72,8 @@ type Backend interface {
 	SendTx(ctx context.Context, signedTx *types.Transaction) error
 	GetPoolTransactions() (types.Transactions, error)
 	GetPoolTransaction(txHash common.Hash) *types.Transaction
+	GetPoolTransactionInfo(txHash common.Hash) *core.TxPoolInfo
+	GetPoolGapStatus() *core.TxGapStatus
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
@@ -98,6 +102,22 @@ type Backend interface {
 
 func GetAPIs(apiBackend Backend) []rpc.API {
 	nonceLock := new(AddrLocker)
+
+	// Uncles don't exist under POSV, these only remain for client compatibility
+	rpc.RegisterDeprecatedMethods(
+		"eth_getUncleByBlockNumberAndIndex",
+		"eth_getUncleByBlockHashAndIndex",
+		"eth_getUncleCountByBlockNumber",
+		"eth_getUncleCountByBlockHash",
+		"personal_signAndSendTransaction",
+	)
+	// Legacy TomoX book dumps, superseded by the bid/ask, investing and borrowing trees
+	rpc.RegisterDeprecatedMethods(
+		"tomox_getBids",
+		"tomox_getAsks",
+		"tomox_getInvests",
+		"tomox_getBorrows",
+	)
 	return []rpc.API{
 		{
 			Namespace: "eth",
@@ -119,6 +139,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicTomoXTransactionPoolAPI(apiBackend, nonceLock),
 			Public:    true,
+		}, {
+			Namespace: "tomoxlending",
+			Version:   "1.0",
+			Service:   NewPublicTomoXLendingAPI(apiBackend),
+			Public:    true,
 		}, {
 			Namespace: "txpool",
 			Version:   "1.0",
@@ -0,0 +1,101 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/rpc"
+)
+
+// callCache is a bounded cache of eth_call results, keyed by the hash of the
+// block the call was executed on and the call arguments. Calls on the latest
+// block resolve to the hash of the current head, so cached results of an old
+// head are never served once the chain moves on.
+type callCache struct {
+	results *lru.Cache
+}
+
+// newCallCache creates an eth_call result cache of the given size, or returns
+// nil if the size disables caching.
+func newCallCache(size int) *callCache {
+	if size <= 0 {
+		return nil
+	}
+	results, _ := lru.New(size)
+	return &callCache{results: results}
+}
+
+// callCacheKey returns the cache key of a call executed on the given block.
+func callCacheKey(blockHash common.Hash, args CallArgs) (common.Hash, error) {
+	enc, err := json.Marshal(args)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(blockHash[:], enc), nil
+}
+
+func (c *callCache) get(key common.Hash) (hexutil.Bytes, bool) {
+	if result, ok := c.results.Get(key); ok {
+		return result.(hexutil.Bytes), true
+	}
+	return nil, false
+}
+
+func (c *callCache) add(key common.Hash, result hexutil.Bytes) {
+	c.results.Add(key, result)
+}
+
+// cachedCall executes a call on the given block, serving repeated calls from
+// the call cache. Pending calls are not cached, the pending state changes
+// without its hash doing so.
+func (s *PublicBlockChainAPI) cachedCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, timeout time.Duration) (hexutil.Bytes, error) {
+	if s.callCache == nil || blockNr == rpc.PendingBlockNumber {
+		result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, timeout)
+		return result, err
+	}
+	header, err := s.b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, timeout)
+		return result, err
+	}
+	key, err := callCacheKey(header.Hash(), args)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := s.callCache.get(key); ok {
+		return result, nil
+	}
+	// Pin the call to the resolved block, and only cache its result if that
+	// block is still canonical afterwards
+	number := rpc.BlockNumber(header.Number.Int64())
+	result, _, _, err := s.doCall(ctx, args, number, vm.Config{}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if canon, _ := s.b.HeaderByNumber(ctx, number); canon != nil && canon.Hash() == header.Hash() {
+		s.callCache.add(key, result)
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/common/math"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// callBackend is a Backend executing calls on a single block whose state holds
+// a contract that never returns.
+type callBackend struct {
+	Backend
+	header *types.Header
+	loop   common.Address
+}
+
+func newCallBackend() *callBackend {
+	return &callBackend{
+		header: &types.Header{Number: big.NewInt(1), Time: big.NewInt(0), GasLimit: params.GenesisGasLimit, Difficulty: big.NewInt(1)},
+		loop:   common.HexToAddress("0x0000000000000000000000000000000000000100"),
+	}
+}
+
+func (b *callBackend) CallCacheSize() int { return 16 }
+
+func (b *callBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	return b.header, nil
+}
+
+func (b *callBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	return types.NewBlockWithHeader(b.header), nil
+}
+
+func (b *callBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.SetCode(b.loop, []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0x00, byte(vm.JUMP)})
+	return statedb, b.header, nil
+}
+
+func (b *callBackend) GetEngine() consensus.Engine { return ethash.NewFaker() }
+
+func (b *callBackend) TomoxService() *tomox.TomoX {
+	return &tomox.TomoX{StateCache: tradingstate.NewDatabase(rawdb.NewMemoryDatabase())}
+}
+
+func (b *callBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, tomoxState *tradingstate.TradingStateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, nil, &common.Address{})
+	return vm.NewEVM(context, state, tomoxState, params.TestChainConfig, vmCfg), func() error { return nil }, nil
+}
+
+// Tests that calls aborted by their timeout or by the caller fail instead of
+// returning, and caching, the result of the partial execution.
+func TestCallCacheAborted(t *testing.T) {
+	b := newCallBackend()
+	api := NewPublicBlockChainAPI(b)
+	args := CallArgs{From: common.HexToAddress("0x0000000000000000000000000000000000000001"), To: &b.loop, GasPrice: hexutil.Big(*common.TRC21GasPrice)}
+
+	if _, err := api.cachedCall(context.Background(), args, rpc.LatestBlockNumber, 50*time.Millisecond); err == nil {
+		t.Fatalf("call past its timeout succeeded")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := api.cachedCall(ctx, args, rpc.LatestBlockNumber, 0); err == nil {
+		t.Fatalf("call aborted by the caller succeeded")
+	}
+	if n := api.callCache.results.Len(); n != 0 {
+		t.Fatalf("aborted calls cached: have %d results, want 0", n)
+	}
+}
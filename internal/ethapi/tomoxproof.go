@@ -0,0 +1,280 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// proofList collects the trie nodes of a Merkle proof, from the root down to
+// the proven key.
+type proofList []hexutil.Bytes
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, common.CopyBytes(value))
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	return errors.New("proof list doesn't support deletion")
+}
+
+// OrderProofResult is the Merkle proof of an order, from the trading state root
+// committed by the block author down to the order. The order book proof leads to
+// the order book, whose order root the order proof starts from.
+type OrderProofResult struct {
+	BlockHash      common.Hash             `json:"blockHash"`
+	BlockNumber    hexutil.Uint64          `json:"blockNumber"`
+	TradingRoot    common.Hash             `json:"tradingRoot"`
+	OrderBook      common.Hash             `json:"orderBook"`
+	OrderBookProof proofList               `json:"orderBookProof"`
+	OrderId        common.Hash             `json:"orderId"`
+	OrderProof     proofList               `json:"orderProof"`
+	Order          *tradingstate.OrderItem `json:"order"`
+}
+
+// GetOrderProof returns the Merkle proof of the order with the given id in the
+// trading state of a block. The order is nil if the order book doesn't hold it,
+// the proof then proves its absence.
+func (s *PublicTomoXTransactionPoolAPI) GetOrderProof(ctx context.Context, baseToken, quoteToken common.Address, orderId uint64, blockNr rpc.BlockNumber) (*OrderProofResult, error) {
+	tomoxService := s.b.TomoxService()
+	if tomoxService == nil {
+		return nil, errors.New("TomoX service not found")
+	}
+	block, author, err := tomoXProofBlock(ctx, s.b, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	root, err := tomoxService.GetTradingStateRoot(block, author)
+	if err != nil {
+		return nil, err
+	}
+	result := &OrderProofResult{
+		BlockHash:   block.Hash(),
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		TradingRoot: root,
+		OrderBook:   tradingstate.GetTradingOrderBookHash(baseToken, quoteToken),
+		OrderId:     common.BigToHash(new(big.Int).SetUint64(orderId)),
+	}
+	order, err := tradingstate.ProveOrder(tomoxService.GetStateCache(), root, result.OrderBook, result.OrderId, &result.OrderBookProof, &result.OrderProof)
+	if err != nil {
+		return nil, err
+	}
+	if order.OrderID == orderId {
+		result.Order = &order
+	}
+	return result, nil
+}
+
+// maxProofLevels is the maximum number of price or interest levels proven at once.
+const maxProofLevels = 100
+
+// PriceLevelsProofResult is the Merkle proof of the best price levels of a side
+// of an order book, from the trading state root committed by the block author.
+// The order book proof leads to the order book, from whose root of the side the
+// edge proofs prove the range of the levels.
+type PriceLevelsProofResult struct {
+	BlockHash      common.Hash               `json:"blockHash"`
+	BlockNumber    hexutil.Uint64            `json:"blockNumber"`
+	TradingRoot    common.Hash               `json:"tradingRoot"`
+	OrderBook      common.Hash               `json:"orderBook"`
+	OrderBookProof proofList                 `json:"orderBookProof"`
+	Side           string                    `json:"side"`
+	Levels         []tradingstate.PriceLevel `json:"levels"`
+	FirstProof     proofList                 `json:"firstProof"`
+	LastProof      proofList                 `json:"lastProof"`
+}
+
+// GetPriceLevelsProof returns the best price levels of a side of an order book
+// in the trading state of a block, up to depth of them, with the Merkle proof
+// that they are the best ones.
+func (s *PublicTomoXTransactionPoolAPI) GetPriceLevelsProof(ctx context.Context, baseToken, quoteToken common.Address, side string, depth uint64, blockNr rpc.BlockNumber) (*PriceLevelsProofResult, error) {
+	tomoxService := s.b.TomoxService()
+	if tomoxService == nil {
+		return nil, errors.New("TomoX service not found")
+	}
+	if depth == 0 || depth > maxProofLevels {
+		return nil, fmt.Errorf("depth must be between 1 and %d", maxProofLevels)
+	}
+	block, author, err := tomoXProofBlock(ctx, s.b, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	root, err := tomoxService.GetTradingStateRoot(block, author)
+	if err != nil {
+		return nil, err
+	}
+	result := &PriceLevelsProofResult{
+		BlockHash:   block.Hash(),
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		TradingRoot: root,
+		OrderBook:   tradingstate.GetTradingOrderBookHash(baseToken, quoteToken),
+		Side:        side,
+	}
+	result.Levels, err = tradingstate.ProvePriceLevels(tomoxService.GetStateCache(), root, result.OrderBook, side, int(depth), &result.OrderBookProof, &result.FirstProof, &result.LastProof)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PublicTomoXLendingAPI provides the TomoX lending state of the chain.
+type PublicTomoXLendingAPI struct {
+	b Backend
+}
+
+// NewPublicTomoXLendingAPI creates a new RPC service reading the TomoX lending
+// state.
+func NewPublicTomoXLendingAPI(b Backend) *PublicTomoXLendingAPI {
+	return &PublicTomoXLendingAPI{b}
+}
+
+// LendingItemProofResult is the Merkle proof of a lending item, from the lending
+// state root committed by the block author down to the item.
+type LendingItemProofResult struct {
+	BlockHash        common.Hash               `json:"blockHash"`
+	BlockNumber      hexutil.Uint64            `json:"blockNumber"`
+	LendingRoot      common.Hash               `json:"lendingRoot"`
+	LendingBook      common.Hash               `json:"lendingBook"`
+	LendingBookProof proofList                 `json:"lendingBookProof"`
+	LendingId        common.Hash               `json:"lendingId"`
+	LendingItemProof proofList                 `json:"lendingItemProof"`
+	LendingItem      *lendingstate.LendingItem `json:"lendingItem"`
+}
+
+// GetLendingItemProof returns the Merkle proof of the lending item with the given
+// id in the lending state of a block. The item is nil if the lending book
+// doesn't hold it, the proof then proves its absence.
+func (s *PublicTomoXLendingAPI) GetLendingItemProof(ctx context.Context, lendingToken common.Address, term uint64, lendingId uint64, blockNr rpc.BlockNumber) (*LendingItemProofResult, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	block, author, err := tomoXProofBlock(ctx, s.b, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	root, err := lendingService.GetLendingStateRoot(block, author)
+	if err != nil {
+		return nil, err
+	}
+	result := &LendingItemProofResult{
+		BlockHash:   block.Hash(),
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		LendingRoot: root,
+		LendingBook: lendingstate.GetLendingOrderBookHash(lendingToken, term),
+		LendingId:   common.BigToHash(new(big.Int).SetUint64(lendingId)),
+	}
+	item, err := lendingstate.ProveLendingItem(lendingService.GetStateCache(), root, result.LendingBook, result.LendingId, &result.LendingBookProof, &result.LendingItemProof)
+	if err != nil {
+		return nil, err
+	}
+	if item.LendingId == lendingId {
+		result.LendingItem = &item
+	}
+	return result, nil
+}
+
+// InterestLevelsProofResult is the Merkle proof of the best interest levels of
+// a side of a lending book, from the lending state root committed by the block
+// author. The lending book proof leads to the lending book, from whose root of
+// the side the edge proofs prove the range of the levels.
+type InterestLevelsProofResult struct {
+	BlockHash        common.Hash                  `json:"blockHash"`
+	BlockNumber      hexutil.Uint64               `json:"blockNumber"`
+	LendingRoot      common.Hash                  `json:"lendingRoot"`
+	LendingBook      common.Hash                  `json:"lendingBook"`
+	LendingBookProof proofList                    `json:"lendingBookProof"`
+	Side             string                       `json:"side"`
+	Levels           []lendingstate.InterestLevel `json:"levels"`
+	FirstProof       proofList                    `json:"firstProof"`
+	LastProof        proofList                    `json:"lastProof"`
+}
+
+// GetInterestLevelsProof returns the best interest levels of a side of a
+// lending book in the lending state of a block, up to depth of them, with the
+// Merkle proof that they are the best ones.
+func (s *PublicTomoXLendingAPI) GetInterestLevelsProof(ctx context.Context, lendingToken common.Address, term uint64, side string, depth uint64, blockNr rpc.BlockNumber) (*InterestLevelsProofResult, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	if depth == 0 || depth > maxProofLevels {
+		return nil, fmt.Errorf("depth must be between 1 and %d", maxProofLevels)
+	}
+	block, author, err := tomoXProofBlock(ctx, s.b, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	root, err := lendingService.GetLendingStateRoot(block, author)
+	if err != nil {
+		return nil, err
+	}
+	result := &InterestLevelsProofResult{
+		BlockHash:   block.Hash(),
+		BlockNumber: hexutil.Uint64(block.NumberU64()),
+		LendingRoot: root,
+		LendingBook: lendingstate.GetLendingOrderBookHash(lendingToken, term),
+		Side:        side,
+	}
+	result.Levels, err = lendingstate.ProveInterestLevels(lendingService.GetStateCache(), root, result.LendingBook, side, int(depth), &result.LendingBookProof, &result.FirstProof, &result.LastProof)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetRiskParameters returns the risk parameters in effect at a block for the
+// loans backed by a collateral.
+func (s *PublicTomoXLendingAPI) GetRiskParameters(ctx context.Context, collateralToken common.Address, blockNr rpc.BlockNumber) (*lendingstate.RiskParameters, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	return lendingService.GetRiskParameters(s.b.ChainConfig(), header, statedb, collateralToken), nil
+}
+
+// tomoXProofBlock retrieves the block to prove the TomoX state of and its author,
+// whose transaction commits the state roots.
+func tomoXProofBlock(ctx context.Context, b Backend, blockNr rpc.BlockNumber) (*types.Block, common.Address, error) {
+	block, err := b.BlockByNumber(ctx, blockNr)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	if block == nil {
+		return nil, common.Address{}, fmt.Errorf("block #%d not found", blockNr)
+	}
+	author, err := b.GetEngine().Author(block.Header())
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return block, author, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/rpc"
+)
+
+var (
+	stateVerifyCounter     = metrics.NewRegisteredCounter("rpc/state/verify/total", nil)
+	stateVerifyFailCounter = metrics.NewRegisteredCounter("rpc/state/verify/failed", nil)
+)
+
+// errStateVerification is returned instead of a state read which doesn't match
+// the Merkle proof of its state root, as the database is likely corrupted.
+var errStateVerification = errors.New("state read failed verification against the state root")
+
+// verifyStateRead checks a sampled fraction of the RPC state reads against Merkle
+// proofs of the header's state root. The account of addr and the given storage
+// slots are verified. Pending state isn't committed, so it's never verified.
+func verifyStateRead(b Backend, blockNr rpc.BlockNumber, statedb *state.StateDB, header *types.Header, addr common.Address, slots ...common.Hash) error {
+	ratio := b.StateVerifyRatio()
+	if ratio <= 0 || blockNr == rpc.PendingBlockNumber || rand.Float64() >= ratio {
+		return nil
+	}
+	stateVerifyCounter.Inc(1)
+	if err := verifyAccount(statedb, header.Root, addr, slots); err != nil {
+		stateVerifyFailCounter.Inc(1)
+		log.Error("RPC state read failed verification", "number", header.Number, "root", header.Root, "address", addr, "err", err)
+		return errStateVerification
+	}
+	return nil
+}
+
+// verifyAccount compares the account of addr and its storage slots as read from
+// statedb with the values proven against root.
+func verifyAccount(statedb *state.StateDB, root common.Hash, addr common.Address, slots []common.Hash) error {
+	account, err := state.ProveAccount(statedb.Database(), root, addr)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		if statedb.Exist(addr) {
+			return fmt.Errorf("account %x missing from proof", addr)
+		}
+		return nil
+	}
+	if account.Nonce != statedb.GetNonce(addr) {
+		return fmt.Errorf("nonce mismatch: have %d, proven %d", statedb.GetNonce(addr), account.Nonce)
+	}
+	if account.Balance.Cmp(statedb.GetBalance(addr)) != 0 {
+		return fmt.Errorf("balance mismatch: have %v, proven %v", statedb.GetBalance(addr), account.Balance)
+	}
+	codeHash := common.BytesToHash(account.CodeHash)
+	if have := statedb.GetCodeHash(addr); have != codeHash {
+		return fmt.Errorf("code hash mismatch: have %x, proven %x", have, codeHash)
+	}
+	if have := crypto.Keccak256Hash(statedb.GetCode(addr)); have != codeHash {
+		return fmt.Errorf("code mismatch: have hash %x, proven %x", have, codeHash)
+	}
+	for _, slot := range slots {
+		value, err := state.ProveStorage(statedb.Database(), addr, account.Root, slot)
+		if err != nil {
+			return err
+		}
+		if have := statedb.GetState(addr, slot); have != value {
+			return fmt.Errorf("storage slot %x mismatch: have %x, proven %x", slot, have, value)
+		}
+	}
+	return nil
+}
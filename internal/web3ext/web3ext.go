@@ -33,6 +33,7 @@ var Modules = map[string]string{
 	"tomoxlending": TomoXLending_JS,
 	"swarmfs":      SWARMFS_JS,
 	"txpool":       TxPool_JS,
+	"watch":        Watch_JS,
 }
 
 const Chequebook_JS = `
@@ -138,6 +139,33 @@ web3._extend({
 			call: 'posv_getSignersAtHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getMasternodePerformance',
+			call: 'posv_getMasternodePerformance',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getSigningReport',
+			call: 'posv_getSigningReport',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getCheckpointProof',
+			call: 'posv_getCheckpointProof',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getStakeSnapshot',
+			call: 'posv_getStakeSnapshot',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getPenaltyDetails',
+			call: 'posv_getPenaltyDetails',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -162,6 +190,20 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'addTrustedPeer',
+			call: 'admin_addTrustedPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeTrustedPeer',
+			call: 'admin_removeTrustedPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'savePeers',
+			call: 'admin_savePeers'
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -245,6 +287,12 @@ web3._extend({
 			call: 'debug_dumpBlock',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'accountRange',
+			call: 'debug_accountRange',
+			params: 5,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null, null, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'chaindbProperty',
 			call: 'debug_chaindbProperty',
@@ -402,6 +450,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'preimages',
+			call: 'debug_preimages',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'getBadBlocks',
 			call: 'debug_getBadBlocks',
@@ -412,6 +466,16 @@ web3._extend({
 			call: 'debug_storageRangeAt',
 			params: 5,
 		}),
+		new web3._extend.Method({
+			name: 'trieSizes',
+			call: 'debug_trieSizes',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'traceRewards',
+			call: 'debug_traceRewards',
+			params: 1,
+		}),
 		new web3._extend.Method({
 			name: 'getModifiedAccountsByNumber',
 			call: 'debug_getModifiedAccountsByNumber',
@@ -424,6 +488,18 @@ web3._extend({
 			params: 2,
 			inputFormatter:[null, null],
 		}),
+		new web3._extend.Method({
+			name: 'getModifiedStorageByNumber',
+			call: 'debug_getModifiedStorageByNumber',
+			params: 2,
+			inputFormatter: [null, null],
+		}),
+		new web3._extend.Method({
+			name: 'getModifiedStorageByHash',
+			call: 'debug_getModifiedStorageByHash',
+			params: 2,
+			inputFormatter: [null, null],
+		}),
 	],
 	properties: []
 });
@@ -467,11 +543,21 @@ web3._extend({
 			call: 'eth_getRawTransactionByHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getTransactionExtended',
+			call: 'eth_getTransactionByHashExtended',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'getRewardByHash',
 			call: 'eth_getRewardByHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getFinalizedBlock',
+			call: 'eth_getFinalizedBlock',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'getRawTransactionFromBlock',
 			call: function(args) {
@@ -518,6 +604,22 @@ web3._extend({
 			name: 'stop',
 			call: 'miner_stop'
 		}),
+		new web3._extend.Method({
+			name: 'pause',
+			call: 'miner_pause'
+		}),
+		new web3._extend.Method({
+			name: 'resume',
+			call: 'miner_resume'
+		}),
+		new web3._extend.Method({
+			name: 'paused',
+			call: 'miner_paused'
+		}),
+		new web3._extend.Method({
+			name: 'health',
+			call: 'miner_health'
+		}),
 		new web3._extend.Method({
 			name: 'setEtherbase',
 			call: 'miner_setEtherbase',
@@ -724,6 +826,18 @@ web3._extend({
             params: 3
 		}),
 		new web3._extend.Method({
+            name: 'getOrderProof',
+            call: 'tomox_getOrderProof',
+            params: 4,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getPriceLevelsProof',
+            call: 'tomox_getPriceLevelsProof',
+            params: 5,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
             name: 'getPrice',
             call: 'tomox_getPrice',
             params: 2
@@ -784,6 +898,17 @@ web3._extend({
             params: 1
         }),
 		new web3._extend.Method({
+            name: 'getRelayerInfo',
+            call: 'tomox_getRelayerInfo',
+            params: 2,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getEpochSnapshot',
+            call: 'tomox_getEpochSnapshot',
+            params: 1
+		}),
+		new web3._extend.Method({
             name: 'getBestInvesting',
             call: 'tomox_getBestInvesting',
             params: 2
@@ -833,6 +958,18 @@ web3._extend({
             call: 'tomox_getLendingTradeById',
             params: 3
 		}),
+		new web3._extend.Method({
+            name: 'getCandles',
+            call: 'tomox_getCandles',
+            params: 5,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter, null, null, null]
+		}),
+		new web3._extend.Method({
+            name: 'getOrdersByAddress',
+            call: 'tomox_getOrdersByAddress',
+            params: 2,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
 	]
 });
 `
@@ -936,6 +1073,30 @@ web3._extend({
             call: 'tomoxlending_getPrice',
             params: 2
 		}),
+		new web3._extend.Method({
+            name: 'getLendingItemProof',
+            call: 'tomoxlending_getLendingItemProof',
+            params: 4,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getInterestLevelsProof',
+            call: 'tomoxlending_getInterestLevelsProof',
+            params: 5,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getRiskParameters',
+            call: 'tomoxlending_getRiskParameters',
+            params: 2,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getTradesByAddress',
+            call: 'tomoxlending_getTradesByAddress',
+            params: 2,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
 	]
 });
 `
@@ -1003,6 +1164,33 @@ web3._extend({
 				return status;
 			}
 		}),
+		new web3._extend.Property({
+			name: 'gap',
+			getter: 'txpool_gap'
+		}),
+	]
+});
+`
+
+const Watch_JS = `
+web3._extend({
+	property: 'watch',
+	methods: [
+		new web3._extend.Method({
+			name: 'addAddresses',
+			call: 'watch_addAddresses',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'removeAddresses',
+			call: 'watch_removeAddresses',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'addresses',
+			call: 'watch_addresses',
+			params: 1
+		}),
 	]
 });
 `
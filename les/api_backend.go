@@ -89,6 +89,16 @@ func (b *LesApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	return light.NewState(ctx, header, b.eth.odr), header, nil
 }
 
+// StateVerifyRatio always returns zero, the light state is retrieved through
+// Merkle proofs already.
+func (b *LesApiBackend) StateVerifyRatio() float64 {
+	return 0
+}
+
+func (b *LesApiBackend) CallCacheSize() int {
+	return b.eth.config.RPCCallCacheSize
+}
+
 func (b *LesApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
 	return b.eth.blockchain.GetBlockByHash(ctx, blockHash)
 }
@@ -133,6 +143,17 @@ func (b *LesApiBackend) GetPoolTransaction(txHash common.Hash) *types.Transactio
 	return b.eth.txPool.GetTransaction(txHash)
 }
 
+// GetPoolTransactionInfo always returns nil, the light pool only tracks the
+// locally submitted transactions and knows nothing about their ordering.
+func (b *LesApiBackend) GetPoolTransactionInfo(txHash common.Hash) *core.TxPoolInfo {
+	return nil
+}
+
+// GetPoolGapStatus always returns nil, the light pool not producing blocks.
+func (b *LesApiBackend) GetPoolGapStatus() *core.TxGapStatus {
+	return nil
+}
+
 func (b *LesApiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.eth.txPool.GetNonce(ctx, addr)
 }
@@ -172,6 +193,10 @@ func (b *LesApiBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 	return b.eth.blockchain.SubscribeLogsEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeChainReorgEvent(ch chan<- core.ChainReorgEvent) event.Subscription {
+	return b.eth.blockchain.SubscribeChainReorgEvent(ch)
+}
+
 func (b *LesApiBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.eth.blockchain.SubscribeRemovedLogsEvent(ch)
 }
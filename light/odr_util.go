@@ -127,7 +127,7 @@ func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint
 // in a block given by its hash.
 func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (types.Receipts, error) {
 	// Retrieve the potentially incomplete receipts from disk or network
-	receipts := core.GetBlockReceipts(odr.Database(), hash, number)
+	receipts := core.GetRawBlockReceipts(odr.Database(), hash, number)
 	if receipts == nil {
 		r := &ReceiptsRequest{Hash: hash, Number: number}
 		if err := odr.Retrieve(ctx, r); err != nil {
@@ -135,8 +135,8 @@ func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, num
 		}
 		receipts = r.Receipts
 	}
-	// If the receipts are incomplete, fill the derived fields
-	if len(receipts) > 0 && receipts[0].TxHash == (common.Hash{}) {
+	// Stored and retrieved receipts only carry the consensus fields, fill the derived ones
+	if len(receipts) > 0 {
 		block, err := GetBlock(ctx, odr, hash, number)
 		if err != nil {
 			return nil, err
@@ -147,7 +147,6 @@ func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, num
 		if err := core.SetReceiptsData(config, block, receipts); err != nil {
 			return nil, err
 		}
-		core.WriteBlockReceipts(odr.Database(), hash, number, receipts)
 	}
 	return receipts, nil
 }
@@ -156,7 +155,7 @@ func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, num
 // block given by its hash.
 func GetBlockLogs(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) ([][]*types.Log, error) {
 	// Retrieve the potentially incomplete receipts from disk or network
-	receipts := core.GetBlockReceipts(odr.Database(), hash, number)
+	receipts := core.GetRawBlockReceipts(odr.Database(), hash, number)
 	if receipts == nil {
 		r := &ReceiptsRequest{Hash: hash, Number: number}
 		if err := odr.Retrieve(ctx, r); err != nil {
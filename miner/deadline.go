@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/log"
+)
+
+const (
+	// packBudgetShare is the share of the slot the transactions are packed
+	// within at most, the budget starting there.
+	packBudgetShare = 2
+
+	// packBudgetMinShare is the share of the slot always left to pack the
+	// transactions, however close the previous blocks came to the deadline.
+	packBudgetMinShare = 10
+
+	// sealCloseShare is the share of the slot left before the deadline under
+	// which a block is sealed too close to it.
+	sealCloseShare = 4
+)
+
+// packBudget adapts the time given to pack the transactions of a block to how
+// close the previous blocks came to the deadline of their slot, shrinking it
+// on loaded masternodes so that they don't miss their turn.
+type packBudget struct {
+	period time.Duration // Length of the slots, zero for no deadline
+	budget time.Duration // Time given to pack the transactions of the next block
+	lock   sync.Mutex
+}
+
+// newPackBudget creates the budget of the slots of the period.
+func newPackBudget(period time.Duration) *packBudget {
+	b := &packBudget{period: period, budget: period / packBudgetShare}
+	packBudgetGauge.Update(int64(b.budget))
+	return b
+}
+
+// deadline returns the time the packing of the transactions started at start
+// stops, the zero time if it's unbounded.
+func (b *packBudget) deadline(start time.Time) time.Time {
+	if b.period == 0 {
+		return time.Time{}
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return start.Add(b.budget)
+}
+
+// update accounts the time the local sealing of a block took, excluding the
+// wait of the engine for the slot. A block sealed close to the deadline halves
+// the budget, any other grows it back by a tenth of its maximum.
+func (b *packBudget) update(elapsed time.Duration) {
+	if b.period == 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	max, min := b.period/packBudgetShare, b.period/packBudgetMinShare
+	if elapsed >= b.period-b.period/sealCloseShare {
+		sealCloseMeter.Mark(1)
+		if b.budget /= 2; b.budget < min {
+			b.budget = min
+		}
+		log.Warn("Block sealed close to the deadline, shrinking packing time", "elapsed", elapsed, "period", b.period, "budget", b.budget)
+	} else if b.budget < max {
+		if b.budget += max / 10; b.budget > max {
+			b.budget = max
+		}
+	}
+	packBudgetGauge.Update(int64(b.budget))
+}
+
+// current returns the time given to pack the transactions of the next block.
+func (b *packBudget) current() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.budget
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the packing time shrinks when blocks are sealed close to the
+// deadline of their slot, and grows back when they are not.
+func TestPackBudget(t *testing.T) {
+	b := newPackBudget(2 * time.Second)
+	if have := b.current(); have != time.Second {
+		t.Fatalf("initial budget mismatch: have %v, want %v", have, time.Second)
+	}
+	// Blocks sealed close to the deadline halve the budget, down to its minimum
+	for _, want := range []time.Duration{500 * time.Millisecond, 250 * time.Millisecond, 200 * time.Millisecond, 200 * time.Millisecond} {
+		b.update(1800 * time.Millisecond)
+		if have := b.current(); have != want {
+			t.Fatalf("shrunk budget mismatch: have %v, want %v", have, want)
+		}
+	}
+	// Blocks sealed in time grow it back, up to its maximum
+	b.update(500 * time.Millisecond)
+	if have, want := b.current(), 300*time.Millisecond; have != want {
+		t.Fatalf("grown budget mismatch: have %v, want %v", have, want)
+	}
+	for i := 0; i < 20; i++ {
+		b.update(500 * time.Millisecond)
+	}
+	if have := b.current(); have != time.Second {
+		t.Fatalf("restored budget mismatch: have %v, want %v", have, time.Second)
+	}
+	start := time.Now()
+	if have, want := b.deadline(start), start.Add(time.Second); !have.Equal(want) {
+		t.Errorf("deadline mismatch: have %v, want %v", have, want)
+	}
+	// Instant sealing has no deadline
+	if deadline := newPackBudget(0).deadline(start); !deadline.IsZero() {
+		t.Errorf("instant sealing deadline mismatch: have %v, want none", deadline)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the miner.
+
+package miner
+
+import (
+	"github.com/tomochain/tomochain/metrics"
+)
+
+var (
+	sealPrepareTimer   = metrics.NewRegisteredTimer("miner/seal/prepare", nil)
+	sealTomoXTimer     = metrics.NewRegisteredTimer("miner/seal/tomox", nil)
+	sealPackTimer      = metrics.NewRegisteredTimer("miner/seal/pack", nil)
+	sealCommitTimer    = metrics.NewRegisteredTimer("miner/seal/commit", nil)
+	sealBroadcastTimer = metrics.NewRegisteredTimer("miner/seal/broadcast", nil)
+	sealTotalTimer     = metrics.NewRegisteredTimer("miner/seal/total", nil)
+
+	sealCloseMeter    = metrics.NewRegisteredMeter("miner/seal/close", nil)
+	sealTruncateMeter = metrics.NewRegisteredMeter("miner/seal/truncate", nil)
+	packBudgetGauge   = metrics.NewRegisteredGauge("miner/seal/budget", nil)
+)
@@ -71,6 +71,10 @@ func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, engine con
 		worker:   newWorker(config, engine, common.Address{}, eth, mux, announceTxs),
 		canStart: 1,
 	}
+	if core.GetMinerPaused(eth.ChainDb()) {
+		atomic.StoreInt32(&miner.worker.paused, 1)
+		log.Warn("Block sealing paused for maintenance, use miner.resume() to propose blocks again")
+	}
 	miner.Register(NewCpuAgent(eth.BlockChain(), engine))
 	go miner.update()
 
@@ -125,6 +129,47 @@ func (self *Miner) Stop() {
 	atomic.StoreInt32(&self.shouldStart, 0)
 }
 
+// Pause stops proposing new blocks. A block already being sealed is finished
+// and the node keeps importing and validating the chain, so it can be drained
+// gracefully before maintenance. The pause is persisted across restarts.
+func (self *Miner) Pause() {
+	atomic.StoreInt32(&self.worker.paused, 1)
+	core.WriteMinerPaused(self.eth.ChainDb(), true)
+	log.Info("Paused block sealing for maintenance")
+}
+
+// Resume lifts a maintenance pause, proposing blocks again from the next turn.
+func (self *Miner) Resume() {
+	atomic.StoreInt32(&self.worker.paused, 0)
+	core.WriteMinerPaused(self.eth.ChainDb(), false)
+	log.Info("Resumed block sealing")
+	if self.Mining() {
+		self.worker.commitNewWork()
+	}
+}
+
+// Paused returns whether block sealing is paused for maintenance.
+func (self *Miner) Paused() bool {
+	return atomic.LoadInt32(&self.worker.paused) == 1
+}
+
+// SetHealthy holds or lets the proposing of new blocks as the health checks of
+// the node fail or pass. Unlike a maintenance pause, a hold isn't persisted.
+func (self *Miner) SetHealthy(healthy bool) {
+	if healthy {
+		if atomic.SwapInt32(&self.worker.unhealthy, 0) == 1 && self.Mining() {
+			self.worker.commitNewWork()
+		}
+		return
+	}
+	atomic.StoreInt32(&self.worker.unhealthy, 1)
+}
+
+// Healthy returns whether block sealing isn't held by failed health checks.
+func (self *Miner) Healthy() bool {
+	return atomic.LoadInt32(&self.worker.unhealthy) == 0
+}
+
 func (self *Miner) Register(agent Agent) {
 	if self.Mining() {
 		agent.Start()
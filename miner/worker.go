@@ -97,7 +97,9 @@ type Work struct {
 	txs      []*types.Transaction
 	receipts []*types.Receipt
 
-	createdAt time.Time
+	createdAt    time.Time
+	packDeadline time.Time     // Time the packing of the transactions stops, zero if unbounded
+	sealElapsed  time.Duration // Time taken to assemble the block for the engine to seal
 }
 
 type Result struct {
@@ -140,9 +142,12 @@ type worker struct {
 	possibleUncles map[common.Hash]*types.Block
 
 	unconfirmed *unconfirmedBlocks // set of locally mined blocks pending canonicalness confirmations
+	budget      *packBudget        // time given to pack the transactions, adapted to the deadlines met
 
 	// atomic status counters
 	mining                int32
+	paused                int32
+	unhealthy             int32
 	atWork                int32
 	announceTxs           bool
 	lastParentBlockCommit string
@@ -165,6 +170,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		coinbase:       coinbase,
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
+		budget:         newPackBudget(slotPeriod(config)),
 		announceTxs:    announceTxs,
 	}
 	if worker.announceTxs {
@@ -301,7 +307,7 @@ func (self *worker) update() {
 
 			// Handle ChainSideEvent
 		case ev := <-self.chainSideCh:
-			if self.config.Posv == nil {
+			if self.hasUncles() {
 				self.uncleMu.Lock()
 				self.possibleUncles[ev.Block.Hash()] = ev.Block
 				self.uncleMu.Unlock()
@@ -314,12 +320,12 @@ func (self *worker) update() {
 				acc, _ := types.Sender(self.current.signer, ev.Tx)
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
 				feeCapacity := state.GetTRC21FeeCapacityFromState(self.current.state)
-				txset, specialTxs := types.NewTransactionsByPriceAndNonce(self.current.signer, txs, nil, feeCapacity)
+				txset, specialTxs := types.NewTransactionsByPriceAndNonce(self.current.signer, txs, nil, feeCapacity, self.current.header.BaseFee)
 				self.current.commitTransactions(self.mux, feeCapacity, txset, specialTxs, self.chain, self.coinbase)
 				self.currentMu.Unlock()
 			} else {
 				// If we're mining, but nothing is being processed, wake on new transactions
-				if self.config.Posv != nil && self.config.Posv.Period == 0 {
+				if self.instantSeal() {
 					self.commitNewWork()
 				}
 			}
@@ -357,6 +363,7 @@ func (self *worker) wait() {
 			for _, log := range work.state.Logs() {
 				log.BlockHash = block.Hash()
 			}
+			tcommit := time.Now()
 			self.currentMu.Lock()
 			stat, err := self.chain.WriteBlockWithState(block, work.receipts, work.state, work.tradingState, work.lendingState)
 			self.currentMu.Unlock()
@@ -364,13 +371,25 @@ func (self *worker) wait() {
 				log.Error("Failed writing block to chain", "err", err)
 				continue
 			}
+			commitElapsed := time.Since(tcommit)
+			sealCommitTimer.Update(commitElapsed)
+
 			// check if canon block and write transactions
 			if stat == core.CanonStatTy {
 				// implicit by posting ChainHeadEvent
 				mustCommitNewWork = false
 			}
 			// Broadcast the block and announce chain insertion event
+			tbroadcast := time.Now()
 			self.mux.Post(core.NewMinedBlockEvent{Block: block})
+			broadcastElapsed := time.Since(tbroadcast)
+			sealBroadcastTimer.Update(broadcastElapsed)
+
+			// Adapt the packing time to how close the block came to its deadline
+			elapsed := work.sealElapsed + commitElapsed + broadcastElapsed
+			sealTotalTimer.Update(elapsed)
+			self.budget.update(elapsed)
+
 			var (
 				events []interface{}
 				logs   = work.state.Logs()
@@ -381,11 +400,11 @@ func (self *worker) wait() {
 			}
 			if work.config.Posv != nil {
 				// epoch block
-				if (block.NumberU64() % work.config.Posv.Epoch) == 0 {
+				if work.config.Posv.IsCheckpoint(block.NumberU64()) {
 					core.CheckpointCh <- 1
 				}
 				// prepare set of masternodes for the next epoch
-				if (block.NumberU64() % work.config.Posv.Epoch) == (work.config.Posv.Epoch - work.config.Posv.Gap) {
+				if work.config.Posv.IsGap(block.NumberU64()) {
 					err := self.chain.UpdateM1()
 					if err != nil {
 						log.Error("Error when update masternodes set. Stopping node", "err", err)
@@ -457,13 +476,14 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 	author, _ := self.chain.Engine().Author(parent.Header())
 	var tomoxState *tradingstate.TradingStateDB
 	var lendingState *lendingstate.LendingStateDB
-	if self.config.Posv != nil {
+	if self.config.ExchangeEpochs() != nil {
 		tomoX := self.eth.GetTomoX()
 		tomoxState, err = tomoX.GetTradingState(parent, author)
 		if err != nil {
 			log.Error("Failed to get tomox state ", "number", parent.Number(), "err", err)
 			return err
 		}
+		tomoxState.SetPruning(self.config.IsTIPTomoXPrune(header.Number))
 		lending := self.eth.GetTomoXLending()
 		lendingState, err = lending.GetLendingState(parent, author)
 		if err != nil {
@@ -474,7 +494,7 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 
 	work := &Work{
 		config:       self.config,
-		signer:       types.NewEIP155Signer(self.config.ChainId),
+		signer:       types.MakeSigner(self.config, header.Number),
 		state:        state,
 		parentState:  state.Copy(),
 		tradingState: tomoxState,
@@ -486,7 +506,7 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 		createdAt:    time.Now(),
 	}
 
-	if self.config.Posv == nil {
+	if self.hasUncles() {
 		// when 08 is processed ancestors contain 07 (quick block)
 		for _, ancestor := range self.chain.GetBlocksFromHash(parent.Hash(), 7) {
 			for _, uncle := range ancestor.Uncles() {
@@ -530,6 +550,16 @@ func (self *worker) commitNewWork() {
 
 	// Only try to commit new work if we are mining
 	if atomic.LoadInt32(&self.mining) == 1 {
+		// Stop proposing while paused, sealing work already handed to the
+		// agents is still finished and imported.
+		if atomic.LoadInt32(&self.paused) == 1 {
+			log.Debug("Block sealing paused for maintenance")
+			return
+		}
+		if atomic.LoadInt32(&self.unhealthy) == 1 {
+			log.Debug("Block sealing held by failed health checks")
+			return
+		}
 		// check if we are right after parent's coinbase in the list
 		// only go with Posv
 		if self.config.Posv != nil {
@@ -554,7 +584,7 @@ func (self *worker) commitNewWork() {
 				h := posv.Hop(len, preIndex, curIndex)
 				gap := waitPeriod * int64(h)
 				// Check nearest checkpoint block in hop range.
-				nearest := self.config.Posv.Epoch - (parent.Header().Number.Uint64() % self.config.Posv.Epoch)
+				nearest := self.config.Posv.EpochLength(parent.Header().Number.Uint64()) - self.config.Posv.EpochOffset(parent.Header().Number.Uint64())
 				if uint64(h) >= nearest {
 					gap = waitPeriodCheckpoint * int64(h)
 				}
@@ -586,11 +616,16 @@ func (self *worker) commitNewWork() {
 		Extra:      self.extra,
 		Time:       big.NewInt(tstamp),
 	}
+	// Set baseFee if we are on an EIP-1559 chain
+	if self.config.IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(self.config, parent.Header())
+	}
 	// Only set the coinbase if we are mining (avoid spurious block rewards)
 	if atomic.LoadInt32(&self.mining) == 1 {
 		header.Coinbase = self.coinbase
 	}
 
+	tprepare := time.Now()
 	if err := self.engine.Prepare(self.chain, header); err != nil {
 		log.Error("Failed to prepare header for new block", "err", err)
 		return
@@ -616,9 +651,13 @@ func (self *worker) commitNewWork() {
 	}
 	// Create the current work task and check any fork transitions needed
 	work := self.current
+	sealPrepareTimer.UpdateSince(tprepare)
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(work.state)
 	}
+	if self.config.TIPTomoXMakerRebateBlock != nil && self.config.TIPTomoXMakerRebateBlock.Cmp(header.Number) == 0 {
+		misc.ApplyTomoXMakerRebateFork(work.state)
+	}
 	if common.TIPSigningBlock.Cmp(header.Number) == 0 {
 		work.state.DeleteAddress(common.HexToAddress(common.BlockSigners))
 	}
@@ -628,26 +667,28 @@ func (self *worker) commitNewWork() {
 	}
 	// won't grasp txs at checkpoint
 	var (
-		txs                                                                  *types.TransactionsByPriceAndNonce
-		specialTxs                                                           types.Transactions
-		tradingTransaction                                                   *types.Transaction
-		lendingTransaction                                                   *types.Transaction
-		tradingTxMatches                                                     []tradingstate.TxDataMatch
-		tradingMatchingResults                                               map[common.Hash]tradingstate.MatchingResult
-		lendingMatchingResults                                               map[common.Hash]lendingstate.MatchingResult
-		lendingInput                                                         []*lendingstate.LendingItem
-		updatedTrades                                                        map[common.Hash]*lendingstate.LendingTrade
-		liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades []*lendingstate.LendingTrade
-		lendingFinalizedTradeTransaction                                     *types.Transaction
+		txs                                                                                   *types.TransactionsByPriceAndNonce
+		specialTxs                                                                            types.Transactions
+		tradingTransaction                                                                    *types.Transaction
+		lendingTransaction                                                                    *types.Transaction
+		tradingTxMatches                                                                      []tradingstate.TxDataMatch
+		tradingMatchingResults                                                                map[common.Hash]tradingstate.MatchingResult
+		lendingMatchingResults                                                                map[common.Hash]lendingstate.MatchingResult
+		lendingInput                                                                          []*lendingstate.LendingItem
+		updatedTrades                                                                         map[common.Hash]*lendingstate.LendingTrade
+		liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades []*lendingstate.LendingTrade
+		lendingFinalizedTradeTransaction                                                      *types.Transaction
+		lendingLogs                                                                           []*types.Log
 	)
 	feeCapacity := state.GetTRC21FeeCapacityFromStateWithCache(parent.Root(), work.state)
-	if self.config.Posv != nil && header.Number.Uint64()%self.config.Posv.Epoch != 0 {
+	if self.config.Posv == nil || !self.config.Posv.IsCheckpoint(header.Number.Uint64()) {
 		pending, err := self.eth.TxPool().Pending()
 		if err != nil {
 			log.Error("Failed to fetch pending transactions", "err", err)
 			return
 		}
-		txs, specialTxs = types.NewTransactionsByPriceAndNonce(self.current.signer, pending, signers, feeCapacity)
+		pending = self.eth.TxPool().DelayGapTransactions(pending, header.Number.Uint64())
+		txs, specialTxs = types.NewTransactionsByPriceAndNonce(self.current.signer, pending, signers, feeCapacity, header.BaseFee)
 	}
 	if atomic.LoadInt32(&self.mining) == 1 {
 		wallet, err := self.eth.AccountManager().Find(accounts.Account{Address: self.coinbase})
@@ -655,31 +696,53 @@ func (self *worker) commitNewWork() {
 			log.Warn("Can't find coinbase account wallet", "coinbase", self.coinbase, "err", err)
 			return
 		}
-		if self.config.Posv != nil && self.chain.Config().IsTIPTomoX(header.Number) {
+		tmatch := time.Now()
+		if epochs := self.config.ExchangeEpochs(); self.config.IsTomoXEnabled(header.Number) {
 			tomoX := self.eth.GetTomoX()
 			tomoXLending := self.eth.GetTomoXLending()
-			if tomoX != nil && header.Number.Uint64() > self.config.Posv.Epoch {
-				if header.Number.Uint64()%self.config.Posv.Epoch == 0 {
-					err := tomoX.UpdateMediumPriceBeforeEpoch(header.Number.Uint64()/self.config.Posv.Epoch, work.tradingState, work.state)
+			if tomoX != nil {
+				if epochs.IsCheckpoint(header.Number.Uint64()) {
+					err := tomoX.UpdateMediumPriceBeforeEpoch(epochs.EpochOf(header.Number.Uint64()), work.tradingState, work.state)
 					if err != nil {
 						log.Error("Fail when update medium price last epoch", "error", err)
 						return
 					}
+					if self.config.IsTIPTomoXDelisting(header.Number) {
+						if err := tomoX.CancelDelistedOrders(header, work.tradingState, work.state); err != nil {
+							log.Error("Fail when cancel orders of delisted pairs", "error", err)
+							return
+						}
+					}
+					if self.config.IsTIPTomoXOrderExpiry(header.Number) {
+						if err := tomoX.PurgeExpiredOrders(header, work.tradingState); err != nil {
+							log.Error("Fail when purge expired orders", "error", err)
+							return
+						}
+					}
 				}
 				// won't grasp tx at checkpoint
 				//https://github.com/tomochain/tomochain-v1/pull/416
-				if header.Number.Uint64()%self.config.Posv.Epoch != 0 {
+				if !epochs.IsCheckpoint(header.Number.Uint64()) {
 					log.Debug("Start processing order pending")
 					tradingOrderPending, _ := self.eth.OrderPool().Pending()
 					log.Debug("Start processing order pending", "len", len(tradingOrderPending))
 					tradingTxMatches, tradingMatchingResults = tomoX.ProcessOrderPending(header, self.coinbase, self.chain, tradingOrderPending, work.state, work.tradingState)
 					log.Debug("trading transaction matches found", "tradingTxMatches", len(tradingTxMatches))
+					if self.config.IsTIPTomoXStopOrder(header.Number) {
+						if err := tomoX.ProcessTriggeredOrders(header, self.coinbase, self.chain, work.state, work.tradingState); err != nil {
+							log.Error("Fail when activate triggered stop orders", "error", err)
+							return
+						}
+					}
 
 					lendingOrderPending, _ := self.eth.LendingPool().Pending()
 					lendingInput, lendingMatchingResults = tomoXLending.ProcessOrderPending(header, self.coinbase, self.chain, lendingOrderPending, work.state, work.lendingState, work.tradingState)
+					if self.config.IsTIPTomoXReceiptLogs(header.Number) {
+						lendingLogs = core.LendingLogs(lendingInput, lendingMatchingResults)
+					}
 					log.Debug("lending transaction matches found", "lendingInput", len(lendingInput), "lendingMatchingResults", len(lendingMatchingResults))
-					if header.Number.Uint64()%self.config.Posv.Epoch == common.LiquidateLendingTradeBlock {
-						updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err = tomoXLending.ProcessLiquidationData(header, self.chain, work.state, work.tradingState, work.lendingState)
+					if epochs.EpochOffset(header.Number.Uint64()) == common.LiquidateLendingTradeBlock {
+						updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err = tomoXLending.ProcessLiquidationData(header, self.chain, work.state, work.tradingState, work.lendingState)
 						if err != nil {
 							log.Error("Fail when process lending liquidation data ", "error", err)
 							return
@@ -705,9 +768,12 @@ func (self *worker) commitNewWork() {
 						return
 					} else {
 						tradingTransaction = txM
-						if tomoX.IsSDKNode() {
+						if tomoX.IsSDKNode() || tomoX.CandlesEnabled() {
 							self.chain.AddMatchingResult(tradingTransaction.Hash(), tradingMatchingResults)
 						}
+						if self.config.IsTIPTomoXReceiptLogs(header.Number) {
+							work.state.SetSettlementLogs(tradingTransaction.Hash(), core.TradingLogs(tradingTxMatches, tradingMatchingResults))
+						}
 					}
 				}
 				if len(lendingInput) > 0 {
@@ -730,15 +796,18 @@ func (self *worker) commitNewWork() {
 						return
 					} else {
 						lendingTransaction = signedLendingTx
-						if tomoX.IsSDKNode() {
+						if tomoX.IsSDKNode() || tomoX.AddressIndexEnabled() {
 							self.chain.AddLendingResult(lendingTransaction.Hash(), lendingMatchingResults)
 						}
+						if self.config.IsTIPTomoXReceiptLogs(header.Number) {
+							work.state.SetSettlementLogs(lendingTransaction.Hash(), lendingLogs)
+						}
 					}
 				}
 
 				if len(updatedTrades) > 0 {
 					log.Debug("M1 finalized trades")
-					finalizedTradeData, err := lendingstate.EncodeFinalizedResult(liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades)
+					finalizedTradeData, err := lendingstate.EncodeFinalizedResult(liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades)
 					if err != nil {
 						log.Error("Fail to marshal lendingData", "error", err)
 						return
@@ -754,6 +823,9 @@ func (self *worker) commitNewWork() {
 						if tomoX.IsSDKNode() {
 							self.chain.AddFinalizedTrades(lendingFinalizedTradeTransaction.Hash(), updatedTrades)
 						}
+						if self.config.IsTIPTomoXReceiptLogs(header.Number) {
+							work.state.SetSettlementLogs(lendingFinalizedTradeTransaction.Hash(), core.LiquidationLogs(liquidatedTrades))
+						}
 					}
 				}
 			}
@@ -770,24 +842,33 @@ func (self *worker) commitNewWork() {
 			specialTxs = append(specialTxs, lendingFinalizedTradeTransaction)
 		}
 
-		TomoxStateRoot := work.tradingState.IntermediateRoot()
-		LendingStateRoot := work.lendingState.IntermediateRoot()
-		txData := append(TomoxStateRoot.Bytes(), LendingStateRoot.Bytes()...)
-		tx := types.NewTransaction(work.state.GetNonce(self.coinbase), common.HexToAddress(common.TradingStateAddr), big.NewInt(0), txMatchGasLimit, big.NewInt(0), txData)
-		txStateRoot, err := wallet.SignTx(accounts.Account{Address: self.coinbase}, tx, self.config.ChainId)
-		if err != nil {
-			log.Error("Fail to create tx state root", "error", err)
-			return
+		// commit the TomoX state roots, unless the chain has no TomoX epochs
+		if work.tradingState != nil && work.lendingState != nil {
+			TomoxStateRoot := work.tradingState.IntermediateRoot()
+			LendingStateRoot := work.lendingState.IntermediateRoot()
+			txData := append(TomoxStateRoot.Bytes(), LendingStateRoot.Bytes()...)
+			tx := types.NewTransaction(work.state.GetNonce(self.coinbase), common.HexToAddress(common.TradingStateAddr), big.NewInt(0), txMatchGasLimit, big.NewInt(0), txData)
+			txStateRoot, err := wallet.SignTx(accounts.Account{Address: self.coinbase}, tx, self.config.ChainId)
+			if err != nil {
+				log.Error("Fail to create tx state root", "error", err)
+				return
+			}
+			specialTxs = append(specialTxs, txStateRoot)
 		}
-		specialTxs = append(specialTxs, txStateRoot)
+		sealTomoXTimer.UpdateSince(tmatch)
+		work.packDeadline = self.budget.deadline(time.Now())
 	}
+	tpack := time.Now()
 	work.commitTransactions(self.mux, feeCapacity, txs, specialTxs, self.chain, self.coinbase)
+	if atomic.LoadInt32(&self.mining) == 1 {
+		sealPackTimer.UpdateSince(tpack)
+	}
 	// compute uncles for the new block.
 	var (
 		uncles    []*types.Header
 		badUncles []common.Hash
 	)
-	if self.config.Posv == nil {
+	if self.hasUncles() {
 		for hash, uncle := range self.possibleUncles {
 			if len(uncles) == 2 {
 				break
@@ -816,9 +897,37 @@ func (self *worker) commitNewWork() {
 		self.unconfirmed.Shift(work.Block.NumberU64() - 1)
 		self.lastParentBlockCommit = parent.Hash().Hex()
 	}
+	work.sealElapsed = time.Since(tprepare)
 	self.push(work)
 }
 
+// slotPeriod returns the length of the slots the blocks are sealed in, zero
+// if they are sealed as soon as transactions arrive or by proof-of-work.
+func slotPeriod(config *params.ChainConfig) time.Duration {
+	var period uint64
+	switch {
+	case config.Posv != nil:
+		period = config.Posv.Period
+	case config.Clique != nil:
+		period = config.Clique.Period
+	case config.Dev != nil:
+		period = config.Dev.Period
+	}
+	return time.Duration(period) * time.Second
+}
+
+// hasUncles returns whether the engine rewards uncles, proof-of-work being the
+// only one including them.
+func (self *worker) hasUncles() bool {
+	return self.config.Posv == nil && self.config.Clique == nil && self.config.Dev == nil
+}
+
+// instantSeal returns whether the engine seals blocks as soon as transactions
+// arrive instead of periodically.
+func (self *worker) instantSeal() bool {
+	return !self.hasUncles() && slotPeriod(self.config) == 0
+}
+
 func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	hash := uncle.Hash()
 	if work.uncles.Contains(hash) {
@@ -890,13 +999,13 @@ func (env *Work) commitTransactions(mux *event.TypeMux, balanceFee map[common.Ad
 			log.Trace("Ignoring reply protected special transaction", "hash", tx.Hash(), "eip155", env.config.EIP155Block)
 			continue
 		}
-		if tx.To().Hex() == common.BlockSigners {
+		if tx.To().Hex() == common.BlockSigners && env.config.Posv != nil {
 			if len(tx.Data()) < 68 {
 				log.Trace("Data special transaction invalid length", "hash", tx.Hash(), "data", len(tx.Data()))
 				continue
 			}
 			blkNumber := binary.BigEndian.Uint64(tx.Data()[8:40])
-			if blkNumber >= env.header.Number.Uint64() || blkNumber <= env.header.Number.Uint64()-env.config.Posv.Epoch*2 {
+			if blkNumber >= env.header.Number.Uint64() || blkNumber <= env.header.Number.Uint64()-env.config.Posv.EpochLength(env.header.Number.Uint64())*2 {
 				log.Trace("Data special transaction invalid number", "hash", tx.Hash(), "blkNumber", blkNumber, "miner", env.header.Number)
 				continue
 			}
@@ -948,6 +1057,12 @@ func (env *Work) commitTransactions(mux *event.TypeMux, balanceFee map[common.Ad
 			log.Info("this block has no transaction")
 			break
 		}
+		// Leave the rest of the slot to seal and broadcast the block
+		if !env.packDeadline.IsZero() && time.Now().After(env.packDeadline) {
+			log.Debug("Transaction packing time exceeded", "number", env.header.Number, "txs", env.tcount)
+			sealTruncateMeter.Mark(1)
+			break
+		}
 		// Retrieve the next transaction and abort if all done
 		tx := txs.Peek()
 
@@ -145,6 +145,11 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// RPCDeprecationMetrics enables usage counters for RPC methods and request
+	// fields which are scheduled for removal. The counters are reported via the
+	// metrics registry, so metrics collection needs to be turned on as well.
+	RPCDeprecationMetrics bool `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -154,11 +154,12 @@ func (n *Node) Start() error {
 	n.serverConfig.PrivateKey = n.config.NodeKey()
 	n.serverConfig.Name = n.config.NodeName()
 	n.serverConfig.Logger = n.log
-	if n.serverConfig.StaticNodes == nil {
-		n.serverConfig.StaticNodes = n.config.StaticNodes()
+	// The static and trusted node files are loaded, and watched, by the server
+	if n.serverConfig.StaticNodesFile == "" {
+		n.serverConfig.StaticNodesFile = n.config.resolvePath(datadirStaticNodes)
 	}
-	if n.serverConfig.TrustedNodes == nil {
-		n.serverConfig.TrustedNodes = n.config.TrustedNodes()
+	if n.serverConfig.TrustedNodesFile == "" {
+		n.serverConfig.TrustedNodesFile = n.config.resolvePath(datadirTrustedNodes)
 	}
 	if n.serverConfig.NodeDatabase == "" {
 		n.serverConfig.NodeDatabase = n.config.NodeDB()
@@ -256,6 +257,10 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
 	}
+	if n.config.RPCDeprecationMetrics {
+		rpc.EnableDeprecationTracking()
+		n.log.Info("Tracking usage of deprecated RPC endpoints", "methods", len(rpc.DeprecatedMethods()))
+	}
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
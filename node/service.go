@@ -39,12 +39,13 @@ type ServiceContext struct {
 
 // OpenDatabase opens an existing database with the given name (or creates one
 // if no previous can be found) from within the node's data directory. If the
-// node is an ephemeral one, a memory database is returned.
+// node is an ephemeral one, a memory database is returned. The internals of the
+// database are reported through the metrics under eth/db/<name>/.
 func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (ethdb.Database, error) {
 	if ctx.config.DataDir == "" {
 		return rawdb.NewMemoryDatabase(), nil
 	}
-	db, err := rawdb.NewLevelDBDatabase(ctx.config.resolvePath(name), cache, handles, "")
+	db, err := rawdb.NewLevelDBDatabase(ctx.config.resolvePath(name), cache, handles, "eth/db/"+name+"/")
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +86,9 @@ type Table struct {
 
 	net  transport
 	self *Node // metadata of the local node
+
+	announced *Node      // local node with its announced endpoint, changed by SetSelfIP
+	selfMu    sync.Mutex // protects announced
 }
 
 type bondproc struct {
@@ -118,10 +121,12 @@ func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string
 	if err != nil {
 		return nil, err
 	}
+	self := NewNode(ourID, ourAddr.IP, uint16(ourAddr.Port), uint16(ourAddr.Port))
 	tab := &Table{
 		net:        t,
 		db:         db,
-		self:       NewNode(ourID, ourAddr.IP, uint16(ourAddr.Port), uint16(ourAddr.Port)),
+		self:       self,
+		announced:  self,
 		bonding:    make(map[NodeID]*bondproc),
 		bondslots:  make(chan struct{}, maxBondingPingPongs),
 		refreshReq: make(chan chan struct{}),
@@ -164,7 +169,20 @@ func (tab *Table) seedRand() {
 // Self returns the local node.
 // The returned node should not be modified by the caller.
 func (tab *Table) Self() *Node {
-	return tab.self
+	tab.selfMu.Lock()
+	defer tab.selfMu.Unlock()
+
+	return tab.announced
+}
+
+// SetSelfIP changes the IP address of the local node announced to the other
+// nodes, e.g. after a change of the external IP of its NAT.
+func (tab *Table) SetSelfIP(ip net.IP) {
+	tab.selfMu.Lock()
+	defer tab.selfMu.Unlock()
+
+	self := tab.announced
+	tab.announced = NewNode(self.ID, ip, self.UDP, self.TCP)
 }
 
 // ReadRandomNodes fills the given slice with random nodes from the
@@ -163,7 +163,6 @@ type udp struct {
 	conn        conn
 	netrestrict *netutil.Netlist
 	priv        *ecdsa.PrivateKey
-	ourEndpoint rpcEndpoint
 
 	addpending chan *pending
 	gotreply   chan reply
@@ -254,7 +253,6 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 		realaddr = cfg.AnnounceAddr
 	}
 	// TODO: separate TCP port
-	udp.ourEndpoint = makeEndpoint(realaddr, uint16(realaddr.Port))
 	tab, err := newTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath, cfg.Bootnodes)
 	if err != nil {
 		return nil, nil, err
@@ -266,6 +264,12 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	return udp.Table, udp, nil
 }
 
+// ourEndpoint returns the endpoint of the local node announced in the pings.
+func (t *udp) ourEndpoint() rpcEndpoint {
+	self := t.Self()
+	return makeEndpoint(&net.UDPAddr{IP: self.IP, Port: int(self.UDP)}, self.TCP)
+}
+
 func (t *udp) close() {
 	close(t.closing)
 	t.conn.Close()
@@ -276,7 +280,7 @@ func (t *udp) close() {
 func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) error {
 	req := &ping{
 		Version:    Version,
-		From:       t.ourEndpoint,
+		From:       t.ourEndpoint(),
 		To:         makeEndpoint(toaddr, 0), // TODO: maybe use known TCP port from DB
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
 	}
@@ -0,0 +1,344 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/discv5"
+	"github.com/tomochain/tomochain/p2p/dnsdisc"
+	"github.com/tomochain/tomochain/p2p/enr"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// discv5Network is the part of the discovery v5 network used by the dialer.
+type discv5Network interface {
+	Self() *discv5.Node
+	Resolve(target discv5.NodeID) *discv5.Node
+	Lookup(target discv5.NodeID) []*discv5.Node
+	ReadRandomNodes([]*discv5.Node) int
+}
+
+// discv5Table is the discovery table of the dialer when discovery v5 runs,
+// alone or alongside the v4 table. The random nodes are read from the v5
+// network first, keeping only those whose record passes the dial filter, and
+// completed from the v4 table.
+type discv5Table struct {
+	v4     discoverTable // nil if discovery v4 is disabled
+	v5     discv5Network
+	filter func(*enr.Record) bool
+	buf    []*discv5.Node
+}
+
+func newDiscv5Table(v4 discoverTable, v5 discv5Network, filter func(*enr.Record) bool) *discv5Table {
+	return &discv5Table{v4: v4, v5: v5, filter: filter}
+}
+
+func (t *discv5Table) Self() *discover.Node {
+	if t.v4 != nil {
+		return t.v4.Self()
+	}
+	return convertNode(t.v5.Self())
+}
+
+// Close closes the v4 table, the v5 network being closed by the server.
+func (t *discv5Table) Close() {
+	if t.v4 != nil {
+		t.v4.Close()
+	}
+}
+
+func (t *discv5Table) Resolve(target discover.NodeID) *discover.Node {
+	if t.v4 != nil {
+		if n := t.v4.Resolve(target); n != nil {
+			return n
+		}
+	}
+	if n := t.v5.Resolve(discv5.NodeID(target)); n != nil && n.TCP != 0 {
+		return convertNode(n)
+	}
+	return nil
+}
+
+func (t *discv5Table) Lookup(target discover.NodeID) []*discover.Node {
+	if t.v4 != nil {
+		return t.v4.Lookup(target)
+	}
+	return t.convertNodes(t.v5.Lookup(discv5.NodeID(target)), nil)
+}
+
+func (t *discv5Table) ReadRandomNodes(buf []*discover.Node) int {
+	if len(t.buf) < len(buf) {
+		t.buf = make([]*discv5.Node, len(buf))
+	}
+	nodes := t.buf[:len(buf)]
+	for i := range nodes {
+		nodes[i] = nil
+	}
+	n := t.v5.ReadRandomNodes(nodes)
+	if n > len(nodes) {
+		n = len(nodes)
+	}
+	found := len(t.convertNodes(nodes[:n], buf[:0]))
+	if t.v4 != nil && found < len(buf) {
+		found += t.v4.ReadRandomNodes(buf[found:])
+	}
+	return found
+}
+
+// convertNodes appends to buf the dialable nodes whose record, if known,
+// passes the dial filter.
+func (t *discv5Table) convertNodes(nodes []*discv5.Node, buf []*discover.Node) []*discover.Node {
+	for _, n := range nodes {
+		if n == nil || n.TCP == 0 {
+			continue
+		}
+		if r := n.Record(); r != nil && t.filter != nil && !t.filter(r) {
+			continue
+		}
+		buf = append(buf, convertNode(n))
+	}
+	return buf
+}
+
+func convertNode(n *discv5.Node) *discover.Node {
+	return discover.NewNode(discover.NodeID(n.ID), n.IP, n.UDP, n.TCP)
+}
+
+// dnsTable complements the discovery table of the dialer with the nodes of
+// the DNS node lists, synced in the background. Up to half of the random
+// nodes are read from the lists.
+type dnsTable struct {
+	discoverTable
+	client *dnsdisc.Client
+	urls   []string
+
+	mu     sync.Mutex
+	nodes  []*discover.Node
+	closed chan struct{}
+}
+
+func newDNSTable(tab discoverTable, client *dnsdisc.Client, urls []string) *dnsTable {
+	t := &dnsTable{
+		discoverTable: tab,
+		client:        client,
+		urls:          urls,
+		closed:        make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// loop syncs the node lists every recheck interval.
+func (t *dnsTable) loop() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			nodes, err := t.client.SyncNodes(t.urls)
+			if err != nil {
+				log.Warn("Failed to sync DNS node lists", "err", err)
+			}
+			// Keep the nodes of the last sync if all the lists failed
+			if err == nil || len(nodes) > 0 {
+				t.mu.Lock()
+				t.nodes = nodes
+				t.mu.Unlock()
+			}
+			log.Debug("Synced DNS node lists", "nodes", len(nodes))
+			timer.Reset(t.client.RecheckInterval())
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+func (t *dnsTable) Close() {
+	close(t.closed)
+	t.discoverTable.Close()
+}
+
+func (t *dnsTable) ReadRandomNodes(buf []*discover.Node) int {
+	t.mu.Lock()
+	n := 0
+	for _, i := range rand.Perm(len(t.nodes)) {
+		if n == (len(buf)+1)/2 {
+			break
+		}
+		buf[n] = t.nodes[i]
+		n++
+	}
+	t.mu.Unlock()
+
+	return n + t.discoverTable.ReadRandomNodes(buf[n:])
+}
+
+// dialFilter returns the filter of the records of the discovered nodes,
+// accepting those accepted by any protocol, or nil if no protocol filters.
+func (srv *Server) dialFilter() func(*enr.Record) bool {
+	var filters []func(*enr.Record) bool
+	for _, p := range srv.Protocols {
+		if p.DialFilter != nil {
+			filters = append(filters, p.DialFilter)
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return func(r *enr.Record) bool {
+		for _, filter := range filters {
+			if filter(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// makeRecord assembles and signs the record of the local node, holding its
+// endpoint and the attributes of the protocols, with the sequence number next
+// to the one of the previous record.
+func (srv *Server) makeRecord(seq uint64) (*enr.Record, error) {
+	r := new(enr.Record)
+	r.SetSeq(seq)
+
+	realaddr := srv.realaddr
+	var ip net.IP
+	if srv.listener != nil {
+		addr := srv.listener.Addr().(*net.TCPAddr)
+		ip = addr.IP
+		r.Set(enr.TCP(addr.Port))
+	}
+	if realaddr != nil {
+		if ip == nil || !realaddr.IP.IsUnspecified() {
+			ip = realaddr.IP
+		}
+		r.Set(enr.UDP(realaddr.Port))
+		if srv.DiscoveryV5 {
+			r.Set(enr.DiscPort(realaddr.Port))
+		}
+	}
+	if srv.extIP != nil {
+		ip = srv.extIP
+	}
+	if ip != nil && !ip.IsUnspecified() {
+		if ip4 := ip.To4(); ip4 != nil {
+			r.Set(enr.IP4(ip4))
+		} else {
+			r.Set(enr.IP6(ip))
+		}
+	}
+	for _, p := range srv.Protocols {
+		for _, attr := range p.Attributes {
+			r.Set(attr)
+		}
+	}
+	if err := r.Sign(srv.PrivateKey); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// natLoop checks the external IP of the NAT periodically, updating the endpoint
+// advertised by the local node when it changes, e.g. after the router of a
+// dynamic IP reconnected.
+func (srv *Server) natLoop() {
+	defer srv.loopWG.Done()
+
+	ticker := time.NewTicker(extIPRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if ip, err := srv.NAT.ExternalIP(); err != nil {
+			srv.log.Debug("Couldn't get external IP", "interface", srv.NAT, "err", err)
+		} else if err := srv.setExternalIP(ip); err != nil {
+			srv.log.Warn("Failed to update the local node record", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// setExternalIP changes the external IP advertised by the local node in its
+// discovery endpoint, its enode URL and its record.
+func (srv *Server) setExternalIP(ip net.IP) error {
+	srv.selfLock.Lock()
+	defer srv.selfLock.Unlock()
+
+	if ip.Equal(srv.extIP) {
+		return nil
+	}
+	srv.log.Info("External IP changed", "old", srv.extIP, "new", ip)
+	srv.extIP = ip
+	if srv.realaddr != nil {
+		srv.realaddr = &net.UDPAddr{IP: ip, Port: srv.realaddr.Port}
+	}
+	if srv.discv4 != nil {
+		srv.discv4.SetSelfIP(ip)
+	}
+	record, err := srv.makeRecord(srv.record.Seq())
+	if err != nil {
+		return err
+	}
+	srv.record = record
+	if srv.DiscV5 != nil {
+		srv.DiscV5.SetRecord(record)
+	}
+	return nil
+}
+
+// externalIP returns the external IP of the NAT, or nil if unknown.
+func (srv *Server) externalIP() net.IP {
+	srv.selfLock.Lock()
+	defer srv.selfLock.Unlock()
+
+	return srv.extIP
+}
+
+// LocalRecord returns the signed record of the local node, or nil if the
+// server is not running.
+func (srv *Server) LocalRecord() *enr.Record {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return nil
+	}
+	srv.selfLock.Lock()
+	defer srv.selfLock.Unlock()
+
+	return srv.record
+}
+
+// encodeRecord returns the textual form of a node record.
+func encodeRecord(r *enr.Record) string {
+	blob, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		return ""
+	}
+	return "enr:" + base64.RawURLEncoding.EncodeToString(blob)
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/discv5"
+	"github.com/tomochain/tomochain/p2p/enr"
+	"github.com/tomochain/tomochain/p2p/nat"
+)
+
+type fakeV5 []*discv5.Node
+
+func (t fakeV5) Self() *discv5.Node                     { return new(discv5.Node) }
+func (t fakeV5) Resolve(discv5.NodeID) *discv5.Node     { return nil }
+func (t fakeV5) Lookup(discv5.NodeID) []*discv5.Node    { return t }
+func (t fakeV5) ReadRandomNodes(buf []*discv5.Node) int { return copy(buf, t) }
+
+// Tests that the local record advertises the endpoint of the node and the
+// attributes of its protocols.
+func TestServerLocalRecord(t *testing.T) {
+	srv := &Server{Config: Config{
+		MaxPeers:   10,
+		ListenAddr: "127.0.0.1:0",
+		PrivateKey: newkey(),
+		NoDial:     true,
+		Protocols: []Protocol{{
+			Name:       "test",
+			Attributes: []enr.Entry{enr.WithEntry("test", uint(7))},
+		}},
+	}}
+	if srv.LocalRecord() != nil {
+		t.Fatal("record of a stopped server")
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	r := srv.LocalRecord()
+	var (
+		key  enr.Secp256k1
+		ip   enr.IP4
+		port enr.TCP
+		attr uint
+	)
+	if err := r.Load(&key); err != nil || crypto.PubkeyToAddress(ecdsa.PublicKey(key)) != crypto.PubkeyToAddress(srv.PrivateKey.PublicKey) {
+		t.Errorf("key mismatch: %v", err)
+	}
+	if err := r.Load(&ip); err != nil || !net.IP(ip).Equal(net.IP{127, 0, 0, 1}) {
+		t.Errorf("ip mismatch: have %v, err %v", net.IP(ip), err)
+	}
+	if err := r.Load(&port); err != nil || int(port) != srv.listener.Addr().(*net.TCPAddr).Port {
+		t.Errorf("port mismatch: have %d, err %v", port, err)
+	}
+	if err := r.Load(enr.WithEntry("test", &attr)); err != nil || attr != 7 {
+		t.Errorf("attribute mismatch: have %d, err %v", attr, err)
+	}
+	if info := srv.NodeInfo(); !strings.HasPrefix(info.ENR, "enr:") {
+		t.Errorf("record not in the node info: %q", info.ENR)
+	}
+}
+
+// Tests that a change of the external IP of the NAT is advertised in the enode
+// URL and in the record of the local node.
+func TestServerExternalIPChange(t *testing.T) {
+	srv := &Server{Config: Config{
+		MaxPeers:   10,
+		ListenAddr: "127.0.0.1:0",
+		PrivateKey: newkey(),
+		NoDial:     true,
+		NAT:        nat.ExtIP(net.IP{1, 2, 3, 4}),
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	if ip := srv.Self().IP; !ip.Equal(net.IP{1, 2, 3, 4}) {
+		t.Fatalf("enode IP mismatch: have %v, want 1.2.3.4", ip)
+	}
+	seq := srv.LocalRecord().Seq()
+
+	if err := srv.setExternalIP(net.IP{5, 6, 7, 8}); err != nil {
+		t.Fatalf("failed to change the external IP: %v", err)
+	}
+	if ip := srv.Self().IP; !ip.Equal(net.IP{5, 6, 7, 8}) {
+		t.Errorf("enode IP mismatch: have %v, want 5.6.7.8", ip)
+	}
+	r := srv.LocalRecord()
+	if r.Seq() != seq+1 {
+		t.Errorf("record sequence mismatch: have %d, want %d", r.Seq(), seq+1)
+	}
+	var ip enr.IP4
+	if err := r.Load(&ip); err != nil || !net.IP(ip).Equal(net.IP{5, 6, 7, 8}) {
+		t.Errorf("record IP mismatch: have %v, err %v", net.IP(ip), err)
+	}
+	// The same IP is not advertised again
+	if err := srv.setExternalIP(net.IP{5, 6, 7, 8}); err != nil || srv.LocalRecord().Seq() != seq+1 {
+		t.Errorf("record updated for the same IP: seq %d, err %v", srv.LocalRecord().Seq(), err)
+	}
+}
+
+// Tests that the discovered nodes are dialed if any protocol accepts them.
+func TestServerDialFilter(t *testing.T) {
+	key := func(r *enr.Record) string {
+		var v string
+		r.Load(enr.WithEntry("proto", &v))
+		return v
+	}
+	srv := &Server{Config: Config{Protocols: []Protocol{
+		{Name: "a", DialFilter: func(r *enr.Record) bool { return key(r) == "a" }},
+		{Name: "b", DialFilter: func(r *enr.Record) bool { return key(r) == "b" }},
+		{Name: "c"},
+	}}}
+	filter := srv.dialFilter()
+	for _, proto := range []string{"a", "b", "c"} {
+		var r enr.Record
+		r.Set(enr.WithEntry("proto", proto))
+		if have, want := filter(&r), proto != "c"; have != want {
+			t.Errorf("record of %s: acceptance mismatch: have %v, want %v", proto, have, want)
+		}
+	}
+	if (&Server{Config: Config{Protocols: []Protocol{{Name: "c"}}}}).dialFilter() != nil {
+		t.Error("filter without filtering protocols")
+	}
+}
+
+// Tests that the random nodes are read from discovery v5 first, skipping the
+// undialable ones, and completed from the v4 table.
+func TestDiscv5TableReadRandomNodes(t *testing.T) {
+	v5 := fakeV5{
+		discv5.NewNode(discv5.NodeID{1}, net.IP{10, 0, 0, 1}, 30301, 30303),
+		discv5.NewNode(discv5.NodeID{2}, net.IP{10, 0, 0, 2}, 30301, 0),
+		discv5.NewNode(discv5.NodeID{3}, net.IP{10, 0, 0, 3}, 30301, 30303),
+	}
+	v4 := fakeTable{
+		{ID: discover.NodeID{4}},
+		{ID: discover.NodeID{5}},
+		{ID: discover.NodeID{6}},
+	}
+	// The nodes whose record is unknown yet are not filtered
+	buf := make([]*discover.Node, 4)
+	n := newDiscv5Table(v4, v5, func(*enr.Record) bool { return false }).ReadRandomNodes(buf)
+
+	var ids []discover.NodeID
+	for _, node := range buf[:n] {
+		ids = append(ids, node.ID)
+	}
+	want := []discover.NodeID{{1}, {3}, {4}, {5}}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("nodes mismatch: have %x, want %x", ids, want)
+	}
+	// Without the v4 table, lookups go through discovery v5
+	if nodes := newDiscv5Table(nil, v5, nil).Lookup(discover.NodeID{}); len(nodes) != 2 || nodes[1].TCP != 30303 {
+		t.Errorf("lookup mismatch: %v", nodes)
+	}
+}
+
+// Tests that up to half of the random nodes are read from the DNS node lists.
+func TestDNSTableReadRandomNodes(t *testing.T) {
+	tab := &dnsTable{
+		discoverTable: fakeTable{{ID: discover.NodeID{4}}, {ID: discover.NodeID{5}}, {ID: discover.NodeID{6}}},
+		nodes:         []*discover.Node{{ID: discover.NodeID{1}}, {ID: discover.NodeID{2}}, {ID: discover.NodeID{3}}},
+	}
+	buf := make([]*discover.Node, 4)
+	if n := tab.ReadRandomNodes(buf); n != 4 {
+		t.Fatalf("node count mismatch: have %d, want %d", n, 4)
+	}
+	listed := 0
+	for _, n := range buf {
+		if n.ID[0] <= 3 {
+			listed++
+		}
+	}
+	if listed != 2 {
+		t.Errorf("listed nodes mismatch: have %d, want %d", listed, 2)
+	}
+	// Without nodes in the discovery table, the lists fill half of the buffer
+	tab.discoverTable = fakeTable{}
+	if n := tab.ReadRandomNodes(buf); n != 2 {
+		t.Errorf("node count mismatch: have %d, want %d", n, 2)
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/crypto/sha3"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p/enr"
 	"github.com/tomochain/tomochain/p2p/netutil"
 	"github.com/tomochain/tomochain/rlp"
 )
@@ -37,6 +38,8 @@ var (
 	errInvalidEvent = errors.New("invalid in current state")
 	errNoQuery      = errors.New("no pending query")
 	errWrongAddress = errors.New("unknown sender address")
+	errNoRequest    = errors.New("no pending record request")
+	errRecordOwner  = errors.New("record not signed by the node")
 )
 
 const (
@@ -86,6 +89,10 @@ type Network struct {
 
 	// Buffers for state transition.
 	sendBuf []*ingressPacket
+
+	// The local node record served to the peers, set by SetRecord.
+	// Access is restricted to the Network.loop goroutine.
+	record *enr.Record
 }
 
 // transport is implemented by the UDP transport.
@@ -194,6 +201,12 @@ func (net *Network) ReadRandomNodes(buf []*Node) (n int) {
 	return n
 }
 
+// SetRecord sets the node record served to the peers requesting it. The
+// record must be signed with the key of the local node.
+func (net *Network) SetRecord(r *enr.Record) {
+	net.reqTableOp(func() { net.record = r })
+}
+
 // SetFallbackNodes sets the initial points of contact. These nodes
 // are used to connect to the network if the table is empty and there
 // are no known nodes in the database.
@@ -783,6 +796,16 @@ type nodeNetGuts struct {
 	deferredQueries   []*findnodeQuery // queries that can't be sent yet
 	pendingNeighbours *findnodeQuery   // current query, waiting for reply
 	queryTimeouts     int
+	enrEcho           []byte // hash of last record request sent by us
+
+	// The node record, replaced (never modified) when a newer one is received.
+	record *enr.Record
+}
+
+// Record returns the last node record received from the node, or nil if the
+// node did not send any.
+func (n *Node) Record() *enr.Record {
+	return n.record
 }
 
 func (n *nodeNetGuts) deferQuery(q *findnodeQuery) {
@@ -840,6 +863,8 @@ const (
 	topicRegisterPacket
 	topicQueryPacket
 	topicNodesPacket
+	enrRequestPacket
+	enrResponsePacket
 
 	// Non-packet events.
 	// Event values in this category are allocated outside
@@ -883,6 +908,7 @@ func init() {
 				q.reply <- nil
 			}
 			n.deferredQueries = nil
+			n.enrEcho = nil
 			if n.pendingNeighbours != nil {
 				n.pendingNeighbours.reply <- nil
 				n.pendingNeighbours = nil
@@ -964,6 +990,7 @@ func init() {
 		enter: func(net *Network, n *Node) {
 			n.queryTimeouts = 0
 			n.startNextQuery(net)
+			net.requestRecord(n)
 			// Insert into the table and start revalidation of the last node
 			// in the bucket if it is full.
 			last := net.tab.add(n)
@@ -1066,6 +1093,11 @@ func (net *Network) checkPacket(n *Node, ev nodeEvent, pkt *ingressPacket) error
 			return fmt.Errorf("pong reply token mismatch")
 		}
 		n.pingEcho = nil
+	case enrResponsePacket:
+		if n.enrEcho == nil || !bytes.Equal(pkt.data.(*enrResponse).ReplyTok, n.enrEcho) {
+			return errNoRequest
+		}
+		n.enrEcho = nil
 	}
 	// Address validation.
 	// TODO: Ideally we would do the following:
@@ -1209,11 +1241,46 @@ func (net *Network) handleQueryEvent(n *Node, ev nodeEvent, pkt *ingressPacket)
 		}
 		return n.state, nil
 
+	case enrRequestPacket:
+		if net.record != nil {
+			net.conn.send(n, enrResponsePacket, &enrResponse{ReplyTok: pkt.hash, Record: *net.record})
+		}
+		return n.state, nil
+	case enrResponsePacket:
+		return n.state, net.handleRecord(n, &pkt.data.(*enrResponse).Record)
+
 	default:
 		return n.state, errInvalidEvent
 	}
 }
 
+// requestRecord asks n for its node record, unless a request is in flight.
+func (net *Network) requestRecord(n *Node) {
+	if n.enrEcho != nil || n.ID == net.tab.self.ID {
+		return
+	}
+	n.enrEcho = net.conn.send(n, enrRequestPacket, &enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+}
+
+// handleRecord stores the record sent by n if signed by its key, unless the
+// known one is as recent. The signature itself is verified on decoding.
+func (net *Network) handleRecord(n *Node, r *enr.Record) error {
+	var key enr.Secp256k1
+	if err := r.Load(&key); err != nil {
+		return err
+	}
+	if PubkeyID((*ecdsa.PublicKey)(&key)) != n.ID {
+		return errRecordOwner
+	}
+	if n.record != nil && r.Seq() <= n.record.Seq() {
+		return nil
+	}
+	n.record = r
+	return nil
+}
+
 func (net *Network) checkTopicRegister(data *topicRegister) (*pong, error) {
 	var pongpkt ingressPacket
 	if err := decodePacket(data.Pong, &pongpkt); err != nil {
@@ -17,6 +17,7 @@
 package discv5
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"net"
 	"testing"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/p2p/enr"
 )
 
 func TestNetwork_Lookup(t *testing.T) {
@@ -63,6 +65,78 @@ func TestNetwork_Lookup(t *testing.T) {
 	// TODO: check result nodes are actually closest
 }
 
+func TestNetwork_Record(t *testing.T) {
+	key, other := newkey(), newkey()
+	n := NewNode(PubkeyID(&key.PublicKey), net.IP{10, 0, 2, 99}, 30303, 30303)
+	n.state = known
+
+	local := new(enr.Record)
+	if err := local.Sign(other); err != nil {
+		t.Fatal(err)
+	}
+	transport := &recordTransport{}
+	network := &Network{conn: transport, tab: newTable(PubkeyID(&other.PublicKey), transport.localAddr()), record: local}
+
+	// Record requests are answered with the local record
+	req := &ingressPacket{remoteID: n.ID, ev: enrRequestPacket, hash: []byte{1}, data: &enrRequest{}}
+	if _, err := network.handleQueryEvent(n, enrRequestPacket, req); err != nil {
+		t.Fatalf("failed to handle the request: %v", err)
+	}
+	if len(transport.sent) != 1 || transport.sent[0].(*enrResponse).Record.Seq() != local.Seq() {
+		t.Fatalf("local record not sent: %v", transport.sent)
+	}
+	// Responses are only accepted for pending requests, with records of the node
+	response := func(key *ecdsa.PrivateKey, seq uint64) *ingressPacket {
+		var r enr.Record
+		r.SetSeq(seq)
+		r.Set(enr.TCP(30303))
+		if err := r.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		// Round-trip the packet to check the signature
+		packet, _, err := encodePacket(key, byte(enrResponsePacket), &enrResponse{ReplyTok: n.enrEcho, Record: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkt := new(ingressPacket)
+		if err := decodePacket(packet, pkt); err != nil {
+			t.Fatalf("failed to decode the response: %v", err)
+		}
+		return pkt
+	}
+	if err := network.checkPacket(n, enrResponsePacket, response(key, 1)); err != errNoRequest {
+		t.Fatalf("unsolicited response: error mismatch: have %v, want %v", err, errNoRequest)
+	}
+	network.requestRecord(n)
+	if err := network.checkPacket(n, enrResponsePacket, response(key, 1)); err != nil {
+		t.Fatalf("failed to check the response: %v", err)
+	}
+	if _, err := network.handleQueryEvent(n, enrResponsePacket, response(other, 1)); err != errRecordOwner {
+		t.Fatalf("foreign record: error mismatch: have %v, want %v", err, errRecordOwner)
+	}
+	newer, older := response(key, 5), response(key, 3)
+	for _, pkt := range []*ingressPacket{newer, older} {
+		if _, err := network.handleQueryEvent(n, enrResponsePacket, pkt); err != nil {
+			t.Fatalf("failed to handle the record: %v", err)
+		}
+	}
+	var port enr.TCP
+	if n.Record() != &newer.data.(*enrResponse).Record || n.Record().Load(&port) != nil || port != 30303 {
+		t.Fatalf("newest record not kept: %v", n.Record())
+	}
+}
+
+// recordTransport records the packets sent through the generic send.
+type recordTransport struct {
+	preminedTestnet
+	sent []interface{}
+}
+
+func (tr *recordTransport) send(to *Node, ptype nodeEvent, data interface{}) (hash []byte) {
+	tr.sent = append(tr.sent, data)
+	return []byte{byte(len(tr.sent))}
+}
+
 // This is the test network for the Lookup test.
 // The nodes were obtained by running testnet.mine with a random NodeID as target.
 var lookupTestnet = &preminedTestnet{
@@ -292,7 +366,7 @@ func (tn *preminedTestnet) send(to *Node, ptype nodeEvent, data interface{}) (ha
 	switch ptype {
 	case pingPacket:
 		injectResponse(tn.net, to, pongPacket, &pong{ReplyTok: []byte{1}})
-	case pongPacket:
+	case pongPacket, enrRequestPacket:
 		// ignored
 	case findnodeHashPacket:
 		// current log distance is encoded in port number
@@ -5,21 +5,21 @@ package discv5
 import "strconv"
 
 const (
-	_nodeEvent_name_0 = "invalidEventpingPacketpongPacketfindnodePacketneighborsPacketfindnodeHashPackettopicRegisterPackettopicQueryPackettopicNodesPacket"
+	_nodeEvent_name_0 = "invalidEventpingPacketpongPacketfindnodePacketneighborsPacketfindnodeHashPackettopicRegisterPackettopicQueryPackettopicNodesPacketenrRequestPacketenrResponsePacket"
 	_nodeEvent_name_1 = "pongTimeoutpingTimeoutneighboursTimeout"
 )
 
 var (
-	_nodeEvent_index_0 = [...]uint8{0, 12, 22, 32, 46, 61, 79, 98, 114, 130}
+	_nodeEvent_index_0 = [...]uint8{0, 12, 22, 32, 46, 61, 79, 98, 114, 130, 146, 163}
 	_nodeEvent_index_1 = [...]uint8{0, 11, 22, 39}
 )
 
 func (i nodeEvent) String() string {
 	switch {
-	case 0 <= i && i <= 8:
+	case 0 <= i && i <= 10:
 		return _nodeEvent_name_0[_nodeEvent_index_0[i]:_nodeEvent_index_0[i+1]]
-	case 265 <= i && i <= 267:
-		i -= 265
+	case 267 <= i && i <= 269:
+		i -= 267
 		return _nodeEvent_name_1[_nodeEvent_index_1[i]:_nodeEvent_index_1[i+1]]
 	default:
 		return "nodeEvent(" + strconv.FormatInt(int64(i), 10) + ")"
@@ -0,0 +1,261 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/enr"
+)
+
+var (
+	errNoRoot        = errors.New("no valid root found")
+	errNoEntry       = errors.New("no valid entry found")
+	errLinkInENRTree = errors.New("link in the node subtree")
+	errENRInLinkTree = errors.New("node record in the link subtree")
+)
+
+// Resolver is a DNS resolver able to look up TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+// Config holds the settings of a Client.
+type Config struct {
+	Timeout         time.Duration // Timeout of a DNS lookup
+	RecheckInterval time.Duration // Interval between the checks of the tree roots
+	CacheLimit      int           // Number of entries kept in the cache
+	Resolver        Resolver      // DNS resolver, the system one if nil
+}
+
+// DefaultConfig contains the default settings of a Client.
+var DefaultConfig = Config{
+	Timeout:         5 * time.Second,
+	RecheckInterval: 30 * time.Minute,
+	CacheLimit:      1000,
+}
+
+// Client syncs the node lists published under DNS domains.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache // cache of the entries, keyed by their name
+}
+
+// NewClient creates a client, using the defaults for the unset settings.
+func NewClient(cfg Config) *Client {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultConfig.Timeout
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = DefaultConfig.RecheckInterval
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = DefaultConfig.CacheLimit
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	entries, _ := lru.New(cfg.CacheLimit)
+	return &Client{cfg: cfg, entries: entries}
+}
+
+// RecheckInterval returns the interval between the syncs of the trees.
+func (c *Client) RecheckInterval() time.Duration {
+	return c.cfg.RecheckInterval
+}
+
+// ParseURL checks the URL of a tree, enrtree://<key>@<domain>.
+func ParseURL(url string) error {
+	_, err := parseLink(url)
+	return err
+}
+
+// SyncTree downloads the tree at the given URL, verifying its signature with
+// the key of the URL. The entries known from earlier syncs are not downloaded
+// again.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	loc, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tree URL %q: %v", url, err)
+	}
+	root, err := c.resolveRoot(loc)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tree{root: root, entries: make(map[string]entry)}
+	if err := c.syncSubtree(t, loc.domain, root.eroot, false); err != nil {
+		return nil, err
+	}
+	if err := c.syncSubtree(t, loc.domain, root.lroot, true); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SyncNodes downloads the trees at the given URLs and the trees they link,
+// returning the dialable nodes of all. The trees failing to sync are skipped,
+// the first error being returned along with the nodes of the others.
+func (c *Client) SyncNodes(urls []string) ([]*discover.Node, error) {
+	var (
+		nodes   []*discover.Node
+		failure error
+		seen    = make(map[string]bool)
+		queue   = append([]string{}, urls...)
+	)
+	for len(queue) > 0 {
+		url := queue[0]
+		queue = queue[1:]
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+
+		t, err := c.SyncTree(url)
+		if err != nil {
+			log.Debug("Failed to sync DNS node list", "url", url, "err", err)
+			if failure == nil {
+				failure = err
+			}
+			continue
+		}
+		for _, r := range t.Nodes() {
+			if n, err := nodeFromRecord(r); err == nil {
+				nodes = append(nodes, n)
+			}
+		}
+		queue = append(queue, t.Links()...)
+	}
+	return nodes, failure
+}
+
+// resolveRoot looks up the root of the tree at the location, verifying its
+// signature.
+func (c *Client) resolveRoot(loc *linkEntry) (*rootEntry, error) {
+	txts, err := c.lookupTXT(loc.domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		if !strings.HasPrefix(txt, rootPrefix) {
+			continue
+		}
+		root, err := parseRoot(txt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root at %s: %v", loc.domain, err)
+		}
+		if !root.verifySignature(loc.pubkey) {
+			return nil, errInvalidSig
+		}
+		return root, nil
+	}
+	return nil, errNoRoot
+}
+
+// syncSubtree adds to the tree the entry of the given hash and its children.
+func (c *Client) syncSubtree(t *Tree, domain, hash string, link bool) error {
+	e, err := c.resolveEntry(domain, hash)
+	if err != nil {
+		return err
+	}
+	t.entries[hash] = e
+
+	switch e := e.(type) {
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := c.syncSubtree(t, domain, child, link); err != nil {
+				return err
+			}
+		}
+	case *linkEntry:
+		if !link {
+			return errLinkInENRTree
+		}
+	case *enrEntry:
+		if link {
+			return errENRInLinkTree
+		}
+	}
+	return nil
+}
+
+// resolveEntry looks up the entry of the given hash, checking the hash of
+// its TXT record.
+func (c *Client) resolveEntry(domain, hash string) (entry, error) {
+	name := hash + "." + domain
+	if e, ok := c.entries.Get(name); ok {
+		return e.(entry), nil
+	}
+	txts, err := c.lookupTXT(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		if !matchesHash(txt, hash) {
+			continue
+		}
+		e, err := parseEntry(txt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry at %s: %v", name, err)
+		}
+		c.entries.Add(name, e)
+		return e, nil
+	}
+	return nil, fmt.Errorf("%v at %s", errNoEntry, name)
+}
+
+func (c *Client) lookupTXT(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	return c.cfg.Resolver.LookupTXT(ctx, name)
+}
+
+// nodeFromRecord returns the node of a record holding a TCP endpoint.
+func nodeFromRecord(r *enr.Record) (*discover.Node, error) {
+	var (
+		key enr.Secp256k1
+		ip4 enr.IP4
+		ip6 enr.IP6
+		tcp enr.TCP
+		udp enr.UDP
+		ip  net.IP
+	)
+	if err := r.Load(&key); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&ip4); err == nil {
+		ip = net.IP(ip4)
+	} else if err := r.Load(&ip6); err == nil {
+		ip = net.IP(ip6)
+	} else {
+		return nil, err
+	}
+	if err := r.Load(&tcp); err != nil {
+		return nil, err
+	}
+	if err := r.Load(&udp); err != nil {
+		udp = enr.UDP(tcp)
+	}
+	return discover.NewNode(discover.PubkeyID((*ecdsa.PublicKey)(&key)), ip, uint16(udp), uint16(tcp)), nil
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/enr"
+)
+
+// mapResolver serves the TXT records of a map.
+type mapResolver map[string]string
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if txt, ok := mr[name]; ok {
+		return []string{txt}, nil
+	}
+	return nil, fmt.Errorf("no TXT record at %s", name)
+}
+
+func (mr mapResolver) add(records map[string]string) {
+	for name, txt := range records {
+		mr[name] = txt
+	}
+}
+
+func testKey(i int) *ecdsa.PrivateKey {
+	key, _ := crypto.ToECDSA(crypto.Keccak256([]byte(fmt.Sprintf("dnsdisc-%d", i))))
+	return key
+}
+
+// testNodes returns signed records of nodes n to n+count-1.
+func testNodes(t *testing.T, n, count int) []*enr.Record {
+	var nodes []*enr.Record
+	for i := n; i < n+count; i++ {
+		r := new(enr.Record)
+		r.Set(enr.IP4(net.IP{10, 0, byte(i >> 8), byte(i)}))
+		r.Set(enr.TCP(30303))
+		r.Set(enr.UDP(30301))
+		if err := r.Sign(testKey(i)); err != nil {
+			t.Fatalf("failed to sign the record %d: %v", i, err)
+		}
+		nodes = append(nodes, r)
+	}
+	return nodes
+}
+
+// publish signs the tree with the key and adds it to the resolver.
+func publish(t *testing.T, mr mapResolver, tree *Tree, key *ecdsa.PrivateKey, domain string) string {
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		t.Fatalf("failed to sign the tree: %v", err)
+	}
+	mr.add(tree.ToTXT(domain))
+	return url
+}
+
+func nodeIDs(nodes []*discover.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID.String()
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Tests that a published tree is synced back with all its nodes and links.
+func TestSyncTree(t *testing.T) {
+	var (
+		mr    = make(mapResolver)
+		nodes = testNodes(t, 0, 40)
+		link  = (&linkEntry{domain: "other.example.org", pubkey: &testKey(100).PublicKey}).url()
+	)
+	tree, err := MakeTree(3, nodes, []string{link})
+	if err != nil {
+		t.Fatalf("failed to make the tree: %v", err)
+	}
+	for name, txt := range tree.ToTXT("nodes.example.org") {
+		if strings.HasPrefix(txt, branchPrefix) && len(txt) > 370 {
+			t.Errorf("branch at %s too long: %d bytes", name, len(txt))
+		}
+	}
+	url := publish(t, mr, tree, testKey(99), "nodes.example.org")
+
+	client := NewClient(Config{Resolver: mr})
+	synced, err := client.SyncTree(url)
+	if err != nil {
+		t.Fatalf("failed to sync the tree: %v", err)
+	}
+	if synced.Seq() != 3 {
+		t.Errorf("sequence number mismatch: have %d, want %d", synced.Seq(), 3)
+	}
+	if len(synced.Nodes()) != len(nodes) {
+		t.Errorf("node count mismatch: have %d, want %d", len(synced.Nodes()), len(nodes))
+	}
+	if links := synced.Links(); len(links) != 1 || links[0] != link {
+		t.Errorf("links mismatch: have %v, want %v", links, []string{link})
+	}
+	// The entries are cached once synced
+	for name := range mr {
+		if name != "nodes.example.org" {
+			delete(mr, name)
+		}
+	}
+	if _, err := client.SyncTree(url); err != nil {
+		t.Errorf("failed to sync the cached tree: %v", err)
+	}
+}
+
+// Tests that the trees not signed by the key of their URL, or whose entries do
+// not match their hash, are rejected.
+func TestSyncTreeInvalid(t *testing.T) {
+	mr := make(mapResolver)
+	tree, err := MakeTree(1, testNodes(t, 0, 3), nil)
+	if err != nil {
+		t.Fatalf("failed to make the tree: %v", err)
+	}
+	publish(t, mr, tree, testKey(98), "nodes.example.org")
+	url := (&linkEntry{domain: "nodes.example.org", pubkey: &testKey(99).PublicKey}).url()
+	if _, err := NewClient(Config{Resolver: mr}).SyncTree(url); err != errInvalidSig {
+		t.Errorf("foreign signature: error mismatch: have %v, want %v", err, errInvalidSig)
+	}
+	url = publish(t, mr, tree, testKey(99), "nodes.example.org")
+	for name, txt := range mr {
+		if strings.HasPrefix(txt, enrPrefix) {
+			mr[name] = (&enrEntry{node: testNodes(t, 10, 1)[0]}).String()
+			break
+		}
+	}
+	if _, err := NewClient(Config{Resolver: mr}).SyncTree(url); err == nil || !strings.Contains(err.Error(), errNoEntry.Error()) {
+		t.Errorf("tampered entry: error mismatch: have %v, want %v", err, errNoEntry)
+	}
+}
+
+// Tests that the nodes of the linked trees are synced too, the trees failing
+// to sync being skipped.
+func TestSyncNodes(t *testing.T) {
+	var (
+		mr      = make(mapResolver)
+		missing = (&linkEntry{domain: "missing.example.org", pubkey: &testKey(97).PublicKey}).url()
+	)
+	linked, err := MakeTree(1, testNodes(t, 20, 5), []string{missing})
+	if err != nil {
+		t.Fatalf("failed to make the linked tree: %v", err)
+	}
+	linkedURL := publish(t, mr, linked, testKey(98), "linked.example.org")
+
+	tree, err := MakeTree(1, testNodes(t, 0, 5), []string{linkedURL})
+	if err != nil {
+		t.Fatalf("failed to make the tree: %v", err)
+	}
+	url := publish(t, mr, tree, testKey(99), "nodes.example.org")
+
+	nodes, err := NewClient(Config{Resolver: mr}).SyncNodes([]string{url})
+	if err == nil {
+		t.Error("missing tree not reported")
+	}
+	var want []*discover.Node
+	for _, r := range append(testNodes(t, 0, 5), testNodes(t, 20, 5)...) {
+		n, err := nodeFromRecord(r)
+		if err != nil {
+			t.Fatalf("failed to convert the record: %v", err)
+		}
+		want = append(want, n)
+	}
+	if have, want := nodeIDs(nodes), nodeIDs(want); strings.Join(have, ",") != strings.Join(want, ",") {
+		t.Errorf("nodes mismatch: have %v, want %v", have, want)
+	}
+	if nodes[0].TCP != 30303 || nodes[0].UDP != 30301 {
+		t.Errorf("endpoint mismatch: have %v", nodes[0])
+	}
+}
+
+// Tests that the malformed entries are rejected.
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		txt string
+		err error
+	}{
+		{"enrtree-branch:", nil},
+		{"enrtree-branch:AAAAAAAAAAAAAAAAAAAA", errInvalidChild},
+		{"enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA,AAAA", errInvalidChild},
+		{"enrtree://AM5FCQLWIZX2QFPNJAP7VUERCCRNGRHWZG3YYHIUV7BVDQ5FDPRT2@", errSyntax},
+		{"enrtree://nodes.example.org", errNoPubkey},
+		{"enrtree://AAAA@nodes.example.org", errBadPubkey},
+		{"enr:-HW4QES8QIeXTYlDzbfr1WEzE-XKY4f8gJFJzjJL-9D7TC9lJb4Z3JPRRz1lP4pL_N_QpT6rGQjAU9Apnc-C1iMP36OAgmlkgnY0iXNlY3AyNTZrMaED5IdwfMxdmR8W37HqSFdQLjDkIwBd4Q_MjxgZifgKSdM", errInvalidENR},
+		{"enrtree-foo:", errUnknownEntry},
+	}
+	for i, tt := range tests {
+		if _, err := parseEntry(tt.txt); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
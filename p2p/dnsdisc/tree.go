@@ -0,0 +1,359 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements the node lists published as signed trees of DNS
+// TXT records, as specified by EIP-1459.
+package dnsdisc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/p2p/enr"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+const (
+	rootPrefix   = "enrtree-root:v1"
+	linkPrefix   = "enrtree://"
+	branchPrefix = "enrtree-branch:"
+	enrPrefix    = "enr:"
+
+	// Number of children of a branch, keeping its TXT record below the 370
+	// bytes fitting in a single DNS packet.
+	maxChildren = 370 / (hashLength + 1)
+	hashLength  = 26 // base32 encoding of the 16-byte hashes
+)
+
+var (
+	b32format = base32.StdEncoding.WithPadding(base32.NoPadding)
+	b64format = base64.RawURLEncoding
+)
+
+var (
+	errUnknownEntry = errors.New("unknown entry type")
+	errNoPubkey     = errors.New("missing public key")
+	errBadPubkey    = errors.New("invalid public key")
+	errInvalidENR   = errors.New("invalid node record")
+	errInvalidChild = errors.New("invalid child hash")
+	errInvalidSig   = errors.New("invalid root signature")
+	errSyntax       = errors.New("invalid syntax")
+)
+
+// Tree is a node list, as the entries published under a DNS domain.
+type Tree struct {
+	root    *rootEntry
+	entries map[string]entry
+}
+
+type (
+	entry interface {
+		fmt.Stringer
+	}
+	rootEntry struct {
+		eroot string // hash of the root of the node subtree
+		lroot string // hash of the root of the link subtree
+		seq   uint
+		sig   []byte
+	}
+	branchEntry struct {
+		children []string
+	}
+	enrEntry struct {
+		node *enr.Record
+	}
+	linkEntry struct {
+		str    string
+		domain string
+		pubkey *ecdsa.PublicKey
+	}
+)
+
+// MakeTree creates a tree of the given nodes and links to other trees, to be
+// signed before publishing.
+func MakeTree(seq uint, nodes []*enr.Record, links []string) (*Tree, error) {
+	records := make([]entry, 0, len(nodes))
+	for _, n := range nodes {
+		if !n.Signed() {
+			return nil, errInvalidENR
+		}
+		records = append(records, &enrEntry{node: n})
+	}
+	linkEntries := make([]entry, 0, len(links))
+	for _, l := range links {
+		le, err := parseLink(l)
+		if err != nil {
+			return nil, err
+		}
+		linkEntries = append(linkEntries, le)
+	}
+	t := &Tree{entries: make(map[string]entry)}
+	eroot := t.build(records)
+	t.entries[subdomain(eroot)] = eroot
+	lroot := t.build(linkEntries)
+	t.entries[subdomain(lroot)] = lroot
+	t.root = &rootEntry{eroot: subdomain(eroot), lroot: subdomain(lroot), seq: seq}
+	return t, nil
+}
+
+// build adds the entries to the tree under a subtree of branches, returning
+// the root of the subtree.
+func (t *Tree) build(entries []entry) entry {
+	if len(entries) == 1 {
+		t.entries[subdomain(entries[0])] = entries[0]
+		return entries[0]
+	}
+	if len(entries) <= maxChildren {
+		hashes := make([]string, len(entries))
+		for i, e := range entries {
+			hashes[i] = subdomain(e)
+			t.entries[hashes[i]] = e
+		}
+		sort.Strings(hashes)
+		return &branchEntry{hashes}
+	}
+	var subtrees []entry
+	for len(entries) > 0 {
+		n := maxChildren
+		if len(entries) < n {
+			n = len(entries)
+		}
+		sub := t.build(entries[:n])
+		entries = entries[n:]
+		subtrees = append(subtrees, sub)
+		t.entries[subdomain(sub)] = sub
+	}
+	return t.build(subtrees)
+}
+
+// Sign signs the tree with the given key, returning the URL of the tree once
+// published under the given domain.
+func (t *Tree) Sign(key *ecdsa.PrivateKey, domain string) (string, error) {
+	root := *t.root
+	sig, err := crypto.Sign(root.sigHash(), key)
+	if err != nil {
+		return "", err
+	}
+	root.sig = sig
+	t.root = &root
+	return (&linkEntry{domain: domain, pubkey: &key.PublicKey}).url(), nil
+}
+
+// Seq returns the sequence number of the tree.
+func (t *Tree) Seq() uint {
+	return t.root.seq
+}
+
+// Nodes returns the node records of the tree.
+func (t *Tree) Nodes() []*enr.Record {
+	var nodes []*enr.Record
+	for _, e := range t.entries {
+		if ee, ok := e.(*enrEntry); ok {
+			nodes = append(nodes, ee.node)
+		}
+	}
+	return nodes
+}
+
+// Links returns the URLs of the trees linked by the tree.
+func (t *Tree) Links() []string {
+	var links []string
+	for _, e := range t.entries {
+		if le, ok := e.(*linkEntry); ok {
+			links = append(links, le.str)
+		}
+	}
+	return links
+}
+
+// ToTXT returns the TXT records of the tree, keyed by their name under the
+// given domain.
+func (t *Tree) ToTXT(domain string) map[string]string {
+	records := map[string]string{domain: t.root.String()}
+	for hash, e := range t.entries {
+		name := hash
+		if domain != "" {
+			name = hash + "." + domain
+		}
+		records[name] = e.String()
+	}
+	return records
+}
+
+// subdomain returns the name of the TXT record of the entry.
+func subdomain(e entry) string {
+	h := crypto.Keccak256([]byte(e.String()))
+	return b32format.EncodeToString(h[:16])
+}
+
+func (e *rootEntry) String() string {
+	return fmt.Sprintf(rootPrefix+" e=%s l=%s seq=%d sig=%s", e.eroot, e.lroot, e.seq, b64format.EncodeToString(e.sig))
+}
+
+func (e *rootEntry) sigHash() []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf(rootPrefix+" e=%s l=%s seq=%d", e.eroot, e.lroot, e.seq)))
+}
+
+func (e *rootEntry) verifySignature(pubkey *ecdsa.PublicKey) bool {
+	if len(e.sig) != 65 {
+		return false
+	}
+	return crypto.VerifySignature(crypto.FromECDSAPub(pubkey), e.sigHash(), e.sig[:64])
+}
+
+func (e *branchEntry) String() string {
+	return branchPrefix + strings.Join(e.children, ",")
+}
+
+func (e *enrEntry) String() string {
+	blob, err := rlp.EncodeToBytes(e.node)
+	if err != nil {
+		panic(fmt.Errorf("dnsdisc: can't encode the record: %v", err))
+	}
+	return enrPrefix + b64format.EncodeToString(blob)
+}
+
+func (e *linkEntry) String() string {
+	return e.url()
+}
+
+func (e *linkEntry) url() string {
+	return linkPrefix + b32format.EncodeToString(crypto.CompressPubkey(e.pubkey)) + "@" + e.domain
+}
+
+// parseRoot parses the TXT record at the domain of a tree.
+func parseRoot(txt string) (*rootEntry, error) {
+	var (
+		e   rootEntry
+		sig string
+	)
+	fields := strings.Fields(txt)
+	if len(fields) != 5 || fields[0] != rootPrefix {
+		return nil, errSyntax
+	}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, errSyntax
+		}
+		switch kv[0] {
+		case "e":
+			e.eroot = kv[1]
+		case "l":
+			e.lroot = kv[1]
+		case "seq":
+			seq, err := strconv.ParseUint(kv[1], 10, 32)
+			if err != nil {
+				return nil, errSyntax
+			}
+			e.seq = uint(seq)
+		case "sig":
+			sig = kv[1]
+		default:
+			return nil, errSyntax
+		}
+	}
+	if !isValidHash(e.eroot) || !isValidHash(e.lroot) {
+		return nil, errInvalidChild
+	}
+	var err error
+	if e.sig, err = b64format.DecodeString(sig); err != nil || len(e.sig) != 65 {
+		return nil, errInvalidSig
+	}
+	return &e, nil
+}
+
+// parseEntry parses the TXT record of a tree entry.
+func parseEntry(txt string) (entry, error) {
+	switch {
+	case strings.HasPrefix(txt, linkPrefix):
+		return parseLink(txt)
+	case strings.HasPrefix(txt, branchPrefix):
+		return parseBranch(txt[len(branchPrefix):])
+	case strings.HasPrefix(txt, enrPrefix):
+		return parseENR(txt[len(enrPrefix):])
+	default:
+		return nil, errUnknownEntry
+	}
+}
+
+// parseLink parses the URL of a tree, enrtree://<key>@<domain>.
+func parseLink(url string) (*linkEntry, error) {
+	if !strings.HasPrefix(url, linkPrefix) {
+		return nil, errSyntax
+	}
+	pos := strings.IndexByte(url, '@')
+	if pos == -1 {
+		return nil, errNoPubkey
+	}
+	keystring, domain := url[len(linkPrefix):pos], url[pos+1:]
+	if domain == "" {
+		return nil, errSyntax
+	}
+	keybytes, err := b32format.DecodeString(keystring)
+	if err != nil {
+		return nil, errBadPubkey
+	}
+	key, err := crypto.DecompressPubkey(keybytes)
+	if err != nil {
+		return nil, errBadPubkey
+	}
+	return &linkEntry{str: url, domain: domain, pubkey: key}, nil
+}
+
+func parseBranch(list string) (entry, error) {
+	var hashes []string
+	if list != "" {
+		hashes = strings.Split(list, ",")
+	}
+	for _, h := range hashes {
+		if !isValidHash(h) {
+			return nil, errInvalidChild
+		}
+	}
+	return &branchEntry{hashes}, nil
+}
+
+func parseENR(enc string) (entry, error) {
+	blob, err := b64format.DecodeString(enc)
+	if err != nil {
+		return nil, errInvalidENR
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(blob, &r); err != nil {
+		return nil, errInvalidENR
+	}
+	return &enrEntry{node: &r}, nil
+}
+
+func isValidHash(h string) bool {
+	dec, err := b32format.DecodeString(h)
+	return err == nil && len(dec) == 16
+}
+
+// matchesHash reports whether the TXT record is the one of the given hash.
+func matchesHash(txt, hash string) bool {
+	h := crypto.Keccak256([]byte(txt))
+	dec, err := b32format.DecodeString(hash)
+	return err == nil && bytes.Equal(dec, h[:16])
+}
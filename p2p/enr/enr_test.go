@@ -87,6 +87,20 @@ func TestGetSetDiscPort(t *testing.T) {
 	assert.Equal(t, port, port2)
 }
 
+// TestGetSetTCPUDP tests encoding/decoding and setting/getting of the TCP and UDP keys.
+func TestGetSetTCPUDP(t *testing.T) {
+	var r Record
+	r.Set(TCP(30303))
+	r.Set(UDP(30301))
+
+	var tcp TCP
+	var udp UDP
+	require.NoError(t, r.Load(&tcp))
+	require.NoError(t, r.Load(&udp))
+	assert.Equal(t, TCP(30303), tcp)
+	assert.Equal(t, UDP(30301), udp)
+}
+
 // TestGetSetSecp256k1 tests encoding/decoding and setting/getting of the Secp256k1 key.
 func TestGetSetSecp256k1(t *testing.T) {
 	var r Record
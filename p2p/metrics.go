@@ -27,6 +27,7 @@ import (
 var (
 	ingressConnectMeter = metrics.NewRegisteredMeter("p2p/InboundConnects", nil)
 	ingressTrafficMeter = metrics.NewRegisteredMeter("p2p/InboundTraffic", nil)
+	ingressRejectMeter  = metrics.NewRegisteredMeter("p2p/InboundRejects", nil)
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/OutboundConnects", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter("p2p/OutboundTraffic", nil)
 )
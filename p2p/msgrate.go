@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"time"
+)
+
+// msgRateAbuse is the number of seconds of tokens a peer may ask beyond its
+// limit before being deemed abusive.
+const msgRateAbuse = 10
+
+// ErrMsgRateAbuse is returned by MsgRateLimiter.Allow for the peers asking
+// persistently more than their limit.
+var ErrMsgRateAbuse = errors.New("message rate abuse")
+
+// MsgRateLimiter limits the rate of the messages, or of the items they carry,
+// received from a peer, with a token bucket per message code holding up to a
+// second of tokens. The tokens asked beyond the limit accumulate as an excess
+// draining at the same rate, so that a peer sending more than twice its rate
+// ends up abusive. It is not safe for concurrent use.
+type MsgRateLimiter struct {
+	buckets map[uint64]*msgBucket
+}
+
+type msgBucket struct {
+	rate      float64
+	allowance float64
+	excess    float64
+	last      time.Time
+}
+
+// NewMsgRateLimiter creates a limiter allowing, for each message code of the
+// map, the given number of tokens per second.
+func NewMsgRateLimiter(rates map[uint64]float64, now time.Time) *MsgRateLimiter {
+	l := &MsgRateLimiter{buckets: make(map[uint64]*msgBucket, len(rates))}
+	for code, rate := range rates {
+		l.buckets[code] = &msgBucket{rate: rate, allowance: rate, last: now}
+	}
+	return l
+}
+
+// Allow takes n tokens from the bucket of the message code at the given time,
+// returning how many of them are within the limit, or ErrMsgRateAbuse once the
+// excess of the peer is too high. The codes without a limit are always allowed.
+func (l *MsgRateLimiter) Allow(code uint64, n int, now time.Time) (int, error) {
+	b := l.buckets[code]
+	if b == nil {
+		return n, nil
+	}
+	refill := now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+
+	b.allowance += refill
+	if b.allowance > b.rate {
+		b.allowance = b.rate
+	}
+	b.excess -= refill
+	if b.excess < 0 {
+		b.excess = 0
+	}
+	allowed := n
+	if float64(n) > b.allowance {
+		allowed = int(b.allowance)
+	}
+	b.allowance -= float64(allowed)
+	b.excess += float64(n - allowed)
+
+	if b.excess > msgRateAbuse*b.rate {
+		return allowed, ErrMsgRateAbuse
+	}
+	return allowed, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMsgRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewMsgRateLimiter(map[uint64]float64{1: 10}, now)
+	if allowed, _ := limiter.Allow(1, 15, now); allowed != 10 {
+		t.Errorf("burst mismatch: have %d, want 10", allowed)
+	}
+	if allowed, _ := limiter.Allow(1, 5, now.Add(100*time.Millisecond)); allowed != 1 {
+		t.Errorf("refill mismatch: have %d, want 1", allowed)
+	}
+	// A long idle time doesn't allow more than a second of tokens
+	if allowed, _ := limiter.Allow(1, 50, now.Add(time.Hour)); allowed != 10 {
+		t.Errorf("idle refill mismatch: have %d, want 10", allowed)
+	}
+	// Codes without a limit are always allowed
+	if allowed, err := limiter.Allow(2, 1000, now); allowed != 1000 || err != nil {
+		t.Errorf("unlimited code: have %d allowed, err %v", allowed, err)
+	}
+}
+
+// Tests that the peers sending up to twice their rate are throttled, and the
+// ones sending more are deemed abusive.
+func TestMsgRateLimiterAbuse(t *testing.T) {
+	for _, tt := range []struct {
+		perSecond int
+		abuse     bool
+	}{
+		{perSecond: 10},
+		{perSecond: 20},
+		{perSecond: 30, abuse: true},
+	} {
+		now := time.Now()
+		limiter := NewMsgRateLimiter(map[uint64]float64{1: 10}, now)
+
+		var err error
+		for i := 0; i < 60*tt.perSecond && err == nil; i++ {
+			now = now.Add(time.Second / time.Duration(tt.perSecond))
+			_, err = limiter.Allow(1, 1, now)
+		}
+		if (err == ErrMsgRateAbuse) != tt.abuse {
+			t.Errorf("%d messages per second: abuse mismatch: have %v, want %v", tt.perSecond, err, tt.abuse)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/jackpal/go-nat-pmp"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p/netutil"
 )
 
 // An implementation of nat.Interface can map local ports to ports
@@ -55,16 +56,24 @@ type Interface interface {
 //
 //     "" or "none"         return nil
 //     "extip:77.12.33.4"   will assume the local machine is reachable on the given IP
-//     "any"                uses the first auto-detected mechanism
+//     "any"                uses the first auto-detected mechanism, or STUN to find the external IP
 //     "upnp"               uses the Universal Plug and Play protocol
 //     "pmp"                uses NAT-PMP with an auto-detected gateway address
 //     "pmp:192.168.0.1"    uses NAT-PMP with the given gateway address
+//     "stun"               finds the external IP with the default STUN server
+//     "stun:host:port"     finds the external IP with the given STUN server
 func Parse(spec string) (Interface, error) {
 	var (
 		parts = strings.SplitN(spec, ":", 2)
 		mech  = strings.ToLower(parts[0])
 		ip    net.IP
 	)
+	if mech == "stun" {
+		if len(parts) > 1 {
+			return STUN(parts[1]), nil
+		}
+		return STUN(DefaultSTUNServer), nil
+	}
 	if len(parts) > 1 {
 		ip = net.ParseIP(parts[1])
 		if ip == nil {
@@ -93,9 +102,12 @@ func Parse(spec string) (Interface, error) {
 const (
 	mapTimeout        = 20 * time.Minute
 	mapUpdateInterval = 15 * time.Minute
+	mapRetryInterval  = time.Minute
 )
 
 // Map adds a port mapping on m and keeps it alive until c is closed.
+// The mapping is renewed before its lease ends, and retried sooner
+// when the gateway fails to add it, e.g. while rebooting.
 // This function is typically invoked in its own goroutine.
 func Map(m Interface, c chan struct{}, protocol string, extport, intport int, name string) {
 	log := log.New("proto", protocol, "extport", extport, "intport", intport, "interface", m)
@@ -107,6 +119,7 @@ func Map(m Interface, c chan struct{}, protocol string, extport, intport int, na
 	}()
 	if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
 		log.Debug("Couldn't add port mapping", "err", err)
+		refresh.Reset(mapRetryInterval)
 	} else {
 		log.Info("Mapped network port")
 	}
@@ -120,8 +133,10 @@ func Map(m Interface, c chan struct{}, protocol string, extport, intport int, na
 			log.Trace("Refreshing port mapping")
 			if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
 				log.Debug("Couldn't add port mapping", "err", err)
+				refresh.Reset(mapRetryInterval)
+			} else {
+				refresh.Reset(mapUpdateInterval)
 			}
-			refresh.Reset(mapUpdateInterval)
 		}
 	}
 }
@@ -146,11 +161,13 @@ func (extIP) AddMapping(string, int, int, string, time.Duration) error { return
 func (extIP) DeleteMapping(string, int, int) error                     { return nil }
 
 // Any returns a port mapper that tries to discover any supported
-// mechanism on the local network.
+// mechanism on the local network. The external IP is asked to the
+// default STUN server if no router is found, or if the router is
+// itself behind a NAT.
 func Any() Interface {
 	// TODO: attempt to discover whether the local machine has an
 	// Internet-class address. Return ExtIP in this case.
-	return startautodisc("UPnP or NAT-PMP", func() Interface {
+	ad := startautodisc("UPnP or NAT-PMP", func() Interface {
 		found := make(chan Interface, 2)
 		go func() { found <- discoverUPnP() }()
 		go func() { found <- discoverPMP() }()
@@ -161,6 +178,8 @@ func Any() Interface {
 		}
 		return nil
 	})
+	ad.(*autodisc).fallback = STUN(DefaultSTUNServer)
+	return ad
 }
 
 // UPnP returns a port mapper that uses UPnP. It will attempt to
@@ -193,6 +212,8 @@ type autodisc struct {
 
 	mu    sync.Mutex
 	found Interface
+
+	fallback Interface // asked for the external IP if the discovered one isn't
 }
 
 func startautodisc(what string, doit func() Interface) Interface {
@@ -215,10 +236,19 @@ func (n *autodisc) DeleteMapping(protocol string, extport, intport int) error {
 }
 
 func (n *autodisc) ExternalIP() (net.IP, error) {
-	if err := n.wait(); err != nil {
-		return nil, err
+	var ip net.IP
+	err := n.wait()
+	if err == nil {
+		if ip, err = n.found.ExternalIP(); err == nil && !netutil.IsLAN(ip) {
+			return ip, nil
+		}
+	}
+	if n.fallback != nil {
+		if ext, ferr := n.fallback.ExternalIP(); ferr == nil {
+			return ext, nil
+		}
 	}
-	return n.found.ExternalIP()
+	return ip, err
 }
 
 func (n *autodisc) String() string {
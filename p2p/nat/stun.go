@@ -0,0 +1,150 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultSTUNServer is the STUN server asked for the external IP when none is
+// given.
+const DefaultSTUNServer = "stun.l.google.com:19302"
+
+const (
+	stunTimeout       = 3 * time.Second
+	stunMagicCookie   = 0x2112A442
+	stunBindingReq    = 0x0001
+	stunBindingResp   = 0x0101
+	stunMappedAddr    = 0x0001
+	stunXorMappedAddr = 0x0020
+)
+
+var errNoSTUNAddress = errors.New("no mapped address in STUN response")
+
+// STUN returns a port mapper that asks the given STUN server (RFC 5389), as
+// host:port, the address the packets of the local machine come from. It can't
+// map ports, which must be mapped manually or be reachable already.
+func STUN(server string) Interface {
+	return stun(server)
+}
+
+type stun string
+
+func (s stun) String() string { return fmt.Sprintf("STUN(%s)", string(s)) }
+
+// These do nothing.
+func (stun) AddMapping(string, int, int, string, time.Duration) error { return nil }
+func (stun) DeleteMapping(string, int, int) error                     { return nil }
+
+// ExternalIP sends a binding request to the STUN server, returning the IP of
+// the address mapped in the response.
+func (s stun) ExternalIP() (net.IP, error) {
+	conn, err := net.Dial("udp", string(s))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:], stunBindingReq)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	if _, err := rand.Read(req[8:20]); err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(stunTimeout))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1280)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		resp := buf[:n]
+		if len(resp) < 20 || binary.BigEndian.Uint16(resp[0:]) != stunBindingResp || !bytes.Equal(resp[4:20], req[4:20]) {
+			continue // not the response to the request
+		}
+		return parseSTUNResponse(resp)
+	}
+}
+
+// parseSTUNResponse extracts the mapped IP of a binding response, preferring
+// the XOR-MAPPED-ADDRESS attribute over the legacy MAPPED-ADDRESS one.
+func parseSTUNResponse(resp []byte) (net.IP, error) {
+	var mapped net.IP
+
+	attrs := resp[20:]
+	if length := int(binary.BigEndian.Uint16(resp[2:])); length < len(attrs) {
+		attrs = attrs[:length]
+	}
+	for len(attrs) >= 4 {
+		typ, length := binary.BigEndian.Uint16(attrs[0:]), int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+length {
+			break
+		}
+		value := attrs[4 : 4+length]
+		switch typ {
+		case stunXorMappedAddr:
+			if ip := stunAddress(value); ip != nil {
+				// The address is XOR-ed with the magic cookie and the transaction ID
+				for i := range ip {
+					ip[i] ^= resp[4+i]
+				}
+				return ip, nil
+			}
+		case stunMappedAddr:
+			mapped = stunAddress(value)
+		}
+		// The attributes are padded to a multiple of 4 bytes
+		if next := 4 + (length+3)/4*4; next < len(attrs) {
+			attrs = attrs[next:]
+		} else {
+			break
+		}
+	}
+	if mapped == nil {
+		return nil, errNoSTUNAddress
+	}
+	return mapped, nil
+}
+
+// stunAddress returns a copy of the IP of an address attribute, or nil if the
+// attribute is malformed.
+func stunAddress(value []byte) net.IP {
+	if len(value) < 4 {
+		return nil
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+size {
+		return nil
+	}
+	return append(net.IP{}, value[4:4+size]...)
+}
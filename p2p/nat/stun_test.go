@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// serveSTUN answers a binding request with the given attributes, the mapped
+// address of the XOR-MAPPED-ADDRESS ones being XOR-ed by the server.
+func serveSTUN(t *testing.T, conn *net.UDPConn, attrs func(req []byte) []byte) {
+	buf := make([]byte, 1280)
+	n, addr, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Errorf("failed to read request: %v", err)
+		return
+	}
+	req := buf[:n]
+	if n != 20 || binary.BigEndian.Uint16(req) != stunBindingReq || binary.BigEndian.Uint32(req[4:]) != stunMagicCookie {
+		t.Errorf("invalid request: %x", req)
+		return
+	}
+	body := attrs(req)
+	resp := make([]byte, 20, 20+len(body))
+	binary.BigEndian.PutUint16(resp, stunBindingResp)
+	binary.BigEndian.PutUint16(resp[2:], uint16(len(body)))
+	copy(resp[4:], req[4:20])
+	conn.WriteToUDP(append(resp, body...), addr)
+}
+
+func stunAttr(typ uint16, family byte, port uint16, ip net.IP) []byte {
+	attr := make([]byte, 8, 8+len(ip))
+	binary.BigEndian.PutUint16(attr, typ)
+	binary.BigEndian.PutUint16(attr[2:], uint16(4+len(ip)))
+	attr[5] = family
+	binary.BigEndian.PutUint16(attr[6:], port)
+	return append(attr, ip...)
+}
+
+func TestSTUN(t *testing.T) {
+	xored := func(ip net.IP, req []byte) net.IP {
+		x := append(net.IP{}, ip...)
+		for i := range x {
+			x[i] ^= req[4+i]
+		}
+		return x
+	}
+	tests := []struct {
+		attrs func(req []byte) []byte
+		want  net.IP
+	}{
+		// XOR-MAPPED-ADDRESS is preferred over MAPPED-ADDRESS
+		{
+			attrs: func(req []byte) []byte {
+				mapped := stunAttr(stunMappedAddr, 0x01, 30303, net.IP{9, 9, 9, 9})
+				return append(mapped, stunAttr(stunXorMappedAddr, 0x01, 30303, xored(net.IP{33, 44, 55, 66}, req))...)
+			},
+			want: net.IP{33, 44, 55, 66},
+		},
+		{
+			attrs: func(req []byte) []byte {
+				return stunAttr(stunXorMappedAddr, 0x02, 30303, xored(net.ParseIP("2001:db8::1"), req))
+			},
+			want: net.ParseIP("2001:db8::1"),
+		},
+		{
+			attrs: func(req []byte) []byte {
+				return stunAttr(stunMappedAddr, 0x01, 30303, net.IP{33, 44, 55, 66})
+			},
+			want: net.IP{33, 44, 55, 66},
+		},
+		{
+			attrs: func(req []byte) []byte { return nil },
+		},
+	}
+	for i, tt := range tests {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		go serveSTUN(t, conn, tt.attrs)
+
+		ip, err := STUN(conn.LocalAddr().String()).ExternalIP()
+		conn.Close()
+		if tt.want == nil {
+			if err != errNoSTUNAddress {
+				t.Errorf("test %d: error mismatch: have %v, want %v", i, err, errNoSTUNAddress)
+			}
+			continue
+		}
+		if err != nil || !ip.Equal(tt.want) {
+			t.Errorf("test %d: have %v, err %v, want %v", i, ip, err, tt.want)
+		}
+	}
+}
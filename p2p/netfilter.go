@@ -0,0 +1,241 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/netutil"
+)
+
+// netFilterRecheck is the interval between the checks of the net filter file
+// for modifications.
+const netFilterRecheck = 10 * time.Second
+
+var (
+	errDeniedIP          = errors.New("denied by the net filter")
+	errTooManyFromIP     = errors.New("too many connections from the IP")
+	errTooManyFromSubnet = errors.New("too many connections from the subnet")
+)
+
+// inboundLimiter counts the inbound connections per IP address and per /24
+// subnet, from their acceptance until they are closed, and filters them with
+// the networks allowed and denied by the net filter file. The connections of
+// the allowed networks and of the trusted nodes bypass the limits.
+type inboundLimiter struct {
+	ips     *netutil.DistinctNetSet // nil if unlimited
+	subnets *netutil.DistinctNetSet // nil if unlimited
+	trusted map[string]bool
+
+	allow *netutil.Netlist
+	deny  *netutil.Netlist
+	mu    sync.Mutex
+}
+
+func newInboundLimiter(perIP, perSubnet int, trusted []*discover.Node) *inboundLimiter {
+	l := new(inboundLimiter)
+	if perIP > 0 {
+		l.ips = &netutil.DistinctNetSet{Subnet: 128, Limit: uint(perIP)}
+	}
+	if perSubnet > 0 {
+		l.subnets = &netutil.DistinctNetSet{Subnet: 24, Limit: uint(perSubnet)}
+	}
+	l.setTrusted(trusted)
+	return l
+}
+
+// setTrusted replaces the trusted nodes, whose IPs bypass the limits.
+func (l *inboundLimiter) setTrusted(trusted []*discover.Node) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.trusted = make(map[string]bool, len(trusted))
+	for _, n := range trusted {
+		if n.IP != nil {
+			l.trusted[n.IP.String()] = true
+		}
+	}
+}
+
+// setFilter replaces the allowed and denied networks.
+func (l *inboundLimiter) setFilter(allow, deny *netutil.Netlist) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.allow, l.deny = allow, deny
+}
+
+// denied reports whether the connections from the IP are refused by the net
+// filter, the allowed networks taking precedence over the denied ones.
+func (l *inboundLimiter) denied(ip net.IP) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return !l.allow.Contains(ip) && l.deny.Contains(ip)
+}
+
+// acquire counts a new connection from the IP, returning the function to call
+// once it is closed, or an error if the connection must be refused.
+func (l *inboundLimiter) acquire(ip net.IP) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.allow.Contains(ip) || l.trusted[ip.String()] {
+		return func() {}, nil
+	}
+	if l.deny.Contains(ip) {
+		return nil, errDeniedIP
+	}
+	if l.ips != nil && !l.ips.Add(ip) {
+		return nil, errTooManyFromIP
+	}
+	if l.subnets != nil && !l.subnets.Add(ip) {
+		if l.ips != nil {
+			l.ips.Remove(ip)
+		}
+		return nil, errTooManyFromSubnet
+	}
+	var once sync.Once
+	return func() { once.Do(func() { l.release(ip) }) }, nil
+}
+
+func (l *inboundLimiter) release(ip net.IP) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.ips != nil {
+		l.ips.Remove(ip)
+	}
+	if l.subnets != nil {
+		l.subnets.Remove(ip)
+	}
+}
+
+// limitedConn is an inbound connection releasing its slot of the limiter when
+// closed.
+type limitedConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.release()
+	return err
+}
+
+// parseNetFilter parses a net filter file, made of lines "allow <cidr>" and
+// "deny <cidr>". Empty lines and the comments starting with # are ignored.
+func parseNetFilter(data []byte) (allow, deny *netutil.Netlist, err error) {
+	allow, deny = new(netutil.Netlist), new(netutil.Netlist)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("line %d: want \"allow|deny <cidr>\"", line)
+		}
+		_, n, err := net.ParseCIDR(fields[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		switch fields[0] {
+		case "allow":
+			*allow = append(*allow, *n)
+		case "deny":
+			*deny = append(*deny, *n)
+		default:
+			return nil, nil, fmt.Errorf("line %d: unknown rule %q", line, fields[0])
+		}
+	}
+	return allow, deny, scanner.Err()
+}
+
+// loadNetFilter reads the net filter file into the inbound limiter, returning
+// the modification time of the file.
+func (srv *Server) loadNetFilter() (time.Time, error) {
+	info, err := os.Stat(srv.NetFilterFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	data, err := ioutil.ReadFile(srv.NetFilterFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	allow, deny, err := parseNetFilter(data)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid net filter %s: %v", srv.NetFilterFile, err)
+	}
+	srv.inbound.setFilter(allow, deny)
+	return info.ModTime(), nil
+}
+
+// netFilterLoop reloads the net filter file whenever it is modified, dropping
+// the inbound peers it denies.
+func (srv *Server) netFilterLoop(modified time.Time) {
+	defer srv.loopWG.Done()
+
+	ticker := time.NewTicker(netFilterRecheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(srv.NetFilterFile)
+			if err != nil || info.ModTime().Equal(modified) {
+				continue
+			}
+			if modified, err = srv.loadNetFilter(); err != nil {
+				srv.log.Warn("Failed to reload net filter", "err", err)
+				continue
+			}
+			srv.log.Info("Reloaded net filter", "file", srv.NetFilterFile)
+			srv.dropDeniedPeers()
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// dropDeniedPeers disconnects the inbound peers denied by the net filter.
+func (srv *Server) dropDeniedPeers() {
+	for _, p := range srv.Peers() {
+		if !p.Inbound() || p.Info().Network.Trusted {
+			continue
+		}
+		if tcp, ok := p.RemoteAddr().(*net.TCPAddr); ok && srv.inbound.denied(tcp.IP) {
+			p.log.Debug("Dropping peer denied by the net filter")
+			p.Disconnect(DiscRequested)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// Tests that the connections are limited per IP and per subnet until released,
+// the allowed networks and the trusted nodes bypassing the limits.
+func TestInboundLimiter(t *testing.T) {
+	trusted := discover.NewNode(discover.NodeID{1}, net.IP{10, 0, 0, 9}, 30303, 30303)
+	l := newInboundLimiter(2, 3, []*discover.Node{trusted})
+
+	allow, deny, err := parseNetFilter([]byte("allow 10.0.1.0/24\ndeny 10.0.0.0/8 # all the others\n"))
+	if err != nil {
+		t.Fatalf("failed to parse the net filter: %v", err)
+	}
+	l.setFilter(allow, deny)
+
+	tests := []struct {
+		ip  net.IP
+		err error
+	}{
+		{net.IP{192, 168, 0, 1}, nil},
+		{net.IP{192, 168, 0, 1}, nil},
+		{net.IP{192, 168, 0, 1}, errTooManyFromIP},
+		{net.IP{192, 168, 0, 2}, nil},
+		{net.IP{192, 168, 0, 3}, errTooManyFromSubnet},
+		{net.IP{192, 168, 1, 1}, nil},
+		{net.IP{10, 0, 0, 1}, errDeniedIP},
+		{net.IP{10, 0, 0, 9}, nil},
+		{net.IP{10, 0, 1, 1}, nil},
+		{net.IP{10, 0, 1, 1}, nil},
+		{net.IP{10, 0, 1, 1}, nil},
+	}
+	var releases []func()
+	for i, tt := range tests {
+		release, err := l.acquire(tt.ip)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if err == nil {
+			releases = append(releases, release)
+		}
+	}
+	// Releasing twice frees a single slot
+	releases[0]()
+	releases[0]()
+	if _, err := l.acquire(net.IP{192, 168, 0, 4}); err != nil {
+		t.Errorf("released slot not reused: %v", err)
+	}
+	if _, err := l.acquire(net.IP{192, 168, 0, 5}); err != errTooManyFromSubnet {
+		t.Errorf("error mismatch: have %v, want %v", err, errTooManyFromSubnet)
+	}
+}
+
+// Tests that the malformed net filters are rejected.
+func TestParseNetFilter(t *testing.T) {
+	for _, data := range []string{
+		"allow",
+		"allow 10.0.0.1",
+		"permit 10.0.0.0/8",
+		"deny 10.0.0.0/8 10.1.0.0/16",
+	} {
+		if _, _, err := parseNetFilter([]byte(data)); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+	}
+}
+
+// Tests that the listener closes the connections above the limit of their IP.
+func TestServerMaxPeersPerIP(t *testing.T) {
+	id := randomID()
+	srv := &Server{
+		Config: Config{
+			MaxPeers:      10,
+			MaxPeersPerIP: 1,
+			ListenAddr:    "127.0.0.1:0",
+			PrivateKey:    newkey(),
+			NoDial:        true,
+		},
+		newTransport: func(fd net.Conn) transport { return newTestTransport(id, fd) },
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	// The first connection is kept open
+	first, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer first.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	second, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer second.Close()
+
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("connection above the limit not closed: %v", err)
+	}
+}
+
+// Tests that the net filter file is reloaded once modified.
+func TestServerNetFilterReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netfilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "netfilter")
+	if err := ioutil.WriteFile(file, []byte("deny 10.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Config: Config{
+		MaxPeers:      10,
+		PrivateKey:    newkey(),
+		NoDial:        true,
+		NoDiscovery:   true,
+		NetFilterFile: file,
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	if !srv.inbound.denied(net.IP{10, 0, 0, 1}) {
+		t.Error("denied network not loaded")
+	}
+	if err := ioutil.WriteFile(file, []byte("allow 10.0.0.0/24\ndeny 10.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.loadNetFilter(); err != nil {
+		t.Fatalf("could not reload the net filter: %v", err)
+	}
+	if srv.inbound.denied(net.IP{10, 0, 0, 1}) || !srv.inbound.denied(net.IP{10, 0, 1, 1}) {
+		t.Error("allowed network not reloaded")
+	}
+	// Invalid filters fail the startup
+	ioutil.WriteFile(file, []byte("deny everyone\n"), 0644)
+	if err := (&Server{Config: Config{PrivateKey: newkey(), NoDial: true, NoDiscovery: true, NetFilterFile: file}}).Start(); err == nil {
+		t.Error("invalid net filter accepted")
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// nodeSetRecheck is the interval between the checks of the static and trusted
+// node files for modifications.
+const nodeSetRecheck = 10 * time.Second
+
+var errNoNodesFile = errors.New("no static or trusted nodes file configured")
+
+// nodeSet is the set of the static or of the trusted nodes of the server. It is
+// changed at runtime by AddPeer/RemovePeer or AddTrustedPeer/RemoveTrustedPeer,
+// and by the modifications of its file, a JSON list of enode URLs, the nodes
+// listed in and delisted from the file being added and removed.
+type nodeSet struct {
+	kind   string               // Kind of the nodes, static or trusted
+	path   string               // Path to the file of the set, empty if none
+	add    func(*discover.Node) // Adds a node listed in the file to the server
+	remove func(*discover.Node) // Removes a node delisted from the file from the server
+
+	nodes    map[discover.NodeID]*discover.Node // Nodes currently in the set
+	listed   map[discover.NodeID]*discover.Node // Nodes of the file, as last loaded or saved
+	modified time.Time                          // Modification time of the file, as last loaded or saved
+	lock     sync.Mutex
+}
+
+func newNodeSet(kind, path string, nodes []*discover.Node, add, remove func(*discover.Node)) *nodeSet {
+	s := &nodeSet{
+		kind:   kind,
+		path:   path,
+		add:    add,
+		remove: remove,
+		nodes:  make(map[discover.NodeID]*discover.Node, len(nodes)),
+		listed: make(map[discover.NodeID]*discover.Node),
+	}
+	for _, n := range nodes {
+		s.nodes[n.ID] = n
+	}
+	return s
+}
+
+func (s *nodeSet) put(n *discover.Node) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.nodes[n.ID] = n
+}
+
+func (s *nodeSet) del(n *discover.Node) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.nodes, n.ID)
+}
+
+// list returns the nodes of the set, sorted by ID.
+func (s *nodeSet) list() []*discover.Node {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return sortedNodes(s.nodes)
+}
+
+// reload applies the modifications of the file since it was last loaded or
+// saved, returning the number of nodes added and removed. A missing file lists
+// no nodes. An invalid file is skipped until modified again.
+func (s *nodeSet) reload() (added, removed int, err error) {
+	var modified time.Time
+	if info, err := os.Stat(s.path); err == nil {
+		modified = info.ModTime()
+	} else if !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+	s.lock.Lock()
+	if modified.Equal(s.modified) {
+		s.lock.Unlock()
+		return 0, 0, nil
+	}
+	s.lock.Unlock()
+
+	var nodes []*discover.Node
+	if !modified.IsZero() {
+		nodes, err = parseNodesFile(s.path)
+	}
+	s.lock.Lock()
+	s.modified = modified
+	if err != nil {
+		s.lock.Unlock()
+		return 0, 0, err
+	}
+	listed := make(map[discover.NodeID]*discover.Node, len(nodes))
+	for _, n := range nodes {
+		listed[n.ID] = n
+	}
+	var adds, removes []*discover.Node
+	for id, n := range s.listed {
+		if listed[id] == nil {
+			removes = append(removes, n)
+		}
+	}
+	for id, n := range listed {
+		if old := s.listed[id]; old == nil || old.String() != n.String() {
+			adds = append(adds, n)
+		}
+	}
+	s.listed = listed
+	s.lock.Unlock()
+
+	for _, n := range removes {
+		s.remove(n)
+	}
+	for _, n := range adds {
+		s.add(n)
+	}
+	return len(adds), len(removes), nil
+}
+
+// save writes the nodes of the set to its file, replacing it atomically.
+func (s *nodeSet) save() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	urls := make([]string, 0, len(s.nodes))
+	for _, n := range sortedNodes(s.nodes) {
+		urls = append(urls, n.String())
+	}
+	data, err := json.MarshalIndent(urls, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.listed = make(map[discover.NodeID]*discover.Node, len(s.nodes))
+	for id, n := range s.nodes {
+		s.listed[id] = n
+	}
+	s.modified = info.ModTime()
+	return nil
+}
+
+// parseNodesFile reads a JSON list of enode URLs, skipping the empty ones.
+func parseNodesFile(path string) ([]*discover.Node, error) {
+	var urls []string
+	if err := common.LoadJSON(path, &urls); err != nil {
+		return nil, err
+	}
+	nodes := make([]*discover.Node, 0, len(urls))
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		n, err := discover.ParseNode(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enode %q: %v", url, err)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func sortedNodes(nodes map[discover.NodeID]*discover.Node) []*discover.Node {
+	list := make([]*discover.Node, 0, len(nodes))
+	for _, n := range nodes {
+		list = append(list, n)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].ID[:], list[j].ID[:]) < 0
+	})
+	return list
+}
+
+// nodeSetLoop loads the static and trusted node files, and reloads them
+// whenever they are modified.
+func (srv *Server) nodeSetLoop() {
+	defer srv.loopWG.Done()
+
+	ticker := time.NewTicker(nodeSetRecheck)
+	defer ticker.Stop()
+
+	for {
+		for _, s := range []*nodeSet{srv.static, srv.trusted} {
+			if s.path == "" {
+				continue
+			}
+			added, removed, err := s.reload()
+			if err != nil {
+				srv.log.Warn("Failed to load nodes file", "kind", s.kind, "file", s.path, "err", err)
+				continue
+			}
+			if added > 0 || removed > 0 {
+				srv.log.Info("Loaded nodes file", "kind", s.kind, "file", s.path, "added", added, "removed", removed)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// SaveNodes writes the current static and trusted nodes, including the ones
+// added and removed at runtime, to their files so that the changes survive a
+// restart.
+func (srv *Server) SaveNodes() error {
+	if srv.static == nil {
+		return errServerStopped
+	}
+	saved := false
+	for _, s := range []*nodeSet{srv.static, srv.trusted} {
+		if s.path == "" {
+			continue
+		}
+		if err := s.save(); err != nil {
+			return fmt.Errorf("failed to save %s nodes: %v", s.kind, err)
+		}
+		saved = true
+	}
+	if !saved {
+		return errNoNodesFile
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// nodeSetTester records the nodes a node set adds and removes.
+type nodeSetTester struct {
+	set     *nodeSet
+	added   []discover.NodeID
+	removed []discover.NodeID
+}
+
+func newNodeSetTester(path string) *nodeSetTester {
+	tester := new(nodeSetTester)
+	tester.set = newNodeSet("static", path, nil, func(n *discover.Node) {
+		tester.set.put(n)
+		tester.added = append(tester.added, n.ID)
+	}, func(n *discover.Node) {
+		tester.set.del(n)
+		tester.removed = append(tester.removed, n.ID)
+	})
+	return tester
+}
+
+// writeNodesFile writes a nodes file, modified at the given time.
+func writeNodesFile(t *testing.T, path, data string, modified time.Time) {
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that the nodes listed in and delisted from the nodes file are added and
+// removed when it is modified.
+func TestNodeSetReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		path   = filepath.Join(dir, "static-nodes.json")
+		a      = discover.NewNode(discover.NodeID{1}, net.IP{10, 0, 0, 1}, 30303, 30303)
+		b      = discover.NewNode(discover.NodeID{2}, net.IP{10, 0, 0, 2}, 30303, 30303)
+		c      = discover.NewNode(discover.NodeID{3}, net.IP{10, 0, 0, 3}, 30303, 30303)
+		now    = time.Now().Truncate(time.Second)
+		tester = newNodeSetTester(path)
+		reload = func(wantAdded, wantRemoved []discover.NodeID) {
+			t.Helper()
+			tester.added, tester.removed = nil, nil
+			if _, _, err := tester.set.reload(); err != nil {
+				t.Fatalf("failed to reload: %v", err)
+			}
+			if len(tester.added) != len(wantAdded) || len(tester.removed) != len(wantRemoved) ||
+				(len(wantAdded) > 0 && !reflect.DeepEqual(tester.added, wantAdded)) ||
+				(len(wantRemoved) > 0 && !reflect.DeepEqual(tester.removed, wantRemoved)) {
+				t.Fatalf("changes mismatch: have +%v -%v, want +%v -%v", tester.added, tester.removed, wantAdded, wantRemoved)
+			}
+		}
+	)
+	// A missing file lists no nodes
+	reload(nil, nil)
+
+	writeNodesFile(t, path, `["`+a.String()+`", ""]`, now)
+	reload([]discover.NodeID{a.ID}, nil)
+	reload(nil, nil)
+
+	writeNodesFile(t, path, `["`+b.String()+`"]`, now.Add(time.Second))
+	reload([]discover.NodeID{b.ID}, []discover.NodeID{a.ID})
+
+	// An invalid file is reported once, keeping the nodes
+	writeNodesFile(t, path, `["enode://invalid"]`, now.Add(2*time.Second))
+	if _, _, err := tester.set.reload(); err == nil {
+		t.Fatalf("invalid file loaded")
+	}
+	reload(nil, nil)
+
+	writeNodesFile(t, path, `["`+b.String()+`", "`+c.String()+`"]`, now.Add(3*time.Second))
+	reload([]discover.NodeID{c.ID}, nil)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	tester.added, tester.removed = nil, nil
+	if _, removed, err := tester.set.reload(); err != nil || removed != 2 {
+		t.Fatalf("deleted file: have %d removed, err %v", removed, err)
+	}
+	if nodes := tester.set.list(); len(nodes) != 0 {
+		t.Errorf("nodes left: %v", nodes)
+	}
+}
+
+// Tests that the saved nodes are loaded back, and not reapplied by the next
+// reload.
+func TestNodeSetSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodeset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		path   = filepath.Join(dir, "trusted-nodes.json")
+		a      = discover.NewNode(discover.NodeID{1}, net.IP{10, 0, 0, 1}, 30303, 30303)
+		b      = discover.NewNode(discover.NodeID{2}, net.IP{10, 0, 0, 2}, 30303, 30303)
+		tester = newNodeSetTester(path)
+	)
+	tester.set.put(b)
+	tester.set.put(a)
+	if err := tester.set.save(); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	nodes, err := parseNodesFile(path)
+	if err != nil {
+		t.Fatalf("failed to parse the saved file: %v", err)
+	}
+	if want := []*discover.Node{a, b}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("saved nodes mismatch: have %v, want %v", nodes, want)
+	}
+	if added, removed, err := tester.set.reload(); added != 0 || removed != 0 || err != nil {
+		t.Errorf("saved file reapplied: %d added, %d removed, err %v", added, removed, err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/enr"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,15 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Attributes contains protocol specific information for the node record,
+	// advertised to the peers through discovery v5.
+	Attributes []enr.Entry
+
+	// DialFilter is an optional function reporting whether to dial a node
+	// discovered with the given record. A discovered node is dialed if any of
+	// the protocols accepts its record.
+	DialFilter func(*enr.Record) bool
 }
 
 func (p Protocol) cap() Cap {
@@ -31,6 +31,8 @@ import (
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/p2p/discover"
 	"github.com/tomochain/tomochain/p2p/discv5"
+	"github.com/tomochain/tomochain/p2p/dnsdisc"
+	"github.com/tomochain/tomochain/p2p/enr"
 	"github.com/tomochain/tomochain/p2p/nat"
 	"github.com/tomochain/tomochain/p2p/netutil"
 )
@@ -49,6 +51,9 @@ const (
 
 	// Maximum amount of time allowed for writing a complete message.
 	frameWriteTimeout = 20 * time.Second
+
+	// Interval between the checks of the external IP of the NAT.
+	extIPRefreshInterval = 5 * time.Minute
 )
 
 var errServerStopped = errors.New("server stopped")
@@ -93,6 +98,11 @@ type Config struct {
 	// protocol.
 	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+	// DiscoveryDNS contains the enrtree:// URLs of the node lists published
+	// under DNS domains, synced periodically to find the nodes to dial along
+	// with the discovery table.
+	DiscoveryDNS []string `toml:",omitempty"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*discover.Node
@@ -101,11 +111,30 @@ type Config struct {
 	// allowed to connect, even above the peer limit.
 	TrustedNodes []*discover.Node
 
+	// StaticNodesFile and TrustedNodesFile are the paths to JSON lists of enode
+	// URLs, adding to the static and trusted nodes. The files are reloaded
+	// whenever they are modified, the nodes listed in and delisted from them
+	// being added and removed without a restart.
+	StaticNodesFile  string `toml:",omitempty"`
+	TrustedNodesFile string `toml:",omitempty"`
+
 	// Connectivity can be restricted to certain IP networks.
 	// If this option is set to a non-nil value, only hosts which match one of the
 	// IP networks contained in the list are considered.
 	NetRestrict *netutil.Netlist `toml:",omitempty"`
 
+	// MaxPeersPerIP and MaxPeersPerSubnet limit the inbound connections, pending
+	// or established, from a single IP address and from a single /24 subnet.
+	// Zero disables the limit. The trusted nodes are not limited.
+	MaxPeersPerIP     int `toml:",omitempty"`
+	MaxPeersPerSubnet int `toml:",omitempty"`
+
+	// NetFilterFile is the path to a file of "allow <cidr>" and "deny <cidr>"
+	// lines. The inbound connections from the denied networks are refused, the
+	// ones from the allowed networks bypass the denied networks and the limits.
+	// The file is reloaded whenever it is modified.
+	NetFilterFile string `toml:",omitempty"`
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
@@ -157,9 +186,17 @@ type Server struct {
 	running bool
 
 	ntab         discoverTable
+	discv4       *discover.Table
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
+	record       *enr.Record  // protected by selfLock
+	realaddr     *net.UDPAddr // discovery endpoint, nil if discovery is disabled
+	extIP        net.IP       // external IP of the NAT, protected by selfLock
+	selfLock     sync.Mutex
+	inbound      *inboundLimiter
+	static       *nodeSet
+	trusted      *nodeSet
 	DiscV5       *discv5.Network
 
 	// These are for Peers, PeerCount (and nothing else).
@@ -169,6 +206,8 @@ type Server struct {
 	quit          chan struct{}
 	addstatic     chan *discover.Node
 	removestatic  chan *discover.Node
+	addtrusted    chan *discover.Node
+	removetrusted chan *discover.Node
 	posthandshake chan *conn
 	addpeer       chan *conn
 	delpeer       chan peerDrop
@@ -286,7 +325,7 @@ func (srv *Server) PeerCount() int {
 // server is shut down. If the connection fails for any reason, the server will
 // attempt to reconnect the peer.
 func (srv *Server) AddPeer(node *discover.Node) {
-
+	srv.static.put(node)
 	select {
 	case srv.addstatic <- node:
 	case <-srv.quit:
@@ -295,12 +334,35 @@ func (srv *Server) AddPeer(node *discover.Node) {
 
 // RemovePeer disconnects from the given node
 func (srv *Server) RemovePeer(node *discover.Node) {
+	srv.static.del(node)
 	select {
 	case srv.removestatic <- node:
 	case <-srv.quit:
 	}
 }
 
+// AddTrustedPeer adds the given node to the trusted nodes, always allowed to
+// connect, even above the peer limit. It doesn't affect the existing connection
+// to the node, if any.
+func (srv *Server) AddTrustedPeer(node *discover.Node) {
+	srv.trusted.put(node)
+	srv.inbound.setTrusted(srv.trusted.list())
+	select {
+	case srv.addtrusted <- node:
+	case <-srv.quit:
+	}
+}
+
+// RemoveTrustedPeer removes the given node from the trusted nodes.
+func (srv *Server) RemoveTrustedPeer(node *discover.Node) {
+	srv.trusted.del(node)
+	srv.inbound.setTrusted(srv.trusted.list())
+	select {
+	case srv.removetrusted <- node:
+	case <-srv.quit:
+	}
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -325,11 +387,15 @@ func (srv *Server) makeSelf(listener net.Listener, ntab discoverTable) *discover
 		if listener == nil {
 			return &discover.Node{IP: net.ParseIP("0.0.0.0"), ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
 		}
-		// Otherwise inject the listener address too
+		// Otherwise inject the listener address too, or the external IP if known
 		addr := listener.Addr().(*net.TCPAddr)
+		ip := addr.IP
+		if ext := srv.externalIP(); ext != nil {
+			ip = ext
+		}
 		return &discover.Node{
 			ID:  discover.PubkeyID(&srv.PrivateKey.PublicKey),
-			IP:  addr.IP,
+			IP:  ip,
 			TCP: uint16(addr.Port),
 		}
 	}
@@ -411,6 +477,8 @@ func (srv *Server) Start() (err error) {
 	srv.posthandshake = make(chan *conn)
 	srv.addstatic = make(chan *discover.Node)
 	srv.removestatic = make(chan *discover.Node)
+	srv.addtrusted = make(chan *discover.Node)
+	srv.removetrusted = make(chan *discover.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
@@ -435,12 +503,13 @@ func (srv *Server) Start() (err error) {
 			if !realaddr.IP.IsLoopback() {
 				go nat.Map(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
 			}
-			// TODO: react to external IP changes over time.
 			if ext, err := srv.NAT.ExternalIP(); err == nil {
 				realaddr = &net.UDPAddr{IP: ext, Port: realaddr.Port}
+				srv.extIP = ext
 			}
 		}
 	}
+	srv.realaddr = realaddr
 
 	if !srv.NoDiscovery && srv.DiscoveryV5 {
 		unhandled = make(chan discover.ReadPacket, 100)
@@ -462,6 +531,7 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 		srv.ntab = ntab
+		srv.discv4 = ntab
 	}
 
 	if srv.DiscoveryV5 {
@@ -481,8 +551,15 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 		srv.DiscV5 = ntab
+		srv.ntab = newDiscv5Table(srv.ntab, ntab, srv.dialFilter())
+	}
+	if srv.ntab != nil && len(srv.DiscoveryDNS) > 0 {
+		srv.ntab = newDNSTable(srv.ntab, dnsdisc.NewClient(dnsdisc.Config{}), srv.DiscoveryDNS)
 	}
 
+	srv.static = newNodeSet("static", srv.StaticNodesFile, srv.StaticNodes, srv.AddPeer, srv.RemovePeer)
+	srv.trusted = newNodeSet("trusted", srv.TrustedNodesFile, srv.TrustedNodes, srv.AddTrustedPeer, srv.RemoveTrustedPeer)
+
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
 
@@ -491,6 +568,16 @@ func (srv *Server) Start() (err error) {
 	for _, p := range srv.Protocols {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
+	// inbound limits
+	srv.inbound = newInboundLimiter(srv.MaxPeersPerIP, srv.MaxPeersPerSubnet, srv.TrustedNodes)
+	if srv.NetFilterFile != "" {
+		modified, err := srv.loadNetFilter()
+		if err != nil {
+			return err
+		}
+		srv.loopWG.Add(1)
+		go srv.netFilterLoop(modified)
+	}
 	// listen/dial
 	if srv.ListenAddr != "" {
 		if err := srv.startListening(); err != nil {
@@ -500,9 +587,24 @@ func (srv *Server) Start() (err error) {
 	if srv.NoDial && srv.ListenAddr == "" {
 		srv.log.Warn("P2P server will be useless, neither dialing nor listening")
 	}
+	// node record, once the listening port is known
+	if srv.record, err = srv.makeRecord(0); err != nil {
+		return err
+	}
+	if srv.DiscV5 != nil {
+		srv.DiscV5.SetRecord(srv.record)
+	}
 
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
+	if srv.StaticNodesFile != "" || srv.TrustedNodesFile != "" {
+		srv.loopWG.Add(1)
+		go srv.nodeSetLoop()
+	}
+	if srv.NAT != nil {
+		srv.loopWG.Add(1)
+		go srv.natLoop()
+	}
 	srv.running = true
 	return nil
 }
@@ -547,8 +649,8 @@ func (srv *Server) run(dialstate dialer) {
 		queuedTasks  []task // tasks that can't run yet
 	)
 	// Put trusted nodes into a map to speed up checks.
-	// Trusted peers are loaded on startup and cannot be
-	// modified while the server is running.
+	// Trusted peers are loaded on startup and can be
+	// added and removed while the server is running.
 	for _, n := range srv.TrustedNodes {
 		trusted[n.ID] = true
 	}
@@ -606,6 +708,16 @@ running:
 			if p, ok := peers[n.ID]; ok {
 				p.Disconnect(DiscRequested)
 			}
+		case n := <-srv.addtrusted:
+			// This channel is used by AddTrustedPeer to mark the
+			// node trusted for its next connections.
+			srv.log.Debug("Adding trusted node", "node", n)
+			trusted[n.ID] = true
+		case n := <-srv.removetrusted:
+			// This channel is used by RemoveTrustedPeer to stop
+			// trusting the node for its next connections.
+			srv.log.Debug("Removing trusted node", "node", n)
+			delete(trusted, n.ID)
 		case op := <-srv.peerOp:
 			// This channel is used by Peers and PeerCount.
 			op(peers)
@@ -791,7 +903,22 @@ func (srv *Server) listenLoop() {
 			}
 		}
 
+		// Reject connections above the limits of their IP and subnet.
+		var release func()
+		if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok {
+			if release, err = srv.inbound.acquire(tcp.IP); err != nil {
+				srv.log.Debug("Rejected conn", "addr", fd.RemoteAddr(), "err", err)
+				ingressRejectMeter.Mark(1)
+				fd.Close()
+				slots <- struct{}{}
+				continue
+			}
+		}
+
 		fd = newMeteredConn(fd, true)
+		if release != nil {
+			fd = &limitedConn{Conn: fd, release: release}
+		}
 		srv.log.Trace("Accepted connection", "addr", fd.RemoteAddr())
 		go func() {
 			srv.SetupConn(fd, inboundConn, nil)
@@ -921,6 +1048,7 @@ type NodeInfo struct {
 	ID    string `json:"id"`    // Unique node identifier (also the encryption key)
 	Name  string `json:"name"`  // Name of the node, including client type, version, OS, custom data
 	Enode string `json:"enode"` // Enode URL for adding this peer from remote peers
+	ENR   string `json:"enr"`   // Ethereum Node Record advertised through discovery v5
 	IP    string `json:"ip"`    // IP address of the node
 	Ports struct {
 		Discovery int `json:"discovery"` // UDP listening port for discovery protocol
@@ -943,6 +1071,9 @@ func (srv *Server) NodeInfo() *NodeInfo {
 		ListenAddr: srv.ListenAddr,
 		Protocols:  make(map[string]interface{}),
 	}
+	if r := srv.LocalRecord(); r != nil {
+		info.ENR = encodeRecord(r)
+	}
 	info.Ports.Discovery = int(node.UDP)
 	info.Ports.Listener = int(node.TCP)
 
@@ -18,6 +18,7 @@ package params
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 
 	"github.com/tomochain/tomochain/common"
@@ -214,13 +215,38 @@ type ChainConfig struct {
 	TIPTomoXBlock                *big.Int `json:"tipTomoXBlock,omitempty"`                // TIPTomoX switch block (nil = no fork, 0 = already activated)
 	TIPTomoXLendingBlock         *big.Int `json:"tipTomoXLendingBlock,omitempty"`         // TIPTomoXLending switch block (nil = no fork, 0 = already activated)
 	TIPTomoXCancellationFeeBlock *big.Int `json:"tipTomoXCancellationFeeBlock,omitempty"` // TIPTomoXCancellationFee switch block (nil = no fork, 0 = already activated)
+	TIPTomoXDelistingBlock       *big.Int `json:"tipTomoXDelistingBlock,omitempty"`       // TIPTomoXDelisting switch block (nil = no fork, 0 = already activated)
+	TIPTomoXBatchCancelBlock     *big.Int `json:"tipTomoXBatchCancelBlock,omitempty"`     // TIPTomoXBatchCancel switch block (nil = no fork, 0 = already activated)
+	TIPTomoXStopOrderBlock       *big.Int `json:"tipTomoXStopOrderBlock,omitempty"`       // TIPTomoXStopOrder switch block (nil = no fork, 0 = already activated)
+	TIPTomoXIcebergOrderBlock    *big.Int `json:"tipTomoXIcebergOrderBlock,omitempty"`    // TIPTomoXIcebergOrder switch block (nil = no fork, 0 = already activated)
+	TIPTomoXPostOnlyBlock        *big.Int `json:"tipTomoXPostOnlyBlock,omitempty"`        // TIPTomoXPostOnly switch block (nil = no fork, 0 = already activated)
+	TIPTomoXTimeInForceBlock     *big.Int `json:"tipTomoXTimeInForceBlock,omitempty"`     // TIPTomoXTimeInForce switch block (nil = no fork, 0 = already activated)
+	TIPTomoXOrderExpiryBlock     *big.Int `json:"tipTomoXOrderExpiryBlock,omitempty"`     // TIPTomoXOrderExpiry switch block (nil = no fork, 0 = already activated)
+	TIPTomoXSelfTradeBlock       *big.Int `json:"tipTomoXSelfTradeBlock,omitempty"`       // TIPTomoXSelfTrade switch block (nil = no fork, 0 = already activated)
+	TIPTomoXPartialRepayBlock    *big.Int `json:"tipTomoXPartialRepayBlock,omitempty"`    // TIPTomoXPartialRepay switch block (nil = no fork, 0 = already activated)
+	TIPTomoXAutoRenewBlock       *big.Int `json:"tipTomoXAutoRenewBlock,omitempty"`       // TIPTomoXAutoRenew switch block (nil = no fork, 0 = already activated)
+	TIPTomoXVariableRateBlock    *big.Int `json:"tipTomoXVariableRateBlock,omitempty"`    // TIPTomoXVariableRate switch block (nil = no fork, 0 = already activated)
+	TIPTomoXDutchAuctionBlock    *big.Int `json:"tipTomoXDutchAuctionBlock,omitempty"`    // TIPTomoXDutchAuction switch block (nil = no fork, 0 = already activated)
+	TIPTomoXOracleBlock          *big.Int `json:"tipTomoXOracleBlock,omitempty"`          // TIPTomoXOracle switch block (nil = no fork, 0 = already activated)
+	TIPTomoXMakerRebateBlock     *big.Int `json:"tipTomoXMakerRebateBlock,omitempty"`     // TIPTomoXMakerRebate switch block (nil = no fork, 0 = already activated)
+	TIPTomoXReceiptLogsBlock     *big.Int `json:"tipTomoXReceiptLogsBlock,omitempty"`     // TIPTomoXReceiptLogs switch block (nil = no fork, 0 = already activated)
+	TIPTomoXRiskGovernanceBlock  *big.Int `json:"tipTomoXRiskGovernanceBlock,omitempty"`  // TIPTomoXRiskGovernance switch block (nil = no fork, 0 = already activated)
+	TIPTomoXCrossPairBlock       *big.Int `json:"tipTomoXCrossPairBlock,omitempty"`       // TIPTomoXCrossPair switch block (nil = no fork, 0 = already activated)
+	TIPTomoXReplaceOrderBlock    *big.Int `json:"tipTomoXReplaceOrderBlock,omitempty"`    // TIPTomoXReplaceOrder switch block (nil = no fork, 0 = already activated)
+	TIPTomoXPruneBlock           *big.Int `json:"tipTomoXPruneBlock,omitempty"`           // TIPTomoXPrune switch block (nil = no fork, 0 = already activated)
+	TIPSlashingBlock             *big.Int `json:"tipSlashingBlock,omitempty"`             // TIPSlashing switch block (nil = no fork, 0 = already activated)
+	TIPRandomProposerBlock       *big.Int `json:"tipRandomProposerBlock,omitempty"`       // TIPRandomProposer switch block (nil = no fork, 0 = already activated)
+	TIPKeyRotationBlock          *big.Int `json:"tipKeyRotationBlock,omitempty"`          // TIPKeyRotation switch block (nil = no fork, 0 = already activated)
 
 	SaigonBlock *big.Int `json:"saigonBlock,omitempty"` // Saigon switch block (nil = no fork, 0 = already activated)
+	BerlinBlock *big.Int `json:"berlinBlock,omitempty"` // Berlin switch block (nil = no fork, 0 = already activated)
+	LondonBlock *big.Int `json:"londonBlock,omitempty"` // London switch block (nil = no fork, 0 = already activated)
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
 	Posv   *PosvConfig   `json:"posv,omitempty"`
+	Dev    *DevConfig    `json:"dev,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -242,6 +268,18 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// DevConfig is the consensus engine configs for the instant sealing by a single
+// signer of private networks, without any masternode.
+type DevConfig struct {
+	Period uint64 `json:"period"` // Number of seconds between blocks to enforce (0 = seal on transactions)
+	Epoch  uint64 `json:"epoch"`  // Epoch length of the TomoX settlements (0 = no TomoX)
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *DevConfig) String() string {
+	return "dev"
+}
+
 // PosvConfig is the consensus engine configs for proof-of-stake-voting based sealing.
 type PosvConfig struct {
 	Period              uint64         `json:"period"`              // Number of seconds between blocks to enforce
@@ -250,6 +288,22 @@ type PosvConfig struct {
 	RewardCheckpoint    uint64         `json:"rewardCheckpoint"`    // Checkpoint block for calculate rewards.
 	Gap                 uint64         `json:"gap"`                 // Gap time preparing for the next epoch
 	FoudationWalletAddr common.Address `json:"foudationWalletAddr"` // Foundation Address Wallet
+
+	// BaseFeeRecipient receives the EIP-1559 base fee of every transaction once
+	// London is active. If unset, the base fee is burnt as on Ethereum.
+	BaseFeeRecipient *common.Address `json:"baseFeeRecipient,omitempty"`
+
+	// EpochForks reschedule the length of the epochs and of their gap, in
+	// ascending order of their blocks. Epoch and Gap apply before the first one.
+	EpochForks []PosvEpochFork `json:"epochForks,omitempty"`
+}
+
+// PosvEpochFork changes the length of the epochs and of their gap from a block
+// on, which must be a checkpoint of the epochs before it.
+type PosvEpochFork struct {
+	Block *big.Int `json:"block"`
+	Epoch uint64   `json:"epoch"`
+	Gap   uint64   `json:"gap"`
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -257,18 +311,153 @@ func (c *PosvConfig) String() string {
 	return "posv"
 }
 
+// CheckEpochForks checks that every epoch fork is scheduled at a checkpoint of
+// the epochs before it, and leaves room in its epochs for the gap and for the
+// blocks checked for the signatures of the masternodes coming back from their
+// penalties.
+func (c *PosvConfig) CheckEpochForks() error {
+	start, epoch := uint64(0), c.Epoch
+	for _, fork := range c.EpochForks {
+		if fork.Block == nil || fork.Block.Sign() <= 0 {
+			return fmt.Errorf("epoch fork without block")
+		}
+		block := fork.Block.Uint64()
+		if block <= start || (block-start)%epoch != 0 {
+			return fmt.Errorf("epoch fork at block %d is not a checkpoint of the epochs of %d blocks from block %d", block, epoch, start)
+		}
+		if fork.Epoch < common.RangeReturnSigner {
+			return fmt.Errorf("epoch fork at block %d has epochs of %d blocks, shorter than the %d blocks signed by the returning masternodes", block, fork.Epoch, common.RangeReturnSigner)
+		}
+		if fork.Gap == 0 || fork.Gap >= fork.Epoch {
+			return fmt.Errorf("epoch fork at block %d has a gap of %d blocks out of epochs of %d blocks", block, fork.Gap, fork.Epoch)
+		}
+		start, epoch = block, fork.Epoch
+	}
+	return nil
+}
+
+// epochSchedule returns the schedule of the epochs in force at the block: the
+// block and the epoch number it applies from, and the length of the epochs and
+// of their gap.
+func (c *PosvConfig) epochSchedule(number uint64) (start, index, epoch, gap uint64) {
+	epoch, gap = c.Epoch, c.Gap
+	for _, fork := range c.EpochForks {
+		block := fork.Block.Uint64()
+		if block > number {
+			break
+		}
+		index += (block - start) / epoch
+		start, epoch, gap = block, fork.Epoch, fork.Gap
+	}
+	return start, index, epoch, gap
+}
+
+// EpochLength returns the length of the epoch of the block.
+func (c *PosvConfig) EpochLength(number uint64) uint64 {
+	_, _, epoch, _ := c.epochSchedule(number)
+	return epoch
+}
+
+// GapLength returns the gap of the epoch of the block.
+func (c *PosvConfig) GapLength(number uint64) uint64 {
+	_, _, _, gap := c.epochSchedule(number)
+	return gap
+}
+
+// EpochOffset returns the position of the block in its epoch, zero for the
+// checkpoint.
+func (c *PosvConfig) EpochOffset(number uint64) uint64 {
+	start, _, epoch, _ := c.epochSchedule(number)
+	return (number - start) % epoch
+}
+
+// IsCheckpoint returns whether the block starts an epoch.
+func (c *PosvConfig) IsCheckpoint(number uint64) bool {
+	return c.EpochOffset(number) == 0
+}
+
+// IsGap returns whether the block is the gap before the checkpoint ending its
+// epoch, when the candidates of the next epoch are settled.
+func (c *PosvConfig) IsGap(number uint64) bool {
+	start, _, epoch, gap := c.epochSchedule(number)
+	return (number-start)%epoch == epoch-gap
+}
+
+// IsInGap returns whether the block is one of the gap blocks, from the gap of
+// its epoch to the checkpoint ending it.
+func (c *PosvConfig) IsInGap(number uint64) bool {
+	start, _, epoch, gap := c.epochSchedule(number)
+	return (number-start)%epoch >= epoch-gap
+}
+
+// NextCheckpoint returns the checkpoint ending the epoch of the block.
+func (c *PosvConfig) NextCheckpoint(number uint64) uint64 {
+	return c.Checkpoint(number) + c.EpochLength(number)
+}
+
+// EpochOf returns the number of the epoch of the block.
+func (c *PosvConfig) EpochOf(number uint64) uint64 {
+	start, index, epoch, _ := c.epochSchedule(number)
+	return index + (number-start)/epoch
+}
+
+// Checkpoint returns the checkpoint starting the epoch of the block.
+func (c *PosvConfig) Checkpoint(number uint64) uint64 {
+	return number - c.EpochOffset(number)
+}
+
+// EpochCheckpoint returns the checkpoint starting the epoch of the index.
+func (c *PosvConfig) EpochCheckpoint(index uint64) uint64 {
+	start, first, epoch := uint64(0), uint64(0), c.Epoch
+	for _, fork := range c.EpochForks {
+		block := fork.Block.Uint64()
+		next := first + (block-start)/epoch
+		if next > index {
+			break
+		}
+		start, first, epoch = block, next, fork.Epoch
+	}
+	return start + (index-first)*epoch
+}
+
+// PreviousCheckpoint returns the checkpoint the given number of epochs before
+// the epoch of the block, and false if there is none.
+func (c *PosvConfig) PreviousCheckpoint(number uint64, epochs uint64) (uint64, bool) {
+	index := c.EpochOf(number)
+	if index < epochs {
+		return 0, false
+	}
+	return c.EpochCheckpoint(index - epochs), true
+}
+
+// TrustedCheckpoint represents a set of post-processed trie roots (CHT and
+// BloomTrie) associated with the appropriate section index and head hash. It is
+// used to start light syncing from this checkpoint and avoid downloading the
+// entire header chain while still being able to securely access old headers/logs.
+type TrustedCheckpoint struct {
+	Name         string      `json:"-"`
+	SectionIndex uint64      `json:"sectionIndex"`
+	SectionHead  common.Hash `json:"sectionHead"`
+	CHTRoot      common.Hash `json:"chtRoot"`
+	BloomRoot    common.Hash `json:"bloomRoot"`
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
 	switch {
 	case c.Ethash != nil:
 		engine = c.Ethash
+	case c.Clique != nil:
+		engine = c.Clique
 	case c.Posv != nil:
 		engine = c.Posv
+	case c.Dev != nil:
+		engine = c.Dev
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v TIP2019: %v TIPSigning: %v TIPRandomize: %v BlackListHF: %v TIPTRC21Fee: %v TIPTomoX: %v TIPTomoXLending: %v TIPTomoXCancellationFee: %v Saigon: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v TIP2019: %v TIPSigning: %v TIPRandomize: %v BlackListHF: %v TIPTRC21Fee: %v TIPTomoX: %v TIPTomoXLending: %v TIPTomoXCancellationFee: %v Saigon: %v Berlin: %v London: %v Engine: %v}",
 		c.ChainId,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -287,6 +476,8 @@ func (c *ChainConfig) String() string {
 		c.TIPTomoXLendingBlock,
 		c.TIPTomoXCancellationFeeBlock,
 		c.SaigonBlock,
+		c.BerlinBlock,
+		c.LondonBlock,
 		engine,
 	)
 }
@@ -351,6 +542,28 @@ func (c *ChainConfig) IsTIPTomoX(num *big.Int) bool {
 	return isForked(common.TIPTomoXBlock, num)
 }
 
+// ExchangeEpochs returns the epochs TomoX settles the prices, the expiries and
+// the liquidations of its markets by: the epochs of the masternodes with posv,
+// the epochs of the engine on private networks, nil if TomoX isn't processed.
+func (c *ChainConfig) ExchangeEpochs() *PosvConfig {
+	switch {
+	case c.Posv != nil:
+		return c.Posv
+	case c.Clique != nil && c.Clique.Epoch > 0:
+		return &PosvConfig{Period: c.Clique.Period, Epoch: c.Clique.Epoch}
+	case c.Dev != nil && c.Dev.Epoch > 0:
+		return &PosvConfig{Period: c.Dev.Period, Epoch: c.Dev.Epoch}
+	}
+	return nil
+}
+
+// IsTomoXEnabled returns whether the TomoX trading and lending transactions of
+// the block are processed, from TIPTomoX on after the first epoch.
+func (c *ChainConfig) IsTomoXEnabled(num *big.Int) bool {
+	epochs := c.ExchangeEpochs()
+	return c.IsTIPTomoX(num) && epochs != nil && num.Uint64() > epochs.Epoch
+}
+
 func (c *ChainConfig) IsTIPTomoXLending(num *big.Int) bool {
 	return isForked(common.TIPTomoXLendingBlock, num)
 }
@@ -359,10 +572,202 @@ func (c *ChainConfig) IsTIPTomoXCancellationFee(num *big.Int) bool {
 	return isForked(common.TIPTomoXCancellationFeeBlock, num)
 }
 
+// IsTIPTomoXDelisting returns whether num is either equal to the TIPTomoXDelisting
+// fork block or greater. From then on, the orders resting on the books of pairs
+// no longer listed by any relayer are cancelled at every checkpoint block.
+func (c *ChainConfig) IsTIPTomoXDelisting(num *big.Int) bool {
+	return isForked(c.TIPTomoXDelistingBlock, num)
+}
+
+// IsTIPTomoXBatchCancel returns whether num is either equal to the TIPTomoXBatchCancel
+// fork block or greater. From then on, a single cancellation order may cancel
+// several resting orders of the same pair at once.
+func (c *ChainConfig) IsTIPTomoXBatchCancel(num *big.Int) bool {
+	return isForked(c.TIPTomoXBatchCancelBlock, num)
+}
+
+// IsTIPTomoXStopOrder returns whether num is either equal to the TIPTomoXStopOrder
+// fork block or greater. From then on, stop market and stop limit orders rest
+// until the last price of their pair reaches their trigger price, and are then
+// matched as market and limit orders when the block is finalized.
+func (c *ChainConfig) IsTIPTomoXStopOrder(num *big.Int) bool {
+	return isForked(c.TIPTomoXStopOrderBlock, num)
+}
+
+// IsTIPTomoXIcebergOrder returns whether num is either equal to the
+// TIPTomoXIcebergOrder fork block or greater. From then on, limit orders may
+// show only a clip of their quantity in the order book, replenished from the
+// hidden rest of the order after each fill.
+func (c *ChainConfig) IsTIPTomoXIcebergOrder(num *big.Int) bool {
+	return isForked(c.TIPTomoXIcebergOrderBlock, num)
+}
+
+// IsTIPTomoXPostOnly returns whether num is either equal to the TIPTomoXPostOnly
+// fork block or greater. From then on, a limit order may be flagged post-only,
+// and is rejected instead of matched if it would cross the spread.
+func (c *ChainConfig) IsTIPTomoXPostOnly(num *big.Int) bool {
+	return isForked(c.TIPTomoXPostOnlyBlock, num)
+}
+
+// IsTIPTomoXTimeInForce returns whether num is either equal to the
+// TIPTomoXTimeInForce fork block or greater. From then on, a limit order may be
+// immediate-or-cancel, its unmatched part being cancelled, or fill-or-kill,
+// rejected unless it is filled entirely. Neither is ever added to the order book.
+func (c *ChainConfig) IsTIPTomoXTimeInForce(num *big.Int) bool {
+	return isForked(c.TIPTomoXTimeInForceBlock, num)
+}
+
+// IsTIPTomoXOrderExpiry returns whether num is either equal to the
+// TIPTomoXOrderExpiry fork block or greater. From then on, an order may be good
+// till a date, and is cancelled at the first checkpoint block past its expiry.
+func (c *ChainConfig) IsTIPTomoXOrderExpiry(num *big.Int) bool {
+	return isForked(c.TIPTomoXOrderExpiryBlock, num)
+}
+
+// IsTIPTomoXSelfTrade returns whether num is either equal to the TIPTomoXSelfTrade
+// fork block or greater. From then on, an order may choose which of itself and
+// the resting order is cancelled, instead of trading with an order of the same
+// user or relayer.
+func (c *ChainConfig) IsTIPTomoXSelfTrade(num *big.Int) bool {
+	return isForked(c.TIPTomoXSelfTradeBlock, num)
+}
+
+// IsTIPTomoXPartialRepay returns whether num is either equal to the
+// TIPTomoXPartialRepay fork block or greater. From then on, a borrower may repay
+// part of a loan before its maturity, releasing part of the collateral.
+func (c *ChainConfig) IsTIPTomoXPartialRepay(num *big.Int) bool {
+	return isForked(c.TIPTomoXPartialRepayBlock, num)
+}
+
+// IsTIPTomoXAutoRenew returns whether num is either equal to the
+// TIPTomoXAutoRenew fork block or greater. From then on, a borrower may have a
+// loan rolled over at maturity into a new term at a capped interest rate.
+func (c *ChainConfig) IsTIPTomoXAutoRenew(num *big.Int) bool {
+	return isForked(c.TIPTomoXAutoRenewBlock, num)
+}
+
+// IsTIPTomoXVariableRate returns whether num is either equal to the
+// TIPTomoXVariableRate fork block or greater. From then on, the open loans of a
+// lending book with a variable interest rate model are repriced at every
+// liquidation checkpoint, from the utilization of the book.
+func (c *ChainConfig) IsTIPTomoXVariableRate(num *big.Int) bool {
+	return isForked(c.TIPTomoXVariableRateBlock, num)
+}
+
+// IsTIPTomoXDutchAuction returns whether num is either equal to the
+// TIPTomoXDutchAuction fork block or greater. From then on, the collateral of a
+// liquidated lending trade is sold in a descending-price auction.
+func (c *ChainConfig) IsTIPTomoXDutchAuction(num *big.Int) bool {
+	return isForked(c.TIPTomoXDutchAuctionBlock, num)
+}
+
+// IsTIPTomoXOracle returns whether num is either equal to the TIPTomoXOracle
+// fork block or greater. From then on, the prices of the lending contract are
+// the median of the prices reported by several feeders per pair.
+func (c *ChainConfig) IsTIPTomoXOracle(num *big.Int) bool {
+	return isForked(c.TIPTomoXOracleBlock, num)
+}
+
+// IsTIPTomoXMakerRebate returns whether num is either equal to the
+// TIPTomoXMakerRebate fork block or greater. From then on, relayers may pay
+// their makers a rebate out of the taker fee instead of charging a maker fee,
+// on the trades with the takers of the same relayer.
+func (c *ChainConfig) IsTIPTomoXMakerRebate(num *big.Int) bool {
+	return isForked(c.TIPTomoXMakerRebateBlock, num)
+}
+
+// IsTIPTomoXReceiptLogs returns whether num is either equal to the
+// TIPTomoXReceiptLogs fork block or greater. From then on, the receipts of the
+// trading and lending transactions log the trades, cancellations, loans and
+// liquidations they settle.
+func (c *ChainConfig) IsTIPTomoXReceiptLogs(num *big.Int) bool {
+	return isForked(c.TIPTomoXReceiptLogsBlock, num)
+}
+
+// IsTIPTomoXRiskGovernance returns whether num is either equal to the
+// TIPTomoXRiskGovernance fork block or greater. From then on, the risk
+// parameters of the loans backed by a collateral are those scheduled by the
+// governance of the lending contract for the epoch of the block.
+func (c *ChainConfig) IsTIPTomoXRiskGovernance(num *big.Int) bool {
+	return isForked(c.TIPTomoXRiskGovernanceBlock, num)
+}
+
+// IsTIPTomoXCrossPair returns whether num is either equal to the
+// TIPTomoXCrossPair fork block or greater. From then on, what the order book of
+// a pair without TOMO can't fill of a market order is routed through the order
+// books of both tokens against TOMO.
+func (c *ChainConfig) IsTIPTomoXCrossPair(num *big.Int) bool {
+	return isForked(c.TIPTomoXCrossPairBlock, num)
+}
+
+// IsTIPTomoXReplaceOrder returns whether num is either equal to the
+// TIPTomoXReplaceOrder fork block or greater. From then on, a limit order may
+// replace a resting order of its user in a single order transaction.
+func (c *ChainConfig) IsTIPTomoXReplaceOrder(num *big.Int) bool {
+	return isForked(c.TIPTomoXReplaceOrderBlock, num)
+}
+
+// IsTIPTomoXPrune returns whether num is either equal to the TIPTomoXPrune fork
+// block or greater. From then on, the filled and cancelled orders and the
+// emptied price levels are dropped from the trading state, and the orders and
+// price levels modified again after the tries were updated are written again.
+func (c *ChainConfig) IsTIPTomoXPrune(num *big.Int) bool {
+	return isForked(c.TIPTomoXPruneBlock, num)
+}
+
+// IsTIPSlashing returns whether num is either equal to the TIPSlashing fork
+// block or greater. From then on, the masternodes proven by an evidence
+// transaction to have signed two blocks of the same number are penalized at
+// the next checkpoint.
+func (c *ChainConfig) IsTIPSlashing(num *big.Int) bool {
+	return isForked(c.TIPSlashingBlock, num)
+}
+
+// IsTIPRandomProposer returns whether num is either equal to the
+// TIPRandomProposer fork block or greater. From then on, the checkpoints
+// commit a seed chained from the previous one, which shuffles the order the
+// masternodes of the epoch propose blocks in.
+func (c *ChainConfig) IsTIPRandomProposer(num *big.Int) bool {
+	return isForked(c.TIPRandomProposerBlock, num)
+}
+
+// IsTIPKeyRotation returns whether num is either equal to the TIPKeyRotation
+// fork block or greater. From then on, the owner of a candidate may register a
+// new signing key for it in the validator contract, which the masternode seals
+// its blocks with from the next epoch on.
+func (c *ChainConfig) IsTIPKeyRotation(num *big.Int) bool {
+	return isForked(c.TIPKeyRotationBlock, num)
+}
+
+// ApplyTomoXForks makes the TomoX fork blocks scheduled in the configuration
+// effective. These forks are checked against the globals in package common,
+// which otherwise only hold the bundled schedule.
+func (c *ChainConfig) ApplyTomoXForks() {
+	if c.TIPTomoXBlock != nil {
+		common.TIPTomoXBlock = c.TIPTomoXBlock
+	}
+	if c.TIPTomoXLendingBlock != nil {
+		common.TIPTomoXLendingBlock = c.TIPTomoXLendingBlock
+	}
+}
+
 func (c *ChainConfig) IsSaigon(num *big.Int) bool {
 	return isForked(c.SaigonBlock, num)
 }
 
+// IsBerlin returns whether num is either equal to the Berlin fork block or greater.
+// Berlin enables the EIP-2929 cold/warm state access gas schedule and the
+// EIP-2565 ModExp repricing on top of the Istanbul rules.
+func (c *ChainConfig) IsBerlin(num *big.Int) bool {
+	return isForked(c.BerlinBlock, num)
+}
+
+// IsLondon returns whether num is either equal to the London fork block or greater.
+// London introduces the EIP-1559 base fee and dynamic fee transactions.
+func (c *ChainConfig) IsLondon(num *big.Int) bool {
+	return isForked(c.LondonBlock, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -450,9 +855,121 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.TIPTomoXCancellationFeeBlock, newcfg.TIPTomoXCancellationFeeBlock, head) {
 		return newCompatError("TIPTomoXCancellationFee fork block", c.TIPTomoXCancellationFeeBlock, newcfg.TIPTomoXCancellationFeeBlock)
 	}
+	if isForkIncompatible(c.TIPTomoXDelistingBlock, newcfg.TIPTomoXDelistingBlock, head) {
+		return newCompatError("TIPTomoXDelisting fork block", c.TIPTomoXDelistingBlock, newcfg.TIPTomoXDelistingBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXBatchCancelBlock, newcfg.TIPTomoXBatchCancelBlock, head) {
+		return newCompatError("TIPTomoXBatchCancel fork block", c.TIPTomoXBatchCancelBlock, newcfg.TIPTomoXBatchCancelBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXStopOrderBlock, newcfg.TIPTomoXStopOrderBlock, head) {
+		return newCompatError("TIPTomoXStopOrder fork block", c.TIPTomoXStopOrderBlock, newcfg.TIPTomoXStopOrderBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXIcebergOrderBlock, newcfg.TIPTomoXIcebergOrderBlock, head) {
+		return newCompatError("TIPTomoXIcebergOrder fork block", c.TIPTomoXIcebergOrderBlock, newcfg.TIPTomoXIcebergOrderBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXPostOnlyBlock, newcfg.TIPTomoXPostOnlyBlock, head) {
+		return newCompatError("TIPTomoXPostOnly fork block", c.TIPTomoXPostOnlyBlock, newcfg.TIPTomoXPostOnlyBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXTimeInForceBlock, newcfg.TIPTomoXTimeInForceBlock, head) {
+		return newCompatError("TIPTomoXTimeInForce fork block", c.TIPTomoXTimeInForceBlock, newcfg.TIPTomoXTimeInForceBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXOrderExpiryBlock, newcfg.TIPTomoXOrderExpiryBlock, head) {
+		return newCompatError("TIPTomoXOrderExpiry fork block", c.TIPTomoXOrderExpiryBlock, newcfg.TIPTomoXOrderExpiryBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXSelfTradeBlock, newcfg.TIPTomoXSelfTradeBlock, head) {
+		return newCompatError("TIPTomoXSelfTrade fork block", c.TIPTomoXSelfTradeBlock, newcfg.TIPTomoXSelfTradeBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXPartialRepayBlock, newcfg.TIPTomoXPartialRepayBlock, head) {
+		return newCompatError("TIPTomoXPartialRepay fork block", c.TIPTomoXPartialRepayBlock, newcfg.TIPTomoXPartialRepayBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXAutoRenewBlock, newcfg.TIPTomoXAutoRenewBlock, head) {
+		return newCompatError("TIPTomoXAutoRenew fork block", c.TIPTomoXAutoRenewBlock, newcfg.TIPTomoXAutoRenewBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXVariableRateBlock, newcfg.TIPTomoXVariableRateBlock, head) {
+		return newCompatError("TIPTomoXVariableRate fork block", c.TIPTomoXVariableRateBlock, newcfg.TIPTomoXVariableRateBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXDutchAuctionBlock, newcfg.TIPTomoXDutchAuctionBlock, head) {
+		return newCompatError("TIPTomoXDutchAuction fork block", c.TIPTomoXDutchAuctionBlock, newcfg.TIPTomoXDutchAuctionBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXOracleBlock, newcfg.TIPTomoXOracleBlock, head) {
+		return newCompatError("TIPTomoXOracle fork block", c.TIPTomoXOracleBlock, newcfg.TIPTomoXOracleBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXMakerRebateBlock, newcfg.TIPTomoXMakerRebateBlock, head) {
+		return newCompatError("TIPTomoXMakerRebate fork block", c.TIPTomoXMakerRebateBlock, newcfg.TIPTomoXMakerRebateBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXReceiptLogsBlock, newcfg.TIPTomoXReceiptLogsBlock, head) {
+		return newCompatError("TIPTomoXReceiptLogs fork block", c.TIPTomoXReceiptLogsBlock, newcfg.TIPTomoXReceiptLogsBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXRiskGovernanceBlock, newcfg.TIPTomoXRiskGovernanceBlock, head) {
+		return newCompatError("TIPTomoXRiskGovernance fork block", c.TIPTomoXRiskGovernanceBlock, newcfg.TIPTomoXRiskGovernanceBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXCrossPairBlock, newcfg.TIPTomoXCrossPairBlock, head) {
+		return newCompatError("TIPTomoXCrossPair fork block", c.TIPTomoXCrossPairBlock, newcfg.TIPTomoXCrossPairBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXReplaceOrderBlock, newcfg.TIPTomoXReplaceOrderBlock, head) {
+		return newCompatError("TIPTomoXReplaceOrder fork block", c.TIPTomoXReplaceOrderBlock, newcfg.TIPTomoXReplaceOrderBlock)
+	}
+	if isForkIncompatible(c.TIPTomoXPruneBlock, newcfg.TIPTomoXPruneBlock, head) {
+		return newCompatError("TIPTomoXPrune fork block", c.TIPTomoXPruneBlock, newcfg.TIPTomoXPruneBlock)
+	}
+	if isForkIncompatible(c.TIPSlashingBlock, newcfg.TIPSlashingBlock, head) {
+		return newCompatError("TIPSlashing fork block", c.TIPSlashingBlock, newcfg.TIPSlashingBlock)
+	}
+	if c.Posv != nil && newcfg.Posv != nil {
+		if block := c.Posv.epochForksDiffer(newcfg.Posv, head); block != nil {
+			return newCompatError("Posv epoch fork", block, block)
+		}
+	}
+	if isForkIncompatible(c.TIPRandomProposerBlock, newcfg.TIPRandomProposerBlock, head) {
+		return newCompatError("TIPRandomProposer fork block", c.TIPRandomProposerBlock, newcfg.TIPRandomProposerBlock)
+	}
+	if isForkIncompatible(c.TIPKeyRotationBlock, newcfg.TIPKeyRotationBlock, head) {
+		return newCompatError("TIPKeyRotation fork block", c.TIPKeyRotationBlock, newcfg.TIPKeyRotationBlock)
+	}
 	if isForkIncompatible(c.SaigonBlock, newcfg.SaigonBlock, head) {
 		return newCompatError("Saigon fork block", c.SaigonBlock, newcfg.SaigonBlock)
 	}
+	if isForkIncompatible(c.BerlinBlock, newcfg.BerlinBlock, head) {
+		return newCompatError("Berlin fork block", c.BerlinBlock, newcfg.BerlinBlock)
+	}
+	if isForkIncompatible(c.LondonBlock, newcfg.LondonBlock, head) {
+		return newCompatError("London fork block", c.LondonBlock, newcfg.LondonBlock)
+	}
+	return nil
+}
+
+// epochForksDiffer returns the first block the epochs of the configs differ
+// from, if the head is past it.
+func (c *PosvConfig) epochForksDiffer(newcfg *PosvConfig, head *big.Int) *big.Int {
+	if head == nil {
+		return nil
+	}
+	number := head.Uint64()
+	forks, newforks := c.EpochForks, newcfg.EpochForks
+	for i := 0; i < len(forks) || i < len(newforks); i++ {
+		var fork, newfork *PosvEpochFork
+		if i < len(forks) {
+			fork = &forks[i]
+		}
+		if i < len(newforks) {
+			newfork = &newforks[i]
+		}
+		if fork != nil && newfork != nil && configNumEqual(fork.Block, newfork.Block) && fork.Epoch == newfork.Epoch && fork.Gap == newfork.Gap {
+			continue
+		}
+		// The first different fork only matters once either is active
+		block := new(big.Int).SetUint64(math.MaxUint64)
+		if fork != nil && fork.Block != nil {
+			block = fork.Block
+		}
+		if newfork != nil && newfork.Block != nil && newfork.Block.Cmp(block) < 0 {
+			block = newfork.Block
+		}
+		if block.Uint64() <= number {
+			return block
+		}
+		return nil
+	}
 	return nil
 }
 
@@ -523,7 +1040,7 @@ type Rules struct {
 	IsTIP2019, IsTIPSigning, IsTIPRandomize                  bool
 	IsBlackListHF, IsTIPTRC21Fee                             bool
 	IsTIPTomoX, IsTIPTomoXLending, IsTIPTomoXCancellationFee bool
-	IsSaigon                                                 bool
+	IsSaigon, IsBerlin, IsLondon                             bool
 }
 
 func (c *ChainConfig) Rules(num *big.Int) Rules {
@@ -551,5 +1068,7 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsTIPTomoXLending:         c.IsTIPTomoXLending(num),
 		IsTIPTomoXCancellationFee: c.IsTIPTomoXCancellationFee(num),
 		IsSaigon:                  c.IsSaigon(num),
+		IsBerlin:                  c.IsBerlin(num),
+		IsLondon:                  c.IsLondon(num),
 	}
 }
@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/tomochain/tomochain/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -79,3 +81,114 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestPosvEpochForks(t *testing.T) {
+	config := &PosvConfig{Epoch: 900, Gap: 450, EpochForks: []PosvEpochFork{
+		{Block: big.NewInt(1800), Epoch: 300, Gap: 100},
+		{Block: big.NewInt(2400), Epoch: 1200, Gap: 600},
+	}}
+	if err := config.CheckEpochForks(); err != nil {
+		t.Fatalf("valid epoch forks rejected: %v", err)
+	}
+	for _, tt := range []struct {
+		number, epoch, checkpoint, length uint64
+		isGap                             bool
+	}{
+		{0, 0, 0, 900, false},
+		{450, 0, 0, 900, true},
+		{1799, 1, 900, 900, false},
+		{1800, 2, 1800, 300, false},
+		{2000, 2, 1800, 300, true},
+		{2100, 3, 2100, 300, false},
+		{2399, 3, 2100, 300, false},
+		{2400, 4, 2400, 1200, false},
+		{3000, 4, 2400, 1200, true},
+		{3600, 5, 3600, 1200, false},
+	} {
+		if epoch := config.EpochOf(tt.number); epoch != tt.epoch {
+			t.Errorf("block %d: epoch mismatch: have %d, want %d", tt.number, epoch, tt.epoch)
+		}
+		if checkpoint := config.Checkpoint(tt.number); checkpoint != tt.checkpoint {
+			t.Errorf("block %d: checkpoint mismatch: have %d, want %d", tt.number, checkpoint, tt.checkpoint)
+		}
+		if checkpoint := config.EpochCheckpoint(tt.epoch); checkpoint != tt.checkpoint {
+			t.Errorf("epoch %d: checkpoint mismatch: have %d, want %d", tt.epoch, checkpoint, tt.checkpoint)
+		}
+		if length := config.EpochLength(tt.number); length != tt.length {
+			t.Errorf("block %d: epoch length mismatch: have %d, want %d", tt.number, length, tt.length)
+		}
+		if isCheckpoint := config.IsCheckpoint(tt.number); isCheckpoint != (tt.number == tt.checkpoint) {
+			t.Errorf("block %d: checkpoint flag mismatch: have %v", tt.number, isCheckpoint)
+		}
+		if isGap := config.IsGap(tt.number); isGap != tt.isGap {
+			t.Errorf("block %d: gap flag mismatch: have %v, want %v", tt.number, isGap, tt.isGap)
+		}
+	}
+	for number, inGap := range map[uint64]bool{449: false, 450: true, 899: true, 900: false, 2299: false, 2300: true, 2399: true} {
+		if have := config.IsInGap(number); have != inGap {
+			t.Errorf("block %d: gap blocks flag mismatch: have %v, want %v", number, have, inGap)
+		}
+	}
+	if checkpoint := config.NextCheckpoint(2000); checkpoint != 2100 {
+		t.Errorf("next checkpoint mismatch: have %d, want 2100", checkpoint)
+	}
+	if checkpoint, ok := config.PreviousCheckpoint(2500, 2); !ok || checkpoint != 1800 {
+		t.Errorf("previous checkpoint mismatch: have %d (%v), want 1800", checkpoint, ok)
+	}
+	if _, ok := config.PreviousCheckpoint(900, 2); ok {
+		t.Errorf("previous checkpoint before the genesis")
+	}
+
+	invalid := &PosvConfig{Epoch: 900, Gap: 450, EpochForks: []PosvEpochFork{{Block: big.NewInt(2000), Epoch: 300, Gap: 100}}}
+	if err := invalid.CheckEpochForks(); err == nil {
+		t.Errorf("epoch fork off a checkpoint accepted")
+	}
+	invalid.EpochForks[0].Block, invalid.EpochForks[0].Gap = big.NewInt(1800), 300
+	if err := invalid.CheckEpochForks(); err == nil {
+		t.Errorf("epoch fork without room for its gap accepted")
+	}
+
+	stored := &ChainConfig{Posv: config}
+	rescheduled := &ChainConfig{Posv: &PosvConfig{Epoch: 900, Gap: 450, EpochForks: []PosvEpochFork{
+		{Block: big.NewInt(1800), Epoch: 300, Gap: 100},
+		{Block: big.NewInt(2700), Epoch: 1200, Gap: 600},
+	}}}
+	if err := stored.CheckCompatible(rescheduled, 2000); err != nil {
+		t.Errorf("rescheduling a future epoch fork rejected: %v", err)
+	}
+	if err := stored.CheckCompatible(rescheduled, 2500); err == nil || err.RewindTo != 2399 {
+		t.Errorf("rescheduling an active epoch fork accepted: %v", err)
+	}
+}
+
+func TestExchangeEpochs(t *testing.T) {
+	for i, tt := range []struct {
+		config *ChainConfig
+		epoch  uint64 // 0 = TomoX not processed
+	}{
+		{&ChainConfig{Ethash: new(EthashConfig)}, 0},
+		{&ChainConfig{Posv: &PosvConfig{Epoch: 900}}, 900},
+		{&ChainConfig{Clique: &CliqueConfig{Period: 2, Epoch: 30000}}, 30000},
+		{&ChainConfig{Dev: &DevConfig{Epoch: 100}}, 100},
+		{&ChainConfig{Dev: &DevConfig{}}, 0},
+	} {
+		epochs := tt.config.ExchangeEpochs()
+		if (epochs == nil) != (tt.epoch == 0) || (epochs != nil && epochs.Epoch != tt.epoch) {
+			t.Errorf("test %d: epochs mismatch: have %v, want epoch %d", i, epochs, tt.epoch)
+		}
+		if tt.config.IsTomoXEnabled(new(big.Int).Sub(common.TIPTomoXBlock, common.Big1)) {
+			t.Errorf("test %d: TomoX enabled before TIPTomoX", i)
+		}
+		if enabled := tt.config.IsTomoXEnabled(common.TIPTomoXBlock); enabled != (tt.epoch != 0) {
+			t.Errorf("test %d: TomoX enabled mismatch: have %v, want %v", i, enabled, tt.epoch != 0)
+		}
+	}
+	// TomoX starts after the first epoch
+	defer func(block *big.Int) { common.TIPTomoXBlock = block }(common.TIPTomoXBlock)
+	common.TIPTomoXBlock = big.NewInt(0)
+
+	config := &ChainConfig{Dev: &DevConfig{Epoch: 100}}
+	if config.IsTomoXEnabled(big.NewInt(100)) || !config.IsTomoXEnabled(big.NewInt(101)) {
+		t.Errorf("TomoX not enabled after the first epoch")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/tomochain/tomochain/metrics"
+)
+
+const (
+	deprecatedMethodPrefix = "rpc/deprecated/method/"
+	deprecatedFieldPrefix  = "rpc/deprecated/field/"
+)
+
+var (
+	// deprecationTracking is set to 1 if usage of deprecated RPC methods and
+	// fields should be counted. It is disabled by default, so the lookups
+	// cost nothing for nodes that don't opt in.
+	deprecationTracking int32
+
+	deprecatedLock    sync.RWMutex
+	deprecatedMethods = make(map[string]metrics.Counter) // full method name (e.g. eth_getWork) -> usage counter
+	deprecatedFields  = make(map[string]metrics.Counter) // field name (e.g. eth_sendTransaction.data) -> usage counter
+)
+
+// EnableDeprecationTracking turns on the usage counters of deprecated RPC
+// methods and fields. The counters are published through the default metrics
+// registry, hence metrics collection must be enabled as well.
+func EnableDeprecationTracking() {
+	atomic.StoreInt32(&deprecationTracking, 1)
+}
+
+// DeprecationTracking reports whether deprecated RPC usage is being counted.
+func DeprecationTracking() bool {
+	return atomic.LoadInt32(&deprecationTracking) == 1
+}
+
+// RegisterDeprecatedMethods marks the given fully qualified RPC methods (e.g.
+// eth_getWork) as deprecated. Registering a method more than once is a no-op.
+func RegisterDeprecatedMethods(methods ...string) {
+	deprecatedLock.Lock()
+	defer deprecatedLock.Unlock()
+
+	for _, method := range methods {
+		if _, ok := deprecatedMethods[method]; !ok {
+			deprecatedMethods[method] = metrics.NewRegisteredCounter(deprecatedMethodPrefix+method, nil)
+		}
+	}
+}
+
+// DeprecatedMethods returns the list of RPC methods registered as deprecated.
+func DeprecatedMethods() []string {
+	deprecatedLock.RLock()
+	defer deprecatedLock.RUnlock()
+
+	methods := make([]string, 0, len(deprecatedMethods))
+	for method := range deprecatedMethods {
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// MarkDeprecatedField records a single use of a deprecated request field. The
+// name should identify both the method family and the field, for example
+// "eth_sendTransaction.data". It is a no-op unless tracking is enabled.
+func MarkDeprecatedField(field string) {
+	if !DeprecationTracking() {
+		return
+	}
+	deprecatedLock.RLock()
+	counter, ok := deprecatedFields[field]
+	deprecatedLock.RUnlock()
+
+	if !ok {
+		deprecatedLock.Lock()
+		if counter, ok = deprecatedFields[field]; !ok {
+			counter = metrics.NewRegisteredCounter(deprecatedFieldPrefix+field, nil)
+			deprecatedFields[field] = counter
+		}
+		deprecatedLock.Unlock()
+	}
+	counter.Inc(1)
+}
+
+// markDeprecatedCall records a single invocation of the given RPC method if it
+// was registered as deprecated and tracking is enabled.
+func markDeprecatedCall(method string) {
+	if !DeprecationTracking() {
+		return
+	}
+	deprecatedLock.RLock()
+	counter, ok := deprecatedMethods[method]
+	deprecatedLock.RUnlock()
+
+	if ok {
+		counter.Inc(1)
+	}
+}
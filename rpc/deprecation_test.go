@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"testing"
+
+	"github.com/tomochain/tomochain/metrics"
+)
+
+func TestDeprecatedMethodTracking(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	RegisterDeprecatedMethods("test_rets")
+	RegisterDeprecatedMethods("test_rets") // duplicate registration must be harmless
+
+	counter := metrics.DefaultRegistry.Get(deprecatedMethodPrefix + "test_rets").(metrics.Counter)
+	call := func() {
+		var res string
+		if err := client.Call(&res, "test_rets"); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Call(nil, "test_noArgsRets"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Calls must not be counted until tracking is enabled
+	call()
+	if n := counter.Count(); n != 0 {
+		t.Fatalf("counted %d calls before tracking was enabled", n)
+	}
+	EnableDeprecationTracking()
+	call()
+	call()
+	if n := counter.Count(); n != 2 {
+		t.Fatalf("deprecated call count mismatch: have %d, want 2", n)
+	}
+	if metrics.DefaultRegistry.Get(deprecatedMethodPrefix+"test_noArgsRets") != nil {
+		t.Fatal("non-deprecated method has a usage counter")
+	}
+	// Field usage is counted lazily by name
+	MarkDeprecatedField("test_echo.legacy")
+	MarkDeprecatedField("test_echo.legacy")
+	field := metrics.DefaultRegistry.Get(deprecatedFieldPrefix + "test_echo.legacy").(metrics.Counter)
+	if n := field.Count(); n != 2 {
+		t.Fatalf("deprecated field count mismatch: have %d, want 2", n)
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tomox
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+const (
+	MaxAddressRecordsPerRequest = 1000 // Maximum number of records of an address returned by a request
+
+	addressIndexUndoDepth = 1024 // Number of blocks whose indexed records can be rolled back
+
+	orderIndexName = "orders"
+)
+
+var (
+	addressIndexPrefix     = []byte("tomox-address-")      // addressIndexPrefix + name + address + number + seq -> record
+	addressIndexUndoPrefix = []byte("tomox-undo-address-") // addressIndexUndoPrefix + name + number + hash -> keys of the records of the block
+
+	ErrAddressIndexDisabled = errors.New("address index is disabled")
+)
+
+// AddressRecord is a record of a block indexed under an address.
+type AddressRecord struct {
+	Address common.Address
+	Value   []byte
+}
+
+// addressIndexDatabase is the database the address indexes are persisted in.
+type addressIndexDatabase interface {
+	ethdb.KeyValueReader
+	ethdb.KeyValueWriter
+	ethdb.Batcher
+	ethdb.Iteratee
+}
+
+// AddressIndex indexes records of the canonical blocks by address, in the order
+// of the blocks, and keeps the keys of the records each block indexed so that
+// they are deleted if the block is reorganised away.
+type AddressIndex struct {
+	db         addressIndexDatabase
+	prefix     []byte
+	undoPrefix []byte
+	lock       sync.Mutex
+}
+
+// NewAddressIndex creates the address index of the given name held in db.
+func NewAddressIndex(db addressIndexDatabase, name string) *AddressIndex {
+	return &AddressIndex{
+		db:         db,
+		prefix:     append(append([]byte{}, addressIndexPrefix...), name+"-"...),
+		undoPrefix: append(append([]byte{}, addressIndexUndoPrefix...), name+"-"...),
+	}
+}
+
+// undoKey returns the key of the keys of the records indexed by a block.
+func (x *AddressIndex) undoKey(number uint64, hash common.Hash) []byte {
+	key := append(append([]byte{}, x.undoPrefix...), encodeCandleUint(number)...)
+	return append(key, hash.Bytes()...)
+}
+
+// addressKey returns the prefix of the keys of the records of an address.
+func (x *AddressIndex) addressKey(addr common.Address) []byte {
+	return append(append([]byte{}, x.prefix...), addr.Bytes()...)
+}
+
+// Index adds the records of a canonical block to the index, in order.
+func (x *AddressIndex) Index(block *types.Block, records []AddressRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	var (
+		batch = x.db.NewBatch()
+		undo  = make([][]byte, 0, len(records))
+		seq   = make([]byte, 4)
+	)
+	for i, record := range records {
+		binary.BigEndian.PutUint32(seq, uint32(i))
+		key := append(append(x.addressKey(record.Address), encodeCandleUint(block.NumberU64())...), seq...)
+		if err := batch.Put(key, record.Value); err != nil {
+			return err
+		}
+		undo = append(undo, key)
+	}
+	blob, err := rlp.EncodeToBytes(undo)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(x.undoKey(block.NumberU64(), block.Hash()), blob); err != nil {
+		return err
+	}
+	if err := x.pruneUndo(batch, block.NumberU64()); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// pruneUndo deletes the keys of the records indexed by the blocks too old to be
+// rolled back anymore.
+func (x *AddressIndex) pruneUndo(batch ethdb.Batch, number uint64) error {
+	if number < addressIndexUndoDepth {
+		return nil
+	}
+	it := x.db.NewIterator(x.undoPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(x.undoPrefix)+8+common.HashLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(x.undoPrefix):]) > number-addressIndexUndoDepth {
+			break
+		}
+		if err := batch.Delete(common.CopyBytes(key)); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// Rollback deletes the records indexed by a block reorganised away.
+func (x *AddressIndex) Rollback(block *types.Block) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	undoKey := x.undoKey(block.NumberU64(), block.Hash())
+	if has, err := x.db.Has(undoKey); err != nil || !has {
+		return err
+	}
+	blob, err := x.db.Get(undoKey)
+	if err != nil {
+		return err
+	}
+	var keys [][]byte
+	if err := rlp.DecodeBytes(blob, &keys); err != nil {
+		return err
+	}
+	batch := x.db.NewBatch()
+	for _, key := range keys {
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+	}
+	if err := batch.Delete(undoKey); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Debug("Rolled back address index of a reorganised block", "number", block.NumberU64(), "hash", block.Hash(), "records", len(keys))
+	return nil
+}
+
+// Records returns up to limit records of an address indexed by the blocks from
+// the given number on, in the order they were indexed.
+func (x *AddressIndex) Records(addr common.Address, from uint64, limit int) ([][]byte, error) {
+	it := x.db.NewIterator(x.addressKey(addr), encodeCandleUint(from))
+	defer it.Release()
+
+	records := [][]byte{}
+	for len(records) < limit && it.Next() {
+		records = append(records, common.CopyBytes(it.Value()))
+	}
+	return records, it.Error()
+}
+
+// OrderRecord is an order of a trading transaction indexed under the address of
+// its user.
+type OrderRecord struct {
+	OrderID         uint64         `json:"orderID"`
+	Hash            common.Hash    `json:"hash"`
+	ExchangeAddress common.Address `json:"exchangeAddress"`
+	BaseToken       common.Address `json:"baseToken"`
+	QuoteToken      common.Address `json:"quoteToken"`
+	Side            string         `json:"side"`
+	Type            string         `json:"type"`
+	Status          string         `json:"status"`
+	Price           *big.Int       `json:"price"`
+	Quantity        *big.Int       `json:"quantity"`
+	TxHash          common.Hash    `json:"txHash"`
+	BlockNumber     uint64         `json:"blockNumber"`
+	BlockHash       common.Hash    `json:"blockHash"`
+}
+
+// orderRecords returns the records of the orders of the trading transactions of
+// a block, a record for each order cancelled by a batch.
+func orderRecords(block *types.Block, batches []tradingstate.TxMatchBatch) ([]AddressRecord, error) {
+	var records []AddressRecord
+	for _, batch := range batches {
+		for _, txMatch := range batch.Data {
+			order, err := txMatch.DecodeOrder()
+			if err != nil {
+				return nil, err
+			}
+			record := OrderRecord{
+				OrderID:         order.OrderID,
+				Hash:            order.Hash,
+				ExchangeAddress: order.ExchangeAddress,
+				BaseToken:       order.BaseToken,
+				QuoteToken:      order.QuoteToken,
+				Side:            order.Side,
+				Type:            order.Type,
+				Status:          order.Status,
+				Price:           new(big.Int),
+				Quantity:        new(big.Int),
+				TxHash:          batch.TxHash,
+				BlockNumber:     block.NumberU64(),
+				BlockHash:       block.Hash(),
+			}
+			if order.Price != nil {
+				record.Price.Set(order.Price)
+			}
+			if order.Quantity != nil {
+				record.Quantity.Set(order.Quantity)
+			}
+			cancels := []types.OrderCancel{{OrderID: order.OrderID, Hash: order.Hash}}
+			if len(order.Cancels) > 0 {
+				cancels, record.Status = order.Cancels, tradingstate.OrderStatusCancelled
+			}
+			for _, cancel := range cancels {
+				record.OrderID, record.Hash = cancel.OrderID, cancel.Hash
+				blob, err := rlp.EncodeToBytes(&record)
+				if err != nil {
+					return nil, err
+				}
+				records = append(records, AddressRecord{Address: order.UserAddress, Value: blob})
+			}
+		}
+	}
+	return records, nil
+}
+
+// AddressIndexEnabled returns whether the orders and lending trades are indexed
+// by the addresses of their users.
+func (tomox *TomoX) AddressIndexEnabled() bool {
+	return tomox.orderIndex != nil
+}
+
+// IndexOrders indexes the orders of the trading transactions of a canonical
+// block by the addresses of their users.
+func (tomox *TomoX) IndexOrders(block *types.Block, batches []tradingstate.TxMatchBatch) error {
+	if tomox.orderIndex == nil {
+		return ErrAddressIndexDisabled
+	}
+	records, err := orderRecords(block, batches)
+	if err != nil {
+		return err
+	}
+	return tomox.orderIndex.Index(block, records)
+}
+
+// RollbackOrderIndex deletes the orders indexed by a block reorganised away.
+func (tomox *TomoX) RollbackOrderIndex(block *types.Block) error {
+	if tomox.orderIndex == nil {
+		return ErrAddressIndexDisabled
+	}
+	return tomox.orderIndex.Rollback(block)
+}
+
+// GetOrdersByAddress returns up to limit orders of a user sent in the blocks
+// from the given number on.
+func (tomox *TomoX) GetOrdersByAddress(addr common.Address, from uint64, limit int) ([]*OrderRecord, error) {
+	if tomox.orderIndex == nil {
+		return nil, ErrAddressIndexDisabled
+	}
+	blobs, err := tomox.orderIndex.Records(addr, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]*OrderRecord, 0, len(blobs))
+	for _, blob := range blobs {
+		order := new(OrderRecord)
+		if err := rlp.DecodeBytes(blob, order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
@@ -0,0 +1,76 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func indexedBatch(t *testing.T, orders ...*tradingstate.OrderItem) tradingstate.TxMatchBatch {
+	batch := tradingstate.TxMatchBatch{TxHash: common.HexToHash("0xf1")}
+	for _, order := range orders {
+		order.Signature = &tradingstate.Signature{V: 1}
+		enc, err := tradingstate.EncodeBytesItem(order)
+		if err != nil {
+			t.Fatalf("failed to encode order: %v", err)
+		}
+		batch.Data = append(batch.Data, tradingstate.TxDataMatch{Order: enc})
+	}
+	return batch
+}
+
+func TestOrderIndex(t *testing.T) {
+	var (
+		tomox = &TomoX{orderIndex: NewAddressIndex(rawdb.NewMemoryDatabase(), orderIndexName)}
+		alice = common.HexToAddress("0x01")
+		bob   = common.HexToAddress("0x02")
+	)
+	first := candleBlock(1, 10)
+	if err := tomox.IndexOrders(first, []tradingstate.TxMatchBatch{indexedBatch(t,
+		&tradingstate.OrderItem{UserAddress: alice, Nonce: big.NewInt(1), OrderID: 1, Hash: common.HexToHash("0xa1"), Price: big.NewInt(5), Quantity: big.NewInt(2), Status: tradingstate.OrderNew},
+		&tradingstate.OrderItem{UserAddress: bob, Nonce: big.NewInt(1), OrderID: 2, Hash: common.HexToHash("0xb1"), Status: tradingstate.OrderNew},
+	)}); err != nil {
+		t.Fatalf("failed to index orders: %v", err)
+	}
+	second := candleBlock(2, 12)
+	if err := tomox.IndexOrders(second, []tradingstate.TxMatchBatch{indexedBatch(t,
+		&tradingstate.OrderItem{UserAddress: alice, Nonce: big.NewInt(2), Cancels: []types.OrderCancel{{OrderID: 1, Hash: common.HexToHash("0xa1")}, {OrderID: 3, Hash: common.HexToHash("0xa3")}}},
+	)}); err != nil {
+		t.Fatalf("failed to index orders: %v", err)
+	}
+	orders, err := tomox.GetOrdersByAddress(alice, 0, MaxAddressRecordsPerRequest)
+	if err != nil {
+		t.Fatalf("failed to get orders: %v", err)
+	}
+	if len(orders) != 3 {
+		t.Fatalf("order count mismatch: have %d, want 3", len(orders))
+	}
+	if orders[0].OrderID != 1 || orders[0].Price.Int64() != 5 || orders[0].BlockNumber != 1 || orders[0].TxHash != common.HexToHash("0xf1") {
+		t.Errorf("order mismatch: have %+v", orders[0])
+	}
+	for i, want := range []uint64{1, 3} {
+		if order := orders[1+i]; order.OrderID != want || order.Status != tradingstate.OrderStatusCancelled || order.BlockNumber != 2 {
+			t.Errorf("cancellation %d mismatch: have %+v", i, order)
+		}
+	}
+	if orders, _ := tomox.GetOrdersByAddress(alice, 2, MaxAddressRecordsPerRequest); len(orders) != 2 {
+		t.Errorf("order count from block 2 mismatch: have %d, want 2", len(orders))
+	}
+	if orders, _ := tomox.GetOrdersByAddress(bob, 0, MaxAddressRecordsPerRequest); len(orders) != 1 || orders[0].OrderID != 2 {
+		t.Errorf("orders of another user mismatch: have %v", orders)
+	}
+	// Reorganising the second block away deletes its orders only
+	if err := tomox.RollbackOrderIndex(second); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if orders, _ := tomox.GetOrdersByAddress(alice, 0, MaxAddressRecordsPerRequest); len(orders) != 1 || orders[0].OrderID != 1 {
+		t.Errorf("orders after rollback mismatch: have %v", orders)
+	}
+	if _, err := (&TomoX{}).GetOrdersByAddress(alice, 0, MaxAddressRecordsPerRequest); err != ErrAddressIndexDisabled {
+		t.Errorf("disabled index error mismatch: have %v, want %v", err, ErrAddressIndexDisabled)
+	}
+}
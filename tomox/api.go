@@ -5,6 +5,9 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/rpc"
 )
 
 const (
@@ -40,3 +43,55 @@ func NewPublicTomoXAPI(t *TomoX) *PublicTomoXAPI {
 func (api *PublicTomoXAPI) Version(ctx context.Context) string {
 	return ProtocolVersionStr
 }
+
+// GetCandles returns the candles of a pair over an interval (1m, 5m, 1h or 1d)
+// opened between the from and to unix times included, up to MaxCandlesPerRequest.
+func (api *PublicTomoXAPI) GetCandles(ctx context.Context, baseToken, quoteToken common.Address, interval string, from, to uint64) ([]*Candle, error) {
+	return api.t.GetCandles(baseToken, quoteToken, interval, from, to, MaxCandlesPerRequest)
+}
+
+// GetOrdersByAddress returns the orders of a user sent in the blocks from the
+// given number on, oldest first, up to MaxAddressRecordsPerRequest.
+func (api *PublicTomoXAPI) GetOrdersByAddress(ctx context.Context, address common.Address, fromBlock uint64) ([]*OrderRecord, error) {
+	return api.t.GetOrdersByAddress(address, fromBlock, MaxAddressRecordsPerRequest)
+}
+
+// Candles sends a notification each time a canonical block updates a candle of
+// the pair over the interval, or a reorganisation restores it.
+func (api *PublicTomoXAPI) Candles(ctx context.Context, baseToken, quoteToken common.Address, interval string) (*rpc.Subscription, error) {
+	if _, err := candleInterval(interval); err != nil {
+		return nil, err
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	updates := make(chan []*Candle, 16)
+	candlesSub, err := api.t.SubscribeCandles(updates)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer candlesSub.Unsubscribe()
+		for {
+			select {
+			case candles := <-updates:
+				for _, candle := range candles {
+					if candle.BaseToken == baseToken && candle.QuoteToken == quoteToken && candle.Interval == interval {
+						notifier.Notify(rpcSub.ID, candle)
+					}
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			case <-candlesSub.Err():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -0,0 +1,374 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tomox
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxDAO"
+)
+
+const (
+	MaxCandlesPerRequest = 1000 // Maximum number of candles returned by a request
+
+	candleUndoDepth = 1024 // Number of blocks whose candle updates can be rolled back
+)
+
+var (
+	candlePrefix     = []byte("tomox-candle-")      // candlePrefix + baseToken + quoteToken + interval + openTime -> candle
+	candleUndoPrefix = []byte("tomox-undo-candle-") // candleUndoPrefix + number + hash -> candles before the block
+
+	ErrCandlesDisabled       = errors.New("candle aggregation is disabled")
+	ErrUnknownCandleInterval = errors.New("unknown candle interval")
+)
+
+// candleIntervals are the intervals candles are aggregated over.
+var candleIntervals = []struct {
+	name   string
+	length uint64 // in seconds
+}{
+	{"1m", 60},
+	{"5m", 5 * 60},
+	{"1h", 60 * 60},
+	{"1d", 24 * 60 * 60},
+}
+
+// candleInterval returns the length in seconds of the named candle interval.
+func candleInterval(name string) (uint64, error) {
+	for _, interval := range candleIntervals {
+		if interval.name == name {
+			return interval.length, nil
+		}
+	}
+	return 0, ErrUnknownCandleInterval
+}
+
+// Candle is the open, high, low and close prices and the volume of the trades
+// of a pair matched over an interval.
+type Candle struct {
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+	Interval   string         `json:"interval"`
+	OpenTime   uint64         `json:"openTime"` // Unix time of the start of the interval
+	Open       *big.Int       `json:"open"`
+	High       *big.Int       `json:"high"`
+	Low        *big.Int       `json:"low"`
+	Close      *big.Int       `json:"close"`
+	Volume     *big.Int       `json:"volume"` // Quantity of base token traded
+	Count      uint64         `json:"count"`  // Number of trades
+}
+
+// add updates the candle with a trade.
+func (c *Candle) add(price, quantity *big.Int) {
+	if c.Count == 0 {
+		c.Open, c.High, c.Low = price, price, price
+	}
+	if price.Cmp(c.High) > 0 {
+		c.High = price
+	}
+	if price.Cmp(c.Low) < 0 {
+		c.Low = price
+	}
+	c.Close = price
+	c.Volume = new(big.Int).Add(c.Volume, quantity)
+	c.Count++
+}
+
+// candleUndo is the value of a candle before a block was aggregated, empty if
+// the candle didn't exist.
+type candleUndo struct {
+	Key  []byte
+	Prev []byte
+}
+
+// candleDatabase is the database the candles are persisted in.
+type candleDatabase interface {
+	ethdb.KeyValueReader
+	ethdb.KeyValueWriter
+	ethdb.Batcher
+	ethdb.Iteratee
+}
+
+// candleAggregator aggregates the trades matched in the canonical blocks into
+// candles, and keeps the candles each block modified so that they are rolled
+// back if the block is reorganised away.
+type candleAggregator struct {
+	db    candleDatabase
+	lock  sync.Mutex
+	feed  event.Feed
+	scope event.SubscriptionScope
+}
+
+func newCandleAggregator(db candleDatabase) *candleAggregator {
+	return &candleAggregator{db: db}
+}
+
+// candlesKey returns the prefix of the keys of the candles of a pair over an
+// interval.
+func candlesKey(baseToken, quoteToken common.Address, length uint64) []byte {
+	key := make([]byte, 0, len(candlePrefix)+2*common.AddressLength+8)
+	key = append(key, candlePrefix...)
+	key = append(key, baseToken.Bytes()...)
+	key = append(key, quoteToken.Bytes()...)
+	return append(key, encodeCandleUint(length)...)
+}
+
+// candleUndoKey returns the key of the candles modified by a block.
+func candleUndoKey(number uint64, hash common.Hash) []byte {
+	key := append(append([]byte{}, candleUndoPrefix...), encodeCandleUint(number)...)
+	return append(key, hash.Bytes()...)
+}
+
+func encodeCandleUint(n uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, n)
+	return enc
+}
+
+// read returns the encoded candle at the given key, nil if it doesn't exist.
+func (a *candleAggregator) read(key []byte) ([]byte, error) {
+	if has, err := a.db.Has(key); err != nil || !has {
+		return nil, err
+	}
+	return a.db.Get(key)
+}
+
+// aggregate adds the trades matched in a block to the candles of their pairs.
+func (a *candleAggregator) aggregate(block *types.Block, trades []map[string]string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var (
+		blockTime = block.Time().Uint64()
+		candles   []*Candle
+		updated   = make(map[string]*Candle)
+		undo      []candleUndo
+	)
+	for _, trade := range trades {
+		price := tradingstate.ToBigInt(trade[tradingstate.TradePrice])
+		quantity := tradingstate.ToBigInt(trade[tradingstate.TradeQuantity])
+		if price.Sign() <= 0 || quantity.Sign() <= 0 {
+			continue
+		}
+		baseToken := common.HexToAddress(trade[tradingstate.TradeBaseToken])
+		quoteToken := common.HexToAddress(trade[tradingstate.TradeQuoteToken])
+		for _, interval := range candleIntervals {
+			openTime := blockTime - blockTime%interval.length
+			key := append(candlesKey(baseToken, quoteToken, interval.length), encodeCandleUint(openTime)...)
+			candle, ok := updated[string(key)]
+			if !ok {
+				prev, err := a.read(key)
+				if err != nil {
+					return err
+				}
+				candle = &Candle{BaseToken: baseToken, QuoteToken: quoteToken, Interval: interval.name, OpenTime: openTime, Volume: new(big.Int)}
+				if prev != nil {
+					if err := rlp.DecodeBytes(prev, candle); err != nil {
+						return err
+					}
+				}
+				updated[string(key)] = candle
+				candles = append(candles, candle)
+				undo = append(undo, candleUndo{Key: key, Prev: prev})
+			}
+			candle.add(price, quantity)
+		}
+	}
+	if len(candles) == 0 {
+		return nil
+	}
+	batch := a.db.NewBatch()
+	for _, entry := range undo {
+		blob, err := rlp.EncodeToBytes(updated[string(entry.Key)])
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(entry.Key, blob); err != nil {
+			return err
+		}
+	}
+	blob, err := rlp.EncodeToBytes(undo)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(candleUndoKey(block.NumberU64(), block.Hash()), blob); err != nil {
+		return err
+	}
+	if err := a.pruneUndo(batch, block.NumberU64()); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	a.feed.Send(candles)
+	return nil
+}
+
+// pruneUndo deletes the candles modified by the blocks too old to be rolled
+// back anymore.
+func (a *candleAggregator) pruneUndo(batch ethdb.Batch, number uint64) error {
+	if number < candleUndoDepth {
+		return nil
+	}
+	it := a.db.NewIterator(candleUndoPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(candleUndoPrefix)+8+common.HashLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(candleUndoPrefix):]) > number-candleUndoDepth {
+			break
+		}
+		if err := batch.Delete(common.CopyBytes(key)); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// rollback restores the candles modified by a block reorganised away.
+func (a *candleAggregator) rollback(block *types.Block) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	undoKey := candleUndoKey(block.NumberU64(), block.Hash())
+	blob, err := a.read(undoKey)
+	if err != nil || blob == nil {
+		return err
+	}
+	var undo []candleUndo
+	if err := rlp.DecodeBytes(blob, &undo); err != nil {
+		return err
+	}
+	var (
+		batch    = a.db.NewBatch()
+		restored []*Candle
+	)
+	for i := len(undo) - 1; i >= 0; i-- {
+		if len(undo[i].Prev) == 0 {
+			if err := batch.Delete(undo[i].Key); err != nil {
+				return err
+			}
+			continue
+		}
+		candle := new(Candle)
+		if err := rlp.DecodeBytes(undo[i].Prev, candle); err != nil {
+			return err
+		}
+		if err := batch.Put(undo[i].Key, undo[i].Prev); err != nil {
+			return err
+		}
+		restored = append(restored, candle)
+	}
+	if err := batch.Delete(undoKey); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Debug("Rolled back candles of a reorganised block", "number", block.NumberU64(), "hash", block.Hash(), "candles", len(undo))
+	if len(restored) > 0 {
+		a.feed.Send(restored)
+	}
+	return nil
+}
+
+// candles returns up to limit candles of a pair over an interval, opened
+// between from and to included.
+func (a *candleAggregator) candles(baseToken, quoteToken common.Address, interval string, from, to uint64, limit int) ([]*Candle, error) {
+	length, err := candleInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	it := a.db.NewIterator(candlesKey(baseToken, quoteToken, length), encodeCandleUint(from-from%length))
+	defer it.Release()
+
+	candles := []*Candle{}
+	for len(candles) < limit && it.Next() {
+		candle := new(Candle)
+		if err := rlp.DecodeBytes(it.Value(), candle); err != nil {
+			return nil, err
+		}
+		if candle.OpenTime > to {
+			break
+		}
+		candles = append(candles, candle)
+	}
+	return candles, it.Error()
+}
+
+// subscribe registers a subscription to the candles updated by the blocks.
+func (a *candleAggregator) subscribe(ch chan<- []*Candle) event.Subscription {
+	return a.scope.Track(a.feed.Subscribe(ch))
+}
+
+// CandlesEnabled returns whether the matched trades are aggregated into candles.
+func (tomox *TomoX) CandlesEnabled() bool {
+	return tomox.candles != nil
+}
+
+// AggregateCandles adds the trades matched in a canonical block to the candles.
+func (tomox *TomoX) AggregateCandles(block *types.Block, trades []map[string]string) error {
+	if tomox.candles == nil {
+		return ErrCandlesDisabled
+	}
+	return tomox.candles.aggregate(block, trades)
+}
+
+// RollbackCandles restores the candles modified by a block reorganised away.
+func (tomox *TomoX) RollbackCandles(block *types.Block) error {
+	if tomox.candles == nil {
+		return ErrCandlesDisabled
+	}
+	return tomox.candles.rollback(block)
+}
+
+// RollbackBlockCandles restores the candles held in db that a block modified,
+// for the offline tools rewinding the chain without a running TomoX service.
+func RollbackBlockCandles(db *tomoxDAO.BatchDatabase, block *types.Block) error {
+	return newCandleAggregator(db).rollback(block)
+}
+
+// GetCandles returns up to limit candles of a pair over an interval, opened
+// between from and to included.
+func (tomox *TomoX) GetCandles(baseToken, quoteToken common.Address, interval string, from, to uint64, limit int) ([]*Candle, error) {
+	if tomox.candles == nil {
+		return nil, ErrCandlesDisabled
+	}
+	return tomox.candles.candles(baseToken, quoteToken, interval, from, to, limit)
+}
+
+// SubscribeCandles registers a subscription to the candles updated by the
+// canonical blocks.
+func (tomox *TomoX) SubscribeCandles(ch chan<- []*Candle) (event.Subscription, error) {
+	if tomox.candles == nil {
+		return nil, ErrCandlesDisabled
+	}
+	return tomox.candles.subscribe(ch), nil
+}
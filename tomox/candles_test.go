@@ -0,0 +1,146 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+var (
+	candleBaseToken  = common.HexToAddress("0x0000000000000000000000000000000000000011")
+	candleQuoteToken = common.HexToAddress("0x0000000000000000000000000000000000000022")
+)
+
+func candleBlock(number, time uint64) *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number), Time: new(big.Int).SetUint64(time)})
+}
+
+func candleTrade(price, quantity int64) map[string]string {
+	return map[string]string{
+		tradingstate.TradeBaseToken:  candleBaseToken.Hex(),
+		tradingstate.TradeQuoteToken: candleQuoteToken.Hex(),
+		tradingstate.TradePrice:      big.NewInt(price).String(),
+		tradingstate.TradeQuantity:   big.NewInt(quantity).String(),
+	}
+}
+
+func checkCandle(t *testing.T, candle *Candle, openTime uint64, open, high, low, close, volume int64, count uint64) {
+	t.Helper()
+	if candle.OpenTime != openTime || candle.Open.Int64() != open || candle.High.Int64() != high || candle.Low.Int64() != low ||
+		candle.Close.Int64() != close || candle.Volume.Int64() != volume || candle.Count != count {
+		t.Errorf("candle mismatch: have %+v, want open time %d, ohlc %d/%d/%d/%d, volume %d, count %d", candle, openTime, open, high, low, close, volume, count)
+	}
+}
+
+func TestAggregateCandles(t *testing.T) {
+	candles := newCandleAggregator(rawdb.NewMemoryDatabase())
+
+	// Two blocks within the same minute, and a block of the next minute
+	if err := candles.aggregate(candleBlock(1, 3600), []map[string]string{candleTrade(10, 1), candleTrade(12, 2)}); err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	if err := candles.aggregate(candleBlock(2, 3630), []map[string]string{candleTrade(8, 3), candleTrade(0, 1)}); err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	if err := candles.aggregate(candleBlock(3, 3665), []map[string]string{candleTrade(11, 4)}); err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	minutes, err := candles.candles(candleBaseToken, candleQuoteToken, "1m", 0, 7200, MaxCandlesPerRequest)
+	if err != nil {
+		t.Fatalf("failed to get candles: %v", err)
+	}
+	if len(minutes) != 2 {
+		t.Fatalf("candle count mismatch: have %d, want 2", len(minutes))
+	}
+	checkCandle(t, minutes[0], 3600, 10, 12, 8, 8, 6, 3)
+	checkCandle(t, minutes[1], 3660, 11, 11, 11, 11, 4, 1)
+
+	hours, err := candles.candles(candleBaseToken, candleQuoteToken, "1h", 3600, 3600, MaxCandlesPerRequest)
+	if err != nil || len(hours) != 1 {
+		t.Fatalf("hour candles mismatch: have %v, %v", hours, err)
+	}
+	checkCandle(t, hours[0], 3600, 10, 12, 8, 11, 10, 4)
+
+	// The range and the limit bound the candles returned
+	if minutes, _ := candles.candles(candleBaseToken, candleQuoteToken, "1m", 3630, 7200, MaxCandlesPerRequest); len(minutes) != 2 {
+		t.Errorf("candles opened in the interval of from missing: have %d, want 2", len(minutes))
+	}
+	if minutes, _ := candles.candles(candleBaseToken, candleQuoteToken, "1m", 3661, 7200, MaxCandlesPerRequest); len(minutes) != 1 {
+		t.Errorf("candles before from returned: have %d, want 1", len(minutes))
+	}
+	if minutes, _ := candles.candles(candleBaseToken, candleQuoteToken, "1m", 0, 7200, 1); len(minutes) != 1 || minutes[0].OpenTime != 3600 {
+		t.Errorf("limit mismatch: have %v", minutes)
+	}
+	if minutes, _ := candles.candles(candleQuoteToken, candleBaseToken, "1m", 0, 7200, MaxCandlesPerRequest); len(minutes) != 0 {
+		t.Errorf("candles of another pair returned: have %d", len(minutes))
+	}
+	if _, err := candles.candles(candleBaseToken, candleQuoteToken, "2m", 0, 7200, MaxCandlesPerRequest); err != ErrUnknownCandleInterval {
+		t.Errorf("unknown interval error mismatch: have %v, want %v", err, ErrUnknownCandleInterval)
+	}
+}
+
+func TestRollbackCandles(t *testing.T) {
+	candles := newCandleAggregator(rawdb.NewMemoryDatabase())
+	updates := make(chan []*Candle, 16)
+	sub := candles.subscribe(updates)
+	defer sub.Unsubscribe()
+
+	first, second := candleBlock(1, 60), candleBlock(2, 70)
+	if err := candles.aggregate(first, []map[string]string{candleTrade(10, 1)}); err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	if err := candles.aggregate(second, []map[string]string{candleTrade(20, 2)}); err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	if updated := <-updates; len(updated) != len(candleIntervals) {
+		t.Errorf("updated candle count mismatch: have %d, want %d", len(updated), len(candleIntervals))
+	}
+	<-updates
+
+	// Rolling back the second block restores the candles of the first one
+	if err := candles.rollback(second); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	minutes, _ := candles.candles(candleBaseToken, candleQuoteToken, "1m", 0, 120, MaxCandlesPerRequest)
+	if len(minutes) != 1 {
+		t.Fatalf("candle count mismatch: have %d, want 1", len(minutes))
+	}
+	checkCandle(t, minutes[0], 60, 10, 10, 10, 10, 1, 1)
+	if restored := <-updates; len(restored) != len(candleIntervals) || restored[0].Count != 1 {
+		t.Errorf("restored candles mismatch: have %v", restored)
+	}
+	// Rolling back the first block deletes the candles it created
+	if err := candles.rollback(first); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if minutes, _ := candles.candles(candleBaseToken, candleQuoteToken, "1m", 0, 120, MaxCandlesPerRequest); len(minutes) != 0 {
+		t.Errorf("candles of a rolled back block left: have %v", minutes)
+	}
+	// Blocks already rolled back or without trades are ignored
+	if err := candles.rollback(second); err != nil {
+		t.Errorf("failed to roll back an unknown block: %v", err)
+	}
+}
+
+func TestPruneCandleUndo(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	candles := newCandleAggregator(db)
+
+	old, recent := candleBlock(1, 60), candleBlock(candleUndoDepth, 120)
+	candles.aggregate(old, []map[string]string{candleTrade(10, 1)})
+	candles.aggregate(recent, []map[string]string{candleTrade(10, 1)})
+	if has, _ := db.Has(candleUndoKey(old.NumberU64(), old.Hash())); !has {
+		t.Fatalf("undo of a block within the depth pruned")
+	}
+	candles.aggregate(candleBlock(candleUndoDepth+1, 180), []map[string]string{candleTrade(10, 1)})
+	if has, _ := db.Has(candleUndoKey(old.NumberU64(), old.Hash())); has {
+		t.Errorf("undo of a block beyond the depth not pruned")
+	}
+	if has, _ := db.Has(candleUndoKey(recent.NumberU64(), recent.Hash())); !has {
+		t.Errorf("undo of a recent block pruned")
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tomox
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Since TIPTomoXCrossPair, what the order book of a pair without TOMO can't
+// fill of a market order is routed through TOMO, the only intermediate token:
+// the order trades in two hops against the order books of its base and quote
+// tokens quoted in TOMO, in the order the tokens are spent.
+//
+// A market ask of the base token sells what is left of it for TOMO, then buys
+// the quote token with all the TOMO received. A market bid of the base token
+// first quotes the TOMO cost of what is left of it from the asks of the base
+// token, sells the quote token until that much TOMO is received, then buys up
+// to the quantity left with the TOMO received.
+//
+// Each hop settles ordinary trades of the taker order, the taker paying the fee
+// of its relayer on each of them and the relayers being charged the matching
+// fee of each trade. A route of which the last hop trades nothing is undone,
+// and what the last hop can't spend of the TOMO of the first one stays with
+// the taker.
+
+// isCrossPair reports whether an order is of a pair between two tokens other
+// than TOMO, which can be routed through TOMO.
+func isCrossPair(order *tradingstate.OrderItem) bool {
+	return order.BaseToken.String() != common.TomoNativeAddress && order.QuoteToken.String() != common.TomoNativeAddress
+}
+
+// newHopOrder returns the order trading the token of a cross pair order
+// against TOMO on the given side for one hop of its route.
+func newHopOrder(order *tradingstate.OrderItem, token common.Address, side string) *tradingstate.OrderItem {
+	hop := *order
+	hop.BaseToken = token
+	hop.QuoteToken = common.HexToAddress(common.TomoNativeAddress)
+	hop.Side = side
+	hop.Type = tradingstate.Market
+	return &hop
+}
+
+// routeMarketOrder routes the quantity a market order of a cross pair has left
+// through TOMO, returning the trades of both hops.
+func (tomox *TomoX) routeMarketOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, order *tradingstate.OrderItem, quantity *big.Int) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	var (
+		tomo       = common.HexToAddress(common.TomoNativeAddress)
+		baseBook   = tradingstate.GetTradingOrderBookHash(order.BaseToken, tomo)
+		quoteBook  = tradingstate.GetTradingOrderBookHash(order.QuoteToken, tomo)
+		tomoxSnap  = tradingStateDB.Snapshot()
+		dbSnap     = statedb.Snapshot()
+		trades     []map[string]string
+		rejects    []*tradingstate.OrderItem
+		lastTrades []map[string]string
+		newRejects []*tradingstate.OrderItem
+		err        error
+	)
+	baseTokenDecimal, err := tomox.GetTokenDecimal(chain, statedb, order.BaseToken)
+	if err != nil || baseTokenDecimal.Sign() == 0 {
+		return nil, nil, nil
+	}
+	quoteTokenDecimal, err := tomox.GetTokenDecimal(chain, statedb, order.QuoteToken)
+	if err != nil || quoteTokenDecimal.Sign() == 0 {
+		return nil, nil, nil
+	}
+	feeRate := tradingstate.GetExRelayerFee(order.ExchangeAddress, statedb)
+
+	before := tradingstate.GetTokenBalance(order.UserAddress, tomo, statedb)
+	if order.Side == tradingstate.Ask {
+		sell := newHopOrder(order, order.BaseToken, tradingstate.Ask)
+		sell.Quantity = quantity
+		_, trades, newRejects, err = tomox.matchMarketOrder(header, coinbase, chain, statedb, tradingStateDB, baseBook, sell)
+		if err != nil {
+			return nil, nil, err
+		}
+		rejects = append(rejects, withoutOrder(newRejects, sell)...)
+		received := new(big.Int).Sub(tradingstate.GetTokenBalance(order.UserAddress, tomo, statedb), before)
+		if received.Sign() > 0 {
+			buy := newHopOrder(order, order.QuoteToken, tradingstate.Bid)
+			_, lastTrades, newRejects, err = tomox.buyWithBudget(header, coinbase, chain, statedb, tradingStateDB, quoteBook, buy, received, nil, quoteTokenDecimal, feeRate)
+			if err != nil {
+				return nil, nil, err
+			}
+			rejects = append(rejects, withoutOrder(newRejects, buy)...)
+		}
+	} else {
+		cost := quoteMarketBid(tradingStateDB.Copy(), baseBook, quantity, baseTokenDecimal)
+		cost = new(big.Int).Div(new(big.Int).Mul(cost, new(big.Int).Add(common.TomoXBaseFee, feeRate)), common.TomoXBaseFee)
+		if cost.Sign() > 0 {
+			sell := newHopOrder(order, order.QuoteToken, tradingstate.Ask)
+			trades, newRejects, err = tomox.sellForProceeds(header, coinbase, chain, statedb, tradingStateDB, quoteBook, sell, cost, quoteTokenDecimal, feeRate)
+			if err != nil {
+				return nil, nil, err
+			}
+			rejects = append(rejects, withoutOrder(newRejects, sell)...)
+		}
+		received := new(big.Int).Sub(tradingstate.GetTokenBalance(order.UserAddress, tomo, statedb), before)
+		if received.Sign() > 0 {
+			buy := newHopOrder(order, order.BaseToken, tradingstate.Bid)
+			_, lastTrades, newRejects, err = tomox.buyWithBudget(header, coinbase, chain, statedb, tradingStateDB, baseBook, buy, received, quantity, baseTokenDecimal, feeRate)
+			if err != nil {
+				return nil, nil, err
+			}
+			rejects = append(rejects, withoutOrder(newRejects, buy)...)
+		}
+	}
+	if len(lastTrades) == 0 {
+		log.Debug("Undo the route of a market order without trades on its last hop", "side", order.Side, "quantity", quantity)
+		tradingStateDB.RevertToSnapshot(tomoxSnap)
+		statedb.RevertToSnapshot(dbSnap)
+		return nil, nil, nil
+	}
+	return append(trades, lastTrades...), rejects, nil
+}
+
+// buyWithBudget buys the base token of an order book for TOMO with the hop
+// order, price level after price level, spending at most budget and buying at
+// most maxQuantity if it isn't nil. It returns the quantity bought.
+func (tomox *TomoX) buyWithBudget(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, hop *tradingstate.OrderItem, budget *big.Int, maxQuantity *big.Int, baseTokenDecimal *big.Int, feeRate *big.Int) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
+	var (
+		tomo    = common.HexToAddress(common.TomoNativeAddress)
+		bought  = new(big.Int)
+		trades  []map[string]string
+		rejects []*tradingstate.OrderItem
+	)
+	budget = new(big.Int).Set(budget)
+	for budget.Sign() > 0 {
+		price, _ := tradingStateDB.GetBestAskPrice(orderBook)
+		if price.Sign() == 0 {
+			break
+		}
+		// quantity * price / decimal * (1 + feeRate / baseFee) <= budget
+		quantity := new(big.Int).Mul(budget, baseTokenDecimal)
+		quantity.Mul(quantity, common.TomoXBaseFee)
+		quantity.Div(quantity, new(big.Int).Mul(price, new(big.Int).Add(common.TomoXBaseFee, feeRate)))
+		if maxQuantity != nil {
+			if left := new(big.Int).Sub(maxQuantity, bought); quantity.Cmp(left) > 0 {
+				quantity = left
+			}
+		}
+		if quantity.Sign() <= 0 {
+			break
+		}
+		before := tradingstate.GetTokenBalance(hop.UserAddress, tomo, statedb)
+		remaining, newTrades, newRejects, err := tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, price, quantity, hop)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+		budget.Sub(budget, new(big.Int).Sub(before, tradingstate.GetTokenBalance(hop.UserAddress, tomo, statedb)))
+		bought.Add(bought, new(big.Int).Sub(quantity, remaining))
+		if containsOrder(newRejects, hop) || len(newTrades) == 0 && len(newRejects) == 0 {
+			break
+		}
+	}
+	return bought, trades, rejects, nil
+}
+
+// sellForProceeds sells the base token of an order book for TOMO with the hop
+// order, price level after price level, until the taker received target TOMO.
+func (tomox *TomoX) sellForProceeds(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, hop *tradingstate.OrderItem, target *big.Int, baseTokenDecimal *big.Int, feeRate *big.Int) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	var (
+		tomo     = common.HexToAddress(common.TomoNativeAddress)
+		received = new(big.Int)
+		trades   []map[string]string
+		rejects  []*tradingstate.OrderItem
+	)
+	net := new(big.Int).Sub(common.TomoXBaseFee, feeRate)
+	if net.Sign() <= 0 {
+		return nil, nil, nil
+	}
+	for received.Cmp(target) < 0 {
+		price, _ := tradingStateDB.GetBestBidPrice(orderBook)
+		if price.Sign() == 0 {
+			break
+		}
+		// quantity * price / decimal * (1 - feeRate / baseFee) >= target - received
+		quantity := new(big.Int).Sub(target, received)
+		quantity.Mul(quantity, baseTokenDecimal)
+		quantity.Mul(quantity, common.TomoXBaseFee)
+		divisor := new(big.Int).Mul(price, net)
+		quantity.Add(quantity, new(big.Int).Sub(divisor, common.Big1))
+		quantity.Div(quantity, divisor)
+
+		before := tradingstate.GetTokenBalance(hop.UserAddress, tomo, statedb)
+		_, newTrades, newRejects, err := tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, price, quantity, hop)
+		if err != nil {
+			return nil, nil, err
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+		received.Add(received, new(big.Int).Sub(tradingstate.GetTokenBalance(hop.UserAddress, tomo, statedb), before))
+		if containsOrder(newRejects, hop) || len(newTrades) == 0 && len(newRejects) == 0 {
+			break
+		}
+	}
+	return trades, rejects, nil
+}
+
+// quoteMarketBid returns the TOMO a market bid of the given quantity would pay
+// for the asks of an order book, before fees. The asks are consumed, the order
+// book must be a copy.
+func quoteMarketBid(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, quantity *big.Int, baseTokenDecimal *big.Int) *big.Int {
+	cost := new(big.Int)
+	quantity = new(big.Int).Set(quantity)
+	for quantity.Sign() > 0 {
+		price, _ := tradingStateDB.GetBestAskPrice(orderBook)
+		if price.Sign() == 0 {
+			break
+		}
+		orderId, amount, err := tradingStateDB.GetBestOrderIdAndAmount(orderBook, price, tradingstate.Ask)
+		if err != nil || amount.Sign() == 0 {
+			break
+		}
+		traded := amount
+		if quantity.Cmp(amount) < 0 {
+			traded = quantity
+		}
+		cost.Add(cost, new(big.Int).Div(new(big.Int).Mul(traded, price), baseTokenDecimal))
+		quantity.Sub(quantity, traded)
+		if err := tradingStateDB.SubAmountOrderItem(orderBook, orderId, price, amount, tradingstate.Ask); err != nil {
+			break
+		}
+	}
+	return cost
+}
+
+// withoutOrder returns the orders but the given one, the hop orders standing
+// in for the taker order which is never rejected by its route.
+func withoutOrder(orders []*tradingstate.OrderItem, order *tradingstate.OrderItem) []*tradingstate.OrderItem {
+	var kept []*tradingstate.OrderItem
+	for _, o := range orders {
+		if o != order {
+			kept = append(kept, o)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,282 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tomox
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/p2p"
+)
+
+// The tomox protocol gossips the orders signed for relayers between nodes, so
+// they reach the order pools of the masternodes, and are matched in the special
+// transactions of their blocks, whichever node the relayer sent them to.
+const (
+	statusCode         = 0x00 // status of the node, sent once for the handshake
+	ordersCode         = 0x01 // batch of orders
+	ProtocolLength     = uint64(2)
+	ProtocolMaxMsgSize = 1024 * 1024 // Maximum size of a protocol message
+
+	DefaultOrderGossipRate     = 100   // Default number of orders per second accepted from a peer
+	DefaultOrderGossipPoolSize = 10240 // Default number of gossiped orders kept to be relayed
+
+	orderGossipLifetime = 10 * time.Minute // Time a gossiped order stays in the pool
+	orderGossipCycle    = time.Minute      // Time between the expirations of the pool
+	orderChanSize       = 4096             // Size of the channel listening to the order pool
+	peerQueueSize       = 128              // Number of order batches queued for a peer
+	maxKnownOrders      = 32768            // Maximum order hashes kept as known by a peer
+)
+
+var (
+	gossipInMeter          = metrics.NewRegisteredMeter("tomox/gossip/in", nil)
+	gossipOutMeter         = metrics.NewRegisteredMeter("tomox/gossip/out", nil)
+	gossipRateLimitedMeter = metrics.NewRegisteredMeter("tomox/gossip/ratelimited", nil)
+	gossipRateAbuseMeter   = metrics.NewRegisteredMeter("tomox/gossip/rateabuse", nil)
+	gossipEvictedMeter     = metrics.NewRegisteredMeter("tomox/gossip/evicted", nil)
+)
+
+// OrderPool is the pool of the orders waiting to be matched, which validates the
+// gossiped orders and announces the orders it accepts.
+type OrderPool interface {
+	AddRemotes(txs []*types.OrderTransaction) []error
+	SubscribeTxPreEvent(ch chan<- core.OrderTxPreEvent) event.Subscription
+}
+
+// orderGossipPool is the bounded pool of the orders gossiped recently, so each
+// of them is relayed once. Once full, the oldest orders are evicted first.
+type orderGossipPool struct {
+	lock   sync.RWMutex
+	size   int
+	orders map[common.Hash]*types.OrderTransaction
+	added  map[common.Hash]time.Time
+	queue  []common.Hash // hashes of the orders in order of arrival
+}
+
+func newOrderGossipPool(size int) *orderGossipPool {
+	return &orderGossipPool{
+		size:   size,
+		orders: make(map[common.Hash]*types.OrderTransaction),
+		added:  make(map[common.Hash]time.Time),
+	}
+}
+
+// add inserts an order into the pool, returning false if it was already known.
+func (pool *orderGossipPool) add(tx *types.OrderTransaction, now time.Time) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	hash := tx.Hash()
+	if _, ok := pool.orders[hash]; ok {
+		return false
+	}
+	for len(pool.queue) >= pool.size {
+		pool.remove(pool.queue[0])
+		gossipEvictedMeter.Mark(1)
+	}
+	pool.orders[hash] = tx
+	pool.added[hash] = now
+	pool.queue = append(pool.queue, hash)
+	return true
+}
+
+// remove drops the oldest order of the pool, which must be the given one.
+func (pool *orderGossipPool) remove(hash common.Hash) {
+	delete(pool.orders, hash)
+	delete(pool.added, hash)
+	pool.queue = pool.queue[1:]
+}
+
+// has returns whether the order with the given hash is in the pool.
+func (pool *orderGossipPool) has(hash common.Hash) bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	_, ok := pool.orders[hash]
+	return ok
+}
+
+// expire drops the orders gossiped longer than orderGossipLifetime ago, and
+// returns their number.
+func (pool *orderGossipPool) expire(now time.Time) int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	expired := 0
+	for len(pool.queue) > 0 && now.Sub(pool.added[pool.queue[0]]) > orderGossipLifetime {
+		pool.remove(pool.queue[0])
+		expired++
+	}
+	return expired
+}
+
+// pending returns the orders of the pool in order of arrival.
+func (pool *orderGossipPool) pending() []*types.OrderTransaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	txs := make([]*types.OrderTransaction, 0, len(pool.queue))
+	for _, hash := range pool.queue {
+		txs = append(txs, pool.orders[hash])
+	}
+	return txs
+}
+
+// len returns the number of orders in the pool.
+func (pool *orderGossipPool) len() int {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return len(pool.queue)
+}
+
+// SetOrderPool sets the pool the gossiped orders are added to, and whose orders
+// are gossiped. Without it, the node takes no part in the gossip.
+func (tomox *TomoX) SetOrderPool(pool OrderPool) {
+	tomox.orderPool = pool
+}
+
+// HandlePeer is called by the p2p server for each peer supporting the tomox
+// protocol, and runs it until the peer disconnects.
+func (tomox *TomoX) HandlePeer(remote *p2p.Peer, rw p2p.MsgReadWriter) error {
+	p := newPeer(tomox, remote, rw)
+	if err := p.handshake(); err != nil {
+		return err
+	}
+	tomox.peersLock.Lock()
+	tomox.peers[p] = struct{}{}
+	tomox.peersLock.Unlock()
+
+	defer func() {
+		tomox.peersLock.Lock()
+		delete(tomox.peers, p)
+		tomox.peersLock.Unlock()
+	}()
+
+	go p.broadcast()
+	defer p.stop()
+
+	p.send(tomox.gossip.pending())
+	return tomox.runMessageLoop(p)
+}
+
+// runMessageLoop reads and processes the messages of a peer.
+func (tomox *TomoX) runMessageLoop(p *peer) error {
+	for {
+		packet, err := p.rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if packet.Size > ProtocolMaxMsgSize {
+			packet.Discard()
+			return fmt.Errorf("peer [%x] sent oversized message: %d > %d", p.ID(), packet.Size, ProtocolMaxMsgSize)
+		}
+		switch packet.Code {
+		case ordersCode:
+			var txs []*types.OrderTransaction
+			if err := packet.Decode(&txs); err != nil {
+				return fmt.Errorf("peer [%x] sent invalid orders: %v", p.ID(), err)
+			}
+			if err := tomox.handleOrders(p, txs, time.Now()); err != nil {
+				return fmt.Errorf("peer [%x] sent too many orders: %v", p.ID(), err)
+			}
+		default:
+			// New message types might be implemented in the future versions.
+		}
+		packet.Discard()
+	}
+}
+
+// handleOrders adds the orders received from a peer, within its rate limit, to
+// the order pool. The orders the pool accepts are relayed to the other peers.
+// It returns an error if the peer persistently exceeds its rate limit.
+func (tomox *TomoX) handleOrders(p *peer, txs []*types.OrderTransaction, now time.Time) error {
+	allowed, err := p.limiter.Allow(ordersCode, len(txs), now)
+	if err != nil {
+		gossipRateAbuseMeter.Mark(1)
+		return err
+	}
+	if allowed < len(txs) {
+		log.Debug("Dropped orders over the rate limit of the peer", "peer", p.ID(), "orders", len(txs)-allowed)
+		gossipRateLimitedMeter.Mark(int64(len(txs) - allowed))
+		txs = txs[:allowed]
+	}
+	unknown := make([]*types.OrderTransaction, 0, len(txs))
+	for _, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		p.mark(tx.Hash())
+		if !tomox.gossip.has(tx.Hash()) {
+			unknown = append(unknown, tx)
+		}
+	}
+	if len(unknown) == 0 || tomox.orderPool == nil {
+		return nil
+	}
+	gossipInMeter.Mark(int64(len(unknown)))
+	tomox.orderPool.AddRemotes(unknown)
+	return nil
+}
+
+// broadcastOrders queues the orders to all the peers which don't know them.
+func (tomox *TomoX) broadcastOrders(txs []*types.OrderTransaction) {
+	tomox.peersLock.RLock()
+	defer tomox.peersLock.RUnlock()
+
+	for p := range tomox.peers {
+		p.send(txs)
+	}
+}
+
+// gossipLoop gossips the orders entering the order pool, and expires the pool
+// of gossiped orders.
+func (tomox *TomoX) gossipLoop() {
+	if tomox.orderPool == nil {
+		return
+	}
+	ch := make(chan core.OrderTxPreEvent, orderChanSize)
+	sub := tomox.orderPool.SubscribeTxPreEvent(ch)
+	defer sub.Unsubscribe()
+
+	expire := time.NewTicker(orderGossipCycle)
+	defer expire.Stop()
+
+	for {
+		select {
+		case ev := <-ch:
+			if tomox.gossip.add(ev.Tx, time.Now()) {
+				tomox.broadcastOrders([]*types.OrderTransaction{ev.Tx})
+			}
+		case <-expire.C:
+			tomox.gossip.expire(time.Now())
+
+		// Err() channel will be closed when unsubscribing.
+		case <-sub.Err():
+			return
+		case <-tomox.quit:
+			return
+		}
+	}
+}
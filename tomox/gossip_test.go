@@ -0,0 +1,139 @@
+package tomox
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// testOrderPool is an order pool accepting all the orders added to it.
+type testOrderPool struct {
+	feed  event.Feed
+	added chan []*types.OrderTransaction
+}
+
+func newTestOrderPool() *testOrderPool {
+	return &testOrderPool{added: make(chan []*types.OrderTransaction, 16)}
+}
+
+func (pool *testOrderPool) AddRemotes(txs []*types.OrderTransaction) []error {
+	pool.added <- txs
+	return make([]error, len(txs))
+}
+
+func (pool *testOrderPool) SubscribeTxPreEvent(ch chan<- core.OrderTxPreEvent) event.Subscription {
+	return pool.feed.Subscribe(ch)
+}
+
+func newTestGossipHost(pool OrderPool, rate, size int) *TomoX {
+	return &TomoX{
+		orderPool:  pool,
+		gossip:     newOrderGossipPool(size),
+		gossipRate: rate,
+		peers:      make(map[*peer]struct{}),
+		quit:       make(chan struct{}),
+	}
+}
+
+func testOrders(n int) []*types.OrderTransaction {
+	txs := make([]*types.OrderTransaction, n)
+	for i := range txs {
+		txs[i] = types.NewOrderTransaction(uint64(i), big.NewInt(1), big.NewInt(1), common.Address{}, common.Address{}, common.Address{}, common.Address{}, tradingstate.OrderNew, tradingstate.Bid, tradingstate.Limit, common.Hash{}, 0)
+	}
+	return txs
+}
+
+func TestOrderGossipPool(t *testing.T) {
+	pool := newOrderGossipPool(3)
+	txs := testOrders(4)
+	now := time.Now()
+	for i, tx := range txs {
+		if !pool.add(tx, now.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("order %d not added", i)
+		}
+	}
+	if pool.add(txs[3], now) {
+		t.Errorf("known order added again")
+	}
+	// The oldest order is evicted once the pool is full
+	if pool.len() != 3 || pool.has(txs[0].Hash()) {
+		t.Errorf("pool mismatch: have %d orders, oldest evicted %v", pool.len(), !pool.has(txs[0].Hash()))
+	}
+	if pending := pool.pending(); len(pending) != 3 || pending[0] != txs[1] || pending[2] != txs[3] {
+		t.Errorf("pending orders mismatch: have %v", pending)
+	}
+	if expired := pool.expire(now.Add(orderGossipLifetime + 2*time.Minute + time.Second)); expired != 2 || pool.len() != 1 || !pool.has(txs[3].Hash()) {
+		t.Errorf("expiration mismatch: have %d expired, %d left", expired, pool.len())
+	}
+}
+
+func TestHandleOrders(t *testing.T) {
+	pool := newTestOrderPool()
+	host := newTestGossipHost(pool, 10, 16)
+	p := newPeer(host, p2p.NewPeer(discover.NodeID{1}, "peer", nil), nil)
+
+	// The orders over the rate limit of the peer are dropped
+	txs := testOrders(15)
+	if err := host.handleOrders(p, txs, time.Now()); err != nil {
+		t.Fatalf("failed to handle orders: %v", err)
+	}
+	if added := <-pool.added; len(added) != 10 {
+		t.Errorf("added orders mismatch: have %d, want 10", len(added))
+	}
+	// Orders already gossiped aren't added again
+	host.gossip.add(txs[10], time.Now())
+	if err := host.handleOrders(p, txs[10:12], time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("failed to handle orders: %v", err)
+	}
+	if added := <-pool.added; len(added) != 1 || added[0] != txs[11] {
+		t.Errorf("added orders mismatch: have %v, want the unknown order", added)
+	}
+	if !p.known.Contains(txs[10].Hash()) || !p.known.Contains(txs[11].Hash()) {
+		t.Errorf("orders received from the peer not marked as known")
+	}
+	// Flooding the peer's rate limit is abusive
+	if err := host.handleOrders(p, testOrders(200), time.Now().Add(time.Second)); err != p2p.ErrMsgRateAbuse {
+		t.Errorf("flood error mismatch: have %v, want %v", err, p2p.ErrMsgRateAbuse)
+	}
+}
+
+// Tests that the orders entering the order pool of a node are gossiped to the
+// order pools of its peers.
+func TestOrderGossip(t *testing.T) {
+	poolA, poolB := newTestOrderPool(), newTestOrderPool()
+	hostA, hostB := newTestGossipHost(poolA, 10, 16), newTestGossipHost(poolB, 10, 16)
+	defer close(hostA.quit)
+
+	rwA, rwB := p2p.MsgPipe()
+	defer rwA.Close()
+	go hostA.HandlePeer(p2p.NewPeer(discover.NodeID{2}, "b", nil), rwA)
+	go hostB.HandlePeer(p2p.NewPeer(discover.NodeID{1}, "a", nil), rwB)
+	go hostA.gossipLoop()
+
+	tx := testOrders(1)[0]
+	for deadline := time.Now().Add(time.Second); poolA.feed.Send(core.OrderTxPreEvent{Tx: tx}) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("gossip loop not subscribed to the order pool")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case added := <-poolB.added:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("gossiped orders mismatch: have %v", added)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("order not gossiped")
+	}
+	if !hostA.gossip.has(tx.Hash()) {
+		t.Errorf("gossiped order missing from the pool")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/tomochain/tomochain/core/types"
 	"math/big"
+	"sort"
 	"strconv"
 	"time"
 
@@ -60,6 +61,18 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 		rejects = append(rejects, order)
 		return trades, rejects, nil
 	}
+	if len(order.Cancels) > 0 {
+		if !chain.Config().IsTIPTomoXBatchCancel(header.Number) {
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+		err, reject := tomox.ProcessBatchCancelOrder(header, tradingStateDB, statedb, chain, coinbase, orderBook, order)
+		if err != nil || reject {
+			log.Debug("Reject batch cancellation", "cancels", len(order.Cancels), "err", err)
+			rejects = append(rejects, order)
+		}
+		return trades, rejects, nil
+	}
 	if order.Status == tradingstate.OrderStatusCancelled {
 		err, reject := tomox.ProcessCancelOrder(header, tradingStateDB, statedb, chain, coinbase, orderBook, order)
 		if err != nil || reject {
@@ -68,7 +81,7 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 		}
 		return trades, rejects, nil
 	}
-	if order.Type != tradingstate.Market {
+	if order.Type != tradingstate.Market && order.Type != tradingstate.StopMarket {
 		if order.Price.Sign() == 0 || common.BigToHash(order.Price).Big().Cmp(order.Price) != 0 {
 			log.Debug("Reject order price invalid", "price", order.Price)
 			rejects = append(rejects, order)
@@ -80,19 +93,88 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 		rejects = append(rejects, order)
 		return trades, rejects, nil
 	}
+	if order.IsIcebergOrder() && !chain.Config().IsTIPTomoXIcebergOrder(header.Number) {
+		log.Debug("Reject iceberg order before TIPTomoXIcebergOrder", "displayQuantity", order.DisplayQuantity)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.IsPostOnly() && !chain.Config().IsTIPTomoXPostOnly(header.Number) {
+		log.Debug("Reject post-only order before TIPTomoXPostOnly", "timeInForce", order.TimeInForce)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.IsImmediate() && !chain.Config().IsTIPTomoXTimeInForce(header.Number) {
+		log.Debug("Reject immediate order before TIPTomoXTimeInForce", "timeInForce", order.TimeInForce)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.SelfTradePrevention != "" && !chain.Config().IsTIPTomoXSelfTrade(header.Number) {
+		log.Debug("Reject order preventing self-trades before TIPTomoXSelfTrade", "selfTradePrevention", order.SelfTradePrevention)
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
+	if order.ExpiresAt != 0 {
+		if !chain.Config().IsTIPTomoXOrderExpiry(header.Number) {
+			log.Debug("Reject good-till-date order before TIPTomoXOrderExpiry", "expiresAt", order.ExpiresAt)
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+		if order.IsExpired(header.Time.Uint64()) {
+			log.Debug("Reject expired order", "expiresAt", order.ExpiresAt, "time", header.Time)
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+	}
+	if order.IsStopOrder() {
+		if !chain.Config().IsTIPTomoXStopOrder(header.Number) {
+			log.Debug("Reject stop order before TIPTomoXStopOrder", "type", order.Type)
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+		log.Debug("Process stop order", "side", order.Side, "quantity", order.Quantity, "triggerPrice", order.TriggerPrice)
+		tomox.processStopOrder(tradingStateDB, orderBook, order)
+		return trades, rejects, nil
+	}
+	if order.IsReplacement() {
+		if !chain.Config().IsTIPTomoXReplaceOrder(header.Number) {
+			log.Debug("Reject replacement before TIPTomoXReplaceOrder", "replacedOrderId", order.ReplacedOrderID)
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+		log.Debug("Process replacement", "side", order.Side, "quantity", order.Quantity, "price", order.Price, "replacedOrderId", order.ReplacedOrderID)
+		trades, rejects, err = tomox.processReplaceOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		if err != nil {
+			log.Debug("Reject replacement", "err", err, "order", tradingstate.ToJSON(order))
+			trades = []map[string]string{}
+			rejects = append(rejects, order)
+		}
+		return trades, rejects, nil
+	}
 	orderType := order.Type
 	// if we do not use auto-increment orderid, we must set price slot to avoid conflict
 	if orderType == tradingstate.Market {
 		log.Debug("Process maket order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = tomox.processMarketOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		trades, rejects, err = tomox.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject market order", "err", err, "order", tradingstate.ToJSON(order))
 			trades = []map[string]string{}
 			rejects = append(rejects, order)
 		}
+	} else if order.IsPostOnly() && crossesSpread(tradingStateDB, orderBook, order) {
+		log.Debug("Reject post-only order crossing the spread", "side", order.Side, "price", order.Price)
+		order.ExtraData = tradingstate.RejectReasonPostOnly
+		rejects = append(rejects, order)
+	} else if order.IsImmediate() {
+		log.Debug("Process immediate order", "side", order.Side, "quantity", order.Quantity, "price", order.Price, "timeInForce", order.TimeInForce)
+		trades, rejects, err = tomox.processImmediateOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		if err != nil {
+			log.Debug("Reject immediate order", "err", err, "order", tradingstate.ToJSON(order))
+			trades = []map[string]string{}
+			rejects = append(rejects, order)
+		}
 	} else {
 		log.Debug("Process limit order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = tomox.processLimitOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		trades, rejects, err = tomox.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject limit order", "err", err, "order", tradingstate.ToJSON(order))
 			trades = []map[string]string{}
@@ -104,7 +186,25 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 }
 
 // processMarketOrder : process the market order
-func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processMarketOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	quantityToTrade, trades, rejects, err := tomox.matchMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	if quantityToTrade.Sign() > 0 && !containsOrder(rejects, order) && chain.Config().IsTIPTomoXCrossPair(header.Number) && isCrossPair(order) {
+		routedTrades, routedRejects, err := tomox.routeMarketOrder(header, coinbase, chain, statedb, tradingStateDB, order, quantityToTrade)
+		if err != nil {
+			return nil, nil, err
+		}
+		trades = append(trades, routedTrades...)
+		rejects = append(rejects, routedRejects...)
+	}
+	return trades, rejects, nil
+}
+
+// matchMarketOrder matches the market order against the other side of the
+// order book, returning the quantity left unmatched
+func (tomox *TomoX) matchMarketOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
@@ -120,9 +220,9 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 		bestPrice, volume := tradingStateDB.GetBestAskPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
@@ -133,9 +233,9 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 		bestPrice, volume := tradingStateDB.GetBestBidPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
@@ -143,12 +243,31 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 			log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		}
 	}
-	return trades, rejects, nil
+	return quantityToTrade, trades, rejects, nil
 }
 
 // processLimitOrder : process the limit order, can change the quote
 // If not care for performance, we should make a copy of quote to prevent further reference problem
-func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processLimitOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	quantityToTrade, trades, rejects, err := tomox.matchLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	if quantityToTrade.Cmp(tradingstate.Zero) > 0 {
+		orderId := tradingStateDB.GetNonce(orderBook)
+		order.OrderID = orderId + 1
+		order.Quantity = quantityToTrade
+		tradingStateDB.SetNonce(orderBook, orderId+1)
+		orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.OrderID))
+		tradingStateDB.InsertOrderItem(orderBook, orderIdHash, *order)
+		log.Debug("After matching, order (unmatched part) is now added to tree", "side", order.Side, "order", order)
+	}
+	return trades, rejects, nil
+}
+
+// matchLimitOrder matches the limit order against the other side of the order
+// book as far as its price allows, returning the quantity left unmatched
+func (tomox *TomoX) matchLimitOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
@@ -168,9 +287,9 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 		log.Debug("processLimitOrder ", "side", side, "minPrice", minPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(minPrice) >= 0 && minPrice.Cmp(zero) > 0 {
 			log.Debug("Min price in asks tree", "price", minPrice.String())
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
@@ -183,9 +302,9 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 		log.Debug("processLimitOrder ", "side", side, "maxPrice", maxPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(maxPrice) <= 0 && maxPrice.Cmp(zero) > 0 {
 			log.Debug("Max price in bids tree", "price", maxPrice.String())
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
 			trades = append(trades, newTrades...)
 			rejects = append(rejects, newRejects...)
@@ -194,20 +313,128 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 			log.Debug("processLimitOrder ", "side", side, "maxPrice", maxPrice, "orderPrice", price, "volume", volume)
 		}
 	}
-	if quantityToTrade.Cmp(zero) > 0 {
-		orderId := tradingStateDB.GetNonce(orderBook)
-		order.OrderID = orderId + 1
-		order.Quantity = quantityToTrade
-		tradingStateDB.SetNonce(orderBook, orderId+1)
-		orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.OrderID))
-		tradingStateDB.InsertOrderItem(orderBook, orderIdHash, *order)
-		log.Debug("After matching, order (unmatched part) is now added to tree", "side", order.Side, "order", order)
+	return quantityToTrade, trades, rejects, nil
+}
+
+// processImmediateOrder : match an immediate-or-cancel or fill-or-kill limit
+// order, of which nothing is ever added to the order book. What isn't matched
+// of an immediate-or-cancel order is cancelled, and a fill-or-kill order which
+// can't be filled entirely is rejected without any trade.
+func (tomox *TomoX) processImmediateOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	if order.TimeInForce == tradingstate.FillOrKill {
+		// try the order on copies of the states first, none of its trades may
+		// be applied unless it is filled
+		quantityToTrade, _, rejects, err := tomox.matchLimitOrder(header, coinbase, chain, statedb.Copy(), tradingStateDB.Copy(), orderBook, order)
+		if err != nil {
+			return nil, nil, err
+		}
+		if quantityToTrade.Sign() > 0 || containsOrder(rejects, order) {
+			order.ExtraData = tradingstate.RejectReasonFillOrKill
+			return []map[string]string{}, []*tradingstate.OrderItem{order}, nil
+		}
+	}
+	quantityToTrade, trades, rejects, err := tomox.matchLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a taker rejected while matching has nothing left to trade
+	if quantityToTrade.Sign() > 0 && !containsOrder(rejects, order) {
+		order.ExtraData = tradingstate.RejectReasonImmediateOrCancel
+		rejects = append(rejects, order)
 	}
 	return trades, rejects, nil
 }
 
+// processReplaceOrder : cancel the resting order replaced by a limit order and
+// process the limit order in its place, atomically: if either can't be done,
+// the replacement is rejected and the replaced order left untouched. A
+// replacement at the same price which doesn't increase the quantity takes the
+// order id, and so the queue position, of the order it replaces; any other is
+// processed as a new order. The cancellation of the replaced order is returned
+// among the rejected orders, with its cancellation fee as extra data.
+func (tomox *TomoX) processReplaceOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	replaced := tradingStateDB.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(order.ReplacedOrderID)))
+	if tradingstate.IsEmptyOrder(replaced) || replaced.Quantity == nil || replaced.Quantity.Sign() == 0 || replaced.IsStopOrder() ||
+		replaced.UserAddress != order.UserAddress || replaced.ExchangeAddress != order.ExchangeAddress || replaced.Side != order.Side {
+		log.Debug("Reject replacement of an order which can't be replaced", "replacedOrderId", order.ReplacedOrderID, "user", order.UserAddress)
+		order.ExtraData = tradingstate.RejectReasonReplace
+		return []map[string]string{}, []*tradingstate.OrderItem{order}, nil
+	}
+	tomoxSnap := tradingStateDB.Snapshot()
+	dbSnap := statedb.Snapshot()
+	revert := func() {
+		tradingStateDB.RevertToSnapshot(tomoxSnap)
+		statedb.RevertToSnapshot(dbSnap)
+	}
+	cancel := replaced
+	cancel.Status = tradingstate.OrderStatusCancelled
+	if err, reject := tomox.ProcessCancelOrder(header, tradingStateDB, statedb, chain, coinbase, orderBook, &cancel); err != nil || reject {
+		log.Debug("Reject replacement of an order which can't be cancelled", "replacedOrderId", order.ReplacedOrderID, "err", err)
+		revert()
+		order.ExtraData = tradingstate.RejectReasonReplace
+		return []map[string]string{}, []*tradingstate.OrderItem{order}, nil
+	}
+	var (
+		trades  []map[string]string
+		rejects []*tradingstate.OrderItem
+		err     error
+	)
+	switch {
+	case order.Price.Cmp(replaced.Price) == 0 && order.Quantity.Cmp(replaced.Quantity) <= 0 && !order.IsImmediate():
+		order.OrderID = replaced.OrderID
+		tradingStateDB.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), *order)
+	case order.IsPostOnly() && crossesSpread(tradingStateDB, orderBook, order):
+		order.ExtraData = tradingstate.RejectReasonPostOnly
+		rejects = append(rejects, order)
+	case order.IsImmediate():
+		trades, rejects, err = tomox.processImmediateOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	default:
+		trades, rejects, err = tomox.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// a replacement rejected without any trade replaces nothing
+	if len(trades) == 0 && containsOrder(rejects, order) {
+		revert()
+		return []map[string]string{}, []*tradingstate.OrderItem{order}, nil
+	}
+	return trades, append(rejects, &cancel), nil
+}
+
+// containsOrder reports whether order is one of orders
+func containsOrder(orders []*tradingstate.OrderItem, order *tradingstate.OrderItem) bool {
+	for _, o := range orders {
+		if o == order {
+			return true
+		}
+	}
+	return false
+}
+
+// crossesSpread reports whether a limit order would be matched against the best
+// order of the other side of the order book
+func crossesSpread(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) bool {
+	if order.Side == tradingstate.Bid {
+		bestAsk, _ := tradingStateDB.GetBestAskPrice(orderBook)
+		return bestAsk.Sign() > 0 && order.Price.Cmp(bestAsk) >= 0
+	}
+	bestBid, _ := tradingStateDB.GetBestBidPrice(orderBook)
+	return bestBid.Sign() > 0 && order.Price.Cmp(bestBid) <= 0
+}
+
+// processStopOrder : rest the stop order until its trigger price is reached,
+// taking the next order id of the order book
+func (tomox *TomoX) processStopOrder(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) {
+	orderId := tradingStateDB.GetNonce(orderBook)
+	order.OrderID = orderId + 1
+	tradingStateDB.SetNonce(orderBook, orderId+1)
+	orderIdHash := common.BigToHash(new(big.Int).SetUint64(order.OrderID))
+	tradingStateDB.InsertOrderItem(orderBook, orderIdHash, *order)
+}
+
 // processOrderList : process the order list
-func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processOrderList(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
 	quantityToTrade := tradingstate.CloneBigInt(quantityStillToTrade)
 	log.Debug("Process matching between order and orderlist", "quantityToTrade", quantityToTrade)
 	var (
@@ -225,6 +452,25 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 		if oldestOrder.Quantity == nil || oldestOrder.Quantity.Sign() == 0 && amount.Sign() == 0 {
 			break
 		}
+		if order.IsSelfTrade(&oldestOrder) {
+			// the resting order is cancelled unless only the newest is, the
+			// taker is cancelled unless only the oldest is
+			if order.SelfTradePrevention != tradingstate.CancelNewest {
+				if err := tradingStateDB.CancelOrder(orderBook, &oldestOrder); err != nil {
+					return nil, nil, nil, err
+				}
+				oldestOrder.ExtraData = tradingstate.RejectReasonSelfTrade
+				rejects = append(rejects, &oldestOrder)
+			}
+			if order.SelfTradePrevention != tradingstate.CancelOldest {
+				log.Debug("Reject taker preventing a self-trade", "orderId", orderId, "selfTradePrevention", order.SelfTradePrevention)
+				order.ExtraData = tradingstate.RejectReasonSelfTrade
+				rejects = append(rejects, order)
+				quantityToTrade = tradingstate.Zero
+				break
+			}
+			continue
+		}
 		var (
 			tradedQuantity    *big.Int
 			maxTradedQuantity *big.Int
@@ -254,7 +500,7 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 		} else {
 			quotePrice = common.BasePrice
 		}
-		tradedQuantity, rejectMaker, settleBalanceResult, err := tomox.getTradeQuantity(quotePrice, coinbase, chain, statedb, order, &oldestOrder, maxTradedQuantity)
+		tradedQuantity, rejectMaker, settleBalanceResult, err := tomox.getTradeQuantity(header, quotePrice, coinbase, chain, statedb, order, &oldestOrder, maxTradedQuantity)
 		if err != nil && err == tradingstate.ErrQuantityTradeTooSmall {
 			if tradedQuantity.Cmp(maxTradedQuantity) == 0 {
 				if quantityToTrade.Cmp(amount) == 0 { // reject Taker & maker
@@ -320,6 +566,9 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 			if settleBalanceResult != nil {
 				tradeRecord[tradingstate.MakerFee] = settleBalanceResult.Maker.Fee.Text(10)
 				tradeRecord[tradingstate.TakerFee] = settleBalanceResult.Taker.Fee.Text(10)
+				if settleBalanceResult.Maker.Rebate != nil {
+					tradeRecord[tradingstate.MakerRebate] = settleBalanceResult.Maker.Rebate.Text(10)
+				}
 			}
 			// maker price is actual price
 			// Taker price is offer price
@@ -361,7 +610,7 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 	return quantityToTrade, trades, rejects, nil
 }
 
-func (tomox *TomoX) getTradeQuantity(quotePrice *big.Int, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quantityToTrade *big.Int) (*big.Int, bool, *tradingstate.SettleBalance, error) {
+func (tomox *TomoX) getTradeQuantity(header *types.Header, quotePrice *big.Int, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quantityToTrade *big.Int) (*big.Int, bool, *tradingstate.SettleBalance, error) {
 	baseTokenDecimal, err := tomox.GetTokenDecimal(chain, statedb, makerOrder.BaseToken)
 	if err != nil || baseTokenDecimal.Sign() == 0 {
 		return tradingstate.Zero, false, nil, fmt.Errorf("Fail to get tokenDecimal. Token: %v . Err: %v", makerOrder.BaseToken.String(), err)
@@ -390,6 +639,16 @@ func (tomox *TomoX) getTradeQuantity(quotePrice *big.Int, coinbase common.Addres
 	}
 	takerFeeRate := tradingstate.GetExRelayerFee(takerOrder.ExchangeAddress, statedb)
 	makerFeeRate := tradingstate.GetExRelayerFee(makerOrder.ExchangeAddress, statedb)
+	makerRebateRate := big.NewInt(0)
+	// The rebate is funded from the taker fee received by the relayer of the
+	// taker, so a relayer only pays it on the trades between its own orders
+	if chain.Config().IsTIPTomoXMakerRebate(header.Number) && makerOrder.ExchangeAddress == takerOrder.ExchangeAddress {
+		makerRebateRate = tradingstate.GetExRelayerMakerRebate(makerOrder.ExchangeAddress, statedb)
+		if makerRebateRate.Sign() > 0 {
+			// makers of a relayer paying a rebate aren't charged a maker fee
+			makerFeeRate = big.NewInt(0)
+		}
+	}
 	var takerBalance, makerBalance *big.Int
 	switch takerOrder.Side {
 	case tradingstate.Bid:
@@ -410,6 +669,7 @@ func (tomox *TomoX) getTradeQuantity(quotePrice *big.Int, coinbase common.Addres
 		settleBalanceResult, err = tradingstate.GetSettleBalance(quotePrice, takerOrder.Side, takerFeeRate, makerOrder.BaseToken, makerOrder.QuoteToken, makerOrder.Price, makerFeeRate, baseTokenDecimal, quoteTokenDecimal, quantity)
 		log.Debug("GetSettleBalance", "settleBalanceResult", settleBalanceResult, "err", err)
 		if err == nil {
+			settleBalanceResult.ApplyMakerRebate(takerOrder.Side, makerOrder.Price, baseTokenDecimal, makerRebateRate, quantity)
 			err = DoSettleBalance(coinbase, takerOrder, makerOrder, settleBalanceResult, statedb)
 		}
 		return quantity, rejectMaker, settleBalanceResult, err
@@ -558,7 +818,12 @@ func DoSettleBalance(coinbase common.Address, takerOrder, makerOrder *tradingsta
 		mapBalances[settleBalance.Maker.OutToken] = map[common.Address]*big.Int{}
 	}
 	mapBalances[settleBalance.Maker.OutToken][makerOrder.UserAddress] = newMakerOutTotal
-	newTakerFee, err := tradingstate.CheckAddTokenBalance(takerExOwner, settleBalance.Taker.Fee, makerOrder.QuoteToken, statedb, mapBalances)
+	takerExFee := settleBalance.Taker.Fee
+	if settleBalance.Maker.Rebate != nil {
+		// the maker rebate is paid out of the taker fee
+		takerExFee = new(big.Int).Sub(takerExFee, settleBalance.Maker.Rebate)
+	}
+	newTakerFee, err := tradingstate.CheckAddTokenBalance(takerExOwner, takerExFee, makerOrder.QuoteToken, statedb, mapBalances)
 	if err != nil {
 		return err
 	}
@@ -620,7 +885,7 @@ func (tomox *TomoX) ProcessCancelOrder(header *types.Header, tradingStateDB *tra
 	// order: basic order information (includes orderId, orderHash, baseToken, quoteToken) which user send to tomox to cancel order
 	// originOrder: full order information getting from order trie
 	originOrder := tradingStateDB.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)))
-	if originOrder == tradingstate.EmptyOrder {
+	if tradingstate.IsEmptyOrder(originOrder) {
 		return fmt.Errorf("order not found. OrderId: %v. Base: %s. Quote: %s", order.OrderID, order.BaseToken.Hex(), order.QuoteToken.Hex()), false
 	}
 	var tokenBalance *big.Int
@@ -682,6 +947,31 @@ func (tomox *TomoX) ProcessCancelOrder(header *types.Header, tradingStateDB *tra
 	return nil, false
 }
 
+// ProcessBatchCancelOrder cancels every order listed by a batch cancellation,
+// each paying its own cancellation fee. The batch is atomic: if any of the orders
+// can't be cancelled, none is. The extra data of the batch lists the extra data
+// of the cancellations, in order.
+func (tomox *TomoX) ProcessBatchCancelOrder(header *types.Header, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB, chain consensus.ChainContext, coinbase common.Address, orderBook common.Hash, order *tradingstate.OrderItem) (error, bool) {
+	tomoxSnap := tradingStateDB.Snapshot()
+	dbSnap := statedb.Snapshot()
+
+	extraData := make([]string, 0, len(order.Cancels))
+	for _, cancel := range order.Cancels {
+		cancelOrder := *order
+		cancelOrder.OrderID, cancelOrder.Hash, cancelOrder.Cancels = cancel.OrderID, cancel.Hash, nil
+		if err, reject := tomox.ProcessCancelOrder(header, tradingStateDB, statedb, chain, coinbase, orderBook, &cancelOrder); err != nil || reject {
+			tradingStateDB.RevertToSnapshot(tomoxSnap)
+			statedb.RevertToSnapshot(dbSnap)
+			return err, reject
+		}
+		extraData = append(extraData, cancelOrder.ExtraData)
+	}
+	data, _ := json.Marshal(extraData)
+	order.ExtraData = string(data)
+
+	return nil, false
+}
+
 // cancellation fee = 1/10 trading fee
 // deprecated after hardfork at TIPTomoXCancellationFee
 func getCancelFeeV1(baseTokenDecimal *big.Int, feeRate *big.Int, order *tradingstate.OrderItem) *big.Int {
@@ -755,6 +1045,175 @@ func (tomox *TomoX) UpdateMediumPriceBeforeEpoch(epochNumber uint64, tradingStat
 	return nil
 }
 
+// CancelDelistedOrders cancels every order resting on the books of pairs which
+// are no longer listed by any relayer, leaving no zombie books in the trading
+// state. New orders on such pairs are already rejected by VerifyPair.
+// Orders are cancelled free of charge; TomoX doesn't lock balances, so the
+// users' token balances are left untouched.
+func (tomox *TomoX) CancelDelistedOrders(header *types.Header, tradingStateDB *tradingstate.TradingStateDB, statedb *state.StateDB) error {
+	mapPairs, err := tradingstate.GetAllTradingPairs(statedb)
+	if err != nil {
+		return err
+	}
+	cancelledOrders := []*tradingstate.OrderItem{}
+	for _, orderBook := range tradingStateDB.GetAllOrderBooks() {
+		if mapPairs[orderBook] {
+			continue
+		}
+		orderIds, err := tradingStateDB.GetRestingOrderIds(orderBook)
+		if err != nil {
+			return err
+		}
+		stopOrderIds, err := tradingStateDB.GetStopOrderIds(orderBook)
+		if err != nil {
+			return err
+		}
+		orderIds = append(orderIds, stopOrderIds...)
+		for _, orderId := range orderIds {
+			order := tradingStateDB.GetOrder(orderBook, orderId)
+			if err := tradingStateDB.CancelOrder(orderBook, &order); err != nil {
+				return err
+			}
+			order.Status = tradingstate.OrderStatusCancelled
+			cancelledOrders = append(cancelledOrders, &order)
+		}
+		if len(orderIds) > 0 {
+			log.Info("Cancelled orders of delisted pair", "orderBook", orderBook.Hex(), "count", len(orderIds))
+		}
+	}
+	if tomox.IsSDKNode() && len(cancelledOrders) > 0 {
+		if err := tomox.LogCancelledOrders(header, cancelledOrders, tradingstate.CancelReasonDelisted); err != nil {
+			log.Error("failed to update orders of delisted pairs", "err", err)
+		}
+	}
+	return nil
+}
+
+// PurgeExpiredOrders cancels every good-till-date order whose expiry time is
+// reached by the given checkpoint block, so expired orders stop taking room in
+// the order books.
+func (tomox *TomoX) PurgeExpiredOrders(header *types.Header, tradingStateDB *tradingstate.TradingStateDB) error {
+	expiredOrders := []*tradingstate.OrderItem{}
+	for _, orderBook := range tradingStateDB.GetAllOrderBooks() {
+		for {
+			order, expired := tradingStateDB.GetExpiredOrder(orderBook, header.Time.Uint64())
+			if !expired {
+				break
+			}
+			if err := tradingStateDB.CancelOrder(orderBook, &order); err != nil {
+				return err
+			}
+			order.Status = tradingstate.OrderStatusCancelled
+			expiredOrders = append(expiredOrders, &order)
+		}
+	}
+	if len(expiredOrders) > 0 {
+		log.Info("Cancelled expired orders", "number", header.Number, "count", len(expiredOrders))
+	}
+	if tomox.IsSDKNode() && len(expiredOrders) > 0 {
+		if err := tomox.LogCancelledOrders(header, expiredOrders, tradingstate.CancelReasonExpired); err != nil {
+			log.Error("failed to update expired orders", "err", err)
+		}
+	}
+	return nil
+}
+
+// ProcessTriggeredOrders activates the stop orders whose trigger price has been
+// reached by the last price of their pair, once the orders of the block have been
+// matched. Sell stops are activated when the price falls to their trigger price
+// or below, buy stops when it rises to their trigger price or above. Activating
+// an order may move the price and trigger further stop orders; up to
+// MaxTriggeredOrders are activated per block, the others in the next blocks.
+func (tomox *TomoX) ProcessTriggeredOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB) error {
+	mapPairs, err := tradingstate.GetAllTradingPairs(statedb)
+	if err != nil {
+		return err
+	}
+	orderBooks := make([]common.Hash, 0, len(mapPairs))
+	for orderBook := range mapPairs {
+		orderBooks = append(orderBooks, orderBook)
+	}
+	sort.Slice(orderBooks, func(i, j int) bool {
+		return orderBooks[i].Big().Cmp(orderBooks[j].Big()) < 0
+	})
+	activated := 0
+	for _, orderBook := range orderBooks {
+		for activated < common.MaxTriggeredOrders {
+			price := tradingStateDB.GetLastPrice(orderBook)
+			order, triggered := tradingStateDB.GetTriggeredStopOrder(orderBook, tradingstate.Ask, price)
+			if !triggered {
+				order, triggered = tradingStateDB.GetTriggeredStopOrder(orderBook, tradingstate.Bid, price)
+			}
+			if !triggered {
+				break
+			}
+			if err := tomox.activateStopOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, &order); err != nil {
+				return err
+			}
+			activated++
+		}
+	}
+	if activated > 0 {
+		log.Debug("Activated stop orders", "number", header.Number, "count", activated)
+	}
+	return nil
+}
+
+// activateStopOrder removes a triggered stop order from the stop orders of its
+// book and matches it as a market or limit order, the rest of a limit order
+// resting in the book with a new order id. A stop order failing to match is
+// dropped.
+func (tomox *TomoX) activateStopOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) error {
+	if err := tradingStateDB.CancelOrder(orderBook, order); err != nil {
+		return err
+	}
+	tomoxSnap := tradingStateDB.Snapshot()
+	dbSnap := statedb.Snapshot()
+
+	taker := *order
+	taker.TriggerPrice = nil
+	var err error
+	if order.Type == tradingstate.StopMarket {
+		taker.Type = tradingstate.Market
+		_, _, err = tomox.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, &taker)
+	} else {
+		taker.Type = tradingstate.Limit
+		_, _, err = tomox.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, &taker)
+	}
+	if err != nil {
+		log.Debug("Reject triggered stop order", "orderId", order.OrderID, "err", err)
+		tradingStateDB.RevertToSnapshot(tomoxSnap)
+		statedb.RevertToSnapshot(dbSnap)
+	}
+	return nil
+}
+
+// put orders cancelled by the protocol, as their pair was delisted or they
+// expired, to mongodb, so that SDK nodes stop showing them as open
+func (tomox *TomoX) LogCancelledOrders(header *types.Header, orders []*tradingstate.OrderItem, reason string) error {
+	db := tomox.GetMongoDB()
+	db.InitBulk()
+
+	updatedAt := time.Unix(header.Time.Int64(), 0).UTC()
+	for _, order := range orders {
+		updatedOrder := order
+		val, err := db.GetObject(order.Hash, &tradingstate.OrderItem{})
+		if err == nil && val != nil {
+			updatedOrder = val.(*tradingstate.OrderItem)
+		}
+		updatedOrder.Status = tradingstate.OrderStatusCancelled
+		updatedOrder.ExtraData = reason
+		updatedOrder.UpdatedAt = updatedAt
+		if err := db.PutObject(updatedOrder.Hash, updatedOrder); err != nil {
+			return err
+		}
+	}
+	if err := db.CommitBulk(); err != nil {
+		return err
+	}
+	return nil
+}
+
 // put average price of epoch to mongodb for tracking liquidation trades
 // epochPriceResult: a map of epoch average price, key is orderbook hash , value is epoch average price
 // orderbook hash genereted from baseToken, quoteToken at tomochain/tomox/tradingstate/common.go:214
@@ -1,12 +1,20 @@
 package tomox
 
 import (
+	"encoding/json"
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"math/big"
 	"reflect"
 	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
 )
 
 func Test_getCancelFeeV1(t *testing.T) {
@@ -572,3 +580,897 @@ func TestGetTradeQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestCancelDelistedOrders(t *testing.T) {
+	tomox := &TomoX{}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	// list tokenA/tokenB for a single relayer, tokenA/tokenC isn't listed
+	tokenA := common.HexToAddress("0x1000000000000000000000000000000000000002")
+	tokenB := common.HexToAddress("0x1100000000000000000000000000000000000003")
+	tokenC := common.HexToAddress("0x1200000000000000000000000000000000000004")
+	relayer, contract := common.HexToAddress("0x0000000000000000000000000000000000000010"), common.HexToAddress(common.RelayerRegistrationSMC)
+	statedb.SetState(contract, common.BigToHash(new(big.Int).SetUint64(tradingstate.RelayerMappingSlot["RelayerCount"])), common.BigToHash(common.Big1))
+	statedb.SetState(contract, common.BigToHash(state.GetLocMappingAtKey(common.Hash{}, tradingstate.RelayerMappingSlot["RELAYER_COINBASES"])), relayer.Hash())
+	locBig := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	for slot, token := range map[string]common.Address{"_fromTokens": tokenA, "_toTokens": tokenB} {
+		slotHash := common.BigToHash(new(big.Int).Add(locBig, tradingstate.RelayerStructMappingSlot[slot]))
+		statedb.SetState(contract, slotHash, common.BigToHash(common.Big1))
+		statedb.SetState(contract, state.GetLocDynamicArrAtElement(slotHash, 0, 1), token.Hash())
+	}
+	listed, delisted := tradingstate.GetTradingOrderBookHash(tokenA, tokenB), tradingstate.GetTradingOrderBookHash(tokenA, tokenC)
+
+	for i, orderBook := range []common.Hash{listed, delisted, listed, delisted} {
+		side := tradingstate.Ask
+		if i%2 == 1 {
+			side = tradingstate.Bid
+		}
+		order := tradingstate.OrderItem{
+			OrderID:  uint64(i + 1),
+			Hash:     common.BigToHash(big.NewInt(int64(i + 1))),
+			Quantity: big.NewInt(100),
+			Price:    big.NewInt(int64(10 + i)),
+			Side:     side,
+			Status:   tradingstate.OrderStatusOpen,
+		}
+		tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), order)
+	}
+	header := &types.Header{Number: big.NewInt(900), Time: big.NewInt(0)}
+	if err := tomox.CancelDelistedOrders(header, tradingStateDb, statedb); err != nil {
+		t.Fatalf("failed to cancel delisted orders: %v", err)
+	}
+	if ids, _ := tradingStateDb.GetRestingOrderIds(delisted); len(ids) != 0 {
+		t.Errorf("delisted book still has %d resting orders", len(ids))
+	}
+	if ids, _ := tradingStateDb.GetRestingOrderIds(listed); len(ids) != 2 {
+		t.Errorf("listed book resting orders mismatch: have %d, want 2", len(ids))
+	}
+	if price, volume := tradingStateDb.GetBestBidPrice(delisted); price.Sign() != 0 || volume.Sign() != 0 {
+		t.Errorf("delisted book still has a best bid: price %v, volume %v", price, volume)
+	}
+}
+
+func TestPurgeExpiredOrders(t *testing.T) {
+	tomox := &TomoX{}
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	ethBook, btcBook := common.StringToHash("ETH/TOMO"), common.StringToHash("BTC/TOMO")
+	for i, expiresAt := range []uint64{100, 0, 200, 100} {
+		orderBook, side := ethBook, tradingstate.Ask
+		if i%2 == 1 {
+			orderBook, side = btcBook, tradingstate.Bid
+		}
+		order := tradingstate.OrderItem{
+			OrderID:   uint64(i + 1),
+			Hash:      common.BigToHash(big.NewInt(int64(i + 1))),
+			Quantity:  big.NewInt(100),
+			Price:     big.NewInt(int64(10 + i)),
+			Side:      side,
+			Status:    tradingstate.OrderStatusOpen,
+			ExpiresAt: expiresAt,
+		}
+		tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), order)
+	}
+	header := &types.Header{Number: big.NewInt(900), Time: big.NewInt(150)}
+	if err := tomox.PurgeExpiredOrders(header, tradingStateDb); err != nil {
+		t.Fatalf("failed to purge expired orders: %v", err)
+	}
+	for orderBook, want := range map[common.Hash]int{ethBook: 1, btcBook: 1} {
+		if ids, _ := tradingStateDb.GetRestingOrderIds(orderBook); len(ids) != want {
+			t.Errorf("resting orders mismatch of %x: have %d, want %d", orderBook, len(ids), want)
+		}
+	}
+	if order, expired := tradingStateDb.GetExpiredOrder(ethBook, 200); !expired || order.OrderID != 3 {
+		t.Errorf("order expiring later mismatch: have %d, want 3", order.OrderID)
+	}
+}
+
+// batchCancelChain is a chain context only serving its configuration.
+type batchCancelChain struct {
+	config *params.ChainConfig
+}
+
+func (c *batchCancelChain) Engine() consensus.Engine                    { return nil }
+func (c *batchCancelChain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+func (c *batchCancelChain) CurrentHeader() *types.Header                { return nil }
+func (c *batchCancelChain) Config() *params.ChainConfig                 { return c.config }
+
+func TestApplyBatchCancelOrder(t *testing.T) {
+	cache, _ := lru.New(defaultCacheLimit)
+	tomox := &TomoX{tokenDecimalCache: cache}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	baseToken, quoteToken := common.HexToAddress(common.TomoNativeAddress), common.HexToAddress("0x1100000000000000000000000000000000000003")
+	relayer, contract := common.HexToAddress("0x0000000000000000000000000000000000000010"), common.HexToAddress(common.RelayerRegistrationSMC)
+	locBig := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	deposit := new(big.Int).Mul(common.BasePrice, new(big.Int).Add(common.RelayerLockedFund, common.Big1))
+	statedb.SetState(contract, common.BigToHash(new(big.Int).Add(locBig, tradingstate.RelayerStructMappingSlot["_deposit"])), common.BigToHash(deposit))
+	statedb.AddBalance(contract, deposit)
+	statedb.AddBalance(user, common.BasePrice)
+
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+	for i := uint64(1); i <= 3; i++ {
+		order := tradingstate.OrderItem{
+			OrderID:         i,
+			Hash:            common.BigToHash(new(big.Int).SetUint64(i)),
+			Quantity:        big.NewInt(100),
+			Price:           big.NewInt(int64(10 + i)),
+			Side:            tradingstate.Ask,
+			Status:          tradingstate.OrderStatusOpen,
+			UserAddress:     user,
+			ExchangeAddress: relayer,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+		}
+		tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(i)), order)
+	}
+	batchCancel := func(nonce uint64, ids ...uint64) *tradingstate.OrderItem {
+		var cancels []types.OrderCancel
+		for _, id := range ids {
+			cancels = append(cancels, types.OrderCancel{OrderID: id, Hash: common.BigToHash(new(big.Int).SetUint64(id))})
+		}
+		tx, err := types.OrderSignTx(types.NewBatchCancelOrderTransaction(nonce, relayer, user, baseToken, quoteToken, cancels), types.OrderTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign batch cancellation: %v", err)
+		}
+		V, R, S := tx.Signature()
+		return &tradingstate.OrderItem{
+			Nonce:           new(big.Int).SetUint64(nonce),
+			ExchangeAddress: relayer,
+			UserAddress:     user,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderStatusCancelled,
+			Cancels:         tx.Cancels(),
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	config := *params.TestChainConfig
+	config.TIPTomoXBatchCancelBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+	resting := func() int {
+		ids, _ := tradingStateDb.GetRestingOrderIds(orderBook)
+		return len(ids)
+	}
+	// Before the fork, batch cancellations are rejected
+	if _, rejects, err := tomox.ApplyOrder(&types.Header{Number: big.NewInt(900)}, common.Address{}, chain, statedb, tradingStateDb, orderBook, batchCancel(0, 1, 3)); err != nil || len(rejects) != 1 {
+		t.Fatalf("batch cancellation before the fork: rejects %d, err %v", len(rejects), err)
+	}
+	if n := resting(); n != 3 {
+		t.Fatalf("resting orders mismatch: have %d, want 3", n)
+	}
+	header := &types.Header{Number: big.NewInt(1000)}
+	order := batchCancel(1, 1, 3)
+	if _, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order); err != nil || len(rejects) != 0 {
+		t.Fatalf("failed to apply batch cancellation: rejects %d, err %v", len(rejects), err)
+	}
+	if n := resting(); n != 1 {
+		t.Fatalf("resting orders mismatch: have %d, want 1", n)
+	}
+	var extraData []string
+	if err := json.Unmarshal([]byte(order.ExtraData), &extraData); err != nil || len(extraData) != 2 {
+		t.Errorf("extra data mismatch: have %q, err %v", order.ExtraData, err)
+	}
+	// A batch with a missing order cancels nothing
+	if _, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, batchCancel(2, 2, 4)); err != nil || len(rejects) != 1 {
+		t.Fatalf("batch cancellation with a missing order: rejects %d, err %v", len(rejects), err)
+	}
+	if n := resting(); n != 1 {
+		t.Fatalf("resting orders mismatch: have %d, want 1", n)
+	}
+	if nonce := tradingStateDb.GetNonce(user.Hash()); nonce != 3 {
+		t.Errorf("nonce mismatch: have %d, want 3", nonce)
+	}
+}
+
+// registerRelayerPair registers a relayer, owning itself and with enough deposit
+// for its orders to be matched, listing a single pair.
+func registerRelayerPair(statedb *state.StateDB, relayer, baseToken, quoteToken common.Address) {
+	contract := common.HexToAddress(common.RelayerRegistrationSMC)
+	locBig := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	deposit := new(big.Int).Mul(common.BasePrice, new(big.Int).Add(common.RelayerLockedFund, common.Big1))
+	statedb.SetState(contract, common.BigToHash(new(big.Int).Add(locBig, tradingstate.RelayerStructMappingSlot["_deposit"])), common.BigToHash(deposit))
+	statedb.AddBalance(contract, deposit)
+	statedb.SetState(contract, common.BigToHash(new(big.Int).Add(locBig, tradingstate.RelayerStructMappingSlot["_owner"])), relayer.Hash())
+	statedb.SetState(contract, common.BigToHash(new(big.Int).SetUint64(tradingstate.RelayerMappingSlot["RelayerCount"])), common.BigToHash(common.Big1))
+	statedb.SetState(contract, common.BigToHash(state.GetLocMappingAtKey(common.Hash{}, tradingstate.RelayerMappingSlot["RELAYER_COINBASES"])), relayer.Hash())
+	for slot, token := range map[string]common.Address{"_fromTokens": baseToken, "_toTokens": quoteToken} {
+		slotHash := common.BigToHash(new(big.Int).Add(locBig, tradingstate.RelayerStructMappingSlot[slot]))
+		statedb.SetState(contract, slotHash, common.BigToHash(common.Big1))
+		statedb.SetState(contract, state.GetLocDynamicArrAtElement(slotHash, 0, 1), token.Hash())
+	}
+}
+
+func TestApplyStopOrder(t *testing.T) {
+	cache, _ := lru.New(defaultCacheLimit)
+	tomox := &TomoX{tokenDecimalCache: cache}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	baseToken, quoteToken := common.HexToAddress(common.TomoNativeAddress), common.HexToAddress("0x1100000000000000000000000000000000000003")
+	relayer := common.HexToAddress("0x0000000000000000000000000000000000000010")
+	registerRelayerPair(statedb, relayer, baseToken, quoteToken)
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+
+	stopOrder := func(nonce uint64) *tradingstate.OrderItem {
+		quantity, price, triggerPrice := big.NewInt(100), big.NewInt(9), big.NewInt(10)
+		hash := common.BigToHash(new(big.Int).SetUint64(nonce + 1))
+		tx, err := types.OrderSignTx(types.NewStopOrderTransaction(nonce, quantity, price, triggerPrice, relayer, user, baseToken, quoteToken, tradingstate.Ask, tradingstate.StopLimit, hash), types.OrderTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign stop order: %v", err)
+		}
+		V, R, S := tx.Signature()
+		return &tradingstate.OrderItem{
+			Nonce:           new(big.Int).SetUint64(nonce),
+			Quantity:        quantity,
+			Price:           price,
+			TriggerPrice:    triggerPrice,
+			ExchangeAddress: relayer,
+			UserAddress:     user,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderNew,
+			Side:            tradingstate.Ask,
+			Type:            tradingstate.StopLimit,
+			Hash:            hash,
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	config := *params.TestChainConfig
+	config.TIPTomoXStopOrderBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+	count := func() (int, int) {
+		stops, _ := tradingStateDb.GetStopOrderIds(orderBook)
+		resting, _ := tradingStateDb.GetRestingOrderIds(orderBook)
+		return len(stops), len(resting)
+	}
+	// Before the fork, stop orders are rejected
+	if _, rejects, err := tomox.ApplyOrder(&types.Header{Number: big.NewInt(900)}, common.Address{}, chain, statedb, tradingStateDb, orderBook, stopOrder(0)); err != nil || len(rejects) != 1 {
+		t.Fatalf("stop order before the fork: rejects %d, err %v", len(rejects), err)
+	}
+	header := &types.Header{Number: big.NewInt(1000)}
+	if _, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, stopOrder(1)); err != nil || len(rejects) != 0 {
+		t.Fatalf("failed to apply stop order: rejects %d, err %v", len(rejects), err)
+	}
+	if stops, resting := count(); stops != 1 || resting != 0 {
+		t.Fatalf("order count mismatch: have %d stop and %d resting, want 1 and 0", stops, resting)
+	}
+	// The sell stop isn't triggered without a trade, nor above its trigger price
+	for _, price := range []*big.Int{common.Big0, big.NewInt(11)} {
+		tradingStateDb.SetLastPrice(orderBook, price)
+		if err := tomox.ProcessTriggeredOrders(header, common.Address{}, chain, statedb, tradingStateDb); err != nil {
+			t.Fatalf("failed to process triggered orders: %v", err)
+		}
+		if stops, resting := count(); stops != 1 || resting != 0 {
+			t.Fatalf("order count mismatch at price %v: have %d stop and %d resting, want 1 and 0", price, stops, resting)
+		}
+	}
+	// At its trigger price, it rests in the book as a limit order
+	tradingStateDb.SetLastPrice(orderBook, big.NewInt(10))
+	if err := tomox.ProcessTriggeredOrders(header, common.Address{}, chain, statedb, tradingStateDb); err != nil {
+		t.Fatalf("failed to process triggered orders: %v", err)
+	}
+	if stops, resting := count(); stops != 0 || resting != 1 {
+		t.Fatalf("order count mismatch: have %d stop and %d resting, want 0 and 1", stops, resting)
+	}
+	ids, _ := tradingStateDb.GetRestingOrderIds(orderBook)
+	order := tradingStateDb.GetOrder(orderBook, ids[0])
+	if order.Type != tradingstate.Limit || order.Price.Cmp(big.NewInt(9)) != 0 || order.TriggerPrice != nil || order.OrderID != 2 {
+		t.Errorf("activated order mismatch: have type %s, price %v, trigger %v, id %d", order.Type, order.Price, order.TriggerPrice, order.OrderID)
+	}
+}
+
+func TestApplyPostOnlyOrder(t *testing.T) {
+	cache, _ := lru.New(defaultCacheLimit)
+	tomox := &TomoX{tokenDecimalCache: cache}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	baseToken, quoteToken := common.HexToAddress(common.TomoNativeAddress), common.HexToAddress("0x1100000000000000000000000000000000000003")
+	relayer := common.HexToAddress("0x0000000000000000000000000000000000000010")
+	registerRelayerPair(statedb, relayer, baseToken, quoteToken)
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+
+	// a sell order resting at 10
+	tradingStateDb.SetNonce(orderBook, 1)
+	tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(common.Big1), tradingstate.OrderItem{
+		OrderID:         1,
+		Hash:            common.BigToHash(common.Big1),
+		Quantity:        big.NewInt(100),
+		Price:           big.NewInt(10),
+		Side:            tradingstate.Ask,
+		Type:            tradingstate.Limit,
+		Status:          tradingstate.OrderStatusOpen,
+		ExchangeAddress: relayer,
+		BaseToken:       baseToken,
+		QuoteToken:      quoteToken,
+	})
+	postOnly := func(nonce uint64, price int64) *tradingstate.OrderItem {
+		hash := common.BigToHash(new(big.Int).SetUint64(nonce + 2))
+		tx := types.NewOrderTransaction(nonce, big.NewInt(100), big.NewInt(price), relayer, user, baseToken, quoteToken, tradingstate.OrderNew, tradingstate.Bid, tradingstate.Limit, hash, 0)
+		tx.SetTimeInForce(types.OrderTimeInForcePostOnly)
+		tx, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign post-only order: %v", err)
+		}
+		V, R, S := tx.Signature()
+		return &tradingstate.OrderItem{
+			Nonce:           new(big.Int).SetUint64(nonce),
+			Quantity:        big.NewInt(100),
+			Price:           big.NewInt(price),
+			ExchangeAddress: relayer,
+			UserAddress:     user,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderNew,
+			Side:            tradingstate.Bid,
+			Type:            tradingstate.Limit,
+			Hash:            hash,
+			TimeInForce:     tradingstate.PostOnly,
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	config := *params.TestChainConfig
+	config.TIPTomoXPostOnlyBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+	resting := func() int {
+		ids, _ := tradingStateDb.GetRestingOrderIds(orderBook)
+		return len(ids)
+	}
+	// Before the fork, post-only orders are rejected
+	if _, rejects, err := tomox.ApplyOrder(&types.Header{Number: big.NewInt(900)}, common.Address{}, chain, statedb, tradingStateDb, orderBook, postOnly(0, 9)); err != nil || len(rejects) != 1 {
+		t.Fatalf("post-only order before the fork: rejects %d, err %v", len(rejects), err)
+	}
+	// A buy order at the best ask would cross the spread
+	header := &types.Header{Number: big.NewInt(1000)}
+	order := postOnly(1, 10)
+	trades, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order)
+	if err != nil || len(trades) != 0 || len(rejects) != 1 {
+		t.Fatalf("crossing post-only order: trades %d, rejects %d, err %v", len(trades), len(rejects), err)
+	}
+	if rejects[0].ExtraData != tradingstate.RejectReasonPostOnly {
+		t.Errorf("reject reason mismatch: have %q, want %q", rejects[0].ExtraData, tradingstate.RejectReasonPostOnly)
+	}
+	if n := resting(); n != 1 {
+		t.Fatalf("resting orders mismatch: have %d, want 1", n)
+	}
+	// Below it, the order rests in the book
+	if _, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, postOnly(2, 9)); err != nil || len(rejects) != 0 {
+		t.Fatalf("failed to apply post-only order: rejects %d, err %v", len(rejects), err)
+	}
+	if n := resting(); n != 2 {
+		t.Fatalf("resting orders mismatch: have %d, want 2", n)
+	}
+}
+
+func TestApplyImmediateOrder(t *testing.T) {
+	cache, _ := lru.New(defaultCacheLimit)
+	tomox := &TomoX{tokenDecimalCache: cache}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	takerKey, _ := crypto.GenerateKey()
+	taker, maker := crypto.PubkeyToAddress(takerKey.PublicKey), common.HexToAddress("0x0000000000000000000000000000000000000020")
+	baseToken, quoteToken := common.HexToAddress(common.TomoNativeAddress), common.HexToAddress("0x1100000000000000000000000000000000000003")
+	relayer := common.HexToAddress("0x0000000000000000000000000000000000000010")
+	registerRelayerPair(statedb, relayer, baseToken, quoteToken)
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+
+	// the maker sells 20 TOMO at 10 tokens each, the taker holds 1000 tokens
+	unit, price := new(big.Int).Mul(big.NewInt(10), common.BasePrice), new(big.Int).Mul(big.NewInt(10), common.BasePrice)
+	cache.Add(quoteToken, common.BasePrice)
+	statedb.SetNonce(quoteToken, 1)
+	statedb.AddBalance(maker, new(big.Int).Mul(big.NewInt(100), common.BasePrice))
+	tradingstate.SetTokenBalance(taker, new(big.Int).Mul(big.NewInt(1000), common.BasePrice), quoteToken, statedb)
+	tradingStateDb.SetNonce(orderBook, 1)
+	tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(common.Big1), tradingstate.OrderItem{
+		OrderID:         1,
+		Hash:            common.BigToHash(common.Big1),
+		Quantity:        new(big.Int).Mul(big.NewInt(2), unit),
+		Price:           price,
+		Side:            tradingstate.Ask,
+		Type:            tradingstate.Limit,
+		Status:          tradingstate.OrderStatusOpen,
+		UserAddress:     maker,
+		ExchangeAddress: relayer,
+		BaseToken:       baseToken,
+		QuoteToken:      quoteToken,
+	})
+	immediate := func(nonce uint64, quantity int64, tif string) *tradingstate.OrderItem {
+		hash := common.BigToHash(new(big.Int).SetUint64(nonce + 2))
+		amount := new(big.Int).Mul(big.NewInt(quantity), unit)
+		tx := types.NewOrderTransaction(nonce, amount, price, relayer, taker, baseToken, quoteToken, tradingstate.OrderNew, tradingstate.Bid, tradingstate.Limit, hash, 0)
+		tx.SetTimeInForce(tif)
+		tx, err := types.OrderSignTx(tx, types.OrderTxSigner{}, takerKey)
+		if err != nil {
+			t.Fatalf("failed to sign order: %v", err)
+		}
+		V, R, S := tx.Signature()
+		return &tradingstate.OrderItem{
+			Nonce:           new(big.Int).SetUint64(nonce),
+			Quantity:        amount,
+			Price:           price,
+			ExchangeAddress: relayer,
+			UserAddress:     taker,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderNew,
+			Side:            tradingstate.Bid,
+			Type:            tradingstate.Limit,
+			Hash:            hash,
+			TimeInForce:     tif,
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	config := *params.TestChainConfig
+	config.TIPTomoXTimeInForceBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+	check := func(asks int64, tokens int64) {
+		t.Helper()
+		if ids, _ := tradingStateDb.GetRestingOrderIds(orderBook); len(ids) != 1 {
+			t.Errorf("resting orders mismatch: have %d, want 1", len(ids))
+		}
+		if have := tradingStateDb.GetVolume(orderBook, price, tradingstate.Ask); have.Cmp(new(big.Int).Mul(big.NewInt(asks), common.BasePrice)) != 0 {
+			t.Errorf("ask volume mismatch: have %v, want %d TOMO", have, asks)
+		}
+		if have := tradingstate.GetTokenBalance(taker, quoteToken, statedb); have.Cmp(new(big.Int).Mul(big.NewInt(tokens), common.BasePrice)) != 0 {
+			t.Errorf("taker balance mismatch: have %v, want %d tokens", have, tokens)
+		}
+	}
+	// Before the fork, immediate orders are rejected
+	if _, rejects, err := tomox.ApplyOrder(&types.Header{Number: big.NewInt(900)}, common.Address{}, chain, statedb, tradingStateDb, orderBook, immediate(0, 1, tradingstate.ImmediateOrCancel)); err != nil || len(rejects) != 1 {
+		t.Fatalf("immediate order before the fork: rejects %d, err %v", len(rejects), err)
+	}
+	header := &types.Header{Number: big.NewInt(1000)}
+
+	// A fill-or-kill order which can't be filled trades nothing
+	order := immediate(1, 3, tradingstate.FillOrKill)
+	trades, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order)
+	if err != nil || len(trades) != 0 || len(rejects) != 1 || rejects[0].ExtraData != tradingstate.RejectReasonFillOrKill {
+		t.Fatalf("unfilled fill-or-kill order: trades %d, rejects %d, err %v", len(trades), len(rejects), err)
+	}
+	check(20, 1000)
+
+	// A fill-or-kill order which can be filled trades entirely
+	if trades, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, immediate(2, 1, tradingstate.FillOrKill)); err != nil || len(trades) != 1 || len(rejects) != 0 {
+		t.Fatalf("filled fill-or-kill order: trades %d, rejects %d, err %v", len(trades), len(rejects), err)
+	}
+	check(10, 900)
+
+	// The unmatched part of an immediate-or-cancel order is cancelled
+	order = immediate(3, 3, tradingstate.ImmediateOrCancel)
+	trades, rejects, err = tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order)
+	if err != nil || len(trades) != 1 || len(rejects) != 1 || rejects[0].ExtraData != tradingstate.RejectReasonImmediateOrCancel {
+		t.Fatalf("immediate-or-cancel order: trades %d, rejects %d, err %v", len(trades), len(rejects), err)
+	}
+	if ids, _ := tradingStateDb.GetRestingOrderIds(orderBook); len(ids) != 0 {
+		t.Errorf("resting orders mismatch: have %d, want 0", len(ids))
+	}
+	if have := tradingstate.GetTokenBalance(taker, quoteToken, statedb); have.Cmp(new(big.Int).Mul(big.NewInt(800), common.BasePrice)) != 0 {
+		t.Errorf("taker balance mismatch: have %v, want 800 tokens", have)
+	}
+}
+
+func TestApplySelfTradePrevention(t *testing.T) {
+	cache, _ := lru.New(defaultCacheLimit)
+	tomox := &TomoX{tokenDecimalCache: cache}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	baseToken, quoteToken := common.HexToAddress(common.TomoNativeAddress), common.HexToAddress("0x1100000000000000000000000000000000000003")
+	relayer := common.HexToAddress("0x0000000000000000000000000000000000000010")
+	registerRelayerPair(statedb, relayer, baseToken, quoteToken)
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+
+	// a sell order of the user resting at 10
+	tradingStateDb.SetNonce(orderBook, 1)
+	tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(common.Big1), tradingstate.OrderItem{
+		OrderID:         1,
+		Hash:            common.BigToHash(common.Big1),
+		Quantity:        big.NewInt(100),
+		Price:           big.NewInt(10),
+		Side:            tradingstate.Ask,
+		Type:            tradingstate.Limit,
+		Status:          tradingstate.OrderStatusOpen,
+		UserAddress:     user,
+		ExchangeAddress: relayer,
+		BaseToken:       baseToken,
+		QuoteToken:      quoteToken,
+	})
+	order := func(nonce uint64, side string, mode string) *tradingstate.OrderItem {
+		hash := common.BigToHash(new(big.Int).SetUint64(nonce + 2))
+		tx := types.NewOrderTransaction(nonce, big.NewInt(100), big.NewInt(10), relayer, user, baseToken, quoteToken, tradingstate.OrderNew, side, tradingstate.Limit, hash, 0)
+		tx.SetSelfTradePrevention(mode)
+		tx, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign order: %v", err)
+		}
+		V, R, S := tx.Signature()
+		return &tradingstate.OrderItem{
+			Nonce:               new(big.Int).SetUint64(nonce),
+			Quantity:            big.NewInt(100),
+			Price:               big.NewInt(10),
+			ExchangeAddress:     relayer,
+			UserAddress:         user,
+			BaseToken:           baseToken,
+			QuoteToken:          quoteToken,
+			Status:              tradingstate.OrderNew,
+			Side:                side,
+			Type:                tradingstate.Limit,
+			Hash:                hash,
+			SelfTradePrevention: mode,
+			Signature:           &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	config := *params.TestChainConfig
+	config.TIPTomoXSelfTradeBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+	resting := func() int {
+		ids, _ := tradingStateDb.GetRestingOrderIds(orderBook)
+		return len(ids)
+	}
+	// Before the fork, orders preventing self-trades are rejected
+	if _, rejects, err := tomox.ApplyOrder(&types.Header{Number: big.NewInt(900)}, common.Address{}, chain, statedb, tradingStateDb, orderBook, order(0, tradingstate.Bid, tradingstate.CancelNewest)); err != nil || len(rejects) != 1 {
+		t.Fatalf("order before the fork: rejects %d, err %v", len(rejects), err)
+	}
+	header := &types.Header{Number: big.NewInt(1000)}
+	for i, tt := range []struct {
+		side    string
+		mode    string
+		rejects []common.Hash
+		resting int
+	}{
+		// cancel newest rejects the taker, the sell order keeps resting
+		{tradingstate.Bid, tradingstate.CancelNewest, []common.Hash{common.BigToHash(big.NewInt(3))}, 1},
+		// cancel oldest cancels the sell order, the taker rests instead
+		{tradingstate.Bid, tradingstate.CancelOldest, []common.Hash{common.BigToHash(common.Big1)}, 1},
+		// cancel both cancels the resting buy order and the taker
+		{tradingstate.Ask, tradingstate.CancelBoth, []common.Hash{common.BigToHash(big.NewInt(4)), common.BigToHash(big.NewInt(5))}, 0},
+	} {
+		trades, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order(uint64(i+1), tt.side, tt.mode))
+		if err != nil || len(trades) != 0 || len(rejects) != len(tt.rejects) {
+			t.Fatalf("%s order: trades %d, rejects %d, err %v", tt.mode, len(trades), len(rejects), err)
+		}
+		for j, reject := range rejects {
+			if reject.Hash != tt.rejects[j] || reject.ExtraData != tradingstate.RejectReasonSelfTrade {
+				t.Errorf("%s order: reject %d mismatch: have %x %q, want %x", tt.mode, j, reject.Hash, reject.ExtraData, tt.rejects[j])
+			}
+		}
+		if n := resting(); n != tt.resting {
+			t.Errorf("%s order: resting orders mismatch: have %d, want %d", tt.mode, n, tt.resting)
+		}
+	}
+}
+
+func TestMakerRebate(t *testing.T) {
+	cache, _ := lru.New(defaultCacheLimit)
+	tomox := &TomoX{tokenDecimalCache: cache}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	taker, maker := common.HexToAddress("0x0000000000000000000000000000000000000021"), common.HexToAddress("0x0000000000000000000000000000000000000020")
+	baseToken, quoteToken := common.HexToAddress(common.TomoNativeAddress), common.HexToAddress("0x1100000000000000000000000000000000000003")
+	takerRelayer, makerRelayer := common.HexToAddress("0x0000000000000000000000000000000000000010"), common.HexToAddress("0x0000000000000000000000000000000000000011")
+	takerOwner, makerOwner := common.HexToAddress("0x0000000000000000000000000000000000000030"), common.HexToAddress("0x0000000000000000000000000000000000000031")
+	deposit := new(big.Int).Mul(common.BasePrice, new(big.Int).Add(common.RelayerLockedFund, common.Big1))
+	pair := []common.Address{baseToken}
+	tradingstate.RegisterRelayer(statedb, takerRelayer, takerOwner, deposit, big.NewInt(10), pair, []common.Address{quoteToken})
+	tradingstate.RegisterRelayer(statedb, makerRelayer, makerOwner, deposit, big.NewInt(10), pair, []common.Address{quoteToken})
+	// the maker relayer pays its makers a 0.05% rebate
+	tradingstate.SetRelayerMakerRebate(statedb, makerRelayer, big.NewInt(5))
+
+	// the maker sells 10 TOMO at 10 tokens each to the taker
+	cache.Add(quoteToken, common.BasePrice)
+	statedb.SetNonce(quoteToken, 1)
+	statedb.AddBalance(maker, new(big.Int).Mul(big.NewInt(10), common.BasePrice))
+	tradingstate.SetTokenBalance(taker, new(big.Int).Mul(big.NewInt(1000), common.BasePrice), quoteToken, statedb)
+	quantity, price := new(big.Int).Mul(big.NewInt(10), common.BasePrice), new(big.Int).Mul(big.NewInt(10), common.BasePrice)
+	takerOrder := &tradingstate.OrderItem{Quantity: quantity, Price: price, Side: tradingstate.Bid, UserAddress: taker, ExchangeAddress: takerRelayer, BaseToken: baseToken, QuoteToken: quoteToken}
+	makerOrder := &tradingstate.OrderItem{Quantity: quantity, Price: price, Side: tradingstate.Ask, UserAddress: maker, ExchangeAddress: makerRelayer, BaseToken: baseToken, QuoteToken: quoteToken}
+
+	config := *params.TestChainConfig
+	config.TIPTomoXMakerRebateBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+	tokens := func(milli int64) *big.Int {
+		return new(big.Int).Div(new(big.Int).Mul(big.NewInt(milli), common.BasePrice), big.NewInt(1000))
+	}
+	tests := []struct {
+		number                         int64
+		relayer                        common.Address // relayer of the taker order
+		taker, maker, takerEx, makerEx *big.Int
+		rebate                         bool
+	}{
+		// before the fork, the maker pays the maker fee of 0.1 token
+		{999, makerRelayer, tokens(899900), tokens(99900), tokens(0), tokens(200), false},
+		// from then on, the maker is paid 0.05 token out of the taker fee
+		{1000, makerRelayer, tokens(899900), tokens(100050), tokens(0), tokens(50), true},
+		// but not by the relayer of a taker of another relayer
+		{1000, takerRelayer, tokens(899900), tokens(99900), tokens(100), tokens(100), false},
+	}
+	for _, tt := range tests {
+		db := statedb.Copy()
+		takerOrder := *takerOrder
+		takerOrder.ExchangeAddress = tt.relayer
+		traded, _, settleBalance, err := tomox.getTradeQuantity(&types.Header{Number: big.NewInt(tt.number)}, nil, common.Address{}, chain, db, &takerOrder, makerOrder, quantity)
+		if err != nil || traded.Cmp(quantity) != 0 {
+			t.Fatalf("block %d: traded %v, err %v", tt.number, traded, err)
+		}
+		if rebate := settleBalance.Maker.Rebate != nil; rebate != tt.rebate {
+			t.Errorf("block %d: maker rebate mismatch: have %v, want %v", tt.number, rebate, tt.rebate)
+		}
+		for name, check := range map[string]struct {
+			addr common.Address
+			want *big.Int
+		}{"taker": {taker, tt.taker}, "maker": {maker, tt.maker}, "taker relayer": {takerOwner, tt.takerEx}, "maker relayer": {makerOwner, tt.makerEx}} {
+			if have := tradingstate.GetTokenBalance(check.addr, quoteToken, db); have.Cmp(check.want) != 0 {
+				t.Errorf("block %d: %s balance mismatch: have %v, want %v", tt.number, name, have, check.want)
+			}
+		}
+	}
+}
+
+func TestCrossPairMarketOrder(t *testing.T) {
+	var (
+		tomo        = common.HexToAddress(common.TomoNativeAddress)
+		baseToken   = common.HexToAddress("0x1100000000000000000000000000000000000003")
+		quoteToken  = common.HexToAddress("0x2200000000000000000000000000000000000004")
+		relayer     = common.HexToAddress("0x0000000000000000000000000000000000000010")
+		maker       = common.HexToAddress("0x0000000000000000000000000000000000000020")
+		baseBook    = tradingstate.GetTradingOrderBookHash(baseToken, tomo)
+		quoteBook   = tradingstate.GetTradingOrderBookHash(quoteToken, tomo)
+		crossBook   = tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+		units       = func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), common.BasePrice) }
+		dust        = big.NewInt(1000)
+		takerKey, _ = crypto.GenerateKey()
+		taker       = crypto.PubkeyToAddress(takerKey.PublicKey)
+	)
+	config := *params.TestChainConfig
+	config.TIPTomoXCrossPairBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+
+	// newStates returns the states of a relayer charging a 0.1% fee, listing
+	// the cross pair only, and of a maker resting a single order on the base
+	// and quote books, 2 TOMO the base token and 0.5 TOMO the quote token
+	newStates := func(takerSide string) (*TomoX, *state.StateDB, *tradingstate.TradingStateDB) {
+		cache, _ := lru.New(defaultCacheLimit)
+		tomox := &TomoX{tokenDecimalCache: cache}
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+		tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+		registerRelayerPair(statedb, relayer, baseToken, quoteToken)
+		locBig := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+		statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), common.BigToHash(new(big.Int).Add(locBig, tradingstate.RelayerStructMappingSlot["_fee"])), common.BigToHash(big.NewInt(10)))
+		for _, token := range []common.Address{baseToken, quoteToken} {
+			cache.Add(token, common.BasePrice)
+			statedb.SetNonce(token, 1)
+		}
+		statedb.AddBalance(maker, units(1000))
+		tradingstate.SetTokenBalance(maker, units(1000), baseToken, statedb)
+		tradingstate.SetTokenBalance(maker, units(1000), quoteToken, statedb)
+
+		makerSide := tradingstate.Bid
+		if takerSide == tradingstate.Bid {
+			makerSide = tradingstate.Ask
+		}
+		for i, book := range []struct {
+			hash  common.Hash
+			token common.Address
+			side  string
+			price *big.Int
+		}{
+			{baseBook, baseToken, makerSide, units(2)},
+			{quoteBook, quoteToken, takerSide, new(big.Int).Div(units(1), big.NewInt(2))},
+		} {
+			tradingStateDb.SetNonce(book.hash, 1)
+			tradingStateDb.InsertOrderItem(book.hash, common.BigToHash(common.Big1), tradingstate.OrderItem{
+				OrderID:         1,
+				Hash:            common.BigToHash(big.NewInt(int64(i + 1))),
+				Quantity:        units(100),
+				Price:           book.price,
+				Side:            book.side,
+				Type:            tradingstate.Limit,
+				Status:          tradingstate.OrderStatusOpen,
+				UserAddress:     maker,
+				ExchangeAddress: relayer,
+				BaseToken:       book.token,
+				QuoteToken:      tomo,
+			})
+		}
+		return tomox, statedb, tradingStateDb
+	}
+	market := func(side string, quantity *big.Int) *tradingstate.OrderItem {
+		hash := common.BigToHash(big.NewInt(100))
+		tx, err := types.OrderSignTx(types.NewOrderTransaction(0, quantity, common.Big0, relayer, taker, baseToken, quoteToken, tradingstate.OrderNew, side, tradingstate.Market, hash, 0), types.OrderTxSigner{}, takerKey)
+		if err != nil {
+			t.Fatalf("failed to sign order: %v", err)
+		}
+		V, R, S := tx.Signature()
+		return &tradingstate.OrderItem{
+			Nonce:           common.Big0,
+			Quantity:        quantity,
+			Price:           common.Big0,
+			ExchangeAddress: relayer,
+			UserAddress:     taker,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderNew,
+			Side:            side,
+			Type:            tradingstate.Market,
+			Hash:            hash,
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	within := func(have, want *big.Int) bool {
+		diff := new(big.Int).Sub(have, want)
+		return diff.CmpAbs(dust) <= 0
+	}
+
+	// Before the fork, nothing of a market order is routed
+	tomox, statedb, tradingStateDb := newStates(tradingstate.Ask)
+	tradingstate.SetTokenBalance(taker, units(10), baseToken, statedb)
+	if trades, _, err := tomox.ApplyOrder(&types.Header{Number: big.NewInt(900)}, common.Address{}, chain, statedb, tradingStateDb, crossBook, market(tradingstate.Ask, units(10))); err != nil || len(trades) != 0 {
+		t.Fatalf("market order before the fork: trades %d, err %v", len(trades), err)
+	}
+	header := &types.Header{Number: big.NewInt(1000)}
+
+	// A market ask sells 10 base tokens for 19.98 TOMO, buying 19.98 / 0.5005
+	// quote tokens with them
+	tomox, statedb, tradingStateDb = newStates(tradingstate.Ask)
+	tradingstate.SetTokenBalance(taker, units(10), baseToken, statedb)
+	trades, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, crossBook, market(tradingstate.Ask, units(10)))
+	if err != nil || len(trades) != 2 || len(rejects) != 0 {
+		t.Fatalf("routed market ask: trades %d, rejects %d, err %v", len(trades), len(rejects), err)
+	}
+	if trades[0][tradingstate.TradeBaseToken] != baseToken.String() || trades[1][tradingstate.TradeBaseToken] != quoteToken.String() || trades[1][tradingstate.TradeQuoteToken] != tomo.String() {
+		t.Errorf("hop pairs mismatch: have %v", trades)
+	}
+	if trades[0][tradingstate.TakerFee] != units(20).Div(units(20), big.NewInt(1000)).String() {
+		t.Errorf("first hop taker fee mismatch: have %s", trades[0][tradingstate.TakerFee])
+	}
+	want := new(big.Int).Div(new(big.Int).Mul(units(1998), big.NewInt(100)), big.NewInt(5005))
+	if have := tradingstate.GetTokenBalance(taker, quoteToken, statedb); !within(have, want) {
+		t.Errorf("taker quote balance mismatch: have %v, want %v", have, want)
+	}
+	if have := tradingstate.GetTokenBalance(taker, baseToken, statedb); have.Sign() != 0 {
+		t.Errorf("taker base balance mismatch: have %v, want 0", have)
+	}
+	if have := tradingstate.GetTokenBalance(taker, tomo, statedb); !within(have, common.Big0) {
+		t.Errorf("taker TOMO left mismatch: have %v", have)
+	}
+	// A route of which the last hop trades nothing is undone
+	tomox, statedb, tradingStateDb = newStates(tradingstate.Ask)
+	tradingstate.SetTokenBalance(taker, units(10), baseToken, statedb)
+	tradingstate.SetTokenBalance(maker, common.Big0, quoteToken, statedb)
+	if trades, _, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, crossBook, market(tradingstate.Ask, units(10))); err != nil || len(trades) != 0 {
+		t.Fatalf("market ask without liquidity on the last hop: trades %d, err %v", len(trades), err)
+	}
+	if have := tradingstate.GetTokenBalance(taker, baseToken, statedb); have.Cmp(units(10)) != 0 {
+		t.Errorf("taker base balance mismatch: have %v, want 10 tokens", have)
+	}
+	if ids, _ := tradingStateDb.GetRestingOrderIds(quoteBook); len(ids) != 1 {
+		t.Errorf("resting orders of the last hop mismatch: have %d, want 1", len(ids))
+	}
+
+	// A market bid sells the quote tokens 5 base tokens cost in TOMO, buying as
+	// many base tokens as they pay for
+	tomox, statedb, tradingStateDb = newStates(tradingstate.Bid)
+	tradingstate.SetTokenBalance(taker, units(100), quoteToken, statedb)
+	trades, rejects, err = tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, crossBook, market(tradingstate.Bid, units(5)))
+	if err != nil || len(trades) != 2 || len(rejects) != 0 {
+		t.Fatalf("routed market bid: trades %d, rejects %d, err %v", len(trades), len(rejects), err)
+	}
+	if trades[0][tradingstate.TradeBaseToken] != quoteToken.String() || trades[1][tradingstate.TradeBaseToken] != baseToken.String() {
+		t.Errorf("hop pairs mismatch: have %v", trades)
+	}
+	if have := tradingstate.GetTokenBalance(taker, baseToken, statedb); !within(have, units(5)) || have.Cmp(units(5)) > 0 {
+		t.Errorf("taker base balance mismatch: have %v, want 5 tokens", have)
+	}
+	// 10.01 TOMO received for a 0.1% fee at 0.5 TOMO the quote token
+	sold := new(big.Int).Sub(units(100), tradingstate.GetTokenBalance(taker, quoteToken, statedb))
+	if want := new(big.Int).Div(new(big.Int).Mul(units(1001), big.NewInt(200)), big.NewInt(9990)); !within(sold, want) {
+		t.Errorf("taker quote tokens sold mismatch: have %v, want %v", sold, want)
+	}
+}
+
+func TestApplyReplaceOrder(t *testing.T) {
+	cache, _ := lru.New(defaultCacheLimit)
+	tomox := &TomoX{tokenDecimalCache: cache}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	baseToken, quoteToken := common.HexToAddress(common.TomoNativeAddress), common.HexToAddress("0x1100000000000000000000000000000000000003")
+	relayer := common.HexToAddress("0x0000000000000000000000000000000000000010")
+	registerRelayerPair(statedb, relayer, baseToken, quoteToken)
+	statedb.AddBalance(user, new(big.Int).Mul(big.NewInt(1000), common.BasePrice))
+	orderBook := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+
+	// a sell order of the user resting at 10, and a buy order of another at 5
+	tradingStateDb.SetNonce(orderBook, 2)
+	for _, resting := range []tradingstate.OrderItem{
+		{OrderID: 1, Hash: common.BigToHash(common.Big1), Price: big.NewInt(10), Side: tradingstate.Ask, UserAddress: user},
+		{OrderID: 2, Hash: common.BigToHash(common.Big2), Price: big.NewInt(5), Side: tradingstate.Bid, UserAddress: common.HexToAddress("0x02")},
+	} {
+		resting.Quantity, resting.Type, resting.Status = big.NewInt(100), tradingstate.Limit, tradingstate.OrderStatusOpen
+		resting.ExchangeAddress, resting.BaseToken, resting.QuoteToken = relayer, baseToken, quoteToken
+		tradingStateDb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(resting.OrderID)), resting)
+	}
+	replace := func(nonce uint64, price, quantity int64, replacedOrderID uint64, timeInForce string) *tradingstate.OrderItem {
+		hash := common.BigToHash(new(big.Int).SetUint64(nonce + 10))
+		tx := types.NewOrderTransaction(nonce, big.NewInt(quantity), big.NewInt(price), relayer, user, baseToken, quoteToken, tradingstate.OrderNew, tradingstate.Ask, tradingstate.Limit, hash, 0)
+		tx.SetTimeInForce(timeInForce)
+		tx.SetReplacedOrderID(replacedOrderID)
+		tx, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign replacement: %v", err)
+		}
+		V, R, S := tx.Signature()
+		return &tradingstate.OrderItem{
+			Nonce:           new(big.Int).SetUint64(nonce),
+			Quantity:        big.NewInt(quantity),
+			Price:           big.NewInt(price),
+			ExchangeAddress: relayer,
+			UserAddress:     user,
+			BaseToken:       baseToken,
+			QuoteToken:      quoteToken,
+			Status:          tradingstate.OrderNew,
+			Side:            tradingstate.Ask,
+			Type:            tradingstate.Limit,
+			Hash:            hash,
+			TimeInForce:     timeInForce,
+			ReplacedOrderID: replacedOrderID,
+			Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+		}
+	}
+	resting := func(id uint64) tradingstate.OrderItem {
+		return tradingStateDb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(id)))
+	}
+	config := *params.TestChainConfig
+	config.TIPTomoXPostOnlyBlock = big.NewInt(0)
+	config.TIPTomoXReplaceOrderBlock = big.NewInt(1000)
+	chain := &batchCancelChain{config: &config}
+
+	// Before the fork, replacements are rejected
+	if _, rejects, err := tomox.ApplyOrder(&types.Header{Number: big.NewInt(900)}, common.Address{}, chain, statedb, tradingStateDb, orderBook, replace(0, 10, 60, 1, "")); err != nil || len(rejects) != 1 {
+		t.Fatalf("replacement before the fork: rejects %d, err %v", len(rejects), err)
+	}
+	// An order which isn't resting can't be replaced
+	header := &types.Header{Number: big.NewInt(1000)}
+	_, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, replace(1, 10, 60, 9, ""))
+	if err != nil || len(rejects) != 1 || rejects[0].ExtraData != tradingstate.RejectReasonReplace {
+		t.Fatalf("replacement of a missing order: rejects %v, err %v", rejects, err)
+	}
+	// Reducing the quantity at the same price keeps the order id
+	order := replace(2, 10, 60, 1, "")
+	if _, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, order); err != nil || len(rejects) != 1 || rejects[0].OrderID != 1 || rejects[0].Status != tradingstate.OrderStatusCancelled {
+		t.Fatalf("in place replacement: rejects %v, err %v", rejects, err)
+	}
+	if replaced := resting(1); order.OrderID != 1 || replaced.Hash != order.Hash || replaced.Quantity.Int64() != 60 {
+		t.Fatalf("in place replacement mismatch: have id %d, resting %+v", order.OrderID, replaced)
+	}
+	// Changing the price queues the order anew
+	moved := replace(3, 11, 60, 1, "")
+	if _, rejects, err := tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, moved); err != nil || len(rejects) != 1 || rejects[0].Hash != order.Hash {
+		t.Fatalf("replacement at another price: rejects %v, err %v", rejects, err)
+	}
+	if moved.OrderID != 3 || resting(3).Hash != moved.Hash || resting(1).Quantity.Sign() != 0 {
+		t.Fatalf("replacement at another price mismatch: have id %d", moved.OrderID)
+	}
+	// A post-only replacement crossing the spread replaces nothing
+	_, rejects, err = tomox.ApplyOrder(header, common.Address{}, chain, statedb, tradingStateDb, orderBook, replace(4, 5, 60, 3, tradingstate.PostOnly))
+	if err != nil || len(rejects) != 1 || rejects[0].ExtraData != tradingstate.RejectReasonPostOnly {
+		t.Fatalf("crossing post-only replacement: rejects %v, err %v", rejects, err)
+	}
+	if kept := resting(3); kept.Hash != moved.Hash || kept.Quantity.Int64() != 60 {
+		t.Errorf("replaced order mismatch: have %+v", kept)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tomox
+
+import (
+	"fmt"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// peer represents a tomox protocol peer connection.
+type peer struct {
+	host *TomoX
+	peer *p2p.Peer
+	rw   p2p.MsgReadWriter
+
+	known   mapset.Set          // Orders already known by the peer to avoid wasting bandwidth
+	limiter *p2p.MsgRateLimiter // Limit of the orders accepted from the peer
+
+	queue chan []*types.OrderTransaction // Batches of orders to send to the peer
+	quit  chan struct{}
+}
+
+// newPeer creates a new tomox peer object, but does not run the handshake itself.
+func newPeer(host *TomoX, remote *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	return &peer{
+		host:    host,
+		peer:    remote,
+		rw:      rw,
+		known:   mapset.NewSet(),
+		limiter: p2p.NewMsgRateLimiter(map[uint64]float64{ordersCode: float64(host.gossipRate)}, time.Now()),
+		queue:   make(chan []*types.OrderTransaction, peerQueueSize),
+		quit:    make(chan struct{}),
+	}
+}
+
+// ID returns the id of the remote peer.
+func (p *peer) ID() discover.NodeID {
+	return p.peer.ID()
+}
+
+// stop terminates the broadcast of the orders to the peer.
+func (p *peer) stop() {
+	close(p.quit)
+}
+
+// handshake sends the protocol initiation status message to the remote peer and
+// verifies the remote status too.
+func (p *peer) handshake() error {
+	// Send the handshake status message asynchronously
+	errc := make(chan error, 1)
+	go func() {
+		errc <- p2p.SendItems(p.rw, statusCode, ProtocolVersion)
+	}()
+
+	// Fetch the remote status packet and verify protocol match
+	packet, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer packet.Discard()
+	if packet.Code != statusCode {
+		return fmt.Errorf("peer [%x] sent packet %x before status packet", p.ID(), packet.Code)
+	}
+	s := rlp.NewStream(packet.Payload, uint64(packet.Size))
+	if _, err := s.List(); err != nil {
+		return fmt.Errorf("peer [%x] sent bad status message: %v", p.ID(), err)
+	}
+	peerVersion, err := s.Uint()
+	if err != nil {
+		return fmt.Errorf("peer [%x] sent bad status message (unable to decode version): %v", p.ID(), err)
+	}
+	if peerVersion != ProtocolVersion {
+		return fmt.Errorf("peer [%x]: protocol version mismatch %d != %d", p.ID(), peerVersion, ProtocolVersion)
+	}
+	if err := <-errc; err != nil {
+		return fmt.Errorf("peer [%x] failed to send status packet: %v", p.ID(), err)
+	}
+	return nil
+}
+
+// mark marks an order known to the peer so that it won't be sent back.
+func (p *peer) mark(hash common.Hash) {
+	for p.known.Cardinality() >= maxKnownOrders {
+		p.known.Pop()
+	}
+	p.known.Add(hash)
+}
+
+// send queues the orders unknown to the peer to be sent to it, dropping them if
+// the queue of the peer is full.
+func (p *peer) send(txs []*types.OrderTransaction) {
+	bundle := make([]*types.OrderTransaction, 0, len(txs))
+	for _, tx := range txs {
+		if !p.known.Contains(tx.Hash()) {
+			bundle = append(bundle, tx)
+		}
+	}
+	if len(bundle) == 0 {
+		return
+	}
+	select {
+	case p.queue <- bundle:
+		for _, tx := range bundle {
+			p.mark(tx.Hash())
+		}
+	default:
+		log.Debug("Dropped orders to a busy peer", "peer", p.ID(), "orders", len(bundle))
+	}
+}
+
+// broadcast transmits the queued orders to the peer until it's stopped.
+func (p *peer) broadcast() {
+	for {
+		select {
+		case txs := <-p.queue:
+			if err := p2p.Send(p.rw, ordersCode, txs); err != nil {
+				log.Trace("Failed to send orders", "peer", p.ID(), "err", err)
+				return
+			}
+			gossipOutMeter.Mark(int64(len(txs)))
+		case <-p.quit:
+			return
+		}
+	}
+}
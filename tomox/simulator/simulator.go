@@ -0,0 +1,224 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulator replays orders and cancellations against in-memory states
+// with the TomoX matching engine, block after block as the block processor
+// does, so that relayers and auditors can fuzz the matching and verify offline
+// that it is deterministic.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// errCheckpointOrders is returned if orders are given to a checkpoint block,
+// which matches none.
+var errCheckpointOrders = errors.New("no orders are matched in a checkpoint block")
+
+// Pair is a trading pair of a relayer.
+type Pair struct {
+	BaseToken  common.Address
+	QuoteToken common.Address
+}
+
+// Result is the outcome of an order replayed by the simulator.
+type Result struct {
+	Order   *tradingstate.OrderItem
+	Trades  []map[string]string
+	Rejects []*tradingstate.OrderItem
+}
+
+// Block is the outcome of a block of orders replayed by the simulator, with the
+// roots of the states after it.
+type Block struct {
+	Number      uint64
+	Results     []*Result
+	StateRoot   common.Hash
+	TradingRoot common.Hash
+}
+
+// Simulator applies blocks of orders to a chain state and a trading state.
+type Simulator struct {
+	config       *params.ChainConfig
+	engine       *tomox.TomoX
+	statedb      *state.StateDB
+	tradingState *tradingstate.TradingStateDB
+	header       *types.Header
+}
+
+// New creates a simulator replaying orders on top of the given states, from
+// the block after the given head. Nil states are created empty in memory.
+func New(config *params.ChainConfig, head *types.Header, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB) *Simulator {
+	if statedb == nil {
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	}
+	if tradingState == nil {
+		tradingState, _ = tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	}
+	if head == nil {
+		head = &types.Header{Number: new(big.Int), Time: new(big.Int)}
+	}
+	return &Simulator{
+		config:       config,
+		engine:       tomox.NewMatchingEngine(),
+		statedb:      statedb,
+		tradingState: tradingState,
+		header:       types.CopyHeader(head),
+	}
+}
+
+// State returns the chain state of the simulator.
+func (s *Simulator) State() *state.StateDB {
+	return s.statedb
+}
+
+// TradingState returns the trading state of the simulator.
+func (s *Simulator) TradingState() *tradingstate.TradingStateDB {
+	return s.tradingState
+}
+
+// Head returns the header of the last block replayed.
+func (s *Simulator) Head() *types.Header {
+	return types.CopyHeader(s.header)
+}
+
+// SetTokenDecimal sets the decimal of a token, 10 to the power of its decimals,
+// instead of calling its contract.
+func (s *Simulator) SetTokenDecimal(token common.Address, decimal *big.Int) {
+	s.engine.SetTokenDecimal(token, decimal)
+}
+
+// SetTokenBalance sets the balance of a user in a token, creating the account
+// of the token if needed.
+func (s *Simulator) SetTokenBalance(user, token common.Address, balance *big.Int) error {
+	if token != common.HexToAddress(common.TomoNativeAddress) && !s.statedb.Exist(token) {
+		s.statedb.SetNonce(token, 1)
+	}
+	return tradingstate.SetTokenBalance(user, balance, token, s.statedb)
+}
+
+// RegisterRelayer registers a relayer, owning itself, with the given deposit and
+// trading fee for the given pairs, as the relayer registration contract does.
+func (s *Simulator) RegisterRelayer(relayer common.Address, deposit, fee *big.Int, pairs ...Pair) {
+	var (
+		contract = common.HexToAddress(common.RelayerRegistrationSMC)
+		loc      = tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+		count    = common.BigToHash(new(big.Int).SetUint64(tradingstate.RelayerMappingSlot["RelayerCount"]))
+		index    = s.statedb.GetState(contract, count).Big()
+	)
+	field := func(name string) common.Hash {
+		return common.BigToHash(new(big.Int).Add(loc, tradingstate.RelayerStructMappingSlot[name]))
+	}
+	s.statedb.SetState(contract, field("_deposit"), common.BigToHash(deposit))
+	s.statedb.AddBalance(contract, deposit)
+	s.statedb.SetState(contract, field("_fee"), common.BigToHash(fee))
+	s.statedb.SetState(contract, field("_index"), common.BigToHash(index))
+	s.statedb.SetState(contract, field("_owner"), relayer.Hash())
+	s.statedb.SetState(contract, common.BytesToHash(state.GetLocMappingAtKey(common.BigToHash(index), tradingstate.RelayerMappingSlot["RELAYER_COINBASES"]).Bytes()), relayer.Hash())
+	s.statedb.SetState(contract, count, common.BigToHash(new(big.Int).Add(index, common.Big1)))
+
+	fromTokens, toTokens := field("_fromTokens"), field("_toTokens")
+	s.statedb.SetState(contract, fromTokens, common.BigToHash(big.NewInt(int64(len(pairs)))))
+	s.statedb.SetState(contract, toTokens, common.BigToHash(big.NewInt(int64(len(pairs)))))
+	for i, pair := range pairs {
+		s.statedb.SetState(contract, state.GetLocDynamicArrAtElement(fromTokens, uint64(i), 1), pair.BaseToken.Hash())
+		s.statedb.SetState(contract, state.GetLocDynamicArrAtElement(toTokens, uint64(i), 1), pair.QuoteToken.Hash())
+	}
+}
+
+// ApplyBlock replays the orders of the next block, matched by the given
+// coinbase at the given time, in order. An order the engine fails to apply
+// makes the whole block invalid, as it would on the chain. The orders are
+// updated by the matching, as they are by the engine.
+func (s *Simulator) ApplyBlock(coinbase common.Address, time uint64, orders []*tradingstate.OrderItem) (*Block, error) {
+	header := &types.Header{
+		ParentHash: s.header.Hash(),
+		Number:     new(big.Int).Add(s.header.Number, common.Big1),
+		Time:       new(big.Int).SetUint64(time),
+		Coinbase:   coinbase,
+	}
+	chain := &chainContext{config: s.config, header: header}
+	block := &Block{Number: header.Number.Uint64()}
+	s.tradingState.SetPruning(s.config.IsTIPTomoXPrune(header.Number))
+
+	if s.config.Posv != nil && s.config.Posv.Epoch > 0 && s.config.Posv.IsCheckpoint(block.Number) {
+		if len(orders) > 0 {
+			return nil, errCheckpointOrders
+		}
+		if err := s.engine.UpdateMediumPriceBeforeEpoch(s.config.Posv.EpochOf(block.Number), s.tradingState, s.statedb); err != nil {
+			return nil, err
+		}
+		if s.config.IsTIPTomoXDelisting(header.Number) {
+			if err := s.engine.CancelDelistedOrders(header, s.tradingState, s.statedb); err != nil {
+				return nil, err
+			}
+		}
+		if s.config.IsTIPTomoXOrderExpiry(header.Number) {
+			if err := s.engine.PurgeExpiredOrders(header, s.tradingState); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for i, order := range orders {
+			orderBook := tradingstate.GetTradingOrderBookHash(order.BaseToken, order.QuoteToken)
+			trades, rejects, err := s.engine.ApplyOrder(header, coinbase, chain, s.statedb, s.tradingState, orderBook, order)
+			if err != nil {
+				return nil, fmt.Errorf("order %d: %v", i, err)
+			}
+			block.Results = append(block.Results, &Result{Order: order, Trades: trades, Rejects: rejects})
+		}
+		if s.config.IsTIPTomoXStopOrder(header.Number) {
+			if err := s.engine.ProcessTriggeredOrders(header, coinbase, chain, s.statedb, s.tradingState); err != nil {
+				return nil, err
+			}
+		}
+	}
+	block.TradingRoot = s.tradingState.IntermediateRoot()
+	block.StateRoot = s.statedb.IntermediateRoot(s.config.IsEIP158(header.Number))
+	s.header = header
+	return block, nil
+}
+
+// chainContext is the chain the engine matches orders on, whose only known
+// header is the one of the block replayed.
+type chainContext struct {
+	config *params.ChainConfig
+	header *types.Header
+}
+
+func (c *chainContext) Engine() consensus.Engine { return nil }
+
+func (c *chainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if c.header.Hash() == hash && c.header.Number.Uint64() == number {
+		return c.header
+	}
+	return nil
+}
+
+func (c *chainContext) CurrentHeader() *types.Header { return c.header }
+
+func (c *chainContext) Config() *params.ChainConfig { return c.config }
@@ -0,0 +1,133 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+var (
+	baseToken  = common.HexToAddress(common.TomoNativeAddress)
+	quoteToken = common.HexToAddress("0x1100000000000000000000000000000000000003")
+	relayer    = common.HexToAddress("0x0000000000000000000000000000000000000010")
+)
+
+func newOrder(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, side string, price, quantity *big.Int) *tradingstate.OrderItem {
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	hash := crypto.Keccak256Hash(user.Bytes(), new(big.Int).SetUint64(nonce).Bytes())
+	tx := types.NewOrderTransaction(nonce, quantity, price, relayer, user, baseToken, quoteToken, tradingstate.OrderNew, side, tradingstate.Limit, hash, 0)
+	tx, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign order: %v", err)
+	}
+	V, R, S := tx.Signature()
+	return &tradingstate.OrderItem{
+		Nonce:           new(big.Int).SetUint64(nonce),
+		Quantity:        quantity,
+		Price:           price,
+		ExchangeAddress: relayer,
+		UserAddress:     user,
+		BaseToken:       baseToken,
+		QuoteToken:      quoteToken,
+		Status:          tradingstate.OrderNew,
+		Side:            side,
+		Type:            tradingstate.Limit,
+		Hash:            hash,
+		Signature:       &tradingstate.Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)},
+	}
+}
+
+func newSimulator(t *testing.T, users ...common.Address) *Simulator {
+	sim := New(params.TestChainConfig, nil, nil, nil)
+	sim.SetTokenDecimal(quoteToken, common.BasePrice)
+	sim.RegisterRelayer(relayer, new(big.Int).Mul(common.BasePrice, new(big.Int).Add(common.RelayerLockedFund, common.Big1)), new(big.Int), Pair{baseToken, quoteToken})
+	funds := new(big.Int).Mul(big.NewInt(100), common.BasePrice)
+	for _, user := range users {
+		for _, token := range []common.Address{baseToken, quoteToken} {
+			if err := sim.SetTokenBalance(user, token, funds); err != nil {
+				t.Fatalf("failed to fund user: %v", err)
+			}
+		}
+	}
+	return sim
+}
+
+// Tests that replaying the same orders produces the same trades and roots.
+func TestReplayDeterminism(t *testing.T) {
+	makerKey, _ := crypto.GenerateKey()
+	takerKey, _ := crypto.GenerateKey()
+	maker, taker := crypto.PubkeyToAddress(makerKey.PublicKey), crypto.PubkeyToAddress(takerKey.PublicKey)
+	ten := new(big.Int).Mul(big.NewInt(10), common.BasePrice)
+	blocks := [][]*tradingstate.OrderItem{
+		{newOrder(t, makerKey, 0, tradingstate.Ask, common.BasePrice, ten)},
+		{newOrder(t, takerKey, 0, tradingstate.Bid, common.BasePrice, big.NewInt(0).Div(ten, common.Big2))},
+	}
+	replay := func() []*Block {
+		sim := newSimulator(t, maker, taker)
+		var results []*Block
+		for i, orders := range blocks {
+			copies := make([]*tradingstate.OrderItem, len(orders))
+			for j, order := range orders {
+				copied := *order
+				copies[j] = &copied
+			}
+			block, err := sim.ApplyBlock(common.Address{}, uint64(i+1)*2, copies)
+			if err != nil {
+				t.Fatalf("block %d: failed to apply: %v", i+1, err)
+			}
+			results = append(results, block)
+		}
+		return results
+	}
+	first, second := replay(), replay()
+	if trades := first[1].Results[0].Trades; len(trades) != 1 || trades[0][tradingstate.TradeQuantity] != new(big.Int).Div(ten, common.Big2).String() {
+		t.Fatalf("trades mismatch: have %v", trades)
+	}
+	for i := range first {
+		if first[i].Number != uint64(i+1) || first[i].StateRoot != second[i].StateRoot || first[i].TradingRoot != second[i].TradingRoot {
+			t.Errorf("block %d: roots mismatch: have %x/%x, want %x/%x", i+1, second[i].StateRoot, second[i].TradingRoot, first[i].StateRoot, first[i].TradingRoot)
+		}
+	}
+	if first[0].TradingRoot == first[1].TradingRoot {
+		t.Errorf("trading root unchanged by the trade")
+	}
+}
+
+// Tests that no orders are matched in checkpoint blocks.
+func TestCheckpointBlock(t *testing.T) {
+	makerKey, _ := crypto.GenerateKey()
+	config := *params.TestChainConfig
+	config.Posv = &params.PosvConfig{Epoch: 2}
+	sim := New(&config, nil, nil, nil)
+	if _, err := sim.ApplyBlock(common.Address{}, 1, nil); err != nil {
+		t.Fatalf("failed to apply block: %v", err)
+	}
+	if _, err := sim.ApplyBlock(common.Address{}, 2, []*tradingstate.OrderItem{newOrder(t, makerKey, 0, tradingstate.Ask, common.BasePrice, common.BasePrice)}); err != errCheckpointOrders {
+		t.Errorf("checkpoint block error mismatch: have %v, want %v", err, errCheckpointOrders)
+	}
+	if head := sim.Head(); head.Number.Uint64() != 1 {
+		t.Errorf("head mismatch: have %d, want 1", head.Number)
+	}
+}
@@ -1,24 +1,30 @@
 package tomox
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/enr"
+	"github.com/tomochain/tomochain/rlp"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"github.com/tomochain/tomochain/tomoxDAO"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 
+	"github.com/VictoriaMetrics/fastcache"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/trie"
 	"golang.org/x/sync/syncmap"
 )
 
@@ -29,6 +35,8 @@ const (
 	overflowIdx        // Indicator of message queue overflow
 	defaultCacheLimit  = 1024
 	MaximumTxMatchSize = 1000
+
+	DefaultSinkKafkaTopic = "tomox." // Default prefix of the Kafka topics of the records
 )
 
 var (
@@ -42,11 +50,25 @@ type Config struct {
 	DBName         string `toml:",omitempty"`
 	ConnectionUrl  string `toml:",omitempty"`
 	ReplicaSetName string `toml:",omitempty"`
+
+	SinkPostgresURL string `toml:",omitempty"` // PostgreSQL database the SDK node also reports its records to
+	SinkKafkaURL    string `toml:",omitempty"` // Kafka REST proxy the SDK node also reports its records to
+	SinkKafkaTopic  string `toml:",omitempty"` // Prefix of the Kafka topics of the records
+
+	OrderGossipRate     int `toml:",omitempty"` // Orders per second accepted from a peer of the tomox protocol
+	OrderGossipPoolSize int `toml:",omitempty"` // Number of gossiped orders kept to be relayed
+
+	Candles      bool `toml:",omitempty"` // Aggregate the matched trades into candles
+	AddressIndex bool `toml:",omitempty"` // Index the orders and lending trades by user address
+	Verify       bool `toml:",omitempty"` // Cross-check the special transactions of the imported blocks
 }
 
 // DefaultConfig represents (shocker!) the default configuration.
 var DefaultConfig = Config{
-	DataDir: "",
+	DataDir:             "",
+	OrderGossipRate:     DefaultOrderGossipRate,
+	OrderGossipPoolSize: DefaultOrderGossipPoolSize,
+	SinkKafkaTopic:      DefaultSinkKafkaTopic,
 }
 
 type TomoX struct {
@@ -59,22 +81,63 @@ type TomoX struct {
 	orderNonce map[common.Address]*big.Int
 
 	sdkNode           bool
+	verify            bool
 	settings          syncmap.Map // holds configuration settings that can be dynamically changed
 	tokenDecimalCache *lru.Cache
 	orderCache        *lru.Cache
+
+	// Order gossip
+	orderPool  OrderPool
+	gossip     *orderGossipPool
+	gossipRate int
+	peers      map[*peer]struct{}
+	peersLock  sync.RWMutex
+	quit       chan struct{}
+
+	candles    *candleAggregator // nil unless the candles are enabled
+	orderIndex *AddressIndex     // nil unless the address index is enabled
 }
 
 func (tomox *TomoX) Protocols() []p2p.Protocol {
-	return []p2p.Protocol{}
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: uint(ProtocolVersion),
+		Length:  ProtocolLength,
+		Run:     tomox.HandlePeer,
+		NodeInfo: func() interface{} {
+			return map[string]interface{}{
+				"version":       ProtocolVersionStr,
+				"pendingOrders": tomox.gossip.len(),
+			}
+		},
+		Attributes: []enr.Entry{tomoxEntry{Version: uint(ProtocolVersion)}},
+	}}
 }
 
+// tomoxEntry is the "tomox" entry of the node record, advertising the version
+// of the tomox protocol run by the node.
+type tomoxEntry struct {
+	Version uint
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e tomoxEntry) ENRKey() string { return "tomox" }
+
 func (tomox *TomoX) Start(server *p2p.Server) error {
+	go tomox.gossipLoop()
 	return nil
 }
 
 func (tomox *TomoX) SaveData() {
 }
 func (tomox *TomoX) Stop() error {
+	close(tomox.quit)
+	if tomox.candles != nil {
+		tomox.candles.scope.Close()
+	}
 	return nil
 }
 
@@ -94,6 +157,23 @@ func NewMongoDBEngine(cfg *Config) *tomoxDAO.MongoDatabase {
 	return mongoDB
 }
 
+// NewReportingSinks connects to the sinks the SDK node reports its records to
+// besides MongoDB.
+func NewReportingSinks(cfg *Config) []tomoxDAO.Sink {
+	var sinks []tomoxDAO.Sink
+	if cfg.SinkPostgresURL != "" {
+		sink, err := tomoxDAO.NewPostgresSink(cfg.SinkPostgresURL)
+		if err != nil {
+			log.Crit("Failed to init postgresql sink", "err", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if cfg.SinkKafkaURL != "" {
+		sinks = append(sinks, tomoxDAO.NewKafkaSink(cfg.SinkKafkaURL, cfg.SinkKafkaTopic))
+	}
+	return sinks
+}
+
 func New(cfg *Config) *TomoX {
 	tokenDecimalCache, _ := lru.New(defaultCacheLimit)
 	orderCache, _ := lru.New(tradingstate.OrderCacheLimit)
@@ -102,7 +182,18 @@ func New(cfg *Config) *TomoX {
 		Triegc:            prque.New(),
 		tokenDecimalCache: tokenDecimalCache,
 		orderCache:        orderCache,
+		gossipRate:        cfg.OrderGossipRate,
+		peers:             make(map[*peer]struct{}),
+		quit:              make(chan struct{}),
+	}
+	if tomoX.gossipRate <= 0 {
+		tomoX.gossipRate = DefaultOrderGossipRate
 	}
+	poolSize := cfg.OrderGossipPoolSize
+	if poolSize <= 0 {
+		poolSize = DefaultOrderGossipPoolSize
+	}
+	tomoX.gossip = newOrderGossipPool(poolSize)
 
 	// default DBEngine: levelDB
 	tomoX.db = NewLDBEngine(cfg)
@@ -111,7 +202,20 @@ func New(cfg *Config) *TomoX {
 	if cfg.DBEngine == "mongodb" { // this is an add-on DBEngine for SDK nodes
 		tomoX.mongodb = NewMongoDBEngine(cfg)
 		tomoX.sdkNode = true
+		if sinks := NewReportingSinks(cfg); len(sinks) > 0 {
+			tomoX.mongodb = tomoxDAO.NewReportingDatabase(tomoX.mongodb, sinks...)
+		}
+	} else if cfg.SinkPostgresURL != "" || cfg.SinkKafkaURL != "" {
+		log.Warn("Reporting sinks are ignored without the mongodb engine of SDK nodes")
+	}
+
+	if cfg.Candles {
+		tomoX.candles = newCandleAggregator(tomoX.db)
 	}
+	if cfg.AddressIndex {
+		tomoX.orderIndex = NewAddressIndex(tomoX.db, orderIndexName)
+	}
+	tomoX.verify = cfg.Verify
 
 	tomoX.StateCache = tradingstate.NewDatabase(tomoX.db)
 	tomoX.settings.Store(overflowIdx, false)
@@ -119,6 +223,23 @@ func New(cfg *Config) *TomoX {
 	return tomoX
 }
 
+// NewMatchingEngine creates a TomoX only applying orders to the states it is
+// given, without database, SDK node nor order gossip.
+func NewMatchingEngine() *TomoX {
+	tokenDecimalCache, _ := lru.New(defaultCacheLimit)
+	orderCache, _ := lru.New(tradingstate.OrderCacheLimit)
+	return &TomoX{
+		orderNonce:        make(map[common.Address]*big.Int),
+		Triegc:            prque.New(),
+		tokenDecimalCache: tokenDecimalCache,
+		orderCache:        orderCache,
+		gossipRate:        DefaultOrderGossipRate,
+		gossip:            newOrderGossipPool(DefaultOrderGossipPoolSize),
+		peers:             make(map[*peer]struct{}),
+		quit:              make(chan struct{}),
+	}
+}
+
 // Overflow returns an indication if the message queue is full.
 func (tomox *TomoX) Overflow() bool {
 	val, _ := tomox.settings.Load(overflowIdx)
@@ -129,6 +250,12 @@ func (tomox *TomoX) IsSDKNode() bool {
 	return tomox.sdkNode
 }
 
+// VerifyEnabled returns whether the special transactions of the imported blocks
+// are cross-checked with the matching of the node, besides the state roots.
+func (tomox *TomoX) VerifyEnabled() bool {
+	return tomox.verify
+}
+
 func (tomox *TomoX) GetLevelDB() tomoxDAO.TomoXDAO {
 	return tomox.db
 }
@@ -180,18 +307,25 @@ func (tomox *TomoX) ProcessOrderPending(header *types.Header, coinbase common.Ad
 		}
 
 		order := &tradingstate.OrderItem{
-			Nonce:           big.NewInt(int64(tx.Nonce())),
-			Quantity:        tx.Quantity(),
-			Price:           tx.Price(),
-			ExchangeAddress: tx.ExchangeAddress(),
-			UserAddress:     tx.UserAddress(),
-			BaseToken:       tx.BaseToken(),
-			QuoteToken:      tx.QuoteToken(),
-			Status:          tx.Status(),
-			Side:            tx.Side(),
-			Type:            tx.Type(),
-			Hash:            tx.OrderHash(),
-			OrderID:         tx.OrderID(),
+			Nonce:               big.NewInt(int64(tx.Nonce())),
+			Quantity:            tx.Quantity(),
+			Price:               tx.Price(),
+			ExchangeAddress:     tx.ExchangeAddress(),
+			UserAddress:         tx.UserAddress(),
+			BaseToken:           tx.BaseToken(),
+			QuoteToken:          tx.QuoteToken(),
+			Status:              tx.Status(),
+			Side:                tx.Side(),
+			Type:                tx.Type(),
+			Hash:                tx.OrderHash(),
+			OrderID:             tx.OrderID(),
+			Cancels:             tx.Cancels(),
+			TriggerPrice:        tx.TriggerPrice(),
+			DisplayQuantity:     tx.DisplayQuantity(),
+			TimeInForce:         tx.TimeInForce(),
+			ExpiresAt:           tx.ExpiresAt(),
+			SelfTradePrevention: tx.SelfTradePrevention(),
+			ReplacedOrderID:     tx.ReplacedOrderID(),
 			Signature: &tradingstate.Signature{
 				V: byte(n),
 				R: common.BigToHash(R),
@@ -315,6 +449,15 @@ func (tomox *TomoX) ConvertTOMOToToken(chain consensus.ChainContext, statedb *st
 // 		a. PutObject them to `trades` collection
 // 		b. Update status of regrading orders to sdktypes.OrderStatusFilled
 func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, trades []map[string]string, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) error {
+	if len(takerOrderInTx.Cancels) > 0 {
+		return tomox.syncBatchCancelToSDKNode(takerOrderInTx, txHash, txMatchTime, statedb, rejectedOrders, dirtyOrderCount)
+	}
+	if takerOrderInTx.IsReplacement() {
+		var err error
+		if rejectedOrders, err = tomox.syncReplacedOrderToSDKNode(takerOrderInTx, txHash, txMatchTime, statedb, rejectedOrders, dirtyOrderCount); err != nil {
+			return err
+		}
+	}
 	var (
 		// originTakerOrder: order get from db, nil if it doesn't exist
 		// takerOrderInTx: order decoded from txdata
@@ -387,6 +530,12 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 		tradeRecord.PricePoint = price
 		tradeRecord.BaseToken = updatedTakerOrder.BaseToken
 		tradeRecord.QuoteToken = updatedTakerOrder.QuoteToken
+		// the trades of a routed order are on the pairs of its tokens against TOMO
+		if baseToken, ok := trade[tradingstate.TradeBaseToken]; ok {
+			tradeRecord.BaseToken = common.HexToAddress(baseToken)
+			tradeRecord.QuoteToken = common.HexToAddress(trade[tradingstate.TradeQuoteToken])
+		}
+		tradesQuoteToken := tradeRecord.BaseToken != updatedTakerOrder.BaseToken
 		tradeRecord.Status = tradingstate.TradeStatusSuccess
 		tradeRecord.Taker = updatedTakerOrder.UserAddress
 		tradeRecord.Maker = common.HexToAddress(trade[tradingstate.TradeMaker])
@@ -394,11 +543,22 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 		tradeRecord.MakerOrderHash = common.HexToHash(trade[tradingstate.TradeMakerOrderHash])
 		tradeRecord.TxHash = txHash
 		tradeRecord.TakerOrderSide = updatedTakerOrder.Side
+		if tradesQuoteToken {
+			// the hop trading the quote token is on the other side
+			if updatedTakerOrder.Side == tradingstate.Bid {
+				tradeRecord.TakerOrderSide = tradingstate.Ask
+			} else {
+				tradeRecord.TakerOrderSide = tradingstate.Bid
+			}
+		}
 		tradeRecord.TakerExchange = updatedTakerOrder.ExchangeAddress
 		tradeRecord.MakerExchange = common.HexToAddress(trade[tradingstate.TradeMakerExchange])
 
 		tradeRecord.MakeFee, _ = new(big.Int).SetString(trade[tradingstate.MakerFee], 10)
 		tradeRecord.TakeFee, _ = new(big.Int).SetString(trade[tradingstate.TakerFee], 10)
+		if rebate, ok := trade[tradingstate.MakerRebate]; ok {
+			tradeRecord.MakerRebate, _ = new(big.Int).SetString(rebate, 10)
+		}
 
 		// set makerOrderType, takerOrderType
 		tradeRecord.MakerOrderType = trade[tradingstate.MakerOrderType]
@@ -428,6 +588,10 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 		makerDirtyFilledAmount[trade[tradingstate.TradeMakerOrderHash]] = makerFilledAmount
 		makerDirtyHashes = append(makerDirtyHashes, trade[tradingstate.TradeMakerOrderHash])
 
+		// the quote token traded by a routed order doesn't fill it
+		if tradesQuoteToken {
+			continue
+		}
 		//updatedTakerOrder = tomox.updateMatchedOrder(updatedTakerOrder, filledAmount, txMatchTime, txHash)
 		//  update filledAmount, status of takerOrder
 		updatedTakerOrder.FilledAmount = new(big.Int).Add(updatedTakerOrder.FilledAmount, filledAmount)
@@ -513,6 +677,9 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 				} else {
 					updatedTakerOrder.Status = tradingstate.OrderStatusRejected
 				}
+				if rejectedOrder.ExtraData != "" {
+					updatedTakerOrder.ExtraData = rejectedOrder.ExtraData
+				}
 				updatedTakerOrder.TxHash = txHash
 				updatedTakerOrder.UpdatedAt = txMatchTime
 				if err := db.PutObject(updatedTakerOrder.Hash, updatedTakerOrder); err != nil {
@@ -562,6 +729,46 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 	return nil
 }
 
+// syncBatchCancelToSDKNode updates the SDK node with each of the orders cancelled
+// by a batch cancellation, as if they were cancelled one by one.
+func (tomox *TomoX) syncBatchCancelToSDKNode(batch *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) error {
+	if len(rejectedOrders) > 0 {
+		log.Debug("Batch cancellation is rejected", "order", tradingstate.ToJSON(batch))
+		return nil
+	}
+	var extraData []string
+	if err := json.Unmarshal([]byte(batch.ExtraData), &extraData); err != nil || len(extraData) != len(batch.Cancels) {
+		return fmt.Errorf("invalid extra data of batch cancellation at txhash %s : %s", txHash.Hex(), batch.ExtraData)
+	}
+	for i, cancel := range batch.Cancels {
+		order := *batch
+		order.OrderID, order.Hash, order.ExtraData, order.Cancels = cancel.OrderID, cancel.Hash, extraData[i], nil
+		if err := tomox.SyncDataToSDKNode(&order, txHash, txMatchTime, statedb, nil, nil, dirtyOrderCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncReplacedOrderToSDKNode updates the SDK node with the cancellation of the
+// order replaced by a replacement, returned among its rejected orders, and
+// returns the other rejected orders.
+func (tomox *TomoX) syncReplacedOrderToSDKNode(replacement *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) ([]*tradingstate.OrderItem, error) {
+	var others []*tradingstate.OrderItem
+	for _, rejected := range rejectedOrders {
+		if rejected.OrderID != replacement.ReplacedOrderID || rejected.Hash == replacement.Hash {
+			others = append(others, rejected)
+			continue
+		}
+		order := *rejected
+		order.Status, order.ReplacedOrderID = tradingstate.OrderStatusCancelled, 0
+		if err := tomox.SyncDataToSDKNode(&order, txHash, txMatchTime, statedb, nil, nil, dirtyOrderCount); err != nil {
+			return nil, err
+		}
+	}
+	return others, nil
+}
+
 func (tomox *TomoX) GetTradingState(block *types.Block, author common.Address) (*tradingstate.TradingStateDB, error) {
 	root, err := tomox.GetTradingStateRoot(block, author)
 	if err != nil {
@@ -576,6 +783,12 @@ func (tomox *TomoX) GetTradingState(block *types.Block, author common.Address) (
 func (tomox *TomoX) GetStateCache() tradingstate.Database {
 	return tomox.StateCache
 }
+
+// SetTrieCleanCache replaces the trading state database with one reading the
+// trie nodes through the given clean cache, shared with the other tries.
+func (tomox *TomoX) SetTrieCleanCache(cleans *fastcache.Cache) {
+	tomox.StateCache = tradingstate.NewDatabaseWithConfig(tomox.db, &trie.Config{Cleans: cleans})
+}
 func (tomox *TomoX) HasTradingState(block *types.Block, author common.Address) bool {
 	root, err := tomox.GetTradingStateRoot(block, author)
 	if err != nil {
@@ -15,13 +15,21 @@ const (
 )
 
 var (
-	EmptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
-	Ask       = "SELL"
-	Bid       = "BUY"
-	Market    = "MO"
-	Limit     = "LO"
-	Cancel    = "CANCELLED"
-	OrderNew  = "NEW"
+	EmptyRoot         = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	Ask               = "SELL"
+	Bid               = "BUY"
+	Market            = "MO"
+	Limit             = "LO"
+	StopMarket        = "SMO"
+	StopLimit         = "SLO"
+	PostOnly          = "PO"
+	ImmediateOrCancel = "IOC"
+	FillOrKill        = "FOK"
+	CancelNewest      = "CN"
+	CancelOldest      = "CO"
+	CancelBoth        = "CB"
+	Cancel            = "CANCELLED"
+	OrderNew          = "NEW"
 )
 
 var EmptyHash = common.Hash{}
@@ -41,19 +49,34 @@ var EmptyOrder = OrderItem{
 	Quantity: Zero,
 }
 
+// IsEmptyOrder reports whether order is the EmptyOrder returned for the orders
+// missing from the order trie.
+func IsEmptyOrder(order OrderItem) bool {
+	return order.Quantity == EmptyOrder.Quantity
+}
+
 var (
-	ErrInvalidSignature = errors.New("verify order: invalid signature")
-	ErrInvalidPrice     = errors.New("verify order: invalid price")
-	ErrInvalidQuantity  = errors.New("verify order: invalid quantity")
-	ErrInvalidRelayer   = errors.New("verify order: invalid relayer")
-	ErrInvalidOrderType = errors.New("verify order: unsupported order type")
-	ErrInvalidOrderSide = errors.New("verify order: invalid order side")
-	ErrInvalidStatus    = errors.New("verify order: invalid status")
+	ErrInvalidSignature   = errors.New("verify order: invalid signature")
+	ErrInvalidPrice       = errors.New("verify order: invalid price")
+	ErrInvalidQuantity    = errors.New("verify order: invalid quantity")
+	ErrInvalidRelayer     = errors.New("verify order: invalid relayer")
+	ErrInvalidOrderType   = errors.New("verify order: unsupported order type")
+	ErrInvalidOrderSide   = errors.New("verify order: invalid order side")
+	ErrInvalidStatus      = errors.New("verify order: invalid status")
+	ErrInvalidCancels     = errors.New("verify order: invalid batch cancellation")
+	ErrInvalidTrigger     = errors.New("verify order: invalid trigger price")
+	ErrInvalidDisplay     = errors.New("verify order: invalid display quantity")
+	ErrInvalidTimeInForce = errors.New("verify order: invalid time in force")
+	ErrInvalidExpiry      = errors.New("verify order: invalid expiry time")
+	ErrInvalidSelfTrade   = errors.New("verify order: invalid self-trade prevention")
+	ErrInvalidReplace     = errors.New("verify order: invalid replacement")
 
 	// supported order types
 	MatchingOrderType = map[string]bool{
-		Market: true,
-		Limit:  true,
+		Market:     true,
+		Limit:      true,
+		StopMarket: true,
+		StopLimit:  true,
 	}
 )
 
@@ -77,6 +100,15 @@ type tradingExchangeObject struct {
 	BidRoot                common.Hash // merkle root of the storage trie
 	OrderRoot              common.Hash
 	LiquidationPriceRoot   common.Hash
+
+	// Stop orders waiting for their trigger price, after TIPTomoXStopOrder.
+	// These roots are kept empty until the first stop order of the book.
+	StopAskRoot common.Hash `rlp:"optional"`
+	StopBidRoot common.Hash `rlp:"optional"`
+
+	// Good-till-date orders by expiry time, after TIPTomoXOrderExpiry. This root
+	// is kept empty until the first such order of the book.
+	ExpiryRoot common.Hash `rlp:"optional"`
 }
 
 var (
@@ -93,6 +125,8 @@ var (
 		"RELAYER_ON_SALE_LIST": 6,
 		"RelayerCount":         7,
 		"MinimumDeposit":       8,
+		// ActiveRelayerCount and TomoXListing take slots 9 and 10
+		"RELAYER_MAKER_REBATES": 11,
 	}
 	RelayerStructMappingSlot = map[string]*big.Int{
 		"_deposit":    big.NewInt(0),
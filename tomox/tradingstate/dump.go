@@ -132,6 +132,44 @@ func (self *TradingStateDB) DumpBidTrie(orderBook common.Hash) (map[*big.Int]Dum
 	return mapResult, nil
 }
 
+// DumpStopTrie returns the stop orders of the given side of an order book, by
+// trigger price.
+func (self *TradingStateDB) DumpStopTrie(orderBook common.Hash, side string) (map[*big.Int]DumpOrderList, error) {
+	exhangeObject := self.getStateExchangeObject(orderBook)
+	if exhangeObject == nil {
+		return nil, fmt.Errorf("Order book not found orderBook : %v ", orderBook.Hex())
+	}
+	tr := exhangeObject.getStopTrie(self.db, side)
+	if tr == nil {
+		return nil, fmt.Errorf("Order side not found : %s ", side)
+	}
+	objects, _, _ := exhangeObject.stopOrderLists(side)
+	mapResult := map[*big.Int]DumpOrderList{}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		priceHash := common.BytesToHash(it.Key)
+		if common.EmptyHash(priceHash) {
+			continue
+		}
+		if _, exist := objects[priceHash]; exist {
+			continue
+		}
+		price := new(big.Int).SetBytes(priceHash.Bytes())
+		var data orderList
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return nil, fmt.Errorf("Fail when decode stop order list orderBook : %v ,price :%v ", orderBook.Hex(), price)
+		}
+		stateOrderList := newStateOrderList(self, side, orderBook, priceHash, data, nil)
+		mapResult[price] = stateOrderList.DumpOrderList(self.db)
+	}
+	for priceHash, stateOrderList := range objects {
+		if stateOrderList.Volume().Sign() > 0 {
+			mapResult[new(big.Int).SetBytes(priceHash.Bytes())] = stateOrderList.DumpOrderList(self.db)
+		}
+	}
+	return mapResult, nil
+}
+
 func (self *TradingStateDB) GetBids(orderBook common.Hash) (map[*big.Int]*big.Int, error) {
 	exhangeObject := self.getStateExchangeObject(orderBook)
 	if exhangeObject == nil {
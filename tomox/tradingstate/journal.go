@@ -101,10 +101,14 @@ func (ch subAmountOrder) undo(s *TradingStateDB) {
 		return
 	}
 	stateOrderItem := stateOrderBook.getStateOrderObject(s.db, ch.orderId)
-	newAmount := new(big.Int).Add(stateOrderItem.Quantity(), ch.amount)
-	stateOrderItem.setVolume(newAmount)
+	currentAmount := new(big.Int).SetBytes(stateOrderList.GetOrderAmount(s.db, ch.orderId).Bytes())
+	stateOrderItem.setVolume(new(big.Int).Add(stateOrderItem.Quantity(), ch.amount))
+	newAmount := stateOrderItem.data.VisibleQuantity()
 	stateOrderList.insertOrderItem(s.db, ch.orderId, common.BigToHash(newAmount))
-	stateOrderList.AddVolume(ch.amount)
+	stateOrderList.AddVolume(new(big.Int).Sub(newAmount, currentAmount))
+	if ch.order.ExpiresAt != 0 {
+		s.insertOrderExpiry(stateOrderBook, ch.orderId, ch.order.ExpiresAt)
+	}
 }
 func (ch nonceChange) undo(s *TradingStateDB) {
 	s.SetNonce(ch.hash, ch.prev)
@@ -0,0 +1,205 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// Migration is a change of the layout of the trading state, applied to the
+// state of an epoch checkpoint by the hard fork changing it.
+type Migration struct {
+	Name  string
+	Apply func(state *TradingStateDB) error
+}
+
+// OrderBookDiff is the change of an order book by a migration.
+type OrderBookDiff struct {
+	Hash         common.Hash `json:"hash"`
+	RootBefore   common.Hash `json:"rootBefore"` // empty if the order book was created
+	RootAfter    common.Hash `json:"rootAfter"`  // empty if the order book was removed
+	OrdersBefore int         `json:"ordersBefore"`
+	OrdersAfter  int         `json:"ordersAfter"`
+}
+
+// Migrate applies the migrations in turn to a copy of the state, which is left
+// untouched, and returns the migrated state along with the order books they
+// changed.
+func Migrate(state *TradingStateDB, migrations ...Migration) (*TradingStateDB, []OrderBookDiff, error) {
+	state.Finalise()
+	migrated := state.Copy()
+	for _, migration := range migrations {
+		if err := migration.Apply(migrated); err != nil {
+			return nil, nil, fmt.Errorf("migration %s: %v", migration.Name, err)
+		}
+		migrated.Finalise()
+		if err := migrated.Error(); err != nil {
+			return nil, nil, fmt.Errorf("migration %s: %v", migration.Name, err)
+		}
+	}
+	diffs, err := DiffOrderBooks(state, migrated)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrated, diffs, nil
+}
+
+// DiffOrderBooks returns the order books which differ between two states, in
+// ascending order of their hashes.
+func DiffOrderBooks(before, after *TradingStateDB) ([]OrderBookDiff, error) {
+	before.Finalise()
+	after.Finalise()
+
+	seen := map[common.Hash]struct{}{}
+	var orderBooks []common.Hash
+	for _, hashes := range [][]common.Hash{before.GetOrderBookHashes(), after.GetOrderBookHashes()} {
+		for _, orderBook := range hashes {
+			if _, ok := seen[orderBook]; !ok {
+				seen[orderBook] = struct{}{}
+				orderBooks = append(orderBooks, orderBook)
+			}
+		}
+	}
+	sort.Slice(orderBooks, func(i, j int) bool {
+		return bytes.Compare(orderBooks[i][:], orderBooks[j][:]) < 0
+	})
+
+	diffs := []OrderBookDiff{}
+	for _, orderBook := range orderBooks {
+		diff := OrderBookDiff{Hash: orderBook, RootBefore: before.GetOrderBookRoot(orderBook), RootAfter: after.GetOrderBookRoot(orderBook)}
+		if diff.RootBefore == diff.RootAfter {
+			continue
+		}
+		var err error
+		if diff.OrdersBefore, err = before.countOrders(orderBook); err != nil {
+			return nil, err
+		}
+		if diff.OrdersAfter, err = after.countOrders(orderBook); err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// countOrders returns the number of orders of an order book, resting or waiting
+// for their trigger price.
+func (self *TradingStateDB) countOrders(orderBook common.Hash) (int, error) {
+	resting, err := self.GetRestingOrderIds(orderBook)
+	if err != nil {
+		return 0, err
+	}
+	stops, err := self.GetStopOrderIds(orderBook)
+	if err != nil {
+		return 0, err
+	}
+	return len(resting) + len(stops), nil
+}
+
+// RekeyOrderBook moves the order book of a pair to another pair, such as the
+// pair of a token whose contract was redeployed. The orders keep their ids,
+// hashes and signatures, their tokens being rewritten to the new pair. Order
+// books holding the liquidation prices of lending trades can't be moved, as
+// the trades refer to them by their tokens.
+func RekeyOrderBook(baseToken, quoteToken, newBaseToken, newQuoteToken common.Address) Migration {
+	return Migration{
+		Name: fmt.Sprintf("rekey %s/%s to %s/%s", baseToken.Hex(), quoteToken.Hex(), newBaseToken.Hex(), newQuoteToken.Hex()),
+		Apply: func(state *TradingStateDB) error {
+			return state.moveOrderBook(GetTradingOrderBookHash(baseToken, quoteToken), GetTradingOrderBookHash(newBaseToken, newQuoteToken), func(order *OrderItem) {
+				order.BaseToken, order.QuoteToken = newBaseToken, newQuoteToken
+			})
+		},
+	}
+}
+
+// DropOrderBook removes the order book of a delisted pair, along with its
+// prices. The order book must hold no order anymore.
+func DropOrderBook(baseToken, quoteToken common.Address) Migration {
+	return Migration{
+		Name: fmt.Sprintf("drop %s/%s", baseToken.Hex(), quoteToken.Hex()),
+		Apply: func(state *TradingStateDB) error {
+			orderBook := GetTradingOrderBookHash(baseToken, quoteToken)
+			stateObject := state.getStateExchangeObject(orderBook)
+			if stateObject == nil {
+				return fmt.Errorf("order book %s not found", orderBook.Hex())
+			}
+			if !isEmptyRoot(stateObject.data.OrderRoot) || !isEmptyRoot(stateObject.data.LiquidationPriceRoot) {
+				return fmt.Errorf("order book %s still holds orders or liquidation prices", orderBook.Hex())
+			}
+			state.deleteStateExchangeObject(orderBook)
+			return nil
+		},
+	}
+}
+
+// moveOrderBook moves the order book from one hash to another, rewriting each
+// of its orders. The state is committed first, so that the tries of the order
+// book can be opened again under the new hash, the orders only being written
+// again.
+func (self *TradingStateDB) moveOrderBook(from, to common.Hash, rewrite func(order *OrderItem)) error {
+	if _, err := self.Commit(); err != nil {
+		return err
+	}
+	src := self.getStateExchangeObject(from)
+	if src == nil {
+		return fmt.Errorf("order book %s not found", from.Hex())
+	}
+	if self.getStateExchangeObject(to) != nil {
+		return fmt.Errorf("order book %s already exists", to.Hex())
+	}
+	if !isEmptyRoot(src.data.LiquidationPriceRoot) {
+		return fmt.Errorf("order book %s holds liquidation prices", from.Hex())
+	}
+	dst := newStateExchanges(self, to, src.data, self.MarkStateExchangeObjectDirty)
+	self.setStateExchangeObject(dst)
+
+	var orderIds []common.Hash
+	it := trie.NewIterator(dst.getOrdersTrie(self.db).NodeIterator(nil))
+	for it.Next() {
+		orderIds = append(orderIds, common.BytesToHash(it.Key))
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	for _, orderId := range orderIds {
+		order := dst.getStateOrderObject(self.db, orderId)
+		if order == nil {
+			return fmt.Errorf("order %s of order book %s not found", orderId.Hex(), from.Hex())
+		}
+		rewrite(&order.data)
+		dst.MarkStateOrderObjectDirty(orderId)
+	}
+	self.deleteStateExchangeObject(from)
+	return self.Error()
+}
+
+// deleteStateExchangeObject removes an order book from the trie. The tries it
+// refers to are left to the other states sharing their nodes.
+func (self *TradingStateDB) deleteStateExchangeObject(orderBook common.Hash) {
+	delete(self.stateExhangeObjects, orderBook)
+	delete(self.stateExhangeObjectsDirty, orderBook)
+	self.setError(self.trie.TryDelete(orderBook[:]))
+}
+
+func isEmptyRoot(root common.Hash) bool {
+	return root == EmptyRoot || root == EmptyHash
+}
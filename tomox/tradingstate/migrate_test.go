@@ -0,0 +1,94 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestMigrateOrderBooks(t *testing.T) {
+	var (
+		oldBase  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		newBase  = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		quote    = common.HexToAddress("0x3000000000000000000000000000000000000003")
+		delisted = common.HexToAddress("0x4000000000000000000000000000000000000004")
+		oldBook  = GetTradingOrderBookHash(oldBase, quote)
+		newBook  = GetTradingOrderBookHash(newBase, quote)
+	)
+	statedb, _ := New(EmptyRoot, NewDatabase(rawdb.NewMemoryDatabase()))
+	for i := uint64(1); i <= 3; i++ {
+		side := Ask
+		if i == 3 {
+			side = Bid
+		}
+		statedb.InsertOrderItem(oldBook, common.BigToHash(new(big.Int).SetUint64(i)), OrderItem{OrderID: i, BaseToken: oldBase, QuoteToken: quote, Quantity: big.NewInt(10), Price: big.NewInt(int64(100 + i)), Side: side, Hash: common.BigToHash(big.NewInt(int64(i))), Signature: &Signature{V: 1}})
+	}
+	statedb.SetLastPrice(oldBook, big.NewInt(101))
+	statedb.SetNonce(GetTradingOrderBookHash(delisted, quote), 1)
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+
+	migrated, diffs, err := Migrate(statedb, RekeyOrderBook(oldBase, quote, newBase, quote), DropOrderBook(delisted, quote))
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if have := statedb.IntermediateRoot(); have != root {
+		t.Errorf("migrated source state: root %x, want %x", have, root)
+	}
+	if len(diffs) != 3 {
+		t.Fatalf("diff count mismatch: have %d, want 3: %+v", len(diffs), diffs)
+	}
+	for _, diff := range diffs {
+		switch diff.Hash {
+		case oldBook:
+			if diff.OrdersBefore != 3 || diff.OrdersAfter != 0 || diff.RootAfter != (common.Hash{}) {
+				t.Errorf("moved order book diff mismatch: %+v", diff)
+			}
+		case newBook:
+			if diff.OrdersBefore != 0 || diff.OrdersAfter != 3 || diff.RootBefore != (common.Hash{}) {
+				t.Errorf("new order book diff mismatch: %+v", diff)
+			}
+		default:
+			if diff.RootAfter != (common.Hash{}) {
+				t.Errorf("dropped order book diff mismatch: %+v", diff)
+			}
+		}
+	}
+	if price := migrated.GetLastPrice(newBook); price == nil || price.Int64() != 101 {
+		t.Errorf("last price of the moved order book mismatch: have %v, want 101", price)
+	}
+	if best, _ := migrated.GetBestAskPrice(newBook); best.Int64() != 101 {
+		t.Errorf("best ask of the moved order book mismatch: have %v, want 101", best)
+	}
+	order := migrated.GetOrder(newBook, common.BigToHash(big.NewInt(2)))
+	if order.BaseToken != newBase || order.Hash != common.BigToHash(big.NewInt(2)) {
+		t.Errorf("moved order mismatch: have %+v", order)
+	}
+	// The migrated state is committed like any other
+	migratedRoot, err := migrated.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit migrated state: %v", err)
+	}
+	reopened, err := New(migratedRoot, statedb.Database())
+	if err != nil {
+		t.Fatalf("failed to open migrated state: %v", err)
+	}
+	if ids, _ := reopened.GetRestingOrderIds(newBook); len(ids) != 3 {
+		t.Errorf("resting orders of the moved order book mismatch: have %d, want 3", len(ids))
+	}
+	if reopened.Exist(oldBook) || reopened.Exist(GetTradingOrderBookHash(delisted, quote)) {
+		t.Errorf("moved or dropped order books still exist")
+	}
+
+	// Order books with orders can't be dropped, nor overwritten
+	if _, _, err := Migrate(statedb, DropOrderBook(oldBase, quote)); err == nil {
+		t.Errorf("dropped an order book holding orders")
+	}
+	if _, _, err := Migrate(reopened, RekeyOrderBook(newBase, quote, newBase, quote)); err == nil {
+		t.Errorf("moved an order book onto an existing one")
+	}
+}
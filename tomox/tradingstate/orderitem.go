@@ -21,6 +21,21 @@ const (
 	OrderStatusFilled        = "FILLED"
 	OrderStatusCancelled     = "CANCELLED"
 	OrderStatusRejected      = "REJECTED"
+
+	// extra data of a post-only order rejected as it would cross the spread
+	RejectReasonPostOnly = `{"Reason":"POST_ONLY"}`
+	// extra data of an immediate-or-cancel order of which a part isn't matched
+	RejectReasonImmediateOrCancel = `{"Reason":"IMMEDIATE_OR_CANCEL"}`
+	// extra data of a fill-or-kill order rejected as it can't be filled
+	RejectReasonFillOrKill = `{"Reason":"FILL_OR_KILL"}`
+	// extra data of an order cancelled to prevent a self-trade
+	RejectReasonSelfTrade = `{"Reason":"SELF_TRADE"}`
+	// extra data of a replacement rejected as the order it replaces can't be cancelled
+	RejectReasonReplace = `{"Reason":"REPLACE"}`
+	// extra data of a good-till-date order cancelled as it expired
+	CancelReasonExpired = `{"Reason":"EXPIRED"}`
+	// extra data of an order cancelled as its pair was delisted
+	CancelReasonDelisted = `{"Reason":"DELISTED"}`
 )
 
 // OrderItem : info that will be store in database
@@ -43,6 +58,27 @@ type OrderItem struct {
 	UpdatedAt       time.Time      `json:"updatedAt,omitempty"`
 	OrderID         uint64         `json:"orderID,omitempty"`
 	ExtraData       string         `json:"extraData,omitempty"`
+
+	// Orders cancelled by a batch cancellation
+	Cancels []types.OrderCancel `json:"cancels,omitempty" rlp:"optional"`
+
+	// Price activating a stop order
+	TriggerPrice *big.Int `json:"triggerPrice,omitempty" rlp:"optional"`
+
+	// Quantity shown in the order book by an iceberg order
+	DisplayQuantity *big.Int `json:"displayQuantity,omitempty" rlp:"optional"`
+
+	// Time in force of a limit order: PostOnly, ImmediateOrCancel or FillOrKill
+	TimeInForce string `json:"timeInForce,omitempty" rlp:"optional"`
+
+	// Unix time after which a good-till-date order expires
+	ExpiresAt uint64 `json:"expiresAt,omitempty" rlp:"optional"`
+
+	// Self-trade prevention mode: CancelNewest, CancelOldest or CancelBoth
+	SelfTradePrevention string `json:"selfTradePrevention,omitempty" rlp:"optional"`
+
+	// Order id of the resting order replaced by a limit order
+	ReplacedOrderID uint64 `json:"replacedOrderID,omitempty" rlp:"optional"`
 }
 
 // Signature struct
@@ -207,7 +243,7 @@ func (o *OrderItem) VerifyOrder(state *state.StateDB) error {
 func (o *OrderItem) VerifyBasicOrderInfo() error {
 
 	if o.Status == OrderNew {
-		if o.Type == Limit {
+		if o.Type == Limit || o.Type == StopLimit {
 			if err := o.verifyPrice(); err != nil {
 				return err
 			}
@@ -225,6 +261,27 @@ func (o *OrderItem) VerifyBasicOrderInfo() error {
 	if err := o.verifyStatus(); err != nil {
 		return err
 	}
+	if err := o.verifyCancels(); err != nil {
+		return err
+	}
+	if err := o.verifyTriggerPrice(); err != nil {
+		return err
+	}
+	if err := o.verifyDisplayQuantity(); err != nil {
+		return err
+	}
+	if err := o.verifyTimeInForce(); err != nil {
+		return err
+	}
+	if err := o.verifyExpiry(); err != nil {
+		return err
+	}
+	if err := o.verifySelfTradePrevention(); err != nil {
+		return err
+	}
+	if err := o.verifyReplace(); err != nil {
+		return err
+	}
 	if err := o.verifySignature(); err != nil {
 		return err
 	}
@@ -241,10 +298,22 @@ func (o *OrderItem) verifyRelayer(state *state.StateDB) error {
 
 //verify signatures
 func (o *OrderItem) verifySignature() error {
+	tx, err := o.signedTransaction()
+	if err != nil {
+		return err
+	}
+	if orderSender(tx) != tx.UserAddress() {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signedTransaction returns the order transaction the user signed for the order.
+func (o *OrderItem) signedTransaction() (*types.OrderTransaction, error) {
 	bigstr := o.Nonce.String()
 	n, err := strconv.ParseInt(bigstr, 10, 64)
 	if err != nil {
-		return ErrInvalidSignature
+		return nil, ErrInvalidSignature
 	}
 	V := big.NewInt(int64(o.Signature.V))
 	R := o.Signature.R.Big()
@@ -252,12 +321,29 @@ func (o *OrderItem) verifySignature() error {
 
 	tx := types.NewOrderTransaction(uint64(n), o.Quantity, o.Price, o.ExchangeAddress, o.UserAddress,
 		o.BaseToken, o.QuoteToken, o.Status, o.Side, o.Type, o.Hash, o.OrderID)
-	tx.ImportSignature(V, R, S)
-	from, _ := types.OrderSender(types.OrderTxSigner{}, tx)
-	if from != tx.UserAddress() {
-		return ErrInvalidSignature
+	if len(o.Cancels) > 0 {
+		tx = types.NewBatchCancelOrderTransaction(uint64(n), o.ExchangeAddress, o.UserAddress, o.BaseToken, o.QuoteToken, o.Cancels)
+	} else if o.TriggerPrice != nil {
+		tx = types.NewStopOrderTransaction(uint64(n), o.Quantity, o.Price, o.TriggerPrice, o.ExchangeAddress, o.UserAddress,
+			o.BaseToken, o.QuoteToken, o.Side, o.Type, o.Hash)
+	} else if o.DisplayQuantity != nil {
+		tx = types.NewIcebergOrderTransaction(uint64(n), o.Quantity, o.DisplayQuantity, o.Price, o.ExchangeAddress, o.UserAddress,
+			o.BaseToken, o.QuoteToken, o.Side, o.Hash)
 	}
-	return nil
+	if o.TimeInForce != "" {
+		tx.SetTimeInForce(o.TimeInForce)
+	}
+	if o.ExpiresAt != 0 {
+		tx.SetExpiresAt(o.ExpiresAt)
+	}
+	if o.SelfTradePrevention != "" {
+		tx.SetSelfTradePrevention(o.SelfTradePrevention)
+	}
+	if o.ReplacedOrderID != 0 {
+		tx.SetReplacedOrderID(o.ReplacedOrderID)
+	}
+	tx.ImportSignature(V, R, S)
+	return tx, nil
 }
 
 // verify order type
@@ -313,6 +399,163 @@ func (o *OrderItem) verifyStatus() error {
 	return nil
 }
 
+// verifyCancels make sure only a cancellation lists the orders it cancels, each
+// of them once, and not more than the limit
+func (o *OrderItem) verifyCancels() error {
+	if len(o.Cancels) == 0 {
+		return nil
+	}
+	if o.Status != Cancel || o.OrderID != 0 || len(o.Cancels) > common.MaxOrderCancels {
+		log.Debug("Invalid batch cancellation", "status", o.Status, "orderId", o.OrderID, "cancels", len(o.Cancels))
+		return ErrInvalidCancels
+	}
+	seen := make(map[uint64]struct{}, len(o.Cancels))
+	for _, cancel := range o.Cancels {
+		if _, ok := seen[cancel.OrderID]; ok || cancel.OrderID == 0 {
+			log.Debug("Invalid order in batch cancellation", "orderId", cancel.OrderID)
+			return ErrInvalidCancels
+		}
+		seen[cancel.OrderID] = struct{}{}
+	}
+	return nil
+}
+
+// IsStopOrder reports whether the order waits for its trigger price before being
+// matched
+func (o *OrderItem) IsStopOrder() bool {
+	return o.Type == StopMarket || o.Type == StopLimit
+}
+
+// verifyTriggerPrice make sure only new stop orders have a trigger price, which
+// is a positive number
+func (o *OrderItem) verifyTriggerPrice() error {
+	if o.TriggerPrice == nil {
+		if o.Status == OrderNew && o.IsStopOrder() {
+			return ErrInvalidTrigger
+		}
+		return nil
+	}
+	if o.Status != OrderNew || !o.IsStopOrder() || o.TriggerPrice.Sign() <= 0 || common.BigToHash(o.TriggerPrice).Big().Cmp(o.TriggerPrice) != 0 {
+		log.Debug("Invalid trigger price", "type", o.Type, "status", o.Status, "triggerPrice", o.TriggerPrice)
+		return ErrInvalidTrigger
+	}
+	return nil
+}
+
+// IsIcebergOrder reports whether the order shows only a part of its quantity in
+// the order book
+func (o *OrderItem) IsIcebergOrder() bool {
+	return o.DisplayQuantity != nil
+}
+
+// VisibleQuantity returns the quantity of the order shown in the order book: the
+// display quantity of an iceberg order, or what is left of it if less, the whole
+// quantity otherwise
+func (o *OrderItem) VisibleQuantity() *big.Int {
+	if o.DisplayQuantity == nil || o.Quantity == nil || o.DisplayQuantity.Cmp(o.Quantity) >= 0 {
+		return o.Quantity
+	}
+	return o.DisplayQuantity
+}
+
+// verifyDisplayQuantity make sure only new limit orders have a display quantity,
+// which is a positive number less than their quantity
+func (o *OrderItem) verifyDisplayQuantity() error {
+	if o.DisplayQuantity == nil {
+		return nil
+	}
+	if o.Status != OrderNew || o.Type != Limit || o.DisplayQuantity.Sign() <= 0 || o.Quantity == nil || o.DisplayQuantity.Cmp(o.Quantity) >= 0 {
+		log.Debug("Invalid display quantity", "type", o.Type, "status", o.Status, "quantity", o.Quantity, "displayQuantity", o.DisplayQuantity)
+		return ErrInvalidDisplay
+	}
+	return nil
+}
+
+// IsPostOnly reports whether the order is rejected instead of matched if it would
+// cross the spread
+func (o *OrderItem) IsPostOnly() bool {
+	return o.TimeInForce == PostOnly
+}
+
+// IsImmediate reports whether nothing of the order is added to the order book,
+// the order being immediate-or-cancel or fill-or-kill
+func (o *OrderItem) IsImmediate() bool {
+	return o.TimeInForce == ImmediateOrCancel || o.TimeInForce == FillOrKill
+}
+
+// verifyTimeInForce make sure only new limit orders have a time in force, which
+// is post-only, immediate-or-cancel or fill-or-kill
+func (o *OrderItem) verifyTimeInForce() error {
+	if o.TimeInForce == "" {
+		return nil
+	}
+	if o.Status != OrderNew || o.Type != Limit || (!o.IsPostOnly() && !o.IsImmediate()) {
+		log.Debug("Invalid time in force", "type", o.Type, "status", o.Status, "timeInForce", o.TimeInForce)
+		return ErrInvalidTimeInForce
+	}
+	return nil
+}
+
+// IsExpired reports whether the order is good till a date which is before the
+// given unix time
+func (o *OrderItem) IsExpired(time uint64) bool {
+	return o.ExpiresAt != 0 && o.ExpiresAt <= time
+}
+
+// verifyExpiry make sure only new orders resting in the order book have an
+// expiry time
+func (o *OrderItem) verifyExpiry() error {
+	if o.ExpiresAt == 0 {
+		return nil
+	}
+	if o.Status != OrderNew || o.Type == Market || o.IsImmediate() {
+		log.Debug("Invalid expiry time", "type", o.Type, "status", o.Status, "timeInForce", o.TimeInForce, "expiresAt", o.ExpiresAt)
+		return ErrInvalidExpiry
+	}
+	return nil
+}
+
+// IsSelfTrade reports whether the order prevents trading with the given order,
+// both belonging to the same user or relayer
+func (o *OrderItem) IsSelfTrade(maker *OrderItem) bool {
+	if o.SelfTradePrevention == "" {
+		return false
+	}
+	return o.UserAddress == maker.UserAddress || o.ExchangeAddress == maker.ExchangeAddress
+}
+
+// verifySelfTradePrevention make sure only new orders have a self-trade
+// prevention mode, which is cancel newest, cancel oldest or cancel both
+func (o *OrderItem) verifySelfTradePrevention() error {
+	switch o.SelfTradePrevention {
+	case "":
+		return nil
+	case CancelNewest, CancelOldest, CancelBoth:
+		if o.Status == OrderNew {
+			return nil
+		}
+	}
+	log.Debug("Invalid self-trade prevention", "status", o.Status, "selfTradePrevention", o.SelfTradePrevention)
+	return ErrInvalidSelfTrade
+}
+
+// IsReplacement reports whether the order replaces a resting order
+func (o *OrderItem) IsReplacement() bool {
+	return o.ReplacedOrderID != 0
+}
+
+// verifyReplace make sure only new limit orders replace a resting order
+func (o *OrderItem) verifyReplace() error {
+	if !o.IsReplacement() {
+		return nil
+	}
+	if o.Status != OrderNew || o.Type != Limit {
+		log.Debug("Invalid replacement", "status", o.Status, "type", o.Type, "replacedOrderId", o.ReplacedOrderID)
+		return ErrInvalidReplace
+	}
+	return nil
+}
+
 func IsValidRelayer(statedb *state.StateDB, address common.Address) bool {
 	slot := RelayerMappingSlot["RELAYER_LIST"]
 	locRelayerState := GetLocMappingAtKey(address.Hash(), slot)
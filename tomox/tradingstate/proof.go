@@ -0,0 +1,152 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// ProveOrder writes into bookProof the Merkle proof of the order book in the
+// trading state trie with the given root, and into orderProof the proof of the
+// order in the order trie of the book, both listing the trie nodes from the
+// root down to the key. It returns the proven order, which is empty if the
+// order book holds no order with the given id.
+func ProveOrder(db Database, root common.Hash, orderBook common.Hash, orderId common.Hash, bookProof, orderProof ethdb.KeyValueWriter) (OrderItem, error) {
+	book, err := proveOrderBook(db, root, orderBook, bookProof)
+	if err != nil {
+		return OrderItem{}, err
+	}
+	orders, err := db.OpenStorageTrie(orderBook, book.OrderRoot)
+	if err != nil {
+		return OrderItem{}, err
+	}
+	if err := orders.Prove(orderId[:], 0, orderProof); err != nil {
+		return OrderItem{}, err
+	}
+	var order OrderItem
+	enc, err := orders.TryGet(orderId[:])
+	if err != nil || len(enc) == 0 {
+		return order, err
+	}
+	if err := rlp.DecodeBytes(enc, &order); err != nil {
+		return OrderItem{}, fmt.Errorf("invalid order %x: %v", orderId, err)
+	}
+	return order, nil
+}
+
+// PriceLevel is a price of a side of an order book, with the volume resting at
+// it and the root of the trie of its orders.
+type PriceLevel struct {
+	Price  *big.Int    `json:"price"`
+	Volume *big.Int    `json:"volume"`
+	Root   common.Hash `json:"root"`
+}
+
+// ProvePriceLevels writes into bookProof the Merkle proof of the order book in
+// the trading state trie with the given root, and into firstProof and lastProof
+// the edge proofs of the range of the best price levels of a side of the book,
+// up to depth of them. The range of the asks starts at the zero price and the
+// one of the bids ends at the highest bid, so that trie.VerifyRangeProof proves
+// that no better level is left out. It returns the levels from the best one,
+// none and no range proof if the side is empty.
+func ProvePriceLevels(db Database, root common.Hash, orderBook common.Hash, side string, depth int, bookProof, firstProof, lastProof ethdb.KeyValueWriter) ([]PriceLevel, error) {
+	book, err := proveOrderBook(db, root, orderBook, bookProof)
+	if err != nil {
+		return nil, err
+	}
+	var sideRoot common.Hash
+	switch side {
+	case Ask:
+		sideRoot = book.AskRoot
+	case Bid:
+		sideRoot = book.BidRoot
+	default:
+		return nil, fmt.Errorf("invalid order side %q", side)
+	}
+	tr, err := db.OpenStorageTrie(orderBook, sideRoot)
+	if err != nil {
+		return nil, err
+	}
+	var keys, values [][]byte
+	for it := trie.NewIterator(tr.NodeIterator(nil)); it.Next(); {
+		keys, values = append(keys, common.CopyBytes(it.Key)), append(values, common.CopyBytes(it.Value))
+		// the best asks are the lowest prices, the best bids the highest
+		if side == Ask && len(keys) == depth {
+			break
+		}
+	}
+	if len(keys) > depth {
+		keys, values = keys[len(keys)-depth:], values[len(values)-depth:]
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	first := keys[0]
+	if side == Ask {
+		first = common.Hash{}.Bytes()
+	}
+	if err := tr.Prove(first, 0, firstProof); err != nil {
+		return nil, err
+	}
+	if err := tr.Prove(keys[len(keys)-1], 0, lastProof); err != nil {
+		return nil, err
+	}
+	levels := make([]PriceLevel, len(keys))
+	for i := range keys {
+		var list orderList
+		if err := rlp.DecodeBytes(values[i], &list); err != nil {
+			return nil, fmt.Errorf("invalid price level %x: %v", keys[i], err)
+		}
+		level := PriceLevel{Price: new(big.Int).SetBytes(keys[i]), Volume: list.Volume, Root: list.Root}
+		if side == Ask {
+			levels[i] = level
+		} else {
+			levels[len(keys)-1-i] = level
+		}
+	}
+	return levels, nil
+}
+
+// proveOrderBook writes into bookProof the Merkle proof of the order book in
+// the trading state trie with the given root and returns the order book.
+func proveOrderBook(db Database, root common.Hash, orderBook common.Hash, bookProof ethdb.KeyValueWriter) (*tradingExchangeObject, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := tr.Prove(orderBook[:], 0, bookProof); err != nil {
+		return nil, err
+	}
+	enc, err := tr.TryGet(orderBook[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(enc) == 0 {
+		return nil, fmt.Errorf("order book %x not found", orderBook)
+	}
+	var book tradingExchangeObject
+	if err := rlp.DecodeBytes(enc, &book); err != nil {
+		return nil, fmt.Errorf("invalid order book %x: %v", orderBook, err)
+	}
+	return &book, nil
+}
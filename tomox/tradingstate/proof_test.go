@@ -0,0 +1,159 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// Tests that the proofs of an order verify against the trading state root and
+// the order root of the book, and that missing orders are proven absent.
+func TestProveOrder(t *testing.T) {
+	var (
+		stateCache = NewDatabase(rawdb.NewMemoryDatabase())
+		orderBook  = common.StringToHash("BTC/TOMO")
+	)
+	statedb, _ := New(common.Hash{}, stateCache)
+	for i := 1; i <= 8; i++ {
+		order := OrderItem{OrderID: uint64(i), Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(i)), Side: Ask, Signature: &Signature{V: 1}}
+		statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(int64(i))), order)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	bookProof, orderProof := memorydb.New(), memorydb.New()
+	order, err := ProveOrder(stateCache, root, orderBook, common.BigToHash(big.NewInt(3)), bookProof, orderProof)
+	if err != nil {
+		t.Fatalf("failed to prove order: %v", err)
+	}
+	if order.OrderID != 3 {
+		t.Fatalf("proven order mismatch: have %d, want 3", order.OrderID)
+	}
+	enc, err := trie.VerifyProof(root, orderBook[:], bookProof)
+	if err != nil {
+		t.Fatalf("failed to verify order book proof: %v", err)
+	}
+	var book tradingExchangeObject
+	if err := rlp.DecodeBytes(enc, &book); err != nil {
+		t.Fatalf("failed to decode proven order book: %v", err)
+	}
+	orderId := common.BigToHash(big.NewInt(3))
+	enc, err = trie.VerifyProof(book.OrderRoot, orderId[:], orderProof)
+	if err != nil {
+		t.Fatalf("failed to verify order proof: %v", err)
+	}
+	want, _ := rlp.EncodeToBytes(&order)
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("proven order encoding mismatch: have %x, want %x", enc, want)
+	}
+	// A missing order is proven absent, a missing book fails
+	missing := common.BigToHash(big.NewInt(100))
+	bookProof, orderProof = memorydb.New(), memorydb.New()
+	if order, err := ProveOrder(stateCache, root, orderBook, missing, bookProof, orderProof); err != nil || order.OrderID != 0 {
+		t.Fatalf("missing order mismatch: have %d, %v", order.OrderID, err)
+	}
+	if enc, err := trie.VerifyProof(book.OrderRoot, missing[:], orderProof); err != nil || enc != nil {
+		t.Fatalf("absence proof mismatch: have %x, %v", enc, err)
+	}
+	if _, err := ProveOrder(stateCache, root, common.StringToHash("ETH/TOMO"), orderId, memorydb.New(), memorydb.New()); err == nil {
+		t.Fatalf("proved an order of a missing book")
+	}
+}
+
+// Tests that the best price levels of both sides of a book are proven to be the
+// best ones by a range proof against the roots of the sides.
+func TestProvePriceLevels(t *testing.T) {
+	var (
+		stateCache = NewDatabase(rawdb.NewMemoryDatabase())
+		orderBook  = common.StringToHash("BTC/TOMO")
+	)
+	statedb, _ := New(common.Hash{}, stateCache)
+	for i := 1; i <= 10; i++ {
+		side, price := Ask, int64(10+i)
+		if i > 5 {
+			side, price = Bid, int64(i)
+		}
+		order := OrderItem{OrderID: uint64(i), Quantity: big.NewInt(int64(i)), Price: big.NewInt(price), Side: side, Signature: &Signature{V: 1}}
+		statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(int64(i))), order)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	for _, test := range []struct {
+		side   string
+		depth  int
+		prices []int64
+	}{
+		{Ask, 3, []int64{11, 12, 13}},
+		{Ask, 10, []int64{11, 12, 13, 14, 15}},
+		{Bid, 2, []int64{10, 9}},
+		{Bid, 1, []int64{10}},
+	} {
+		bookProof, firstProof, lastProof := memorydb.New(), memorydb.New(), memorydb.New()
+		levels, err := ProvePriceLevels(stateCache, root, orderBook, test.side, test.depth, bookProof, firstProof, lastProof)
+		if err != nil {
+			t.Fatalf("%s %d: failed to prove price levels: %v", test.side, test.depth, err)
+		}
+		if len(levels) != len(test.prices) {
+			t.Fatalf("%s %d: level count mismatch: have %d, want %d", test.side, test.depth, len(levels), len(test.prices))
+		}
+		enc, err := trie.VerifyProof(root, orderBook[:], bookProof)
+		if err != nil {
+			t.Fatalf("failed to verify order book proof: %v", err)
+		}
+		var book tradingExchangeObject
+		if err := rlp.DecodeBytes(enc, &book); err != nil {
+			t.Fatalf("failed to decode proven order book: %v", err)
+		}
+		// rebuild the range in key order from the levels
+		var keys, values [][]byte
+		for i := range levels {
+			level := levels[i]
+			if test.side == Bid {
+				level = levels[len(levels)-1-i]
+			}
+			enc, _ := rlp.EncodeToBytes(&orderList{Volume: level.Volume, Root: level.Root})
+			keys, values = append(keys, common.BigToHash(level.Price).Bytes()), append(values, enc)
+		}
+		sideRoot, first := book.AskRoot, common.Hash{}.Bytes()
+		if test.side == Bid {
+			sideRoot, first = book.BidRoot, keys[0]
+		}
+		err, more := trie.VerifyRangeProof(sideRoot, first, keys, values, firstProof, lastProof)
+		if err != nil {
+			t.Fatalf("%s %d: failed to verify range proof: %v", test.side, test.depth, err)
+		}
+		if test.side == Bid && more {
+			t.Errorf("%s %d: bids above the best proven", test.side, test.depth)
+		}
+		for i, price := range test.prices {
+			if levels[i].Price.Int64() != price {
+				t.Errorf("%s %d: level %d price mismatch: have %v, want %d", test.side, test.depth, i, levels[i].Price, price)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/metrics"
+)
+
+var (
+	prunedOrdersMeter      = metrics.NewRegisteredMeter("tomox/trading/pruned/orders", nil)
+	prunedPriceLevelsMeter = metrics.NewRegisteredMeter("tomox/trading/pruned/levels", nil)
+)
+
+// pruneTerminal runs once the modifications of the book were written into its
+// tries. It drops the filled and cancelled orders and the emptied price levels,
+// whose entries were deleted from the tries, from the live objects of the book,
+// and rearms the dirty callbacks of the objects left. A callback only fires on
+// the first modification of an object, so without it an object modified again
+// after the tries were updated, like an order filled across two transactions,
+// would keep its former entry in the tries until it's loaded again.
+// It only runs once enabled by SetPruning, from the TIPTomoXPrune fork on.
+func (self *tradingExchanges) pruneTerminal() (orders int, levels int) {
+	for orderId, obj := range self.stateOrderObjects {
+		if _, isDirty := self.stateOrderObjectsDirty[orderId]; isDirty {
+			continue
+		}
+		if obj.empty() {
+			delete(self.stateOrderObjects, orderId)
+			orders++
+			continue
+		}
+		obj.onDirty = self.MarkStateOrderObjectDirty
+	}
+	stopAsks, stopAsksDirty, markStopAsk := self.stopOrderLists(Ask)
+	stopBids, stopBidsDirty, markStopBid := self.stopOrderLists(Bid)
+	for _, lists := range []struct {
+		objects map[common.Hash]*stateOrderList
+		dirties map[common.Hash]struct{}
+		onDirty func(price common.Hash)
+	}{
+		{self.stateAskObjects, self.stateAskObjectsDirty, self.MarkStateAskObjectDirty},
+		{self.stateBidObjects, self.stateBidObjectsDirty, self.MarkStateBidObjectDirty},
+		{stopAsks, stopAsksDirty, markStopAsk},
+		{stopBids, stopBidsDirty, markStopBid},
+		{self.stateExpiryObjects, self.stateExpiryObjectsDirty, self.MarkStateExpiryObjectDirty},
+	} {
+		for price, obj := range lists.objects {
+			if _, isDirty := lists.dirties[price]; isDirty {
+				continue
+			}
+			if obj.empty() {
+				delete(lists.objects, price)
+				levels++
+				continue
+			}
+			obj.onDirty = lists.onDirty
+		}
+	}
+	prunedOrdersMeter.Mark(int64(orders))
+	prunedPriceLevelsMeter.Mark(int64(levels))
+	return orders, levels
+}
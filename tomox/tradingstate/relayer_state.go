@@ -27,6 +27,14 @@ func GetExRelayerFee(relayer common.Address, statedb *state.StateDB) *big.Int {
 	return statedb.GetState(common.HexToAddress(common.RelayerRegistrationSMC), locHash).Big()
 }
 
+// GetExRelayerMakerRebate returns the rate, in TomoXBaseFee units, of the rebate
+// the relayer pays its makers out of the taker fee, as set by its owner with
+// updateMakerRebate of the relayer registration contract. Zero if it pays none.
+func GetExRelayerMakerRebate(relayer common.Address, statedb *state.StateDB) *big.Int {
+	locBig := GetLocMappingAtKey(relayer.Hash(), RelayerMappingSlot["RELAYER_MAKER_REBATES"])
+	return statedb.GetState(common.HexToAddress(common.RelayerRegistrationSMC), common.BigToHash(locBig)).Big()
+}
+
 func GetRelayerOwner(relayer common.Address, statedb *state.StateDB) common.Address {
 	slot := RelayerMappingSlot["RELAYER_LIST"]
 	locBig := GetLocMappingAtKey(relayer.Hash(), slot)
@@ -350,3 +358,90 @@ func SetSubRelayerFee(relayer common.Address, balance *big.Int, fee *big.Int, st
 	statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), locHashDeposit, common.BigToHash(balance))
 	statedb.SubBalance(common.HexToAddress(common.RelayerRegistrationSMC), fee)
 }
+
+// RegisterRelayer writes a relayer into the storage of the relayer registration
+// contract, laid out as the contract's register method does, and locks its
+// deposit into the contract balance. It's used to set up relayers in genesis.
+func RegisterRelayer(statedb *state.StateDB, coinbase, owner common.Address, deposit, fee *big.Int, fromTokens, toTokens []common.Address) {
+	contract := common.HexToAddress(common.RelayerRegistrationSMC)
+	index := GetRelayerCount(statedb)
+
+	locBig := GetLocMappingAtKey(coinbase.Hash(), RelayerMappingSlot["RELAYER_LIST"])
+	statedb.SetState(contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_deposit"]), common.BigToHash(deposit))
+	statedb.SetState(contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_fee"]), common.BigToHash(fee))
+	statedb.SetState(contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_index"]), common.BigToHash(new(big.Int).SetUint64(index)))
+	statedb.SetState(contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_owner"]), owner.Hash())
+	setAddressArray(statedb, contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_fromTokens"]), fromTokens)
+	setAddressArray(statedb, contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_toTokens"]), toTokens)
+	locCoinbase := state.GetLocMappingAtKey(common.BigToHash(new(big.Int).SetUint64(index)), RelayerMappingSlot["RELAYER_COINBASES"])
+	statedb.SetState(contract, common.BigToHash(locCoinbase), coinbase.Hash())
+	statedb.SetState(contract, state.GetLocSimpleVariable(RelayerMappingSlot["RelayerCount"]), common.BigToHash(new(big.Int).SetUint64(index+1)))
+	statedb.AddBalance(contract, deposit)
+}
+
+// SetRelayerMakerRebate writes the maker rebate rate of a relayer into the
+// storage of the relayer registration contract.
+func SetRelayerMakerRebate(statedb *state.StateDB, coinbase common.Address, rebate *big.Int) {
+	locBig := GetLocMappingAtKey(coinbase.Hash(), RelayerMappingSlot["RELAYER_MAKER_REBATES"])
+	statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), common.BigToHash(locBig), common.BigToHash(rebate))
+}
+
+// setAddressArray writes a dynamic address array into contract storage at the given slot.
+func setAddressArray(statedb *state.StateDB, contract common.Address, locHash common.Hash, addrs []common.Address) {
+	statedb.SetState(contract, locHash, common.BigToHash(new(big.Int).SetUint64(uint64(len(addrs)))))
+	for i, addr := range addrs {
+		statedb.SetState(contract, state.GetLocDynamicArrAtElement(locHash, uint64(i), 1), addr.Hash())
+	}
+}
+
+// RelayerPair is a trading pair listed by a relayer.
+type RelayerPair struct {
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+	OrderBook  common.Hash    `json:"orderBook"`
+}
+
+// RelayerInfo is the registration of a relayer, as kept by the relayer
+// registration contract.
+type RelayerInfo struct {
+	Coinbase    common.Address `json:"coinbase"`
+	Owner       common.Address `json:"owner"`
+	Index       uint64         `json:"index"`
+	Deposit     *big.Int       `json:"deposit"`
+	Fee         *big.Int       `json:"fee"`
+	MakerRebate *big.Int       `json:"makerRebate"` // rate of the rebate paid to makers out of the taker fee
+	Pairs       []RelayerPair  `json:"pairs"`
+	ResignTime  *big.Int       `json:"resignTime"` // time the deposit is released at, zero unless the relayer resigned
+	SalePrice   *big.Int       `json:"salePrice"`  // price the relayer is on sale for, zero unless it is
+}
+
+// GetRelayerInfo reads the registration of the given relayer from the relayer
+// registration contract, nil if the address isn't the coinbase of a relayer.
+func GetRelayerInfo(relayer common.Address, statedb *state.StateDB) (*RelayerInfo, error) {
+	contract := common.HexToAddress(common.RelayerRegistrationSMC)
+	owner := GetRelayerOwner(relayer, statedb)
+	if owner == (common.Address{}) {
+		return nil, nil
+	}
+	locBig := GetLocMappingAtKey(relayer.Hash(), RelayerMappingSlot["RELAYER_LIST"])
+	info := &RelayerInfo{
+		Coinbase:    relayer,
+		Owner:       owner,
+		Index:       statedb.GetState(contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_index"])).Big().Uint64(),
+		Deposit:     statedb.GetState(contract, state.GetLocOfStructElement(locBig, RelayerStructMappingSlot["_deposit"])).Big(),
+		Fee:         GetExRelayerFee(relayer, statedb),
+		MakerRebate: GetExRelayerMakerRebate(relayer, statedb),
+		Pairs:       []RelayerPair{},
+		ResignTime:  statedb.GetState(contract, common.BigToHash(GetLocMappingAtKey(relayer.Hash(), RelayerMappingSlot["RESIGN_REQUESTS"]))).Big(),
+		SalePrice:   statedb.GetState(contract, common.BigToHash(GetLocMappingAtKey(relayer.Hash(), RelayerMappingSlot["RELAYER_ON_SALE_LIST"]))).Big(),
+	}
+	length := GetBaseTokenLength(relayer, statedb)
+	if quoteLength := GetQuoteTokenLength(relayer, statedb); quoteLength != length {
+		return nil, fmt.Errorf("Invalid length from token & to toke : from :%d , to :%d ", length, quoteLength)
+	}
+	for i := uint64(0); i < length; i++ {
+		baseToken, quoteToken := GetBaseTokenAtIndex(relayer, statedb, i), GetQuoteTokenAtIndex(relayer, statedb, i)
+		info.Pairs = append(info.Pairs, RelayerPair{BaseToken: baseToken, QuoteToken: quoteToken, OrderBook: GetTradingOrderBookHash(baseToken, quoteToken)})
+	}
+	return info, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+func TestGetRelayerInfo(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		coinbase = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+		owner    = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+		tomo     = common.HexToAddress(common.TomoNativeAddress)
+		btc      = common.HexToAddress("0x0000000000000000000000000000000000000b7c")
+		eth      = common.HexToAddress("0x0000000000000000000000000000000000000e7f")
+		deposit  = new(big.Int).Mul(big.NewInt(25000), common.BasePrice)
+	)
+	if info, err := GetRelayerInfo(coinbase, statedb); info != nil || err != nil {
+		t.Fatalf("info of an unregistered relayer: have %+v, %v", info, err)
+	}
+	RegisterRelayer(statedb, common.HexToAddress("0x00000000000000000000000000000000000000c0"), owner, deposit, big.NewInt(5), []common.Address{btc}, []common.Address{tomo})
+	RegisterRelayer(statedb, coinbase, owner, deposit, big.NewInt(10), []common.Address{btc, eth}, []common.Address{tomo, tomo})
+
+	info, err := GetRelayerInfo(coinbase, statedb)
+	if err != nil || info == nil {
+		t.Fatalf("failed to get relayer info: %v", err)
+	}
+	if info.Owner != owner || info.Index != 1 || info.Deposit.Cmp(deposit) != 0 || info.Fee.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("relayer info mismatch: have %+v", info)
+	}
+	if info.ResignTime.Sign() != 0 || info.SalePrice.Sign() != 0 {
+		t.Errorf("relayer neither resigned nor on sale: have %v, %v", info.ResignTime, info.SalePrice)
+	}
+	if len(info.Pairs) != 2 || info.Pairs[1].BaseToken != eth || info.Pairs[1].OrderBook != GetTradingOrderBookHash(eth, tomo) {
+		t.Errorf("relayer pairs mismatch: have %+v", info.Pairs)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+)
+
+const orderSenderCacheLimit = 4096
+
+// orderSenders caches the users recovered from the signatures of the orders, by
+// hash of their signed transactions.
+var orderSenders, _ = lru.New(orderSenderCacheLimit)
+
+// orderSender returns the user who signed an order transaction, or the zero
+// address if the signature is invalid.
+func orderSender(tx *types.OrderTransaction) common.Address {
+	hash := tx.Hash()
+	if from, ok := orderSenders.Get(hash); ok {
+		return from.(common.Address)
+	}
+	from, err := types.OrderSender(types.OrderTxSigner{}, tx)
+	if err != nil {
+		return common.Address{}
+	}
+	orderSenders.Add(hash, from)
+	return from
+}
+
+// RecoverOrderSenders recovers concurrently the users who signed the orders, so
+// that the orders matched one after the other find them cached. A signature
+// depends on its order only, so the matching doesn't depend on the scheduling.
+func RecoverOrderSenders(orders []*OrderItem) {
+	ParallelFor(len(orders), func(i int) {
+		if orders[i].Signature == nil || orders[i].Nonce == nil {
+			return
+		}
+		if tx, err := orders[i].signedTransaction(); err == nil {
+			orderSender(tx)
+		}
+	})
+}
+
+// ParallelFor calls fn for each index below n over as many goroutines as CPUs,
+// returning once all calls returned.
+func ParallelFor(n int, fn func(i int)) {
+	workers := runtime.NumCPU()
+	if workers > n {
+		workers = n
+	}
+	var (
+		wg   sync.WaitGroup
+		next = int64(-1)
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := atomic.AddInt64(&next, 1); i < int64(n); i = atomic.AddInt64(&next, 1) {
+				fn(int(i))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,70 @@
+package tradingstate
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+func TestRecoverOrderSenders(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	orders := make([]*OrderItem, 16)
+	for i := range orders {
+		order := &OrderItem{
+			Nonce:       big.NewInt(int64(i)),
+			Quantity:    big.NewInt(1000),
+			Price:       big.NewInt(int64(100 + i)),
+			UserAddress: user,
+			Status:      OrderNew,
+			Side:        Bid,
+			Type:        Limit,
+			Signature:   &Signature{},
+		}
+		tx, err := order.signedTransaction()
+		if err != nil {
+			t.Fatalf("failed to build order transaction: %v", err)
+		}
+		signed, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign order: %v", err)
+		}
+		V, R, S := signed.Signature()
+		order.Signature = &Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)}
+		orders[i] = order
+	}
+	// an order without signature is left to the matching to reject
+	orders = append(orders, &OrderItem{Nonce: big.NewInt(1)})
+	RecoverOrderSenders(orders)
+
+	for i, order := range orders[:len(orders)-1] {
+		tx, _ := order.signedTransaction()
+		if from, ok := orderSenders.Get(tx.Hash()); !ok || from.(common.Address) != user {
+			t.Errorf("order %d: sender mismatch: have %v, want %x", i, from, user)
+		}
+		if err := order.verifySignature(); err != nil {
+			t.Errorf("order %d: failed to verify signature: %v", i, err)
+		}
+	}
+	// a tampered order doesn't match the cached sender of the original
+	orders[0].Price = big.NewInt(1)
+	if err := orders[0].verifySignature(); err == nil {
+		t.Errorf("tampered order verified")
+	}
+}
+
+func TestParallelFor(t *testing.T) {
+	for _, n := range []int{0, 1, 7, 1000} {
+		calls := make([]int32, n)
+		ParallelFor(n, func(i int) { atomic.AddInt32(&calls[i], 1) })
+		for i, c := range calls {
+			if c != 1 {
+				t.Fatalf("n=%d: index %d called %d times", n, i, c)
+			}
+		}
+	}
+}
@@ -17,12 +17,43 @@ type TradeResult struct {
 	InTotal  *big.Int
 	OutToken common.Address
 	OutTotal *big.Int
+	Rebate   *big.Int `json:",omitempty"` // maker rebate paid out of the taker fee, nil if none
 }
 type SettleBalance struct {
 	Taker TradeResult
 	Maker TradeResult
 }
 
+// ApplyMakerRebate pays the maker a rebate of rebateRate / TomoXBaseFee of the
+// traded quote token quantity, instead of charging it a maker fee. The rebate is
+// funded from the taker fee, so it is capped at it: the taker still pays its full
+// fee, and the taker relayer receives the fee less the rebate. It's only applied
+// when the taker and the maker use the same relayer, which pays the rebate.
+func (settleBalance *SettleBalance) ApplyMakerRebate(takerSide string, makerPrice *big.Int, baseTokenDecimal *big.Int, rebateRate *big.Int, quantityToTrade *big.Int) {
+	if rebateRate == nil || rebateRate.Sign() <= 0 {
+		return
+	}
+	quoteTokenQuantity := new(big.Int).Mul(quantityToTrade, makerPrice)
+	quoteTokenQuantity = new(big.Int).Div(quoteTokenQuantity, baseTokenDecimal)
+	rebate := new(big.Int).Mul(quoteTokenQuantity, rebateRate)
+	rebate = new(big.Int).Div(rebate, common.TomoXBaseFee)
+	if rebate.Cmp(settleBalance.Taker.Fee) > 0 {
+		rebate = new(big.Int).Set(settleBalance.Taker.Fee)
+	}
+	if rebate.Sign() <= 0 {
+		return
+	}
+	settleBalance.Maker.Fee = new(big.Int)
+	settleBalance.Maker.Rebate = rebate
+	if takerSide == Bid {
+		// the maker sells and receives the quote token quantity plus the rebate
+		settleBalance.Maker.InTotal = new(big.Int).Add(quoteTokenQuantity, rebate)
+	} else {
+		// the maker buys and pays the quote token quantity less the rebate
+		settleBalance.Maker.OutTotal = new(big.Int).Sub(quoteTokenQuantity, rebate)
+	}
+}
+
 func (settleBalance *SettleBalance) String() string {
 	jsonData, _ := json.Marshal(settleBalance)
 	return string(jsonData)
@@ -261,3 +261,45 @@ func TestGetSettleBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyMakerRebate(t *testing.T) {
+	testToken := common.HexToAddress("0x0000000000000000000000000000000000000022")
+	quoteToken := common.HexToAddress(common.TomoNativeAddress)
+	tradeQuantity := new(big.Int).Mul(big.NewInt(1000), common.BasePrice)
+	takerFee := common.BasePrice // 0.1% of 1000 TOMO
+	tests := []struct {
+		name        string
+		takerSide   string
+		rebateRate  *big.Int
+		wantRebate  *big.Int
+		wantInTotal *big.Int // maker InTotal
+		wantOut     *big.Int // maker OutTotal
+	}{
+		{"BUY, no rebate", Bid, big.NewInt(0), nil, tradeQuantity, tradeQuantity},
+		{"BUY, rebate 0.05%", Bid, big.NewInt(5), new(big.Int).Div(common.BasePrice, big.NewInt(2)), new(big.Int).Add(tradeQuantity, new(big.Int).Div(common.BasePrice, big.NewInt(2))), tradeQuantity},
+		{"BUY, rebate capped at the taker fee", Bid, big.NewInt(20), takerFee, new(big.Int).Add(tradeQuantity, takerFee), tradeQuantity},
+		{"SELL, rebate 0.05%", Ask, big.NewInt(5), new(big.Int).Div(common.BasePrice, big.NewInt(2)), tradeQuantity, new(big.Int).Sub(tradeQuantity, new(big.Int).Div(common.BasePrice, big.NewInt(2)))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// makers of a relayer paying a rebate are settled without maker fee
+			settleBalance, err := GetSettleBalance(common.BasePrice, tt.takerSide, big.NewInt(10), testToken, quoteToken, common.BasePrice, big.NewInt(0), common.BasePrice, common.BasePrice, tradeQuantity)
+			if err != nil {
+				t.Fatalf("GetSettleBalance() error = %v", err)
+			}
+			settleBalance.ApplyMakerRebate(tt.takerSide, common.BasePrice, common.BasePrice, tt.rebateRate, tradeQuantity)
+			if !reflect.DeepEqual(settleBalance.Maker.Rebate, tt.wantRebate) {
+				t.Errorf("rebate mismatch: have %v, want %v", settleBalance.Maker.Rebate, tt.wantRebate)
+			}
+			if settleBalance.Maker.Fee.Sign() != 0 {
+				t.Errorf("maker fee mismatch: have %v, want 0", settleBalance.Maker.Fee)
+			}
+			if settleBalance.Taker.Fee.Cmp(takerFee) != 0 {
+				t.Errorf("taker fee mismatch: have %v, want %v", settleBalance.Taker.Fee, takerFee)
+			}
+			if settleBalance.Maker.InTotal.Cmp(tt.wantInTotal) != 0 || settleBalance.Maker.OutTotal.Cmp(tt.wantOut) != 0 {
+				t.Errorf("maker totals mismatch: have in %v out %v, want in %v out %v", settleBalance.Maker.InTotal, settleBalance.Maker.OutTotal, tt.wantInTotal, tt.wantOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// OrderBookSnapshot is the content of an order book, as exported for relayers
+// to rebuild their off-chain database.
+type OrderBookSnapshot struct {
+	Hash       common.Hash        `json:"hash"`
+	Info       *DumpOrderBookInfo `json:"info"`
+	Orders     []OrderItem        `json:"orders"`     // resting orders, by order id
+	StopOrders []OrderItem        `json:"stopOrders"` // stop orders waiting for their trigger price, by order id
+}
+
+// GetOrderBookHashes returns the hashes of every order book of the state, in
+// ascending order.
+func (self *TradingStateDB) GetOrderBookHashes() []common.Hash {
+	seen := map[common.Hash]struct{}{}
+	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	for it.Next() {
+		if orderBook := common.BytesToHash(it.Key); !common.EmptyHash(orderBook) {
+			seen[orderBook] = struct{}{}
+		}
+	}
+	for orderBook := range self.stateExhangeObjects {
+		seen[orderBook] = struct{}{}
+	}
+	orderBooks := make([]common.Hash, 0, len(seen))
+	for orderBook := range seen {
+		orderBooks = append(orderBooks, orderBook)
+	}
+	sort.Slice(orderBooks, func(i, j int) bool {
+		return bytes.Compare(orderBooks[i][:], orderBooks[j][:]) < 0
+	})
+	return orderBooks
+}
+
+// SnapshotOrderBook returns the content of the given order book.
+func (self *TradingStateDB) SnapshotOrderBook(orderBook common.Hash) (*OrderBookSnapshot, error) {
+	info, err := self.DumpOrderBookInfo(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &OrderBookSnapshot{Hash: orderBook, Info: info, Orders: []OrderItem{}, StopOrders: []OrderItem{}}
+	orderIds, err := self.GetRestingOrderIds(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	for _, orderId := range orderIds {
+		order := self.GetOrder(orderBook, orderId)
+		if IsEmptyOrder(order) {
+			return nil, fmt.Errorf("order %v of order book %v not found", orderId.Big(), orderBook.Hex())
+		}
+		snapshot.Orders = append(snapshot.Orders, order)
+	}
+	stopIds, err := self.GetStopOrderIds(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	for _, orderId := range stopIds {
+		order := self.GetOrder(orderBook, orderId)
+		if IsEmptyOrder(order) {
+			return nil, fmt.Errorf("stop order %v of order book %v not found", orderId.Big(), orderBook.Hex())
+		}
+		snapshot.StopOrders = append(snapshot.StopOrders, order)
+	}
+	return snapshot, nil
+}
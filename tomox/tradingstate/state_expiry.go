@@ -0,0 +1,178 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// The good-till-date orders of an order book are indexed by expiry time, like
+// the lending trades by liquidation time: expiry time => order list => order id.
+// The volume of a list is its number of orders, the orders themselves are kept
+// in the order trie of the book.
+
+// getExpiryTrie returns the trie of the good-till-date orders of the book.
+func (self *tradingExchanges) getExpiryTrie(db Database) Trie {
+	if self.expiryTrie == nil {
+		var err error
+		self.expiryTrie, err = db.OpenStorageTrie(self.orderBookHash, self.data.ExpiryRoot)
+		if err != nil {
+			self.expiryTrie, _ = db.OpenStorageTrie(self.orderBookHash, EmptyHash)
+			self.setError(fmt.Errorf("can't create expiry trie: %v", err))
+		}
+	}
+	return self.expiryTrie
+}
+
+// MarkStateExpiryObjectDirty adds the specified expiry order list to the dirty
+// map.
+func (self *tradingExchanges) MarkStateExpiryObjectDirty(time common.Hash) {
+	self.stateExpiryObjectsDirty[time] = struct{}{}
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+// getStateExpiryOrderList retrieves the orders expiring at the given time.
+// Returns nil if not found, or if all of them were removed.
+func (self *tradingExchanges) getStateExpiryOrderList(db Database, time common.Hash) *stateOrderList {
+	// Prefer 'live' objects. An emptied list is already deleted from the trie.
+	if obj := self.stateExpiryObjects[time]; obj != nil {
+		if obj.empty() {
+			return nil
+		}
+		return obj
+	}
+	// Load the object from the database.
+	enc, err := self.getExpiryTrie(db).TryGet(time[:])
+	if len(enc) == 0 {
+		self.setError(err)
+		return nil
+	}
+	var data orderList
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		log.Error("Failed to decode expiry order list", "time", time, "err", err)
+		return nil
+	}
+	// Insert into the live set.
+	obj := newStateOrderList(self.db, "", self.orderBookHash, time, data, self.MarkStateExpiryObjectDirty)
+	self.stateExpiryObjects[time] = obj
+	return obj
+}
+
+// createStateExpiryOrderList creates the orders expiring at the given time.
+func (self *tradingExchanges) createStateExpiryOrderList(db Database, time common.Hash) *stateOrderList {
+	newobj := newStateOrderList(self.db, "", self.orderBookHash, time, orderList{Volume: Zero}, self.MarkStateExpiryObjectDirty)
+	self.stateExpiryObjects[time] = newobj
+	self.stateExpiryObjectsDirty[time] = struct{}{}
+	data, err := rlp.EncodeToBytes(newobj)
+	if err != nil {
+		panic(fmt.Errorf("can't encode expiry order list object at %x: %v", time[:], err))
+	}
+	self.setError(self.getExpiryTrie(db).TryUpdate(time[:], data))
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+	return newobj
+}
+
+func (self *tradingExchanges) removeStateExpiryOrderList(db Database, stateOrderList *stateOrderList) {
+	self.setError(self.getExpiryTrie(db).TryDelete(stateOrderList.price[:]))
+}
+
+// getLowestExpiryTime returns the earliest expiry time of the good-till-date
+// orders of the book, along with the orders expiring then.
+func (self *tradingExchanges) getLowestExpiryTime(db Database) (common.Hash, *stateOrderList) {
+	encKey, encValue, err := self.getExpiryTrie(db).TryGetBestLeftKeyAndValue()
+	if err != nil {
+		log.Error("Failed find lowest expiry time", "orderbook", self.orderBookHash.Hex(), "err", err)
+		return EmptyHash, nil
+	}
+	if len(encKey) == 0 || len(encValue) == 0 {
+		return EmptyHash, nil
+	}
+	time := common.BytesToHash(encKey)
+	obj := self.stateExpiryObjects[time]
+	if obj == nil {
+		var data orderList
+		if err := rlp.DecodeBytes(encValue, &data); err != nil {
+			log.Error("Failed to decode lowest expiry order list", "err", err)
+			return EmptyHash, nil
+		}
+		obj = newStateOrderList(self.db, "", self.orderBookHash, time, data, self.MarkStateExpiryObjectDirty)
+		self.stateExpiryObjects[time] = obj
+	}
+	return time, obj
+}
+
+// updateExpiryTrie writes cached expiry order list modifications into the trie.
+func (self *tradingExchanges) updateExpiryTrie(db Database) Trie {
+	tr := self.getExpiryTrie(db)
+	for time, orderList := range self.stateExpiryObjects {
+		if _, isDirty := self.stateExpiryObjectsDirty[time]; isDirty {
+			delete(self.stateExpiryObjectsDirty, time)
+			if orderList.empty() {
+				self.setError(tr.TryDelete(time[:]))
+				continue
+			}
+			orderList.updateRoot(db)
+			// Encoding []byte cannot fail, ok to ignore the error.
+			v, _ := rlp.EncodeToBytes(orderList)
+			self.setError(tr.TryUpdate(time[:], v))
+		}
+	}
+	return tr
+}
+
+// updateExpiryRoot updates the root of the expiry trie if it was opened.
+func (self *tradingExchanges) updateExpiryRoot(db Database) {
+	if self.expiryTrie != nil {
+		self.data.ExpiryRoot = optionalRoot(self.updateExpiryTrie(db).Hash())
+	}
+}
+
+// CommitExpiryTrie commits the expiry trie, if it was opened, to db.
+func (self *tradingExchanges) CommitExpiryTrie(db Database) error {
+	if self.expiryTrie == nil {
+		return nil
+	}
+	tr := self.updateExpiryTrie(db)
+	if self.dbErr != nil {
+		return self.dbErr
+	}
+	root, err := tr.Commit(func(leaf []byte, parent common.Hash) error {
+		var orderList orderList
+		if err := rlp.DecodeBytes(leaf, &orderList); err != nil {
+			return nil
+		}
+		if orderList.Root != EmptyRoot {
+			db.TrieDB().Reference(orderList.Root, parent)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	self.data.ExpiryRoot = optionalRoot(root)
+	return nil
+}
@@ -52,6 +52,8 @@ type stateOrderList struct {
 	cachedStorage map[common.Hash]common.Hash // Storage entry cache to avoid duplicate reads
 	dirtyStorage  map[common.Hash]common.Hash // Storage entries that need to be flushed to disk
 
+	bestOrderId *common.Hash // Oldest order of the list as last found in the trie, nil if unknown
+
 	onDirty func(price common.Hash) // Callback method to mark a state object newly dirty
 }
 
@@ -123,16 +125,45 @@ func (self *stateOrderList) GetOrderAmount(db Database, orderId common.Hash) com
 	return amount
 }
 
+// getBestOrderId returns the oldest order of the list, the zero hash if the
+// list has no order. Partial fills leave it in place, so it is only looked up
+// in the trie again once an order older than it is inserted or it is removed.
+func (self *stateOrderList) getBestOrderId(db Database) (common.Hash, error) {
+	if self.bestOrderId != nil {
+		return *self.bestOrderId, nil
+	}
+	key, _, err := self.getTrie(db).TryGetBestLeftKeyAndValue()
+	if err != nil || len(key) == 0 {
+		return EmptyHash, err
+	}
+	orderId := common.BytesToHash(key)
+	self.bestOrderId = &orderId
+	return orderId, nil
+}
+
+// updateBestOrderId drops the cached oldest order if the given order, inserted
+// into or deleted from the trie, may change it.
+func (self *stateOrderList) updateBestOrderId(orderId common.Hash, deleted bool) {
+	if self.bestOrderId == nil {
+		return
+	}
+	if cmp := bytes.Compare(orderId[:], self.bestOrderId[:]); (deleted && cmp == 0) || (!deleted && cmp < 0) {
+		self.bestOrderId = nil
+	}
+}
+
 // SetState updates a value in orderId storage.
 func (self *stateOrderList) insertOrderItem(db Database, orderId common.Hash, amount common.Hash) {
 	self.setOrderItem(orderId, amount)
 	self.setError(self.getTrie(db).TryUpdate(orderId[:], amount[:]))
+	self.updateBestOrderId(orderId, false)
 }
 
 // SetState updates a value in orderId storage.
 func (self *stateOrderList) removeOrderItem(db Database, orderId common.Hash) {
 	tr := self.getTrie(db)
 	self.setError(tr.TryDelete(orderId[:]))
+	self.updateBestOrderId(orderId, true)
 	self.setOrderItem(orderId, EmptyHash)
 }
 
@@ -153,10 +184,12 @@ func (self *stateOrderList) updateTrie(db Database) Trie {
 		delete(self.dirtyStorage, orderId)
 		if amount == EmptyHash {
 			self.setError(tr.TryDelete(orderId[:]))
+			self.updateBestOrderId(orderId, true)
 			continue
 		}
 		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(amount[:], "\x00"))
 		self.setError(tr.TryUpdate(orderId[:], v))
+		self.updateBestOrderId(orderId, false)
 	}
 	return tr
 }
@@ -185,6 +218,7 @@ func (self *stateOrderList) deepCopy(db *TradingStateDB, onDirty func(price comm
 	for orderId, amount := range self.cachedStorage {
 		stateOrderList.cachedStorage[orderId] = amount
 	}
+	stateOrderList.bestOrderId = self.bestOrderId
 	return stateOrderList
 }
 
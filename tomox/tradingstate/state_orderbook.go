@@ -17,6 +17,7 @@
 package tradingstate
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/log"
@@ -48,6 +49,9 @@ type tradingExchanges struct {
 	bidsTrie             Trie // storage trie, which becomes non-nil on first access
 	ordersTrie           Trie // storage trie, which becomes non-nil on first access
 	liquidationPriceTrie Trie
+	stopAsksTrie         Trie
+	stopBidsTrie         Trie
+	expiryTrie           Trie
 
 	stateAskObjects      map[common.Hash]*stateOrderList
 	stateAskObjectsDirty map[common.Hash]struct{}
@@ -61,6 +65,20 @@ type tradingExchanges struct {
 	liquidationPriceStates      map[common.Hash]*liquidationPriceState
 	liquidationPriceStatesDirty map[common.Hash]struct{}
 
+	stateStopAskObjects      map[common.Hash]*stateOrderList
+	stateStopAskObjectsDirty map[common.Hash]struct{}
+
+	stateStopBidObjects      map[common.Hash]*stateOrderList
+	stateStopBidObjectsDirty map[common.Hash]struct{}
+
+	stateExpiryObjects      map[common.Hash]*stateOrderList
+	stateExpiryObjectsDirty map[common.Hash]struct{}
+
+	// Best price caches, the lowest ask and highest bid price levels as last
+	// found in the asks and bids tries, nil if unknown.
+	bestAskPrice *common.Hash
+	bestBidPrice *common.Hash
+
 	onDirty func(hash common.Hash) // Callback method to mark a state object newly dirty
 }
 
@@ -96,6 +114,12 @@ func (s *tradingExchanges) empty() bool {
 	if !common.EmptyHash(s.data.LiquidationPriceRoot) {
 		return false
 	}
+	if !common.EmptyHash(s.data.StopAskRoot) || !common.EmptyHash(s.data.StopBidRoot) {
+		return false
+	}
+	if !common.EmptyHash(s.data.ExpiryRoot) {
+		return false
+	}
 	return true
 }
 
@@ -113,6 +137,12 @@ func newStateExchanges(db *TradingStateDB, hash common.Hash, data tradingExchang
 		stateBidObjectsDirty:        make(map[common.Hash]struct{}),
 		stateOrderObjectsDirty:      make(map[common.Hash]struct{}),
 		liquidationPriceStatesDirty: make(map[common.Hash]struct{}),
+		stateStopAskObjects:         make(map[common.Hash]*stateOrderList),
+		stateStopAskObjectsDirty:    make(map[common.Hash]struct{}),
+		stateStopBidObjects:         make(map[common.Hash]*stateOrderList),
+		stateStopBidObjectsDirty:    make(map[common.Hash]struct{}),
+		stateExpiryObjects:          make(map[common.Hash]*stateOrderList),
+		stateExpiryObjectsDirty:     make(map[common.Hash]struct{}),
 		onDirty:                     onDirty,
 	}
 }
@@ -154,6 +184,9 @@ func (c *tradingExchanges) getOrdersTrie(db Database) Trie {
 }
 
 func (c *tradingExchanges) getBestPriceAsksTrie(db Database) common.Hash {
+	if c.bestAskPrice != nil {
+		return *c.bestAskPrice
+	}
 	trie := c.getAsksTrie(db)
 	encKey, encValue, err := trie.TryGetBestLeftKeyAndValue()
 	if err != nil {
@@ -174,10 +207,14 @@ func (c *tradingExchanges) getBestPriceAsksTrie(db Database) common.Hash {
 		obj := newStateOrderList(c.db, Bid, c.orderBookHash, price, data, c.MarkStateAskObjectDirty)
 		c.stateAskObjects[price] = obj
 	}
-	return common.BytesToHash(encKey)
+	c.bestAskPrice = &price
+	return price
 }
 
 func (c *tradingExchanges) getBestBidsTrie(db Database) common.Hash {
+	if c.bestBidPrice != nil {
+		return *c.bestBidPrice
+	}
 	trie := c.getBidsTrie(db)
 	encKey, encValue, err := trie.TryGetBestRightKeyAndValue()
 	if err != nil {
@@ -199,7 +236,34 @@ func (c *tradingExchanges) getBestBidsTrie(db Database) common.Hash {
 		obj := newStateOrderList(c.db, Bid, c.orderBookHash, price, data, c.MarkStateBidObjectDirty)
 		c.stateBidObjects[price] = obj
 	}
-	return common.BytesToHash(encKey)
+	c.bestBidPrice = &price
+	return price
+}
+
+// updateBestPrice drops the cached best price of a side if the given price
+// level, inserted into or deleted from the trie of the side, may change it.
+func (c *tradingExchanges) updateBestPrice(side string, price common.Hash, deleted bool) {
+	var best **common.Hash
+	switch side {
+	case Ask:
+		best = &c.bestAskPrice
+	case Bid:
+		best = &c.bestBidPrice
+	default:
+		return
+	}
+	if *best == nil {
+		return
+	}
+	cmp := bytes.Compare(price[:], (*best)[:])
+	switch {
+	case deleted && cmp == 0:
+	case !deleted && side == Ask && cmp < 0:
+	case !deleted && side == Bid && cmp > 0:
+	default:
+		return
+	}
+	*best = nil
 }
 
 // updateAskTrie writes cached storage modifications into the object's storage trie.
@@ -210,12 +274,14 @@ func (self *tradingExchanges) updateAsksTrie(db Database) Trie {
 			delete(self.stateAskObjectsDirty, price)
 			if orderList.empty() {
 				self.setError(tr.TryDelete(price[:]))
+				self.updateBestPrice(Ask, price, true)
 				continue
 			}
 			orderList.updateRoot(db)
 			// Encoding []byte cannot fail, ok to ignore the error.
 			v, _ := rlp.EncodeToBytes(orderList)
 			self.setError(tr.TryUpdate(price[:], v))
+			self.updateBestPrice(Ask, price, false)
 		}
 	}
 
@@ -276,12 +342,14 @@ func (self *tradingExchanges) updateBidsTrie(db Database) Trie {
 			delete(self.stateBidObjectsDirty, price)
 			if orderList.empty() {
 				self.setError(tr.TryDelete(price[:]))
+				self.updateBestPrice(Bid, price, true)
 				continue
 			}
 			orderList.updateRoot(db)
 			// Encoding []byte cannot fail, ok to ignore the error.
 			v, _ := rlp.EncodeToBytes(orderList)
 			self.setError(tr.TryUpdate(price[:], v))
+			self.updateBestPrice(Bid, price, false)
 		}
 	}
 	return tr
@@ -326,6 +394,7 @@ func (self *tradingExchanges) deepCopy(db *TradingStateDB, onDirty func(hash com
 	if self.ordersTrie != nil {
 		stateExchanges.ordersTrie = db.db.CopyTrie(self.ordersTrie)
 	}
+	stateExchanges.bestAskPrice, stateExchanges.bestBidPrice = self.bestAskPrice, self.bestBidPrice
 	for price, bidObject := range self.stateBidObjects {
 		stateExchanges.stateBidObjects[price] = bidObject.deepCopy(db, self.MarkStateBidObjectDirty)
 	}
@@ -350,6 +419,33 @@ func (self *tradingExchanges) deepCopy(db *TradingStateDB, onDirty func(hash com
 	for price := range self.liquidationPriceStatesDirty {
 		stateExchanges.liquidationPriceStatesDirty[price] = struct{}{}
 	}
+	if self.stopAsksTrie != nil {
+		stateExchanges.stopAsksTrie = db.db.CopyTrie(self.stopAsksTrie)
+	}
+	if self.stopBidsTrie != nil {
+		stateExchanges.stopBidsTrie = db.db.CopyTrie(self.stopBidsTrie)
+	}
+	for price, stopObject := range self.stateStopAskObjects {
+		stateExchanges.stateStopAskObjects[price] = stopObject.deepCopy(db, stateExchanges.MarkStateStopAskObjectDirty)
+	}
+	for price := range self.stateStopAskObjectsDirty {
+		stateExchanges.stateStopAskObjectsDirty[price] = struct{}{}
+	}
+	for price, stopObject := range self.stateStopBidObjects {
+		stateExchanges.stateStopBidObjects[price] = stopObject.deepCopy(db, stateExchanges.MarkStateStopBidObjectDirty)
+	}
+	for price := range self.stateStopBidObjectsDirty {
+		stateExchanges.stateStopBidObjectsDirty[price] = struct{}{}
+	}
+	if self.expiryTrie != nil {
+		stateExchanges.expiryTrie = db.db.CopyTrie(self.expiryTrie)
+	}
+	for time, expiryObject := range self.stateExpiryObjects {
+		stateExchanges.stateExpiryObjects[time] = expiryObject.deepCopy(db, stateExchanges.MarkStateExpiryObjectDirty)
+	}
+	for time := range self.stateExpiryObjectsDirty {
+		stateExchanges.stateExpiryObjectsDirty[time] = struct{}{}
+	}
 	return stateExchanges
 }
 
@@ -402,11 +498,13 @@ func (self *tradingExchanges) setMediumPrice(price *big.Int, quantity *big.Int)
 // updateStateExchangeObject writes the given object to the trie.
 func (self *tradingExchanges) removeStateOrderListAskObject(db Database, stateOrderList *stateOrderList) {
 	self.setError(self.asksTrie.TryDelete(stateOrderList.price[:]))
+	self.updateBestPrice(Ask, stateOrderList.price, true)
 }
 
 // updateStateExchangeObject writes the given object to the trie.
 func (self *tradingExchanges) removeStateOrderListBidObject(db Database, stateOrderList *stateOrderList) {
 	self.setError(self.bidsTrie.TryDelete(stateOrderList.price[:]))
+	self.updateBestPrice(Bid, stateOrderList.price, true)
 }
 
 // Retrieve a state object given my the address. Returns nil if not found.
@@ -454,6 +552,7 @@ func (self *tradingExchanges) createStateOrderListAskObject(db Database, price c
 		panic(fmt.Errorf("can't encode order list object at %x: %v", price[:], err))
 	}
 	self.setError(self.asksTrie.TryUpdate(price[:], data))
+	self.updateBestPrice(Ask, price, false)
 	if self.onDirty != nil {
 		self.onDirty(self.Hash())
 		self.onDirty = nil
@@ -506,6 +605,7 @@ func (self *tradingExchanges) createStateBidOrderListObject(db Database, price c
 		panic(fmt.Errorf("can't encode order list object at %x: %v", price[:], err))
 	}
 	self.setError(self.bidsTrie.TryUpdate(price[:], data))
+	self.updateBestPrice(Bid, price, false)
 	if self.onDirty != nil {
 		self.onDirty(self.Hash())
 		self.onDirty = nil
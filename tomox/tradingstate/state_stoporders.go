@@ -0,0 +1,251 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// The stop orders of an order book are indexed by trigger price, one trie per
+// side, mirroring the ask and bid tries: trigger price => order list => order
+// id => amount. The orders themselves are kept in the order trie of the book.
+
+// getStopTrie returns the trie of the stop orders of the given side, nil if the
+// side is unknown.
+func (self *tradingExchanges) getStopTrie(db Database, side string) Trie {
+	var (
+		tr   *Trie
+		root common.Hash
+	)
+	switch side {
+	case Ask:
+		tr, root = &self.stopAsksTrie, self.data.StopAskRoot
+	case Bid:
+		tr, root = &self.stopBidsTrie, self.data.StopBidRoot
+	default:
+		return nil
+	}
+	if *tr == nil {
+		var err error
+		*tr, err = db.OpenStorageTrie(self.orderBookHash, root)
+		if err != nil {
+			*tr, _ = db.OpenStorageTrie(self.orderBookHash, EmptyHash)
+			self.setError(fmt.Errorf("can't create stop orders trie: %v", err))
+		}
+	}
+	return *tr
+}
+
+// stopOrderLists returns the live and dirty stop order lists of the given side.
+func (self *tradingExchanges) stopOrderLists(side string) (map[common.Hash]*stateOrderList, map[common.Hash]struct{}, func(price common.Hash)) {
+	switch side {
+	case Ask:
+		return self.stateStopAskObjects, self.stateStopAskObjectsDirty, self.MarkStateStopAskObjectDirty
+	case Bid:
+		return self.stateStopBidObjects, self.stateStopBidObjectsDirty, self.MarkStateStopBidObjectDirty
+	}
+	return nil, nil, nil
+}
+
+// MarkStateStopAskObjectDirty adds the specified sell stop order list to the
+// dirty map.
+func (self *tradingExchanges) MarkStateStopAskObjectDirty(price common.Hash) {
+	self.stateStopAskObjectsDirty[price] = struct{}{}
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+// MarkStateStopBidObjectDirty adds the specified buy stop order list to the
+// dirty map.
+func (self *tradingExchanges) MarkStateStopBidObjectDirty(price common.Hash) {
+	self.stateStopBidObjectsDirty[price] = struct{}{}
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+}
+
+// getStateStopOrderList retrieves the stop orders of a side triggered at the
+// given price. Returns nil if not found, or if all of them were removed.
+func (self *tradingExchanges) getStateStopOrderList(db Database, side string, price common.Hash) *stateOrderList {
+	objects, _, onDirty := self.stopOrderLists(side)
+	if objects == nil {
+		return nil
+	}
+	// Prefer 'live' objects. An emptied list is already deleted from the trie.
+	if obj := objects[price]; obj != nil {
+		if obj.empty() {
+			return nil
+		}
+		return obj
+	}
+	// Load the object from the database.
+	enc, err := self.getStopTrie(db, side).TryGet(price[:])
+	if len(enc) == 0 {
+		self.setError(err)
+		return nil
+	}
+	var data orderList
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		log.Error("Failed to decode stop order list", "price", price, "err", err)
+		return nil
+	}
+	// Insert into the live set.
+	obj := newStateOrderList(self.db, side, self.orderBookHash, price, data, onDirty)
+	objects[price] = obj
+	return obj
+}
+
+// createStateStopOrderList creates the stop orders of a side triggered at the
+// given price.
+func (self *tradingExchanges) createStateStopOrderList(db Database, side string, price common.Hash) *stateOrderList {
+	objects, dirties, onDirty := self.stopOrderLists(side)
+	if objects == nil {
+		return nil
+	}
+	newobj := newStateOrderList(self.db, side, self.orderBookHash, price, orderList{Volume: Zero}, onDirty)
+	objects[price] = newobj
+	dirties[price] = struct{}{}
+	data, err := rlp.EncodeToBytes(newobj)
+	if err != nil {
+		panic(fmt.Errorf("can't encode stop order list object at %x: %v", price[:], err))
+	}
+	self.setError(self.getStopTrie(db, side).TryUpdate(price[:], data))
+	if self.onDirty != nil {
+		self.onDirty(self.Hash())
+		self.onDirty = nil
+	}
+	return newobj
+}
+
+func (self *tradingExchanges) removeStateStopOrderList(db Database, stateOrderList *stateOrderList) {
+	self.setError(self.getStopTrie(db, stateOrderList.orderType).TryDelete(stateOrderList.price[:]))
+}
+
+// getBestStopPrice returns the trigger price of the stop orders of a side to be
+// activated first: the highest for sell stops, which are activated as the price
+// falls, and the lowest for buy stops, which are activated as the price rises.
+func (self *tradingExchanges) getBestStopPrice(db Database, side string) common.Hash {
+	tr := self.getStopTrie(db, side)
+	if tr == nil {
+		return EmptyHash
+	}
+	var (
+		encKey, encValue []byte
+		err              error
+	)
+	if side == Ask {
+		encKey, encValue, err = tr.TryGetBestRightKeyAndValue()
+	} else {
+		encKey, encValue, err = tr.TryGetBestLeftKeyAndValue()
+	}
+	if err != nil {
+		log.Error("Failed find best stop price", "orderbook", self.orderBookHash.Hex(), "side", side)
+		return EmptyHash
+	}
+	if len(encKey) == 0 || len(encValue) == 0 {
+		return EmptyHash
+	}
+	price := common.BytesToHash(encKey)
+	if objects, _, onDirty := self.stopOrderLists(side); objects[price] == nil {
+		var data orderList
+		if err := rlp.DecodeBytes(encValue, &data); err != nil {
+			log.Error("Failed to decode best stop order list", "err", err)
+			return EmptyHash
+		}
+		objects[price] = newStateOrderList(self.db, side, self.orderBookHash, price, data, onDirty)
+	}
+	return price
+}
+
+// updateStopTrie writes cached stop order list modifications of a side into its
+// trie.
+func (self *tradingExchanges) updateStopTrie(db Database, side string) Trie {
+	tr := self.getStopTrie(db, side)
+	objects, dirties, _ := self.stopOrderLists(side)
+	for price, orderList := range objects {
+		if _, isDirty := dirties[price]; isDirty {
+			delete(dirties, price)
+			if orderList.empty() {
+				self.setError(tr.TryDelete(price[:]))
+				continue
+			}
+			orderList.updateRoot(db)
+			// Encoding []byte cannot fail, ok to ignore the error.
+			v, _ := rlp.EncodeToBytes(orderList)
+			self.setError(tr.TryUpdate(price[:], v))
+		}
+	}
+	return tr
+}
+
+// optionalRoot keeps the root of an empty stop orders or expiry trie to the zero
+// hash, so the encoding of books without such orders doesn't change.
+func optionalRoot(root common.Hash) common.Hash {
+	if root == EmptyRoot {
+		return EmptyHash
+	}
+	return root
+}
+
+// updateStopRoots updates the roots of the stop orders tries which were opened.
+func (self *tradingExchanges) updateStopRoots(db Database) {
+	if self.stopAsksTrie != nil {
+		self.data.StopAskRoot = optionalRoot(self.updateStopTrie(db, Ask).Hash())
+	}
+	if self.stopBidsTrie != nil {
+		self.data.StopBidRoot = optionalRoot(self.updateStopTrie(db, Bid).Hash())
+	}
+}
+
+// CommitStopTries commits the stop orders tries which were opened to db.
+func (self *tradingExchanges) CommitStopTries(db Database) error {
+	for _, side := range []string{Ask, Bid} {
+		if (side == Ask && self.stopAsksTrie == nil) || (side == Bid && self.stopBidsTrie == nil) {
+			continue
+		}
+		tr := self.updateStopTrie(db, side)
+		if self.dbErr != nil {
+			return self.dbErr
+		}
+		root, err := tr.Commit(func(leaf []byte, parent common.Hash) error {
+			var orderList orderList
+			if err := rlp.DecodeBytes(leaf, &orderList); err != nil {
+				return nil
+			}
+			if orderList.Root != EmptyRoot {
+				db.TrieDB().Reference(orderList.Root, parent)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if side == Ask {
+			self.data.StopAskRoot = optionalRoot(root)
+		} else {
+			self.data.StopBidRoot = optionalRoot(root)
+		}
+	}
+	return nil
+}
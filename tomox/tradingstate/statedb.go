@@ -20,14 +20,21 @@ package tradingstate
 import (
 	"fmt"
 	"math/big"
+	"runtime"
 	"sort"
 	"sync"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
 )
 
+// TradingTrieSize accounts the nodes committed to the trading state tries.
+var TradingTrieSize = state.NewTrieSizeCounter("trading")
+
 type revision struct {
 	id           int
 	journalIndex int
@@ -46,6 +53,10 @@ type TradingStateDB struct {
 	stateExhangeObjects      map[common.Hash]*tradingExchanges
 	stateExhangeObjectsDirty map[common.Hash]struct{}
 
+	// Whether the terminal orders and price levels are pruned, from the
+	// TIPTomoXPrune fork on
+	prune bool
+
 	// DB error.
 	// State objects are used by the consensus core and VM which are
 	// unable to deal with database-level errors. Any error that occurs
@@ -190,13 +201,23 @@ func (self *TradingStateDB) InsertOrderItem(orderBook common.Hash, orderId commo
 		stateExchange = self.createExchangeObject(orderBook)
 	}
 	var stateOrderList *stateOrderList
-	switch order.Side {
-	case Ask:
+	switch {
+	case order.IsStopOrder():
+		// stop orders wait in the stop orders of their side, by trigger price
+		triggerHash := common.BigToHash(order.TriggerPrice)
+		stateOrderList = stateExchange.getStateStopOrderList(self.db, order.Side, triggerHash)
+		if stateOrderList == nil {
+			stateOrderList = stateExchange.createStateStopOrderList(self.db, order.Side, triggerHash)
+		}
+		if stateOrderList == nil {
+			return
+		}
+	case order.Side == Ask:
 		stateOrderList = stateExchange.getStateOrderListAskObject(self.db, priceHash)
 		if stateOrderList == nil {
 			stateOrderList = stateExchange.createStateOrderListAskObject(self.db, priceHash)
 		}
-	case Bid:
+	case order.Side == Bid:
 		stateOrderList = stateExchange.getStateBidOrderListObject(self.db, priceHash)
 		if stateOrderList == nil {
 			stateOrderList = stateExchange.createStateBidOrderListObject(self.db, priceHash)
@@ -210,8 +231,12 @@ func (self *TradingStateDB) InsertOrderItem(orderBook common.Hash, orderId commo
 		order:     &order,
 	})
 	stateExchange.createStateOrderObject(self.db, orderId, order)
-	stateOrderList.insertOrderItem(self.db, orderId, common.BigToHash(order.Quantity))
-	stateOrderList.AddVolume(order.Quantity)
+	// an iceberg order only shows its visible part in the order list
+	stateOrderList.insertOrderItem(self.db, orderId, common.BigToHash(order.VisibleQuantity()))
+	stateOrderList.AddVolume(order.VisibleQuantity())
+	if order.ExpiresAt != 0 {
+		self.insertOrderExpiry(stateExchange, orderId, order.ExpiresAt)
+	}
 }
 
 func (self *TradingStateDB) GetOrder(orderBook common.Hash, orderId common.Hash) OrderItem {
@@ -257,15 +282,20 @@ func (self *TradingStateDB) SubAmountOrderItem(orderBook common.Hash, orderId co
 		order:     self.GetOrder(orderBook, orderId),
 		amount:    amount,
 	})
-	newAmount := new(big.Int).Sub(currentAmount, amount)
+	// the order list holds the visible part of an iceberg order, which is
+	// replenished from its hidden part after each fill
+	stateOrderItem.setVolume(new(big.Int).Sub(stateOrderItem.Quantity(), amount))
+	newAmount := stateOrderItem.data.VisibleQuantity()
 	log.Debug("SubAmountOrderItem", "orderId", orderId.Hex(), "side", side, "price", price.Uint64(), "amount", amount.Uint64(), "new amount", newAmount.Uint64())
-	stateOrderList.subVolume(amount)
-	stateOrderItem.setVolume(newAmount)
+	stateOrderList.subVolume(new(big.Int).Sub(currentAmount, newAmount))
 	if newAmount.Sign() == 0 {
 		stateOrderList.removeOrderItem(self.db, orderId)
 	} else {
 		stateOrderList.setOrderItem(orderId, common.BigToHash(newAmount))
 	}
+	if stateOrderItem.Quantity().Sign() == 0 && stateOrderItem.data.ExpiresAt != 0 {
+		self.removeOrderExpiry(stateObject, orderId, stateOrderItem.data.ExpiresAt)
+	}
 	if stateOrderList.empty() {
 		switch side {
 		case Ask:
@@ -289,11 +319,15 @@ func (self *TradingStateDB) CancelOrder(orderBook common.Hash, order *OrderItem)
 		return fmt.Errorf("Order item empty  order book : %s , order id  : %s ", orderBook, orderIdHash.Hex())
 	}
 	priceHash := common.BigToHash(stateOrderItem.data.Price)
+	isStopOrder := stateOrderItem.data.IsStopOrder()
 	var stateOrderList *stateOrderList
-	switch stateOrderItem.data.Side {
-	case Ask:
+	switch {
+	case isStopOrder:
+		priceHash = common.BigToHash(stateOrderItem.data.TriggerPrice)
+		stateOrderList = stateObject.getStateStopOrderList(self.db, stateOrderItem.data.Side, priceHash)
+	case stateOrderItem.data.Side == Ask:
 		stateOrderList = stateObject.getStateOrderListAskObject(self.db, priceHash)
-	case Bid:
+	case stateOrderItem.data.Side == Bid:
 		stateOrderList = stateObject.getStateBidOrderListObject(self.db, priceHash)
 	default:
 		return fmt.Errorf("Order side not found : %s ", order.Side)
@@ -320,11 +354,16 @@ func (self *TradingStateDB) CancelOrder(orderBook common.Hash, order *OrderItem)
 	stateOrderItem.setVolume(big.NewInt(0))
 	stateOrderList.subVolume(currentAmount)
 	stateOrderList.removeOrderItem(self.db, orderIdHash)
+	if stateOrderItem.data.ExpiresAt != 0 {
+		self.removeOrderExpiry(stateObject, orderIdHash, stateOrderItem.data.ExpiresAt)
+	}
 	if stateOrderList.empty() {
-		switch stateOrderItem.data.Side {
-		case Ask:
+		switch {
+		case isStopOrder:
+			stateObject.removeStateStopOrderList(self.db, stateOrderList)
+		case stateOrderItem.data.Side == Ask:
 			stateObject.removeStateOrderListAskObject(self.db, stateOrderList)
-		case Bid:
+		case stateOrderItem.data.Side == Bid:
 			stateObject.removeStateOrderListBidObject(self.db, stateOrderList)
 		default:
 		}
@@ -399,11 +438,10 @@ func (self *TradingStateDB) GetBestOrderIdAndAmount(orderBook common.Hash, price
 			return EmptyHash, Zero, fmt.Errorf("not found side :%s ", side)
 		}
 		if stateOrderList != nil {
-			key, _, err := stateOrderList.getTrie(self.db).TryGetBestLeftKeyAndValue()
+			orderId, err := stateOrderList.getBestOrderId(self.db)
 			if err != nil {
 				return EmptyHash, Zero, err
 			}
-			orderId := common.BytesToHash(key)
 			amount := stateOrderList.GetOrderAmount(self.db, orderId)
 			return orderId, new(big.Int).SetBytes(amount.Bytes()), nil
 		}
@@ -412,6 +450,200 @@ func (self *TradingStateDB) GetBestOrderIdAndAmount(orderBook common.Hash, price
 	return EmptyHash, Zero, fmt.Errorf("not found orderBook : %s ", orderBook.Hex())
 }
 
+// GetAllOrderBooks returns the hashes of every order book kept in the trading
+// state, including books that are no longer listed, in ascending order.
+func (self *TradingStateDB) GetAllOrderBooks() []common.Hash {
+	seen := make(map[common.Hash]struct{})
+	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	for it.Next() {
+		seen[common.BytesToHash(it.Key)] = struct{}{}
+	}
+	if it.Err != nil {
+		self.setError(it.Err)
+	}
+	for hash := range self.stateExhangeObjects {
+		seen[hash] = struct{}{}
+	}
+	orderBooks := make([]common.Hash, 0, len(seen))
+	for hash := range seen {
+		orderBooks = append(orderBooks, hash)
+	}
+	sort.Slice(orderBooks, func(i, j int) bool {
+		return orderBooks[i].Big().Cmp(orderBooks[j].Big()) < 0
+	})
+	return orderBooks
+}
+
+// GetOrderBookRoot returns the hash of the committed state of the given order
+// book, covering its prices and the roots of its order tries, or the empty hash
+// if the order book doesn't exist.
+func (self *TradingStateDB) GetOrderBookRoot(orderBook common.Hash) common.Hash {
+	enc, err := self.trie.TryGet(orderBook[:])
+	if len(enc) == 0 {
+		self.setError(err)
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(enc)
+}
+
+// OrderBookTrieRoots decodes an order book object, as stored in the leaves of
+// the trading state trie, and returns the roots of its ask, bid, order and
+// liquidation price tries, followed by the roots of its stop order tries and of
+// its expiry trie.
+func OrderBookTrieRoots(leaf []byte) ([]common.Hash, error) {
+	var data tradingExchangeObject
+	if err := rlp.DecodeBytes(leaf, &data); err != nil {
+		return nil, err
+	}
+	return []common.Hash{data.AskRoot, data.BidRoot, data.OrderRoot, data.LiquidationPriceRoot, data.StopAskRoot, data.StopBidRoot, data.ExpiryRoot}, nil
+}
+
+// OrderListTrieRoots decodes an order list, as stored in the leaves of the ask,
+// bid, liquidation price and lending book tries, and returns the root of the
+// trie holding its entries.
+func OrderListTrieRoots(leaf []byte) ([]common.Hash, error) {
+	var data orderList
+	if err := rlp.DecodeBytes(leaf, &data); err != nil {
+		return nil, err
+	}
+	return []common.Hash{data.Root}, nil
+}
+
+// GetRestingOrderIds returns the ids of every order resting on either side of
+// the given order book, in ascending order.
+func (self *TradingStateDB) GetRestingOrderIds(orderBook common.Hash) ([]common.Hash, error) {
+	if self.getStateExchangeObject(orderBook) == nil {
+		return nil, nil
+	}
+	asks, err := self.DumpAskTrie(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	bids, err := self.DumpBidTrie(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	orderIds := []common.Hash{}
+	for _, side := range []map[*big.Int]DumpOrderList{asks, bids} {
+		for _, orderList := range side {
+			for orderId, amount := range orderList.Orders {
+				if amount.Sign() > 0 {
+					orderIds = append(orderIds, common.BigToHash(orderId))
+				}
+			}
+		}
+	}
+	sort.Slice(orderIds, func(i, j int) bool {
+		return orderIds[i].Big().Cmp(orderIds[j].Big()) < 0
+	})
+	return orderIds, nil
+}
+
+// GetStopOrderIds returns the ids of every stop order of the given order book
+// waiting for its trigger price, in ascending order.
+func (self *TradingStateDB) GetStopOrderIds(orderBook common.Hash) ([]common.Hash, error) {
+	if self.getStateExchangeObject(orderBook) == nil {
+		return nil, nil
+	}
+	orderIds := []common.Hash{}
+	for _, side := range []string{Ask, Bid} {
+		stops, err := self.DumpStopTrie(orderBook, side)
+		if err != nil {
+			return nil, err
+		}
+		for _, orderList := range stops {
+			for orderId, amount := range orderList.Orders {
+				if amount.Sign() > 0 {
+					orderIds = append(orderIds, common.BigToHash(orderId))
+				}
+			}
+		}
+	}
+	sort.Slice(orderIds, func(i, j int) bool {
+		return orderIds[i].Big().Cmp(orderIds[j].Big()) < 0
+	})
+	return orderIds, nil
+}
+
+// GetTriggeredStopOrder returns the stop order of the given side to be activated
+// first at the given price, if any: the oldest sell stop with the highest
+// trigger price at or above the price, or the oldest buy stop with the lowest
+// trigger price at or below it.
+func (self *TradingStateDB) GetTriggeredStopOrder(orderBook common.Hash, side string, price *big.Int) (OrderItem, bool) {
+	stateObject := self.getStateExchangeObject(orderBook)
+	if stateObject == nil || price == nil || price.Sign() <= 0 {
+		return EmptyOrder, false
+	}
+	triggerHash := stateObject.getBestStopPrice(self.db, side)
+	if common.EmptyHash(triggerHash) {
+		return EmptyOrder, false
+	}
+	trigger := triggerHash.Big()
+	if (side == Ask && trigger.Cmp(price) < 0) || (side == Bid && trigger.Cmp(price) > 0) {
+		return EmptyOrder, false
+	}
+	stateOrderList := stateObject.getStateStopOrderList(self.db, side, triggerHash)
+	if stateOrderList == nil {
+		return EmptyOrder, false
+	}
+	orderId, err := stateOrderList.getBestOrderId(self.db)
+	if err != nil || common.EmptyHash(orderId) {
+		log.Error("Stop order list without orders", "orderBook", orderBook.Hex(), "side", side, "trigger", trigger, "err", err)
+		return EmptyOrder, false
+	}
+	order := self.GetOrder(orderBook, orderId)
+	return order, !IsEmptyOrder(order)
+}
+
+// GetExpiredOrder returns the good-till-date order of the given order book
+// expiring first, if it expires at the given unix time or before.
+func (self *TradingStateDB) GetExpiredOrder(orderBook common.Hash, time uint64) (OrderItem, bool) {
+	stateObject := self.getStateExchangeObject(orderBook)
+	if stateObject == nil {
+		return EmptyOrder, false
+	}
+	expiryHash, stateOrderList := stateObject.getLowestExpiryTime(self.db)
+	if stateOrderList == nil || stateOrderList.empty() || expiryHash.Big().Cmp(new(big.Int).SetUint64(time)) > 0 {
+		return EmptyOrder, false
+	}
+	orderId, err := stateOrderList.getBestOrderId(self.db)
+	if err != nil || common.EmptyHash(orderId) {
+		log.Error("Expiry order list without orders", "orderBook", orderBook.Hex(), "expiry", expiryHash.Big(), "err", err)
+		return EmptyOrder, false
+	}
+	order := self.GetOrder(orderBook, orderId)
+	return order, !IsEmptyOrder(order)
+}
+
+// insertOrderExpiry indexes a good-till-date order by its expiry time. An order
+// already indexed is left as is.
+func (self *TradingStateDB) insertOrderExpiry(stateExchange *tradingExchanges, orderId common.Hash, expiresAt uint64) {
+	expiryHash := common.Uint64ToHash(expiresAt)
+	stateOrderList := stateExchange.getStateExpiryOrderList(self.db, expiryHash)
+	if stateOrderList == nil {
+		stateOrderList = stateExchange.createStateExpiryOrderList(self.db, expiryHash)
+	}
+	if !common.EmptyHash(stateOrderList.GetOrderAmount(self.db, orderId)) {
+		return
+	}
+	stateOrderList.insertOrderItem(self.db, orderId, common.BigToHash(One))
+	stateOrderList.AddVolume(One)
+}
+
+// removeOrderExpiry removes a good-till-date order from the expiry index, once
+// it is cancelled or filled.
+func (self *TradingStateDB) removeOrderExpiry(stateExchange *tradingExchanges, orderId common.Hash, expiresAt uint64) {
+	stateOrderList := stateExchange.getStateExpiryOrderList(self.db, common.Uint64ToHash(expiresAt))
+	if stateOrderList == nil || common.EmptyHash(stateOrderList.GetOrderAmount(self.db, orderId)) {
+		return
+	}
+	stateOrderList.removeOrderItem(self.db, orderId)
+	stateOrderList.subVolume(One)
+	if stateOrderList.empty() {
+		stateExchange.removeStateExpiryOrderList(self.db, stateOrderList)
+	}
+}
+
 // updateStateExchangeObject writes the given object to the trie.
 func (self *TradingStateDB) updateStateExchangeObject(stateObject *tradingExchanges) {
 	addr := stateObject.Hash()
@@ -486,6 +718,7 @@ func (self *TradingStateDB) Copy() *TradingStateDB {
 		trie:                     self.db.CopyTrie(self.trie),
 		stateExhangeObjects:      make(map[common.Hash]*tradingExchanges, len(self.stateExhangeObjectsDirty)),
 		stateExhangeObjectsDirty: make(map[common.Hash]struct{}, len(self.stateExhangeObjectsDirty)),
+		prune:                    self.prune,
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.stateExhangeObjectsDirty {
@@ -498,6 +731,13 @@ func (self *TradingStateDB) Copy() *TradingStateDB {
 	return state
 }
 
+// SetPruning sets whether the filled and cancelled orders and the emptied price
+// levels are pruned when the state is finalised or committed. It's only enabled
+// from the TIPTomoXPrune fork on, as it changes the roots of the blocks before.
+func (self *TradingStateDB) SetPruning(enabled bool) {
+	self.prune = enabled
+}
+
 func (s *TradingStateDB) clearJournalAndRefund() {
 	s.journal = nil
 	s.validRevisions = s.validRevisions[:0]
@@ -543,6 +783,11 @@ func (s *TradingStateDB) Finalise() {
 			stateObject.updateBidsRoot(s.db)
 			stateObject.updateOrdersRoot(s.db)
 			stateObject.updateLiquidationPriceRoot(s.db)
+			stateObject.updateStopRoots(s.db)
+			stateObject.updateExpiryRoot(s.db)
+			if s.prune {
+				stateObject.pruneTerminal()
+			}
 			// Update the object in the main orderId trie.
 			s.updateStateExchangeObject(stateObject)
 			//delete(s.stateExhangeObjectsDirty, addr)
@@ -562,25 +807,55 @@ func (s *TradingStateDB) IntermediateRoot() common.Hash {
 // Commit writes the state to the underlying in-memory trie database.
 func (s *TradingStateDB) Commit() (root common.Hash, err error) {
 	defer s.clearJournalAndRefund()
-	// Commit objects to the trie.
+	nodes, size := s.db.TrieDB().Inserted()
+	defer func() { TradingTrieSize.MarkSince(s.db.TrieDB(), nodes, size) }()
+	// Commit objects to the trie. The tries of distinct order books are
+	// independent, so they're committed concurrently.
+	var (
+		dirties []*tradingExchanges
+		books   []common.Hash
+	)
 	for addr, stateObject := range s.stateExhangeObjects {
 		if _, isDirty := s.stateExhangeObjectsDirty[addr]; isDirty {
-			// Write any storage changes in the state object to its storage trie.
-			if err := stateObject.CommitAsksTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			if err := stateObject.CommitBidsTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			if err := stateObject.CommitOrdersTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			if err := stateObject.CommitLiquidationPriceTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			// Update the object in the main orderId trie.
-			s.updateStateExchangeObject(stateObject)
-			delete(s.stateExhangeObjectsDirty, addr)
+			dirties = append(dirties, stateObject)
+			books = append(books, addr)
+		}
+	}
+	err = commitConcurrently(len(dirties), func(i int) error {
+		// Write any storage changes in the state object to its storage trie.
+		stateObject := dirties[i]
+		if err := stateObject.CommitAsksTrie(s.db); err != nil {
+			return err
+		}
+		if err := stateObject.CommitBidsTrie(s.db); err != nil {
+			return err
+		}
+		if err := stateObject.CommitOrdersTrie(s.db); err != nil {
+			return err
+		}
+		if err := stateObject.CommitLiquidationPriceTrie(s.db); err != nil {
+			return err
+		}
+		if err := stateObject.CommitStopTries(s.db); err != nil {
+			return err
+		}
+		if err := stateObject.CommitExpiryTrie(s.db); err != nil {
+			return err
+		}
+		if s.prune {
+			stateObject.pruneTerminal()
+		}
+		return nil
+	})
+	if err != nil {
+		return EmptyHash, err
+	}
+	for i, stateObject := range dirties {
+		// Update the object in the main orderId trie.
+		s.updateStateExchangeObject(stateObject)
+		delete(s.stateExhangeObjectsDirty, books[i])
+		if s.prune {
+			stateObject.onDirty = s.MarkStateExchangeObjectDirty
 		}
 	}
 	// Write trie changes.
@@ -601,12 +876,53 @@ func (s *TradingStateDB) Commit() (root common.Hash, err error) {
 		if exchange.LiquidationPriceRoot != EmptyRoot {
 			s.db.TrieDB().Reference(exchange.LiquidationPriceRoot, parent)
 		}
+		for _, root := range []common.Hash{exchange.StopAskRoot, exchange.StopBidRoot, exchange.ExpiryRoot} {
+			if root != EmptyRoot && root != EmptyHash {
+				s.db.TrieDB().Reference(root, parent)
+			}
+		}
 		return nil
 	})
 	log.Debug("Trading State Trie cache stats after commit", "root", root.Hex())
 	return root, err
 }
 
+// commitConcurrently runs commit for every index in [0, n) on a pool of at most
+// one worker per CPU, and returns the error of the lowest failing index.
+func commitConcurrently(n int, commit func(i int) error) error {
+	workers := runtime.NumCPU()
+	if workers > n {
+		workers = n
+	}
+	var (
+		jobs = make(chan int, n)
+		errs = make([]error, n)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = commit(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (self *TradingStateDB) GetAllLowerLiquidationPriceData(orderBook common.Hash, limit *big.Int) map[*big.Int]map[common.Hash][]common.Hash {
 	result := map[*big.Int]map[common.Hash][]common.Hash{}
 	orderbookState := self.getStateExchangeObject(orderBook)
@@ -300,3 +300,504 @@ func TestDumpState(t *testing.T) {
 	fmt.Println("bidTrie", bidTrie)
 	db.Close()
 }
+
+// Tests that order book roots commit to the order book state, and are equal on
+// states holding the same orders.
+func TestOrderBookRoot(t *testing.T) {
+	var (
+		btcTomo = common.StringToHash("BTC/TOMO")
+		ethTomo = common.StringToHash("ETH/TOMO")
+	)
+	commit := func(orders int) *TradingStateDB {
+		stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+		statedb, _ := New(common.Hash{}, stateCache)
+		for i := 1; i <= orders; i++ {
+			order := OrderItem{OrderID: uint64(i), Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(i)), Side: Ask, Signature: &Signature{V: 1}}
+			statedb.InsertOrderItem(btcTomo, common.BigToHash(big.NewInt(int64(i))), order)
+		}
+		statedb.SetLastPrice(ethTomo, big.NewInt(1))
+		root, err := statedb.Commit()
+		if err != nil {
+			t.Fatalf("failed to commit trading state: %v", err)
+		}
+		statedb, err = New(root, stateCache)
+		if err != nil {
+			t.Fatalf("failed to open trading state: %v", err)
+		}
+		return statedb
+	}
+	one, two, same := commit(1), commit(2), commit(2)
+	if root := one.GetOrderBookRoot(common.StringToHash("TOMO/USDT")); root != (common.Hash{}) {
+		t.Errorf("missing order book root mismatch: have %x, want empty", root)
+	}
+	if one.GetOrderBookRoot(btcTomo) == two.GetOrderBookRoot(btcTomo) {
+		t.Errorf("order book root unchanged by an insertion")
+	}
+	if two.GetOrderBookRoot(btcTomo) != same.GetOrderBookRoot(btcTomo) {
+		t.Errorf("order book roots of equal states differ")
+	}
+	if one.GetOrderBookRoot(ethTomo) != two.GetOrderBookRoot(ethTomo) {
+		t.Errorf("order book root changed by an insertion into another book")
+	}
+}
+
+// Tests that committing many order books concurrently yields the same root as
+// hashing them, and that every book can be read back from the committed state.
+func TestCommitOrderBooks(t *testing.T) {
+	const books, orders = 64, 8
+
+	populate := func(statedb *TradingStateDB) {
+		for b := 0; b < books; b++ {
+			orderBook := common.BigToHash(big.NewInt(int64(b + 1)))
+			for i := 1; i <= orders; i++ {
+				side := Ask
+				if i%2 == 0 {
+					side = Bid
+				}
+				order := OrderItem{OrderID: uint64(i), Quantity: big.NewInt(int64(i)), Price: big.NewInt(int64(b + i)), Side: side, Signature: &Signature{V: 1}}
+				statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(int64(i))), order)
+			}
+			statedb.InsertLiquidationPrice(orderBook, big.NewInt(int64(b+1)), common.StringToHash("BTC/TOMO"), uint64(b))
+		}
+	}
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	hashed, _ := New(common.Hash{}, stateCache)
+	populate(hashed)
+	want := hashed.IntermediateRoot()
+
+	committed, _ := New(common.Hash{}, stateCache)
+	populate(committed)
+	root, err := committed.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	if root != want {
+		t.Fatalf("committed root mismatch: have %x, want %x", root, want)
+	}
+	statedb, err := New(root, stateCache)
+	if err != nil {
+		t.Fatalf("failed to open trading state: %v", err)
+	}
+	for b := 0; b < books; b++ {
+		orderBook := common.BigToHash(big.NewInt(int64(b + 1)))
+		for i := 1; i <= orders; i++ {
+			if order := statedb.GetOrder(orderBook, common.BigToHash(big.NewInt(int64(i)))); order.Quantity == nil || order.Quantity.Int64() != int64(i) {
+				t.Fatalf("book %d order %d: quantity mismatch: have %v, want %d", b, i, order.Quantity, i)
+			}
+		}
+	}
+}
+
+// Tests that stop orders wait apart from the book, are triggered highest first
+// for sell stops and lowest first for buy stops, and leave the root of books
+// without stop orders unchanged.
+func TestStopOrders(t *testing.T) {
+	orderBook := common.StringToHash("BTC/TOMO")
+	limit := OrderItem{OrderID: 1, Quantity: big.NewInt(1), Price: big.NewInt(100), Side: Ask, Type: Limit, Signature: &Signature{V: 1}}
+
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	plain, _ := New(common.Hash{}, stateCache)
+	plain.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(1)), limit)
+
+	statedb, _ := New(common.Hash{}, stateCache)
+	statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(1)), limit)
+	if _, triggered := statedb.GetTriggeredStopOrder(orderBook, Ask, big.NewInt(1)); triggered {
+		t.Fatalf("stop order triggered in a book without stop orders")
+	}
+	if have, want := statedb.IntermediateRoot(), plain.IntermediateRoot(); have != want {
+		t.Fatalf("root of a book without stop orders changed: have %x, want %x", have, want)
+	}
+	stops := []OrderItem{
+		{OrderID: 2, Side: Ask, Type: StopMarket, Price: Zero, TriggerPrice: big.NewInt(90)},
+		{OrderID: 3, Side: Ask, Type: StopLimit, Price: big.NewInt(94), TriggerPrice: big.NewInt(95)},
+		{OrderID: 4, Side: Ask, Type: StopMarket, Price: Zero, TriggerPrice: big.NewInt(95)},
+		{OrderID: 5, Side: Bid, Type: StopMarket, Price: Zero, TriggerPrice: big.NewInt(110)},
+	}
+	for _, order := range stops {
+		order.Quantity, order.Signature = big.NewInt(1), &Signature{V: 1}
+		statedb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)), order)
+	}
+	triggered := func(side string, price int64) uint64 {
+		order, ok := statedb.GetTriggeredStopOrder(orderBook, side, big.NewInt(price))
+		if !ok {
+			return 0
+		}
+		return order.OrderID
+	}
+	if ids, _ := statedb.GetRestingOrderIds(orderBook); len(ids) != 1 {
+		t.Errorf("resting orders mismatch: have %d, want 1", len(ids))
+	}
+	if ids, _ := statedb.GetStopOrderIds(orderBook); len(ids) != len(stops) {
+		t.Errorf("stop orders mismatch: have %d, want %d", len(ids), len(stops))
+	}
+	for _, tt := range []struct {
+		side  string
+		price int64
+		want  uint64
+	}{{Ask, 100, 0}, {Ask, 95, 3}, {Ask, 80, 3}, {Bid, 100, 0}, {Bid, 110, 5}} {
+		if have := triggered(tt.side, tt.price); have != tt.want {
+			t.Errorf("%s stop triggered at %d: have order %d, want %d", tt.side, tt.price, have, tt.want)
+		}
+	}
+	// The stop orders survive a commit and are cancelled like any order
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	statedb, _ = New(root, stateCache)
+	for _, want := range []uint64{3, 4, 2} {
+		if have := triggered(Ask, 80); have != want {
+			t.Fatalf("sell stop triggered: have order %d, want %d", have, want)
+		}
+		snap := statedb.Snapshot()
+		order := statedb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(want)))
+		if err := statedb.CancelOrder(orderBook, &order); err != nil {
+			t.Fatalf("failed to cancel stop order %d: %v", want, err)
+		}
+		if want == 2 {
+			statedb.RevertToSnapshot(snap)
+			if have := triggered(Ask, 80); have != want {
+				t.Fatalf("reverted stop order mismatch: have order %d, want %d", have, want)
+			}
+		}
+	}
+}
+
+func TestIcebergOrders(t *testing.T) {
+	var (
+		orderBook = common.StringToHash("BTC/TOMO")
+		price     = big.NewInt(100)
+		iceberg   = common.BigToHash(big.NewInt(1))
+		limit     = common.BigToHash(big.NewInt(2))
+	)
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.InsertOrderItem(orderBook, iceberg, OrderItem{OrderID: 1, Quantity: big.NewInt(100), DisplayQuantity: big.NewInt(30), Price: price, Side: Ask, Type: Limit, Signature: &Signature{V: 1}})
+	statedb.InsertOrderItem(orderBook, limit, OrderItem{OrderID: 2, Quantity: big.NewInt(5), Price: price, Side: Ask, Type: Limit, Signature: &Signature{V: 1}})
+
+	check := func(quantity, visible, volume int64) {
+		t.Helper()
+		if have := statedb.GetOrder(orderBook, iceberg).Quantity; have.Cmp(big.NewInt(quantity)) != 0 {
+			t.Errorf("quantity mismatch: have %v, want %d", have, quantity)
+		}
+		if id, amount, _ := statedb.GetBestOrderIdAndAmount(orderBook, price, Ask); visible > 0 && (id != iceberg || amount.Cmp(big.NewInt(visible)) != 0) {
+			t.Errorf("visible quantity mismatch: have order %x amount %v, want %d", id, amount, visible)
+		}
+		if have := statedb.GetVolume(orderBook, price, Ask); have.Cmp(big.NewInt(volume)) != 0 {
+			t.Errorf("volume mismatch: have %v, want %d", have, volume)
+		}
+	}
+	// Only the clip is in the order list, replenished after each fill
+	check(100, 30, 35)
+	statedb.SubAmountOrderItem(orderBook, iceberg, price, big.NewInt(30), Ask)
+	check(70, 30, 35)
+	snap := statedb.Snapshot()
+	statedb.SubAmountOrderItem(orderBook, iceberg, price, big.NewInt(10), Ask)
+	check(60, 30, 35)
+	statedb.SubAmountOrderItem(orderBook, iceberg, price, big.NewInt(30), Ask)
+	statedb.SubAmountOrderItem(orderBook, iceberg, price, big.NewInt(30), Ask)
+	check(0, 0, 5)
+
+	statedb.RevertToSnapshot(snap)
+	check(70, 30, 35)
+	if err := statedb.SubAmountOrderItem(orderBook, iceberg, price, big.NewInt(31), Ask); err == nil {
+		t.Errorf("filled more than the visible quantity")
+	}
+	// Once less than the clip is left, all of it is visible
+	statedb.SubAmountOrderItem(orderBook, iceberg, price, big.NewInt(30), Ask)
+	statedb.SubAmountOrderItem(orderBook, iceberg, price, big.NewInt(30), Ask)
+	check(10, 10, 15)
+
+	order := statedb.GetOrder(orderBook, iceberg)
+	if err := statedb.CancelOrder(orderBook, &order); err != nil {
+		t.Fatalf("failed to cancel iceberg order: %v", err)
+	}
+	check(0, 0, 5)
+}
+
+// Tests that good-till-date orders are indexed by expiry time until they are
+// filled or cancelled, and leave the root of books without such orders unchanged.
+func TestOrderExpiry(t *testing.T) {
+	var (
+		orderBook = common.StringToHash("BTC/TOMO")
+		price     = big.NewInt(100)
+	)
+	order := func(id uint64, expiresAt uint64) OrderItem {
+		return OrderItem{OrderID: id, Hash: common.BigToHash(new(big.Int).SetUint64(id)), Quantity: big.NewInt(10), Price: price, Side: Ask, Type: Limit, ExpiresAt: expiresAt, Signature: &Signature{V: 1}}
+	}
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	plain, _ := New(common.Hash{}, stateCache)
+	plain.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(4)), order(4, 0))
+
+	statedb, _ := New(common.Hash{}, stateCache)
+	statedb.InsertOrderItem(orderBook, common.BigToHash(big.NewInt(4)), order(4, 0))
+	if have, want := statedb.IntermediateRoot(), plain.IntermediateRoot(); have != want {
+		t.Fatalf("root of a book without good-till-date orders changed: have %x, want %x", have, want)
+	}
+	for id, expiresAt := range map[uint64]uint64{1: 100, 2: 100, 3: 200} {
+		statedb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(id)), order(id, expiresAt))
+	}
+	expired := func(time uint64) uint64 {
+		order, ok := statedb.GetExpiredOrder(orderBook, time)
+		if !ok {
+			return 0
+		}
+		return order.OrderID
+	}
+	if have := expired(99); have != 0 {
+		t.Errorf("order %d expired before its time", have)
+	}
+	if have := expired(100); have != 1 {
+		t.Errorf("expired order mismatch: have %d, want 1", have)
+	}
+	// A filled order leaves the index, unless the fill is reverted
+	snap := statedb.Snapshot()
+	if err := statedb.SubAmountOrderItem(orderBook, common.BigToHash(big.NewInt(1)), price, big.NewInt(10), Ask); err != nil {
+		t.Fatalf("failed to fill order: %v", err)
+	}
+	if have := expired(100); have != 2 {
+		t.Errorf("expired order mismatch after fill: have %d, want 2", have)
+	}
+	statedb.RevertToSnapshot(snap)
+	if have := expired(100); have != 1 {
+		t.Errorf("expired order mismatch after revert: have %d, want 1", have)
+	}
+	// So does a cancelled order
+	for _, id := range []uint64{1, 2} {
+		cancelled := statedb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(id)))
+		if err := statedb.CancelOrder(orderBook, &cancelled); err != nil {
+			t.Fatalf("failed to cancel order %d: %v", id, err)
+		}
+	}
+	if have := expired(150); have != 0 {
+		t.Errorf("cancelled order %d still expiring", have)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if statedb, err = New(root, stateCache); err != nil {
+		t.Fatalf("failed to reopen the state: %v", err)
+	}
+	if have := expired(200); have != 3 {
+		t.Errorf("expired order mismatch after commit: have %d, want 3", have)
+	}
+}
+
+// Tests that the cached best prices of a book and oldest orders of its price
+// levels stay in line with the tries through inserts, fills, cancels and
+// reverts.
+func TestBestPriceCache(t *testing.T) {
+	orderBook := common.StringToHash("BTC/TOMO")
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+
+	check := func(step string) {
+		stateObject := statedb.getStateExchangeObject(orderBook)
+		for _, side := range []string{Ask, Bid} {
+			var (
+				have *big.Int
+				key  []byte
+			)
+			if side == Ask {
+				have, _ = statedb.GetBestAskPrice(orderBook)
+				key, _, _ = stateObject.getAsksTrie(statedb.db).TryGetBestLeftKeyAndValue()
+			} else {
+				have, _ = statedb.GetBestBidPrice(orderBook)
+				key, _, _ = stateObject.getBidsTrie(statedb.db).TryGetBestRightKeyAndValue()
+			}
+			if want := new(big.Int).SetBytes(key); have.Cmp(want) != 0 {
+				t.Fatalf("%s: best %s price mismatch: have %v, want %v", step, side, have, want)
+			}
+			if have.Sign() == 0 {
+				continue
+			}
+			orderId, _, err := statedb.GetBestOrderIdAndAmount(orderBook, have, side)
+			if err != nil {
+				t.Fatalf("%s: failed to get best %s order: %v", step, side, err)
+			}
+			var stateOrderList *stateOrderList
+			if side == Ask {
+				stateOrderList = stateObject.getStateOrderListAskObject(statedb.db, common.BigToHash(have))
+			} else {
+				stateOrderList = stateObject.getStateBidOrderListObject(statedb.db, common.BigToHash(have))
+			}
+			key, _, _ = stateOrderList.getTrie(statedb.db).TryGetBestLeftKeyAndValue()
+			if want := common.BytesToHash(key); orderId != want {
+				t.Fatalf("%s: best %s order mismatch: have %x, want %x", step, side, orderId, want)
+			}
+		}
+	}
+	insert := func(id uint64, side string, price int64) {
+		order := OrderItem{OrderID: id, Hash: common.BigToHash(new(big.Int).SetUint64(id)), Quantity: big.NewInt(10), Price: big.NewInt(price), Side: side, Type: Limit, Signature: &Signature{V: 1}}
+		statedb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(id)), order)
+		check(fmt.Sprintf("insert %d", id))
+	}
+	fill := func(id uint64, amount int64) {
+		order := statedb.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(id)))
+		if err := statedb.SubAmountOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(id)), order.Price, big.NewInt(amount), order.Side); err != nil {
+			t.Fatalf("failed to fill order %d: %v", id, err)
+		}
+		check(fmt.Sprintf("fill %d", id))
+	}
+	insert(1, Ask, 10)
+	insert(2, Ask, 10)
+	insert(3, Bid, 5)
+	insert(4, Ask, 8)
+	insert(5, Bid, 7)
+	insert(6, Bid, 6)
+
+	fill(4, 3)
+	snap := statedb.Snapshot()
+	fill(4, 7)
+	fill(1, 10)
+	fill(5, 10)
+	statedb.RevertToSnapshot(snap)
+	check("revert")
+
+	cancelled := statedb.GetOrder(orderBook, common.BigToHash(big.NewInt(3)))
+	if err := statedb.CancelOrder(orderBook, &cancelled); err != nil {
+		t.Fatalf("failed to cancel order: %v", err)
+	}
+	check("cancel")
+	statedb.Finalise()
+	check("finalise")
+	statedb = statedb.Copy()
+	check("copy")
+	fill(5, 10)
+	insert(7, Ask, 9)
+}
+
+func TestSnapshotOrderBook(t *testing.T) {
+	books := []common.Hash{common.StringToHash("BTC/TOMO"), common.StringToHash("ETH/TOMO")}
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, stateCache)
+	orders := []OrderItem{
+		{OrderID: 3, Quantity: big.NewInt(1), Price: big.NewInt(100), Side: Ask, Type: Limit},
+		{OrderID: 1, Quantity: big.NewInt(2), Price: big.NewInt(90), Side: Bid, Type: Limit},
+		{OrderID: 2, Quantity: big.NewInt(1), Price: Zero, Side: Ask, Type: StopMarket, TriggerPrice: big.NewInt(80)},
+	}
+	for _, order := range orders {
+		order.Signature = &Signature{V: 1}
+		statedb.InsertOrderItem(books[0], common.BigToHash(new(big.Int).SetUint64(order.OrderID)), order)
+	}
+	statedb.InsertOrderItem(books[1], common.BigToHash(big.NewInt(1)), OrderItem{OrderID: 1, Quantity: big.NewInt(1), Price: big.NewInt(10), Side: Bid, Type: Limit, Signature: &Signature{V: 1}})
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	statedb, _ = New(root, stateCache)
+	have := statedb.GetOrderBookHashes()
+	if len(have) != len(books) {
+		t.Fatalf("order books mismatch: have %d, want %d", len(have), len(books))
+	}
+	snapshot, err := statedb.SnapshotOrderBook(books[0])
+	if err != nil {
+		t.Fatalf("failed to snapshot order book: %v", err)
+	}
+	if len(snapshot.Orders) != 2 || snapshot.Orders[0].OrderID != 1 || snapshot.Orders[1].OrderID != 3 {
+		t.Errorf("resting orders mismatch: have %+v", snapshot.Orders)
+	}
+	if len(snapshot.StopOrders) != 1 || snapshot.StopOrders[0].OrderID != 2 {
+		t.Errorf("stop orders mismatch: have %+v", snapshot.StopOrders)
+	}
+	if snapshot.Info.BestAsk.Cmp(big.NewInt(100)) != 0 || snapshot.Info.BestBid.Cmp(big.NewInt(90)) != 0 {
+		t.Errorf("best prices mismatch: have %v/%v, want 100/90", snapshot.Info.BestAsk, snapshot.Info.BestBid)
+	}
+}
+
+// Tests that filled and cancelled orders and emptied price levels are removed
+// from the tries and the live objects, even when modified again after the tries
+// were updated by an intermediate root.
+func TestPruneTerminalOrders(t *testing.T) {
+	var (
+		orderBook      = common.StringToHash("BTC/TOMO")
+		price, other   = big.NewInt(100), big.NewInt(200)
+		filled, partly = common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(2))
+		cancelled      = common.BigToHash(big.NewInt(3))
+	)
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, db)
+	statedb.SetPruning(true)
+	statedb.InsertOrderItem(orderBook, filled, OrderItem{OrderID: 1, Quantity: big.NewInt(10), Price: price, Side: Ask, Type: Limit, Signature: &Signature{V: 1}})
+	statedb.InsertOrderItem(orderBook, partly, OrderItem{OrderID: 2, Quantity: big.NewInt(10), Price: price, Side: Ask, Type: Limit, Signature: &Signature{V: 1}})
+	statedb.InsertOrderItem(orderBook, cancelled, OrderItem{OrderID: 3, Quantity: big.NewInt(5), Price: other, Side: Ask, Type: Limit, Signature: &Signature{V: 1}})
+	statedb.IntermediateRoot()
+
+	// The first order is filled across two transactions
+	if err := statedb.SubAmountOrderItem(orderBook, filled, price, big.NewInt(4), Ask); err != nil {
+		t.Fatalf("failed to fill order: %v", err)
+	}
+	statedb.IntermediateRoot()
+	if err := statedb.SubAmountOrderItem(orderBook, filled, price, big.NewInt(6), Ask); err != nil {
+		t.Fatalf("failed to fill order: %v", err)
+	}
+	if err := statedb.SubAmountOrderItem(orderBook, partly, price, big.NewInt(3), Ask); err != nil {
+		t.Fatalf("failed to fill order: %v", err)
+	}
+	order := statedb.GetOrder(orderBook, cancelled)
+	if err := statedb.CancelOrder(orderBook, &order); err != nil {
+		t.Fatalf("failed to cancel order: %v", err)
+	}
+	statedb.IntermediateRoot()
+	exchange := statedb.getStateExchangeObject(orderBook)
+	if len(exchange.stateOrderObjects) != 1 || exchange.stateOrderObjects[partly] == nil {
+		t.Errorf("live orders mismatch: have %d, want the partly filled order only", len(exchange.stateOrderObjects))
+	}
+	if len(exchange.stateAskObjects) != 1 || exchange.stateAskObjects[common.BigToHash(price)] == nil {
+		t.Errorf("live price levels mismatch: have %d, want 1", len(exchange.stateAskObjects))
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	statedb, _ = New(root, db)
+	for _, orderId := range []common.Hash{filled, cancelled} {
+		if order := statedb.GetOrder(orderBook, orderId); !IsEmptyOrder(order) {
+			t.Errorf("order %x left in the trie with quantity %v", orderId, order.Quantity)
+		}
+	}
+	if have := statedb.GetOrder(orderBook, partly).Quantity; have.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("quantity mismatch: have %v, want 7", have)
+	}
+	if have := statedb.GetVolume(orderBook, price, Ask); have.Cmp(big.NewInt(7)) != 0 {
+		t.Errorf("volume mismatch: have %v, want 7", have)
+	}
+	if best, _ := statedb.GetBestAskPrice(orderBook); best.Cmp(price) != 0 {
+		t.Errorf("best ask mismatch: have %v, want %v", best, price)
+	}
+	if ids, _ := statedb.GetRestingOrderIds(orderBook); len(ids) != 1 || ids[0] != partly {
+		t.Errorf("resting orders mismatch: have %x", ids)
+	}
+}
+
+// Tests that the state isn't pruned before the TIPTomoXPrune fork, so that the
+// roots of the blocks already on the chain are unchanged: an order filled again
+// after the tries were updated keeps its former entry.
+func TestNoPruneBeforeFork(t *testing.T) {
+	var (
+		orderBook = common.StringToHash("BTC/TOMO")
+		price     = big.NewInt(100)
+		orderId   = common.BigToHash(big.NewInt(1))
+	)
+	for _, prune := range []bool{false, true} {
+		statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+		statedb.SetPruning(prune)
+		statedb.InsertOrderItem(orderBook, orderId, OrderItem{OrderID: 1, Quantity: big.NewInt(10), Price: price, Side: Ask, Type: Limit, Signature: &Signature{V: 1}})
+		statedb.IntermediateRoot()
+
+		// The order is partly filled across two transactions
+		if err := statedb.SubAmountOrderItem(orderBook, orderId, price, big.NewInt(4), Ask); err != nil {
+			t.Fatalf("failed to fill order: %v", err)
+		}
+		first := statedb.IntermediateRoot()
+		if err := statedb.SubAmountOrderItem(orderBook, orderId, price, big.NewInt(3), Ask); err != nil {
+			t.Fatalf("failed to fill order: %v", err)
+		}
+		root, err := statedb.Commit()
+		if err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+		if legacy := root == first; legacy == prune {
+			t.Errorf("prune %v: root mismatch: have %x, legacy root %x", prune, root, first)
+		}
+	}
+}
@@ -24,6 +24,7 @@ const (
 	MakerOrderType      = "makerOrderType"
 	MakerFee            = "makerFee"
 	TakerFee            = "takerFee"
+	MakerRebate         = "makerRebate"
 )
 
 type Trade struct {
@@ -41,6 +42,7 @@ type Trade struct {
 	Amount         *big.Int       `json:"amount" bson:"amount"`
 	MakeFee        *big.Int       `json:"makeFee" bson:"makeFee"`
 	TakeFee        *big.Int       `json:"takeFee" bson:"takeFee"`
+	MakerRebate    *big.Int       `json:"makerRebate,omitempty" bson:"makerRebate,omitempty"` // rebate paid to the maker out of the taker fee
 	Status         string         `json:"status" bson:"status"`
 	CreatedAt      time.Time      `json:"createdAt" bson:"createdAt"`
 	UpdatedAt      time.Time      `json:"updatedAt" bson:"updatedAt"`
@@ -63,6 +65,7 @@ type TradeBSON struct {
 	Amount         string    `json:"amount" bson:"amount"`
 	MakeFee        string    `json:"makeFee" bson:"makeFee"`
 	TakeFee        string    `json:"takeFee" bson:"takeFee"`
+	MakerRebate    string    `json:"makerRebate,omitempty" bson:"makerRebate,omitempty"`
 	PricePoint     string    `json:"pricepoint" bson:"pricepoint"`
 	Status         string    `json:"status" bson:"status"`
 	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
@@ -95,6 +98,9 @@ func (t *Trade) GetBSON() (interface{}, error) {
 		TakerOrderType: t.TakerOrderType,
 		MakerOrderType: t.MakerOrderType,
 	}
+	if t.MakerRebate != nil {
+		tr.MakerRebate = t.MakerRebate.String()
+	}
 
 	return tr, nil
 }
@@ -123,6 +129,9 @@ func (t *Trade) SetBSON(raw bson.Raw) error {
 
 	t.MakeFee = ToBigInt(decoded.MakeFee)
 	t.TakeFee = ToBigInt(decoded.TakeFee)
+	if decoded.MakerRebate != "" {
+		t.MakerRebate = ToBigInt(decoded.MakerRebate)
+	}
 
 	t.CreatedAt = decoded.CreatedAt
 	t.UpdatedAt = decoded.UpdatedAt
@@ -0,0 +1,130 @@
+package tomoxDAO
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+)
+
+const (
+	kafkaContentType    = "application/vnd.kafka.json.v2+json"
+	kafkaRequestTimeout = 30 * time.Second
+)
+
+// kafkaMessage is a message produced through the Kafka REST proxy, keyed by the
+// hash of its record so that the writes of a record stay in order.
+type kafkaMessage struct {
+	Key   string     `json:"key"`
+	Value sinkRecord `json:"value"`
+}
+
+// KafkaSink reports the records of an SDK node to Kafka through a Kafka REST
+// proxy, a topic per collection named after the topic prefix.
+type KafkaSink struct {
+	url    string
+	prefix string
+	client *http.Client
+
+	lock    sync.Mutex
+	topics  []string // topics in the order of their first pending message
+	pending map[string][]kafkaMessage
+}
+
+// NewKafkaSink creates a sink producing to the Kafka REST proxy at the given
+// URL, in the topics named by the prefix followed by the collection.
+func NewKafkaSink(url string, prefix string) *KafkaSink {
+	return &KafkaSink{
+		url:     strings.TrimRight(url, "/"),
+		prefix:  prefix,
+		client:  &http.Client{Timeout: kafkaRequestTimeout},
+		pending: make(map[string][]kafkaMessage),
+	}
+}
+
+func (s *KafkaSink) add(val interface{}, record sinkRecord) {
+	collection, ok := collectionOf(val)
+	if !ok {
+		log.Error("KafkaSink: unknown type of object", "val", val)
+		return
+	}
+	topic := s.prefix + collection
+	key := record.Hash
+	if record.Op == sinkOpDeleteByTxHash {
+		key = record.TxHash
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.pending[topic]; !ok {
+		s.topics = append(s.topics, topic)
+	}
+	s.pending[topic] = append(s.pending[topic], kafkaMessage{Key: key.Hex(), Value: record})
+}
+
+func (s *KafkaSink) PutObject(hash common.Hash, val interface{}) error {
+	s.add(val, sinkRecord{Op: sinkOpPut, Hash: hash, TxHash: txHashOf(val), Data: val})
+	return nil
+}
+
+func (s *KafkaSink) DeleteObject(hash common.Hash, val interface{}) error {
+	s.add(val, sinkRecord{Op: sinkOpDelete, Hash: hash})
+	return nil
+}
+
+func (s *KafkaSink) DeleteItemByTxHash(txhash common.Hash, val interface{}) {
+	s.add(val, sinkRecord{Op: sinkOpDeleteByTxHash, TxHash: txhash})
+}
+
+func (s *KafkaSink) InitBulk() {}
+
+func (s *KafkaSink) InitLendingBulk() {}
+
+// CommitBulk produces the buffered messages, a request per topic.
+func (s *KafkaSink) CommitBulk() error {
+	s.lock.Lock()
+	topics, pending := s.topics, s.pending
+	s.topics, s.pending = nil, make(map[string][]kafkaMessage)
+	s.lock.Unlock()
+
+	for _, topic := range topics {
+		if err := s.produce(topic, pending[topic]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *KafkaSink) CommitLendingBulk() error {
+	return s.CommitBulk()
+}
+
+// produce sends messages to a topic of the REST proxy.
+func (s *KafkaSink) produce(topic string, messages []kafkaMessage) error {
+	body, err := json.Marshal(map[string]interface{}{"records": messages})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url+"/topics/"+topic, kafkaContentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		reply, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("kafka rest proxy: failed to produce to %s: %s: %s", topic, resp.Status, reply)
+	}
+	return nil
+}
+
+func (s *KafkaSink) Close() error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package tomoxDAO
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sync"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS tomox_records (
+	collection TEXT NOT NULL,
+	hash       TEXT NOT NULL,
+	tx_hash    TEXT NOT NULL,
+	data       JSONB NOT NULL,
+	PRIMARY KEY (collection, hash)
+);
+CREATE INDEX IF NOT EXISTS tomox_records_tx_hash ON tomox_records (collection, tx_hash);`
+
+// postgresStatement is a write buffered until the bulk is committed.
+type postgresStatement struct {
+	query string
+	args  []interface{}
+}
+
+// PostgresSink reports the records of an SDK node to a PostgreSQL table, a row
+// per record holding it as JSON.
+type PostgresSink struct {
+	db      *sql.DB
+	lock    sync.Mutex
+	pending []postgresStatement
+}
+
+// NewPostgresSink connects to the PostgreSQL database at the given connection
+// string and creates the table of the records if needed.
+func NewPostgresSink(dsn string) (*PostgresSink, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(postgresSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PostgresSink{db: db}, nil
+}
+
+func (s *PostgresSink) add(query string, args ...interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.pending = append(s.pending, postgresStatement{query: query, args: args})
+}
+
+func (s *PostgresSink) PutObject(hash common.Hash, val interface{}) error {
+	collection, ok := collectionOf(val)
+	if !ok {
+		log.Error("PostgresSink: unknown type of object", "val", val)
+		return nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	s.add(`INSERT INTO tomox_records (collection, hash, tx_hash, data) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (collection, hash) DO UPDATE SET tx_hash = EXCLUDED.tx_hash, data = EXCLUDED.data`,
+		collection, hash.Hex(), txHashOf(val).Hex(), string(data))
+	return nil
+}
+
+func (s *PostgresSink) DeleteObject(hash common.Hash, val interface{}) error {
+	if collection, ok := collectionOf(val); ok {
+		s.add(`DELETE FROM tomox_records WHERE collection = $1 AND hash = $2`, collection, hash.Hex())
+	}
+	return nil
+}
+
+func (s *PostgresSink) DeleteItemByTxHash(txhash common.Hash, val interface{}) {
+	if collection, ok := collectionOf(val); ok {
+		s.add(`DELETE FROM tomox_records WHERE collection = $1 AND tx_hash = $2`, collection, txhash.Hex())
+	}
+}
+
+func (s *PostgresSink) InitBulk() {}
+
+func (s *PostgresSink) InitLendingBulk() {}
+
+// CommitBulk writes the buffered records in a single transaction.
+func (s *PostgresSink) CommitBulk() error {
+	s.lock.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.lock.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range pending {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresSink) CommitLendingBulk() error {
+	return s.CommitBulk()
+}
+
+func (s *PostgresSink) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,178 @@
+package tomoxDAO
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Sink is a destination the SDK node reports the orders, trades, lending items,
+// lending trades and epoch prices it records to. Writes are buffered from
+// InitBulk or InitLendingBulk on, and flushed by CommitBulk or CommitLendingBulk.
+// MongoDatabase is a Sink.
+type Sink interface {
+	PutObject(hash common.Hash, val interface{}) error
+	DeleteObject(hash common.Hash, val interface{}) error
+	DeleteItemByTxHash(txhash common.Hash, val interface{})
+
+	InitBulk()
+	CommitBulk() error
+	InitLendingBulk()
+	CommitLendingBulk() error
+
+	Close() error
+}
+
+var (
+	_ Sink = (*MongoDatabase)(nil)
+	_ Sink = (*PostgresSink)(nil)
+	_ Sink = (*KafkaSink)(nil)
+)
+
+// sinkRecord is a write reported to a sink streaming the records.
+type sinkRecord struct {
+	Op     string      `json:"op"` // put, delete or deleteByTxHash
+	Hash   common.Hash `json:"hash"`
+	TxHash common.Hash `json:"txHash"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+const (
+	sinkOpPut            = "put"
+	sinkOpDelete         = "delete"
+	sinkOpDeleteByTxHash = "deleteByTxHash"
+)
+
+// collectionOf returns the collection a record is stored in, as MongoDB does,
+// or false if the record is of an unknown type.
+func collectionOf(val interface{}) (string, bool) {
+	switch val := val.(type) {
+	case *tradingstate.OrderItem:
+		return ordersCollection, true
+	case *tradingstate.Trade:
+		return tradesCollection, true
+	case *tradingstate.EpochPriceItem:
+		return epochPriceCollection, true
+	case *lendingstate.LendingTrade:
+		return lendingTradesCollection, true
+	case *lendingstate.LendingItem:
+		switch val.Type {
+		case lendingstate.Repay:
+			return lendingRepayCollection, true
+		case lendingstate.TopUp:
+			return lendingTopUpCollection, true
+		case lendingstate.Recall:
+			return lendingRecallCollection, true
+		default:
+			return lendingItemsCollection, true
+		}
+	}
+	return "", false
+}
+
+// txHashOf returns the hash of the transaction a record was settled by.
+func txHashOf(val interface{}) common.Hash {
+	switch val := val.(type) {
+	case *tradingstate.OrderItem:
+		return val.TxHash
+	case *tradingstate.Trade:
+		return val.TxHash
+	case *lendingstate.LendingTrade:
+		return val.TxHash
+	case *lendingstate.LendingItem:
+		return val.TxHash
+	}
+	return common.Hash{}
+}
+
+// ReportingDatabase is the database of an SDK node reporting its records to
+// sinks besides the database they are read back from.
+type ReportingDatabase struct {
+	TomoXDAO
+	sinks []Sink
+}
+
+// NewReportingDatabase wraps db so that the records written to it are also
+// reported to the given sinks.
+func NewReportingDatabase(db TomoXDAO, sinks ...Sink) *ReportingDatabase {
+	return &ReportingDatabase{TomoXDAO: db, sinks: sinks}
+}
+
+func (db *ReportingDatabase) PutObject(hash common.Hash, val interface{}) error {
+	if err := db.TomoXDAO.PutObject(hash, val); err != nil {
+		return err
+	}
+	for _, sink := range db.sinks {
+		if err := sink.PutObject(hash, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *ReportingDatabase) DeleteObject(hash common.Hash, val interface{}) error {
+	if err := db.TomoXDAO.DeleteObject(hash, val); err != nil {
+		return err
+	}
+	for _, sink := range db.sinks {
+		if err := sink.DeleteObject(hash, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *ReportingDatabase) DeleteItemByTxHash(txhash common.Hash, val interface{}) {
+	db.TomoXDAO.DeleteItemByTxHash(txhash, val)
+	for _, sink := range db.sinks {
+		sink.DeleteItemByTxHash(txhash, val)
+	}
+}
+
+func (db *ReportingDatabase) InitBulk() {
+	db.TomoXDAO.InitBulk()
+	for _, sink := range db.sinks {
+		sink.InitBulk()
+	}
+}
+
+func (db *ReportingDatabase) CommitBulk() error {
+	if err := db.TomoXDAO.CommitBulk(); err != nil {
+		return err
+	}
+	for _, sink := range db.sinks {
+		if err := sink.CommitBulk(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *ReportingDatabase) InitLendingBulk() {
+	db.TomoXDAO.InitLendingBulk()
+	for _, sink := range db.sinks {
+		sink.InitLendingBulk()
+	}
+}
+
+func (db *ReportingDatabase) CommitLendingBulk() error {
+	if err := db.TomoXDAO.CommitLendingBulk(); err != nil {
+		return err
+	}
+	for _, sink := range db.sinks {
+		if err := sink.CommitLendingBulk(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *ReportingDatabase) Close() error {
+	for _, sink := range db.sinks {
+		if err := sink.Close(); err != nil {
+			log.Error("Failed to close reporting sink", "err", err)
+		}
+	}
+	return db.TomoXDAO.Close()
+}
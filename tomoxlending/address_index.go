@@ -0,0 +1,128 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxDAO"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+const tradeIndexName = "lending-trades"
+
+// LendingTradeRecord is a lending trade opened by a lending transaction, indexed
+// under the addresses of its borrower and investor.
+type LendingTradeRecord struct {
+	TradeId         uint64         `json:"tradeId"`
+	Hash            common.Hash    `json:"hash"`
+	Borrower        common.Address `json:"borrower"`
+	Investor        common.Address `json:"investor"`
+	LendingToken    common.Address `json:"lendingToken"`
+	CollateralToken common.Address `json:"collateralToken"`
+	Term            uint64         `json:"term"`
+	Interest        uint64         `json:"interest"`
+	Amount          *big.Int       `json:"amount"`
+	TxHash          common.Hash    `json:"txHash"`
+	BlockNumber     uint64         `json:"blockNumber"`
+	BlockHash       common.Hash    `json:"blockHash"`
+}
+
+func newTradeIndex(db tomoxDAO.TomoXDAO) *tomox.AddressIndex {
+	return tomox.NewAddressIndex(db, tradeIndexName)
+}
+
+// tradeRecords returns the records of the lending trades opened in a block,
+// under the borrower and under the investor of each.
+func tradeRecords(block *types.Block, trades []*lendingstate.LendingTrade) ([]tomox.AddressRecord, error) {
+	var records []tomox.AddressRecord
+	for _, trade := range trades {
+		record := LendingTradeRecord{
+			TradeId:         trade.TradeId,
+			Hash:            trade.Hash,
+			Borrower:        trade.Borrower,
+			Investor:        trade.Investor,
+			LendingToken:    trade.LendingToken,
+			CollateralToken: trade.CollateralToken,
+			Term:            trade.Term,
+			Interest:        trade.Interest,
+			Amount:          new(big.Int),
+			TxHash:          trade.TxHash,
+			BlockNumber:     block.NumberU64(),
+			BlockHash:       block.Hash(),
+		}
+		if trade.Amount != nil {
+			record.Amount.Set(trade.Amount)
+		}
+		blob, err := rlp.EncodeToBytes(&record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, tomox.AddressRecord{Address: trade.Borrower, Value: blob})
+		if trade.Investor != trade.Borrower {
+			records = append(records, tomox.AddressRecord{Address: trade.Investor, Value: blob})
+		}
+	}
+	return records, nil
+}
+
+// IndexLendingTrades indexes the lending trades opened in a canonical block by
+// the addresses of their borrowers and investors.
+func (l *Lending) IndexLendingTrades(block *types.Block, trades []*lendingstate.LendingTrade) error {
+	if l.tradeIndex == nil {
+		return tomox.ErrAddressIndexDisabled
+	}
+	records, err := tradeRecords(block, trades)
+	if err != nil {
+		return err
+	}
+	return l.tradeIndex.Index(block, records)
+}
+
+// RollbackTradeIndex deletes the lending trades indexed by a block reorganised
+// away.
+func (l *Lending) RollbackTradeIndex(block *types.Block) error {
+	if l.tradeIndex == nil {
+		return tomox.ErrAddressIndexDisabled
+	}
+	return l.tradeIndex.Rollback(block)
+}
+
+// GetTradesByAddress returns up to limit lending trades of a borrower or
+// investor opened in the blocks from the given number on.
+func (l *Lending) GetTradesByAddress(addr common.Address, from uint64, limit int) ([]*LendingTradeRecord, error) {
+	if l.tradeIndex == nil {
+		return nil, tomox.ErrAddressIndexDisabled
+	}
+	blobs, err := l.tradeIndex.Records(addr, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]*LendingTradeRecord, 0, len(blobs))
+	for _, blob := range blobs {
+		trade := new(LendingTradeRecord)
+		if err := rlp.DecodeBytes(blob, trade); err != nil {
+			return nil, err
+		}
+		trades = append(trades, trade)
+	}
+	return trades, nil
+}
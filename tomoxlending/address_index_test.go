@@ -0,0 +1,43 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestTradeIndex(t *testing.T) {
+	var (
+		lending  = &Lending{tradeIndex: tomox.NewAddressIndex(rawdb.NewMemoryDatabase(), tradeIndexName)}
+		borrower = common.HexToAddress("0x01")
+		investor = common.HexToAddress("0x02")
+		block    = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)})
+	)
+	trade := &lendingstate.LendingTrade{Borrower: borrower, Investor: investor, TradeId: 4, Term: 86400, Interest: 3, Amount: big.NewInt(1000), Hash: common.HexToHash("0xc1"), TxHash: common.HexToHash("0xf1")}
+	if err := lending.IndexLendingTrades(block, []*lendingstate.LendingTrade{trade}); err != nil {
+		t.Fatalf("failed to index trades: %v", err)
+	}
+	for _, addr := range []common.Address{borrower, investor} {
+		trades, err := lending.GetTradesByAddress(addr, 0, tomox.MaxAddressRecordsPerRequest)
+		if err != nil {
+			t.Fatalf("failed to get trades: %v", err)
+		}
+		if len(trades) != 1 || trades[0].TradeId != 4 || trades[0].Amount.Int64() != 1000 || trades[0].BlockNumber != 7 || trades[0].TxHash != trade.TxHash {
+			t.Errorf("trades of %x mismatch: have %v", addr, trades)
+		}
+	}
+	if trades, _ := lending.GetTradesByAddress(borrower, 8, tomox.MaxAddressRecordsPerRequest); len(trades) != 0 {
+		t.Errorf("trades from a later block mismatch: have %v", trades)
+	}
+	if err := lending.RollbackTradeIndex(block); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if trades, _ := lending.GetTradesByAddress(investor, 0, tomox.MaxAddressRecordsPerRequest); len(trades) != 0 {
+		t.Errorf("trades after rollback mismatch: have %v", trades)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox"
 )
 
 // List of errors
@@ -35,3 +38,10 @@ func NewPublicTomoXLendingAPI(t *Lending) *PublicTomoXLendingAPI {
 func (api *PublicTomoXLendingAPI) Version(ctx context.Context) string {
 	return ProtocolVersionStr
 }
+
+// GetTradesByAddress returns the lending trades of a borrower or investor
+// opened in the blocks from the given number on, oldest first, up to
+// tomox.MaxAddressRecordsPerRequest.
+func (api *PublicTomoXLendingAPI) GetTradesByAddress(ctx context.Context, address common.Address, fromBlock uint64) ([]*LendingTradeRecord, error) {
+	return api.t.GetTradesByAddress(address, fromBlock, tomox.MaxAddressRecordsPerRequest)
+}
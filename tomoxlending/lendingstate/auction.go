@@ -0,0 +1,79 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// LendingAuction is the descending-price auction of the collateral locked by a
+// liquidated lending trade. The price of the whole collateral, in lending token,
+// descends linearly from StartPrice at StartBlock to FloorPrice at EndBlock, and
+// stays at the floor until the auction is closed. The first bid at the current
+// price buys the collateral, repaying the investor up to Debt and the borrower
+// the rest.
+type LendingAuction struct {
+	StartBlock uint64
+	EndBlock   uint64
+	StartPrice *big.Int
+	FloorPrice *big.Int
+	Debt       *big.Int
+	Reason     uint64 // reason of the liquidation which opened the auction
+}
+
+// NewLendingAuction opens at the given block the auction of a collateral of the
+// given value, securing the given debt.
+func NewLendingAuction(number uint64, collateralValue, debt *big.Int, reason uint64) *LendingAuction {
+	startPrice := new(big.Int).Mul(collateralValue, common.AuctionStartRate)
+	startPrice = new(big.Int).Div(startPrice, common.BaseAuction)
+	floorPrice := new(big.Int).Mul(collateralValue, common.AuctionFloorRate)
+	floorPrice = new(big.Int).Div(floorPrice, common.BaseAuction)
+	return &LendingAuction{
+		StartBlock: number,
+		EndBlock:   number + common.LendingAuctionBlocks,
+		StartPrice: startPrice,
+		FloorPrice: floorPrice,
+		Debt:       CloneBigInt(debt),
+		Reason:     reason,
+	}
+}
+
+// Price returns the price of the collateral at the given block.
+func (a *LendingAuction) Price(number uint64) *big.Int {
+	if number >= a.EndBlock || a.StartPrice.Cmp(a.FloorPrice) <= 0 {
+		return CloneBigInt(a.FloorPrice)
+	}
+	if number <= a.StartBlock {
+		return CloneBigInt(a.StartPrice)
+	}
+	// price = StartPrice - (StartPrice - FloorPrice) * elapsed / duration
+	discount := new(big.Int).Sub(a.StartPrice, a.FloorPrice)
+	discount = new(big.Int).Mul(discount, new(big.Int).SetUint64(number-a.StartBlock))
+	discount = new(big.Int).Div(discount, new(big.Int).SetUint64(a.EndBlock-a.StartBlock))
+	return new(big.Int).Sub(a.StartPrice, discount)
+}
+
+// Proceeds splits the price paid for the collateral between the investor, who
+// is repaid the debt first, and the borrower.
+func (a *LendingAuction) Proceeds(price *big.Int) (repaid, surplus *big.Int) {
+	if price.Cmp(a.Debt) <= 0 {
+		return CloneBigInt(price), new(big.Int)
+	}
+	return CloneBigInt(a.Debt), new(big.Int).Sub(price, a.Debt)
+}
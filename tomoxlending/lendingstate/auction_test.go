@@ -0,0 +1,54 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+func TestLendingAuctionPrice(t *testing.T) {
+	// A collateral worth 1000 opens at 1050 and descends to 800
+	auction := NewLendingAuction(100, big.NewInt(1000), big.NewInt(900), LiquidatedByPrice)
+	if auction.EndBlock != 100+common.LendingAuctionBlocks {
+		t.Fatalf("end block mismatch: have %d, want %d", auction.EndBlock, 100+common.LendingAuctionBlocks)
+	}
+	tests := []struct {
+		number uint64
+		want   int64
+	}{
+		{50, 1050},
+		{100, 1050},
+		{100 + common.LendingAuctionBlocks/2, 925},
+		{100 + common.LendingAuctionBlocks, 800},
+		{100 + 2*common.LendingAuctionBlocks, 800},
+	}
+	for _, tt := range tests {
+		if have := auction.Price(tt.number); have.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("price at block %d mismatch: have %v, want %d", tt.number, have, tt.want)
+		}
+	}
+	// The investor is repaid the debt first, the borrower gets the rest
+	if repaid, surplus := auction.Proceeds(big.NewInt(1000)); repaid.Int64() != 900 || surplus.Int64() != 100 {
+		t.Errorf("proceeds mismatch: repaid %v, surplus %v", repaid, surplus)
+	}
+	if repaid, surplus := auction.Proceeds(big.NewInt(850)); repaid.Int64() != 850 || surplus.Sign() != 0 {
+		t.Errorf("proceeds below the debt mismatch: repaid %v, surplus %v", repaid, surplus)
+	}
+}
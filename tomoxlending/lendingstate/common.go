@@ -42,8 +42,9 @@ type lendingObject struct {
 
 // liquidation reasons
 const (
-	LiquidatedByTime  = uint64(0)
-	LiquidatedByPrice = uint64(1)
+	LiquidatedByTime    = uint64(0)
+	LiquidatedByPrice   = uint64(1)
+	LiquidatedByAuction = uint64(2)
 )
 
 type LiquidationData struct {
@@ -94,6 +95,7 @@ type FinalizedResult struct {
 	AutoRepay  []common.Hash
 	AutoTopUp  []common.Hash
 	AutoRecall []common.Hash
+	AutoRenew  []common.Hash
 	TxHash     common.Hash
 	Timestamp  int64
 }
@@ -206,11 +208,12 @@ func WeiToEther(amount *big.Int) *big.Int {
 	return new(big.Int).Div(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
 }
 
-func EncodeFinalizedResult(liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades []*LendingTrade) ([]byte, error) {
+func EncodeFinalizedResult(liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades []*LendingTrade) ([]byte, error) {
 	liquidatedHashes := []common.Hash{}
 	autoRepayHashes := []common.Hash{}
 	autoTopUpHashes := []common.Hash{}
 	autoRecallHashes := []common.Hash{}
+	autoRenewHashes := []common.Hash{}
 
 	for _, trade := range liquidatedTrades {
 		liquidatedHashes = append(liquidatedHashes, trade.Hash)
@@ -224,11 +227,15 @@ func EncodeFinalizedResult(liquidatedTrades, autoRepayTrades, autoTopUpTrades, a
 	for _, trade := range autoRecallTrades {
 		autoRecallHashes = append(autoRecallHashes, trade.Hash)
 	}
+	for _, trade := range autoRenewTrades {
+		autoRenewHashes = append(autoRenewHashes, trade.Hash)
+	}
 	result := FinalizedResult{
 		Liquidated: liquidatedHashes,
 		AutoRepay:  autoRepayHashes,
 		AutoTopUp:  autoTopUpHashes,
 		AutoRecall: autoRecallHashes,
+		AutoRenew:  autoRenewHashes,
 		Timestamp:  time.Now().UnixNano(),
 	}
 	data, err := json.Marshal(result)
@@ -36,6 +36,9 @@ const (
 
 	// Number of codehash->size associations to keep.
 	codeSizeCacheSize = 100000
+
+	// Number of liquidation time trie root->queue associations to keep.
+	liquidationTimesCacheSize = 256
 )
 
 // Database wraps access to tries and contract code.
@@ -57,6 +60,14 @@ type Database interface {
 
 	// TrieDB retrieves the low level trie database used for data storage.
 	TrieDB() *trie.Database
+
+	// liquidationTimes retrieves a copy of the cached liquidation time queue of
+	// the given liquidation time trie root.
+	liquidationTimes(root common.Hash) (*liquidationTimeQueue, bool)
+
+	// cacheLiquidationTimes stores a copy of the liquidation time queue of the
+	// given liquidation time trie root.
+	cacheLiquidationTimes(root common.Hash, queue *liquidationTimeQueue)
 }
 
 // Trie is a Ethereum Merkle Trie.
@@ -78,18 +89,27 @@ type Trie interface {
 // intermediate trie-node memory pool between the low level storage layer and the
 // high level trie abstraction.
 func NewDatabase(db ethdb.Database) Database {
+	return NewDatabaseWithConfig(db, nil)
+}
+
+// NewDatabaseWithConfig creates a backing store for state with the given trie
+// database options.
+func NewDatabaseWithConfig(db ethdb.Database, config *trie.Config) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
+	ltc, _ := lru.New(liquidationTimesCacheSize)
 	return &cachingDB{
-		db:            trie.NewDatabase(db),
-		codeSizeCache: csc,
+		db:                    trie.NewDatabaseWithConfig(db, config),
+		codeSizeCache:         csc,
+		liquidationTimesCache: ltc,
 	}
 }
 
 type cachingDB struct {
-	db            *trie.Database
-	mu            sync.Mutex
-	pastTries     []*TomoXTrie
-	codeSizeCache *lru.Cache
+	db                    *trie.Database
+	mu                    sync.Mutex
+	pastTries             []*TomoXTrie
+	codeSizeCache         *lru.Cache
+	liquidationTimesCache *lru.Cache
 }
 
 // OpenTrie opens the main account trie.
@@ -137,3 +157,18 @@ func (db *cachingDB) ContractCodeSize(addrHash, codeHash common.Hash) (int, erro
 func (db *cachingDB) TrieDB() *trie.Database {
 	return db.db
 }
+
+// liquidationTimes retrieves a copy of the cached liquidation time queue of the
+// given liquidation time trie root.
+func (db *cachingDB) liquidationTimes(root common.Hash) (*liquidationTimeQueue, bool) {
+	if cached, ok := db.liquidationTimesCache.Get(root); ok {
+		return cached.(*liquidationTimeQueue).copy(), true
+	}
+	return nil, false
+}
+
+// cacheLiquidationTimes stores a copy of the liquidation time queue of the given
+// liquidation time trie root.
+func (db *cachingDB) cacheLiquidationTimes(root common.Hash, queue *liquidationTimeQueue) {
+	db.liquidationTimesCache.Add(root, queue.copy())
+}
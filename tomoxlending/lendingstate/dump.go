@@ -415,3 +415,28 @@ func (self *LendingStateDB) DumpLendingTradeTrie(orderBook common.Hash) (map[*bi
 	}
 	return result, nil
 }
+
+// GetOpenLendingTrades returns the open trades of a lending book, by trade id.
+func (self *LendingStateDB) GetOpenLendingTrades(orderBook common.Hash) ([]LendingTrade, error) {
+	if self.getLendingExchange(orderBook) == nil {
+		return nil, nil
+	}
+	trades, err := self.DumpLendingTradeTrie(orderBook)
+	if err != nil {
+		return nil, err
+	}
+	listTradeId := []*big.Int{}
+	for tradeId, trade := range trades {
+		if trade.Amount != nil && trade.Amount.Sign() > 0 {
+			listTradeId = append(listTradeId, tradeId)
+		}
+	}
+	sort.Slice(listTradeId, func(i, j int) bool {
+		return listTradeId[i].Cmp(listTradeId[j]) < 0
+	})
+	result := make([]LendingTrade, 0, len(listTradeId))
+	for _, tradeId := range listTradeId {
+		result = append(result, trades[tradeId])
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+// UtilizationDecimal is the utilization of a lending book whose funds are all
+// lent out, utilizations being percentages in the units of the interest rates.
+var UtilizationDecimal = new(big.Int).Mul(common.BaseLendingInterest, big.NewInt(100))
+
+// InterestRateModel prices the loans of a lending book.
+type InterestRateModel interface {
+	// Rate returns the annual interest rate of a loan agreed at the given rate,
+	// while the given share of the funds of its lending book is lent out.
+	Rate(agreedRate uint64, utilization *big.Int) uint64
+}
+
+// FixedRateModel keeps the rate agreed when an offer of the rate ladder of the
+// book was matched, for the whole term of the loan.
+type FixedRateModel struct{}
+
+// Rate implements InterestRateModel, returning the agreed rate.
+func (FixedRateModel) Rate(agreedRate uint64, utilization *big.Int) uint64 {
+	return agreedRate
+}
+
+// UtilizationRateModel prices loans from the utilization of their lending book:
+// the rate rises from BaseRate by Slope over the full utilization up to Kink,
+// and by JumpSlope over the full utilization beyond.
+type UtilizationRateModel struct {
+	BaseRate  uint64
+	Slope     uint64
+	Kink      uint64
+	JumpSlope uint64
+}
+
+// Rate implements InterestRateModel, ignoring the agreed rate.
+func (m UtilizationRateModel) Rate(agreedRate uint64, utilization *big.Int) uint64 {
+	if utilization.Cmp(UtilizationDecimal) > 0 {
+		utilization = UtilizationDecimal
+	}
+	kink := new(big.Int).SetUint64(m.Kink)
+	normal, excess := utilization, new(big.Int)
+	if utilization.Cmp(kink) > 0 {
+		normal, excess = kink, new(big.Int).Sub(utilization, kink)
+	}
+	rate := new(big.Int).Mul(normal, new(big.Int).SetUint64(m.Slope))
+	rate = rate.Add(rate, new(big.Int).Mul(excess, new(big.Int).SetUint64(m.JumpSlope)))
+	rate = rate.Div(rate, UtilizationDecimal)
+	return m.BaseRate + rate.Uint64()
+}
+
+// GetInterestRateModel returns the interest rate model of a lending book, as
+// set in the lending contract, the fixed rate model if none is.
+func GetInterestRateModel(statedb *state.StateDB, lendingBook common.Hash) InterestRateModel {
+	modelState := GetLocMappingAtKey(lendingBook, InterestRateModelSlot)
+	get := func(field string) uint64 {
+		loc := state.GetLocOfStructElement(modelState, InterestRateModelStructSlots[field])
+		return statedb.GetState(common.HexToAddress(common.LendingRegistrationSMC), loc).Big().Uint64()
+	}
+	model := UtilizationRateModel{
+		BaseRate:  get("baseRate"),
+		Slope:     get("slope"),
+		Kink:      get("kink"),
+		JumpSlope: get("jumpSlope"),
+	}
+	if model.BaseRate == 0 && model.Slope == 0 && model.JumpSlope == 0 {
+		return FixedRateModel{}
+	}
+	return model
+}
+
+// CalculateUtilization returns the share of the funds of a lending book lent
+// out, given the principal of its open loans and the amount of its offers.
+func CalculateUtilization(borrowed, offered *big.Int) *big.Int {
+	supplied := new(big.Int).Add(borrowed, offered)
+	if supplied.Sign() == 0 {
+		return new(big.Int)
+	}
+	utilization := new(big.Int).Mul(borrowed, UtilizationDecimal)
+	return utilization.Div(utilization, supplied)
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+func TestUtilizationRateModel(t *testing.T) {
+	// 2% base rate, rising by 10% up to 80% utilization and by 100% beyond
+	model := UtilizationRateModel{BaseRate: 2 * 1e8, Slope: 10 * 1e8, Kink: 80 * 1e8, JumpSlope: 100 * 1e8}
+	tests := []struct {
+		utilization int64
+		want        uint64
+	}{
+		{0, 2 * 1e8},
+		{50 * 1e8, 7 * 1e8},
+		{80 * 1e8, 10 * 1e8},
+		{90 * 1e8, 20 * 1e8},
+		{100 * 1e8, 30 * 1e8},
+		{200 * 1e8, 30 * 1e8},
+	}
+	for _, tt := range tests {
+		if have := model.Rate(5*1e8, big.NewInt(tt.utilization)); have != tt.want {
+			t.Errorf("utilization %d: rate mismatch: have %d, want %d", tt.utilization, have, tt.want)
+		}
+	}
+	if have := (FixedRateModel{}).Rate(5*1e8, big.NewInt(90*1e8)); have != 5*1e8 {
+		t.Errorf("fixed rate mismatch: have %d, want %d", have, uint64(5*1e8))
+	}
+}
+
+func TestCalculateUtilization(t *testing.T) {
+	if have := CalculateUtilization(new(big.Int), new(big.Int)); have.Sign() != 0 {
+		t.Errorf("utilization of an empty book mismatch: have %v, want 0", have)
+	}
+	if have, want := CalculateUtilization(big.NewInt(300), big.NewInt(100)), big.NewInt(75*1e8); have.Cmp(want) != 0 {
+		t.Errorf("utilization mismatch: have %v, want %v", have, want)
+	}
+}
+
+func TestGetInterestRateModel(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingBook := GetLendingOrderBookHash(common.HexToAddress(common.TomoNativeAddress), common.OneYear)
+
+	if model := GetInterestRateModel(statedb, lendingBook); model != (FixedRateModel{}) {
+		t.Fatalf("model of an unconfigured book mismatch: have %+v, want fixed rate", model)
+	}
+	modelState := GetLocMappingAtKey(lendingBook, InterestRateModelSlot)
+	for field, value := range map[string]int64{"baseRate": 2 * 1e8, "slope": 10 * 1e8, "kink": 80 * 1e8, "jumpSlope": 100 * 1e8} {
+		loc := state.GetLocOfStructElement(modelState, InterestRateModelStructSlots[field])
+		statedb.SetState(common.HexToAddress(common.LendingRegistrationSMC), loc, common.BigToHash(big.NewInt(value)))
+	}
+	want := UtilizationRateModel{BaseRate: 2 * 1e8, Slope: 10 * 1e8, Kink: 80 * 1e8, JumpSlope: 100 * 1e8}
+	if model := GetInterestRateModel(statedb, lendingBook); model != want {
+		t.Errorf("model mismatch: have %+v, want %+v", model, want)
+	}
+}
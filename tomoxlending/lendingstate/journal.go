@@ -73,6 +73,16 @@ type (
 		tradeId   common.Hash
 		prev      *big.Int
 	}
+	tradeAmountChange struct {
+		orderBook common.Hash
+		tradeId   common.Hash
+		prev      *big.Int
+	}
+	tradeInterestChange struct {
+		orderBook common.Hash
+		tradeId   common.Hash
+		prev      uint64
+	}
 )
 
 func (ch insertOrder) undo(s *LendingStateDB) {
@@ -136,3 +146,27 @@ func (ch collateralLockedAmount) undo(s *LendingStateDB) {
 	}
 	stateLendingTrade.SetCollateralLockedAmount(ch.prev)
 }
+
+func (ch tradeAmountChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateLendingTrade := stateOrderBook.getLendingTrade(s.db, ch.tradeId)
+	if stateLendingTrade == nil {
+		return
+	}
+	stateLendingTrade.SetAmount(ch.prev)
+}
+
+func (ch tradeInterestChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateLendingTrade := stateOrderBook.getLendingTrade(s.db, ch.tradeId)
+	if stateLendingTrade == nil {
+		return
+	}
+	stateLendingTrade.SetInterest(ch.prev)
+}
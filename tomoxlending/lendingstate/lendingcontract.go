@@ -17,6 +17,9 @@ var (
 	SupportedBaseSlot         = uint64(3)
 	SupportedTermSlot         = uint64(4)
 	ILOCollateralSlot         = uint64(5)
+	InterestRateModelSlot     = uint64(6)
+	PriceFeedSlot             = uint64(7)
+	RiskParametersSlot        = uint64(8)
 	LendingRelayerStructSlots = map[string]*big.Int{
 		"fee":         big.NewInt(0),
 		"bases":       big.NewInt(1),
@@ -33,6 +36,23 @@ var (
 		"price":       big.NewInt(0),
 		"blockNumber": big.NewInt(1),
 	}
+	InterestRateModelStructSlots = map[string]*big.Int{
+		"baseRate":  big.NewInt(0),
+		"slope":     big.NewInt(1),
+		"kink":      big.NewInt(2),
+		"jumpSlope": big.NewInt(3),
+	}
+	PriceFeedStructSlots = map[string]*big.Int{
+		"feeders": big.NewInt(0),
+		"prices":  big.NewInt(1),
+	}
+	RiskParametersStructSlots = map[string]*big.Int{
+		"depositRate":        big.NewInt(0),
+		"liquidationRate":    big.NewInt(1),
+		"recallRate":         big.NewInt(2),
+		"liquidationPenalty": big.NewInt(3),
+		"epoch":              big.NewInt(4),
+	}
 )
 
 // @function IsValidRelayer : return whether the given address is the coinbase of a valid relayer or not
@@ -136,6 +156,37 @@ func IsValidPair(statedb *state.StateDB, coinbase common.Address, baseToken comm
 	return false, pairIndex
 }
 
+// LendingRelayerPair is a lending book listed by a relayer.
+type LendingRelayerPair struct {
+	LendingToken common.Address `json:"lendingToken"`
+	Term         uint64         `json:"term"`
+	LendingBook  common.Hash    `json:"lendingBook"`
+}
+
+// LendingRelayerInfo is the registration of a relayer, as kept by the lending
+// registration contract.
+type LendingRelayerInfo struct {
+	Fee   *big.Int             `json:"fee"`
+	Pairs []LendingRelayerPair `json:"pairs"`
+}
+
+// @function GetLendingRelayerInfo
+// @param statedb : current state
+// @param coinbase: coinbase address of relayer
+// @return: the lending registration of the relayer, nil if it isn't a lending relayer
+func GetLendingRelayerInfo(statedb *state.StateDB, coinbase common.Address) *LendingRelayerInfo {
+	baseTokenList := GetBaseList(statedb, coinbase)
+	if len(baseTokenList) == 0 {
+		return nil
+	}
+	info := &LendingRelayerInfo{Fee: GetFee(statedb, coinbase), Pairs: []LendingRelayerPair{}}
+	terms := GetTerms(statedb, coinbase)
+	for i := 0; i < len(baseTokenList) && i < len(terms); i++ {
+		info.Pairs = append(info.Pairs, LendingRelayerPair{LendingToken: baseTokenList[i], Term: terms[i], LendingBook: GetLendingOrderBookHash(baseTokenList[i], terms[i])})
+	}
+	return info
+}
+
 // @function GetCollaterals
 // @param statedb : current state
 // @param coinbase: coinbase address of relayer
@@ -312,3 +363,85 @@ func GetAllLendingPairs(statedb *state.StateDB) (allPairs []LendingPair, err err
 	}
 	return allPairs, nil
 }
+
+// @function RegisterLendingRelayer : write the lending configuration of a relayer into the lending contract storage
+// @param statedb : current state
+// @param coinbase: coinbase address of relayer
+// @param fee: feeRate of lending
+// @param bases, terms: lending books of the relayer, the i-th book lends bases[i] for terms[i]
+func RegisterLendingRelayer(statedb *state.StateDB, coinbase common.Address, fee *big.Int, bases []common.Address, terms []uint64) {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	locRelayerState := state.GetLocMappingAtKey(coinbase.Hash(), LendingRelayerListSlot)
+	statedb.SetState(contract, state.GetLocOfStructElement(locRelayerState, LendingRelayerStructSlots["fee"]), common.BigToHash(fee))
+
+	values := make([]common.Hash, len(bases))
+	for i, base := range bases {
+		values[i] = base.Hash()
+	}
+	setArray(statedb, state.GetLocOfStructElement(locRelayerState, LendingRelayerStructSlots["bases"]), values)
+	values = make([]common.Hash, len(terms))
+	for i, term := range terms {
+		values[i] = common.BigToHash(new(big.Int).SetUint64(term))
+	}
+	setArray(statedb, state.GetLocOfStructElement(locRelayerState, LendingRelayerStructSlots["terms"]), values)
+	// no ILO collateral for any book, the default collaterals are used
+	setArray(statedb, state.GetLocOfStructElement(locRelayerState, LendingRelayerStructSlots["collaterals"]), make([]common.Hash, len(bases)))
+}
+
+// @function SetSupportedLending : write the base tokens and terms which tomoxlending supports
+// @param statedb : current state
+// @param baseTokens: list of tokens which are available for lending
+// @param terms: list of supported terms
+func SetSupportedLending(statedb *state.StateDB, baseTokens []common.Address, terms []uint64) {
+	values := make([]common.Hash, len(baseTokens))
+	for i, token := range baseTokens {
+		values[i] = token.Hash()
+	}
+	setArray(statedb, state.GetLocSimpleVariable(SupportedBaseSlot), values)
+	values = make([]common.Hash, len(terms))
+	for i, term := range terms {
+		values[i] = common.BigToHash(new(big.Int).SetUint64(term))
+	}
+	setArray(statedb, state.GetLocSimpleVariable(SupportedTermSlot), values)
+}
+
+// @function AddCollateral : append a default collateral token and write its rates
+// @param statedb : current state
+// @param token: address of collateral token
+// @param depositRate, liquidationRate, recallRate: rates of the collateral
+func AddCollateral(statedb *state.StateDB, token common.Address, depositRate, liquidationRate, recallRate *big.Int) {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	locDefaultCollateralHash := state.GetLocSimpleVariable(DefaultCollateralSlot)
+	length := statedb.GetState(contract, locDefaultCollateralHash).Big().Uint64()
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(locDefaultCollateralHash, length, 1), token.Hash())
+	statedb.SetState(contract, locDefaultCollateralHash, common.BigToHash(new(big.Int).SetUint64(length+1)))
+
+	collateralState := GetLocMappingAtKey(token.Hash(), CollateralMapSlot)
+	statedb.SetState(contract, state.GetLocOfStructElement(collateralState, CollateralStructSlots["depositRate"]), common.BigToHash(depositRate))
+	statedb.SetState(contract, state.GetLocOfStructElement(collateralState, CollateralStructSlots["liquidationRate"]), common.BigToHash(liquidationRate))
+	statedb.SetState(contract, state.GetLocOfStructElement(collateralState, CollateralStructSlots["recallRate"]), common.BigToHash(recallRate))
+}
+
+// @function SetCollateralPrice : write the price of a collateral token in terms of a lending token
+// @param statedb : current state
+// @param collateralToken: address of collateral token
+// @param lendingToken: address of lending token
+// @param price, blockNumber: the price and the block it was updated at
+func SetCollateralPrice(statedb *state.StateDB, collateralToken common.Address, lendingToken common.Address, price, blockNumber *big.Int) {
+	collateralState := GetLocMappingAtKey(collateralToken.Hash(), CollateralMapSlot)
+	locMapPrices := collateralState.Add(collateralState, CollateralStructSlots["price"])
+	locLendingTokenPrice := new(big.Int).SetBytes(crypto.Keccak256(lendingToken.Hash().Bytes(), common.BigToHash(locMapPrices).Bytes()))
+
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	statedb.SetState(contract, state.GetLocOfStructElement(locLendingTokenPrice, PriceStructSlots["price"]), common.BigToHash(price))
+	statedb.SetState(contract, state.GetLocOfStructElement(locLendingTokenPrice, PriceStructSlots["blockNumber"]), common.BigToHash(blockNumber))
+}
+
+// setArray writes a dynamic array into the lending contract storage at the given slot
+func setArray(statedb *state.StateDB, locHash common.Hash, values []common.Hash) {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	statedb.SetState(contract, locHash, common.BigToHash(new(big.Int).SetUint64(uint64(len(values)))))
+	for i, value := range values {
+		statedb.SetState(contract, state.GetLocDynamicArrAtElement(locHash, uint64(i), 1), value)
+	}
+}
@@ -17,7 +17,9 @@ const (
 	Borrowing                  = "BORROW"
 	TopUp                      = "TOPUP"
 	Repay                      = "REPAY"
+	PartialRepay               = "PARTIAL_REPAY"
 	Recall                     = "RECALL"
+	AuctionBid                 = "AUCTION_BID"
 	LendingStatusNew           = "NEW"
 	LendingStatusOpen          = "OPEN"
 	LendingStatusReject        = "REJECTED"
@@ -34,11 +36,13 @@ var ValidInputLendingStatus = map[string]bool{
 }
 
 var ValidInputLendingType = map[string]bool{
-	Market: true,
-	Limit:  true,
-	Repay:  true,
-	TopUp:  true,
-	Recall: true,
+	Market:       true,
+	Limit:        true,
+	Repay:        true,
+	PartialRepay: true,
+	TopUp:        true,
+	Recall:       true,
+	AuctionBid:   true,
 }
 
 // Signature struct
@@ -76,6 +80,9 @@ type LendingItem struct {
 	LendingId       uint64         `bson:"lendingId" json:"lendingId"`
 	LendingTradeId  uint64         `bson:"tradeId" json:"tradeId"`
 	ExtraData       string         `bson:"extraData" json:"extraData"`
+
+	// Highest interest rate at which a borrowing is rolled over at maturity
+	AutoRenewRate uint64 `json:"autoRenewRate,omitempty" rlp:"optional"`
 }
 
 type LendingItemBSON struct {
@@ -201,6 +208,9 @@ func (l *LendingItem) VerifyLendingItem(state *state.StateDB) error {
 	if valid, _ := IsValidPair(state, l.Relayer, l.LendingToken, l.Term); valid == false {
 		return fmt.Errorf("invalid pair . LendToken %s . Term: %v", l.LendingToken.Hex(), l.Term)
 	}
+	if err := l.VerifyAutoRenewRate(); err != nil {
+		return err
+	}
 	if l.Status == LendingStatusNew {
 		if err := l.VerifyLendingType(); err != nil {
 			return err
@@ -260,6 +270,17 @@ func (l *LendingItem) VerifyCollateral(state *state.StateDB) error {
 	return nil
 }
 
+// VerifyAutoRenewRate make sure only new borrowings are rolled over at maturity
+func (l *LendingItem) VerifyAutoRenewRate() error {
+	if l.AutoRenewRate == 0 {
+		return nil
+	}
+	if l.Status != LendingStatusNew || l.Side != Borrowing || (l.Type != Limit && l.Type != Market) {
+		return fmt.Errorf("VerifyAutoRenewRate: only new borrowings can be renewed. Status: %s . Side: %s . Type: %s", l.Status, l.Side, l.Type)
+	}
+	return nil
+}
+
 func (l *LendingItem) VerifyLendingInterest() error {
 	if l.Interest == nil || l.Interest.Sign() <= 0 {
 		return fmt.Errorf("VerifyLendingInterest: invalid interest. Interest: %v", l.Interest)
@@ -331,6 +352,15 @@ func (l *LendingItem) EncodedSide() *big.Int {
 
 //verify signatures
 func (l *LendingItem) VerifyLendingSignature() error {
+	tx := l.signedTransaction()
+	if lendingSender(tx) != tx.UserAddress() {
+		return fmt.Errorf("verify lending item: invalid signature")
+	}
+	return nil
+}
+
+// signedTransaction returns the lending transaction the user signed for the item.
+func (l *LendingItem) signedTransaction() *types.LendingTransaction {
 	V := big.NewInt(int64(l.Signature.V))
 	R := l.Signature.R.Big()
 	S := l.Signature.S.Big()
@@ -338,12 +368,11 @@ func (l *LendingItem) VerifyLendingSignature() error {
 	//(nonce uint64, quantity *big.Int, interest, duration uint64, relayerAddress, userAddress, lendingToken, collateralToken common.Address, status, side, typeLending string, hash common.Hash, id uint64
 	tx := types.NewLendingTransaction(l.Nonce.Uint64(), l.Quantity, l.Interest.Uint64(), l.Term, l.Relayer, l.UserAddress,
 		l.LendingToken, l.CollateralToken, l.AutoTopUp, l.Status, l.Side, l.Type, l.Hash, l.LendingId, l.LendingTradeId, l.ExtraData)
-	tx.ImportSignature(V, R, S)
-	from, _ := types.LendingSender(types.LendingTxSigner{}, tx)
-	if from != tx.UserAddress() {
-		return fmt.Errorf("verify lending item: invalid signature")
+	if l.AutoRenewRate != 0 {
+		tx.SetAutoRenewRate(l.AutoRenewRate)
 	}
-	return nil
+	tx.ImportSignature(V, R, S)
+	return tx
 }
 
 func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingStateDb *LendingStateDB,
@@ -364,7 +393,7 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 				"lendingTradeId: %v. Token: %s. ExpectedBalance: %s. ActualBalance: %s",
 				lendingTradeId, lendingTrade.CollateralToken.Hex(), quantity.String(), tokenBalance.String())
 		}
-	case Repay:
+	case Repay, PartialRepay:
 		lendingBook := GetLendingOrderBookHash(lendingToken, term)
 		lendingTrade := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
 		if lendingTrade == EmptyLendingTrade {
@@ -372,6 +401,10 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 		}
 		tokenBalance := GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
 		paymentBalance := CalculateTotalRepayValue(uint64(time.Now().Unix()), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+		// a partial repayment only needs the amount repaid
+		if orderType == PartialRepay && quantity.Cmp(paymentBalance) < 0 {
+			paymentBalance = quantity
+		}
 
 		if tokenBalance.Cmp(paymentBalance) < 0 {
 			return fmt.Errorf("VerifyBalance: not enough balance to process payment for lendingTrade."+
@@ -379,6 +412,13 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 				lendingTradeId, lendingTrade.LendingToken.Hex(), paymentBalance.String(), tokenBalance.String())
 
 		}
+	case AuctionBid:
+		// the bidder must hold the highest price it bids
+		if tokenBalance := GetTokenBalance(userAddress, lendingToken, statedb); tokenBalance.Cmp(quantity) < 0 {
+			return fmt.Errorf("VerifyBalance: not enough balance to bid in the auction of lendingTrade."+
+				"lendingTradeId: %v. Token: %s. ExpectedBalance: %s. ActualBalance: %s",
+				lendingTradeId, lendingToken.Hex(), quantity.String(), tokenBalance.String())
+		}
 	case Market, Limit:
 		switch side {
 		case Investing:
@@ -0,0 +1,95 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"bytes"
+	"container/heap"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// liquidationTimeQueue is a min-heap of the keys of a liquidation time trie. It
+// mirrors every insertion and deletion of the trie, so that the earliest
+// liquidation time is found without descending the trie.
+type liquidationTimeQueue struct {
+	times []common.Hash
+	index map[common.Hash]int
+}
+
+func newLiquidationTimeQueue() *liquidationTimeQueue {
+	return &liquidationTimeQueue{index: make(map[common.Hash]int)}
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface, use add and remove instead.
+func (q *liquidationTimeQueue) Len() int { return len(q.times) }
+
+func (q *liquidationTimeQueue) Less(i, j int) bool {
+	return bytes.Compare(q.times[i][:], q.times[j][:]) < 0
+}
+
+func (q *liquidationTimeQueue) Swap(i, j int) {
+	q.times[i], q.times[j] = q.times[j], q.times[i]
+	q.index[q.times[i]], q.index[q.times[j]] = i, j
+}
+
+func (q *liquidationTimeQueue) Push(x interface{}) {
+	time := x.(common.Hash)
+	q.index[time] = len(q.times)
+	q.times = append(q.times, time)
+}
+
+func (q *liquidationTimeQueue) Pop() interface{} {
+	time := q.times[len(q.times)-1]
+	q.times = q.times[:len(q.times)-1]
+	delete(q.index, time)
+	return time
+}
+
+// add inserts a liquidation time unless it's already queued.
+func (q *liquidationTimeQueue) add(time common.Hash) {
+	if _, ok := q.index[time]; !ok {
+		heap.Push(q, time)
+	}
+}
+
+// remove drops a liquidation time if it's queued.
+func (q *liquidationTimeQueue) remove(time common.Hash) {
+	if i, ok := q.index[time]; ok {
+		heap.Remove(q, i)
+	}
+}
+
+// lowest returns the earliest queued liquidation time, or false if the queue is empty.
+func (q *liquidationTimeQueue) lowest() (common.Hash, bool) {
+	if len(q.times) == 0 {
+		return common.Hash{}, false
+	}
+	return q.times[0], true
+}
+
+func (q *liquidationTimeQueue) copy() *liquidationTimeQueue {
+	cpy := &liquidationTimeQueue{
+		times: make([]common.Hash, len(q.times)),
+		index: make(map[common.Hash]int, len(q.index)),
+	}
+	copy(cpy.times, q.times)
+	for time, i := range q.index {
+		cpy.index[time] = i
+	}
+	return cpy
+}
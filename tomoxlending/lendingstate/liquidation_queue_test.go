@@ -0,0 +1,117 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+// Tests that the liquidation time queue always yields the same lowest time as
+// the leftmost key of a trie receiving the same updates.
+func TestLiquidationTimeQueue(t *testing.T) {
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	tr, _ := stateCache.OpenStorageTrie(common.Hash{}, EmptyHash)
+	queue := newLiquidationTimeQueue()
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		time := common.BigToHash(big.NewInt(r.Int63n(200)))
+		if r.Intn(3) == 0 {
+			tr.TryDelete(time[:])
+			queue.remove(time)
+		} else {
+			tr.TryUpdate(time[:], []byte{0x01})
+			queue.add(time)
+		}
+		key, _, _ := tr.TryGetBestLeftKeyAndValue()
+		lowest, ok := queue.lowest()
+		if ok != (len(key) > 0) || (ok && lowest != common.BytesToHash(key)) {
+			t.Fatalf("step %d: lowest mismatch: have %x (%v), want %x", i, lowest, ok, key)
+		}
+	}
+	// Copies must be independent of the original
+	cpy := queue.copy()
+	for queue.Len() > 0 {
+		lowest, _ := queue.lowest()
+		queue.remove(lowest)
+	}
+	if cpy.Len() == 0 {
+		t.Fatalf("copy emptied along with the original")
+	}
+}
+
+// Tests that the liquidation time queue is served from the cache for committed
+// lending states, and rebuilt from the trie when the cache misses.
+func TestLiquidationTimeQueueCache(t *testing.T) {
+	var (
+		orderBook  = common.StringToHash("BTC/TOMO")
+		db         = rawdb.NewMemoryDatabase()
+		stateCache = NewDatabase(db)
+	)
+	statedb, _ := New(common.Hash{}, stateCache)
+	for i := 1; i <= 5; i++ {
+		statedb.InsertLiquidationTime(orderBook, big.NewInt(int64(10*i)), uint64(i))
+	}
+	root := statedb.IntermediateRoot()
+	statedb.Commit()
+	if err := stateCache.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	liquidationRoot := statedb.getLendingExchange(orderBook).data.LiquidationTimeRoot
+	if _, ok := stateCache.liquidationTimes(liquidationRoot); !ok {
+		t.Fatalf("liquidation times not cached on commit")
+	}
+	for _, cache := range []Database{stateCache, NewDatabase(db)} {
+		statedb, err := New(root, cache)
+		if err != nil {
+			t.Fatalf("failed to open lending state: %v", err)
+		}
+		if err := statedb.RemoveLiquidationTime(orderBook, 1, 10); err != nil {
+			t.Fatalf("failed to remove liquidation time: %v", err)
+		}
+		time, trades := statedb.GetLowestLiquidationTime(orderBook, big.NewInt(20))
+		if time.Cmp(big.NewInt(20)) != 0 || len(trades) != 1 || trades[0] != common.Uint64ToHash(2) {
+			t.Fatalf("lowest liquidation time mismatch: have %v %x, want 20 [%x]", time, trades, common.Uint64ToHash(2))
+		}
+	}
+}
+
+// Tests that a liquidation time the trie no longer holds is dropped from the
+// queue instead of hiding the times after it.
+func TestLiquidationTimeQueueReconcile(t *testing.T) {
+	orderBook := common.StringToHash("BTC/TOMO")
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.InsertLiquidationTime(orderBook, big.NewInt(20), 1)
+	statedb.IntermediateRoot()
+
+	exchange := statedb.getLendingExchange(orderBook)
+	stale := common.BigToHash(big.NewInt(10))
+	exchange.getLiquidationTimeQueue(statedb.db).add(stale)
+
+	time, trades := statedb.GetLowestLiquidationTime(orderBook, big.NewInt(20))
+	if time.Cmp(big.NewInt(20)) != 0 || len(trades) != 1 || trades[0] != common.Uint64ToHash(1) {
+		t.Fatalf("lowest liquidation time mismatch: have %v %x, want 20 [%x]", time, trades, common.Uint64ToHash(1))
+	}
+	if _, ok := exchange.getLiquidationTimeQueue(statedb.db).index[stale]; ok {
+		t.Errorf("stale liquidation time still queued")
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+// The price oracle of the lending contract keeps, per collateral and lending
+// token, the list of the addresses allowed to feed the price of the pair and
+// the last price reported by each of them:
+//
+//	mapping(collateral => mapping(lending => struct {
+//		address[] feeders;
+//		mapping(address => Price) prices;
+//	}))
+
+// PriceFeed is the last price of a pair reported by a feeder.
+type PriceFeed struct {
+	Feeder      common.Address
+	Price       *big.Int
+	BlockNumber *big.Int
+}
+
+// getLocPriceFeed returns the location of the price feed struct of a pair.
+func getLocPriceFeed(collateralToken common.Address, lendingToken common.Address) *big.Int {
+	locCollateral := GetLocMappingAtKey(collateralToken.Hash(), PriceFeedSlot)
+	return new(big.Int).SetBytes(crypto.Keccak256(lendingToken.Hash().Bytes(), common.BigToHash(locCollateral).Bytes()))
+}
+
+// getLocFeederPrice returns the location of the price struct reported by a feeder.
+func getLocFeederPrice(locPriceFeed *big.Int, feeder common.Address) *big.Int {
+	locPrices := state.GetLocOfStructElement(locPriceFeed, PriceFeedStructSlots["prices"])
+	return new(big.Int).SetBytes(crypto.Keccak256(feeder.Hash().Bytes(), locPrices.Bytes()))
+}
+
+// @function GetPriceFeeders
+// @param statedb : current state
+// @param collateralToken: address of collateral token
+// @param lendingToken: address of lending token
+// @return: the addresses allowed to feed the price of the pair
+func GetPriceFeeders(statedb *state.StateDB, collateralToken common.Address, lendingToken common.Address) []common.Address {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	locFeeders := state.GetLocOfStructElement(getLocPriceFeed(collateralToken, lendingToken), PriceFeedStructSlots["feeders"])
+	length := statedb.GetState(contract, locFeeders).Big().Uint64()
+	feeders := []common.Address{}
+	for i := uint64(0); i < length; i++ {
+		loc := state.GetLocDynamicArrAtElement(locFeeders, i, 1)
+		addr := common.BytesToAddress(statedb.GetState(contract, loc).Bytes())
+		if addr != (common.Address{}) {
+			feeders = append(feeders, addr)
+		}
+	}
+	return feeders
+}
+
+// @function GetPriceFeeds
+// @param statedb : current state
+// @param collateralToken: address of collateral token
+// @param lendingToken: address of lending token
+// @return: the last price reported by each feeder of the pair, feeders which never reported are skipped
+func GetPriceFeeds(statedb *state.StateDB, collateralToken common.Address, lendingToken common.Address) []PriceFeed {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	locPriceFeed := getLocPriceFeed(collateralToken, lendingToken)
+	feeds := []PriceFeed{}
+	for _, feeder := range GetPriceFeeders(statedb, collateralToken, lendingToken) {
+		locPrice := getLocFeederPrice(locPriceFeed, feeder)
+		price := statedb.GetState(contract, state.GetLocOfStructElement(locPrice, PriceStructSlots["price"])).Big()
+		if price.Sign() <= 0 {
+			continue
+		}
+		blockNumber := statedb.GetState(contract, state.GetLocOfStructElement(locPrice, PriceStructSlots["blockNumber"])).Big()
+		feeds = append(feeds, PriceFeed{Feeder: feeder, Price: price, BlockNumber: blockNumber})
+	}
+	return feeds
+}
+
+// median returns the median of the given prices, the average of the two middle
+// ones for an even count. The prices must be sorted.
+func median(prices []*big.Int) *big.Int {
+	n := len(prices)
+	if n%2 == 1 {
+		return CloneBigInt(prices[n/2])
+	}
+	sum := new(big.Int).Add(prices[n/2-1], prices[n/2])
+	return sum.Div(sum, common.Big2)
+}
+
+// MedianPrice aggregates the prices reported by the feeders of a pair at the
+// given block. Stale prices, older than OraclePriceMaxAge blocks, are dropped,
+// then the prices deviating from their median by more than OracleMaxDeviation.
+// The median of the remaining prices is returned only if they were reported by
+// a majority of the feeders, nil otherwise.
+func MedianPrice(feeds []PriceFeed, number uint64, feeders int) *big.Int {
+	fresh := []*big.Int{}
+	for _, feed := range feeds {
+		if feed.Price == nil || feed.Price.Sign() <= 0 || feed.BlockNumber == nil || feed.BlockNumber.Uint64() > number {
+			continue
+		}
+		if number-feed.BlockNumber.Uint64() > common.OraclePriceMaxAge {
+			continue
+		}
+		fresh = append(fresh, feed.Price)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].Cmp(fresh[j]) < 0 })
+	mid := median(fresh)
+
+	// maxDeviation = median * OracleMaxDeviation / BaseOracle
+	maxDeviation := new(big.Int).Mul(mid, common.OracleMaxDeviation)
+	maxDeviation = new(big.Int).Div(maxDeviation, common.BaseOracle)
+	accepted := []*big.Int{}
+	for _, price := range fresh {
+		if new(big.Int).Abs(new(big.Int).Sub(price, mid)).Cmp(maxDeviation) <= 0 {
+			accepted = append(accepted, price)
+		}
+	}
+	if len(accepted)*2 <= feeders {
+		return nil
+	}
+	return median(accepted)
+}
+
+// @function GetOraclePrice
+// @param statedb : current state
+// @param collateralToken: address of collateral token
+// @param lendingToken: address of lending token
+// @param number: the current block number
+// @return: the median price of the collateral token in terms of the lending token, FALSE if the feeders of the pair didn't agree on a price
+func GetOraclePrice(statedb *state.StateDB, collateralToken common.Address, lendingToken common.Address, number uint64) (*big.Int, bool) {
+	feeders := GetPriceFeeders(statedb, collateralToken, lendingToken)
+	if len(feeders) == 0 {
+		return nil, false
+	}
+	price := MedianPrice(GetPriceFeeds(statedb, collateralToken, lendingToken), number, len(feeders))
+	return price, price != nil
+}
+
+// @function SetPriceFeeders : write the addresses allowed to feed the price of a pair
+// @param statedb : current state
+// @param collateralToken: address of collateral token
+// @param lendingToken: address of lending token
+// @param feeders: the feeder addresses
+func SetPriceFeeders(statedb *state.StateDB, collateralToken common.Address, lendingToken common.Address, feeders []common.Address) {
+	values := make([]common.Hash, len(feeders))
+	for i, feeder := range feeders {
+		values[i] = feeder.Hash()
+	}
+	setArray(statedb, state.GetLocOfStructElement(getLocPriceFeed(collateralToken, lendingToken), PriceFeedStructSlots["feeders"]), values)
+}
+
+// @function SetPriceFeed : write the price of a pair reported by a feeder
+// @param statedb : current state
+// @param collateralToken: address of collateral token
+// @param lendingToken: address of lending token
+// @param feeder: the feeder address
+// @param price, blockNumber: the price and the block it was reported at
+func SetPriceFeed(statedb *state.StateDB, collateralToken common.Address, lendingToken common.Address, feeder common.Address, price, blockNumber *big.Int) {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	locPrice := getLocFeederPrice(getLocPriceFeed(collateralToken, lendingToken), feeder)
+	statedb.SetState(contract, state.GetLocOfStructElement(locPrice, PriceStructSlots["price"]), common.BigToHash(price))
+	statedb.SetState(contract, state.GetLocOfStructElement(locPrice, PriceStructSlots["blockNumber"]), common.BigToHash(blockNumber))
+}
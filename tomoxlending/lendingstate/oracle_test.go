@@ -0,0 +1,85 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+func TestMedianPrice(t *testing.T) {
+	feed := func(price int64, number uint64) PriceFeed {
+		return PriceFeed{Price: big.NewInt(price), BlockNumber: new(big.Int).SetUint64(number)}
+	}
+	number := uint64(2000)
+	tests := []struct {
+		name    string
+		feeds   []PriceFeed
+		feeders int
+		want    *big.Int
+	}{
+		{"odd", []PriceFeed{feed(101, number), feed(99, number), feed(100, number)}, 3, big.NewInt(100)},
+		{"even", []PriceFeed{feed(100, number), feed(103, number), feed(98, number), feed(101, number)}, 4, big.NewInt(100)},
+		{"outlier", []PriceFeed{feed(100, number), feed(150, number), feed(102, number)}, 3, big.NewInt(101)},
+		{"stale", []PriceFeed{feed(100, number), feed(50, number-common.OraclePriceMaxAge-1), feed(104, number)}, 3, big.NewInt(102)},
+		{"no quorum", []PriceFeed{feed(100, number), feed(101, number-common.OraclePriceMaxAge-1)}, 3, nil},
+		{"split", []PriceFeed{feed(100, number), feed(200, number)}, 2, nil},
+		{"empty", nil, 3, nil},
+	}
+	for _, test := range tests {
+		have := MedianPrice(test.feeds, number, test.feeders)
+		if (have == nil) != (test.want == nil) || (have != nil && have.Cmp(test.want) != 0) {
+			t.Errorf("%s: median mismatch: have %v, want %v", test.name, have, test.want)
+		}
+	}
+}
+
+func TestGetOraclePrice(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	collateral := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	lending := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	feeders := []common.Address{
+		common.HexToAddress("0x00000000000000000000000000000000000000f1"),
+		common.HexToAddress("0x00000000000000000000000000000000000000f2"),
+		common.HexToAddress("0x00000000000000000000000000000000000000f3"),
+	}
+	if _, ok := GetOraclePrice(statedb, collateral, lending, 100); ok {
+		t.Fatalf("price of a pair without feeders")
+	}
+	SetPriceFeeders(statedb, collateral, lending, feeders)
+	if have := GetPriceFeeders(statedb, collateral, lending); len(have) != len(feeders) || have[2] != feeders[2] {
+		t.Fatalf("feeders mismatch: have %v, want %v", have, feeders)
+	}
+	SetPriceFeed(statedb, collateral, lending, feeders[0], big.NewInt(1000), big.NewInt(90))
+	if _, ok := GetOraclePrice(statedb, collateral, lending, 100); ok {
+		t.Fatalf("price reported by a single feeder out of three")
+	}
+	SetPriceFeed(statedb, collateral, lending, feeders[1], big.NewInt(1020), big.NewInt(95))
+	SetPriceFeed(statedb, collateral, lending, feeders[2], big.NewInt(5000), big.NewInt(99))
+	price, ok := GetOraclePrice(statedb, collateral, lending, 100)
+	if !ok || price.Cmp(big.NewInt(1010)) != 0 {
+		t.Fatalf("oracle price mismatch: have %v, want %v", price, 1010)
+	}
+	// the feeds of the pair don't leak into the inverse pair
+	if _, ok := GetOraclePrice(statedb, lending, collateral, 100); ok {
+		t.Fatalf("price of the inverse pair")
+	}
+}
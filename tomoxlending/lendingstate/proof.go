@@ -0,0 +1,152 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// ProveLendingItem writes into bookProof the Merkle proof of the lending book in
+// the lending state trie with the given root, and into itemProof the proof of
+// the lending item in the item trie of the book. It returns the proven item,
+// which is empty if the lending book holds no item with the given id.
+func ProveLendingItem(db Database, root common.Hash, lendingBook common.Hash, lendingId common.Hash, bookProof, itemProof ethdb.KeyValueWriter) (LendingItem, error) {
+	book, err := proveLendingBook(db, root, lendingBook, bookProof)
+	if err != nil {
+		return LendingItem{}, err
+	}
+	items, err := db.OpenStorageTrie(lendingBook, book.LendingItemRoot)
+	if err != nil {
+		return LendingItem{}, err
+	}
+	if err := items.Prove(lendingId[:], 0, itemProof); err != nil {
+		return LendingItem{}, err
+	}
+	var item LendingItem
+	enc, err := items.TryGet(lendingId[:])
+	if err != nil || len(enc) == 0 {
+		return item, err
+	}
+	if err := rlp.DecodeBytes(enc, &item); err != nil {
+		return LendingItem{}, fmt.Errorf("invalid lending item %x: %v", lendingId, err)
+	}
+	return item, nil
+}
+
+// InterestLevel is an interest rate of a side of a lending book, with the
+// volume offered at it and the root of the trie of its items.
+type InterestLevel struct {
+	Interest *big.Int    `json:"interest"`
+	Volume   *big.Int    `json:"volume"`
+	Root     common.Hash `json:"root"`
+}
+
+// ProveInterestLevels writes into bookProof the Merkle proof of the lending
+// book in the lending state trie with the given root, and into firstProof and
+// lastProof the edge proofs of the range of the best interest levels of a side
+// of the book, up to depth of them. The range of the investing side starts at
+// the zero interest and the one of the borrowing side ends at its highest
+// interest, so that trie.VerifyRangeProof proves that no better level is left
+// out. It returns the levels from the best one, none and no range proof if the
+// side is empty.
+func ProveInterestLevels(db Database, root common.Hash, lendingBook common.Hash, side string, depth int, bookProof, firstProof, lastProof ethdb.KeyValueWriter) ([]InterestLevel, error) {
+	book, err := proveLendingBook(db, root, lendingBook, bookProof)
+	if err != nil {
+		return nil, err
+	}
+	var sideRoot common.Hash
+	switch side {
+	case Investing:
+		sideRoot = book.InvestingRoot
+	case Borrowing:
+		sideRoot = book.BorrowingRoot
+	default:
+		return nil, fmt.Errorf("invalid lending side %q", side)
+	}
+	tr, err := db.OpenStorageTrie(lendingBook, sideRoot)
+	if err != nil {
+		return nil, err
+	}
+	var keys, values [][]byte
+	for it := trie.NewIterator(tr.NodeIterator(nil)); it.Next(); {
+		keys, values = append(keys, common.CopyBytes(it.Key)), append(values, common.CopyBytes(it.Value))
+		// the best investments are the lowest interests, the best borrowings the highest
+		if side == Investing && len(keys) == depth {
+			break
+		}
+	}
+	if len(keys) > depth {
+		keys, values = keys[len(keys)-depth:], values[len(values)-depth:]
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	first := keys[0]
+	if side == Investing {
+		first = common.Hash{}.Bytes()
+	}
+	if err := tr.Prove(first, 0, firstProof); err != nil {
+		return nil, err
+	}
+	if err := tr.Prove(keys[len(keys)-1], 0, lastProof); err != nil {
+		return nil, err
+	}
+	levels := make([]InterestLevel, len(keys))
+	for i := range keys {
+		var list itemList
+		if err := rlp.DecodeBytes(values[i], &list); err != nil {
+			return nil, fmt.Errorf("invalid interest level %x: %v", keys[i], err)
+		}
+		level := InterestLevel{Interest: new(big.Int).SetBytes(keys[i]), Volume: list.Volume, Root: list.Root}
+		if side == Investing {
+			levels[i] = level
+		} else {
+			levels[len(keys)-1-i] = level
+		}
+	}
+	return levels, nil
+}
+
+// proveLendingBook writes into bookProof the Merkle proof of the lending book in
+// the lending state trie with the given root and returns the lending book.
+func proveLendingBook(db Database, root common.Hash, lendingBook common.Hash, bookProof ethdb.KeyValueWriter) (*lendingObject, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := tr.Prove(lendingBook[:], 0, bookProof); err != nil {
+		return nil, err
+	}
+	enc, err := tr.TryGet(lendingBook[:])
+	if err != nil {
+		return nil, err
+	}
+	if len(enc) == 0 {
+		return nil, fmt.Errorf("lending book %x not found", lendingBook)
+	}
+	var book lendingObject
+	if err := rlp.DecodeBytes(enc, &book); err != nil {
+		return nil, fmt.Errorf("invalid lending book %x: %v", lendingBook, err)
+	}
+	return &book, nil
+}
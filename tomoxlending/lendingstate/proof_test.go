@@ -0,0 +1,141 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// Tests that the proofs of a lending item verify against the lending state root
+// and the item root of the book.
+func TestProveLendingItem(t *testing.T) {
+	var (
+		stateCache  = NewDatabase(rawdb.NewMemoryDatabase())
+		lendingBook = common.StringToHash("USDT/30")
+	)
+	statedb, _ := New(common.Hash{}, stateCache)
+	for i := 1; i <= 8; i++ {
+		item := LendingItem{LendingId: uint64(i), Quantity: big.NewInt(int64(i)), Interest: big.NewInt(int64(i)), Side: Investing, Signature: &Signature{V: 1}}
+		statedb.InsertLendingItem(lendingBook, common.BigToHash(big.NewInt(int64(i))), item)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	lendingId := common.BigToHash(big.NewInt(5))
+	bookProof, itemProof := memorydb.New(), memorydb.New()
+	item, err := ProveLendingItem(stateCache, root, lendingBook, lendingId, bookProof, itemProof)
+	if err != nil {
+		t.Fatalf("failed to prove lending item: %v", err)
+	}
+	if item.LendingId != 5 {
+		t.Fatalf("proven item mismatch: have %d, want 5", item.LendingId)
+	}
+	enc, err := trie.VerifyProof(root, lendingBook[:], bookProof)
+	if err != nil {
+		t.Fatalf("failed to verify lending book proof: %v", err)
+	}
+	var book lendingObject
+	if err := rlp.DecodeBytes(enc, &book); err != nil {
+		t.Fatalf("failed to decode proven lending book: %v", err)
+	}
+	if enc, err = trie.VerifyProof(book.LendingItemRoot, lendingId[:], itemProof); err != nil {
+		t.Fatalf("failed to verify lending item proof: %v", err)
+	}
+	if want, _ := rlp.EncodeToBytes(&item); !bytes.Equal(enc, want) {
+		t.Fatalf("proven item encoding mismatch: have %x, want %x", enc, want)
+	}
+	if _, err := ProveLendingItem(stateCache, root, common.StringToHash("TOMO/30"), lendingId, memorydb.New(), memorydb.New()); err == nil {
+		t.Fatalf("proved an item of a missing book")
+	}
+}
+
+// Tests that the best interest levels of both sides of a lending book are
+// proven to be the best ones by a range proof against the roots of the sides.
+func TestProveInterestLevels(t *testing.T) {
+	var (
+		stateCache  = NewDatabase(rawdb.NewMemoryDatabase())
+		lendingBook = common.StringToHash("USDT/30")
+	)
+	statedb, _ := New(common.Hash{}, stateCache)
+	for i := 1; i <= 8; i++ {
+		side, interest := Investing, int64(4+i)
+		if i > 4 {
+			side, interest = Borrowing, int64(i-4)
+		}
+		item := LendingItem{LendingId: uint64(i), Quantity: big.NewInt(int64(i)), Interest: big.NewInt(interest), Side: side, Signature: &Signature{V: 1}}
+		statedb.InsertLendingItem(lendingBook, common.BigToHash(big.NewInt(int64(i))), item)
+	}
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	for _, test := range []struct {
+		side      string
+		depth     int
+		interests []int64
+	}{
+		{Investing, 2, []int64{5, 6}},
+		{Borrowing, 3, []int64{4, 3, 2}},
+	} {
+		bookProof, firstProof, lastProof := memorydb.New(), memorydb.New(), memorydb.New()
+		levels, err := ProveInterestLevels(stateCache, root, lendingBook, test.side, test.depth, bookProof, firstProof, lastProof)
+		if err != nil {
+			t.Fatalf("%s: failed to prove interest levels: %v", test.side, err)
+		}
+		if len(levels) != len(test.interests) {
+			t.Fatalf("%s: level count mismatch: have %d, want %d", test.side, len(levels), len(test.interests))
+		}
+		enc, err := trie.VerifyProof(root, lendingBook[:], bookProof)
+		if err != nil {
+			t.Fatalf("failed to verify lending book proof: %v", err)
+		}
+		var book lendingObject
+		if err := rlp.DecodeBytes(enc, &book); err != nil {
+			t.Fatalf("failed to decode proven lending book: %v", err)
+		}
+		var keys, values [][]byte
+		for i := range levels {
+			level := levels[i]
+			if test.side == Borrowing {
+				level = levels[len(levels)-1-i]
+			}
+			enc, _ := rlp.EncodeToBytes(&itemList{Volume: level.Volume, Root: level.Root})
+			keys, values = append(keys, common.BigToHash(level.Interest).Bytes()), append(values, enc)
+		}
+		sideRoot, first := book.InvestingRoot, common.Hash{}.Bytes()
+		if test.side == Borrowing {
+			sideRoot, first = book.BorrowingRoot, keys[0]
+		}
+		if err, more := trie.VerifyRangeProof(sideRoot, first, keys, values, firstProof, lastProof); err != nil || (test.side == Borrowing && more) {
+			t.Fatalf("%s: failed to verify range proof: %v, more %v", test.side, err, more)
+		}
+		for i, interest := range test.interests {
+			if levels[i].Interest.Int64() != interest {
+				t.Errorf("%s: level %d interest mismatch: have %v, want %d", test.side, i, levels[i].Interest, interest)
+			}
+		}
+	}
+}
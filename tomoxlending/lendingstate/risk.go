@@ -0,0 +1,133 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+// The governance of the lending contract schedules the risk parameters of the
+// loans backed by a collateral for an epoch, keeping next to them the
+// parameters in effect until then:
+//
+//	mapping(collateral => struct {
+//		RiskParameters scheduled;
+//		RiskParameters previous;
+//	})
+//
+// so that the parameters in effect at any block of an epoch are known from the
+// state, whatever the block the governance updated them at.
+
+// riskParametersSize is the number of slots of a RiskParameters struct.
+var riskParametersSize = big.NewInt(int64(len(RiskParametersStructSlots)))
+
+// RiskParameters are the rates bounding the risk of the loans backed by a
+// collateral, in percent of the value lent.
+type RiskParameters struct {
+	DepositRate        *big.Int `json:"depositRate"`        // Collateral locked when opening a loan, the inverse of its maximum loan to value ratio
+	LiquidationRate    *big.Int `json:"liquidationRate"`    // Collateral under which a loan is liquidated
+	RecallRate         *big.Int `json:"recallRate"`         // Collateral above which the excess is recalled to the borrower
+	LiquidationPenalty *big.Int `json:"liquidationPenalty"` // Share of the debt added to the collateral seized from the borrower at liquidation
+	Epoch              uint64   `json:"epoch"`              // Epoch the parameters were scheduled for, zero for the rates of the collateral
+}
+
+// valid returns whether the parameters are usable, the governance having set
+// them all.
+func (p *RiskParameters) valid() bool {
+	return p.Epoch > 0 && p.DepositRate.Sign() > 0 && p.LiquidationRate.Sign() > 0 && p.RecallRate.Sign() > 0 &&
+		p.LiquidationPenalty.Cmp(common.BaseRecall) < 0
+}
+
+// getLocRiskParameters returns the location of the scheduled risk parameters of
+// a collateral, the previous ones following them.
+func getLocRiskParameters(token common.Address) *big.Int {
+	return GetLocMappingAtKey(token.Hash(), RiskParametersSlot)
+}
+
+// readRiskParameters reads a RiskParameters struct at the given location.
+func readRiskParameters(statedb *state.StateDB, loc *big.Int) *RiskParameters {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	get := func(field string) common.Hash {
+		return statedb.GetState(contract, state.GetLocOfStructElement(loc, RiskParametersStructSlots[field]))
+	}
+	return &RiskParameters{
+		DepositRate:        get("depositRate").Big(),
+		LiquidationRate:    get("liquidationRate").Big(),
+		RecallRate:         get("recallRate").Big(),
+		LiquidationPenalty: get("liquidationPenalty").Big(),
+		Epoch:              get("epoch").Big().Uint64(),
+	}
+}
+
+// @function GetCollateralRiskParameters
+// @param statedb : current state
+// @param token: address of collateral token
+// @return: the rates of the collateral as risk parameters, without liquidation penalty
+func GetCollateralRiskParameters(statedb *state.StateDB, token common.Address) *RiskParameters {
+	depositRate, liquidationRate, recallRate := GetCollateralDetail(statedb, token)
+	return &RiskParameters{
+		DepositRate:        depositRate,
+		LiquidationRate:    liquidationRate,
+		RecallRate:         recallRate,
+		LiquidationPenalty: new(big.Int),
+	}
+}
+
+// @function GetRiskParameters
+// @param statedb : current state
+// @param token: address of collateral token
+// @param epoch: epoch of the current block
+// @return: the risk parameters scheduled by the governance in effect at the epoch, the rates of the collateral if none are
+func GetRiskParameters(statedb *state.StateDB, token common.Address, epoch uint64) *RiskParameters {
+	loc := getLocRiskParameters(token)
+	if scheduled := readRiskParameters(statedb, loc); scheduled.valid() && scheduled.Epoch <= epoch {
+		return scheduled
+	}
+	if previous := readRiskParameters(statedb, new(big.Int).Add(loc, riskParametersSize)); previous.valid() && previous.Epoch <= epoch {
+		return previous
+	}
+	return GetCollateralRiskParameters(statedb, token)
+}
+
+// @function SetRiskParameters : schedule the risk parameters of a collateral, as the governance does
+// @param statedb : current state
+// @param token: address of collateral token
+// @param params: the parameters, effective from their epoch on
+// @param epoch: epoch of the current block
+func SetRiskParameters(statedb *state.StateDB, token common.Address, params *RiskParameters, epoch uint64) {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	write := func(loc *big.Int, params *RiskParameters) {
+		set := func(field string, value *big.Int) {
+			statedb.SetState(contract, state.GetLocOfStructElement(loc, RiskParametersStructSlots[field]), common.BigToHash(value))
+		}
+		set("depositRate", params.DepositRate)
+		set("liquidationRate", params.LiquidationRate)
+		set("recallRate", params.RecallRate)
+		set("liquidationPenalty", params.LiquidationPenalty)
+		set("epoch", new(big.Int).SetUint64(params.Epoch))
+	}
+	// the parameters in effect stay so until the new ones, those not in effect
+	// yet are replaced
+	loc := getLocRiskParameters(token)
+	if scheduled := readRiskParameters(statedb, loc); scheduled.valid() && scheduled.Epoch <= epoch {
+		write(new(big.Int).Add(loc, riskParametersSize), scheduled)
+	}
+	write(loc, params)
+}
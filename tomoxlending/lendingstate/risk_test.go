@@ -0,0 +1,69 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+func riskParameters(depositRate, penalty int64, epoch uint64) *RiskParameters {
+	return &RiskParameters{
+		DepositRate:        big.NewInt(depositRate),
+		LiquidationRate:    big.NewInt(110),
+		RecallRate:         big.NewInt(200),
+		LiquidationPenalty: big.NewInt(penalty),
+		Epoch:              epoch,
+	}
+}
+
+func TestGetRiskParameters(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	token := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	AddCollateral(statedb, token, big.NewInt(150), big.NewInt(110), big.NewInt(200))
+
+	check := func(epoch uint64, depositRate int64, penalty int64) {
+		t.Helper()
+		params := GetRiskParameters(statedb, token, epoch)
+		if params.DepositRate.Int64() != depositRate || params.LiquidationPenalty.Int64() != penalty {
+			t.Errorf("epoch %d: parameters mismatch: have %d/%d, want %d/%d", epoch, params.DepositRate, params.LiquidationPenalty, depositRate, penalty)
+		}
+	}
+	// the collateral rates apply until the governance schedules parameters
+	check(1, 150, 0)
+	SetRiskParameters(statedb, token, riskParameters(160, 5, 3), 1)
+	check(2, 150, 0)
+	check(3, 160, 5)
+
+	// parameters scheduled later leave those in effect until their epoch
+	SetRiskParameters(statedb, token, riskParameters(170, 8, 6), 4)
+	check(5, 160, 5)
+	check(6, 170, 8)
+
+	// rescheduling parameters not in effect yet replaces them
+	SetRiskParameters(statedb, token, riskParameters(180, 10, 7), 5)
+	check(6, 160, 5)
+	check(7, 180, 10)
+
+	// incomplete parameters are ignored
+	SetRiskParameters(statedb, token, &RiskParameters{DepositRate: big.NewInt(190), LiquidationRate: new(big.Int), RecallRate: big.NewInt(200), LiquidationPenalty: new(big.Int), Epoch: 9}, 8)
+	check(9, 180, 10)
+}
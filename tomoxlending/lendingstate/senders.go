@@ -0,0 +1,57 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+const lendingSenderCacheLimit = 4096
+
+// lendingSenders caches the users recovered from the signatures of the lending
+// items, by hash of their signed transactions.
+var lendingSenders, _ = lru.New(lendingSenderCacheLimit)
+
+// lendingSender returns the user who signed a lending transaction, or the zero
+// address if the signature is invalid.
+func lendingSender(tx *types.LendingTransaction) common.Address {
+	hash := tx.Hash()
+	if from, ok := lendingSenders.Get(hash); ok {
+		return from.(common.Address)
+	}
+	from, err := types.LendingSender(types.LendingTxSigner{}, tx)
+	if err != nil {
+		return common.Address{}
+	}
+	lendingSenders.Add(hash, from)
+	return from
+}
+
+// RecoverLendingSenders recovers concurrently the users who signed the lending
+// items of the books, so that the items matched one after the other find them
+// cached.
+func RecoverLendingSenders(items []*LendingItem) {
+	tradingstate.ParallelFor(len(items), func(i int) {
+		if items[i].Signature == nil || items[i].Nonce == nil || items[i].Interest == nil {
+			return
+		}
+		lendingSender(items[i].signedTransaction())
+	})
+}
@@ -0,0 +1,53 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+func TestRecoverLendingSenders(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	items := make([]*LendingItem, 16)
+	for i := range items {
+		item := &LendingItem{
+			Nonce:       big.NewInt(int64(i)),
+			Quantity:    big.NewInt(1000),
+			Interest:    big.NewInt(int64(100 + i)),
+			Term:        86400,
+			UserAddress: user,
+			Status:      LendingStatusNew,
+			Side:        Borrowing,
+			Type:        Limit,
+			Signature:   &Signature{},
+		}
+		signed, err := types.LendingSignTx(item.signedTransaction(), types.LendingTxSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign lending item: %v", err)
+		}
+		V, R, S := signed.Signature()
+		item.Signature = &Signature{V: byte(V.Uint64()), R: common.BigToHash(R), S: common.BigToHash(S)}
+		items[i] = item
+	}
+	// an item without signature is left to the matching to reject
+	items = append(items, &LendingItem{Nonce: big.NewInt(1)})
+	RecoverLendingSenders(items)
+
+	for i, item := range items[:len(items)-1] {
+		if from, ok := lendingSenders.Get(item.signedTransaction().Hash()); !ok || from.(common.Address) != user {
+			t.Errorf("item %d: sender mismatch: have %v, want %x", i, from, user)
+		}
+		if err := item.VerifyLendingSignature(); err != nil {
+			t.Errorf("item %d: failed to verify signature: %v", i, err)
+		}
+	}
+	// a tampered item doesn't match the cached sender of the original
+	items[0].Quantity = big.NewInt(1)
+	if err := items[0].VerifyLendingSignature(); err == nil {
+		t.Errorf("tampered item verified")
+	}
+}
@@ -254,3 +254,16 @@ func CalculateTotalRepayValue(finalizeTime, liquidationTime, term uint64, apr ui
 	paymentBalance = new(big.Int).Div(paymentBalance, baseInterestDecimal)
 	return paymentBalance
 }
+
+// CalculatePartialRepayment splits a payment of part of a lending trade into the
+// principal it repays and the interest accrued on that principal, so that
+// repaying a share of the trade costs the same share of its total repay value.
+func CalculatePartialRepayment(finalizeTime, liquidationTime, term uint64, apr uint64, tradeAmount *big.Int, payment *big.Int) (*big.Int, *big.Int) {
+	paymentBalance := CalculateTotalRepayValue(finalizeTime, liquidationTime, term, apr, tradeAmount)
+	if paymentBalance.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+	principal := new(big.Int).Mul(payment, tradeAmount)
+	principal = new(big.Int).Div(principal, paymentBalance)
+	return principal, new(big.Int).Sub(payment, principal)
+}
@@ -459,3 +459,43 @@ func TestCalculateTotalRepayValue(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculatePartialRepayment(t *testing.T) {
+	tradeAmount := new(big.Int).Mul(big.NewInt(1000), common.BasePrice)
+	halfRepayEarly, _ := new(big.Int).SetString("525068493150000000000", 10)
+	tests := []struct {
+		name          string
+		finalizeTime  uint64
+		payment       *big.Int
+		wantPrincipal *big.Int
+		wantInterest  *big.Int
+	}{
+		// apr = 10% per year, term 365 days, 1000 USDT
+		// repay after one day: totalRepay = 1050,1369863
+		// paying half of it repays half of the principal
+		{
+			"term 365 days: half early repay",
+			86400,
+			halfRepayEarly,
+			new(big.Int).Mul(big.NewInt(500), common.BasePrice),
+			new(big.Int).Sub(halfRepayEarly, new(big.Int).Mul(big.NewInt(500), common.BasePrice)),
+		},
+		// repay at the end: totalRepay = 1100
+		// 110 USDT repays 100 USDT of principal and 10 USDT of interest
+		{
+			"term 365 days: tenth repay at the end",
+			common.OneYear,
+			new(big.Int).Mul(big.NewInt(110), common.BasePrice),
+			new(big.Int).Mul(big.NewInt(100), common.BasePrice),
+			new(big.Int).Mul(big.NewInt(10), common.BasePrice),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, interest := CalculatePartialRepayment(tt.finalizeTime, common.OneYear, common.OneYear, 10*1e8, tradeAmount, tt.payment)
+			if principal.Cmp(tt.wantPrincipal) != 0 || interest.Cmp(tt.wantInterest) != 0 {
+				t.Errorf("CalculatePartialRepayment() = %v, %v, want %v, %v", principal, interest, tt.wantPrincipal, tt.wantInterest)
+			}
+		})
+	}
+}
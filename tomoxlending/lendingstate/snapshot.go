@@ -0,0 +1,100 @@
+// Copyright 2019 The tomochain Authors
+// This file is part of the tomochain library.
+//
+// The tomochain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The tomochain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the tomochain library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// LendingBookSnapshot is the content of a lending book, as exported for
+// relayers to rebuild their off-chain database.
+type LendingBookSnapshot struct {
+	Hash   common.Hash        `json:"hash"`
+	Info   *DumpOrderBookInfo `json:"info"`
+	Orders []LendingItem      `json:"orders"` // resting investing and borrowing orders, by order id
+	Trades []LendingTrade     `json:"trades"` // open loans, by trade id
+}
+
+// GetLendingBookHashes returns the hashes of every lending book of the state,
+// in ascending order.
+func (self *LendingStateDB) GetLendingBookHashes() []common.Hash {
+	seen := map[common.Hash]struct{}{}
+	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	for it.Next() {
+		if lendingBook := common.BytesToHash(it.Key); !common.EmptyHash(lendingBook) {
+			seen[lendingBook] = struct{}{}
+		}
+	}
+	for lendingBook := range self.lendingExchangeStates {
+		seen[lendingBook] = struct{}{}
+	}
+	lendingBooks := make([]common.Hash, 0, len(seen))
+	for lendingBook := range seen {
+		lendingBooks = append(lendingBooks, lendingBook)
+	}
+	sort.Slice(lendingBooks, func(i, j int) bool {
+		return bytes.Compare(lendingBooks[i][:], lendingBooks[j][:]) < 0
+	})
+	return lendingBooks
+}
+
+// SnapshotLendingBook returns the content of the given lending book.
+func (self *LendingStateDB) SnapshotLendingBook(lendingBook common.Hash) (*LendingBookSnapshot, error) {
+	info, err := self.DumpOrderBookInfo(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	investings, err := self.DumpInvestingTrie(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	borrowings, err := self.DumpBorrowingTrie(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	orderIds := []*big.Int{}
+	for _, side := range []map[*big.Int]DumpOrderList{investings, borrowings} {
+		for _, itemList := range side {
+			for orderId, amount := range itemList.Orders {
+				if amount.Sign() > 0 {
+					orderIds = append(orderIds, orderId)
+				}
+			}
+		}
+	}
+	sort.Slice(orderIds, func(i, j int) bool {
+		return orderIds[i].Cmp(orderIds[j]) < 0
+	})
+	snapshot := &LendingBookSnapshot{Hash: lendingBook, Info: info, Orders: make([]LendingItem, 0, len(orderIds))}
+	for _, orderId := range orderIds {
+		order := self.GetLendingOrder(lendingBook, common.BigToHash(orderId))
+		if order == EmptyLendingOrder {
+			return nil, fmt.Errorf("lending order %v of lending book %v not found", orderId, lendingBook.Hex())
+		}
+		snapshot.Orders = append(snapshot.Orders, order)
+	}
+	if snapshot.Trades, err = self.GetOpenLendingTrades(lendingBook); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
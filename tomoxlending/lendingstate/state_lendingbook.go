@@ -21,6 +21,7 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
 	"io"
 	"math/big"
 )
@@ -43,6 +44,11 @@ type lendingExchangeState struct {
 	lendingTradeTrie    Trie
 	liquidationTimeTrie Trie
 
+	// liquidationTimeQueue mirrors the keys of liquidationTimeTrie, it's loaded
+	// lazily and must be in place before the trie is modified, so the trie is
+	// only written through tryUpdateLiquidationTime and tryDeleteLiquidationTime.
+	liquidationTimeQueue *liquidationTimeQueue
+
 	liquidationTimeStates      map[common.Hash]*liquidationTimeState
 	liquidationTimestatesDirty map[common.Hash]struct{}
 
@@ -377,18 +383,40 @@ func (self *lendingExchangeState) updateLiquidationTimeTrie(db Database) Trie {
 		if _, isDirty := self.liquidationTimestatesDirty[time]; isDirty {
 			delete(self.liquidationTimestatesDirty, time)
 			if itemList.empty() {
-				self.setError(tr.TryDelete(time[:]))
+				self.setError(self.tryDeleteLiquidationTime(db, time))
 				continue
 			}
 			itemList.updateRoot(db)
 			// Encoding []byte cannot fail, ok to ignore the error.
 			v, _ := rlp.EncodeToBytes(itemList)
-			self.setError(tr.TryUpdate(time[:], v))
+			self.setError(self.tryUpdateLiquidationTime(db, time, v))
 		}
 	}
 	return tr
 }
 
+// tryUpdateLiquidationTime writes a liquidation time into the liquidation time
+// trie, keeping the liquidation time queue in sync.
+func (self *lendingExchangeState) tryUpdateLiquidationTime(db Database, time common.Hash, value []byte) error {
+	queue := self.getLiquidationTimeQueue(db)
+	if err := self.getLiquidationTimeTrie(db).TryUpdate(time[:], value); err != nil {
+		return err
+	}
+	queue.add(time)
+	return nil
+}
+
+// tryDeleteLiquidationTime removes a liquidation time from the liquidation time
+// trie, keeping the liquidation time queue in sync.
+func (self *lendingExchangeState) tryDeleteLiquidationTime(db Database, time common.Hash) error {
+	queue := self.getLiquidationTimeQueue(db)
+	if err := self.getLiquidationTimeTrie(db).TryDelete(time[:]); err != nil {
+		return err
+	}
+	queue.remove(time)
+	return nil
+}
+
 /**
   Update Root
 */
@@ -509,6 +537,9 @@ func (self *lendingExchangeState) CommitLiquidationTimeTrie(db Database) error {
 	})
 	if err == nil {
 		self.data.LiquidationTimeRoot = root
+		if self.liquidationTimeQueue != nil {
+			db.cacheLiquidationTimes(root, self.liquidationTimeQueue)
+		}
 	}
 	return err
 }
@@ -566,32 +597,64 @@ func (self *lendingExchangeState) getBestBorrowingInterest(db Database) common.H
 	return interest
 }
 
+// getLowestLiquidationTime returns the earliest liquidation time of the book.
+// The queue is reconciled against the trie: a time the trie no longer holds is
+// dropped from the queue, so that the lowest time is always its leftmost key.
 func (self *lendingExchangeState) getLowestLiquidationTime(db Database) (common.Hash, *liquidationTimeState) {
-	trie := self.getLiquidationTimeTrie(db)
-	encKey, encValue, err := trie.TryGetBestLeftKeyAndValue()
-	if err != nil {
-		log.Error("Failed find best liquidation time trie ", "orderBook", self.lendingBook.Hex())
-		return EmptyHash, nil
-	}
-	if len(encKey) == 0 || len(encValue) == 0 {
-		log.Debug("Not found get liquidation time trie", "encKey", encKey, "encValue", encValue)
-		return EmptyHash, nil
-	}
-	price := common.BytesToHash(encKey)
-	obj, exist := self.liquidationTimeStates[price]
-	if !exist {
-		var data itemList
-		if err := rlp.DecodeBytes(encValue, &data); err != nil {
-			log.Error("Failed to decode state get liquidation time trie", "err", err)
+	queue := self.getLiquidationTimeQueue(db)
+	for {
+		time, ok := queue.lowest()
+		if !ok {
+			log.Debug("Not found get liquidation time trie", "orderBook", self.lendingBook.Hex())
+			return EmptyHash, nil
+		}
+		obj, exist := self.liquidationTimeStates[time]
+		if !exist {
+			enc, err := self.getLiquidationTimeTrie(db).TryGet(time[:])
+			if err != nil {
+				log.Error("Failed find best liquidation time trie ", "orderBook", self.lendingBook.Hex(), "time", time.Hex(), "err", err)
+				return EmptyHash, nil
+			}
+			if len(enc) == 0 {
+				log.Warn("Dropped stale liquidation time", "orderBook", self.lendingBook.Hex(), "time", time.Hex())
+				queue.remove(time)
+				continue
+			}
+			var data itemList
+			if err := rlp.DecodeBytes(enc, &data); err != nil {
+				log.Error("Failed to decode state get liquidation time trie", "err", err)
+				return EmptyHash, nil
+			}
+			obj = newLiquidationTimeState(self.lendingBook, time, data, self.MarkLiquidationTimeDirty)
+			self.liquidationTimeStates[time] = obj
+		}
+		if obj.empty() {
 			return EmptyHash, nil
 		}
-		obj = newLiquidationTimeState(self.lendingBook, price, data, self.MarkLiquidationTimeDirty)
-		self.liquidationTimeStates[price] = obj
+		return time, obj
+	}
+}
+
+// getLiquidationTimeQueue returns the min-heap of the liquidation times held in
+// the liquidation time trie. The queue is taken from the database cache by trie
+// root, so queues of blocks on either side of a reorg stay valid, and rebuilt
+// by iterating the trie on a miss.
+func (self *lendingExchangeState) getLiquidationTimeQueue(db Database) *liquidationTimeQueue {
+	if self.liquidationTimeQueue != nil {
+		return self.liquidationTimeQueue
+	}
+	if queue, ok := db.liquidationTimes(self.data.LiquidationTimeRoot); ok {
+		self.liquidationTimeQueue = queue
+		return queue
 	}
-	if obj.empty() {
-		return EmptyHash, nil
+	queue := newLiquidationTimeQueue()
+	it := trie.NewIterator(self.getLiquidationTimeTrie(db).NodeIterator(nil))
+	for it.Next() {
+		queue.add(common.BytesToHash(it.Key))
 	}
-	return price, obj
+	self.setError(it.Err)
+	self.liquidationTimeQueue = queue
+	return queue
 }
 
 func (self *lendingExchangeState) deepCopy(db *LendingStateDB, onDirty func(hash common.Hash)) *lendingExchangeState {
@@ -766,7 +829,7 @@ func (self *lendingExchangeState) createLiquidationTime(db Database, time common
 	if err != nil {
 		panic(fmt.Errorf("can't encode liquidation time at %x: %v", time[:], err))
 	}
-	self.setError(self.getLiquidationTimeTrie(db).TryUpdate(time[:], data))
+	self.setError(self.tryUpdateLiquidationTime(db, time, data))
 	if self.onDirty != nil {
 		self.onDirty(self.lendingBook)
 		self.onDirty = nil
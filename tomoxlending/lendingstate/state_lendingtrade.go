@@ -69,6 +69,14 @@ func (self *lendingTradeState) SetLiquidationPrice(price *big.Int) {
 	}
 }
 
+func (self *lendingTradeState) SetInterest(interest uint64) {
+	self.data.Interest = interest
+	if self.onDirty != nil {
+		self.onDirty(self.tradeId)
+		self.onDirty = nil
+	}
+}
+
 func (self *lendingTradeState) SetAmount(amount *big.Int) {
 	self.data.Amount = amount
 	if self.onDirty != nil {
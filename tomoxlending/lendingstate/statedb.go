@@ -20,14 +20,19 @@ package lendingstate
 import (
 	"fmt"
 	"math/big"
+	"runtime"
 	"sort"
 	"sync"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/rlp"
 )
 
+// LendingTrieSize accounts the nodes committed to the lending state tries.
+var LendingTrieSize = state.NewTrieSizeCounter("lending")
+
 type revision struct {
 	id           int
 	journalIndex int
@@ -213,6 +218,40 @@ func (self *LendingStateDB) UpdateCollateralLockedAmount(orderBook common.Hash,
 	})
 	stateLendingTrade.SetCollateralLockedAmount(amount)
 }
+
+// UpdateLendingTradeAmount sets the outstanding principal of a lending trade,
+// once part of it is repaid.
+func (self *LendingStateDB) UpdateLendingTradeAmount(orderBook common.Hash, tradeId uint64, amount *big.Int) {
+	tradeIdHash := common.Uint64ToHash(tradeId)
+	stateExchange := self.getLendingExchange(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createLendingExchangeObject(orderBook)
+	}
+	stateLendingTrade := stateExchange.getLendingTrade(self.db, tradeIdHash)
+	self.journal = append(self.journal, tradeAmountChange{
+		orderBook: orderBook,
+		tradeId:   tradeIdHash,
+		prev:      stateLendingTrade.data.Amount,
+	})
+	stateLendingTrade.SetAmount(amount)
+}
+
+// UpdateLendingTradeInterest sets the interest rate of a lending trade, as its
+// loan is repriced by a variable interest rate model.
+func (self *LendingStateDB) UpdateLendingTradeInterest(orderBook common.Hash, tradeId uint64, interest uint64) {
+	tradeIdHash := common.Uint64ToHash(tradeId)
+	stateExchange := self.getLendingExchange(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createLendingExchangeObject(orderBook)
+	}
+	stateLendingTrade := stateExchange.getLendingTrade(self.db, tradeIdHash)
+	self.journal = append(self.journal, tradeInterestChange{
+		orderBook: orderBook,
+		tradeId:   tradeIdHash,
+		prev:      stateLendingTrade.data.Interest,
+	})
+	stateLendingTrade.SetInterest(interest)
+}
 func (self *LendingStateDB) GetLendingOrder(orderBook common.Hash, orderId common.Hash) LendingItem {
 	stateObject := self.GetOrNewLendingExchangeObject(orderBook)
 	if stateObject == nil {
@@ -397,6 +436,28 @@ func (self *LendingStateDB) GetBestLendingIdAndAmount(orderBook common.Hash, pri
 	return EmptyHash, Zero, fmt.Errorf("not found orderBook : %s ", orderBook.Hex())
 }
 
+// LendingBookTrieRoots decodes a lending book object, as stored in the leaves of
+// the lending state trie, and returns the roots of its investing, borrowing,
+// liquidation time, lending item and lending trade tries.
+func LendingBookTrieRoots(leaf []byte) ([]common.Hash, error) {
+	var data lendingObject
+	if err := rlp.DecodeBytes(leaf, &data); err != nil {
+		return nil, err
+	}
+	return []common.Hash{data.InvestingRoot, data.BorrowingRoot, data.LiquidationTimeRoot, data.LendingItemRoot, data.LendingTradeRoot}, nil
+}
+
+// ItemListTrieRoots decodes an item list, as stored in the leaves of the
+// investing, borrowing and liquidation time tries, and returns the root of the
+// trie holding its entries.
+func ItemListTrieRoots(leaf []byte) ([]common.Hash, error) {
+	var data itemList
+	if err := rlp.DecodeBytes(leaf, &data); err != nil {
+		return nil, err
+	}
+	return []common.Hash{data.Root}, nil
+}
+
 // updateLendingExchange writes the given object to the trie.
 func (self *LendingStateDB) updateLendingExchange(stateObject *lendingExchangeState) {
 	addr := stateObject.Hash()
@@ -548,29 +609,44 @@ func (s *LendingStateDB) IntermediateRoot() common.Hash {
 // Commit writes the state to the underlying in-memory trie database.
 func (s *LendingStateDB) Commit() (root common.Hash, err error) {
 	defer s.clearJournalAndRefund()
-	// Commit objects to the trie.
+	nodes, size := s.db.TrieDB().Inserted()
+	defer func() { LendingTrieSize.MarkSince(s.db.TrieDB(), nodes, size) }()
+	// Commit objects to the trie. The tries of distinct lending books are
+	// independent, so they're committed concurrently.
+	var (
+		dirties []*lendingExchangeState
+		books   []common.Hash
+	)
 	for addr, stateObject := range s.lendingExchangeStates {
 		if _, isDirty := s.lendingExchangeStatesDirty[addr]; isDirty {
-			// Write any storage changes in the state object to its storage trie.
-			if err := stateObject.CommitInvestingTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			if err := stateObject.CommitBorrowingTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			if err := stateObject.CommitLendingItemTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			if err := stateObject.CommitLendingTradeTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			if err := stateObject.CommitLiquidationTimeTrie(s.db); err != nil {
-				return EmptyHash, err
-			}
-			// Update the object in the main tradeId trie.
-			s.updateLendingExchange(stateObject)
-			delete(s.lendingExchangeStatesDirty, addr)
+			dirties = append(dirties, stateObject)
+			books = append(books, addr)
+		}
+	}
+	err = commitConcurrently(len(dirties), func(i int) error {
+		// Write any storage changes in the state object to its storage trie.
+		stateObject := dirties[i]
+		if err := stateObject.CommitInvestingTrie(s.db); err != nil {
+			return err
 		}
+		if err := stateObject.CommitBorrowingTrie(s.db); err != nil {
+			return err
+		}
+		if err := stateObject.CommitLendingItemTrie(s.db); err != nil {
+			return err
+		}
+		if err := stateObject.CommitLendingTradeTrie(s.db); err != nil {
+			return err
+		}
+		return stateObject.CommitLiquidationTimeTrie(s.db)
+	})
+	if err != nil {
+		return EmptyHash, err
+	}
+	for i, stateObject := range dirties {
+		// Update the object in the main tradeId trie.
+		s.updateLendingExchange(stateObject)
+		delete(s.lendingExchangeStatesDirty, books[i])
 	}
 	// Write trie changes.
 	root, err = s.trie.Commit(func(leaf []byte, parent common.Hash) error {
@@ -599,6 +675,42 @@ func (s *LendingStateDB) Commit() (root common.Hash, err error) {
 	return root, err
 }
 
+// commitConcurrently runs commit for every index in [0, n) on a pool of at most
+// one worker per CPU, and returns the error of the lowest failing index.
+func commitConcurrently(n int, commit func(i int) error) error {
+	workers := runtime.NumCPU()
+	if workers > n {
+		workers = n
+	}
+	var (
+		jobs = make(chan int, n)
+		errs = make([]error, n)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = commit(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (self *LendingStateDB) InsertLiquidationTime(lendingBook common.Hash, time *big.Int, tradeId uint64) {
 	timeHash := common.BigToHash(time)
 	lendingExchangeState := self.getLendingExchange(lendingBook)
@@ -630,7 +742,7 @@ func (self *LendingStateDB) RemoveLiquidationTime(lendingBook common.Hash, trade
 	liquidationTime.removeTradeId(self.db, tradeIdHash)
 	liquidationTime.subVolume(One)
 	if liquidationTime.Volume().Sign() == 0 {
-		lendingExchangeState.getLiquidationTimeTrie(self.db).TryDelete(timeHash[:])
+		lendingExchangeState.tryDeleteLiquidationTime(self.db, timeHash)
 	}
 	return nil
 }
@@ -258,3 +258,36 @@ func TestDumpStates(t *testing.T) {
 	fmt.Println(statedb.DumpBorrowingTrie(orderBook))
 	db.Close()
 }
+
+func TestSnapshotLendingBook(t *testing.T) {
+	lendingBook := common.StringToHash("TOMO/1Y")
+	stateCache := NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := New(common.Hash{}, stateCache)
+	orders := []LendingItem{
+		{LendingId: 2, Quantity: big.NewInt(10), Interest: big.NewInt(5), Side: Investing, Signature: &Signature{V: 1}},
+		{LendingId: 1, Quantity: big.NewInt(20), Interest: big.NewInt(8), Side: Borrowing, Signature: &Signature{V: 1}},
+	}
+	for _, order := range orders {
+		statedb.InsertLendingItem(lendingBook, common.BigToHash(new(big.Int).SetUint64(order.LendingId)), order)
+	}
+	statedb.InsertTradingItem(lendingBook, 1, LendingTrade{TradeId: 1, Amount: big.NewInt(5)})
+	statedb.InsertTradingItem(lendingBook, 2, LendingTrade{TradeId: 2, Amount: big.NewInt(0)})
+	root, err := statedb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+	statedb, _ = New(root, stateCache)
+	if have := statedb.GetLendingBookHashes(); len(have) != 1 || have[0] != lendingBook {
+		t.Fatalf("lending books mismatch: have %v, want %v", have, lendingBook)
+	}
+	snapshot, err := statedb.SnapshotLendingBook(lendingBook)
+	if err != nil {
+		t.Fatalf("failed to snapshot lending book: %v", err)
+	}
+	if len(snapshot.Orders) != 2 || snapshot.Orders[0].LendingId != 1 || snapshot.Orders[1].LendingId != 2 {
+		t.Errorf("lending orders mismatch: have %+v", snapshot.Orders)
+	}
+	if len(snapshot.Trades) != 1 || snapshot.Trades[0].TradeId != 1 {
+		t.Errorf("open trades mismatch: have %+v", snapshot.Trades)
+	}
+}
@@ -16,6 +16,7 @@ const (
 	TradeStatusOpen       = "OPEN"
 	TradeStatusClosed     = "CLOSED"
 	TradeStatusLiquidated = "LIQUIDATED"
+	TradeStatusAuction    = "AUCTION"
 )
 
 type LendingTrade struct {
@@ -50,6 +51,11 @@ type LendingTrade struct {
 	ExtraData              string         `bson:"extraData" json:"extraData"`
 	CreatedAt              time.Time      `bson:"createdAt" json:"createdAt"`
 	UpdatedAt              time.Time      `bson:"updatedAt" json:"updatedAt"`
+
+	// Highest interest rate at which the trade is rolled over at maturity
+	AutoRenewRate uint64 `json:"autoRenewRate,omitempty" rlp:"optional"`
+	// Auction of the collateral of the trade, once liquidated
+	Auction *LendingAuction `json:"auction,omitempty" rlp:"optional"`
 }
 
 type LendingTradeBSON struct {
@@ -188,3 +194,12 @@ func (t *LendingTrade) ComputeHash() common.Hash {
 	sha.Write(t.BorrowingOrderHash.Bytes())
 	return common.BytesToHash(sha.Sum(nil))
 }
+
+// ComputeRenewalHash computes the hash of a trade rolling over the trade of the
+// given hash, the borrowing order of both being the same.
+func (t *LendingTrade) ComputeRenewalHash(renewed common.Hash) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(t.InvestingOrderHash.Bytes())
+	sha.Write(renewed.Bytes())
+	return common.BytesToHash(sha.Sum(nil))
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/params"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
 	"math/big"
@@ -61,6 +62,11 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		rejects = append(rejects, order)
 		return trades, rejects, nil
 	}
+	if order.AutoRenewRate != 0 && !chain.Config().IsTIPTomoXAutoRenew(header.Number) {
+		log.Debug("auto-renewal not active", "order", lendingstate.ToJSON(order))
+		rejects = append(rejects, order)
+		return trades, rejects, nil
+	}
 
 	switch order.Type {
 	case lendingstate.TopUp:
@@ -70,7 +76,7 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 		trades = append(trades, newLendingTrade)
 		return trades, rejects, nil
-	case lendingstate.Repay:
+	case lendingstate.Repay, lendingstate.PartialRepay:
 		lendingTrade, err := l.ProcessRepay(header, chain, lendingStateDB, statedb, tradingStateDb, lendingOrderBook, order)
 		if err != nil {
 			log.Debug("Can not process payment", "err", err)
@@ -78,6 +84,19 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 		trades = append(trades, lendingTrade)
 		return trades, rejects, nil
+	case lendingstate.AuctionBid:
+		if !chain.Config().IsTIPTomoXDutchAuction(header.Number) {
+			log.Debug("collateral auctions not active", "order", lendingstate.ToJSON(order))
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+		lendingTrade, err := l.ProcessAuctionBid(header, lendingStateDB, statedb, lendingOrderBook, order)
+		if err != nil {
+			log.Debug("Can not process auction bid", "err", err)
+			rejects = append(rejects, order)
+		}
+		trades = append(trades, lendingTrade)
+		return trades, rejects, nil
 	default:
 	}
 
@@ -266,7 +285,8 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 			return nil, nil, nil, fmt.Errorf("empty collateral")
 		}
 		collateralPrice := common.BasePrice
-		depositRate, liquidationRate, recallRate := lendingstate.GetCollateralDetail(statedb, collateralToken)
+		riskParameters := l.GetRiskParameters(chain.Config(), header, statedb, collateralToken)
+		depositRate, liquidationRate, recallRate := riskParameters.DepositRate, riskParameters.LiquidationRate, riskParameters.RecallRate
 		if depositRate == nil || depositRate.Sign() <= 0 {
 			return nil, nil, nil, fmt.Errorf("invalid depositRate %v", depositRate)
 		}
@@ -376,6 +396,7 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 				lendingTrade.Borrower = order.UserAddress
 				lendingTrade.Investor = oldestOrder.UserAddress
 				lendingTrade.AutoTopUp = order.AutoTopUp
+				lendingTrade.AutoRenewRate = order.AutoRenewRate
 				// fee
 				if settleBalanceResult != nil {
 					lendingTrade.BorrowingFee = settleBalanceResult.Taker.Fee
@@ -389,6 +410,7 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 				lendingTrade.Borrower = oldestOrder.UserAddress
 				lendingTrade.Investor = order.UserAddress
 				lendingTrade.AutoTopUp = oldestOrder.AutoTopUp
+				lendingTrade.AutoRenewRate = oldestOrder.AutoRenewRate
 				// fee
 				if settleBalanceResult != nil {
 					lendingTrade.BorrowingFee = settleBalanceResult.Maker.Fee
@@ -781,6 +803,9 @@ func (l *Lending) ProcessTopUp(lendingStateDB *lendingstate.LendingStateDB, stat
 		log.Debug("ProcessTopUp: invalid quantity", "Quantity", order.Quantity, "lendingTradeId", lendingTradeId.Hex())
 		return nil, true, nil
 	}
+	if lendingTrade.Auction != nil {
+		return fmt.Errorf("ProcessTopUp: collateral in auction. lendingTradeId: %v", lendingTradeId.Hex()), true, nil
+	}
 	return l.ProcessTopUpLendingTrade(lendingStateDB, statedb, tradingStateDb, lendingTradeId, lendingBook, order.Quantity)
 }
 
@@ -798,6 +823,22 @@ func (l *Lending) ProcessRepay(header *types.Header, chain consensus.ChainContex
 	if order.Relayer.String() != lendingTrade.BorrowingRelayer.String() {
 		return nil, fmt.Errorf("ProcessRepay: invalid relayerAddress . Got: %s . Expect: %s", order.Relayer.Hex(), lendingTrade.BorrowingRelayer.Hex())
 	}
+	if lendingTrade.Auction != nil {
+		return nil, fmt.Errorf("ProcessRepay: collateral in auction. lendingTradeId: %v", lendingTradeId)
+	}
+	if order.Type == lendingstate.PartialRepay {
+		if !chain.Config().IsTIPTomoXPartialRepay(header.Number) {
+			return nil, fmt.Errorf("ProcessRepay: partial repayment not active. lendingTradeId: %v", lendingTradeId)
+		}
+		if order.Quantity == nil || order.Quantity.Sign() <= 0 {
+			return nil, fmt.Errorf("ProcessRepay: invalid partial repayment quantity. Quantity: %v", order.Quantity)
+		}
+		// repaying at least the total repay value closes the trade as usual
+		paymentBalance := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+		if order.Quantity.Cmp(paymentBalance) < 0 {
+			return l.ProcessPartialRepayLendingTrade(header, lendingStateDB, statedb, lendingBook, lendingTradeId, order.Quantity)
+		}
+	}
 	return l.ProcessRepayLendingTrade(header, chain, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId)
 }
 
@@ -818,12 +859,16 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 		// repayAmount= CollateralLockedAmount * LiquidationPrice / collateralPrice + interestAmount
 		repayAmount = new(big.Int).Mul(lendingTrade.CollateralLockedAmount, lendingTrade.LiquidationPrice)
 		repayAmount = new(big.Int).Div(repayAmount, collateralPrice)
-		_, liquidationRate, _ := lendingstate.GetCollateralDetail(statedb, lendingTrade.CollateralToken)
+		riskParameters := l.GetRiskParameters(chain.Config(), header, statedb, lendingTrade.CollateralToken)
 		collateralAmount := new(big.Int).Mul(repayAmount, big.NewInt(100))
-		collateralAmount = new(big.Int).Div(collateralAmount, liquidationRate)
+		collateralAmount = new(big.Int).Div(collateralAmount, riskParameters.LiquidationRate)
 		totalCollateralAmount := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, collateralAmount)
 		interestAmount := new(big.Int).Sub(totalCollateralAmount, collateralAmount)
 		repayAmount = new(big.Int).Add(repayAmount, interestAmount)
+		// the penalty is taken out of the collateral recalled to the borrower
+		penaltyAmount := new(big.Int).Mul(repayAmount, riskParameters.LiquidationPenalty)
+		penaltyAmount = new(big.Int).Div(penaltyAmount, big.NewInt(100))
+		repayAmount = new(big.Int).Add(repayAmount, penaltyAmount)
 	}
 
 	recallAmount := common.Big0
@@ -891,6 +936,153 @@ func (l *Lending) LiquidationTrade(lendingStateDB *lendingstate.LendingStateDB,
 	return &lendingTrade, nil
 }
 
+// StartLendingAuction puts the collateral of a liquidated lending trade up for a
+// descending-price auction, opening above its value at the given collateral
+// price. The trade is no longer liquidated by price and falls due at the end of
+// the auction, when the collateral goes to the investor if it wasn't sold.
+func (l *Lending) StartLendingAuction(header *types.Header, chain consensus.ChainContext, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingstateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, collateralPrice *big.Int, reason uint64) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade.TradeId != lendingTradeId {
+		return nil, fmt.Errorf("Lending Trade Id not found : %d ", lendingTradeId)
+	}
+	collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, lendingTrade.CollateralToken)
+	if err != nil {
+		return nil, fmt.Errorf("StartLendingAuction: failed to get collateralTokenDecimal. err: %v", err)
+	}
+	// collateralValue = CollateralLockedAmount * collateralPrice / collateralTokenDecimal
+	collateralValue := new(big.Int).Mul(lendingTrade.CollateralLockedAmount, collateralPrice)
+	collateralValue = new(big.Int).Div(collateralValue, collateralTokenDecimal)
+	time := header.Time.Uint64()
+	debt := lendingstate.CalculateTotalRepayValue(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	auction := lendingstate.NewLendingAuction(header.Number.Uint64(), collateralValue, debt, reason)
+
+	// the unsold collateral is settled at the first liquidation after the end of the auction
+	period := uint64(1)
+	if epochs := chain.Config().ExchangeEpochs(); epochs != nil && epochs.Period > 0 {
+		period = epochs.Period
+	}
+	endTime := time + common.LendingAuctionBlocks*period
+	log.Debug("StartLendingAuction", "lendingTradeId", lendingTradeId, "collateralValue", collateralValue, "debt", debt, "startPrice", auction.StartPrice, "floorPrice", auction.FloorPrice, "endTime", endTime)
+
+	err = tradingstateDB.RemoveLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
+	if err != nil {
+		log.Debug("StartLendingAuction RemoveLiquidationPrice", "err", err)
+		return nil, err
+	}
+	err = lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
+	if err != nil {
+		log.Debug("StartLendingAuction RemoveLiquidationTime", "err", err)
+		return nil, err
+	}
+	newLendingTrade := lendingTrade
+	newLendingTrade.Auction = auction
+	newLendingTrade.LiquidationTime = endTime
+	lendingStateDB.InsertTradingItem(lendingBook, lendingTradeId, newLendingTrade)
+	lendingStateDB.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(endTime), lendingTradeId)
+
+	newLendingTrade.Status = lendingstate.TradeStatusAuction
+	extraData, _ := json.Marshal(auction)
+	newLendingTrade.ExtraData = string(extraData)
+	return &newLendingTrade, nil
+}
+
+// ProcessAuctionBid sells the collateral of a lending trade in auction to the
+// bidder, if its bid isn't below the current price of the auction. The price
+// repays the investor first, the borrower gets the surplus.
+func (l *Lending) ProcessAuctionBid(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash, order *lendingstate.LendingItem) (*lendingstate.LendingTrade, error) {
+	lendingTradeId := order.LendingTradeId
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade || lendingTrade.TradeId != lendingTradeId {
+		return nil, fmt.Errorf("ProcessAuctionBid for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	if lendingTrade.Auction == nil {
+		return nil, fmt.Errorf("ProcessAuctionBid: collateral not in auction. lendingTradeId: %v", lendingTradeId)
+	}
+	price := lendingTrade.Auction.Price(header.Number.Uint64())
+	if order.Quantity == nil || order.Quantity.Cmp(price) < 0 {
+		return nil, fmt.Errorf("ProcessAuctionBid: bid below the auction price. lendingTradeId: %v , bid: %v , price: %v", lendingTradeId, order.Quantity, price)
+	}
+	tokenBalance := lendingstate.GetTokenBalance(order.UserAddress, lendingTrade.LendingToken, statedb)
+	if tokenBalance.Cmp(price) < 0 {
+		return nil, fmt.Errorf("Not enough balance need : %s , have : %s ", price, tokenBalance)
+	}
+	repayAmount, surplus := lendingTrade.Auction.Proceeds(price)
+	log.Debug("ProcessAuctionBid", "lendingTradeId", lendingTradeId, "bidder", order.UserAddress.Hex(), "price", price, "repayAmount", repayAmount, "surplus", surplus)
+
+	lendingstate.SubTokenBalance(order.UserAddress, price, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, repayAmount, lendingTrade.LendingToken, statedb)
+	if surplus.Sign() > 0 {
+		lendingstate.AddTokenBalance(lendingTrade.Borrower, surplus, lendingTrade.LendingToken, statedb)
+	}
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(order.UserAddress, lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+
+	err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
+	if err != nil {
+		log.Debug("ProcessAuctionBid RemoveLiquidationTime", "err", err)
+		return nil, err
+	}
+	err = lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId)
+	if err != nil {
+		log.Debug("ProcessAuctionBid CancelLendingTrade", "err", err)
+		return nil, err
+	}
+	lendingTrade.Status = lendingstate.TradeStatusLiquidated
+	extraData, _ := json.Marshal(struct {
+		lendingstate.LiquidationData
+		Bidder      common.Address
+		Price       *big.Int
+		RepayAmount *big.Int
+		Surplus     *big.Int
+	}{
+		LiquidationData: lendingstate.LiquidationData{
+			RecallAmount:      common.Big0,
+			LiquidationAmount: lendingTrade.CollateralLockedAmount,
+			CollateralPrice:   common.Big0,
+			Reason:            lendingstate.LiquidatedByAuction,
+		},
+		Bidder:      order.UserAddress,
+		Price:       price,
+		RepayAmount: repayAmount,
+		Surplus:     surplus,
+	})
+	lendingTrade.ExtraData = string(extraData)
+	return &lendingTrade, nil
+}
+
+// CloseLendingAuction closes the auction of a collateral which wasn't sold,
+// liquidating the trade as it was before auctions: all the collateral goes to
+// the investor.
+func (l *Lending) CloseLendingAuction(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash, lendingTradeId uint64) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade.TradeId != lendingTradeId || lendingTrade.Auction == nil {
+		return nil, fmt.Errorf("Lending auction not found : %d ", lendingTradeId)
+	}
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+
+	err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
+	if err != nil {
+		log.Debug("CloseLendingAuction RemoveLiquidationTime", "err", err)
+		return nil, err
+	}
+	err = lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId)
+	if err != nil {
+		log.Debug("CloseLendingAuction CancelLendingTrade", "err", err)
+		return nil, err
+	}
+	lendingTrade.Status = lendingstate.TradeStatusLiquidated
+	liquidationData := lendingstate.LiquidationData{
+		RecallAmount:      common.Big0,
+		LiquidationAmount: lendingTrade.CollateralLockedAmount,
+		CollateralPrice:   common.Big0,
+		Reason:            lendingTrade.Auction.Reason,
+	}
+	extraData, _ := json.Marshal(liquidationData)
+	lendingTrade.ExtraData = string(extraData)
+	return &lendingTrade, nil
+}
+
 // cancellation fee = 1/10 borrowing fee
 // deprecated after hardfork at TIPTomoXCancellationFee
 func getCancelFeeV1(collateralTokenDecimal *big.Int, collateralPrice, borrowFee *big.Int, order *lendingstate.LendingItem) *big.Int {
@@ -953,6 +1145,31 @@ func (l *Lending) GetMediumTradePriceBeforeEpoch(chain consensus.ChainContext, s
 	return nil, nil
 }
 
+// GetContractPrice returns the price of a token in terms of a quote token set in
+// the lending contract, and whether it can be used at the given block. Since
+// TIPTomoXOracle the price is the median of the prices reported by the feeders
+// of the pair, before that the single price of the pair is used if it was
+// updated in the current epoch.
+func (l *Lending) GetContractPrice(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, token common.Address, quoteToken common.Address) (*big.Int, bool) {
+	if chain.Config().IsTIPTomoXOracle(header.Number) {
+		return lendingstate.GetOraclePrice(statedb, token, quoteToken, header.Number.Uint64())
+	}
+	price, updatedBlock := lendingstate.GetCollateralPrice(statedb, token, quoteToken)
+	epochs := chain.Config().ExchangeEpochs()
+	return price, epochs.EpochOf(updatedBlock.Uint64()) == epochs.EpochOf(header.Number.Uint64())
+}
+
+// GetRiskParameters returns the risk parameters of the loans backed by a
+// collateral at the given block. Since TIPTomoXRiskGovernance they are those the
+// governance of the lending contract scheduled for the epoch of the block, the
+// rates of the collateral being used until it schedules any.
+func (l *Lending) GetRiskParameters(config *params.ChainConfig, header *types.Header, statedb *state.StateDB, collateralToken common.Address) *lendingstate.RiskParameters {
+	if epochs := config.ExchangeEpochs(); config.IsTIPTomoXRiskGovernance(header.Number) && epochs != nil && epochs.Epoch > 0 {
+		return lendingstate.GetRiskParameters(statedb, collateralToken, epochs.EpochOf(header.Number.Uint64()))
+	}
+	return lendingstate.GetCollateralRiskParameters(statedb, collateralToken)
+}
+
 //LendToken and CollateralToken must meet at least one of following conditions
 //- Have direct pair in TomoX: lendToken/CollateralToken or CollateralToken/LendToken
 //- Have pairs with TOMO:
@@ -963,8 +1180,7 @@ func (l *Lending) GetCollateralPrices(header *types.Header, chain consensus.Chai
 	// collateralTOMOPrice: price of ticker collateralToken/TOMO
 	// collateralPrice: price of ticker collateralToken/lendToken
 
-	collateralPriceFromContract, updatedBlock := lendingstate.GetCollateralPrice(statedb, collateralToken, lendingToken)
-	collateralPriceUpdatedFromContract := updatedBlock.Uint64()/chain.Config().Posv.Epoch == header.Number.Uint64()/chain.Config().Posv.Epoch
+	collateralPriceFromContract, collateralPriceUpdatedFromContract := l.GetContractPrice(header, chain, statedb, collateralToken, lendingToken)
 
 	lendTokenTOMOPrice, err := l.GetTOMOBasePrices(header, chain, statedb, tradingStateDb, lendingToken)
 	if err != nil {
@@ -985,8 +1201,7 @@ func (l *Lending) GetCollateralPrices(header *types.Header, chain consensus.Chai
 		return nil, nil, err
 	}
 	var collateralPrice *big.Int
-	inverseCollateralPriceFromContract, updatedBlock := lendingstate.GetCollateralPrice(statedb, lendingToken, collateralToken)
-	inverseCollateralPriceUpdatedFromContract := updatedBlock.Uint64()/chain.Config().Posv.Epoch == header.Number.Uint64()/chain.Config().Posv.Epoch
+	inverseCollateralPriceFromContract, inverseCollateralPriceUpdatedFromContract := l.GetContractPrice(header, chain, statedb, lendingToken, collateralToken)
 	if inverseCollateralPriceUpdatedFromContract {
 		log.Debug("Getting lending/collateral token price from contract", "price", inverseCollateralPriceFromContract)
 		collateralPrice = new(big.Int).Mul(lendingTokenDecimal, collateralTokenDecimal)
@@ -1020,8 +1235,7 @@ func (l *Lending) GetCollateralPrices(header *types.Header, chain consensus.Chai
 
 func (l *Lending) GetTOMOBasePrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, token common.Address) (*big.Int, error) {
 
-	tokenTOMOPriceFromContract, updatedBlock := lendingstate.GetCollateralPrice(statedb, token, common.HexToAddress(common.TomoNativeAddress))
-	tokenTOMOPriceUpdatedFromContract := updatedBlock.Uint64()/chain.Config().Posv.Epoch == header.Number.Uint64()/chain.Config().Posv.Epoch
+	tokenTOMOPriceFromContract, tokenTOMOPriceUpdatedFromContract := l.GetContractPrice(header, chain, statedb, token, common.HexToAddress(common.TomoNativeAddress))
 
 	if token == common.HexToAddress(common.TomoNativeAddress) {
 		return common.BasePrice, nil
@@ -1031,8 +1245,7 @@ func (l *Lending) GetTOMOBasePrices(header *types.Header, chain consensus.ChainC
 		log.Debug("Getting token/TOMO price from contract", "price", tokenTOMOPriceFromContract)
 		return tokenTOMOPriceFromContract, nil
 	} else {
-		tomoTokenPriceFromContract, updatedBlock := lendingstate.GetCollateralPrice(statedb, common.HexToAddress(common.TomoNativeAddress), token)
-		tomoTokenPriceUpdatedFromContract := updatedBlock.Uint64()/chain.Config().Posv.Epoch == header.Number.Uint64()/chain.Config().Posv.Epoch
+		tomoTokenPriceFromContract, tomoTokenPriceUpdatedFromContract := l.GetContractPrice(header, chain, statedb, common.HexToAddress(common.TomoNativeAddress), token)
 		if tomoTokenPriceUpdatedFromContract && tomoTokenPriceFromContract != nil && tomoTokenPriceFromContract.Sign() > 0 {
 			// getting lendToken price from contract first
 			// otherwise, getting from tomox lendToken/TOMO
@@ -1120,6 +1333,12 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 		return nil, fmt.Errorf("ProcessRepayLendingTrade for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
 	}
 	time := header.Time.Uint64()
+	if lendingTrade.Auction != nil {
+		if lendingTrade.LiquidationTime > time {
+			return nil, fmt.Errorf("ProcessRepayLendingTrade: collateral in auction. lendingTradeId: %v", lendingTradeId)
+		}
+		return l.CloseLendingAuction(lendingStateDB, statedb, lendingBook, lendingTradeId)
+	}
 	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
 	paymentBalance := lendingstate.CalculateTotalRepayValue(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
 	log.Debug("ProcessRepay", "totalInterest", new(big.Int).Sub(paymentBalance, lendingTrade.Amount), "totalRepayValue", paymentBalance, "token", lendingTrade.LendingToken.Hex())
@@ -1128,6 +1347,14 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 		if lendingTrade.LiquidationTime > time {
 			return nil, fmt.Errorf("Not enough balance need : %s , have : %s ", paymentBalance, tokenBalance)
 		}
+		if chain.Config().IsTIPTomoXDutchAuction(header.Number) {
+			_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingstateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken)
+			if err == nil && collateralPrice != nil && collateralPrice.Sign() > 0 {
+				return l.StartLendingAuction(header, chain, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId, collateralPrice, lendingstate.LiquidatedByTime)
+			}
+			// if cannot get collateralPrice, liquidate as before auctions
+			log.Error("ProcessRepayLendingTrade: cannot get collateralPrice", "err", err)
+		}
 		newLendingTrade := &lendingstate.LendingTrade{}
 		var err error
 		if chain.Config().IsTIPTomoXLending(header.Number) {
@@ -1185,6 +1412,193 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 	return &lendingTrade, nil
 }
 
+// ProcessPartialRepayLendingTrade repays part of a lending trade before its
+// maturity. The payment covers the principal it repays and the interest accrued
+// on it, the collateral securing that principal is released to the borrower and
+// the rest of the trade stays open, with an unchanged liquidation price.
+func (l *Lending) ProcessPartialRepayLendingTrade(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, lendingBook common.Hash, lendingTradeId uint64, quantity *big.Int) (*lendingstate.LendingTrade, error) {
+	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeIdHash)
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	time := header.Time.Uint64()
+	if lendingTrade.LiquidationTime <= time {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: lendingTrade already due. lendingTradeId: %v , liquidationTime: %v", lendingTradeId, lendingTrade.LiquidationTime)
+	}
+	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
+	if tokenBalance.Cmp(quantity) < 0 {
+		return nil, fmt.Errorf("Not enough balance need : %s , have : %s ", quantity, tokenBalance)
+	}
+	principal, interest := lendingstate.CalculatePartialRepayment(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount, quantity)
+	if principal.Sign() <= 0 || principal.Cmp(lendingTrade.Amount) >= 0 {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: invalid repaid principal. lendingTradeId: %v , principal: %v , amount: %v", lendingTradeId, principal, lendingTrade.Amount)
+	}
+	// recallAmount = CollateralLockedAmount * principal / Amount
+	recallAmount := new(big.Int).Mul(lendingTrade.CollateralLockedAmount, principal)
+	recallAmount = new(big.Int).Div(recallAmount, lendingTrade.Amount)
+	newAmount := new(big.Int).Sub(lendingTrade.Amount, principal)
+	newLockedAmount := new(big.Int).Sub(lendingTrade.CollateralLockedAmount, recallAmount)
+	log.Debug("ProcessPartialRepay", "lendingTradeId", lendingTradeId, "principal", principal, "interest", interest, "recallAmount", recallAmount, "newAmount", newAmount, "newLockedAmount", newLockedAmount)
+
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, quantity, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, quantity, lendingTrade.LendingToken, statedb)
+
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), recallAmount, lendingTrade.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Borrower, recallAmount, lendingTrade.CollateralToken, statedb)
+
+	lendingStateDB.UpdateLendingTradeAmount(lendingBook, lendingTradeId, newAmount)
+	lendingStateDB.UpdateCollateralLockedAmount(lendingBook, lendingTradeId, newLockedAmount)
+
+	newLendingTrade := lendingTrade
+	newLendingTrade.Amount = newAmount
+	newLendingTrade.CollateralLockedAmount = newLockedAmount
+	newLendingTrade.Status = lendingstate.TradeStatusOpen
+	extraData, _ := json.Marshal(struct {
+		Profit       *big.Int
+		RepayAmount  *big.Int
+		RecallAmount *big.Int
+	}{
+		Profit:       interest,
+		RepayAmount:  principal,
+		RecallAmount: recallAmount,
+	})
+	newLendingTrade.ExtraData = string(extraData)
+	return &newLendingTrade, nil
+}
+
+// ProcessRenewLendingTrade rolls a lending trade over at maturity into a new
+// term, funded by the oldest investing offer at the best rate if that rate
+// doesn't exceed the renewal rate of the trade. The new investor pays off the
+// principal, the borrower the interest due, and the collateral stays locked for
+// the new trade. Returns the closed and the new trades, nils if the trade can't
+// be renewed.
+func (l *Lending) ProcessRenewLendingTrade(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingstateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64) (*lendingstate.LendingTrade, *lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade || lendingTrade.AutoRenewRate == 0 || lendingTrade.Auction != nil {
+		return nil, nil, nil
+	}
+	rate, _ := lendingStateDB.GetBestInvestingRate(lendingBook)
+	if rate.Sign() == 0 || rate.Cmp(new(big.Int).SetUint64(lendingTrade.AutoRenewRate)) > 0 {
+		return nil, nil, nil
+	}
+	offerId, offerAmount, err := lendingStateDB.GetBestLendingIdAndAmount(lendingBook, rate, lendingstate.Investing)
+	if err != nil {
+		return nil, nil, err
+	}
+	offer := lendingStateDB.GetLendingOrder(lendingBook, offerId)
+	if offerAmount.Cmp(lendingTrade.Amount) < 0 || offer.UserAddress == lendingTrade.Borrower {
+		return nil, nil, nil
+	}
+	time := header.Time.Uint64()
+	paymentBalance := lendingstate.CalculateTotalRepayValue(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	interest := new(big.Int).Sub(paymentBalance, lendingTrade.Amount)
+	if lendingstate.GetTokenBalance(offer.UserAddress, lendingTrade.LendingToken, statedb).Cmp(lendingTrade.Amount) < 0 {
+		return nil, nil, nil
+	}
+	if lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb).Cmp(interest) < 0 {
+		return nil, nil, nil
+	}
+	log.Debug("ProcessRenew", "lendingTradeId", lendingTradeId, "rate", rate, "offer", offerId.Hex(), "amount", lendingTrade.Amount, "interest", interest)
+	if err := lendingStateDB.SubAmountLendingItem(lendingBook, offerId, rate, lendingTrade.Amount, lendingstate.Investing); err != nil {
+		return nil, nil, err
+	}
+	lendingstate.SubTokenBalance(offer.UserAddress, lendingTrade.Amount, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, lendingTrade.Amount, lendingTrade.LendingToken, statedb)
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, interest, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, interest, lendingTrade.LendingToken, statedb)
+
+	// close the trade, the collateral it locked securing the new one
+	tradingOrderBook := tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime); err != nil {
+		return nil, nil, err
+	}
+	if err := tradingstateDB.RemoveLiquidationPrice(tradingOrderBook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		return nil, nil, err
+	}
+	if err := lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId); err != nil {
+		return nil, nil, err
+	}
+	tradeId := lendingStateDB.GetTradeNonce(lendingBook) + 1
+	newLendingTrade := lendingTrade
+	newLendingTrade.TradeId = tradeId
+	newLendingTrade.Investor = offer.UserAddress
+	newLendingTrade.InvestingOrderHash = offer.Hash
+	newLendingTrade.InvestingRelayer = offer.Relayer
+	newLendingTrade.Interest = rate.Uint64()
+	newLendingTrade.LiquidationTime = time + lendingTrade.Term
+	newLendingTrade.Amount = lendingstate.CloneBigInt(lendingTrade.Amount)
+	newLendingTrade.CollateralLockedAmount = lendingstate.CloneBigInt(lendingTrade.CollateralLockedAmount)
+	newLendingTrade.LiquidationPrice = lendingstate.CloneBigInt(lendingTrade.LiquidationPrice)
+	newLendingTrade.BorrowingFee = lendingstate.Zero
+	newLendingTrade.InvestingFee = lendingstate.Zero
+	newLendingTrade.MakerOrderType = offer.Type
+	newLendingTrade.Status = lendingstate.TradeStatusOpen
+	newLendingTrade.TxHash = common.Hash{}
+	newLendingTrade.ExtraData = ""
+	newLendingTrade.Hash = newLendingTrade.ComputeRenewalHash(lendingTrade.Hash)
+
+	lendingStateDB.InsertTradingItem(lendingBook, tradeId, newLendingTrade)
+	lendingStateDB.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(newLendingTrade.LiquidationTime), tradeId)
+	lendingStateDB.SetTradeNonce(lendingBook, tradeId)
+	tradingstateDB.InsertLiquidationPrice(tradingOrderBook, newLendingTrade.LiquidationPrice, lendingBook, tradeId)
+
+	lendingTrade.Status = lendingstate.TradeStatusClosed
+	extraData, _ := json.Marshal(struct {
+		Profit         *big.Int
+		RenewedTradeId uint64
+	}{
+		Profit:         interest,
+		RenewedTradeId: tradeId,
+	})
+	lendingTrade.ExtraData = string(extraData)
+	return &lendingTrade, &newLendingTrade, nil
+}
+
+// RepriceLendingTrades sets the interest rate of the open trades of a lending
+// book with a variable interest rate model to the rate of the model, from the
+// current utilization of the book. Returns the trades repriced.
+func (l *Lending) RepriceLendingTrades(statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, lendingBook common.Hash) ([]*lendingstate.LendingTrade, error) {
+	model := lendingstate.GetInterestRateModel(statedb, lendingBook)
+	if _, fixed := model.(lendingstate.FixedRateModel); fixed {
+		return nil, nil
+	}
+	trades, err := lendingStateDB.GetOpenLendingTrades(lendingBook)
+	if err != nil || len(trades) == 0 {
+		return nil, err
+	}
+	investings, err := lendingStateDB.GetInvestings(lendingBook)
+	if err != nil {
+		return nil, err
+	}
+	borrowed, offered := new(big.Int), new(big.Int)
+	for _, trade := range trades {
+		borrowed.Add(borrowed, trade.Amount)
+	}
+	for _, volume := range investings {
+		offered.Add(offered, volume)
+	}
+	utilization := lendingstate.CalculateUtilization(borrowed, offered)
+	log.Debug("RepriceLendingTrades", "lendingBook", lendingBook.Hex(), "borrowed", borrowed, "offered", offered, "utilization", utilization)
+
+	repriced := []*lendingstate.LendingTrade{}
+	for i := range trades {
+		trade := trades[i]
+		// the debt of a trade in auction is settled by the auction
+		if trade.Auction != nil {
+			continue
+		}
+		rate := model.Rate(trade.Interest, utilization)
+		if rate == trade.Interest {
+			continue
+		}
+		lendingStateDB.UpdateLendingTradeInterest(lendingBook, trade.TradeId, rate)
+		trade.Interest = rate
+		repriced = append(repriced, &trade)
+	}
+	return repriced, nil
+}
+
 func (l *Lending) ProcessRecallLendingTrade(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId common.Hash, newLiquidationPrice *big.Int) (error, bool, *lendingstate.LendingTrade) {
 	log.Debug("ProcessRecallLendingTrade", "lendingTradeId", lendingTradeId.Hex(), "lendingBook", lendingBook.Hex(), "newLiquidationPrice", newLiquidationPrice)
 	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeId)
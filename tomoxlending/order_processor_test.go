@@ -2,7 +2,11 @@ package tomoxlending
 
 import (
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
 	"github.com/tomochain/tomochain/tomox"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
@@ -539,3 +543,496 @@ func TestGetLendQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessPartialRepayLendingTrade(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000a11")
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x1200000000000000000000000000000000000002")
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, common.OneYear)
+		tomo            = func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), common.BasePrice) }
+	)
+	statedb.SetNonce(collateralToken, 1)
+	statedb.AddBalance(borrower, tomo(1000))
+	lendingstate.AddTokenBalance(lockAddress, tomo(2000), collateralToken, statedb)
+
+	// 1000 TOMO borrowed for a year at 10%, against 2000 tokens
+	lendingStateDb.InsertTradingItem(lendingBook, 1, lendingstate.LendingTrade{
+		TradeId:                1,
+		Borrower:               borrower,
+		Investor:               investor,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateralToken,
+		Term:                   common.OneYear,
+		Interest:               10 * 1e8,
+		LiquidationTime:        common.OneYear,
+		LiquidationPrice:       common.BasePrice,
+		Amount:                 tomo(1000),
+		CollateralLockedAmount: tomo(2000),
+	})
+	l := New(tomox.New(&tomox.DefaultConfig))
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(86400)}
+
+	// Repaying more than the borrower holds fails
+	if _, err := l.ProcessPartialRepayLendingTrade(header, lendingStateDb, statedb, lendingBook, 1, tomo(1001)); err == nil {
+		t.Fatalf("repaid more than the borrower balance")
+	}
+	// Half of the total repay value after a day repays half of the loan
+	payment, _ := new(big.Int).SetString("525068493150000000000", 10)
+	snap := lendingStateDb.Snapshot()
+	trade, err := l.ProcessPartialRepayLendingTrade(header, lendingStateDb, statedb, lendingBook, 1, payment)
+	if err != nil {
+		t.Fatalf("failed to repay part of the loan: %v", err)
+	}
+	if trade.Status != lendingstate.TradeStatusOpen || trade.Amount.Cmp(tomo(500)) != 0 || trade.CollateralLockedAmount.Cmp(tomo(1000)) != 0 {
+		t.Errorf("trade mismatch: status %s, amount %v, locked %v", trade.Status, trade.Amount, trade.CollateralLockedAmount)
+	}
+	stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1))
+	if stored.Amount.Cmp(tomo(500)) != 0 || stored.CollateralLockedAmount.Cmp(tomo(1000)) != 0 {
+		t.Errorf("stored trade mismatch: amount %v, locked %v", stored.Amount, stored.CollateralLockedAmount)
+	}
+	if have, want := statedb.GetBalance(investor), payment; have.Cmp(want) != 0 {
+		t.Errorf("investor balance mismatch: have %v, want %v", have, want)
+	}
+	if have := lendingstate.GetTokenBalance(borrower, collateralToken, statedb); have.Cmp(tomo(1000)) != 0 {
+		t.Errorf("released collateral mismatch: have %v, want %v", have, tomo(1000))
+	}
+	if have := lendingstate.GetTokenBalance(lockAddress, collateralToken, statedb); have.Cmp(tomo(1000)) != 0 {
+		t.Errorf("locked collateral mismatch: have %v, want %v", have, tomo(1000))
+	}
+	lendingStateDb.RevertToSnapshot(snap)
+	if reverted := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); reverted.Amount.Cmp(tomo(1000)) != 0 || reverted.CollateralLockedAmount.Cmp(tomo(2000)) != 0 {
+		t.Errorf("reverted trade mismatch: amount %v, locked %v", reverted.Amount, reverted.CollateralLockedAmount)
+	}
+	// A trade already due can only be repaid in full
+	header.Time = new(big.Int).SetUint64(common.OneYear)
+	if _, err := l.ProcessPartialRepayLendingTrade(header, lendingStateDb, statedb, lendingBook, 1, tomo(1)); err == nil {
+		t.Errorf("repaid part of a trade already due")
+	}
+}
+
+func TestProcessRenewLendingTrade(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000a11")
+		newInvestor     = common.HexToAddress("0x0000000000000000000000000000000000000a12")
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x1200000000000000000000000000000000000002")
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, common.OneYear)
+		orderBook       = tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+		tomo            = func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), common.BasePrice) }
+	)
+	statedb.SetNonce(collateralToken, 1)
+	statedb.AddBalance(newInvestor, tomo(1500))
+	lendingstate.AddTokenBalance(lockAddress, tomo(4000), collateralToken, statedb)
+
+	// Two loans of 1000 TOMO at 10% due in a year, renewable at up to 12% and 10%
+	for id, rate := range map[uint64]uint64{1: 12 * 1e8, 2: 10 * 1e8} {
+		trade := lendingstate.LendingTrade{
+			TradeId:                id,
+			Borrower:               borrower,
+			Investor:               investor,
+			LendingToken:           lendingToken,
+			CollateralToken:        collateralToken,
+			Term:                   common.OneYear,
+			Interest:               10 * 1e8,
+			LiquidationTime:        common.OneYear,
+			LiquidationPrice:       common.BasePrice,
+			Amount:                 tomo(1000),
+			CollateralLockedAmount: tomo(2000),
+			AutoRenewRate:          rate,
+			Hash:                   common.BigToHash(new(big.Int).SetUint64(id)),
+		}
+		lendingStateDb.InsertTradingItem(lendingBook, id, trade)
+		lendingStateDb.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(trade.LiquidationTime), id)
+		tradingStateDb.InsertLiquidationPrice(orderBook, trade.LiquidationPrice, lendingBook, id)
+	}
+	lendingStateDb.SetTradeNonce(lendingBook, 2)
+
+	// An offer to lend 1000 TOMO at 11%
+	lendingStateDb.InsertLendingItem(lendingBook, common.Uint64ToHash(1), lendingstate.LendingItem{
+		LendingId:    1,
+		Quantity:     tomo(1000),
+		Interest:     big.NewInt(11 * 1e8),
+		Side:         lendingstate.Investing,
+		Type:         lendingstate.Limit,
+		LendingToken: lendingToken,
+		Term:         common.OneYear,
+		UserAddress:  newInvestor,
+		Hash:         common.HexToHash("0x0ff3"),
+	})
+	l := New(tomox.New(&tomox.DefaultConfig))
+	header := &types.Header{Number: big.NewInt(1), Time: new(big.Int).SetUint64(common.OneYear + 1)}
+	renew := func(id uint64) (*lendingstate.LendingTrade, *lendingstate.LendingTrade) {
+		closed, renewed, err := l.ProcessRenewLendingTrade(header, lendingStateDb, statedb, tradingStateDb, lendingBook, id)
+		if err != nil {
+			t.Fatalf("failed to renew trade %d: %v", id, err)
+		}
+		return closed, renewed
+	}
+	// The offer rate exceeds the highest renewal rate of the second trade
+	if _, renewed := renew(2); renewed != nil {
+		t.Fatalf("renewed a trade above its renewal rate")
+	}
+	// The borrower can't pay the interest due
+	if _, renewed := renew(1); renewed != nil {
+		t.Fatalf("renewed a trade without paying its interest")
+	}
+	interest := new(big.Int).Sub(lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), common.OneYear, common.OneYear, 10*1e8, tomo(1000)), tomo(1000))
+	statedb.AddBalance(borrower, interest)
+
+	closed, renewed := renew(1)
+	if renewed == nil {
+		t.Fatalf("trade not renewed")
+	}
+	if closed.Status != lendingstate.TradeStatusClosed || closed.TradeId != 1 {
+		t.Errorf("closed trade mismatch: id %d, status %s", closed.TradeId, closed.Status)
+	}
+	if renewed.TradeId != 3 || renewed.Investor != newInvestor || renewed.Interest != 11*1e8 || renewed.LiquidationTime != header.Time.Uint64()+common.OneYear {
+		t.Errorf("renewed trade mismatch: id %d, investor %x, interest %d, liquidation time %d", renewed.TradeId, renewed.Investor, renewed.Interest, renewed.LiquidationTime)
+	}
+	if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); stored.Amount.Sign() != 0 {
+		t.Errorf("renewed trade still open: amount %v", stored.Amount)
+	}
+	if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(3)); stored.Amount.Cmp(tomo(1000)) != 0 || stored.CollateralLockedAmount.Cmp(tomo(2000)) != 0 || stored.Hash != renewed.Hash {
+		t.Errorf("stored renewal mismatch: amount %v, locked %v", stored.Amount, stored.CollateralLockedAmount)
+	}
+	if have, want := statedb.GetBalance(investor), new(big.Int).Add(tomo(1000), interest); have.Cmp(want) != 0 {
+		t.Errorf("investor balance mismatch: have %v, want %v", have, want)
+	}
+	if have := statedb.GetBalance(newInvestor); have.Cmp(tomo(500)) != 0 {
+		t.Errorf("new investor balance mismatch: have %v, want %v", have, tomo(500))
+	}
+	if have := statedb.GetBalance(borrower); have.Sign() != 0 {
+		t.Errorf("borrower balance mismatch: have %v, want 0", have)
+	}
+	if rate, _ := lendingStateDb.GetBestInvestingRate(lendingBook); rate.Sign() != 0 {
+		t.Errorf("offer not filled: best rate %v", rate)
+	}
+	if have := lendingstate.GetTokenBalance(lockAddress, collateralToken, statedb); have.Cmp(tomo(4000)) != 0 {
+		t.Errorf("locked collateral mismatch: have %v, want %v", have, tomo(4000))
+	}
+}
+
+func TestRepriceLendingTrades(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+
+	var (
+		lendingToken = common.HexToAddress(common.TomoNativeAddress)
+		lendingBook  = lendingstate.GetLendingOrderBookHash(lendingToken, common.OneYear)
+		tomo         = func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), common.BasePrice) }
+		l            = New(tomox.New(&tomox.DefaultConfig))
+	)
+	// Two open loans of 1000 TOMO at 5%, a closed one, and offers of 2000 TOMO
+	for id, amount := range map[uint64]*big.Int{1: tomo(1000), 2: tomo(1000), 3: new(big.Int)} {
+		lendingStateDb.InsertTradingItem(lendingBook, id, lendingstate.LendingTrade{
+			TradeId:      id,
+			LendingToken: lendingToken,
+			Term:         common.OneYear,
+			Interest:     5 * 1e8,
+			Amount:       amount,
+			Hash:         common.BigToHash(new(big.Int).SetUint64(id)),
+		})
+	}
+	lendingStateDb.InsertLendingItem(lendingBook, common.Uint64ToHash(1), lendingstate.LendingItem{
+		LendingId:    1,
+		Quantity:     tomo(2000),
+		Interest:     big.NewInt(6 * 1e8),
+		Side:         lendingstate.Investing,
+		Type:         lendingstate.Limit,
+		LendingToken: lendingToken,
+		Term:         common.OneYear,
+	})
+	// Books without an interest rate model keep their fixed rates
+	if repriced, err := l.RepriceLendingTrades(statedb, lendingStateDb, lendingBook); err != nil || len(repriced) != 0 {
+		t.Fatalf("repriced fixed rate trades: %v, %v", repriced, err)
+	}
+	// 2% base rate rising by 10% with the utilization, here 50%
+	modelState := lendingstate.GetLocMappingAtKey(lendingBook, lendingstate.InterestRateModelSlot)
+	for field, value := range map[string]int64{"baseRate": 2 * 1e8, "slope": 10 * 1e8, "kink": 100 * 1e8} {
+		loc := state.GetLocOfStructElement(modelState, lendingstate.InterestRateModelStructSlots[field])
+		statedb.SetState(common.HexToAddress(common.LendingRegistrationSMC), loc, common.BigToHash(big.NewInt(value)))
+	}
+	snap := lendingStateDb.Snapshot()
+	repriced, err := l.RepriceLendingTrades(statedb, lendingStateDb, lendingBook)
+	if err != nil {
+		t.Fatalf("failed to reprice trades: %v", err)
+	}
+	if len(repriced) != 2 || repriced[0].TradeId != 1 || repriced[1].TradeId != 2 {
+		t.Fatalf("repriced trades mismatch: %v", repriced)
+	}
+	for id := uint64(1); id <= 2; id++ {
+		if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(id)); stored.Interest != 7*1e8 {
+			t.Errorf("trade %d: interest mismatch: have %d, want %d", id, stored.Interest, uint64(7*1e8))
+		}
+	}
+	lendingStateDb.RevertToSnapshot(snap)
+	if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); stored.Interest != 5*1e8 {
+		t.Errorf("reverted interest mismatch: have %d, want %d", stored.Interest, uint64(5*1e8))
+	}
+}
+
+func TestProcessTopUpLendingTrade(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x1200000000000000000000000000000000000002")
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, common.OneYear)
+		orderBook       = tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+		tomo            = func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), common.BasePrice) }
+	)
+	statedb.SetNonce(collateralToken, 1)
+	lendingstate.AddTokenBalance(borrower, tomo(1000), collateralToken, statedb)
+	lendingstate.AddTokenBalance(lockAddress, tomo(1000), collateralToken, statedb)
+
+	lendingStateDb.InsertTradingItem(lendingBook, 1, lendingstate.LendingTrade{
+		TradeId:                1,
+		Borrower:               borrower,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateralToken,
+		Term:                   common.OneYear,
+		LiquidationTime:        common.OneYear,
+		LiquidationPrice:       tomo(8),
+		Amount:                 tomo(1000),
+		CollateralLockedAmount: tomo(1000),
+	})
+	tradingStateDb.InsertLiquidationPrice(orderBook, tomo(8), lendingBook, 1)
+
+	l := New(tomox.New(&tomox.DefaultConfig))
+	if err, reject, _ := l.ProcessTopUpLendingTrade(lendingStateDb, statedb, tradingStateDb, common.Uint64ToHash(1), lendingBook, tomo(1001)); err == nil || !reject {
+		t.Fatalf("topped up more than the borrower balance")
+	}
+	// Doubling the collateral halves the liquidation price
+	err, reject, trade := l.ProcessTopUpLendingTrade(lendingStateDb, statedb, tradingStateDb, common.Uint64ToHash(1), lendingBook, tomo(1000))
+	if err != nil || reject {
+		t.Fatalf("failed to top up: %v", err)
+	}
+	if trade.LiquidationPrice.Cmp(tomo(4)) != 0 || trade.CollateralLockedAmount.Cmp(tomo(2000)) != 0 {
+		t.Errorf("trade mismatch: liquidation price %v, locked %v", trade.LiquidationPrice, trade.CollateralLockedAmount)
+	}
+	stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1))
+	if stored.LiquidationPrice.Cmp(tomo(4)) != 0 || stored.CollateralLockedAmount.Cmp(tomo(2000)) != 0 {
+		t.Errorf("stored trade mismatch: liquidation price %v, locked %v", stored.LiquidationPrice, stored.CollateralLockedAmount)
+	}
+	if have := lendingstate.GetTokenBalance(lockAddress, collateralToken, statedb); have.Cmp(tomo(2000)) != 0 {
+		t.Errorf("locked collateral mismatch: have %v, want %v", have, tomo(2000))
+	}
+	// The trade is only liquidated once the price falls below the new liquidation price
+	if price, data := tradingStateDb.GetHighestLiquidationPriceData(orderBook, tomo(5)); price.Cmp(tomo(4)) != 0 || len(data) != 0 {
+		t.Errorf("liquidation price mismatch: have %v, want %v", price, tomo(4))
+	}
+	if _, data := tradingStateDb.GetHighestLiquidationPriceData(orderBook, tomo(3)); len(data[lendingBook]) != 1 {
+		t.Errorf("trade not liquidated below its new liquidation price: %v", data)
+	}
+}
+
+// auctionChain is a chain context only serving its configuration.
+type auctionChain struct {
+	config *params.ChainConfig
+}
+
+func (c *auctionChain) Engine() consensus.Engine                    { return nil }
+func (c *auctionChain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+func (c *auctionChain) CurrentHeader() *types.Header                { return nil }
+func (c *auctionChain) Config() *params.ChainConfig                 { return c.config }
+
+func TestLendingAuction(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000a11")
+		bidder          = common.HexToAddress("0x0000000000000000000000000000000000000b1d")
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x1200000000000000000000000000000000000002")
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, common.OneYear)
+		orderBook       = tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+		tomo            = func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), common.BasePrice) }
+		chain           = &auctionChain{config: params.TestChainConfig}
+	)
+	statedb.SetNonce(collateralToken, 1)
+	statedb.AddBalance(bidder, tomo(1200))
+	lendingstate.AddTokenBalance(lockAddress, tomo(4000), collateralToken, statedb)
+
+	// Two loans of 1000 TOMO at 10% secured by 2000 tokens, liquidated below 0.7 TOMO
+	for id := uint64(1); id <= 2; id++ {
+		trade := lendingstate.LendingTrade{
+			TradeId:                id,
+			Borrower:               borrower,
+			Investor:               investor,
+			LendingToken:           lendingToken,
+			CollateralToken:        collateralToken,
+			Term:                   common.OneYear,
+			Interest:               10 * 1e8,
+			LiquidationTime:        common.OneYear,
+			LiquidationPrice:       new(big.Int).Div(tomo(7), big.NewInt(10)),
+			Amount:                 tomo(1000),
+			CollateralLockedAmount: tomo(2000),
+			Hash:                   common.BigToHash(new(big.Int).SetUint64(id)),
+		}
+		lendingStateDb.InsertTradingItem(lendingBook, id, trade)
+		lendingStateDb.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(trade.LiquidationTime), id)
+		tradingStateDb.InsertLiquidationPrice(orderBook, trade.LiquidationPrice, lendingBook, id)
+	}
+	tomox := tomox.New(&tomox.DefaultConfig)
+	tomox.SetTokenDecimal(collateralToken, common.BasePrice)
+	l := New(tomox)
+
+	// The collateral, worth 1200 TOMO at 0.6 TOMO, opens at 1260 TOMO with a floor at 960 TOMO
+	start := &types.Header{Number: big.NewInt(1000), Time: new(big.Int).SetUint64(common.OneYear / 2)}
+	price := new(big.Int).Div(tomo(6), big.NewInt(10))
+	for id := uint64(1); id <= 2; id++ {
+		trade, err := l.StartLendingAuction(start, chain, lendingStateDb, statedb, tradingStateDb, lendingBook, id, price, lendingstate.LiquidatedByPrice)
+		if err != nil {
+			t.Fatalf("failed to start auction of trade %d: %v", id, err)
+		}
+		if trade.Status != lendingstate.TradeStatusAuction || trade.Auction.StartPrice.Cmp(tomo(1260)) != 0 || trade.Auction.FloorPrice.Cmp(tomo(960)) != 0 {
+			t.Fatalf("auction mismatch: status %s, auction %+v", trade.Status, trade.Auction)
+		}
+	}
+	endTime := start.Time.Uint64() + common.LendingAuctionBlocks
+	if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); stored.Auction == nil || stored.LiquidationTime != endTime {
+		t.Errorf("stored auction mismatch: auction %+v, liquidation time %d", stored.Auction, stored.LiquidationTime)
+	}
+	if _, data := tradingStateDb.GetHighestLiquidationPriceData(orderBook, common.Big1); len(data) != 0 {
+		t.Errorf("trades in auction still liquidated by price: %v", data)
+	}
+	if lowest, _ := lendingStateDb.GetLowestLiquidationTime(lendingBook, new(big.Int).SetUint64(endTime)); lowest.Uint64() != endTime {
+		t.Errorf("auction due time mismatch: have %v, want %d", lowest, endTime)
+	}
+	// Halfway through the auction the collateral costs 1110 TOMO
+	half := &types.Header{Number: big.NewInt(int64(1000 + common.LendingAuctionBlocks/2)), Time: new(big.Int).SetUint64(endTime - 1)}
+	bid := &lendingstate.LendingItem{UserAddress: bidder, LendingTradeId: 1, Quantity: tomo(1100)}
+	if _, err := l.ProcessAuctionBid(half, lendingStateDb, statedb, lendingBook, bid); err == nil {
+		t.Fatalf("bid below the auction price accepted")
+	}
+	bid.Quantity = tomo(1200)
+	trade, err := l.ProcessAuctionBid(half, lendingStateDb, statedb, lendingBook, bid)
+	if err != nil {
+		t.Fatalf("failed to bid: %v", err)
+	}
+	if trade.Status != lendingstate.TradeStatusLiquidated {
+		t.Errorf("sold trade status mismatch: have %s", trade.Status)
+	}
+	debt := lendingstate.CalculateTotalRepayValue(start.Time.Uint64(), common.OneYear, common.OneYear, 10*1e8, tomo(1000))
+	if have := statedb.GetBalance(investor); have.Cmp(debt) != 0 {
+		t.Errorf("investor balance mismatch: have %v, want %v", have, debt)
+	}
+	if have, want := statedb.GetBalance(borrower), new(big.Int).Sub(tomo(1110), debt); have.Cmp(want) != 0 {
+		t.Errorf("borrower surplus mismatch: have %v, want %v", have, want)
+	}
+	if have := statedb.GetBalance(bidder); have.Cmp(tomo(90)) != 0 {
+		t.Errorf("bidder balance mismatch: have %v, want %v", have, tomo(90))
+	}
+	if have := lendingstate.GetTokenBalance(bidder, collateralToken, statedb); have.Cmp(tomo(2000)) != 0 {
+		t.Errorf("bidder collateral mismatch: have %v, want %v", have, tomo(2000))
+	}
+	if stored := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(1)); stored.Amount.Sign() != 0 {
+		t.Errorf("sold trade still open: amount %v", stored.Amount)
+	}
+	// The unsold collateral goes to the investor once the auction is due
+	end := &types.Header{Number: big.NewInt(int64(1000 + common.LendingAuctionBlocks)), Time: new(big.Int).SetUint64(endTime + 1)}
+	if trade, err = l.ProcessRepayLendingTrade(end, chain, lendingStateDb, statedb, tradingStateDb, lendingBook, 2); err != nil {
+		t.Fatalf("failed to close auction: %v", err)
+	}
+	if trade.Status != lendingstate.TradeStatusLiquidated {
+		t.Errorf("unsold trade status mismatch: have %s", trade.Status)
+	}
+	if have := lendingstate.GetTokenBalance(investor, collateralToken, statedb); have.Cmp(tomo(2000)) != 0 {
+		t.Errorf("investor collateral mismatch: have %v, want %v", have, tomo(2000))
+	}
+	if have := lendingstate.GetTokenBalance(lockAddress, collateralToken, statedb); have.Sign() != 0 {
+		t.Errorf("locked collateral mismatch: have %v, want 0", have)
+	}
+}
+
+func TestGetContractPrice(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x1200000000000000000000000000000000000002")
+		feeders         = []common.Address{
+			common.HexToAddress("0x00000000000000000000000000000000000000f1"),
+			common.HexToAddress("0x00000000000000000000000000000000000000f2"),
+			common.HexToAddress("0x00000000000000000000000000000000000000f3"),
+		}
+		config = *params.TestChainConfig
+		chain  = &auctionChain{config: &config}
+		l      = New(tomox.New(&tomox.DefaultConfig))
+	)
+	config.Posv = &params.PosvConfig{Epoch: 900}
+	config.TIPTomoXOracleBlock = big.NewInt(2000)
+
+	// Before the fork, the single price of the pair is used in its epoch
+	lendingstate.SetCollateralPrice(statedb, collateralToken, lendingToken, big.NewInt(500), big.NewInt(1800))
+	if price, ok := l.GetContractPrice(&types.Header{Number: big.NewInt(1900)}, chain, statedb, collateralToken, lendingToken); !ok || price.Cmp(big.NewInt(500)) != 0 {
+		t.Fatalf("price before the fork mismatch: have %v %v, want 500", price, ok)
+	}
+	// After it, the median reported by the feeders, the single price is ignored
+	header := &types.Header{Number: big.NewInt(2000)}
+	if _, ok := l.GetContractPrice(header, chain, statedb, collateralToken, lendingToken); ok {
+		t.Fatalf("price after the fork without feeders")
+	}
+	lendingstate.SetPriceFeeders(statedb, collateralToken, lendingToken, feeders)
+	lendingstate.SetPriceFeed(statedb, collateralToken, lendingToken, feeders[0], big.NewInt(1000), big.NewInt(1990))
+	lendingstate.SetPriceFeed(statedb, collateralToken, lendingToken, feeders[1], big.NewInt(1040), big.NewInt(1995))
+	lendingstate.SetPriceFeed(statedb, collateralToken, lendingToken, feeders[2], big.NewInt(1020), big.NewInt(1999))
+	if price, ok := l.GetContractPrice(header, chain, statedb, collateralToken, lendingToken); !ok || price.Cmp(big.NewInt(1020)) != 0 {
+		t.Fatalf("price after the fork mismatch: have %v %v, want 1020", price, ok)
+	}
+}
+
+func TestGetRiskParameters(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		collateralToken = common.HexToAddress("0x1200000000000000000000000000000000000002")
+		config          = *params.TestChainConfig
+		l               = New(tomox.New(&tomox.DefaultConfig))
+	)
+	config.Posv = &params.PosvConfig{Epoch: 900}
+	config.TIPTomoXRiskGovernanceBlock = big.NewInt(2000)
+
+	lendingstate.AddCollateral(statedb, collateralToken, big.NewInt(150), big.NewInt(110), big.NewInt(200))
+	lendingstate.SetRiskParameters(statedb, collateralToken, &lendingstate.RiskParameters{
+		DepositRate:        big.NewInt(125),
+		LiquidationRate:    big.NewInt(105),
+		RecallRate:         big.NewInt(175),
+		LiquidationPenalty: big.NewInt(5),
+		Epoch:              3,
+	}, 2)
+	// Before the fork, the rates of the collateral are used
+	if params := l.GetRiskParameters(&config, &types.Header{Number: big.NewInt(1900)}, statedb, collateralToken); params.DepositRate.Int64() != 150 || params.LiquidationPenalty.Sign() != 0 {
+		t.Fatalf("parameters before the fork mismatch: have %+v", params)
+	}
+	// After it, those scheduled by the governance from their epoch on
+	if params := l.GetRiskParameters(&config, &types.Header{Number: big.NewInt(2000)}, statedb, collateralToken); params.DepositRate.Int64() != 150 {
+		t.Fatalf("parameters before their epoch mismatch: have %+v", params)
+	}
+	if params := l.GetRiskParameters(&config, &types.Header{Number: big.NewInt(2700)}, statedb, collateralToken); params.DepositRate.Int64() != 125 || params.LiquidationPenalty.Int64() != 5 {
+		t.Fatalf("parameters of their epoch mismatch: have %+v", params)
+	}
+}
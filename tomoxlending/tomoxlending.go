@@ -16,11 +16,13 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/VictoriaMetrics/fastcache"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/trie"
 )
 
 const (
@@ -44,6 +46,8 @@ type Lending struct {
 	tomox               *tomox.TomoX
 	lendingItemHistory  *lru.Cache
 	lendingTradeHistory *lru.Cache
+
+	tradeIndex *tomox.AddressIndex // nil unless the address index of TomoX is enabled
 }
 
 func (l *Lending) Protocols() []p2p.Protocol {
@@ -72,6 +76,9 @@ func New(tomox *tomox.TomoX) *Lending {
 	}
 	lending.StateCache = lendingstate.NewDatabase(tomox.GetLevelDB())
 	lending.tomox = tomox
+	if tomox.AddressIndexEnabled() {
+		lending.tradeIndex = newTradeIndex(tomox.GetLevelDB())
+	}
 	return lending
 }
 
@@ -137,6 +144,7 @@ func (l *Lending) ProcessOrderPending(header *types.Header, coinbase common.Addr
 			LendingId:       tx.LendingId(),
 			LendingTradeId:  tx.LendingTradeId(),
 			ExtraData:       tx.ExtraData(),
+			AutoRenewRate:   tx.AutoRenewRate(),
 			Signature: &lendingstate.Signature{
 				V: byte(n),
 				R: common.BigToHash(R),
@@ -269,7 +277,7 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		if tradeRecord == nil {
 			continue
 		}
-		if updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall {
+		if updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.PartialRepay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall || updatedTakerLendingItem.Type == lendingstate.AuctionBid {
 			// repay, topup: assign hash = trade.hash
 			updatedTakerLendingItem.Hash = tradeRecord.Hash
 			updatedTakerLendingItem.CollateralToken = tradeRecord.CollateralToken
@@ -293,10 +301,29 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				updatedTakerLendingItem.FilledAmount = paymentBalance
 				// manual repay item
 				updatedTakerLendingItem.AutoTopUp = false
+			case lendingstate.PartialRepay:
+				updatedTakerLendingItem.Status = lendingstate.PartialRepay
+				// a partial repayment covering the total repay value closes the trade
+				if tradeRecord.Status != lendingstate.TradeStatusOpen {
+					updatedTakerLendingItem.Status = lendingstate.Repay
+					paymentBalance := lendingstate.CalculateTotalRepayValue(block.Time().Uint64(), tradeRecord.LiquidationTime, tradeRecord.Term, tradeRecord.Interest, tradeRecord.Amount)
+					updatedTakerLendingItem.Quantity = paymentBalance
+					updatedTakerLendingItem.FilledAmount = paymentBalance
+				}
+				// manual repay item
+				updatedTakerLendingItem.AutoTopUp = false
 			case lendingstate.Recall:
 				updatedTakerLendingItem.Status = lendingstate.Recall
 				// manual recall item
 				updatedTakerLendingItem.AutoTopUp = false
+			case lendingstate.AuctionBid:
+				updatedTakerLendingItem.Status = lendingstate.AuctionBid
+				// the bid pays the price of the auction, at most its quantity
+				if tradeRecord.Auction != nil {
+					price := tradeRecord.Auction.Price(block.NumberU64())
+					updatedTakerLendingItem.FilledAmount = price
+				}
+				updatedTakerLendingItem.AutoTopUp = false
 			}
 
 			log.Debug("UpdateLendingTrade:", "type", updatedTakerLendingItem.Type, "hash", tradeRecord.Hash.Hex(), "status", tradeRecord.Status, "tradeId", tradeRecord.TradeId)
@@ -363,7 +390,7 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		"Interest", updatedTakerLendingItem.Interest, "quantity", updatedTakerLendingItem.Quantity, "filledAmount", updatedTakerLendingItem.FilledAmount, "status", updatedTakerLendingItem.Status,
 		"hash", updatedTakerLendingItem.Hash.Hex(), "txHash", updatedTakerLendingItem.TxHash.Hex())
 
-	if !(updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall) || updatedTakerLendingItem.Status != lendingstate.LendingStatusOpen {
+	if !(updatedTakerLendingItem.Type == lendingstate.Repay || updatedTakerLendingItem.Type == lendingstate.PartialRepay || updatedTakerLendingItem.Type == lendingstate.TopUp || updatedTakerLendingItem.Type == lendingstate.Recall || updatedTakerLendingItem.Type == lendingstate.AuctionBid) || updatedTakerLendingItem.Status != lendingstate.LendingStatusOpen {
 		if err := db.PutObject(updatedTakerLendingItem.Hash, updatedTakerLendingItem); err != nil {
 			return fmt.Errorf("SDKNode: failed to put processed takerOrder. Hash: %s Error: %s", updatedTakerLendingItem.Hash.Hex(), err.Error())
 		}
@@ -615,6 +642,20 @@ func (l *Lending) UpdateLiquidatedTrade(blockTime uint64, result lendingstate.Fi
 		}
 	}
 
+	// adding the trades rolling over the trades renewed
+	for _, hash := range result.AutoRenew {
+		trade := trades[hash]
+		if trade == nil {
+			continue
+		}
+		trade.TxHash = txhash
+		trade.CreatedAt = txTime
+		trade.UpdatedAt = txTime
+		if err := db.PutObject(trade.Hash, trade); err != nil {
+			return err
+		}
+	}
+
 	if err := db.CommitLendingBulk(); err != nil {
 		return fmt.Errorf("failed to updateLendingTrade . Err: %v", err)
 	}
@@ -649,6 +690,8 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 			trade.CollateralLockedAmount = newTrade.CollateralLockedAmount
 			trade.Status = newTrade.Status
 			trade.LiquidationPrice = newTrade.LiquidationPrice
+			trade.Interest = newTrade.Interest
+			trade.LiquidationTime = newTrade.LiquidationTime
 			trade.ExtraData = newTrade.ExtraData
 
 			if err := db.PutObject(trade.Hash, trade); err != nil {
@@ -686,6 +729,15 @@ func (l *Lending) GetStateCache() lendingstate.Database {
 	return l.StateCache
 }
 
+// SetTrieCleanCache replaces the lending state database with one reading the
+// trie nodes through the given clean cache, shared with the other tries.
+func (l *Lending) SetTrieCleanCache(cleans *fastcache.Cache) {
+	if l.tomox == nil {
+		return
+	}
+	l.StateCache = lendingstate.NewDatabaseWithConfig(l.tomox.GetLevelDB(), &trie.Config{Cleans: cleans})
+}
+
 func (l *Lending) HasLendingState(block *types.Block, author common.Address) bool {
 	root, err := l.GetLendingStateRoot(block, author)
 	if err != nil {
@@ -820,6 +872,7 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.Repay})
 	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.TopUp})
 	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.Recall})
+	db.DeleteItemByTxHash(txhash, &lendingstate.LendingItem{Type: lendingstate.AuctionBid})
 
 	if err := db.CommitLendingBulk(); err != nil {
 		return fmt.Errorf("failed to RollbackLendingData. %v", err)
@@ -827,36 +880,62 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 	return nil
 }
 
-func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (updatedTrades map[common.Hash]*lendingstate.LendingTrade, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades []*lendingstate.LendingTrade, err error) {
+func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB) (updatedTrades map[common.Hash]*lendingstate.LendingTrade, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades []*lendingstate.LendingTrade, err error) {
 	time := header.Time
-	updatedTrades = map[common.Hash]*lendingstate.LendingTrade{} // sum of liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades
+	updatedTrades = map[common.Hash]*lendingstate.LendingTrade{} // sum of liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades
 	liquidatedTrades = []*lendingstate.LendingTrade{}
 	autoRepayTrades = []*lendingstate.LendingTrade{}
 	autoTopUpTrades = []*lendingstate.LendingTrade{}
 	autoRecallTrades = []*lendingstate.LendingTrade{}
+	autoRenewTrades = []*lendingstate.LendingTrade{}
 
 	allPairs, err := lendingstate.GetAllLendingPairs(statedb)
 	if err != nil {
 		log.Debug("Not found all trading pairs", "error", err)
-		return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, nil
+		return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, nil
 	}
 	allLendingBooks, err := lendingstate.GetAllLendingBooks(statedb)
 	if err != nil {
 		log.Debug("Not found all lending books", "error", err)
-		return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, nil
+		return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, nil
 	}
 
 	// liquidate trades by time
 	for lendingBook := range allLendingBooks {
+		// reprice the loans of variable rate books before closing the trades due
+		if chain.Config().IsTIPTomoXVariableRate(header.Number) {
+			repricedTrades, err := l.RepriceLendingTrades(statedb, lendingState, lendingBook)
+			if err != nil {
+				log.Error("Fail when reprice lending trades", "lendingBook", lendingBook.Hex(), "error", err)
+				return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err
+			}
+			for _, trade := range repricedTrades {
+				updatedTrades[trade.Hash] = trade
+			}
+		}
 		lowestTime, tradingIds := lendingState.GetLowestLiquidationTime(lendingBook, time)
 		log.Debug("ProcessLiquidationData time", "tradeIds", len(tradingIds))
 		for lowestTime.Sign() > 0 && lowestTime.Cmp(time) < 0 {
 			for _, tradingId := range tradingIds {
+				if chain.Config().IsTIPTomoXAutoRenew(header.Number) {
+					closedTrade, newTrade, err := l.ProcessRenewLendingTrade(header, lendingState, statedb, tradingState, lendingBook, tradingId.Big().Uint64())
+					if err != nil {
+						log.Error("Fail when process renewal ", "time", time, "lendingBook", lendingBook.Hex(), "tradingId", tradingId, "error", err)
+						return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err
+					}
+					// a trade rolled over is neither repaid nor liquidated
+					if newTrade != nil {
+						updatedTrades[closedTrade.Hash] = closedTrade
+						updatedTrades[newTrade.Hash] = newTrade
+						autoRenewTrades = append(autoRenewTrades, newTrade)
+						continue
+					}
+				}
 				log.Debug("ProcessRepay", "lowestTime", lowestTime, "time", time, "lendingBook", lendingBook.Hex(), "tradingId", tradingId.Hex())
 				trade, err := l.ProcessRepayLendingTrade(header, chain, lendingState, statedb, tradingState, lendingBook, tradingId.Big().Uint64())
 				if err != nil {
 					log.Error("Fail when process payment ", "time", time, "lendingBook", lendingBook.Hex(), "tradingId", tradingId, "error", err)
-					return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+					return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err
 				}
 				if trade != nil && trade.Hash != (common.Hash{}) {
 					updatedTrades[trade.Hash] = trade
@@ -895,10 +974,19 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 						}
 					}
 					log.Debug("LiquidationTrade", "highestLiquidatePrice", highestLiquidatePrice, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex())
+					if chain.Config().IsTIPTomoXDutchAuction(header.Number) {
+						newTrade, err := l.StartLendingAuction(header, chain, lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64(), collateralPrice, lendingstate.LiquidatedByPrice)
+						if err != nil {
+							log.Error("Fail when start lending auction", "time", time, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "error", err)
+							return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err
+						}
+						updatedTrades[newTrade.Hash] = newTrade
+						continue
+					}
 					newTrade, err := l.LiquidationTrade(lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64())
 					if err != nil {
 						log.Error("Fail when remove liquidation newTrade", "time", time, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "error", err)
-						return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+						return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err
 					}
 					if newTrade != nil && newTrade.Hash != (common.Hash{}) {
 						newTrade.Status = lendingstate.TradeStatusLiquidated
@@ -918,7 +1006,8 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 			highestLiquidatePrice, liquidationData = tradingState.GetHighestLiquidationPriceData(orderbook, collateralPrice)
 		}
 		// recall trades
-		depositRate, liquidationRate, recallRate := lendingstate.GetCollateralDetail(statedb, lendingPair.CollateralToken)
+		riskParameters := l.GetRiskParameters(chain.Config(), header, statedb, lendingPair.CollateralToken)
+		depositRate, liquidationRate, recallRate := riskParameters.DepositRate, riskParameters.LiquidationRate, riskParameters.RecallRate
 		recalLiquidatePrice := new(big.Int).Mul(collateralPrice, common.BaseRecall)
 		recalLiquidatePrice = new(big.Int).Div(recalLiquidatePrice, recallRate)
 		newLiquidatePrice := new(big.Int).Mul(collateralPrice, liquidationRate)
@@ -936,7 +1025,7 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 							err, _, newTrade := l.ProcessRecallLendingTrade(lendingState, statedb, tradingState, lendingBook, tradingIdHash, newLiquidatePrice)
 							if err != nil {
 								log.Error("ProcessRecallLendingTrade", "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "newLiquidatePrice", newLiquidatePrice, "err", err)
-								return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+								return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, err
 							}
 							// if this action complete successfully, do not liquidate this trade in this epoch
 							log.Debug("AutoRecall", "borrower", trade.Borrower.Hex(), "collateral", newTrade.CollateralToken.Hex(), "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "newLockedAmount", newTrade.CollateralLockedAmount)
@@ -950,5 +1039,5 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 	}
 
 	log.Debug("ProcessLiquidationData", "updatedTrades", len(updatedTrades), "liquidated", len(liquidatedTrades), "autoRepay", len(autoRepayTrades), "autoTopUp", len(autoTopUpTrades), "autoRecall", len(autoRecallTrades))
-	return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, nil
+	return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, autoRenewTrades, nil
 }
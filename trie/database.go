@@ -91,6 +91,9 @@ type Database struct {
 	flushnodes uint64             // Nodes flushed since last commit
 	flushsize  common.StorageSize // Data storage flushed since last commit
 
+	insertnodes uint64             // Nodes inserted since creation
+	insertsize  common.StorageSize // Data storage inserted since creation
+
 	dirtiesSize   common.StorageSize // Storage size of the dirty Node Cache (exc. metadata)
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages Cache
@@ -274,29 +277,50 @@ func expandNode(hash HashNode, n Node) Node {
 	}
 }
 
+// Config defines all necessary options for database.
+type Config struct {
+	Cache       int              // Memory allowance (MB) to use for caching trie nodes in memory
+	Cleans      *fastcache.Cache // Clean node cache shared with other databases, overrides Cache
+	NoPreimages bool             // Flag whether the preimages of trie keys are discarded
+}
+
 // NewDatabase creates a new trie database to store ephemeral trie content before
 // its written out to disk or garbage collected. No read Cache is created, so all
 // data retrievals will hit the underlying disk database.
 func NewDatabase(diskdb ethdb.KeyValueStore) *Database {
-	return NewDatabaseWithCache(diskdb, 0)
+	return NewDatabaseWithConfig(diskdb, nil)
 }
 
 // NewDatabaseWithCache creates a new trie database to store ephemeral trie content
 // before its written out to disk or garbage collected. It also acts as a read Cache
 // for nodes loaded from disk.
 func NewDatabaseWithCache(diskdb ethdb.KeyValueStore, cache int) *Database {
+	return NewDatabaseWithConfig(diskdb, &Config{Cache: cache})
+}
+
+// NewDatabaseWithConfig creates a new trie database to store ephemeral trie
+// content before its written out to disk or garbage collected. If the config
+// disables preimages, the keys of secure tries are not recorded.
+func NewDatabaseWithConfig(diskdb ethdb.KeyValueStore, config *Config) *Database {
 	var cleans *fastcache.Cache
-	if cache > 0 {
-		cleans = fastcache.New(cache * 1024 * 1024)
+	if config != nil {
+		// Nodes are keyed by their hash, so a clean cache is safe to share
+		// between databases regardless of the disk store they are backed by.
+		if cleans = config.Cleans; cleans == nil && config.Cache > 0 {
+			cleans = fastcache.New(config.Cache * 1024 * 1024)
+		}
 	}
-	return &Database{
+	db := &Database{
 		diskdb: diskdb,
 		cleans: cleans,
 		dirties: map[common.Hash]*cachedNode{{}: {
 			children: make(map[common.Hash]uint16),
 		}},
-		preimages: make(map[common.Hash][]byte),
 	}
+	if config == nil || !config.NoPreimages {
+		db.preimages = make(map[common.Hash][]byte)
+	}
+	return db
 }
 
 // DiskDB retrieves the persistent storage backing the trie database.
@@ -304,6 +328,12 @@ func (db *Database) DiskDB() ethdb.KeyValueReader {
 	return db.diskdb
 }
 
+// NewBatch creates a write-only batch on the persistent storage backing the
+// trie database, to be filled through CommitTo.
+func (db *Database) NewBatch() ethdb.Batch {
+	return db.diskdb.NewBatch()
+}
+
 // InsertBlob writes a new reference tracked blob to the memory database if it's
 // yet unknown. This method should only be used for non-trie nodes that require
 // reference counting, since trie nodes are garbage collected directly through
@@ -346,13 +376,20 @@ func (db *Database) insert(hash common.Hash, size int, node Node) {
 		db.dirties[db.newest].flushNext, db.newest = hash, hash
 	}
 	db.dirtiesSize += common.StorageSize(common.HashLength + entry.size)
+
+	db.insertnodes++
+	db.insertsize += common.StorageSize(common.HashLength + entry.size)
 }
 
 // InsertPreimage writes a new trie Node pre-image to the memory database if it's
-// yet unknown. The method will make a copy of the slice.
+// yet unknown. The method will make a copy of the slice. Nothing is recorded if
+// preimages are disabled.
 //
 // Note, this method assumes that the database's Lock is held!
 func (db *Database) InsertPreimage(hash common.Hash, preimage []byte) {
+	if db.preimages == nil {
+		return
+	}
 	if _, ok := db.preimages[hash]; ok {
 		return
 	}
@@ -748,7 +785,9 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	batch.Reset()
 
 	// Reset the storage counters and bumpd metrics
-	db.preimages = make(map[common.Hash][]byte)
+	if db.preimages != nil {
+		db.preimages = make(map[common.Hash][]byte)
+	}
 	db.preimagesSize = 0
 
 	memcacheCommitTimeTimer.Update(time.Since(start))
@@ -769,7 +808,41 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	return nil
 }
 
-// commit is the private locked version of Commit.
+// CommitTo iterates over all the children of a particular node and writes them,
+// along with the accumulated preimages, into the given batch without flushing
+// it, so that several tries can be persisted in a single atomic write. The
+// returned function moves the committed nodes out of the dirty cache and must
+// only be called once the batch has been written.
+func (db *Database) CommitTo(node common.Hash, batch ethdb.Batch) (func(), error) {
+	var keyBuf [secureKeyLength]byte
+	copy(keyBuf[:], secureKeyPrefix)
+
+	for hash, preimage := range db.preimages {
+		copy(keyBuf[secureKeyPrefixLength:], hash[:])
+		if err := batch.Put(keyBuf[:], preimage); err != nil {
+			log.Error("Failed to commit Preimage from trie database", "err", err)
+			return nil, err
+		}
+	}
+	if err := db.commit(node, batch, nil); err != nil {
+		log.Error("Failed to commit trie from trie database", "err", err)
+		return nil, err
+	}
+	uncache := func() {
+		db.Lock.Lock()
+		defer db.Lock.Unlock()
+
+		batch.Replay(&cleaner{db})
+		if db.preimages != nil {
+			db.preimages = make(map[common.Hash][]byte)
+		}
+		db.preimagesSize = 0
+	}
+	return uncache, nil
+}
+
+// commit is the private locked version of Commit. Without an uncacher the batch
+// is never flushed, leaving its write to the caller.
 func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleaner) error {
 	// If the Node does not exist, it's a previously committed Node
 	node, ok := db.dirties[hash]
@@ -789,7 +862,7 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 		return err
 	}
 	// If we've reached an optimal batch size, commit and start over
-	if batch.ValueSize() >= ethdb.IdealBatchSize {
+	if uncacher != nil && batch.ValueSize() >= ethdb.IdealBatchSize {
 		if err := batch.Write(); err != nil {
 			return err
 		}
@@ -850,6 +923,16 @@ func (c *cleaner) Delete(key []byte) error {
 	panic("not implemented")
 }
 
+// Inserted returns the number of nodes and the data storage inserted into the
+// memory database since its creation. Nodes already cached aren't counted again,
+// so the difference across a trie commit is the data the trie adds.
+func (db *Database) Inserted() (uint64, common.StorageSize) {
+	db.Lock.RLock()
+	defer db.Lock.RUnlock()
+
+	return db.insertnodes, db.insertsize
+}
+
 // Size returns the current storage size of the memory Cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {
@@ -19,6 +19,7 @@ package trie
 import (
 	"testing"
 
+	"github.com/VictoriaMetrics/fastcache"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/ethdb/memorydb"
 )
@@ -31,3 +32,71 @@ func TestDatabaseMetarootFetch(t *testing.T) {
 		t.Fatalf("metaroot retrieval succeeded")
 	}
 }
+
+// Tests that trie databases sharing a clean cache serve each other's committed
+// nodes from memory.
+func TestDatabaseSharedCleanCache(t *testing.T) {
+	cleans := fastcache.New(1024 * 1024)
+	first := NewDatabaseWithConfig(memorydb.New(), &Config{Cleans: cleans})
+	second := NewDatabaseWithConfig(memorydb.New(), &Config{Cleans: cleans})
+
+	trie, _ := New(common.Hash{}, first)
+	for i := byte(0); i < 16; i++ {
+		trie.Update([]byte{i}, common.LeftPadBytes([]byte{i}, 32))
+	}
+	root, _ := trie.Commit(nil)
+	if err := first.Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if _, err := second.Node(root); err != nil {
+		t.Fatalf("shared clean cache missed the root: %v", err)
+	}
+	if _, err := NewDatabaseWithCache(memorydb.New(), 1).Node(root); err == nil {
+		t.Fatalf("unshared database resolved a foreign node")
+	}
+}
+
+// Tests that tries of several databases committed into a shared batch are only
+// persisted once the batch is written, and uncached afterwards.
+func TestDatabaseCommitTo(t *testing.T) {
+	diskdb := memorydb.New()
+	first, second := NewDatabase(diskdb), NewDatabase(diskdb)
+
+	var roots []common.Hash
+	for i, db := range []*Database{first, second} {
+		trie, _ := New(common.Hash{}, db)
+		for j := byte(0); j < 16; j++ {
+			trie.Update([]byte{byte(i), j}, common.LeftPadBytes([]byte{j}, 32))
+		}
+		root, _ := trie.Commit(nil)
+		roots = append(roots, root)
+	}
+	batch := first.NewBatch()
+	var uncaches []func()
+	for i, db := range []*Database{first, second} {
+		uncache, err := db.CommitTo(roots[i], batch)
+		if err != nil {
+			t.Fatalf("failed to commit trie %d: %v", i, err)
+		}
+		uncaches = append(uncaches, uncache)
+	}
+	for i, root := range roots {
+		if ok, _ := diskdb.Has(root[:]); ok {
+			t.Fatalf("trie %d persisted before the batch was written", i)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	for _, uncache := range uncaches {
+		uncache()
+	}
+	for i, db := range []*Database{first, second} {
+		if ok, _ := diskdb.Has(roots[i][:]); !ok {
+			t.Errorf("trie %d root not persisted", i)
+		}
+		if nodes, _ := db.Size(); nodes != 0 {
+			t.Errorf("trie %d left %v of dirty nodes", i, nodes)
+		}
+	}
+}
@@ -105,6 +105,27 @@ func TestSecureGetKey(t *testing.T) {
 	}
 }
 
+// Tests that a trie database with preimages disabled neither caches nor
+// persists the keys of a secure trie.
+func TestSecureNoPreimages(t *testing.T) {
+	diskdb := memorydb.New()
+	triedb := NewDatabaseWithConfig(diskdb, &Config{NoPreimages: true})
+	trie, _ := NewSecure(common.Hash{}, triedb)
+
+	key := []byte("foo")
+	trie.Update(key, []byte("bar"))
+	root, _ := trie.Commit(nil)
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if k := trie.GetKey(crypto.Keccak256(key)); k != nil {
+		t.Errorf("GetKey returned %q, want nil", k)
+	}
+	if has, _ := diskdb.Has(secureKey(crypto.Keccak256Hash(key))); has {
+		t.Errorf("preimage persisted despite being disabled")
+	}
+}
+
 func TestSecureTrieConcurrency(t *testing.T) {
 	// Create an initial trie and copy if for concurrent access
 	_, trie, _ := makeTestSecureTrie()